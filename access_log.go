@@ -0,0 +1,38 @@
+package main
+
+import (
+	"./proxy"
+	"strconv"
+	"time"
+)
+
+// startAccessLog receives the access logs of HAProxy, collects their metrics when LOG_METRICS is enabled,
+// and ships them to the configured log management systems every LOG_SHIPPER_INTERVAL seconds.
+func (m *Serve) startAccessLog() {
+	handlers := []func(entry proxy.HttpLogEntry){}
+	if proxy.IsLogMetricsEnabled() {
+		logMetrics = proxy.NewLogMetrics()
+		handlers = append(handlers, logMetrics.Observe)
+	}
+	interval, _ := strconv.Atoi(proxy.GetSecretOrEnvVar("LOG_SHIPPER_INTERVAL", "5"))
+	if interval <= 0 {
+		interval = 5
+	}
+	for _, shipper := range proxy.GetLogShippers() {
+		handlers = append(handlers, shipper.Add)
+		go m.shipLogs(shipper, time.Duration(interval)*time.Second)
+	}
+	go func() {
+		if err := proxy.ListenAccessLogs(proxy.AccessLogAddress, handlers...); err != nil {
+			logWarnf("%s", err.Error())
+		}
+	}()
+}
+
+func (m *Serve) shipLogs(shipper *proxy.LogShipper, interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := shipper.Flush(); err != nil {
+			logWarnf("%s", err.Error())
+		}
+	}
+}
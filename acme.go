@@ -0,0 +1,34 @@
+package main
+
+import (
+	"./proxy"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+)
+
+var readAcmeChallenge = ioutil.ReadFile
+
+// The tokens of ACME challenges are base64url encoded
+var acmeTokenRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// acmeChallenge answers the ACME HTTP-01 challenge forwarded by the acme-challenge backend with the key authorization
+// written by the certificate tooling into ACME_CHALLENGE_PATH (e.g. certbot --webroot -w <ACME_CHALLENGE_PATH>).
+func (m *Serve) acmeChallenge(w http.ResponseWriter, req *http.Request) {
+	dir := proxy.GetSecretOrEnvVar("ACME_CHALLENGE_PATH", "")
+	token := req.URL.Query().Get("token")
+	if len(dir) == 0 || !acmeTokenRegexp.MatchString(token) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	content, err := readAcmeChallenge(fmt.Sprintf("%s%s%s", dir, proxy.AcmeChallengePath, token))
+	if err != nil {
+		logWarnf("Could not read the ACME challenge %s\n%s", token, err.Error())
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	httpWriterSetContentType(w, "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
@@ -0,0 +1,160 @@
+package actions
+
+import (
+	"../proxy"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var orphansNow = time.Now
+
+// OrphanCollector finds services whose source (Swarm service or Consul catalog entry) no longer exists.
+type OrphanCollector interface {
+	Executable
+	GetOrphans() map[string]time.Time
+}
+
+type Orphans struct {
+	BaseReconfigure
+	Mode string
+	// The duration a service needs to be missing before it is removed or flagged.
+	GracePeriod time.Duration
+	// Whether orphaned services should be removed. If false, they are only flagged.
+	RemoveOrphans bool
+	// Removes an orphaned service. The services are removed through NewRemove if it is nil.
+	RemoveService func(serviceName, aclName string) error
+	mu            *sync.Mutex
+	missingSince  map[string]time.Time
+	orphans       map[string]time.Time
+}
+
+var NewOrphans = func(baseData BaseReconfigure, mode string, gracePeriod time.Duration, removeOrphans bool, removeService func(serviceName, aclName string) error) OrphanCollector {
+	return &Orphans{
+		BaseReconfigure: baseData,
+		Mode:            mode,
+		GracePeriod:     gracePeriod,
+		RemoveOrphans:   removeOrphans,
+		RemoveService:   removeService,
+		mu:              &sync.Mutex{},
+		missingSince:    map[string]time.Time{},
+		orphans:         map[string]time.Time{},
+	}
+}
+
+// Execute compares the services configured in the proxy with their sources.
+// Services missing for longer than the grace period are removed or flagged as orphans.
+func (m *Orphans) Execute(args []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := orphansNow()
+	services := proxy.Instance.GetServices()
+	for name := range m.missingSince {
+		if _, ok := services[name]; !ok {
+			delete(m.missingSince, name)
+			delete(m.orphans, name)
+		}
+	}
+	for name, s := range services {
+		exists, err := m.exists(name, s.OutboundHostname)
+		if err != nil {
+			logWarnf("Could not check whether the service %s exists\n%s", name, err.Error())
+			continue
+		} else if exists {
+			delete(m.missingSince, name)
+			delete(m.orphans, name)
+			continue
+		}
+		since, ok := m.missingSince[name]
+		if !ok {
+			m.missingSince[name] = now
+			continue
+		} else if now.Sub(since) < m.GracePeriod {
+			continue
+		}
+		if m.RemoveOrphans {
+			logWarnf("The service %s has been missing since %s. Removing it from the proxy.", name, since.Format(time.RFC3339))
+			if err := m.remove(name, s.AclName); err != nil {
+				continue
+			}
+			delete(m.missingSince, name)
+			delete(m.orphans, name)
+		} else {
+			if _, flagged := m.orphans[name]; !flagged {
+				logWarnf("The service %s has been missing since %s", name, since.Format(time.RFC3339))
+			}
+			m.orphans[name] = since
+		}
+	}
+	return nil
+}
+
+func (m *Orphans) remove(serviceName, aclName string) error {
+	if m.RemoveService != nil {
+		return m.RemoveService(serviceName, aclName)
+	}
+	remove := NewRemove(serviceName, aclName, m.ConfigsPath, m.TemplatesPath, m.ConsulAddresses, m.InstanceName, m.Mode, RemoveOptions{})
+	return remove.Execute([]string{})
+}
+
+// GetOrphans returns the flagged services together with the time since when they are missing.
+func (m *Orphans) GetOrphans() map[string]time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	orphans := map[string]time.Time{}
+	for name, since := range m.orphans {
+		orphans[name] = since
+	}
+	return orphans
+}
+
+// exists returns whether the source of the service exists.
+// In the swarm mode, only the hosts the DNS server answered do not exist are missing.
+// Other failures (e.g. a DNS timeout) are returned as errors so that the service is not mistaken for an orphan.
+func (m *Orphans) exists(serviceName, outboundHostname string) (bool, error) {
+	if isSwarm(m.Mode) {
+		var err error
+		for _, host := range (proxy.Service{ServiceName: serviceName, OutboundHostname: outboundHostname}).GetHosts() {
+			hostErr := isReachable(context.Background(), host)
+			if hostErr == nil {
+				return true, nil
+			} else if !isHostNotFound(hostErr) && !os.IsNotExist(hostErr) {
+				err = hostErr
+			}
+		}
+		return false, err
+	}
+	var err error
+	for _, address := range m.ConsulAddresses {
+		if !strings.HasPrefix(strings.ToLower(address), "http") {
+			address = fmt.Sprintf("http://%s", address)
+		}
+		var resp *http.Response
+		resp, err = httpGet(fmt.Sprintf("%s/v1/catalog/service/%s", address, serviceName))
+		if err != nil {
+			continue
+		}
+		body, readErr := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("Consul responded with the status code %d", resp.StatusCode)
+			continue
+		} else if readErr != nil {
+			err = readErr
+			continue
+		}
+		nodes := []interface{}{}
+		json.Unmarshal(body, &nodes)
+		return len(nodes) > 0, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("No Consul address is configured")
+	}
+	return false, err
+}
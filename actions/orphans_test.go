@@ -0,0 +1,182 @@
+// +build !integration
+
+package actions
+
+import (
+	"../proxy"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type OrphansTestSuite struct {
+	suite.Suite
+	now       time.Time
+	proxyMock *ProxyMock
+}
+
+func TestOrphansUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	logWarnf = func(format string, v ...interface{}) {}
+	logErrorf = func(format string, v ...interface{}) {}
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	lookupHostOrig := lookupHost
+	defer func() { lookupHost = lookupHostOrig }()
+	orphansNowOrig := orphansNow
+	defer func() { orphansNow = orphansNowOrig }()
+	newRemoveOrig := NewRemove
+	defer func() { NewRemove = newRemoveOrig }()
+	suite.Run(t, new(OrphansTestSuite))
+}
+
+func (s *OrphansTestSuite) SetupTest() {
+	s.now = time.Date(2017, 3, 2, 10, 20, 30, 0, time.UTC)
+	orphansNow = func() time.Time {
+		return s.now
+	}
+	s.proxyMock = getProxyMock("GetServices")
+	s.proxyMock.On("GetServices").Return(map[string]proxy.Service{
+		"my-service": {ServiceName: "my-service"},
+	})
+	proxy.Instance = s.proxyMock
+	lookupHost = func(host string) ([]string, error) {
+		return []string{}, &net.DNSError{Err: "no such host", Name: host}
+	}
+}
+
+// Execute
+
+func (s *OrphansTestSuite) Test_Execute_FlagsService_WhenMissingLongerThanGracePeriod() {
+	orphans := NewOrphans(BaseReconfigure{}, "swarm", time.Minute, false, nil)
+
+	orphans.Execute([]string{})
+	s.Empty(orphans.GetOrphans())
+	missingSince := s.now
+	s.now = s.now.Add(2 * time.Minute)
+	orphans.Execute([]string{})
+
+	s.Equal(map[string]time.Time{"my-service": missingSince}, orphans.GetOrphans())
+}
+
+func (s *OrphansTestSuite) Test_Execute_DoesNotFlagService_WhenWithinGracePeriod() {
+	orphans := NewOrphans(BaseReconfigure{}, "swarm", time.Minute, false, nil)
+
+	orphans.Execute([]string{})
+	s.now = s.now.Add(30 * time.Second)
+	orphans.Execute([]string{})
+
+	s.Empty(orphans.GetOrphans())
+}
+
+func (s *OrphansTestSuite) Test_Execute_DoesNotFlagService_WhenLookupFailsTemporarily() {
+	lookupHost = func(host string) ([]string, error) {
+		return []string{}, &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+	}
+	orphans := NewOrphans(BaseReconfigure{}, "swarm", time.Minute, false, nil)
+
+	orphans.Execute([]string{})
+	s.now = s.now.Add(2 * time.Minute)
+	orphans.Execute([]string{})
+
+	s.Empty(orphans.GetOrphans())
+}
+
+func (s *OrphansTestSuite) Test_Execute_UnflagsService_WhenItExistsAgain() {
+	orphans := NewOrphans(BaseReconfigure{}, "swarm", time.Minute, false, nil)
+	orphans.Execute([]string{})
+	s.now = s.now.Add(2 * time.Minute)
+	orphans.Execute([]string{})
+	lookupHost = func(host string) ([]string, error) {
+		return []string{"10.0.0.2"}, nil
+	}
+
+	orphans.Execute([]string{})
+
+	s.Empty(orphans.GetOrphans())
+}
+
+func (s *OrphansTestSuite) Test_Execute_RemovesService_WhenRemoveOrphansIsTrue() {
+	mockObj := getRemoveMock()
+	actualServiceName := ""
+	NewRemove = func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string, options RemoveOptions) Removable {
+		actualServiceName = serviceName
+		return mockObj
+	}
+	orphans := NewOrphans(BaseReconfigure{}, "swarm", time.Minute, true, nil)
+
+	orphans.Execute([]string{})
+	mockObj.AssertNotCalled(s.T(), "Execute", []string{})
+	s.now = s.now.Add(2 * time.Minute)
+	orphans.Execute([]string{})
+
+	s.Equal("my-service", actualServiceName)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+	s.Empty(orphans.GetOrphans())
+}
+
+func (s *OrphansTestSuite) Test_Execute_RemovesServiceThroughRemoveService_WhenSet() {
+	actualServiceName := ""
+	orphans := NewOrphans(BaseReconfigure{}, "swarm", time.Minute, true, func(serviceName, aclName string) error {
+		actualServiceName = serviceName
+		return nil
+	})
+
+	orphans.Execute([]string{})
+	s.now = s.now.Add(2 * time.Minute)
+	orphans.Execute([]string{})
+
+	s.Equal("my-service", actualServiceName)
+	s.Empty(orphans.GetOrphans())
+}
+
+func (s *OrphansTestSuite) Test_Execute_UsesConsulCatalog_WhenModeIsDefault() {
+	actualPath := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actualPath = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("[]"))
+	}))
+	defer func() { srv.Close() }()
+	orphans := NewOrphans(BaseReconfigure{ConsulAddresses: []string{srv.URL}}, "default", 0, false, nil)
+
+	orphans.Execute([]string{})
+	orphans.Execute([]string{})
+
+	s.Equal("/v1/catalog/service/my-service", actualPath)
+	s.Contains(orphans.GetOrphans(), "my-service")
+}
+
+// Mock
+
+type RemoveMock struct {
+	mock.Mock
+}
+
+func (m *RemoveMock) Execute(args []string) error {
+	params := m.Called(args)
+	return params.Error(0)
+}
+
+func (m *RemoveMock) Drain() error {
+	params := m.Called()
+	return params.Error(0)
+}
+
+func (m *RemoveMock) GetRemoved() Removed {
+	params := m.Called()
+	return params.Get(0).(Removed)
+}
+
+func getRemoveMock() *RemoveMock {
+	mockObj := new(RemoveMock)
+	mockObj.On("Execute", mock.Anything).Return(nil)
+	mockObj.On("Drain").Return(nil)
+	mockObj.On("GetRemoved").Return(Removed{})
+	return mockObj
+}
@@ -4,6 +4,7 @@ import (
 	"../proxy"
 	"../registry"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -21,6 +22,8 @@ var mu = &sync.Mutex{}
 
 type Reconfigurable interface {
 	Executable
+	ExecuteContext(ctx context.Context) error
+	Import(ctx context.Context) error
 	GetData() (BaseReconfigure, proxy.Service)
 	ReloadAllServices(addresses []string, instanceName, mode, listenerAddress string) error
 	GetTemplates(sr *proxy.Service) (front, back string, err error)
@@ -52,37 +55,84 @@ var NewReconfigure = func(baseData BaseReconfigure, serviceData proxy.Service, m
 
 // TODO: Remove args
 func (m *Reconfigure) Execute(args []string) error {
+	return m.ExecuteContext(context.Background())
+}
+
+// ExecuteContext reconfigures the proxy unless the context is done before the configuration is written.
+// DNS lookups and registry requests are limited to LOOKUP_TIMEOUT and REGISTRY_TIMEOUT.
+func (m *Reconfigure) ExecuteContext(ctx context.Context) error {
+	if err := m.writeConfigs(ctx); err != nil {
+		return err
+	}
+	// Critical services are taken into account by the health endpoint so their changes are not delayed by bulk reconfigurations
+	reload := Reload{}
+	if m.Critical {
+		reload.Priority = proxy.ReloadUrgent
+	}
+	if err := reload.Execute(false, ""); err != nil {
+		return err
+	}
+	return m.publish(ctx)
+}
+
+// Import writes the configuration of the service without reloading the proxy.
+// It is used to import many services that are served by a single reload afterwards.
+func (m *Reconfigure) Import(ctx context.Context) error {
+	if err := m.writeConfigs(ctx); err != nil {
+		return err
+	}
+	return m.publish(ctx)
+}
+
+func (m *Reconfigure) publish(ctx context.Context) error {
+	proxy.PublishEvent(proxy.Event{Type: proxy.EventReconfigure, ServiceName: m.ServiceName})
+	if len(m.ConsulAddresses) > 0 || !isSwarm(m.Mode) {
+		if err := m.putToConsul(ctx, m.ConsulAddresses, m.Service, m.InstanceName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeConfigs writes the configuration of the service. The proxy is reloaded afterwards,
+// without holding mu, so that the reloads of concurrent reconfigurations can be coalesced.
+func (m *Reconfigure) writeConfigs(ctx context.Context) error {
 	mu.Lock()
 	defer mu.Unlock()
-	if isSwarm(m.Mode) && !m.skipAddressValidation {
-		host := m.ServiceName
-		if len(m.OutboundHostname) > 0 {
-			host = m.OutboundHostname
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// Static responses are served by the proxy itself so the service does not need to be running
+	if isSwarm(m.Mode) && !m.skipAddressValidation && !m.IsStaticResponse() {
+		// The service is reachable as long as one of its hosts is
+		var err error
+		for _, host := range m.GetHosts() {
+			if err = isReachable(ctx, host); err == nil {
+				break
+			}
 		}
-		if _, err := lookupHost(host); err != nil {
-			logPrintf("Could not reach the service %s. Is the service running and connected to the same network as the proxy?", host)
+		if err != nil {
+			logErrorf("Could not reach the service %s. Is the service running and connected to the same network as the proxy?", strings.Join(m.GetHosts(), ", "))
 			return err
 		}
 	}
-	if err := m.createConfigs(m.TemplatesPath, &m.Service); err != nil {
-		return err
+	if isSwarm(m.Mode) && m.ZoneAware {
+		m.Tasks = getHostsZoneTasks(ctx, m.GetHosts())
 	}
-	if !m.hasTemplate() {
-		proxy.Instance.AddService(m.Service)
+	for _, warning := range proxy.GetFeatureWarnings(m.Service) {
+		logWarnf("%s", warning)
 	}
-	if err := proxy.Instance.CreateConfigFromTemplates(); err != nil {
+	// Stop before anything is written so that the proxy is not left with a partial configuration
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	reload := Reload{}
-	if err := reload.Execute(false, ""); err != nil {
+	if err := m.createConfigs(ctx, m.TemplatesPath, &m.Service); err != nil {
 		return err
 	}
-	if len(m.ConsulAddresses) > 0 || !isSwarm(m.Mode) {
-		if err := m.putToConsul(m.ConsulAddresses, m.Service, m.InstanceName); err != nil {
-			return err
-		}
+	if !m.hasTemplate() {
+		proxy.Instance.AddService(m.Service)
 	}
-	return nil
+	return proxy.Instance.CreateConfigFromTemplates()
 }
 
 func (m *Reconfigure) GetData() (BaseReconfigure, proxy.Service) {
@@ -122,7 +172,7 @@ func (m *Reconfigure) reloadFromRegistry(addresses []string, instanceName, mode
 		} else {
 			servicesUrl = fmt.Sprintf("%s/v1/catalog/services", address)
 		}
-		resp, err = http.Get(servicesUrl)
+		resp, err = getRegistryClient().Get(servicesUrl)
 		if err == nil {
 			found = true
 			break
@@ -161,14 +211,12 @@ func (m *Reconfigure) reloadFromRegistry(addresses []string, instanceName, mode
 		s := <-c
 		if len(s.ServiceDest) > 0 && len(s.ServiceDest[0].ServicePath) > 0 {
 			logPrintf("\tConfiguring %s", s.ServiceName)
-			m.createConfigs(m.TemplatesPath, &s)
+			m.createConfigs(context.Background(), m.TemplatesPath, &s)
 		}
 	}
-	if err := proxy.Instance.CreateConfigFromTemplates(); err != nil {
-		return err
-	}
+	// The configuration is created under mu by the reload
 	reload := Reload{}
-	return reload.Execute(false, "")
+	return reload.Execute(true, "")
 }
 
 func (m *Reconfigure) getService(addresses []string, serviceName, instanceName string, c chan proxy.Service) {
@@ -200,7 +248,7 @@ func (m *Reconfigure) getService(addresses []string, serviceName, instanceName s
 func (m *Reconfigure) getServiceAttribute(addresses []string, serviceName, key, instanceName string) (string, bool) {
 	for _, address := range addresses {
 		url := fmt.Sprintf("%s/v1/kv/%s/%s/%s?raw", address, instanceName, serviceName, key)
-		resp, err := http.Get(url)
+		resp, err := getRegistryClient().Get(url)
 		if err == nil && resp.StatusCode == http.StatusOK {
 			defer resp.Body.Close()
 			body, _ := ioutil.ReadAll(resp.Body)
@@ -210,7 +258,7 @@ func (m *Reconfigure) getServiceAttribute(addresses []string, serviceName, key,
 	return "", false
 }
 
-func (m *Reconfigure) createConfigs(templatesPath string, sr *proxy.Service) error {
+func (m *Reconfigure) createConfigs(ctx context.Context, templatesPath string, sr *proxy.Service) error {
 	logPrintf("Creating configuration for the service %s", sr.ServiceName)
 	feTemplate, beTemplate, err := m.GetTemplates(sr)
 	if err != nil {
@@ -234,14 +282,14 @@ func (m *Reconfigure) createConfigs(templatesPath string, sr *proxy.Service) err
 			BeTemplate:    beTemplate,
 			ServiceName:   sr.ServiceName,
 		}
-		if err = registryInstance.CreateConfigs(&args); err != nil {
+		if err = registryInstance.CreateConfigs(ctx, &args); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (m *Reconfigure) putToConsul(addresses []string, sr proxy.Service, instanceName string) error {
+func (m *Reconfigure) putToConsul(ctx context.Context, addresses []string, sr proxy.Service, instanceName string) error {
 	path := []string{}
 	port := ""
 	if len(sr.ServiceDest) > 0 {
@@ -261,7 +309,7 @@ func (m *Reconfigure) putToConsul(addresses []string, sr proxy.Service, instance
 		ConsulTemplateBePath: sr.ConsulTemplateBePath,
 		Port:                 port,
 	}
-	if err := registryInstance.PutService(addresses, instanceName, r); err != nil {
+	if err := registryInstance.PutService(ctx, addresses, instanceName, r); err != nil {
 		return err
 	}
 	return nil
@@ -269,15 +317,17 @@ func (m *Reconfigure) putToConsul(addresses []string, sr proxy.Service, instance
 
 func (m *Reconfigure) GetTemplates(sr *proxy.Service) (front, back string, err error) {
 	if len(sr.TemplateFePath) > 0 && len(sr.TemplateBePath) > 0 {
-		feTmpl, err := readTemplateFile(sr.TemplateFePath)
+		feTmpl, err := readTemplateFile(proxy.ResolveDockerPath(sr.TemplateFePath))
 		if err != nil {
 			return "", "", err
 		}
-		beTmpl, err := readTemplateFile(sr.TemplateBePath)
+		beTmpl, err := readTemplateFile(proxy.ResolveDockerPath(sr.TemplateBePath))
 		if err != nil {
 			return "", "", err
 		}
-		front, back = m.parseTemplate(string(feTmpl), "", string(beTmpl), sr)
+		if front, back, err = m.parseTemplate(string(feTmpl), "", string(beTmpl), sr); err != nil {
+			return "", "", err
+		}
 	} else if len(sr.ConsulTemplateFePath) > 0 && len(sr.ConsulTemplateBePath) > 0 { // Sunset
 		front, err = m.getConsulTemplateFromFile(sr.ConsulTemplateFePath)
 		if err != nil {
@@ -288,145 +338,35 @@ func (m *Reconfigure) GetTemplates(sr *proxy.Service) (front, back string, err e
 			return "", "", err
 		}
 	} else {
-		if len(sr.ReqMode) == 0 {
-			sr.ReqMode = "http"
-		}
-		m.formatData(sr)
-		front, back = m.parseTemplate(
-			"",
-			m.getUsersList(sr),
-			m.getBackTemplate(sr),
-			sr)
+		back = proxy.HaProxy{ConfigsPath: m.ConfigsPath}.RenderBackend(sr, m.Mode)
 	}
 	return front, back, nil
 }
 
-// TODO: Move to ha_proxy.go
-func (m *Reconfigure) formatData(sr *proxy.Service) {
-	sr.AclCondition = ""
-	if len(sr.AclName) == 0 {
-		sr.AclName = sr.ServiceName
-	}
-	sr.Host = m.ServiceName
-	if len(m.OutboundHostname) > 0 {
-		sr.Host = m.OutboundHostname
-	}
-	if len(sr.ServiceColor) > 0 {
-		sr.FullServiceName = fmt.Sprintf("%s-%s", sr.ServiceName, sr.ServiceColor)
-	} else {
-		sr.FullServiceName = sr.ServiceName
-	}
-	if len(sr.PathType) == 0 {
-		sr.PathType = "path_beg"
-	}
-	for i, sd := range sr.ServiceDest {
-		if sd.SrcPort > 0 {
-			sr.ServiceDest[i].SrcPortAclName = fmt.Sprintf(" srcPort_%s%d", sr.ServiceName, sd.SrcPort)
-			sr.ServiceDest[i].SrcPortAcl = fmt.Sprintf(`
-    acl srcPort_%s%d dst_port %d`, sr.ServiceName, sd.SrcPort, sd.SrcPort)
+// parseTemplate renders the templates of the service. The templates can look up only the services of the namespace of the service.
+func (m *Reconfigure) parseTemplate(front, usersList, back string, sr *proxy.Service) (pFront, pBack string, err error) {
+	funcs := proxy.TemplateFuncs(func() map[string]proxy.Service {
+		services := map[string]proxy.Service{}
+		for name, s := range proxy.Instance.GetServices() {
+			if s.Namespace == sr.Namespace {
+				services[name] = s
+			}
 		}
-	}
-}
-
-// TODO: Move to ha_proxy.go
-func (m *Reconfigure) getBackTemplate(sr *proxy.Service) string {
-	back := m.getBackTemplateProtocol("http", sr)
-	if sr.HttpsPort > 0 {
-		back += fmt.Sprintf(
-			`
-%s`,
-			m.getBackTemplateProtocol("https", sr))
-	}
-	return back
-}
-
-func (m *Reconfigure) getBackTemplateProtocol(protocol string, sr *proxy.Service) string {
-	prefix := ""
-	if strings.EqualFold(protocol, "https") {
-		prefix = "https-"
-	}
-	rmode := sr.ReqMode
-	if strings.EqualFold(sr.ReqMode, "sni") {
-		rmode = "tcp"
-	}
-	tmpl := fmt.Sprintf(`{{range .ServiceDest}}
-backend %s{{$.ServiceName}}-be{{.Port}}
-    mode %s`,
-		prefix, rmode,
-	)
-	if strings.EqualFold(rmode, "http") {
-		tmpl += `
-    http-request add-header X-Forwarded-Proto https if { ssl_fc }`
-	}
-	// TODO: Deprecated (dec. 2016).
-	if len(sr.TimeoutServer) > 0 {
-		tmpl += `
-    timeout server {{$.TimeoutServer}}s`
-	}
-	if len(sr.TimeoutTunnel) > 0 {
-		tmpl += `
-    timeout tunnel {{$.TimeoutTunnel}}s`
-	}
-	if len(sr.ReqRepSearch) > 0 && len(sr.ReqRepReplace) > 0 {
-		tmpl += `
-    reqrep {{$.ReqRepSearch}}     {{$.ReqRepReplace}}`
-	}
-	if len(sr.ReqPathSearch) > 0 && len(sr.ReqPathReplace) > 0 {
-		tmpl += `
-    http-request set-path %[path,regsub({{$.ReqPathSearch}},{{$.ReqPathReplace}})]`
-	}
-	if strings.EqualFold(m.Mode, "service") || strings.EqualFold(m.Mode, "swarm") {
-		if strings.EqualFold(protocol, "https") {
-			tmpl += `
-    server {{$.ServiceName}} {{$.Host}}:{{$.HttpsPort}}{{if eq $.SslVerifyNone true}} ssl verify none{{end}}`
-		} else {
-			tmpl += `
-    server {{$.ServiceName}} {{$.Host}}:{{.Port}}{{if eq $.SslVerifyNone true}} ssl verify none{{end}}`
+		return services
+	})
+	contents := []string{}
+	for _, content := range []string{front, usersList, back} {
+		tmpl, err := template.New("template").Funcs(funcs).Parse(content)
+		if err != nil {
+			return "", "", fmt.Errorf("Could not parse the template of the service %s\n%s", sr.ServiceName, err.Error())
 		}
-	} else { // It's Consul
-		tmpl += `
-    {{"{{"}}range $i, $e := service "{{$.FullServiceName}}" "any"{{"}}"}}
-    server {{"{{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}}"}}{{if eq $.SkipCheck false}} check{{if eq $.SslVerifyNone true}} ssl verify none{{end}}{{end}}
-    {{"{{end}}"}}`
-	}
-	if len(sr.Users) > 0 {
-		tmpl += `
-    acl {{$.ServiceName}}UsersAcl http_auth({{$.ServiceName}}Users)
-    http-request auth realm {{$.ServiceName}}Realm if !{{$.ServiceName}}UsersAcl
-    http-request del-header Authorization`
-	} else if len(proxy.GetSecretOrEnvVar("USERS", "")) > 0 {
-		tmpl += `
-    acl defaultUsersAcl http_auth(defaultUsers)
-    http-request auth realm defaultRealm if !defaultUsersAcl
-    http-request del-header Authorization`
-	}
-	tmpl += "{{end}}"
-	return tmpl
-}
-
-func (m *Reconfigure) getUsersList(sr *proxy.Service) string {
-	if len(sr.Users) > 0 {
-		return `userlist {{.ServiceName}}Users{{range .Users}}
-    user {{.Username}} {{if .PassEncrypted}}password{{end}}{{if not .PassEncrypted}}insecure-password{{end}} {{.Password}}{{end}}
-
-`
-	}
-	return ""
-}
-
-func (m *Reconfigure) parseTemplate(front, usersList, back string, sr *proxy.Service) (pFront, pBack string) {
-	var ctFront bytes.Buffer
-	if len(front) > 0 {
-		tmplFront, _ := template.New("template").Parse(front)
-		tmplFront.Execute(&ctFront, sr)
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, sr); err != nil {
+			return "", "", fmt.Errorf("Could not render the template of the service %s\n%s", sr.ServiceName, err.Error())
+		}
+		contents = append(contents, rendered.String())
 	}
-	tmplUsersList, _ := template.New("template").Parse(usersList)
-	tmplBack, _ := template.New("template").Parse(back)
-	var ctUsersList bytes.Buffer
-	var ctBack bytes.Buffer
-	tmplUsersList.Execute(&ctUsersList, sr)
-	tmplBack.Execute(&ctBack, sr)
-	return ctFront.String(), ctUsersList.String() + ctBack.String()
+	return contents[0], contents[1] + contents[2], nil
 }
 
 // TODO: Move to registry package
@@ -5,6 +5,7 @@ package actions
 import (
 	"../proxy"
 	"../registry"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/stretchr/testify/mock"
@@ -14,6 +15,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 type ReconfigureTestSuite struct {
@@ -74,6 +76,7 @@ backend myService-be
 
 func TestReconfigureUnitTestSuite(t *testing.T) {
 	logPrintf = func(format string, v ...interface{}) {}
+	logErrorf = func(format string, v ...interface{}) {}
 	s := new(ReconfigureTestSuite)
 	s.ServiceName = "myService"
 	s.PutPathResponse = "PUT_PATH_OK"
@@ -271,6 +274,179 @@ backend myService-be1234
 	s.Equal(expected, actual)
 }
 
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsPresetChecksAndTimeouts_WhenTcpPresetIsSet() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.ReqMode = "tcp"
+	s.reconfigure.TcpPreset = "redis"
+	s.reconfigure.SendProxyProtocol = true
+	s.reconfigure.Service.ServiceDest[0].Port = "6379"
+	expected := `
+backend myService-be6379
+    mode tcp
+    timeout tunnel 3600s
+    option tcp-check
+    tcp-check send PING\r\n
+    tcp-check expect string +PONG
+    server myService myService:6379 check send-proxy`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsMqttChecksAndStickiness_WhenMqttStickinessIsSet() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.ReqMode = "tcp"
+	s.reconfigure.TcpPreset = "mqtt"
+	s.reconfigure.MqttStickiness = true
+	s.reconfigure.Service.ServiceDest[0].Port = "1883"
+	expected := `
+backend myService-be1883
+    mode tcp
+    timeout tunnel 3600s
+    option tcp-check
+    tcp-check send-binary 101500044d5154540402003c00096466702d636865636b
+    tcp-check expect binary 2002
+    tcp-check send-binary e000
+    tcp-request inspect-delay 5s
+    tcp-request content accept if { lua.mqtt_client_id -m found }
+    stick-table type string len 128 size 100k expire 3600s
+    stick on lua.mqtt_client_id
+    server myService myService:1883 check`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsWriterAndReaderBackends_WhenDbRolesAreSet() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.ReqMode = "tcp"
+	s.reconfigure.DbWriters = []string{"pg-0", "pg-1"}
+	s.reconfigure.DbReaders = []string{"pg-2", "pg-3"}
+	s.reconfigure.Service.ServiceDest = []proxy.ServiceDest{
+		{Port: "5432", SrcPort: 5432, DbRole: "writer"},
+		{Port: "5432", SrcPort: 5433, DbRole: "reader"},
+		{Port: "9187", SrcPort: 9187},
+	}
+	expected := `
+backend myService-be5432
+    mode tcp
+    server myService_writer_0 pg-0:5432 check on-marked-down shutdown-sessions
+    server myService_writer_1 pg-1:5432 check on-marked-down shutdown-sessions backup
+backend myService-be5433
+    mode tcp
+    balance leastconn
+    server myService_reader_0 pg-2:5432 check
+    server myService_reader_1 pg-3:5432 check
+    server myService_writer_0 pg-0:5432 check backup
+    server myService_writer_1 pg-1:5432 check backup
+backend myService-be9187
+    mode tcp
+    server myService myService:9187`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_NamesTcpBackendsAfterPorts_WhenDbRolesAreNotSet() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.ReqMode = "tcp"
+	s.reconfigure.Service.ServiceDest = []proxy.ServiceDest{{Port: "5432", SrcPort: 15432}}
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Contains(actual, "\nbackend myService-be5432\n")
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_ChecksPrimaryOfWriters_WhenDbWriterCheckCommandIsSet() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.ReqMode = "tcp"
+	s.reconfigure.DbWriters = []string{"pg-0", "pg-1"}
+	s.reconfigure.DbReaders = []string{"pg-1"}
+	s.reconfigure.DbWriterCheckCommand = "/scripts/is-primary.sh"
+	s.reconfigure.ExternalCheckCommand = "/scripts/check-lag.sh"
+	s.reconfigure.Service.ServiceDest = []proxy.ServiceDest{
+		{Port: "5432", SrcPort: 5432, DbRole: "writer"},
+		{Port: "5432", SrcPort: 5433, DbRole: "reader"},
+	}
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Contains(actual, `
+    option external-check
+    external-check command /scripts/is-primary.sh
+    balance first
+    server myService_writer_0 pg-0:5432 check on-marked-down shutdown-sessions
+    server myService_writer_1 pg-1:5432 check on-marked-down shutdown-sessions
+`)
+	s.Contains(actual, `
+    option external-check
+    external-check command /scripts/check-lag.sh
+    balance leastconn
+    server myService_reader_0 pg-1:5432 check`)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_SilencesLogs_WhenLoggingIsDisabled() {
+	s.reconfigure.LoggingDisabled = true
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Contains(actual, `
+    http-request set-log-level silent
+`)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_SamplesLogs_WhenLogSampleRateIsSet() {
+	s.reconfigure.LogSampleRate = 10
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Contains(actual, `
+    http-request set-log-level silent if { rand(100) ge 10 }`)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DeniesRequests_WhenServiceIsInMaintenance() {
+	s.reconfigure.Maintenance = true
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Contains(actual, `
+    http-request deny deny_status 503`)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_RejectsConnections_WhenTcpServiceIsInMaintenance() {
+	s.reconfigure.Maintenance = true
+	s.reconfigure.ReqMode = "tcp"
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Contains(actual, `
+    tcp-request content reject`)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsFaultRules_WhenFaultInjectionIsEnabled() {
+	faultInjectionOrig := os.Getenv("FAULT_INJECTION")
+	defer func() { os.Setenv("FAULT_INJECTION", faultInjectionOrig) }()
+	os.Setenv("FAULT_INJECTION", "true")
+	s.reconfigure.ConfigsPath = "/cfg"
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Contains(actual, `
+    http-request set-var(txn.dfp_fault_delay) str(myService),map_str_int(/cfg/faults-delay.map,0)
+    http-request lua.fault-delay if { var(txn.dfp_fault_delay) -m int gt 0 }
+    http-request set-var(txn.dfp_fault_abort) str(myService),map_str_int(/cfg/faults-abort.map,0)
+    http-request deny deny_status 500 if { rand(100),sub(txn.dfp_fault_abort) -m int lt 0 }`)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DoesNotAddFaultRules_WhenFaultInjectionIsDisabled() {
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.NotContains(actual, "fault")
+}
+
 func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpAuth_WhenModeIsSwarmAndUsersEnvIsPresent() {
 	usersOrig := os.Getenv("USERS")
 	defer func() { os.Setenv("USERS", usersOrig) }()
@@ -336,6 +512,48 @@ backend https-myService-be1234
 	s.Equal(expectedBack, actualBack)
 }
 
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsBackendSniAndCaFile_WhenHttpsPortIsPresent() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    server myService myService:1234
+
+backend https-myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    server myService myService:4321 ssl verify required ca-file /run/secrets/backend-ca sni str(api.acme.com) verifyhost api.acme.com`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.Mode = "service"
+	s.reconfigure.HttpsPort = 4321
+	s.reconfigure.BackendCaFile = "docker-secret://backend-ca"
+	s.reconfigure.BackendSni = "api.acme.com"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsBackendSniWithoutVerification_WhenSslVerifyNoneIsTrue() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    server myService myService:1234 ssl verify none
+
+backend https-myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    server myService myService:4321 ssl verify none sni str(api.acme.com)`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.Mode = "service"
+	s.reconfigure.HttpsPort = 4321
+	s.reconfigure.SslVerifyNone = true
+	s.reconfigure.BackendSni = "api.acme.com"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
 func (s ReconfigureTestSuite) Test_GetTemplates_AddsTimeoutServer_WhenPresent() {
 	expectedBack := `
 backend myService-be1234
@@ -368,6 +586,480 @@ backend myService-be1234
 	s.Equal(expectedBack, actualBack)
 }
 
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsStaticResponseWithoutServers_WhenStaticResponseStatusIsSet() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    http-request return status 200 content-type "text/plain" string "User-agent: *\nDisallow: \"/private\" \$HOME"`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.StaticResponseStatus = 200
+	s.reconfigure.StaticResponseBody = "User-agent: *\nDisallow: \"/private\" $HOME"
+	s.reconfigure.Mode = "service"
+	actualFront, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal("", actualFront)
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsStaticResponseContentType_WhenPresent() {
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.StaticResponseStatus = 200
+	s.reconfigure.StaticResponseBody = `{"status":"ok"}`
+	s.reconfigure.StaticResponseContentType = "application/json"
+	s.reconfigure.Mode = "service"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Contains(actualBack, `
+    http-request return status 200 content-type "application/json" string "{\"status\":\"ok\"}"`)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsStaticResponseWithoutBody_WhenBodyIsEmpty() {
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.StaticResponseStatus = 204
+	s.reconfigure.Mode = "service"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.True(strings.HasSuffix(actualBack, `
+    http-request return status 204`))
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsServiceDestTimeouts_WhenPresent() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    timeout server 30s
+    timeout tunnel 3600s
+    server myService myService:1234
+backend myService-be4321
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    timeout server 9999s
+    server myService myService:4321`
+	s.reconfigure.ServiceDest = []proxy.ServiceDest{
+		{Port: "1234", ServicePath: []string{"/long"}, TimeoutServer: "30", TimeoutTunnel: "3600"},
+		{Port: "4321", ServicePath: []string{"/short"}},
+	}
+	s.reconfigure.TimeoutServer = "9999"
+	s.reconfigure.Mode = "service"
+	actualFront, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal("", actualFront)
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsExternalCheck_WhenExternalCheckCommandIsPresent() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    option external-check
+    external-check command /scripts/check-lag.sh
+    server myService myService:1234 check`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.ExternalCheckCommand = "/scripts/check-lag.sh"
+	s.reconfigure.Mode = "service"
+	actualFront, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal("", actualFront)
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHostHeader_WhenSetHostHeaderIsPresent() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    http-request set-header Host my-saas.com
+    server myService myService:1234`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.SetHostHeader = "my-saas.com"
+	s.reconfigure.Mode = "service"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsSetPath_WhenStripPathAndAddPathPrefixArePresent() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    http-request set-path %[path,regsub(^/path-1/?,/)] if { path /path-1 } || { path_beg /path-1/ }
+    http-request set-path %[path,regsub(^/path-2/?,/)] if { path /path-2 } || { path_beg /path-2/ }
+    http-request set-path /internal%[path]
+    server myService myService:1234`
+	s.reconfigure.ServiceDest = []proxy.ServiceDest{
+		{Port: "1234", ServicePath: []string{"/path-1", "/path-2"}},
+	}
+	s.reconfigure.StripPath = true
+	s.reconfigure.AddPathPrefix = "/internal"
+	s.reconfigure.Mode = "service"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_EscapesStripPath_WhenPathHasRegexpMetacharacters() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    http-request set-path %[path,regsub(^/v1[.]0/c[+][+]/?,/)] if { path /v1.0/c++ } || { path_beg /v1.0/c++/ }
+    http-request set-path %[path,regsub(^/?,/)] if { path_beg / }
+    server myService myService:1234`
+	s.reconfigure.ServiceDest = []proxy.ServiceDest{
+		{Port: "1234", ServicePath: []string{"/v1.0/c++/", "/"}},
+	}
+	s.reconfigure.StripPath = true
+	s.reconfigure.Mode = "service"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsCorsPreflight_WhenCorsPreflightIsTrue() {
+	s.reconfigure.Users = []proxy.User{{Username: "user-1", Password: "pass-1"}}
+	expectedBack := `userlist myServiceUsers
+    user user-1 insecure-password pass-1
+
+
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    acl myServicePreflight req.hdr(Access-Control-Request-Method) -m found
+    http-request set-header X-Dfp-Cors-Allow-Origins "https://acme.com,https://www.acme.com" if METH_OPTIONS myServicePreflight
+    http-request set-header X-Dfp-Cors-Allow-Headers "Content-Type, X-Requested-With" if METH_OPTIONS myServicePreflight
+    http-request set-header X-Dfp-Cors-Max-Age 3600 if METH_OPTIONS myServicePreflight
+    http-request use-service lua.cors-preflight if METH_OPTIONS myServicePreflight
+    server myService myService:1234
+    acl myServiceUsersAcl http_auth(myServiceUsers)
+    http-request auth realm myServiceRealm if !myServiceUsersAcl
+    http-request del-header Authorization`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.CorsPreflight = true
+	s.reconfigure.CorsAllowOrigins = "https://acme.com,https://www.acme.com"
+	s.reconfigure.CorsAllowHeaders = "Content-Type, X-Requested-With"
+	s.reconfigure.CorsMaxAge = 3600
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsMirror_WhenMirrorToServiceIsPresent() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    server myService myService:1234
+    option http-buffer-request
+    http-request set-var(txn.dfp_mirror) str(myService-canary:1234)
+    http-request lua.mirror if { rand(100) lt 10 }`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.MirrorToService = "myService-canary"
+	s.reconfigure.MirrorPercentage = 10
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsMirrorWithoutSampling_WhenMirrorPercentageIsNotSet() {
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.MirrorToService = "shadow.acme.com:8080"
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Contains(actualBack, `
+    http-request set-var(txn.dfp_mirror) str(shadow.acme.com:8080)
+    http-request lua.mirror`)
+	s.NotContains(actualBack, "rand(100)")
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsServerPerGroup_WhenSplitByCookie() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    cookie ab_group insert indirect nocache
+    server myService_a myService:1234 cookie a
+    server myService_b myService-v2:1234 cookie b`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.SplitBy = "cookie:ab_group"
+	s.reconfigure.SplitGroups = []proxy.SplitGroup{{Name: "a", Host: "myService"}, {Name: "b", Host: "myService-v2"}}
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsRegularServer_WhenSplitByDoesNotHaveSeparator() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    server myService myService:1234`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.SplitBy = "cookie"
+	s.reconfigure.SplitGroups = []proxy.SplitGroup{{Name: "a", Host: "myService"}, {Name: "b", Host: "myService-v2"}}
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsServerPerTask_WhenTasksAreSet() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    server myService_abc 10.0.0.3:1234 check
+    server myService_def 10.0.0.4:1234 check backup`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.Tasks = []proxy.Task{{Name: "abc", Address: "10.0.0.3"}, {Name: "def", Address: "10.0.0.4", Backup: true}}
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsBackupServers_WhenOutboundHostnameHasMultipleHosts() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    server myService_0 my-service.cluster-1.com:1234 check
+    server myService_1 my-service.cluster-2.com:1234 check backup`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.OutboundHostname = "my-service.cluster-1.com, my-service.cluster-2.com"
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_PrefixesIpv6OutboundHostname() {
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.OutboundHostname = "[2001:db8::1]"
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Contains(actualBack, "server myService ipv6@2001:db8::1:1234")
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsServerWithoutPort_WhenOutboundHostnameIsUnixSocket() {
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.OutboundHostname = "unix:///var/run/app.sock"
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.True(strings.HasSuffix(actualBack, "\n    server myService unix@/var/run/app.sock"))
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsUseServerPerGroup_WhenSplitByHeader() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    server myService_a myService:1234
+    server myService_b myService-v2:1234
+    use-server myService_a if { req.hdr(X-Group) -m str a }
+    use-server myService_b if { req.hdr(X-Group) -m str b }`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.SplitBy = "header:X-Group"
+	s.reconfigure.SplitGroups = []proxy.SplitGroup{{Name: "a", Host: "myService"}, {Name: "b", Host: "myService-v2"}}
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsBandwidthLimits_WhenPresent() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    filter bwlim-out myService_stream default-limit 625000 default-period 1s
+    http-response set-bandwidth-limit myService_stream
+    stick-table type integer size 1 expire 1h store bytes_out_rate(1s)
+    filter bwlim-out myService_total limit 12500000 key be_id
+    http-response set-bandwidth-limit myService_total
+    server myService myService:1234`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.BandwidthLimitPerStream = 625000
+	s.reconfigure.BandwidthLimitTotal = 12500000
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DoesNotAddBandwidthLimits_WhenHaProxyVersionIsOlder() {
+	defer proxy.SetHaProxyVersion(nil)
+	proxy.SetHaProxyVersion(&proxy.HaProxyVersion{Major: 1, Minor: 7})
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    server myService myService:1234`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.BandwidthLimitPerStream = 625000
+	s.reconfigure.BandwidthLimitTotal = 12500000
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsConnectionReuse_WhenPresent() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    http-reuse safe
+    option http-keep-alive
+    server myService myService:1234 pool-max-conn 20`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.HttpReuse = "safe"
+	s.reconfigure.ConnectionMode = "http-keep-alive"
+	s.reconfigure.MaxIdleConnections = 20
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttp10Compatibility_WhenPresent() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    option httpclose
+    http-reuse never
+    option http-buffer-request
+    http-request set-header Connection close
+    option accept-invalid-http-response
+    server myService myService:1234`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.Http10Compatibility = true
+	s.reconfigure.AcceptInvalidHttpResponse = true
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesIdentifierInBackendName_WhenServiceNameHasInvalidCharacters() {
+	s.reconfigure.ServiceName = "my service"
+	s.reconfigure.OutboundHostname = "my-service"
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.Mode = "swarm"
+	id := proxy.GetIdentifier("my service")
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Contains(actualBack, fmt.Sprintf("backend %s-be1234", id))
+	s.Contains(actualBack, fmt.Sprintf("server %s my-service:1234", id))
+	s.Equal("my service", s.reconfigure.ServiceName)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsErrorfiles_WhenPresent() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    errorfile 502 /errorfiles/acme/502.http
+    errorfile 503 /acme-503
+    server myService myService:1234`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.Errorfile503Path = "docker-config://acme-503"
+	s.reconfigure.Errorfile502Path = "/errorfiles/acme/502.http"
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesConnectionMode_WhenHttp10CompatibilityIsTrue() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    option http-tunnel
+    http-reuse never
+    option http-buffer-request
+    http-request set-header Connection close
+    server myService myService:1234`
+	s.reconfigure.ServiceDest[0].Port = "1234"
+	s.reconfigure.Http10Compatibility = true
+	s.reconfigure.ConnectionMode = "http-tunnel"
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsPathNormalization_WhenNormalizeTrailingSlashAndPathMatchCaseInsensitiveAreTrue() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    http-request set-path %[path,regsub(/+$,)] if { path_reg ^/.*[^/]/+$ }
+    http-request set-path %[path,regsub(^/api/?,/,i)] if { path -i /api } || { path_beg -i /api/ }
+    server myService myService:1234`
+	s.reconfigure.ServiceDest = []proxy.ServiceDest{
+		{Port: "1234", ServicePath: []string{"/api"}},
+	}
+	s.reconfigure.NormalizeTrailingSlash = true
+	s.reconfigure.PathMatchCaseInsensitive = true
+	s.reconfigure.StripPath = true
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsLuaService_WhenRewriteResponseUrlsIsTrue() {
+	expectedBack := `
+backend myService-be1234
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    http-request set-var(txn.dfp_public_path) str(/api) if { path /api } || { path_beg /api/ }
+    http-request set-path %[path,regsub(^/api/?,/)] if { path /api } || { path_beg /api/ }
+    http-request set-path /internal%[path]
+    server myService myService:1234
+    http-request set-var(txn.dfp_internal_path) str(/internal)
+    http-request set-var(txn.dfp_upstream) str(myService:1234)
+    http-request set-var(txn.dfp_backend) be_name
+    http-request set-var(txn.dfp_max_size) int(1048576)
+    http-request use-service lua.rewrite-response-urls`
+	s.reconfigure.ServiceDest = []proxy.ServiceDest{
+		{Port: "1234", ServicePath: []string{"/api"}},
+	}
+	s.reconfigure.StripPath = true
+	s.reconfigure.AddPathPrefix = "/internal"
+	s.reconfigure.RewriteResponseUrls = true
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_LimitsRewrittenResponses_WhenRewriteResponseMaxSizeIsSet() {
+	defer os.Unsetenv("REWRITE_RESPONSE_MAX_SIZE")
+	os.Setenv("REWRITE_RESPONSE_MAX_SIZE", "2048")
+	s.reconfigure.ServiceDest = []proxy.ServiceDest{
+		{Port: "1234", ServicePath: []string{"/api"}},
+	}
+	s.reconfigure.StripPath = true
+	s.reconfigure.RewriteResponseUrls = true
+	s.reconfigure.Mode = "swarm"
+	_, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Contains(actualBack, "http-request set-var(txn.dfp_max_size) int(2048)\n")
+}
+
 func (s ReconfigureTestSuite) Test_GetTemplates_AddsMultipleDestinations() {
 	sd := []proxy.ServiceDest{
 		proxy.ServiceDest{Port: "1111", ServicePath: []string{"path-1"}, SrcPort: 2222},
@@ -455,6 +1147,15 @@ func (s ReconfigureTestSuite) Test_GetTemplates_DoesNotSetCheckWhenSkipCheckIsTr
 	s.Equal(s.ConsulTemplateBe, actual)
 }
 
+func (s ReconfigureTestSuite) Test_GetTemplates_SetsCheck_WhenSkipCheckIsTrueAndExternalCheckCommandIsPresent() {
+	s.reconfigure.SkipCheck = true
+	s.reconfigure.ExternalCheckCommand = "/scripts/check-lag.sh"
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.Service)
+
+	s.Contains(actual, `{{$e.Address}}:{{$e.Port}} check
+`)
+}
+
 func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsFileContent_WhenConsulTemplatePathIsSet() {
 	expected := "This is content of a template"
 	readTemplateFileOrig := readTemplateFile
@@ -470,6 +1171,22 @@ func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsFileContent_WhenConsulTem
 	s.Equal(expected, actual)
 }
 
+func (s ReconfigureTestSuite) Test_GetTemplates_ReadsTemplatesFromDockerConfigs_WhenPathsAreDockerConfigReferences() {
+	readTemplateFileOrig := readTemplateFile
+	defer func() { readTemplateFile = readTemplateFileOrig }()
+	actualFiles := []string{}
+	readTemplateFile = func(filename string) ([]byte, error) {
+		actualFiles = append(actualFiles, filename)
+		return []byte("This is service {{.ServiceName}}"), nil
+	}
+	s.Service.TemplateFePath = "docker-config://my-fe-snippet"
+	s.Service.TemplateBePath = "docker-secret://my-be-snippet"
+
+	s.reconfigure.GetTemplates(&s.Service)
+
+	s.Equal([]string{"/my-fe-snippet", "/run/secrets/my-be-snippet"}, actualFiles)
+}
+
 func (s ReconfigureTestSuite) Test_GetTemplates_ProcessesTemplateFromTemplatePath_WhenSpecified() {
 	expectedFeFile := "/path/to/my/fe/template"
 	expectedBeFile := "/path/to/my/be/template"
@@ -494,6 +1211,81 @@ func (s ReconfigureTestSuite) Test_GetTemplates_ProcessesTemplateFromTemplatePat
 	s.Equal(expectedBe, actualBe)
 }
 
+func (s ReconfigureTestSuite) Test_GetTemplates_ExecutesTemplateFunctions_WhenTemplatePathIsSpecified() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{
+		"auth": {ServiceName: "auth", ServiceDest: []proxy.ServiceDest{{Port: "9000"}}},
+	})
+	proxy.Instance = proxyMock
+	readTemplateFileOrig := readTemplateFile
+	defer func() { readTemplateFile = readTemplateFileOrig }()
+	readTemplateFile = func(filename string) ([]byte, error) {
+		return []byte(`server auth auth:{{port "auth"}} timeout {{mul .TimeoutServer 2}}s`), nil
+	}
+	s.Service.TemplateFePath = "/path/to/my/fe/template"
+	s.Service.TemplateBePath = "/path/to/my/be/template"
+	s.Service.TimeoutServer = "15"
+
+	_, actualBe, _ := s.reconfigure.GetTemplates(&s.Service)
+
+	s.Equal("server auth auth:9000 timeout 30s", actualBe)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsError_WhenTemplateCannotBeRendered() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{})
+	proxy.Instance = proxyMock
+	readTemplateFileOrig := readTemplateFile
+	defer func() { readTemplateFile = readTemplateFileOrig }()
+	s.Service.TemplateFePath = "/path/to/my/fe/template"
+	s.Service.TemplateBePath = "/path/to/my/be/template"
+	for _, content := range []string{`{{.ServiceName`, `{{port "unknown"}}`} {
+		readTemplateFile = func(filename string) ([]byte, error) {
+			return []byte(content), nil
+		}
+
+		_, _, err := s.reconfigure.GetTemplates(&s.Service)
+
+		s.Error(err, content)
+	}
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_LooksUpOnlyServicesOfNamespace() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{
+		"team-a.auth": {ServiceName: "team-a.auth", Namespace: "team-a", ServiceDest: []proxy.ServiceDest{{Port: "9000"}}},
+		"team-b.auth": {ServiceName: "team-b.auth", Namespace: "team-b", ServiceDest: []proxy.ServiceDest{{Port: "9001"}}},
+	})
+	proxy.Instance = proxyMock
+	readTemplateFileOrig := readTemplateFile
+	defer func() { readTemplateFile = readTemplateFileOrig }()
+	s.Service.TemplateFePath = "/path/to/my/fe/template"
+	s.Service.TemplateBePath = "/path/to/my/be/template"
+	s.Service.Namespace = "team-a"
+	readTemplateFile = func(filename string) ([]byte, error) {
+		return []byte(`{{port "team-a.auth"}}`), nil
+	}
+
+	_, actualBe, err := s.reconfigure.GetTemplates(&s.Service)
+
+	s.NoError(err)
+	s.Equal("9000", actualBe)
+
+	readTemplateFile = func(filename string) ([]byte, error) {
+		return []byte(`{{port "team-b.auth"}}`), nil
+	}
+
+	_, _, err = s.reconfigure.GetTemplates(&s.Service)
+
+	s.Error(err)
+}
+
 func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsError_WhenTemplateFePathIsNotPresent() {
 	testFilename := "/path/to/my/template"
 	readTemplateFileOrig := readTemplateFile
@@ -855,6 +1647,80 @@ func (s *ReconfigureTestSuite) Test_Execute_ReturnsError_WhenAddressIsNotAccessi
 	//	s.NoError(err)
 }
 
+// ExecuteContext
+
+func (s *ReconfigureTestSuite) Test_ExecuteContext_ReturnsError_WhenContextIsCanceled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	written := false
+	writeBeTemplateOrig := writeBeTemplate
+	defer func() { writeBeTemplate = writeBeTemplateOrig }()
+	writeBeTemplate = func(filename string, data []byte, perm os.FileMode) error {
+		written = true
+		return nil
+	}
+
+	err := s.reconfigure.ExecuteContext(ctx)
+
+	s.Equal(context.Canceled, err)
+	s.False(written)
+}
+
+func (s *ReconfigureTestSuite) Test_Import_CreatesConfigWithoutReloading() {
+	mockObj := getProxyMock("")
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxy.Instance = mockObj
+
+	err := s.reconfigure.Import(context.Background())
+
+	s.NoError(err)
+	mockObj.AssertCalled(s.T(), "CreateConfigFromTemplates")
+	mockObj.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s *ReconfigureTestSuite) Test_ExecuteContext_ReturnsError_WhenLookupDoesNotFinishBeforeTheContext() {
+	s.reconfigure.Mode = "swarm"
+	skipAddressValidationOrig := s.reconfigure.skipAddressValidation
+	defer func() { s.reconfigure.skipAddressValidation = skipAddressValidationOrig }()
+	s.reconfigure.skipAddressValidation = false
+	lookupHostOrig := lookupHost
+	defer func() { lookupHost = lookupHostOrig }()
+	done := make(chan struct{})
+	defer close(done)
+	lookupHost = func(host string) ([]string, error) {
+		<-done
+		return []string{}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	err := s.reconfigure.ExecuteContext(ctx)
+
+	s.Error(err)
+	s.Contains(err.Error(), "context deadline exceeded")
+}
+
+func (s *ReconfigureTestSuite) Test_ExecuteContext_DoesNotLookupHost_WhenServiceHasStaticResponse() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.StaticResponseStatus = 200
+	skipAddressValidationOrig := s.reconfigure.skipAddressValidation
+	defer func() { s.reconfigure.skipAddressValidation = skipAddressValidationOrig }()
+	s.reconfigure.skipAddressValidation = false
+	lookupHostOrig := lookupHost
+	defer func() { lookupHost = lookupHostOrig }()
+	lookups := 0
+	lookupHost = func(host string) ([]string, error) {
+		lookups++
+		return []string{}, fmt.Errorf("The host %s does not exist", host)
+	}
+
+	err := s.reconfigure.ExecuteContext(context.Background())
+
+	s.NoError(err)
+	s.Equal(0, lookups)
+}
+
 // NewReconfigure
 
 func (s *ReconfigureTestSuite) Test_NewReconfigure_AddsBaseAndService() {
@@ -994,6 +1860,16 @@ func (m *ReconfigureMock) Execute(args []string) error {
 	return params.Error(0)
 }
 
+// ExecuteContext is recorded as Execute so that the assertions do not depend on which of the two is invoked
+func (m *ReconfigureMock) ExecuteContext(ctx context.Context) error {
+	return m.Execute([]string{})
+}
+
+// Import is recorded as Execute as well
+func (m *ReconfigureMock) Import(ctx context.Context) error {
+	return m.Execute([]string{})
+}
+
 func (m *ReconfigureMock) GetData() (BaseReconfigure, proxy.Service) {
 	m.Called()
 	return BaseReconfigure{}, proxy.Service{}
@@ -1030,7 +1906,7 @@ type RegistrarableMock struct {
 	mock.Mock
 }
 
-func (m *RegistrarableMock) PutService(addresses []string, instanceName string, r registry.Registry) error {
+func (m *RegistrarableMock) PutService(ctx context.Context, addresses []string, instanceName string, r registry.Registry) error {
 	params := m.Called(addresses, instanceName, r)
 	return params.Error(0)
 }
@@ -1048,7 +1924,7 @@ func (m *RegistrarableMock) SendDeleteRequest(addresses []string, serviceName, k
 	m.Called(addresses, serviceName, key, value, instanceName, c)
 }
 
-func (m *RegistrarableMock) CreateConfigs(args *registry.CreateConfigsArgs) error {
+func (m *RegistrarableMock) CreateConfigs(ctx context.Context, args *registry.CreateConfigsArgs) error {
 	params := m.Called(args)
 	return params.Error(0)
 }
@@ -1061,6 +1937,16 @@ func (m *RegistrarableMock) GetServiceAttribute(addresses []string, instanceName
 	return "something", params.Error(0)
 }
 
+func (m *RegistrarableMock) PutServiceAttributeIfAbsent(addresses []string, serviceName, key, value, instanceName string) (string, error) {
+	params := m.Called(addresses, serviceName, key, value, instanceName)
+	return params.String(0), params.Error(1)
+}
+
+func (m *RegistrarableMock) DeleteServiceAttribute(addresses []string, serviceName, key, instanceName string) error {
+	params := m.Called(addresses, serviceName, key, instanceName)
+	return params.Error(0)
+}
+
 func getRegistrarableMock(skipMethod string) *RegistrarableMock {
 	mockObj := new(RegistrarableMock)
 	if skipMethod != "PutService" {
@@ -1081,6 +1967,9 @@ func getRegistrarableMock(skipMethod string) *RegistrarableMock {
 	if skipMethod != "GetServiceAttribute" {
 		mockObj.On("GetServiceAttribute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	}
+	if skipMethod != "DeleteServiceAttribute" {
+		mockObj.On("DeleteServiceAttribute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	}
 	return mockObj
 }
 
@@ -1103,6 +1992,11 @@ func (m *ProxyMock) ReadConfig() (string, error) {
 	return params.String(0), params.Error(1)
 }
 
+func (m *ProxyMock) RenderConfig() (string, error) {
+	params := m.Called()
+	return params.String(0), params.Error(1)
+}
+
 func (m *ProxyMock) Reload() error {
 	params := m.Called()
 	return params.Error(0)
@@ -1130,6 +2024,12 @@ func (m *ProxyMock) GetCertPaths() []string {
 	return params.Get(0).([]string)
 }
 
+func (m *ProxyMock) GetServices() map[string]proxy.Service {
+	params := m.Called()
+	return params.Get(0).(map[string]proxy.Service)
+}
+
+
 func getProxyMock(skipMethod string) *ProxyMock {
 	mockObj := new(ProxyMock)
 	if skipMethod != "RunCmd" {
@@ -1141,6 +2041,9 @@ func getProxyMock(skipMethod string) *ProxyMock {
 	if skipMethod != "ReadConfig" {
 		mockObj.On("ReadConfig").Return("", nil)
 	}
+	if skipMethod != "RenderConfig" {
+		mockObj.On("RenderConfig").Return("", nil)
+	}
 	if skipMethod != "Reload" {
 		mockObj.On("Reload").Return(nil)
 	}
@@ -1159,6 +2062,9 @@ func getProxyMock(skipMethod string) *ProxyMock {
 	if skipMethod != "GetCertPaths" {
 		mockObj.On("GetCertPaths")
 	}
+	if skipMethod != "GetServices" {
+		mockObj.On("GetServices").Return(map[string]proxy.Service{})
+	}
 	return mockObj
 }
 
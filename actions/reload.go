@@ -6,24 +6,39 @@ type Reloader interface {
 	Execute(recreate bool, listenerAddr string) error
 }
 
-type Reload struct{}
+type Reload struct {
+	// The lane of the reload. Urgent reloads (e.g. after a removal) are not delayed by bulk reconfigurations.
+	Priority proxy.ReloadPriority
+}
+
+// The reloads requested while another one is running are served together by the next one.
+// The configuration is not written while the proxy reads it.
+var reloadQueue = proxy.NewReloadQueue(func() error {
+	mu.Lock()
+	defer mu.Unlock()
+	return proxy.Instance.Reload()
+})
 
+// Execute reloads the proxy through the reload queue. It must not be called while mu is held.
 func (m *Reload) Execute(recreate bool, listenerAddr string) error {
 	if len(listenerAddr) > 0 {
 		recon := NewReconfigure(BaseReconfigure{}, proxy.Service{}, "")
 		if err := recon.ReloadAllServices([]string{}, "", "", listenerAddr); err != nil {
-			logPrintf(err.Error())
+			logErrorf(err.Error())
 			return err
 		}
 	} else {
 		if recreate {
-			if err := proxy.Instance.CreateConfigFromTemplates(); err != nil {
-				logPrintf(err.Error())
+			mu.Lock()
+			err := proxy.Instance.CreateConfigFromTemplates()
+			mu.Unlock()
+			if err != nil {
+				logErrorf(err.Error())
 				return err
 			}
 		}
-		if err := proxy.Instance.Reload(); err != nil {
-			logPrintf(err.Error())
+		if err := reloadQueue.Request(m.Priority); err != nil {
+			logErrorf(err.Error())
 			return err
 		}
 	}
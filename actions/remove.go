@@ -3,11 +3,36 @@ package actions
 import (
 	"../proxy"
 	"fmt"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
+var sleep = time.Sleep
+
 type Removable interface {
 	Executable
+	Drain() error
+	GetRemoved() Removed
+}
+
+type RemoveOptions struct {
+	RemoveCerts  bool `long:"remove-certs" description:"Whether to remove the certificates named after the service or one of its domains."`
+	DrainFirst   bool `long:"drain-first" description:"Whether to remove the frontend of the service and wait for the drain timeout before removing the backend."`
+	DrainTimeout int  `long:"drain-timeout" env:"DRAIN_TIMEOUT" default:"5" description:"The number of seconds to wait before the backend is removed when drain-first is set."`
+	KeepState    bool `long:"keep-state" description:"Whether to keep the service stored in the registry."`
+}
+
+// Removed lists the artifacts removed together with a service.
+type Removed struct {
+	// Whether the frontend ACLs of the service were removed.
+	Frontend bool
+	// Whether the backend of the service was removed.
+	Backend bool
+	// The paths of the removed certificates.
+	Certs []string
+	// Whether the service was removed from the registry.
+	State bool
 }
 
 type Remove struct {
@@ -18,13 +43,19 @@ type Remove struct {
 	TemplatesPath   string `short:"t" long:"templates-path" default:"/cfg/tmpl" description:"The path to the templates directory"`
 	Mode            string
 	AclName         string
+	RemoveOptions
+	removed Removed
+	service proxy.Service
+	found   bool
+	drained bool
 }
 
 var RemoveInstance Remove
 
 // TODO: Change to addresses
-var NewRemove = func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+var NewRemove = func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string, options RemoveOptions) Removable {
 	return &Remove{
+		RemoveOptions:   options,
 		ServiceName:     serviceName,
 		AclName:         aclName,
 		TemplatesPath:   templatesPath,
@@ -38,21 +69,107 @@ var NewRemove = func(serviceName, aclName, configsPath, templatesPath string, co
 // TODO: Remove args
 func (m *Remove) Execute(args []string) error {
 	logPrintf("Removing %s configuration", m.ServiceName)
+	if !m.drained {
+		m.init()
+		if m.DrainFirst {
+			if err := m.drain(); err != nil {
+				logErrorf(err.Error())
+				return err
+			}
+			sleep(time.Second * time.Duration(m.DrainTimeout))
+		}
+	}
+	if err := m.writeConfigs(m.service, m.found); err != nil {
+		logErrorf(err.Error())
+		return err
+	}
+	reload := Reload{Priority: proxy.ReloadUrgent}
+	if err := reload.Execute(false, ""); err != nil {
+		logErrorf(err.Error())
+		return err
+	}
+	proxy.PublishEvent(proxy.Event{Type: proxy.EventRemove, ServiceName: m.ServiceName})
+	return nil
+}
+
+// writeConfigs removes the files and the certificates of the service and creates the configuration without it.
+// The configuration is not written while it is written by a reconfiguration or read by a reload.
+func (m *Remove) writeConfigs(service proxy.Service, found bool) error {
+	mu.Lock()
+	defer mu.Unlock()
 	if err := m.removeFiles(m.TemplatesPath, m.ServiceName, m.AclName, m.ConsulAddresses, m.InstanceName, m.Mode); err != nil {
-		logPrintf(err.Error())
 		return err
 	}
+	if m.RemoveCerts {
+		m.removed.Certs = m.removeCerts(service)
+	}
 	proxy.Instance.RemoveService(m.ServiceName)
-	if err := proxy.Instance.CreateConfigFromTemplates(); err != nil {
-		logPrintf(err.Error())
+	m.removed.Frontend = m.removed.Frontend || found
+	return proxy.Instance.CreateConfigFromTemplates()
+}
+
+func (m *Remove) GetRemoved() Removed {
+	return m.removed
+}
+
+// Drain removes the frontend of the service without waiting for the drain timeout.
+// The backend keeps serving the requests in flight until Execute removes it without draining the service again.
+func (m *Remove) Drain() error {
+	m.init()
+	if err := m.drain(); err != nil {
+		logErrorf(err.Error())
 		return err
 	}
-	reload := Reload{}
-	if err := reload.Execute(false, ""); err != nil {
-		logPrintf(err.Error())
+	m.drained = true
+	return nil
+}
+
+func (m *Remove) init() {
+	m.removed = Removed{}
+	m.service, m.found = proxy.Instance.GetServices()[m.ServiceName]
+}
+
+// drain removes the frontend of the service so that the requests in flight are served by the backend before it is removed.
+func (m *Remove) drain() error {
+	logPrintf("Draining %s before removing its backend", m.ServiceName)
+	mu.Lock()
+	if err := OsRemove(fmt.Sprintf("%s/%s-fe.cfg", m.TemplatesPath, proxy.GetIdentifier(m.getAclName()))); err == nil {
+		m.removed.Frontend = true
+	}
+	proxy.Instance.RemoveService(m.ServiceName)
+	err := proxy.Instance.CreateConfigFromTemplates()
+	mu.Unlock()
+	if err != nil {
 		return err
 	}
-	return nil
+	reload := Reload{Priority: proxy.ReloadUrgent}
+	return reload.Execute(false, "")
+}
+
+func (m *Remove) removeCerts(service proxy.Service) []string {
+	names := append([]string{m.ServiceName}, service.ServiceDomain...)
+	removed := []string{}
+	for _, path := range proxy.Instance.GetCertPaths() {
+		if strings.HasPrefix(path, "/run/secrets") {
+			continue
+		}
+		for _, name := range names {
+			if filepath.Base(path) == name {
+				if err := OsRemove(path); err == nil {
+					removed = append(removed, path)
+				}
+				break
+			}
+		}
+	}
+	return removed
+}
+
+func (m *Remove) getAclName() string {
+	if len(m.AclName) == 0 {
+		return m.ServiceName
+	}
+	return m.AclName
 }
 
 func (m *Remove) removeFiles(templatesPath, serviceName, aclName string, registryAddresses []string, instanceName, mode string) error {
@@ -60,20 +177,25 @@ func (m *Remove) removeFiles(templatesPath, serviceName, aclName string, registr
 	if len(aclName) == 0 {
 		aclName = serviceName
 	}
-	paths := []string{
-		fmt.Sprintf("%s/%s-fe.cfg", templatesPath, aclName),
-		fmt.Sprintf("%s/%s-be.cfg", templatesPath, aclName),
+	// The files are named after the identifier of the ACL name, the same way as by the reconfiguration
+	aclName = proxy.GetIdentifier(aclName)
+	feFile := fmt.Sprintf("%s/%s-fe.cfg", templatesPath, aclName)
+	beFile := fmt.Sprintf("%s/%s-be.cfg", templatesPath, aclName)
+	if err := OsRemove(feFile); err == nil {
+		m.removed.Frontend = true
 	}
-	mu.Lock()
-	defer mu.Unlock()
-	for _, path := range paths {
-		OsRemove(path)
+	if err := OsRemove(beFile); err == nil {
+		m.removed.Backend = true
+	}
+	if m.KeepState {
+		return nil
 	}
 	if !strings.EqualFold(mode, "service") && !strings.EqualFold(mode, "swarm") {
 		var err error
 		if len(registryAddresses) > 0 {
 			for _, address := range registryAddresses {
 				if err = registryInstance.DeleteService([]string{address}, serviceName, instanceName); err == nil {
+					m.removed.State = true
 					return nil
 				}
 			}
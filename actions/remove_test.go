@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"testing"
+	"time"
 )
 
 type RemoveTestSuite struct {
@@ -25,6 +26,7 @@ func TestRemoveUnitTestSuite(t *testing.T) {
 	defer func() { registryInstance = registryInstanceOrig }()
 	registryInstance = getRegistrarableMock("")
 	logPrintf = func(format string, v ...interface{}) {}
+	logErrorf = func(format string, v ...interface{}) {}
 	proxyOrig := proxy.Instance
 	defer func() { proxy.Instance = proxyOrig }()
 	proxy.Instance = getProxyMock("")
@@ -84,6 +86,29 @@ func (s RemoveTestSuite) Test_Execute_RemovesConfigurationFileUsingAclName_WhenP
 	s.Equal(expected, actual)
 }
 
+func (s RemoveTestSuite) Test_Execute_RemovesConfigurationFileNamedAfterIdentifier_WhenNameIsNotValidIdentifier() {
+	s.remove.ServiceName = "my service"
+	s.remove.DrainFirst = true
+	var actual []string
+	expected := []string{
+		fmt.Sprintf("%s/%s-fe.cfg", s.TemplatesPath, proxy.GetIdentifier("my service")),
+		fmt.Sprintf("%s/%s-fe.cfg", s.TemplatesPath, proxy.GetIdentifier("my service")),
+		fmt.Sprintf("%s/%s-be.cfg", s.TemplatesPath, proxy.GetIdentifier("my service")),
+	}
+	OsRemove = func(name string) error {
+		actual = append(actual, name)
+		return nil
+	}
+	sleepOrig := sleep
+	defer func() { sleep = sleepOrig }()
+	sleep = func(d time.Duration) {}
+
+	s.remove.Execute([]string{})
+
+	s.Equal(expected, actual)
+	s.NotEqual("my service", proxy.GetIdentifier("my service"))
+}
+
 func (s RemoveTestSuite) Test_Execute_Invokes_HaProxyCreateConfigFromTemplates() {
 	proxyOrig := proxy.Instance
 	defer func() { proxy.Instance = proxyOrig }()
@@ -188,3 +213,87 @@ func (s RemoveTestSuite) Test_Execute_RemovesService() {
 
 	mockObj.AssertCalled(s.T(), "RemoveService", s.remove.ServiceName)
 }
+
+func (s RemoveTestSuite) Test_Execute_DoesNotInvokeRegistryDeleteService_WhenKeepStateIsTrue() {
+	mockObj := getRegistrarableMock("")
+	s.remove.KeepState = true
+	registryInstanceOrig := registryInstance
+	defer func() { registryInstance = registryInstanceOrig }()
+	registryInstance = mockObj
+
+	s.remove.Execute([]string{})
+
+	mockObj.AssertNotCalled(s.T(), "DeleteService", mock.Anything, mock.Anything, mock.Anything)
+	s.False(s.remove.GetRemoved().State)
+}
+
+func (s RemoveTestSuite) Test_Execute_RemovesCerts_WhenRemoveCertsIsTrue() {
+	mockObj := getProxyMock("GetCertPaths")
+	mockObj.On("GetCertPaths").Return([]string{"/certs/myService", "/certs/other-service", "/run/secrets/myService"})
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxy.Instance = mockObj
+	s.remove.RemoveCerts = true
+	var actual []string
+	OsRemove = func(name string) error {
+		actual = append(actual, name)
+		return nil
+	}
+
+	s.remove.Execute([]string{})
+
+	s.Contains(actual, "/certs/myService")
+	s.NotContains(actual, "/certs/other-service")
+	s.NotContains(actual, "/run/secrets/myService")
+	s.Equal([]string{"/certs/myService"}, s.remove.GetRemoved().Certs)
+}
+
+func (s RemoveTestSuite) Test_Execute_RemovesFrontendBeforeBackend_WhenDrainFirstIsTrue() {
+	s.remove.DrainFirst = true
+	s.remove.DrainTimeout = 3
+	actual := []string{}
+	OsRemove = func(name string) error {
+		actual = append(actual, name)
+		return nil
+	}
+	sleepOrig := sleep
+	defer func() { sleep = sleepOrig }()
+	sleep = func(d time.Duration) {
+		actual = append(actual, d.String())
+	}
+
+	s.remove.Execute([]string{})
+
+	s.Equal(fmt.Sprintf("%s/%s-fe.cfg", s.TemplatesPath, s.ServiceName), actual[0])
+	s.Equal("3s", actual[1])
+	s.Contains(actual[2:], fmt.Sprintf("%s/%s-be.cfg", s.TemplatesPath, s.ServiceName))
+}
+
+func (s RemoveTestSuite) Test_Execute_DoesNotDrainAgain_WhenServiceWasDrained() {
+	s.remove.DrainFirst = true
+	actual := []string{}
+	OsRemove = func(name string) error {
+		actual = append(actual, name)
+		return nil
+	}
+	sleepOrig := sleep
+	defer func() { sleep = sleepOrig }()
+	slept := false
+	sleep = func(d time.Duration) {
+		slept = true
+	}
+
+	s.remove.Drain()
+	s.remove.Execute([]string{})
+
+	s.False(slept)
+	s.Equal(fmt.Sprintf("%s/%s-fe.cfg", s.TemplatesPath, s.ServiceName), actual[0])
+	s.Contains(actual[1:], fmt.Sprintf("%s/%s-be.cfg", s.TemplatesPath, s.ServiceName))
+	s.True(s.remove.GetRemoved().Frontend)
+}
+
+func (s RemoveTestSuite) Test_GetRemoved_ReturnsRemovedArtifacts() {
+	s.remove.Execute([]string{})
+
+	s.Equal(Removed{Frontend: true, Backend: true, State: true}, s.remove.GetRemoved())
+}
@@ -0,0 +1,141 @@
+package actions
+
+import (
+	"../proxy"
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The addresses resolved by PrefetchHosts. They are used instead of new lookups until they are released.
+var prefetched = struct {
+	sync.RWMutex
+	hosts map[string]proxy.HostResolution
+}{hosts: map[string]proxy.HostResolution{}}
+
+var staleCacheNow = time.Now
+
+// The addresses of the hosts and the tasks of the zone aware services resolved last, keyed by the hosts.
+// They are reused when a lookup fails so that a DNS outage does not prevent healthy services from being reconfigured.
+var staleCache = struct {
+	sync.Mutex
+	hosts map[string]staleEntry
+	tasks map[string]staleEntry
+}{hosts: map[string]staleEntry{}, tasks: map[string]staleEntry{}}
+
+type staleEntry struct {
+	addresses []string
+	tasks     []proxy.Task
+	resolved  time.Time
+}
+
+// PrefetchHosts resolves the hosts of the services concurrently so that reconfiguring many services one by one
+// (e.g. when a state is imported) does not wait for each of their lookups in turn.
+// Zone aware services have their tasks (tasks.<host>) resolved as well.
+// The returned function releases the resolved addresses and must be called once the services are reconfigured.
+func PrefetchHosts(ctx context.Context, services []proxy.Service, mode string) func() {
+	if !isSwarm(mode) {
+		return func() {}
+	}
+	hosts := []string{}
+	for _, s := range services {
+		for _, host := range s.GetHosts() {
+			if !proxy.IsUnixSocket(host) {
+				hosts = append(hosts, host)
+			}
+		}
+		if s.ZoneAware {
+			for _, host := range s.GetHosts() {
+				hosts = append(hosts, "tasks."+host)
+			}
+		}
+	}
+	resolutions := proxy.ResolveHosts(ctx, lookupHost, hosts)
+	prefetched.Lock()
+	for host, r := range resolutions {
+		prefetched.hosts[host] = r
+	}
+	prefetched.Unlock()
+	return func() {
+		prefetched.Lock()
+		defer prefetched.Unlock()
+		for host := range resolutions {
+			delete(prefetched.hosts, host)
+		}
+	}
+}
+
+// resolveHost returns the prefetched addresses of the host or, if it was not prefetched, looks it up.
+// When the lookup fails temporarily or times out, the addresses resolved last are returned with a warning unless they are older than DNS_STALE_CACHE_TTL.
+// Other failures (e.g. the host does not exist anymore) are returned as they are.
+func resolveHost(ctx context.Context, host string) ([]string, error) {
+	prefetched.RLock()
+	r, ok := prefetched.hosts[host]
+	prefetched.RUnlock()
+	if !ok {
+		r.Addresses, r.Err = proxy.LookupHost(ctx, lookupHost, host)
+	}
+	if r.Err == nil {
+		putStale(staleCache.hosts, host, staleEntry{addresses: r.Addresses})
+		return r.Addresses, nil
+	}
+	// Canceled requests do not need the addresses
+	if ctx.Err() == nil && isTemporaryLookupError(r.Err) {
+		if entry, ok := getStale(staleCache.hosts, host); ok {
+			logWarnf("Could not resolve %s. The addresses resolved at %s are used instead.\n%s", host, entry.resolved.Format(time.RFC3339), r.Err.Error())
+			return entry.addresses, nil
+		}
+	}
+	return nil, r.Err
+}
+
+// isTemporaryLookupError returns whether the lookup failed because of a temporary DNS failure or a timeout.
+func isTemporaryLookupError(err error) bool {
+	if e, ok := err.(net.Error); ok {
+		return e.Temporary() || e.Timeout()
+	}
+	return false
+}
+
+// isHostNotFound returns whether the lookup failed because the DNS server answered that the host does not exist.
+func isHostNotFound(err error) bool {
+	e, ok := err.(*net.DNSError)
+	return ok && !e.Temporary() && !e.Timeout()
+}
+
+// getStaleTasks returns the tasks of the zone aware service resolved last unless they are older than DNS_STALE_CACHE_TTL.
+func getStaleTasks(host string) ([]proxy.Task, time.Time, bool) {
+	entry, ok := getStale(staleCache.tasks, host)
+	return entry.tasks, entry.resolved, ok
+}
+
+func putStaleTasks(host string, tasks []proxy.Task) {
+	putStale(staleCache.tasks, host, staleEntry{tasks: tasks})
+}
+
+func putStale(entries map[string]staleEntry, key string, entry staleEntry) {
+	staleCache.Lock()
+	defer staleCache.Unlock()
+	entry.resolved = staleCacheNow()
+	entries[key] = entry
+}
+
+func getStale(entries map[string]staleEntry, key string) (staleEntry, bool) {
+	staleCache.Lock()
+	defer staleCache.Unlock()
+	entry, ok := entries[key]
+	if !ok {
+		return entry, false
+	}
+	ttl, err := strconv.Atoi(proxy.GetSecretOrEnvVar("DNS_STALE_CACHE_TTL", "3600"))
+	if err != nil {
+		ttl = 3600
+	}
+	if staleCacheNow().Sub(entry.resolved) > time.Duration(ttl)*time.Second {
+		delete(entries, key)
+		return entry, false
+	}
+	return entry, true
+}
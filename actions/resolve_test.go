@@ -0,0 +1,171 @@
+// +build !integration
+
+package actions
+
+import (
+	"../proxy"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ResolveTestSuite struct {
+	suite.Suite
+	mu      sync.Mutex
+	lookups []string
+}
+
+func TestResolveUnitTestSuite(t *testing.T) {
+	lookupHostOrig := lookupHost
+	defer func() { lookupHost = lookupHostOrig }()
+	logWarnfOrig := logWarnf
+	defer func() { logWarnf = logWarnfOrig }()
+	logWarnf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(ResolveTestSuite))
+}
+
+func (s *ResolveTestSuite) SetupTest() {
+	s.lookups = []string{}
+	staleCache.hosts = map[string]staleEntry{}
+	staleCache.tasks = map[string]staleEntry{}
+	staleCacheNow = time.Now
+	lookupHost = func(host string) ([]string, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.lookups = append(s.lookups, host)
+		if host == "unknown-service" {
+			return nil, fmt.Errorf("no such host")
+		}
+		return []string{"10.0.0.3"}, nil
+	}
+}
+
+// PrefetchHosts
+
+func (s *ResolveTestSuite) Test_PrefetchHosts_ResolvesHostsOnlyOnce() {
+	services := []proxy.Service{
+		{ServiceName: "my-service", ZoneAware: true},
+		{ServiceName: "unknown-service"},
+	}
+
+	release := PrefetchHosts(context.Background(), services, "swarm")
+	defer release()
+	s.Len(s.lookups, 3)
+	s.lookups = []string{}
+
+	s.NoError(isReachable(context.Background(), "my-service"))
+	s.Error(isReachable(context.Background(), "unknown-service"))
+	addresses, _ := resolveHost(context.Background(), "tasks.my-service")
+
+	s.Equal([]string{"10.0.0.3"}, addresses)
+	s.Empty(s.lookups)
+}
+
+func (s *ResolveTestSuite) Test_PrefetchHosts_LooksUpHostsAgain_AfterRelease() {
+	release := PrefetchHosts(context.Background(), []proxy.Service{{ServiceName: "my-service"}}, "swarm")
+	release()
+	s.lookups = []string{}
+
+	isReachable(context.Background(), "my-service")
+
+	s.Equal([]string{"my-service"}, s.lookups)
+}
+
+func (s *ResolveTestSuite) Test_PrefetchHosts_DoesNothing_WhenModeIsNotSwarm() {
+	release := PrefetchHosts(context.Background(), []proxy.Service{{ServiceName: "my-service"}}, "default")
+	defer release()
+
+	s.Empty(s.lookups)
+}
+
+// resolveHost
+
+func (s *ResolveTestSuite) Test_ResolveHost_ReturnsAddressesResolvedLast_WhenLookupFails() {
+	resolveHost(context.Background(), "my-service")
+	lookupHost = func(host string) ([]string, error) {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: host, IsTemporary: true}
+	}
+
+	actual, err := resolveHost(context.Background(), "my-service")
+
+	s.NoError(err)
+	s.Equal([]string{"10.0.0.3"}, actual)
+}
+
+func (s *ResolveTestSuite) Test_ResolveHost_ReturnsAddressesResolvedLast_WhenLookupTimesOut() {
+	resolveHost(context.Background(), "my-service")
+	lookupHost = func(host string) ([]string, error) {
+		return nil, &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+	}
+
+	actual, err := resolveHost(context.Background(), "my-service")
+
+	s.NoError(err)
+	s.Equal([]string{"10.0.0.3"}, actual)
+}
+
+func (s *ResolveTestSuite) Test_ResolveHost_ReturnsError_WhenHostDoesNotExistAnymore() {
+	resolveHost(context.Background(), "my-service")
+	lookupHost = func(host string) ([]string, error) {
+		return nil, &net.DNSError{Err: "no such host", Name: host}
+	}
+
+	_, err := resolveHost(context.Background(), "my-service")
+
+	s.Error(err)
+}
+
+func (s *ResolveTestSuite) Test_ResolveHost_ReturnsError_WhenAddressesResolvedLastAreOlderThanTtl() {
+	defer os.Unsetenv("DNS_STALE_CACHE_TTL")
+	os.Setenv("DNS_STALE_CACHE_TTL", "60")
+	resolveHost(context.Background(), "my-service")
+	staleCacheNow = func() time.Time {
+		return time.Now().Add(time.Minute * 2)
+	}
+	lookupHost = func(host string) ([]string, error) {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: host, IsTemporary: true}
+	}
+
+	_, err := resolveHost(context.Background(), "my-service")
+
+	s.Error(err)
+}
+
+func (s *ResolveTestSuite) Test_ResolveHost_ReturnsError_WhenContextIsCanceled() {
+	resolveHost(context.Background(), "my-service")
+	lookupHost = func(host string) ([]string, error) {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: host, IsTemporary: true}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := resolveHost(ctx, "my-service")
+
+	s.Error(err)
+}
+
+func (s *ResolveTestSuite) Test_ResolveHost_ReturnsError_WhenHostWasNeverResolved() {
+	_, err := resolveHost(context.Background(), "unknown-service")
+
+	s.Error(err)
+}
+
+// getStaleTasks
+
+func (s *ResolveTestSuite) Test_GetStaleTasks_ReturnsTasksResolvedLast() {
+	tasks := []proxy.Task{{Name: "abc", Address: "10.0.0.5"}}
+	putStaleTasks("my-service", tasks)
+
+	actual, _, ok := getStaleTasks("my-service")
+
+	s.True(ok)
+	s.Equal(tasks, actual)
+	_, _, ok = getStaleTasks("other-service")
+	s.False(ok)
+}
@@ -1,9 +1,11 @@
 package actions
 
 import (
+	"../logging"
+	"../proxy"
 	"../registry"
+	"context"
 	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
 	"os"
@@ -19,10 +21,29 @@ func isSwarm(mode string) bool {
 }
 
 var lookupHost = net.LookupHost
-var logPrintf = log.Printf
+var osStat = os.Stat
+var logPrintf = logging.Infof
+var logWarnf = logging.Warnf
+var logErrorf = logging.Errorf
 var httpGet = http.Get
 var registryInstance registry.Registrarable = registry.Consul{}
 var writeFeTemplate = ioutil.WriteFile
 var writeBeTemplate = ioutil.WriteFile
 var readTemplateFile = ioutil.ReadFile
 var OsRemove = os.Remove
+
+// isReachable returns an error if the host cannot be resolved or, in case of a unix socket, the socket does not exist.
+// The lookup gives up when the context is done or after LOOKUP_TIMEOUT.
+func isReachable(ctx context.Context, host string) error {
+	if proxy.IsUnixSocket(host) {
+		_, err := osStat(strings.TrimPrefix(host, "unix://"))
+		return err
+	}
+	_, err := resolveHost(ctx, host)
+	return err
+}
+
+// getRegistryClient returns the client of the requests sent to the registry limited to REGISTRY_TIMEOUT.
+func getRegistryClient() *http.Client {
+	return &http.Client{Timeout: proxy.GetTimeout("REGISTRY_TIMEOUT", 10)}
+}
@@ -0,0 +1,175 @@
+package actions
+
+import (
+	"../proxy"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var dockerSocketPath = "/var/run/docker.sock"
+
+// getDocker sends a GET request to the Docker API through its socket and decodes the JSON response into v
+var getDocker = func(path string, v interface{}) error {
+	client := http.Client{
+		Transport: &http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return net.Dial("unix", dockerSocketPath)
+			},
+		},
+		Timeout: 10 * time.Second,
+	}
+	resp, err := client.Get("http://docker" + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Docker API responded to %s with the status code %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+type dockerTask struct {
+	ID                  string
+	NodeID              string
+	NetworksAttachments []struct {
+		Addresses []string
+	}
+}
+
+type dockerNode struct {
+	Spec struct {
+		Labels map[string]string
+	}
+}
+
+type dockerInfo struct {
+	Swarm struct {
+		NodeID string
+	}
+}
+
+// getZoneTasks returns the running tasks of the Swarm service that are reachable by the proxy.
+// Tasks running on nodes in a different zone than the proxy are marked as backups.
+// The zone of a node is the value of its label defined through ZONE_LABEL.
+func getZoneTasks(ctx context.Context, host string) ([]proxy.Task, error) {
+	addresses, err := resolveHost(ctx, "tasks."+host)
+	if err != nil {
+		return nil, err
+	}
+	reachable := map[string]bool{}
+	for _, address := range addresses {
+		reachable[getCanonicalIp(address)] = true
+	}
+	filters := fmt.Sprintf(`{"service":{"%s":true},"desired-state":{"running":true}}`, host)
+	dockerTasks := []dockerTask{}
+	if err := getDocker("/tasks?filters="+url.QueryEscape(filters), &dockerTasks); err != nil {
+		return nil, err
+	}
+	zoneLabel := proxy.GetSecretOrEnvVar("ZONE_LABEL", "zone")
+	zone, err := getProxyZone(zoneLabel)
+	if err != nil {
+		return nil, err
+	}
+	nodeZones := map[string]string{}
+	tasks := []proxy.Task{}
+	for _, t := range dockerTasks {
+		address := getTaskAddress(t, reachable)
+		if len(address) == 0 {
+			continue
+		}
+		if _, ok := nodeZones[t.NodeID]; !ok {
+			nodeZone, err := getNodeZone(t.NodeID, zoneLabel)
+			if err != nil {
+				return nil, err
+			}
+			nodeZones[t.NodeID] = nodeZone
+		}
+		name := t.ID
+		if len(name) > 12 {
+			name = name[:12]
+		}
+		tasks = append(tasks, proxy.Task{
+			Name:    name,
+			Address: proxy.GetServerHost(address),
+			Backup:  len(zone) > 0 && nodeZones[t.NodeID] != zone,
+		})
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("Could not find any running tasks of the service %s", host)
+	}
+	return tasks, nil
+}
+
+// getHostsZoneTasks resolves the tasks of all the hosts of the zone aware service
+// so that the servers follow the tasks of whichever host is scaled.
+// The tasks of the hosts after the first one are used only as backups, the same way as the hosts themselves.
+// If the tasks of a host cannot be resolved, the tasks resolved last are used or, if there are none, nil is returned
+// so that the addresses of the hosts are used instead.
+func getHostsZoneTasks(ctx context.Context, hosts []string) []proxy.Task {
+	var tasks []proxy.Task
+	for i, host := range hosts {
+		hostTasks, err := getZoneTasks(ctx, host)
+		if err == nil {
+			putStaleTasks(host, hostTasks)
+		} else if stale, resolved, ok := getStaleTasks(host); ok && ctx.Err() == nil && !isHostNotFound(err) {
+			logWarnf("Could not resolve the tasks of the service %s. The tasks resolved at %s will be used instead.\n%s", host, resolved.Format(time.RFC3339), err.Error())
+			hostTasks = stale
+		} else {
+			logWarnf("Could not resolve the tasks of the service %s. The service address will be used instead.\n%s", host, err.Error())
+			return nil
+		}
+		for _, t := range hostTasks {
+			t.Backup = t.Backup || i > 0
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks
+}
+
+// getProxyZone returns the ZONE variable or, if it is not set, the zone of the node the proxy is running on.
+func getProxyZone(zoneLabel string) (string, error) {
+	if zone := proxy.GetSecretOrEnvVar("ZONE", ""); len(zone) > 0 {
+		return zone, nil
+	}
+	info := dockerInfo{}
+	if err := getDocker("/info", &info); err != nil {
+		return "", err
+	}
+	return getNodeZone(info.Swarm.NodeID, zoneLabel)
+}
+
+func getNodeZone(nodeID, zoneLabel string) (string, error) {
+	node := dockerNode{}
+	if err := getDocker("/nodes/"+nodeID, &node); err != nil {
+		return "", err
+	}
+	return node.Spec.Labels[zoneLabel], nil
+}
+
+// getTaskAddress returns the address of the task in one of the networks shared with the proxy.
+func getTaskAddress(t dockerTask, reachable map[string]bool) string {
+	for _, attachment := range t.NetworksAttachments {
+		for _, address := range attachment.Addresses {
+			ip := getCanonicalIp(strings.SplitN(address, "/", 2)[0])
+			if reachable[ip] {
+				return ip
+			}
+		}
+	}
+	return ""
+}
+
+// getCanonicalIp returns the IP in the same format as DNS lookups so that different notations of IPv6 addresses match.
+func getCanonicalIp(address string) string {
+	if ip := net.ParseIP(address); ip != nil {
+		return ip.String()
+	}
+	return address
+}
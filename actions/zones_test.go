@@ -0,0 +1,105 @@
+// +build !integration
+
+package actions
+
+import (
+	"../proxy"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ZonesTestSuite struct {
+	suite.Suite
+	responses map[string]string
+}
+
+func TestZonesUnitTestSuite(t *testing.T) {
+	lookupHostOrig := lookupHost
+	defer func() { lookupHost = lookupHostOrig }()
+	getDockerOrig := getDocker
+	defer func() { getDocker = getDockerOrig }()
+	suite.Run(t, new(ZonesTestSuite))
+}
+
+func (s *ZonesTestSuite) SetupTest() {
+	lookupHost = func(host string) ([]string, error) {
+		return []string{"10.0.0.3", "10.0.0.4"}, nil
+	}
+	s.responses = map[string]string{
+		`/tasks?filters=%7B%22service%22%3A%7B%22my-service%22%3Atrue%7D%2C%22desired-state%22%3A%7B%22running%22%3Atrue%7D%7D`: `[
+			{"ID": "abcdefghijklmnop", "NodeID": "node-1", "NetworksAttachments": [{"Addresses": ["10.255.0.3/16"]}, {"Addresses": ["10.0.0.3/24"]}]},
+			{"ID": "qrstuvwxyz", "NodeID": "node-2", "NetworksAttachments": [{"Addresses": ["10.0.0.4/24"]}]},
+			{"ID": "unreachable", "NodeID": "node-2", "NetworksAttachments": [{"Addresses": ["10.1.0.4/24"]}]}
+		]`,
+		"/info":         `{"Swarm": {"NodeID": "node-1"}}`,
+		"/nodes/node-1": `{"Spec": {"Labels": {"zone": "eu-west-1a"}}}`,
+		"/nodes/node-2": `{"Spec": {"Labels": {"zone": "eu-west-1b"}}}`,
+	}
+	getDocker = func(path string, v interface{}) error {
+		if response, ok := s.responses[path]; ok {
+			return json.Unmarshal([]byte(response), v)
+		}
+		return fmt.Errorf("Unexpected path %s", path)
+	}
+}
+
+// getZoneTasks
+
+func (s ZonesTestSuite) Test_GetZoneTasks_MarksTasksInOtherZonesAsBackups() {
+	actual, err := getZoneTasks(context.Background(), "my-service")
+
+	s.NoError(err)
+	s.Equal([]proxy.Task{
+		{Name: "abcdefghijkl", Address: "10.0.0.3"},
+		{Name: "qrstuvwxyz", Address: "10.0.0.4", Backup: true},
+	}, actual)
+}
+
+func (s ZonesTestSuite) Test_GetZoneTasks_UsesZoneEnvVar() {
+	defer func() { os.Unsetenv("ZONE") }()
+	os.Setenv("ZONE", "eu-west-1b")
+
+	actual, _ := getZoneTasks(context.Background(), "my-service")
+
+	s.True(actual[0].Backup)
+	s.False(actual[1].Backup)
+}
+
+func (s ZonesTestSuite) Test_GetZoneTasks_ReturnsError_WhenDockerApiFails() {
+	getDocker = func(path string, v interface{}) error {
+		return fmt.Errorf("This is an error")
+	}
+
+	_, err := getZoneTasks(context.Background(), "my-service")
+
+	s.Error(err)
+}
+
+// getHostsZoneTasks
+
+func (s ZonesTestSuite) Test_GetHostsZoneTasks_MarksTasksOfOtherHostsAsBackups() {
+	s.responses[`/tasks?filters=%7B%22service%22%3A%7B%22my-service-v2%22%3Atrue%7D%2C%22desired-state%22%3A%7B%22running%22%3Atrue%7D%7D`] = `[
+		{"ID": "v2task", "NodeID": "node-1", "NetworksAttachments": [{"Addresses": ["10.0.0.3/24"]}]}
+	]`
+
+	actual := getHostsZoneTasks(context.Background(), []string{"my-service", "my-service-v2"})
+
+	s.Equal([]proxy.Task{
+		{Name: "abcdefghijkl", Address: "10.0.0.3"},
+		{Name: "qrstuvwxyz", Address: "10.0.0.4", Backup: true},
+		{Name: "v2task", Address: "10.0.0.3", Backup: true},
+	}, actual)
+}
+
+func (s ZonesTestSuite) Test_GetHostsZoneTasks_ReturnsNil_WhenTasksOfAnyHostCannotBeResolved() {
+	staleCache.tasks = map[string]staleEntry{}
+
+	actual := getHostsZoneTasks(context.Background(), []string{"my-service", "unknown-service"})
+
+	s.Nil(actual)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"../proxy"
+	"reflect"
+)
+
+// Services is the message streamed by the Watch call
+type Services struct {
+	Services []proxy.Service
+}
+
+// ReconfigureRequest is the message of the Reconfigure call
+type ReconfigureRequest struct {
+	Service proxy.Service
+	// The version of the service the request is applied to. Mismatches are rejected.
+	Version string
+}
+
+// RemoveRequest is the message of the Remove call. The fields are the same as the parameters of the remove request.
+type RemoveRequest struct {
+	ServiceName string
+	AclName     string
+	Namespace   string
+	Distribute  bool
+	DrainFirst  bool
+	KeepState   bool
+	RemoveCerts bool
+}
+
+// Response is returned by the Reconfigure and Remove calls
+type Response struct {
+	Status      string
+	Message     string
+	ServiceName string
+	Warnings    []string
+	Errors      []proxy.ValidationError
+}
+
+// StatusRequest is the message of the Status call
+type StatusRequest struct{}
+
+// StatusResponse is returned by the Status call. The times are formatted as RFC 3339.
+type StatusResponse struct {
+	ReloadCount        int64
+	ReloadFailureCount int64
+	LastReloadTime     string
+	LastReloadDuration float64
+	LastReloadError    string
+	LastConfigTime     string
+}
+
+// WatchRequest is the message of the Watch call
+type WatchRequest struct{}
+
+// The messages of admin.proto keyed by the types they are decoded into
+var messages = map[reflect.Type]message{
+	reflect.TypeOf(proxy.ServiceDest{}): newMessage(
+		"ServiceDest", proxy.ServiceDest{},
+		"Port", "ServicePath", "SrcPort", "TimeoutServer", "TimeoutTunnel", "Alpn", "FrontendTemplatePath", "DbRole",
+	),
+	reflect.TypeOf(proxy.User{}):       newMessage("User", proxy.User{}, "Username", "Password", "PassEncrypted"),
+	reflect.TypeOf(proxy.SplitGroup{}): newMessage("SplitGroup", proxy.SplitGroup{}, "Name", "Host"),
+	reflect.TypeOf(proxy.Service{}): newMessage(
+		"Service", proxy.Service{},
+		"AclName", "AclPriority", "AddPathPrefix", "ConnectionMode", "Http10Compatibility",
+		"AcceptInvalidHttpResponse", "ConsulTemplateFePath", "ConsulTemplateBePath", "Critical", "Distribute",
+		"BandwidthLimitPerStream", "BandwidthLimitTotal", "CaptureCookies", "CaptureRequestHeaders",
+		"CorsAllowHeaders", "CorsAllowMethods", "CorsAllowOrigins", "CorsMaxAge", "CorsPreflight",
+		"ExternalCheckCommand", "HttpReuse", "HttpsOnly", "HttpsPort", "LogSampleRate", "LoggingDisabled",
+		"Maintenance", "MaxIdleConnections", "MirrorPercentage", "MirrorToService", "Namespace",
+		"NormalizeTrailingSlash", "OutboundHostname", "PathType", "PathMatchCaseInsensitive", "CanonicalDomain",
+		"RedirectWhenHttpProto", "ReqMode", "ReqRepReplace", "ReqRepSearch", "ReqPathReplace", "ReqPathSearch",
+		"RewriteResponseUrls", "ServiceCert", "ServiceCerts", "CertSecret", "ServiceDomain",
+		"ServiceDomainMatchAll", "ServiceName", "SetHostHeader", "StripPath", "SendProxyProtocol", "SkipCheck",
+		"SplitBy", "SplitGroups", "TcpPreset", "SslVerifyNone", "BackendCaFile", "BackendSni",
+		"StaticResponseBody", "StaticResponseContentType", "StaticResponseStatus", "TemplateBePath",
+		"TemplateFePath", "TimeoutServer", "TimeoutTunnel", "ZoneAware", "TtlSeconds", "Users", "ServiceColor",
+		"ServiceDest", "", "Errorfile500Path", "Errorfile502Path", "Errorfile503Path",
+		"SrcNetworks", "NormalizeUri", "Blocklist", "BlocklistIpsPath", "BlocklistUserAgentsPath", "MaxUrlLength",
+		"MqttStickiness", "DbReaders", "DbWriterCheckCommand", "DbWriters", "DomainAclPriority",
+	),
+	reflect.TypeOf(Services{}):           newMessage("Services", Services{}, "Services"),
+	reflect.TypeOf(ReconfigureRequest{}): newMessage("ReconfigureRequest", ReconfigureRequest{}, "Service", "Version"),
+	reflect.TypeOf(RemoveRequest{}): newMessage(
+		"RemoveRequest", RemoveRequest{},
+		"ServiceName", "AclName", "Namespace", "Distribute", "DrainFirst", "KeepState", "RemoveCerts",
+	),
+	reflect.TypeOf(proxy.ValidationError{}): newMessage("ValidationError", proxy.ValidationError{}, "Field", "Message"),
+	reflect.TypeOf(Response{}): newMessage(
+		"Response", Response{},
+		"Status", "Message", "ServiceName", "Warnings", "Errors",
+	),
+	reflect.TypeOf(StatusRequest{}): newMessage("StatusRequest", StatusRequest{}),
+	reflect.TypeOf(StatusResponse{}): newMessage(
+		"StatusResponse", StatusResponse{},
+		"ReloadCount", "ReloadFailureCount", "LastReloadTime", "LastReloadDuration", "LastReloadError", "LastConfigTime",
+	),
+	reflect.TypeOf(WatchRequest{}): newMessage("WatchRequest", WatchRequest{}),
+}
@@ -0,0 +1,306 @@
+package api
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// The protobuf wire types used by the messages of the admin API
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+type message struct {
+	// The name of the message in admin.proto
+	name string
+	// The indexes of the struct fields. The number of a field is its position in the slice plus one.
+	// Reserved numbers have the index -1.
+	fields []int
+}
+
+// newMessage maps the fields of the struct v to the fields of the protobuf message name.
+// The fields are listed in the order of their numbers in admin.proto. An empty name reserves the number of a removed field.
+func newMessage(name string, v interface{}, fields ...string) message {
+	t := reflect.TypeOf(v)
+	m := message{name: name}
+	for _, fieldName := range fields {
+		if len(fieldName) == 0 {
+			m.fields = append(m.fields, -1)
+			continue
+		}
+		field, ok := t.FieldByName(fieldName)
+		if !ok || len(field.Index) != 1 {
+			panic(fmt.Sprintf("%s does not have the field %s", t.Name(), fieldName))
+		}
+		m.fields = append(m.fields, field.Index[0])
+	}
+	return m
+}
+
+// Marshal encodes the message in the protobuf wire format. Fields with zero values are not encoded, the same as in proto3.
+func Marshal(msg interface{}) ([]byte, error) {
+	v := reflect.ValueOf(msg)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	return encodeMessage(v)
+}
+
+// Unmarshal decodes the protobuf wire format into the message. Unknown fields are skipped.
+func Unmarshal(data []byte, msg interface{}) error {
+	v := reflect.ValueOf(msg)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("Unmarshal requires a non-nil pointer")
+	}
+	return decodeMessage(data, v.Elem())
+}
+
+func getMessage(t reflect.Type) (message, error) {
+	m, ok := messages[t]
+	if !ok {
+		return m, fmt.Errorf("%s is not a message of the admin API", t)
+	}
+	return m, nil
+}
+
+func encodeMessage(v reflect.Value) ([]byte, error) {
+	m, err := getMessage(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	buf := []byte{}
+	for i, index := range m.fields {
+		if index < 0 {
+			continue
+		}
+		if buf, err = encodeField(buf, i+1, v.Field(index)); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func encodeField(buf []byte, number int, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			var err error
+			if buf, err = encodeValue(buf, number, v.Index(i), true); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("The keys of %s are not strings", v.Type())
+		}
+		keys := []string{}
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			entry, err := encodeValue([]byte{}, 1, reflect.ValueOf(key), false)
+			if err != nil {
+				return nil, err
+			}
+			if entry, err = encodeValue(entry, 2, v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())), false); err != nil {
+				return nil, err
+			}
+			buf = appendBytes(appendKey(buf, number, wireBytes), entry)
+		}
+		return buf, nil
+	}
+	return encodeValue(buf, number, v, false)
+}
+
+// encodeValue appends a single value. The elements of repeated fields are encoded even when they are zero.
+func encodeValue(buf []byte, number int, v reflect.Value, repeated bool) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() > 0 || repeated {
+			buf = appendBytes(appendKey(buf, number, wireBytes), []byte(v.String()))
+		}
+	case reflect.Bool:
+		if v.Bool() || repeated {
+			value := uint64(0)
+			if v.Bool() {
+				value = 1
+			}
+			buf = appendVarint(appendKey(buf, number, wireVarint), value)
+		}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		if v.Int() != 0 || repeated {
+			buf = appendVarint(appendKey(buf, number, wireVarint), uint64(v.Int()))
+		}
+	case reflect.Float64:
+		if v.Float() != 0 || repeated {
+			bits := make([]byte, 8)
+			binary.LittleEndian.PutUint64(bits, math.Float64bits(v.Float()))
+			buf = append(appendKey(buf, number, wireFixed64), bits...)
+		}
+	case reflect.Struct:
+		data, err := encodeMessage(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > 0 || repeated {
+			buf = appendBytes(appendKey(buf, number, wireBytes), data)
+		}
+	default:
+		return nil, fmt.Errorf("%s cannot be encoded", v.Type())
+	}
+	return buf, nil
+}
+
+func appendKey(buf []byte, number, wire int) []byte {
+	return appendVarint(buf, uint64(number)<<3|uint64(wire))
+}
+
+func appendVarint(buf []byte, value uint64) []byte {
+	for value >= 0x80 {
+		buf = append(buf, byte(value)|0x80)
+		value >>= 7
+	}
+	return append(buf, byte(value))
+}
+
+func appendBytes(buf []byte, data []byte) []byte {
+	return append(appendVarint(buf, uint64(len(data))), data...)
+}
+
+func decodeMessage(data []byte, v reflect.Value) error {
+	m, err := getMessage(v.Type())
+	if err != nil {
+		return err
+	}
+	return decodeFields(data, func(number, wire int, value uint64, payload []byte) error {
+		if number < 1 || number > len(m.fields) || m.fields[number-1] < 0 {
+			return nil
+		}
+		field := v.Field(m.fields[number-1])
+		if err := decodeField(field, wire, value, payload); err != nil {
+			return fmt.Errorf("%s.%s: %s", m.name, v.Type().Field(m.fields[number-1]).Name, err.Error())
+		}
+		return nil
+	})
+}
+
+// decodeFields calls fn with each field of the encoded message.
+// The value holds varint and fixed fields. The payload holds length-delimited fields.
+func decodeFields(data []byte, fn func(number, wire int, value uint64, payload []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("The message is truncated")
+		}
+		data = data[n:]
+		number, wire := int(key>>3), int(key&7)
+		value := uint64(0)
+		var payload []byte
+		switch wire {
+		case wireVarint:
+			if value, n = binary.Uvarint(data); n <= 0 {
+				return fmt.Errorf("The message is truncated")
+			}
+			data = data[n:]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if wire == wireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return fmt.Errorf("The message is truncated")
+			}
+			if size == 8 {
+				value = binary.LittleEndian.Uint64(data)
+			} else {
+				value = uint64(binary.LittleEndian.Uint32(data))
+			}
+			data = data[size:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return fmt.Errorf("The message is truncated")
+			}
+			payload = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return fmt.Errorf("The wire type %d is not supported", wire)
+		}
+		if err := fn(number, wire, value, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeField(v reflect.Value, wire int, value uint64, payload []byte) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := decodeValue(elem, wire, value, payload); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, elem))
+		return nil
+	case reflect.Map:
+		if wire != wireBytes {
+			return fmt.Errorf("The wire type %d does not match a map", wire)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.New(v.Type().Key()).Elem()
+		elem := reflect.New(v.Type().Elem()).Elem()
+		err := decodeFields(payload, func(number, wire int, value uint64, payload []byte) error {
+			switch number {
+			case 1:
+				return decodeValue(key, wire, value, payload)
+			case 2:
+				return decodeValue(elem, wire, value, payload)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+	}
+	return decodeValue(v, wire, value, payload)
+}
+
+func decodeValue(v reflect.Value, wire int, value uint64, payload []byte) error {
+	expected := wireVarint
+	switch v.Kind() {
+	case reflect.String, reflect.Struct:
+		expected = wireBytes
+	case reflect.Float64:
+		expected = wireFixed64
+	}
+	if wire != expected {
+		return fmt.Errorf("The wire type %d does not match %s", wire, v.Type())
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(payload))
+	case reflect.Bool:
+		v.SetBool(value != 0)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(value))
+	case reflect.Float64:
+		v.SetFloat(math.Float64frombits(value))
+	case reflect.Struct:
+		return decodeMessage(payload, v)
+	default:
+		return fmt.Errorf("%s cannot be decoded", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// +build !integration
+
+package api
+
+import (
+	"../proxy"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type CodecTestSuite struct {
+	suite.Suite
+}
+
+func TestCodecUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(CodecTestSuite))
+}
+
+// Marshal
+
+func (s *CodecTestSuite) Test_Marshal_EncodesFieldsInWireFormat() {
+	actual, err := Marshal(RemoveRequest{ServiceName: "abc", DrainFirst: true})
+
+	s.NoError(err)
+	s.Equal([]byte{0x0a, 3, 'a', 'b', 'c', 0x28, 1}, actual)
+}
+
+func (s *CodecTestSuite) Test_Marshal_SkipsZeroValues() {
+	actual, err := Marshal(StatusResponse{})
+
+	s.NoError(err)
+	s.Empty(actual)
+}
+
+func (s *CodecTestSuite) Test_Marshal_ReturnsError_WhenTypeIsNotMessage() {
+	_, err := Marshal(proxy.Task{})
+
+	s.Error(err)
+}
+
+// Unmarshal
+
+func (s *CodecTestSuite) Test_Unmarshal_DecodesMarshaledMessage() {
+	expected := ReconfigureRequest{
+		Service: proxy.Service{
+			ServiceName:       "my-service",
+			AclPriority:       -5,
+			HttpsOnly:         true,
+			ServiceDomain:     []string{"acme.com", ""},
+			ServiceCerts:      map[string]string{"acme.com": "acme.pem", "foo.com": "foo.pem"},
+			DomainAclPriority: map[string]int{"acme.com": -1},
+			Users:             []proxy.User{{Username: "user", Password: "pass", PassEncrypted: true}},
+			ServiceDest: []proxy.ServiceDest{
+				{Port: "1111", ServicePath: []string{"/api"}, SrcPort: 443},
+				{Port: "2222", TimeoutServer: "10"},
+			},
+		},
+		Version: "123",
+	}
+	data, err := Marshal(&expected)
+	s.NoError(err)
+	actual := ReconfigureRequest{}
+
+	err = Unmarshal(data, &actual)
+
+	s.NoError(err)
+	s.Equal(expected, actual)
+}
+
+func (s *CodecTestSuite) Test_Unmarshal_DecodesFloats() {
+	expected := StatusResponse{ReloadCount: 3, LastReloadDuration: 0.25, LastReloadTime: "2017-01-01T00:00:00Z"}
+	data, _ := Marshal(expected)
+	actual := StatusResponse{}
+
+	Unmarshal(data, &actual)
+
+	s.Equal(expected, actual)
+}
+
+func (s *CodecTestSuite) Test_Unmarshal_SkipsUnknownFields() {
+	data := []byte{0x50, 7, 0x5a, 1, 'x', 0x61, 0, 0, 0, 0, 0, 0, 0, 0, 0x6d, 0, 0, 0, 0, 0x0a, 3, 'a', 'b', 'c'}
+	actual := RemoveRequest{}
+
+	err := Unmarshal(data, &actual)
+
+	s.NoError(err)
+	s.Equal(RemoveRequest{ServiceName: "abc"}, actual)
+}
+
+func (s *CodecTestSuite) Test_Unmarshal_SkipsReservedFields() {
+	data := []byte{0xba, 0x04, 3, 'a', 'b', 'c', 0x0a, 3, 'a', 'b', 'c'}
+	actual := proxy.Service{}
+
+	err := Unmarshal(data, &actual)
+
+	s.NoError(err)
+	s.Equal(proxy.Service{AclName: "abc"}, actual)
+}
+
+func (s *CodecTestSuite) Test_Unmarshal_ReturnsError_WhenMessageIsTruncated() {
+	actual := RemoveRequest{}
+
+	err := Unmarshal([]byte{0x0a, 3, 'a'}, &actual)
+
+	s.Error(err)
+}
+
+func (s *CodecTestSuite) Test_Unmarshal_ReturnsError_WhenWireTypeDoesNotMatchField() {
+	actual := RemoveRequest{}
+
+	err := Unmarshal([]byte{0x08, 1}, &actual)
+
+	s.Error(err)
+}
+
+func (s *CodecTestSuite) Test_Unmarshal_ReturnsError_WhenMessageIsNotPointer() {
+	err := Unmarshal([]byte{}, RemoveRequest{})
+
+	s.Error(err)
+}
+
+// messages
+
+func (s *CodecTestSuite) Test_Messages_MatchAdminProto() {
+	content, err := ioutil.ReadFile("admin.proto")
+	s.NoError(err)
+	snakeCase := regexp.MustCompile(`([a-z0-9])([A-Z])`)
+	for t, m := range messages {
+		definition := regexp.MustCompile(`(?s)\nmessage ` + m.name + ` \{(.*?)\}\n`).FindStringSubmatch(string(content))
+		if !s.Len(definition, 2, "%s is missing in admin.proto", m.name) {
+			continue
+		}
+		fields := regexp.MustCompile(`(\w+) = (\d+);`).FindAllStringSubmatch(definition[1], -1)
+		reserved := regexp.MustCompile(`reserved (\d+);`).FindAllStringSubmatch(definition[1], -1)
+		s.Len(fields, len(m.fields)-len(reserved), "The number of the fields of %s does not match", m.name)
+		for _, r := range reserved {
+			number, _ := strconv.Atoi(r[1])
+			s.True(number > 0 && number <= len(m.fields) && m.fields[number-1] < 0, "%s reserves %d", m.name, number)
+		}
+		for _, field := range fields {
+			number, _ := strconv.Atoi(field[2])
+			if !s.True(number > 0 && number <= len(m.fields), "%s.%s is not mapped", m.name, field[1]) {
+				continue
+			}
+			name := t.Field(m.fields[number-1]).Name
+			s.Equal(field[1], strings.ToLower(snakeCase.ReplaceAllString(name, "${1}_${2}")), "%s = %d", m.name, number)
+		}
+	}
+}
@@ -19,6 +19,7 @@ func (a Args) Parse() error {
 	parser.AddCommand("run", "Runs the proxy", "Runs the proxy", &run)
 	parser.AddCommand("reconfigure", "Reconfigures the proxy", "Reconfigures the proxy using information stored in Consul", &actions.ReconfigureInstance)
 	parser.AddCommand("remove", "Removes a service from the proxy", "Removes a service from the proxy", &actions.RemoveInstance)
+	parser.AddCommand("bench", "Benchmarks the configuration generation", "Generates synthetic services and measures how long rendering, validating, and reloading their configuration takes", &bench)
 	if _, err := parser.ParseArgs(os.Args[1:]); err != nil {
 		return fmt.Errorf("Could not parse command line arguments\n%s", err.Error())
 	}
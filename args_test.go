@@ -3,6 +3,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -231,7 +232,7 @@ type RegistrarableMock struct {
 	mock.Mock
 }
 
-func (m *RegistrarableMock) PutService(addresses []string, instanceName string, r registry.Registry) error {
+func (m *RegistrarableMock) PutService(ctx context.Context, addresses []string, instanceName string, r registry.Registry) error {
 	params := m.Called(addresses, instanceName, r)
 	return params.Error(0)
 }
@@ -249,7 +250,7 @@ func (m *RegistrarableMock) SendDeleteRequest(addresses []string, serviceName, k
 	m.Called(addresses, serviceName, key, value, instanceName, c)
 }
 
-func (m *RegistrarableMock) CreateConfigs(args *registry.CreateConfigsArgs) error {
+func (m *RegistrarableMock) CreateConfigs(ctx context.Context, args *registry.CreateConfigsArgs) error {
 	params := m.Called(args)
 	return params.Error(0)
 }
@@ -262,6 +263,16 @@ func (m *RegistrarableMock) GetServiceAttribute(addresses []string, instanceName
 	return "something", params.Error(0)
 }
 
+func (m *RegistrarableMock) PutServiceAttributeIfAbsent(addresses []string, serviceName, key, value, instanceName string) (string, error) {
+	params := m.Called(addresses, serviceName, key, value, instanceName)
+	return params.String(0), params.Error(1)
+}
+
+func (m *RegistrarableMock) DeleteServiceAttribute(addresses []string, serviceName, key, instanceName string) error {
+	params := m.Called(addresses, serviceName, key, instanceName)
+	return params.Error(0)
+}
+
 func getRegistrarableMock(skipMethod string) *RegistrarableMock {
 	mockObj := new(RegistrarableMock)
 	if skipMethod != "PutService" {
@@ -282,6 +293,9 @@ func getRegistrarableMock(skipMethod string) *RegistrarableMock {
 	if skipMethod != "GetServiceAttribute" {
 		mockObj.On("GetServiceAttribute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	}
+	if skipMethod != "DeleteServiceAttribute" {
+		mockObj.On("DeleteServiceAttribute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	}
 	return mockObj
 }
 
@@ -304,6 +318,11 @@ func (m *ProxyMock) ReadConfig() (string, error) {
 	return params.String(0), params.Error(1)
 }
 
+func (m *ProxyMock) RenderConfig() (string, error) {
+	params := m.Called()
+	return params.String(0), params.Error(1)
+}
+
 func (m *ProxyMock) Reload() error {
 	params := m.Called()
 	return params.Error(0)
@@ -331,6 +350,12 @@ func (m *ProxyMock) GetCertPaths() []string {
 	return params.Get(0).([]string)
 }
 
+func (m *ProxyMock) GetServices() map[string]proxy.Service {
+	params := m.Called()
+	return params.Get(0).(map[string]proxy.Service)
+}
+
+
 func getProxyMock(skipMethod string) *ProxyMock {
 	mockObj := new(ProxyMock)
 	if skipMethod != "RunCmd" {
@@ -342,6 +367,9 @@ func getProxyMock(skipMethod string) *ProxyMock {
 	if skipMethod != "ReadConfig" {
 		mockObj.On("ReadConfig").Return("", nil)
 	}
+	if skipMethod != "RenderConfig" {
+		mockObj.On("RenderConfig").Return("", nil)
+	}
 	if skipMethod != "Reload" {
 		mockObj.On("Reload").Return(nil)
 	}
@@ -360,5 +388,8 @@ func getProxyMock(skipMethod string) *ProxyMock {
 	if skipMethod != "GetCertPaths" {
 		mockObj.On("GetCertPaths")
 	}
+	if skipMethod != "GetServices" {
+		mockObj.On("GetServices").Return(map[string]proxy.Service{})
+	}
 	return mockObj
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"./proxy"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Bench measures how long generating and applying the configuration of many services takes.
+type Bench struct {
+	Services      int    `short:"n" long:"services" default:"100" description:"The number of synthetic services to generate."`
+	Engine        string `long:"engine" env:"PROXY_ENGINE" default:"haproxy" description:"The engine (haproxy, nginx, or envoy) the configuration is generated for."`
+	TemplatesPath string `short:"t" long:"templates-path" default:"/cfg/tmpl" description:"The path to the templates directory"`
+	Validate      bool   `long:"validate" description:"Whether the generated configuration should be validated by the proxy binary."`
+	Reload        bool   `long:"reload" description:"Whether the running proxy should be reloaded with the generated configuration. The configuration of the proxy is replaced with the synthetic services."`
+}
+
+// BenchReport contains the timings and the resources used by the benchmark.
+type BenchReport struct {
+	Services int
+	Engine   string
+	// The size of the generated configuration in bytes.
+	ConfigSize int
+	// The time it took to apply all the services.
+	ApplyAll time.Duration
+	// The time it took to render the configuration with all the services.
+	Render time.Duration
+	// The time it took to apply a change of one of the services.
+	ApplyOne time.Duration
+	// The time it took to validate the configuration. Zero if it was not validated.
+	Validate time.Duration
+	// The time it took to reload the proxy. Zero if it was not reloaded.
+	Reload time.Duration
+	// The bytes of the allocated heap objects after the benchmark.
+	HeapAlloc uint64
+	// The bytes allocated during the benchmark.
+	TotalAlloc uint64
+	UserCpu    time.Duration
+	SystemCpu  time.Duration
+}
+
+var bench Bench
+var benchOutput io.Writer = os.Stdout
+var benchTempDir = ioutil.TempDir
+var benchRunCmd = func(cmd *exec.Cmd) error {
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s\n%s", err.Error(), string(out))
+	}
+	return nil
+}
+
+func (m *Bench) Execute(args []string) error {
+	report, err := m.run()
+	if err != nil {
+		return err
+	}
+	m.print(report)
+	return nil
+}
+
+func (m *Bench) run() (BenchReport, error) {
+	report := BenchReport{Services: m.Services, Engine: strings.ToLower(m.Engine)}
+	configsPath, err := benchTempDir("", "dfp-bench")
+	if err != nil {
+		return report, err
+	}
+	defer os.RemoveAll(configsPath)
+	if m.Reload {
+		// The running proxy reads the configuration from its own directory
+		configsPath = "/cfg"
+	}
+	controller, err := proxy.NewController(proxy.ControllerOptions{
+		Engine:        m.Engine,
+		TemplatesPath: m.TemplatesPath,
+		ConfigsPath:   configsPath,
+		DryRun:        true,
+	})
+	if err != nil {
+		return report, err
+	}
+	services := getBenchServices(m.Services)
+	ctx := context.Background()
+	runtime.GC()
+	memBefore := runtime.MemStats{}
+	runtime.ReadMemStats(&memBefore)
+	usageBefore := syscall.Rusage{}
+	syscall.Getrusage(syscall.RUSAGE_SELF, &usageBefore)
+
+	start := time.Now()
+	if err := controller.Apply(ctx, services...); err != nil {
+		return report, err
+	}
+	report.ApplyAll = time.Since(start)
+	start = time.Now()
+	config, err := controller.Render()
+	if err != nil {
+		return report, err
+	}
+	report.Render = time.Since(start)
+	report.ConfigSize = len(config)
+	if len(services) > 0 {
+		changed := services[0]
+		changed.ServiceDest = []proxy.ServiceDest{{Port: "8080", ServicePath: []string{"/bench-0", "/bench-0-v2"}}}
+		start = time.Now()
+		if err := controller.Apply(ctx, changed); err != nil {
+			return report, err
+		}
+		report.ApplyOne = time.Since(start)
+	}
+	if m.Validate {
+		start = time.Now()
+		if err := validateBenchConfig(report.Engine, configsPath); err != nil {
+			return report, fmt.Errorf("The generated configuration is not valid\n%s", err.Error())
+		}
+		report.Validate = time.Since(start)
+	}
+	if m.Reload {
+		start = time.Now()
+		if err := controller.Proxy().Reload(); err != nil {
+			return report, err
+		}
+		report.Reload = time.Since(start)
+	}
+
+	memAfter := runtime.MemStats{}
+	runtime.ReadMemStats(&memAfter)
+	usageAfter := syscall.Rusage{}
+	syscall.Getrusage(syscall.RUSAGE_SELF, &usageAfter)
+	report.HeapAlloc = memAfter.HeapAlloc
+	report.TotalAlloc = memAfter.TotalAlloc - memBefore.TotalAlloc
+	report.UserCpu = time.Duration(usageAfter.Utime.Nano() - usageBefore.Utime.Nano())
+	report.SystemCpu = time.Duration(usageAfter.Stime.Nano() - usageBefore.Stime.Nano())
+	return report, nil
+}
+
+func (m *Bench) print(report BenchReport) {
+	perService := time.Duration(0)
+	if report.Services > 0 {
+		perService = report.ApplyAll / time.Duration(report.Services)
+	}
+	lines := []string{
+		fmt.Sprintf("Services:               %d", report.Services),
+		fmt.Sprintf("Engine:                 %s", report.Engine),
+		fmt.Sprintf("Configuration size:     %d bytes", report.ConfigSize),
+		fmt.Sprintf("Apply (all services):   %s (%s per service)", report.ApplyAll, perService),
+		fmt.Sprintf("Render:                 %s", report.Render),
+		fmt.Sprintf("Apply (one service):    %s", report.ApplyOne),
+		fmt.Sprintf("Validate:               %s", getBenchDuration(report.Validate, m.Validate)),
+		fmt.Sprintf("Reload:                 %s", getBenchDuration(report.Reload, m.Reload)),
+		fmt.Sprintf("Heap in use:            %.1f MB", float64(report.HeapAlloc)/1024/1024),
+		fmt.Sprintf("Allocated:              %.1f MB", float64(report.TotalAlloc)/1024/1024),
+		fmt.Sprintf("CPU (user/system):      %s/%s", report.UserCpu, report.SystemCpu),
+	}
+	fmt.Fprintln(benchOutput, strings.Join(lines, "\n"))
+}
+
+// getBenchServices returns services with a path, a domain, and a port each, which is what most services look like.
+func getBenchServices(count int) []proxy.Service {
+	services := []proxy.Service{}
+	for i := 0; i < count; i++ {
+		services = append(services, proxy.Service{
+			ServiceName:   fmt.Sprintf("bench-service-%d", i),
+			ServiceDomain: []string{fmt.Sprintf("bench-%d.example.com", i)},
+			ServiceDest:   []proxy.ServiceDest{{Port: "8080", ServicePath: []string{fmt.Sprintf("/bench-%d", i)}}},
+		})
+	}
+	return services
+}
+
+// validateBenchConfig checks the configuration with the binary of the engine.
+func validateBenchConfig(engine, configsPath string) error {
+	switch engine {
+	case "haproxy":
+		return benchRunCmd(exec.Command("haproxy", "-c", "-f", configsPath+"/haproxy.cfg"))
+	case "nginx":
+		return benchRunCmd(exec.Command("nginx", "-t", "-c", configsPath+"/nginx.conf"))
+	}
+	return fmt.Errorf("The configuration of the %s engine cannot be validated", engine)
+}
+
+func getBenchDuration(duration time.Duration, enabled bool) string {
+	if !enabled {
+		return "skipped"
+	}
+	return duration.String()
+}
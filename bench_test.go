@@ -0,0 +1,102 @@
+// +build !integration
+
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type BenchTestSuite struct {
+	suite.Suite
+	output   *bytes.Buffer
+	commands [][]string
+}
+
+func (s *BenchTestSuite) SetupTest() {
+	s.output = &bytes.Buffer{}
+	benchOutput = s.output
+	s.commands = [][]string{}
+	benchRunCmd = func(cmd *exec.Cmd) error {
+		s.commands = append(s.commands, cmd.Args)
+		return nil
+	}
+}
+
+func TestBenchUnitTestSuite(t *testing.T) {
+	benchOutputOrig := benchOutput
+	defer func() { benchOutput = benchOutputOrig }()
+	benchRunCmdOrig := benchRunCmd
+	defer func() { benchRunCmd = benchRunCmdOrig }()
+	suite.Run(t, new(BenchTestSuite))
+}
+
+// Execute
+
+func (s *BenchTestSuite) Test_Execute_PrintsReport() {
+	b := Bench{Services: 3, Engine: "haproxy", TemplatesPath: "proxy/test_configs/tmpl"}
+
+	err := b.Execute([]string{})
+
+	s.NoError(err)
+	actual := s.output.String()
+	s.Contains(actual, "Services:               3\n")
+	s.Contains(actual, "Engine:                 haproxy\n")
+	s.Contains(actual, "Validate:               skipped\n")
+	s.Contains(actual, "Reload:                 skipped\n")
+	s.Contains(actual, "CPU (user/system):")
+	s.Empty(s.commands)
+}
+
+func (s *BenchTestSuite) Test_Execute_ReturnsError_WhenEngineIsNotSupported() {
+	b := Bench{Services: 3, Engine: "traefik", TemplatesPath: "proxy/test_configs/tmpl"}
+
+	err := b.Execute([]string{})
+
+	s.Error(err)
+	s.Empty(s.output.String())
+}
+
+func (s *BenchTestSuite) Test_Execute_ValidatesConfig() {
+	for engine, expected := range map[string][]string{
+		"haproxy": {"haproxy", "-c", "-f"},
+		"nginx":   {"nginx", "-t", "-c"},
+	} {
+		s.commands = [][]string{}
+		b := Bench{Services: 3, Engine: engine, TemplatesPath: "proxy/test_configs/tmpl", Validate: true}
+
+		err := b.Execute([]string{})
+
+		s.NoError(err)
+		s.Len(s.commands, 1)
+		s.Equal(expected, s.commands[0][:3])
+	}
+}
+
+// run
+
+func (s *BenchTestSuite) Test_run_RendersAllServices() {
+	b := Bench{Services: 50, Engine: "haproxy", TemplatesPath: "proxy/test_configs/tmpl"}
+
+	actual, err := b.run()
+
+	s.NoError(err)
+	s.Equal(50, actual.Services)
+	s.True(actual.ConfigSize > 50*len("backend bench-service-0-be8080"))
+	s.True(actual.ApplyAll > 0)
+	s.True(actual.ApplyOne > 0)
+}
+
+// getBenchServices
+
+func (s *BenchTestSuite) Test_getBenchServices_ReturnsServices() {
+	actual := getBenchServices(2)
+
+	s.Len(actual, 2)
+	s.Equal("bench-service-1", actual[1].ServiceName)
+	s.Equal([]string{"bench-1.example.com"}, actual[1].ServiceDomain)
+	s.Equal([]string{"/bench-1"}, actual[1].ServiceDest[0].ServicePath)
+}
@@ -0,0 +1,288 @@
+// Package client is the Go client of the proxy API.
+// It converts services to the query parameters of the reconfigure request, authenticates with namespace tokens,
+// and retries the requests that failed because the proxy could not be reached or was unavailable.
+package client
+
+import (
+	"../proxy"
+	"../server"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client sends requests to the API of the proxy.
+type Client struct {
+	// The address of the API of the proxy (e.g. http://proxy:8080).
+	Address string
+	// The token sent through the Authorization header. Required when the proxy uses NAMESPACE_TOKENS.
+	Token string
+	// The number of times a request is retried when the proxy cannot be reached or responds with 502, 503, or 504.
+	Retries int
+	// The time to wait before the first retry. It doubles after each retry.
+	RetryInterval time.Duration
+	HttpClient    *http.Client
+}
+
+// RemoveOptions are the optional parameters of the remove request.
+type RemoveOptions struct {
+	AclName     string
+	Namespace   string
+	Distribute  bool
+	DrainFirst  bool
+	KeepState   bool
+	RemoveCerts bool
+}
+
+// New returns a client of the proxy API at the address that retries failed requests three times.
+func New(address string) *Client {
+	return &Client{
+		Address:       strings.TrimSuffix(address, "/"),
+		Retries:       3,
+		RetryInterval: time.Second,
+		HttpClient:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Reconfigure adds or updates the service.
+// The response is returned together with the error when the proxy rejected the service
+// so that its validation errors and configuration issues can be inspected.
+func (m *Client) Reconfigure(ctx context.Context, s proxy.Service) (server.Response, error) {
+	params, err := GetServiceParams(s)
+	if err != nil {
+		return server.Response{}, err
+	}
+	return m.doJson(ctx, "GET", "/v1/docker-flow-proxy/reconfigure", params, nil)
+}
+
+// Remove removes the service.
+func (m *Client) Remove(ctx context.Context, serviceName string, options RemoveOptions) (server.Response, error) {
+	params := url.Values{}
+	params.Set("serviceName", serviceName)
+	if len(options.AclName) > 0 {
+		params.Set("aclName", options.AclName)
+	}
+	if len(options.Namespace) > 0 {
+		params.Set("namespace", options.Namespace)
+	}
+	for param, value := range map[string]bool{
+		"distribute":  options.Distribute,
+		"drainFirst":  options.DrainFirst,
+		"keepState":   options.KeepState,
+		"removeCerts": options.RemoveCerts,
+	} {
+		if value {
+			params.Set(param, "true")
+		}
+	}
+	return m.doJson(ctx, "GET", "/v1/docker-flow-proxy/remove", params, nil)
+}
+
+// PutCert adds or replaces the certificate with the content of the PEM file.
+func (m *Client) PutCert(ctx context.Context, certName string, cert []byte, distribute bool) error {
+	params := url.Values{}
+	params.Set("certName", certName)
+	if distribute {
+		params.Set("distribute", "true")
+	}
+	_, _, err := m.do(ctx, "PUT", "/v1/docker-flow-proxy/cert", params, cert)
+	return err
+}
+
+// GetConfig returns the configuration of the proxy.
+func (m *Client) GetConfig(ctx context.Context) (string, error) {
+	body, _, err := m.do(ctx, "GET", "/v1/docker-flow-proxy/config", url.Values{}, nil)
+	return string(body), err
+}
+
+func (m *Client) doJson(ctx context.Context, method, path string, params url.Values, body []byte) (server.Response, error) {
+	response := server.Response{}
+	respBody, status, err := m.do(ctx, method, path, params, body)
+	if status == 0 {
+		return response, err
+	}
+	if jsonErr := json.Unmarshal(respBody, &response); jsonErr != nil && err == nil {
+		err = fmt.Errorf("Could not parse the response of %s\n%s", path, jsonErr.Error())
+	}
+	if err != nil && len(response.Message) > 0 {
+		err = fmt.Errorf("%s\n%s", err.Error(), response.Message)
+	}
+	return response, err
+}
+
+// do sends the request and returns the body and the status code of the response.
+// The status code is zero when the proxy could not be reached.
+func (m *Client) do(ctx context.Context, method, path string, params url.Values, body []byte) ([]byte, int, error) {
+	addr := fmt.Sprintf("%s%s?%s", m.Address, path, params.Encode())
+	httpClient := m.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	interval := m.RetryInterval
+	for attempt := 0; ; attempt++ {
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, addr, reqBody)
+		if err != nil {
+			return nil, 0, err
+		}
+		req = req.WithContext(ctx)
+		if len(m.Token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+m.Token)
+		}
+		respBody, status, err := m.send(httpClient, req)
+		if !isRetryable(status) || attempt >= m.Retries {
+			if err == nil && status >= 300 {
+				err = fmt.Errorf("The proxy responded to %s with the status code %d", path, status)
+			}
+			return respBody, status, err
+		}
+		select {
+		case <-ctx.Done():
+			return respBody, status, ctx.Err()
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+}
+
+func (m *Client) send(httpClient *http.Client, req *http.Request) ([]byte, int, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return body, resp.StatusCode, err
+}
+
+// isRetryable returns whether the request failed because the proxy could not be reached (status 0) or was unavailable.
+func isRetryable(status int) bool {
+	return status == 0 || status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// GetServiceParams returns the query parameters of the reconfigure request of the service.
+// The names of the parameters are derived from the fields through proxy.GetParamName, the same way as in the OpenAPI document of the proxy.
+// Fields with zero values are not sent so that the defaults of the proxy apply.
+func GetServiceParams(s proxy.Service) (url.Values, error) {
+	params := url.Values{}
+	v := reflect.ValueOf(s)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := proxy.GetParamName(field)
+		if len(name) == 0 {
+			continue
+		}
+		switch value := v.Field(i).Interface().(type) {
+		case string:
+			if len(value) > 0 {
+				params.Set(name, value)
+			}
+		case int:
+			if value != 0 {
+				params.Set(name, strconv.Itoa(value))
+			}
+		case bool:
+			if value {
+				// LoggingDisabled is sent as the inverted loggingEnabled parameter
+				params.Set(name, strconv.FormatBool(field.Name != "LoggingDisabled"))
+			}
+		case []string:
+			if len(value) > 0 {
+				params.Set(name, strings.Join(value, ","))
+			}
+		case []proxy.SplitGroup:
+			groups := []string{}
+			for _, group := range value {
+				groups = append(groups, group.Name+":"+group.Host)
+			}
+			if len(groups) > 0 {
+				params.Set(name, strings.Join(groups, ","))
+			}
+		case []proxy.User:
+			if err := setUsersParams(params, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for domain, cert := range s.ServiceCerts {
+		params.Set("serviceCert."+domain, cert)
+	}
+	for domain, priority := range s.DomainAclPriority {
+		params.Set("aclPriority."+domain, strconv.Itoa(priority))
+	}
+	if err := setServiceDestParams(params, s.ServiceDest); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+func setUsersParams(params url.Values, users []proxy.User) error {
+	credentials := []string{}
+	for _, user := range users {
+		if user.PassEncrypted != users[0].PassEncrypted {
+			return fmt.Errorf("The passwords of all the users must be either encrypted or not")
+		}
+		credentials = append(credentials, user.Username+":"+user.Password)
+	}
+	if len(credentials) > 0 {
+		params.Set("users", strings.Join(credentials, ","))
+		if users[0].PassEncrypted {
+			params.Set("usersPassEncrypted", "true")
+		}
+	}
+	return nil
+}
+
+// setServiceDestParams sets the parameters of the first destination without an index and those of the others as port.1, servicePath.1, and so on.
+func setServiceDestParams(params url.Values, dests []proxy.ServiceDest) error {
+	if len(dests) > 11 {
+		return fmt.Errorf("The proxy accepts up to 11 destinations of a service")
+	}
+	for i, sd := range dests {
+		suffix := ""
+		if i > 0 {
+			suffix = fmt.Sprintf(".%d", i)
+			if len(sd.Port) == 0 || len(sd.ServicePath) == 0 {
+				return fmt.Errorf("Destination %d must have both the port and the service path", i)
+			}
+			if len(sd.TimeoutServer) > 0 {
+				params.Set("timeoutServer"+suffix, sd.TimeoutServer)
+			}
+			if len(sd.TimeoutTunnel) > 0 {
+				params.Set("timeoutTunnel"+suffix, sd.TimeoutTunnel)
+			}
+		}
+		if len(sd.Port) > 0 {
+			params.Set("port"+suffix, sd.Port)
+		}
+		if len(sd.ServicePath) > 0 {
+			params.Set("servicePath"+suffix, strings.Join(sd.ServicePath, ","))
+		}
+		if len(sd.Alpn) > 0 {
+			params.Set("alpn"+suffix, strings.Join(sd.Alpn, ","))
+		}
+		if len(sd.DbRole) > 0 {
+			params.Set("dbRole"+suffix, sd.DbRole)
+		}
+		if len(sd.FrontendTemplatePath) > 0 {
+			params.Set("frontendTemplatePath"+suffix, sd.FrontendTemplatePath)
+		}
+		if sd.SrcPort > 0 {
+			params.Set("srcPort"+suffix, strconv.Itoa(sd.SrcPort))
+		}
+	}
+	return nil
+}
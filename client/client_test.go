@@ -0,0 +1,182 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"../proxy"
+	"../server"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ClientTestSuite struct {
+	suite.Suite
+	requests []*http.Request
+	bodies   []string
+	statuses []int
+	server   *httptest.Server
+}
+
+func TestClientUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
+
+func (s *ClientTestSuite) SetupTest() {
+	s.requests = []*http.Request{}
+	s.bodies = []string{}
+	s.statuses = []int{}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		s.requests = append(s.requests, req)
+		s.bodies = append(s.bodies, string(body))
+		status := http.StatusOK
+		if len(s.statuses) >= len(s.requests) {
+			status = s.statuses[len(s.requests)-1]
+		}
+		w.WriteHeader(status)
+		resp := server.Response{Status: "OK", ServiceName: req.URL.Query().Get("serviceName")}
+		if status >= 300 {
+			resp.Status, resp.Message = "NOK", "Something went wrong"
+		}
+		js, _ := json.Marshal(resp)
+		w.Write(js)
+	}))
+}
+
+func (s *ClientTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// Reconfigure
+
+func (s *ClientTestSuite) Test_Reconfigure_SendsServiceParams() {
+	c := s.getClient()
+	c.Token = "my-token"
+
+	actual, err := c.Reconfigure(context.Background(), proxy.Service{
+		ServiceName: "go-demo",
+		ServiceDest: []proxy.ServiceDest{{Port: "8080", ServicePath: []string{"/demo"}}},
+	})
+
+	s.NoError(err)
+	s.Equal("go-demo", actual.ServiceName)
+	s.Len(s.requests, 1)
+	s.Equal("/v1/docker-flow-proxy/reconfigure", s.requests[0].URL.Path)
+	s.Equal("go-demo", s.requests[0].URL.Query().Get("serviceName"))
+	s.Equal("8080", s.requests[0].URL.Query().Get("port"))
+	s.Equal("Bearer my-token", s.requests[0].Header.Get("Authorization"))
+}
+
+func (s *ClientTestSuite) Test_Reconfigure_RetriesRequests_WhenProxyIsUnavailable() {
+	s.statuses = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+
+	_, err := s.getClient().Reconfigure(context.Background(), proxy.Service{ServiceName: "go-demo"})
+
+	s.NoError(err)
+	s.Len(s.requests, 3)
+}
+
+func (s *ClientTestSuite) Test_Reconfigure_ReturnsResponseAndError_WhenProxyRejectsService() {
+	s.statuses = []int{http.StatusBadRequest}
+
+	actual, err := s.getClient().Reconfigure(context.Background(), proxy.Service{ServiceName: "go-demo"})
+
+	s.Error(err)
+	s.Contains(err.Error(), "Something went wrong")
+	s.Equal("NOK", actual.Status)
+	s.Len(s.requests, 1)
+}
+
+func (s *ClientTestSuite) Test_Reconfigure_ReturnsError_WhenRetriesAreExhausted() {
+	s.statuses = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+
+	_, err := s.getClient().Reconfigure(context.Background(), proxy.Service{ServiceName: "go-demo"})
+
+	s.Error(err)
+	s.Len(s.requests, 4)
+}
+
+// Remove
+
+func (s *ClientTestSuite) Test_Remove_SendsOptions() {
+	_, err := s.getClient().Remove(context.Background(), "go-demo", RemoveOptions{Namespace: "team-a", KeepState: true})
+
+	s.NoError(err)
+	s.Equal("/v1/docker-flow-proxy/remove", s.requests[0].URL.Path)
+	s.Equal(url.Values{"serviceName": {"go-demo"}, "namespace": {"team-a"}, "keepState": {"true"}}, s.requests[0].URL.Query())
+}
+
+// PutCert
+
+func (s *ClientTestSuite) Test_PutCert_SendsCertInBody() {
+	err := s.getClient().PutCert(context.Background(), "acme.pem", []byte("my-cert"), false)
+
+	s.NoError(err)
+	s.Equal("PUT", s.requests[0].Method)
+	s.Equal("acme.pem", s.requests[0].URL.Query().Get("certName"))
+	s.Equal("my-cert", s.bodies[0])
+}
+
+// GetServiceParams
+
+func (s *ClientTestSuite) Test_GetServiceParams_ReturnsParamsOfFields() {
+	actual, err := GetServiceParams(proxy.Service{
+		ServiceName:       "go-demo",
+		ServiceDomain:     []string{"acme.com", "www.acme.com"},
+		HttpsOnly:         true,
+		AclPriority:       5,
+		DomainAclPriority: map[string]int{"www.acme.com": 10},
+		LoggingDisabled:   true,
+		Users:             []proxy.User{{Username: "admin", Password: "secret"}},
+		SplitGroups:       []proxy.SplitGroup{{Name: "a", Host: "go-demo-a"}},
+		ServiceCerts:      map[string]string{"acme.com": "my-cert"},
+		Tasks:             []proxy.Task{{Name: "go-demo.1"}},
+		ServiceDest: []proxy.ServiceDest{
+			{Port: "8080", ServicePath: []string{"/demo", "/api"}, SrcPort: 80},
+			{Port: "8081", ServicePath: []string{"/admin"}, TimeoutServer: "60"},
+		},
+	})
+
+	s.NoError(err)
+	s.Equal(url.Values{
+		"serviceName":              {"go-demo"},
+		"serviceDomain":            {"acme.com,www.acme.com"},
+		"httpsOnly":                {"true"},
+		"aclPriority":              {"5"},
+		"aclPriority.www.acme.com": {"10"},
+		"loggingEnabled":           {"false"},
+		"users":                    {"admin:secret"},
+		"splitGroups":              {"a:go-demo-a"},
+		"serviceCert.acme.com":     {"my-cert"},
+		"port":                     {"8080"},
+		"servicePath":              {"/demo,/api"},
+		"srcPort":                  {"80"},
+		"port.1":                   {"8081"},
+		"servicePath.1":            {"/admin"},
+		"timeoutServer.1":          {"60"},
+	}, actual)
+}
+
+func (s *ClientTestSuite) Test_GetServiceParams_ReturnsError_WhenOnlySomeUsersHaveEncryptedPasswords() {
+	_, err := GetServiceParams(proxy.Service{
+		ServiceName: "go-demo",
+		Users:       []proxy.User{{Username: "admin", Password: "secret"}, {Username: "bob", Password: "x", PassEncrypted: true}},
+	})
+
+	s.Error(err)
+}
+
+func (s *ClientTestSuite) getClient() *Client {
+	c := New(s.server.URL)
+	c.RetryInterval = time.Millisecond
+	return c
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"./registry"
+)
+
+var watchConsulTemplates = registry.WatchConsulTemplates
+
+// startConsulWatch renders the Consul templates again whenever Consul changes if they are rendered natively (CONSUL_TEMPLATE_RENDERER=native).
+func (m *Serve) startConsulWatch() {
+	if !registry.IsNativeRenderer() || len(m.ConsulAddresses) == 0 {
+		return
+	}
+	logPrintf("Watching Consul for changes")
+	go watchConsulTemplates(m.ConsulAddresses, m.reloadConsulTemplates, func(err error) {
+		logWarnf("%s", err.Error())
+	})
+}
+
+// reloadConsulTemplates reloads the proxy with the configurations rendered by the watcher.
+func (m *Serve) reloadConsulTemplates() {
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	if err := reload.Execute(true, ""); err != nil {
+		logWarnf("%s", err.Error())
+	}
+}
@@ -0,0 +1,66 @@
+// +build !integration
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ConsulWatchTestSuite struct {
+	suite.Suite
+}
+
+func (s *ConsulWatchTestSuite) Test_StartConsulWatch_WatchesConsul_WhenRendererIsNative() {
+	defer os.Unsetenv("CONSUL_TEMPLATE_RENDERER")
+	os.Setenv("CONSUL_TEMPLATE_RENDERER", "native")
+	watched := make(chan []string)
+	watchConsulTemplates = func(addresses []string, changed func(), failed func(error)) {
+		watched <- addresses
+	}
+	srv := Serve{}
+	srv.ConsulAddresses = []string{"my-consul"}
+
+	srv.startConsulWatch()
+
+	s.Equal([]string{"my-consul"}, <-watched)
+}
+
+func (s *ConsulWatchTestSuite) Test_StartConsulWatch_DoesNotWatchConsul_WhenRendererIsNotNative() {
+	watched := false
+	watchConsulTemplates = func(addresses []string, changed func(), failed func(error)) {
+		watched = true
+	}
+	srv := Serve{}
+	srv.ConsulAddresses = []string{"my-consul"}
+
+	srv.startConsulWatch()
+
+	s.False(watched)
+}
+
+func (s *ConsulWatchTestSuite) Test_ReloadConsulTemplates_RecreatesConfig() {
+	reloadOrig := reload
+	defer func() { reload = reloadOrig }()
+	actual := []bool{}
+	reload = ReloadMock{ExecuteMock: func(recreate bool, listenerAddr string) error {
+		actual = append(actual, recreate)
+		return nil
+	}}
+	srv := Serve{}
+
+	srv.reloadConsulTemplates()
+
+	s.Equal([]bool{true}, actual)
+}
+
+func TestConsulWatchUnitTestSuite(t *testing.T) {
+	watchConsulTemplatesOrig := watchConsulTemplates
+	defer func() { watchConsulTemplates = watchConsulTemplatesOrig }()
+	logPrintfOrig := logPrintf
+	defer func() { logPrintf = logPrintfOrig }()
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(ConsulWatchTestSuite))
+}
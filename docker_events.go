@@ -0,0 +1,205 @@
+package main
+
+import (
+	"./actions"
+	"./proxy"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// The path of the socket of the local Docker engine the events are read from
+var dockerEventsSocketPath = "/var/run/docker.sock"
+
+// The tasks of a service are polled after its events until they stay the same for dockerEventsStablePolls polls
+// since the events of the containers started on the other nodes are not available to the proxy.
+// The polling stops after dockerEventsMaxPolls polls even if the tasks keep changing.
+var dockerEventsStablePolls = 3
+var dockerEventsMaxPolls = 30
+
+// taskPolls counts the polls of the tasks of a service after its last event
+type taskPolls struct {
+	count  int
+	stable int
+}
+
+type dockerEvent struct {
+	Type   string
+	Action string
+	Actor  struct {
+		Attributes map[string]string
+	}
+}
+
+// openDockerEvents opens the stream of the events of the Swarm services and the containers of the local Docker engine
+var openDockerEvents = func() (io.ReadCloser, error) {
+	client := http.Client{
+		Transport: &http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return net.Dial("unix", dockerEventsSocketPath)
+			},
+		},
+	}
+	filters := `{"type":["service","container"]}`
+	resp, err := client.Get("http://docker/events?filters=" + url.QueryEscape(filters))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Docker API responded to /events with the status code %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// watchDockerEvents reconfigures the zone aware services when their tasks are started or stopped or when the services are updated
+// so that the servers of their backends follow the scaling of the services without waiting for reconfigure requests.
+// The stream is reopened after the retry interval when it fails.
+func (m *Serve) watchDockerEvents(debounce, retryInterval time.Duration) {
+	for {
+		if err := m.readDockerEvents(debounce); err != nil {
+			logWarnf("Could not read the Docker events\n%s", err.Error())
+		}
+		time.Sleep(retryInterval)
+	}
+}
+
+// readDockerEvents reads the events until the stream fails.
+// The events that arrive within the debounce period after the first one are handled together
+// so that scaling a service to many replicas reconfigures it once.
+// Afterwards, the tasks are polled every debounce period until they converge.
+func (m *Serve) readDockerEvents(debounce time.Duration) error {
+	stream, err := openDockerEvents()
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	names := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		decoder := json.NewDecoder(stream)
+		for {
+			event := dockerEvent{}
+			if err := decoder.Decode(&event); err != nil {
+				errs <- err
+				return
+			}
+			if name := getDockerEventService(event); len(name) > 0 {
+				names <- name
+			}
+		}
+	}()
+	changed := map[string]bool{}
+	polls := map[string]*taskPolls{}
+	var timer <-chan time.Time
+	for {
+		select {
+		case name := <-names:
+			changed[name] = true
+			polls[name] = &taskPolls{}
+			if timer == nil {
+				timer = time.After(debounce)
+			}
+		case <-timer:
+			updated := m.refreshServiceTasks(changed)
+			changed = map[string]bool{}
+			timer = nil
+			for name, p := range polls {
+				p.count++
+				if updated[name] {
+					p.stable = 0
+				} else {
+					p.stable++
+				}
+				if p.stable >= dockerEventsStablePolls || p.count >= dockerEventsMaxPolls {
+					delete(polls, name)
+					continue
+				}
+				changed[name] = true
+			}
+			if len(changed) > 0 {
+				timer = time.After(debounce)
+			}
+		case err := <-errs:
+			if len(changed) > 0 {
+				m.refreshServiceTasks(changed)
+			}
+			return err
+		}
+	}
+}
+
+// getDockerEventService returns the name of the Swarm service whose tasks might have changed because of the event.
+func getDockerEventService(event dockerEvent) string {
+	switch {
+	case event.Type == "service" && event.Action == "update":
+		return event.Actor.Attributes["name"]
+	case event.Type == "container" && (event.Action == "start" || event.Action == "die"):
+		return event.Actor.Attributes["com.docker.swarm.service.name"]
+	}
+	return ""
+}
+
+// refreshServiceTasks regenerates the servers of the zone aware services whose hosts are one of the Swarm services
+// from the running tasks. The proxy is reloaded once, and only if the tasks of any of the services changed.
+// It returns the Swarm services whose tasks changed.
+func (m *Serve) refreshServiceTasks(swarmServices map[string]bool) map[string]bool {
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	services := proxy.Instance.GetServices()
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	updated := map[string]bool{}
+	changed := []proxy.Service{}
+	for _, name := range names {
+		sr := services[name]
+		if !sr.ZoneAware || !m.hasAnyHost(sr, swarmServices) {
+			continue
+		}
+		action := actions.NewReconfigure(m.BaseReconfigure, sr, m.Mode)
+		if err := action.Import(context.Background()); err != nil {
+			logWarnf("Could not reconfigure the service %s with the changed tasks\n%s", name, err.Error())
+			continue
+		}
+		if current, ok := proxy.Instance.GetServices()[name]; ok && !reflect.DeepEqual(current.Tasks, sr.Tasks) {
+			logPrintf("The tasks of the service %s changed. The service was reconfigured.", name)
+			changed = append(changed, current)
+			for _, host := range sr.GetHosts() {
+				if swarmServices[host] {
+					updated[host] = true
+				}
+			}
+		}
+	}
+	if len(changed) > 0 {
+		reloader := reload
+		for _, sr := range changed {
+			if sr.Critical {
+				reloader = urgentReload
+			}
+		}
+		if err := reloader.Execute(false, ""); err != nil {
+			logWarnf("Could not reload the proxy with the changed tasks\n%s", err.Error())
+		}
+	}
+	return updated
+}
+
+func (m *Serve) hasAnyHost(sr proxy.Service, hosts map[string]bool) bool {
+	for _, host := range sr.GetHosts() {
+		if hosts[host] {
+			return true
+		}
+	}
+	return false
+}
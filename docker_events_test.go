@@ -0,0 +1,161 @@
+// +build !integration
+
+package main
+
+import (
+	"./actions"
+	"./proxy"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DockerEventsTestSuite struct {
+	suite.Suite
+	reconfigured []string
+	reloads      int
+	services     map[string]proxy.Service
+}
+
+func (s *DockerEventsTestSuite) SetupTest() {
+	s.reconfigured = []string{}
+	s.reloads = 0
+	s.services = map[string]proxy.Service{
+		"go-demo":     {ServiceName: "go-demo", ZoneAware: true},
+		"go-demo-api": {ServiceName: "go-demo-api", OutboundHostname: "go-demo-api-v2", ZoneAware: true},
+		"other":       {ServiceName: "other"},
+	}
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(s.services)
+	proxy.Instance = proxyMock
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		s.reconfigured = append(s.reconfigured, serviceData.ServiceName)
+		return getReconfigureMock("")
+	}
+	reload = ReloadMock{ExecuteMock: func(recreate bool, listenerAddr string) error {
+		s.reloads++
+		return nil
+	}}
+	dockerEventsStablePolls = 3
+	dockerEventsMaxPolls = 30
+}
+
+func (s *DockerEventsTestSuite) Test_ReadDockerEvents_ReconfiguresZoneAwareServicesWhoseTasksChanged() {
+	openDockerEvents = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(`{"Type":"container","Action":"start","Actor":{"Attributes":{"com.docker.swarm.service.name":"go-demo"}}}
+{"Type":"container","Action":"die","Actor":{"Attributes":{"com.docker.swarm.service.name":"go-demo"}}}
+{"Type":"service","Action":"update","Actor":{"Attributes":{"name":"go-demo-api-v2"}}}
+{"Type":"service","Action":"update","Actor":{"Attributes":{"name":"other"}}}
+{"Type":"container","Action":"exec_start","Actor":{"Attributes":{"com.docker.swarm.service.name":"go-demo-api-v2"}}}
+`)), nil
+	}
+	srv := Serve{}
+
+	err := srv.readDockerEvents(time.Hour)
+
+	s.Equal(io.EOF, err)
+	s.Equal([]string{"go-demo", "go-demo-api"}, s.reconfigured)
+	s.Equal(0, s.reloads)
+}
+
+func (s *DockerEventsTestSuite) Test_ReadDockerEvents_PollsTasksUntilTheyConverge() {
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		s.reconfigured = append(s.reconfigured, serviceData.ServiceName)
+		// The tasks started on the other nodes are resolved by the first two polls
+		if len(s.reconfigured) <= 2 {
+			sr := s.services[serviceData.ServiceName]
+			sr.Tasks = append(sr.Tasks, proxy.Task{Name: fmt.Sprintf("task-%d", len(s.reconfigured))})
+			s.services[serviceData.ServiceName] = sr
+		}
+		return getReconfigureMock("")
+	}
+	s.Equal([]string{"go-demo", "go-demo", "go-demo", "go-demo", "go-demo"}, s.readServiceUpdate())
+	s.Equal(2, s.reloads)
+}
+
+func (s *DockerEventsTestSuite) Test_ReadDockerEvents_StopsPolling_WhenMaxPollsAreReached() {
+	dockerEventsMaxPolls = 4
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		s.reconfigured = append(s.reconfigured, serviceData.ServiceName)
+		sr := s.services[serviceData.ServiceName]
+		sr.Tasks = append(sr.Tasks, proxy.Task{Name: fmt.Sprintf("task-%d", len(s.reconfigured))})
+		s.services[serviceData.ServiceName] = sr
+		return getReconfigureMock("")
+	}
+	s.Equal([]string{"go-demo", "go-demo", "go-demo", "go-demo"}, s.readServiceUpdate())
+	s.Equal(4, s.reloads)
+}
+
+func (s *DockerEventsTestSuite) Test_ReadDockerEvents_ReconfiguresServicesOnce_WhenDebouncePeriodExpires() {
+	dockerEventsStablePolls = 1
+	reader, writer := io.Pipe()
+	openDockerEvents = func() (io.ReadCloser, error) {
+		return reader, nil
+	}
+	srv := Serve{}
+	done := make(chan error)
+	go func() {
+		done <- srv.readDockerEvents(time.Millisecond * 10)
+	}()
+
+	for i := 0; i < 3; i++ {
+		writer.Write([]byte(`{"Type":"container","Action":"start","Actor":{"Attributes":{"com.docker.swarm.service.name":"go-demo"}}}` + "\n"))
+	}
+	time.Sleep(time.Millisecond * 50)
+	writer.Close()
+
+	s.Equal(io.EOF, <-done)
+	s.Equal([]string{"go-demo"}, s.reconfigured)
+}
+
+func (s *DockerEventsTestSuite) Test_ReadDockerEvents_ReturnsError_WhenStreamCannotBeOpened() {
+	openDockerEvents = func() (io.ReadCloser, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+	srv := Serve{}
+
+	err := srv.readDockerEvents(time.Hour)
+
+	s.Error(err)
+	s.Empty(s.reconfigured)
+}
+
+// readServiceUpdate sends the update event of the go-demo service and returns the reconfigured services once the polling stops
+func (s *DockerEventsTestSuite) readServiceUpdate() []string {
+	reader, writer := io.Pipe()
+	openDockerEvents = func() (io.ReadCloser, error) {
+		return reader, nil
+	}
+	srv := Serve{}
+	done := make(chan error)
+	go func() {
+		done <- srv.readDockerEvents(time.Millisecond * 5)
+	}()
+
+	writer.Write([]byte(`{"Type":"service","Action":"update","Actor":{"Attributes":{"name":"go-demo"}}}` + "\n"))
+	time.Sleep(time.Millisecond * 200)
+	writer.Close()
+
+	s.Equal(io.EOF, <-done)
+	return s.reconfigured
+}
+
+func TestDockerEventsUnitTestSuite(t *testing.T) {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	openDockerEventsOrig := openDockerEvents
+	defer func() { openDockerEvents = openDockerEventsOrig }()
+	reloadOrig := reload
+	defer func() { reload = reloadOrig }()
+	logPrintfOrig := logPrintf
+	defer func() { logPrintf = logPrintfOrig }()
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(DockerEventsTestSuite))
+}
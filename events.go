@@ -0,0 +1,100 @@
+package main
+
+import (
+	"./proxy"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The time between the comments sent to event streams so that idle connections are not closed by intermediaries.
+var eventsKeepAliveInterval = 30 * time.Second
+
+// The statuses of the backends seen by the last health check keyed by the names of the backends
+var backendStatuses = map[string]string{}
+
+// events streams the reconfigure, remove, reload, and health events as server-sent events when the client accepts
+// text/event-stream and as JSON lines otherwise. The stream ends when the client disconnects or the proxy shuts down.
+// A namespace token limits the events to those of the services of its namespace and to those not bound to a service.
+func (m *Serve) events(w http.ResponseWriter, req *http.Request) {
+	if !m.authorizeNamespace(w, req) {
+		return
+	}
+	namespace := req.URL.Query().Get("namespace")
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sse := strings.Contains(req.Header.Get("Accept"), "text/event-stream")
+	if sse {
+		httpWriterSetContentType(w, "text/event-stream")
+	} else {
+		httpWriterSetContentType(w, "application/x-ndjson")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	events, unsubscribe := proxy.SubscribeEvents()
+	defer unsubscribe()
+	keepAlive := time.NewTicker(eventsKeepAliveInterval)
+	defer keepAlive.Stop()
+	for !m.isShuttingDown() {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-keepAlive.C:
+			if sse {
+				fmt.Fprint(w, ": keep-alive\n\n")
+			} else {
+				fmt.Fprint(w, "\n")
+			}
+		case event := <-events:
+			if len(event.ServiceName) > 0 && !isInNamespace(namespace, event.ServiceName) {
+				continue
+			}
+			js, _ := json.Marshal(event)
+			if sse {
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, js)
+			} else {
+				fmt.Fprintf(w, "%s\n", js)
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// watchBackendsHealth publishes health events whenever the status of a backend changes.
+// The backends are checked only while someone is subscribed to the events.
+func (m *Serve) watchBackendsHealth(interval time.Duration) {
+	for range time.Tick(interval) {
+		m.publishHealthEvents()
+	}
+}
+
+func (m *Serve) publishHealthEvents() {
+	if !proxy.HasEventSubscribers() {
+		backendStatuses = map[string]string{}
+		return
+	}
+	stats, err := getAllBackendStats()
+	if err != nil {
+		logWarnf("Could not check the health of the backends\n%s", err.Error())
+		return
+	}
+	statuses := map[string]string{}
+	for _, backend := range stats {
+		statuses[backend.Name] = backend.Status
+		if previous, found := backendStatuses[backend.Name]; found && previous != backend.Status {
+			proxy.PublishEvent(proxy.Event{
+				Type:        proxy.EventHealth,
+				ServiceName: backend.Name,
+				Status:      backend.Status,
+				Message:     previous,
+			})
+		}
+	}
+	backendStatuses = statuses
+}
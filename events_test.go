@@ -0,0 +1,136 @@
+// +build !integration
+
+package main
+
+import (
+	"./proxy"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EventsTestSuite struct {
+	suite.Suite
+}
+
+func (s *EventsTestSuite) SetupTest() {
+	backendStatuses = map[string]string{}
+}
+
+// events
+
+func (s *EventsTestSuite) Test_Events_StreamsServerSentEvents() {
+	actual := s.stream("text/event-stream", proxy.Event{Type: proxy.EventRemove, ServiceName: "my-service", Time: time.Unix(0, 0).UTC()})
+
+	s.Equal("text/event-stream", actual.Header().Get("Content-Type"))
+	s.Equal("event: remove\ndata: {\"Time\":\"1970-01-01T00:00:00Z\",\"Type\":\"remove\",\"ServiceName\":\"my-service\"}\n\n", actual.Body.String())
+}
+
+func (s *EventsTestSuite) Test_Events_StreamsJsonLines_WhenClientDoesNotAcceptEventStream() {
+	actual := s.stream("", proxy.Event{Type: proxy.EventReload, Status: "OK", Time: time.Unix(0, 0).UTC()})
+
+	s.Equal("application/x-ndjson", actual.Header().Get("Content-Type"))
+	s.Equal("{\"Time\":\"1970-01-01T00:00:00Z\",\"Type\":\"reload\",\"Status\":\"OK\"}\n", actual.Body.String())
+}
+
+func (s *EventsTestSuite) Test_Events_StreamsEventsOfNamespaceOfToken() {
+	defer func() { os.Unsetenv("NAMESPACE_TOKENS") }()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a,team-b:token-b")
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/events", nil)
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer token-a")
+	rw := httptest.NewRecorder()
+	done := make(chan bool)
+	srv := Serve{}
+	go func() {
+		srv.ServeHTTP(rw, req)
+		done <- true
+	}()
+	for !proxy.HasEventSubscribers() {
+		time.Sleep(time.Millisecond)
+	}
+	proxy.PublishEvent(proxy.Event{Type: proxy.EventRemove, ServiceName: "team-b.my-service", Time: time.Unix(0, 0).UTC()})
+	proxy.PublishEvent(proxy.Event{Type: proxy.EventRemove, ServiceName: "team-a.my-service", Time: time.Unix(0, 0).UTC()})
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	s.Equal("{\"Time\":\"1970-01-01T00:00:00Z\",\"Type\":\"remove\",\"ServiceName\":\"team-a.my-service\"}\n", rw.Body.String())
+}
+
+// publishHealthEvents
+
+func (s *EventsTestSuite) Test_PublishHealthEvents_PublishesChangedStatuses() {
+	events, unsubscribe := proxy.SubscribeEvents()
+	defer unsubscribe()
+	statuses := []string{"UP", "UP", "DOWN"}
+	getAllBackendStats = func() ([]proxy.BackendStats, error) {
+		status := statuses[0]
+		statuses = statuses[1:]
+		return []proxy.BackendStats{{Name: "my-service-be8080", Status: status}}, nil
+	}
+	srv := Serve{}
+
+	srv.publishHealthEvents()
+	srv.publishHealthEvents()
+	srv.publishHealthEvents()
+
+	s.Len(events, 1)
+	event := <-events
+	s.Equal(proxy.EventHealth, event.Type)
+	s.Equal("my-service-be8080", event.ServiceName)
+	s.Equal("DOWN", event.Status)
+	s.Equal("UP", event.Message)
+}
+
+func (s *EventsTestSuite) Test_PublishHealthEvents_DoesNotCheckBackends_WhenNobodyIsSubscribed() {
+	checked := false
+	getAllBackendStats = func() ([]proxy.BackendStats, error) {
+		checked = true
+		return nil, fmt.Errorf("This is an error")
+	}
+	srv := Serve{}
+
+	srv.publishHealthEvents()
+
+	s.False(checked)
+}
+
+// stream sends the event to the events endpoint and returns the response once the client disconnects.
+func (s *EventsTestSuite) stream(accept string, event proxy.Event) *httptest.ResponseRecorder {
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/events", nil)
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", accept)
+	rw := httptest.NewRecorder()
+	done := make(chan bool)
+	srv := Serve{}
+	go func() {
+		srv.ServeHTTP(rw, req)
+		done <- true
+	}()
+	for !proxy.HasEventSubscribers() {
+		time.Sleep(time.Millisecond)
+	}
+	proxy.PublishEvent(event)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+	return rw
+}
+
+func TestEventsUnitTestSuite(t *testing.T) {
+	getAllBackendStatsOrig := getAllBackendStats
+	defer func() { getAllBackendStats = getAllBackendStatsOrig }()
+	logPrintfOrig := logPrintf
+	defer func() { logPrintf = logPrintfOrig }()
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(EventsTestSuite))
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"./api"
+	"./client"
+	"./proxy"
+	"./server"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The prefix of the paths of the calls of the Admin service defined in api/admin.proto
+const grpcAdminPrefix = "/dockerflowproxy.v1.Admin/"
+
+// The gRPC status codes returned by the admin API
+const (
+	grpcOK                 = 0
+	grpcInvalidArgument    = 3
+	grpcDeadlineExceeded   = 4
+	grpcNotFound           = 5
+	grpcAlreadyExists      = 6
+	grpcPermissionDenied   = 7
+	grpcResourceExhausted  = 8
+	grpcFailedPrecondition = 9
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnavailable        = 14
+	grpcUnauthenticated    = 16
+)
+
+var httpListenAndServeTLS = http.ListenAndServeTLS
+
+// The maximum size of the messages of the calls. It matches the default of the gRPC servers.
+const grpcMaxMessageSize = 4 << 20
+
+var errGrpcCompressed = fmt.Errorf("Compressed messages are not supported")
+var errGrpcTooLarge = fmt.Errorf("The message is larger than %d bytes", grpcMaxMessageSize)
+
+// startGrpc serves the admin API over gRPC on GRPC_ADDRESS. The calls are served by the same handlers as the HTTP API.
+// gRPC requires HTTP/2, which Go serves only over TLS, so the server uses the certificate and the key in GRPC_CERT_PATH.
+func (m *Serve) startGrpc() {
+	address := proxy.GetSecretOrEnvVar("GRPC_ADDRESS", "")
+	if len(address) == 0 {
+		return
+	}
+	certPath := proxy.GetSecretOrEnvVar("GRPC_CERT_PATH", "")
+	if len(certPath) == 0 {
+		logWarnf("The gRPC API is not started since GRPC_CERT_PATH is not set")
+		return
+	}
+	go func() {
+		logPrintf("Starting the gRPC API on %s", address)
+		if err := httpListenAndServeTLS(address, certPath, certPath, http.HandlerFunc(m.grpc)); err != nil {
+			logWarnf("The gRPC API stopped\n%s", err.Error())
+		}
+	}()
+}
+
+// grpc serves the calls of the Admin service. The responses are sent with the status in the grpc-status trailer.
+// The token of the call is verified before its message is read.
+func (m *Serve) grpc(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	if !m.authorizeGrpcCall(w, req) {
+		return
+	}
+	data, err := readGrpcMessage(req.Body)
+	if err == errGrpcCompressed {
+		writeGrpcStatus(w, grpcUnimplemented, err.Error())
+		return
+	} else if err == errGrpcTooLarge {
+		writeGrpcStatus(w, grpcResourceExhausted, err.Error())
+		return
+	} else if err != nil {
+		writeGrpcStatus(w, grpcInvalidArgument, err.Error())
+		return
+	}
+	switch req.URL.Path {
+	case grpcAdminPrefix + "Reconfigure":
+		request := api.ReconfigureRequest{}
+		if err := api.Unmarshal(data, &request); err != nil {
+			writeGrpcStatus(w, grpcInvalidArgument, err.Error())
+			return
+		}
+		params, err := client.GetServiceParams(request.Service)
+		if err != nil {
+			writeGrpcStatus(w, grpcInvalidArgument, err.Error())
+			return
+		}
+		if len(request.Version) > 0 {
+			params.Set("version", request.Version)
+		}
+		m.writeGrpcResponse(w, m.serveGrpcCall(req, "/v1/docker-flow-proxy/reconfigure", params))
+	case grpcAdminPrefix + "Remove":
+		request := api.RemoveRequest{}
+		if err := api.Unmarshal(data, &request); err != nil {
+			writeGrpcStatus(w, grpcInvalidArgument, err.Error())
+			return
+		}
+		params := url.Values{}
+		for name, value := range map[string]string{
+			"serviceName": request.ServiceName,
+			"aclName":     request.AclName,
+			"namespace":   request.Namespace,
+		} {
+			if len(value) > 0 {
+				params.Set(name, value)
+			}
+		}
+		for name, value := range map[string]bool{
+			"distribute":  request.Distribute,
+			"drainFirst":  request.DrainFirst,
+			"keepState":   request.KeepState,
+			"removeCerts": request.RemoveCerts,
+		} {
+			if value {
+				params.Set(name, "true")
+			}
+		}
+		m.writeGrpcResponse(w, m.serveGrpcCall(req, "/v1/docker-flow-proxy/remove", params))
+	case grpcAdminPrefix + "Status":
+		resp := m.serveGrpcCall(req, "/v1/docker-flow-proxy/status", url.Values{})
+		status := proxy.Status{}
+		if err := json.Unmarshal(resp.body.Bytes(), &status); err != nil {
+			writeGrpcStatus(w, grpcInternal, err.Error())
+			return
+		}
+		writeGrpcMessage(w, api.StatusResponse{
+			ReloadCount:        int64(status.ReloadCount),
+			ReloadFailureCount: int64(status.ReloadFailureCount),
+			LastReloadTime:     formatGrpcTime(status.LastReloadTime),
+			LastReloadDuration: status.LastReloadDuration,
+			LastReloadError:    status.LastReloadError,
+			LastConfigTime:     formatGrpcTime(status.LastConfigTime),
+		})
+		writeGrpcStatus(w, grpcOK, "")
+	case grpcAdminPrefix + "Watch":
+		m.watchServices(w, req)
+	default:
+		writeGrpcStatus(w, grpcUnimplemented, fmt.Sprintf("%s is not a method of the admin API", req.URL.Path))
+	}
+}
+
+// watchServices streams the services sorted by their names, starting with the current ones.
+// A namespace token limits them to its namespace. The services are sent again after each reconfigure and remove event.
+// The stream ends when the client disconnects or the proxy shuts down.
+func (m *Serve) watchServices(w http.ResponseWriter, req *http.Request) {
+	events, unsubscribe := proxy.SubscribeEvents()
+	defer unsubscribe()
+	if !m.writeGrpcServices(w, req) {
+		return
+	}
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-shutdownCh:
+			writeGrpcStatus(w, grpcUnavailable, "The proxy is shutting down")
+			return
+		case event := <-events:
+			if event.Type != proxy.EventReconfigure && event.Type != proxy.EventRemove {
+				continue
+			}
+			if !m.writeGrpcServices(w, req) {
+				return
+			}
+		}
+	}
+}
+
+// authorizeGrpcCall verifies that the token of the call is a namespace or admin token once namespace tokens are configured.
+// The namespace of the call is authorized once the message is read.
+func (m *Serve) authorizeGrpcCall(w http.ResponseWriter, req *http.Request) bool {
+	resp := &grpcResponse{header: http.Header{}}
+	if m.authorizeNamespace(resp, newGrpcCall(req, req.URL.Path, url.Values{})) {
+		return true
+	}
+	response := server.Response{}
+	json.Unmarshal(resp.body.Bytes(), &response)
+	writeGrpcStatus(w, getGrpcCode(resp.status), response.Message)
+	return false
+}
+
+func (m *Serve) writeGrpcServices(w http.ResponseWriter, req *http.Request) bool {
+	call := newGrpcCall(req, "/v1/docker-flow-proxy/services", url.Values{})
+	resp := &grpcResponse{header: http.Header{}}
+	if !m.authorizeNamespace(resp, call) {
+		m.writeGrpcResponse(w, resp)
+		return false
+	}
+	namespace := call.URL.Query().Get("namespace")
+	configured := proxy.Instance.GetServices()
+	names := []string{}
+	for name, sr := range configured {
+		if len(namespace) == 0 || sr.Namespace == namespace {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	services := api.Services{Services: []proxy.Service{}}
+	for _, name := range names {
+		services.Services = append(services.Services, proxy.RedactService(configured[name]))
+	}
+	if err := writeGrpcMessage(w, services); err != nil {
+		return false
+	}
+	return true
+}
+
+// grpcResponse records the response of the HTTP handler that serves a call or a scheduled action
+type grpcResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *grpcResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *grpcResponse) Write(data []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(data)
+}
+
+func (r *grpcResponse) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// serveGrpcCall serves the call with the HTTP handler of the path.
+func (m *Serve) serveGrpcCall(req *http.Request, path string, params url.Values) *grpcResponse {
+	resp := &grpcResponse{header: http.Header{}}
+	m.ServeHTTP(resp, newGrpcCall(req, path, params))
+	if resp.status == 0 {
+		resp.status = http.StatusOK
+	}
+	return resp
+}
+
+// newGrpcCall returns the HTTP request of the call. The authorization metadata of the call is sent as the Authorization header.
+func newGrpcCall(req *http.Request, path string, params url.Values) *http.Request {
+	call, _ := http.NewRequest("GET", path+"?"+params.Encode(), nil)
+	call = call.WithContext(req.Context())
+	call.RemoteAddr = req.RemoteAddr
+	if authorization := req.Header.Get("Authorization"); len(authorization) > 0 {
+		call.Header.Set("Authorization", authorization)
+	}
+	return call
+}
+
+// writeGrpcResponse sends the JSON response of the HTTP handler as the Response message.
+// The HTTP status is converted to the gRPC status and the message of the response is sent as the grpc-message.
+func (m *Serve) writeGrpcResponse(w http.ResponseWriter, resp *grpcResponse) {
+	response := server.Response{}
+	if err := json.Unmarshal(resp.body.Bytes(), &response); err != nil {
+		writeGrpcStatus(w, grpcInternal, fmt.Sprintf("Could not parse the response\n%s", err.Error()))
+		return
+	}
+	if err := writeGrpcMessage(w, api.Response{
+		Status:      response.Status,
+		Message:     response.Message,
+		ServiceName: response.ServiceName,
+		Warnings:    response.Warnings,
+		Errors:      response.Errors,
+	}); err != nil {
+		return
+	}
+	code, msg := getGrpcCode(resp.status), ""
+	if code != grpcOK {
+		msg = response.Message
+	}
+	writeGrpcStatus(w, code, msg)
+}
+
+func getGrpcCode(status int) int {
+	switch {
+	case status < 300:
+		return grpcOK
+	case status == http.StatusBadRequest:
+		return grpcInvalidArgument
+	case status == http.StatusUnauthorized:
+		return grpcUnauthenticated
+	case status == http.StatusForbidden:
+		return grpcPermissionDenied
+	case status == http.StatusNotFound:
+		return grpcNotFound
+	case status == http.StatusConflict:
+		return grpcAlreadyExists
+	case status == http.StatusPreconditionFailed:
+		return grpcFailedPrecondition
+	case status == http.StatusServiceUnavailable:
+		return grpcUnavailable
+	case status == http.StatusGatewayTimeout:
+		return grpcDeadlineExceeded
+	}
+	return grpcInternal
+}
+
+// readGrpcMessage reads a length-prefixed message of the request.
+// Compressed messages and messages larger than grpcMaxMessageSize are not supported.
+func readGrpcMessage(r io.Reader) ([]byte, error) {
+	prefix := make([]byte, 5)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, fmt.Errorf("Could not read the message\n%s", err.Error())
+	}
+	if prefix[0] != 0 {
+		return nil, errGrpcCompressed
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > grpcMaxMessageSize {
+		return nil, errGrpcTooLarge
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("Could not read the message\n%s", err.Error())
+	}
+	return data, nil
+}
+
+func writeGrpcMessage(w http.ResponseWriter, msg interface{}) error {
+	data, err := api.Marshal(msg)
+	if err != nil {
+		writeGrpcStatus(w, grpcInternal, err.Error())
+		return err
+	}
+	prefix := make([]byte, 5)
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	if _, err := w.Write(append(prefix, data...)); err != nil {
+		return err
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// writeGrpcStatus sets the trailers that end the call. The message is percent-encoded as required by gRPC.
+func writeGrpcStatus(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if len(msg) > 0 {
+		encoded := ""
+		for _, b := range []byte(msg) {
+			if b < 0x20 || b > 0x7e || b == '%' {
+				encoded += fmt.Sprintf("%%%02X", b)
+			} else {
+				encoded += string(b)
+			}
+		}
+		w.Header().Set("Grpc-Message", encoded)
+	}
+}
+
+func formatGrpcTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,321 @@
+// +build !integration
+
+package main
+
+import (
+	"./actions"
+	"./api"
+	"./proxy"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type GrpcTestSuite struct {
+	suite.Suite
+}
+
+func (s *GrpcTestSuite) SetupTest() {
+	logPrintf = func(format string, v ...interface{}) {}
+	logWarnf = func(format string, v ...interface{}) {}
+	serviceVersions = proxy.NewServiceVersions()
+	serviceParams = proxy.NewProfiles()
+	expirations = proxy.NewExpirations()
+	disabledServices = proxy.NewDisabledServices()
+}
+
+func TestGrpcUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(GrpcTestSuite))
+}
+
+// startGrpc
+
+func (s *GrpcTestSuite) Test_StartGrpc_ServesTlsOnGrpcAddress() {
+	defer func() { os.Unsetenv("GRPC_ADDRESS"); os.Unsetenv("GRPC_CERT_PATH") }()
+	os.Setenv("GRPC_ADDRESS", ":8443")
+	os.Setenv("GRPC_CERT_PATH", "/certs/grpc.pem")
+	orig := httpListenAndServeTLS
+	defer func() { httpListenAndServeTLS = orig }()
+	actual := make(chan []string, 1)
+	httpListenAndServeTLS = func(addr, certFile, keyFile string, handler http.Handler) error {
+		actual <- []string{addr, certFile, keyFile}
+		return nil
+	}
+
+	srv := Serve{}
+	srv.startGrpc()
+
+	s.Equal([]string{":8443", "/certs/grpc.pem", "/certs/grpc.pem"}, <-actual)
+}
+
+func (s *GrpcTestSuite) Test_StartGrpc_DoesNotServe_WhenCertPathIsNotSet() {
+	defer func() { os.Unsetenv("GRPC_ADDRESS") }()
+	os.Setenv("GRPC_ADDRESS", ":8443")
+	orig := httpListenAndServeTLS
+	defer func() { httpListenAndServeTLS = orig }()
+	invoked := false
+	httpListenAndServeTLS = func(addr, certFile, keyFile string, handler http.Handler) error {
+		invoked = true
+		return nil
+	}
+
+	srv := Serve{}
+	srv.startGrpc()
+	time.Sleep(10 * time.Millisecond)
+
+	s.False(invoked)
+}
+
+// grpc
+
+func (s *GrpcTestSuite) Test_Grpc_ReconfiguresService() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{})
+	proxy.Instance = proxyMock
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	actual := proxy.Service{}
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		actual = serviceData
+		return mockObj
+	}
+	request := api.ReconfigureRequest{Service: proxy.Service{
+		ServiceName: "my-service",
+		ServiceDest: []proxy.ServiceDest{{Port: "1234", ServicePath: []string{"/api"}}},
+	}}
+	response := api.Response{}
+
+	resp := s.call("Reconfigure", request, context.Background())
+
+	s.Equal("application/grpc", resp.Header.Get("Content-Type"))
+	s.Equal("0", resp.Trailer.Get("Grpc-Status"))
+	s.decode(resp, &response)
+	s.Equal("OK", response.Status)
+	s.Equal("my-service", response.ServiceName)
+	s.Equal("my-service", actual.ServiceName)
+	s.Equal("1234", actual.ServiceDest[0].Port)
+	s.Equal([]string{"/api"}, actual.ServiceDest[0].ServicePath)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+func (s *GrpcTestSuite) Test_Grpc_ReturnsInvalidArgument_WhenServiceIsInvalid() {
+	response := api.Response{}
+
+	resp := s.call("Reconfigure", api.ReconfigureRequest{}, context.Background())
+
+	s.Equal("3", resp.Trailer.Get("Grpc-Status"))
+	s.decode(resp, &response)
+	s.Equal("NOK", response.Status)
+	s.NotEmpty(resp.Trailer.Get("Grpc-Message"))
+}
+
+func (s *GrpcTestSuite) Test_Grpc_RemovesService() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxy.Instance = getProxyMock("")
+	newRemoveOrig := actions.NewRemove
+	defer func() { actions.NewRemove = newRemoveOrig }()
+	actualServiceName, actualAclName := "", ""
+	actualOptions := actions.RemoveOptions{}
+	actions.NewRemove = func(
+		serviceName, aclName, configsPath, templatesPath string,
+		consulAddresses []string,
+		instanceName, mode string,
+		options actions.RemoveOptions,
+	) actions.Removable {
+		actualServiceName, actualAclName, actualOptions = serviceName, aclName, options
+		return getRemoveMock("")
+	}
+	response := api.Response{}
+
+	resp := s.call("Remove", api.RemoveRequest{ServiceName: "my-service", AclName: "my-acl", KeepState: true}, context.Background())
+
+	s.Equal("0", resp.Trailer.Get("Grpc-Status"))
+	s.decode(resp, &response)
+	s.Equal(api.Response{Status: "OK", ServiceName: "my-service"}, response)
+	s.Equal("my-service", actualServiceName)
+	s.Equal("my-acl", actualAclName)
+	s.True(actualOptions.KeepState)
+}
+
+func (s *GrpcTestSuite) Test_Grpc_ReturnsUnauthenticated_WhenNamespaceTokenIsMissing() {
+	defer func() { os.Unsetenv("NAMESPACE_TOKENS") }()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a")
+
+	resp := s.call("Remove", api.RemoveRequest{ServiceName: "my-service", Namespace: "team-a"}, context.Background())
+
+	s.Equal("16", resp.Trailer.Get("Grpc-Status"))
+}
+
+func (s *GrpcTestSuite) Test_Grpc_DoesNotReadMessage_WhenTokenIsInvalid() {
+	defer func() { os.Unsetenv("NAMESPACE_TOKENS") }()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a")
+	body := &grpcTestBody{}
+	req, _ := http.NewRequest("POST", grpcAdminPrefix+"Remove", body)
+	req.Header.Set("Authorization", "Bearer token-b")
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.grpc(rw, req)
+
+	s.Equal("16", rw.Result().Trailer.Get("Grpc-Status"))
+	s.False(body.read)
+}
+
+func (s *GrpcTestSuite) Test_Grpc_ReturnsResourceExhausted_WhenMessageIsTooLarge() {
+	prefix := make([]byte, 5)
+	binary.BigEndian.PutUint32(prefix[1:], grpcMaxMessageSize+1)
+	req, _ := http.NewRequest("POST", grpcAdminPrefix+"Remove", bytes.NewReader(prefix))
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.grpc(rw, req)
+
+	s.Equal("8", rw.Result().Trailer.Get("Grpc-Status"))
+}
+
+func (s *GrpcTestSuite) Test_Grpc_ReturnsStatus() {
+	status := proxy.GetStatus()
+	response := api.StatusResponse{}
+
+	resp := s.call("Status", api.StatusRequest{}, context.Background())
+
+	s.Equal("0", resp.Trailer.Get("Grpc-Status"))
+	s.decode(resp, &response)
+	s.Equal(int64(status.ReloadCount), response.ReloadCount)
+	s.Equal(int64(status.ReloadFailureCount), response.ReloadFailureCount)
+	s.Equal(status.LastReloadError, response.LastReloadError)
+}
+
+func (s *GrpcTestSuite) Test_Grpc_StreamsServices_WhenServicesChange() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{
+		"b-service": {ServiceName: "b-service"},
+		"a-service": {ServiceName: "a-service", ServiceDest: []proxy.ServiceDest{{Port: "1234"}}},
+	})
+	proxy.Instance = proxyMock
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan *http.Response)
+	go func() {
+		done <- s.call("Watch", api.WatchRequest{}, ctx)
+	}()
+	for !proxy.HasEventSubscribers() {
+		time.Sleep(time.Millisecond)
+	}
+	proxy.PublishEvent(proxy.Event{Type: proxy.EventReload, Status: "OK"})
+	proxy.PublishEvent(proxy.Event{Type: proxy.EventReconfigure, ServiceName: "a-service"})
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	resp := <-done
+	expected := api.Services{Services: []proxy.Service{
+		{ServiceName: "a-service", ServiceDest: []proxy.ServiceDest{{Port: "1234"}}},
+		{ServiceName: "b-service"},
+	}}
+
+	for i := 0; i < 2; i++ {
+		actual := api.Services{}
+		s.decode(resp, &actual)
+		s.Equal(expected, actual)
+	}
+	_, err := readGrpcMessage(resp.Body)
+	s.Error(err)
+}
+
+func (s *GrpcTestSuite) Test_Grpc_EndsWatch_WhenProxyShutsDown() {
+	defer func() { shutdownCh = make(chan struct{}) }()
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{})
+	proxy.Instance = proxyMock
+	done := make(chan *http.Response)
+	go func() {
+		done <- s.call("Watch", api.WatchRequest{}, context.Background())
+	}()
+	for !proxy.HasEventSubscribers() {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(shutdownCh)
+
+	select {
+	case resp := <-done:
+		s.Equal("14", resp.Trailer.Get("Grpc-Status"))
+	case <-time.After(time.Second):
+		s.Fail("The watch did not end when the proxy shut down")
+	}
+}
+
+func (s *GrpcTestSuite) Test_Grpc_ReturnsUnimplemented_WhenMethodDoesNotExist() {
+	resp := s.call("Unknown", api.StatusRequest{}, context.Background())
+
+	s.Equal("12", resp.Trailer.Get("Grpc-Status"))
+}
+
+func (s *GrpcTestSuite) Test_Grpc_ReturnsUnimplemented_WhenMessageIsCompressed() {
+	req, _ := http.NewRequest("POST", grpcAdminPrefix+"Status", bytes.NewReader([]byte{1, 0, 0, 0, 0}))
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.grpc(rw, req)
+
+	s.Equal("12", rw.Result().Trailer.Get("Grpc-Status"))
+	s.Equal("Compressed messages are not supported", rw.Result().Trailer.Get("Grpc-Message"))
+}
+
+// writeGrpcStatus
+
+func (s *GrpcTestSuite) Test_WriteGrpcStatus_PercentEncodesMessage() {
+	rw := httptest.NewRecorder()
+
+	writeGrpcStatus(rw, grpcInternal, "100% failed\nnow")
+
+	s.Equal("100%25 failed%0Anow", rw.Header().Get("Grpc-Message"))
+}
+
+// call sends the message to the method of the admin API and returns the response once the call ends.
+func (s *GrpcTestSuite) call(method string, msg interface{}, ctx context.Context) *http.Response {
+	data, err := api.Marshal(msg)
+	s.NoError(err)
+	prefix := make([]byte, 5)
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	req, _ := http.NewRequest("POST", grpcAdminPrefix+method, bytes.NewReader(append(prefix, data...)))
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/grpc")
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.grpc(rw, req)
+
+	return rw.Result()
+}
+
+// grpcTestBody is the body of a call that records whether it was read
+type grpcTestBody struct {
+	read bool
+}
+
+func (b *grpcTestBody) Read(p []byte) (int, error) {
+	b.read = true
+	return 0, io.EOF
+}
+
+// decode reads the next message of the response
+func (s *GrpcTestSuite) decode(resp *http.Response, msg interface{}) {
+	data, err := readGrpcMessage(resp.Body)
+	s.NoError(err)
+	s.NoError(api.Unmarshal(data, msg))
+}
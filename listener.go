@@ -0,0 +1,182 @@
+package main
+
+import (
+	"./actions"
+	"./proxy"
+	"./server"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var ready int32 // Set to 1 once the initial configuration is loaded
+
+// The time the proxy waits before asking the Swarm Listener to send the services again. It doubles after each failure.
+var listenerRetryInterval = time.Second
+
+// The longest time the proxy waits between two attempts to reach the Swarm Listener.
+var listenerRetryMaxInterval = 30 * time.Second
+
+// The time between two checks whether all the services of the Swarm Listener are configured.
+var listenerReadyInterval = time.Second
+
+var listenerClient = &http.Client{Timeout: 5 * time.Second}
+
+// getListenerServices returns the names of the services the Swarm Listener notifies the proxy about.
+var getListenerServices = func(listenerAddr string) ([]string, error) {
+	resp, err := listenerClient.Get(fmt.Sprintf("%s/v1/docker-flow-swarm-listener/get-services", listenerAddr))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Swarm Listener responded with the status code %d", resp.StatusCode)
+	}
+	services := []map[string]string{}
+	if err := json.NewDecoder(resp.Body).Decode(&services); err != nil {
+		return nil, fmt.Errorf("Could not decode the services of the Swarm Listener\n%s", err.Error())
+	}
+	names := []string{}
+	for _, s := range services {
+		if len(s["serviceName"]) > 0 {
+			names = append(names, proxy.GetNamespacedName(s["namespace"], s["serviceName"]))
+		}
+	}
+	return names, nil
+}
+
+// getListenerAddresses returns the addresses of the Swarm Listeners specified as a comma-separated list through LISTENER_ADDRESS.
+func (m *Serve) getListenerAddresses() []string {
+	addresses := []string{}
+	for _, address := range strings.Split(m.ListenerAddress, ",") {
+		if address = strings.TrimSpace(address); len(address) > 0 {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// getListenerUrls returns the URLs of the Swarm Listeners. The port 8080 is used unless the address is already a URL.
+func (m *Serve) getListenerUrls() []string {
+	urls := []string{}
+	for _, address := range m.getListenerAddresses() {
+		if !strings.HasPrefix(address, "http") {
+			address = fmt.Sprintf("http://%s:8080", address)
+		}
+		urls = append(urls, address)
+	}
+	return urls
+}
+
+// getReloadListeners returns the URLs of the Swarm Listeners the reload request asks to send their services.
+// The fromListener query is either a boolean that selects all the listeners or one of the addresses of LISTENER_ADDRESS.
+// Other addresses are rejected so that the proxy cannot be made to send requests to arbitrary hosts.
+func (m *Serve) getReloadListeners(req *http.Request) ([]string, error) {
+	value := req.URL.Query().Get("fromListener")
+	if len(value) == 0 {
+		return []string{}, nil
+	} else if fromListener, err := strconv.ParseBool(value); err == nil {
+		if !fromListener {
+			return []string{}, nil
+		}
+		return m.getListenerUrls(), nil
+	}
+	urls := m.getListenerUrls()
+	for i, address := range m.getListenerAddresses() {
+		if value == address || value == urls[i] {
+			return []string{urls[i]}, nil
+		}
+	}
+	return nil, fmt.Errorf("The listener %s is not one of the addresses of LISTENER_ADDRESS", value)
+}
+
+func (m *Serve) isReady() bool {
+	return atomic.LoadInt32(&ready) == 1
+}
+
+// reloadAllServices loads the services from Consul or asks the Swarm Listener to send them.
+// The Swarm Listener is retried with an exponential backoff for up to LISTENER_RETRY_TIMEOUT seconds
+// since it is often not yet running when the proxy and the listener are started together.
+func (m *Serve) reloadAllServices(recon actions.Reconfigurable, listenerAddr string) error {
+	deadline := time.Now().Add(proxy.GetTimeout("LISTENER_RETRY_TIMEOUT", 60))
+	interval := listenerRetryInterval
+	for {
+		err := recon.ReloadAllServices(m.ConsulAddresses, m.InstanceName, m.Mode, listenerAddr)
+		if err == nil || len(listenerAddr) == 0 || time.Now().Add(interval).After(deadline) {
+			return err
+		}
+		logWarnf("Could not reach the Swarm Listener %s. Retrying in %s\n%s", listenerAddr, interval, err.Error())
+		time.Sleep(interval)
+		if interval *= 2; interval > listenerRetryMaxInterval {
+			interval = listenerRetryMaxInterval
+		}
+	}
+}
+
+// waitUntilReady marks the proxy as ready once all the services the Swarm Listeners know about are configured
+// or, if that does not happen within LISTENER_READY_TIMEOUT seconds, once the timeout expires.
+// Without Swarm Listeners, the proxy is ready as soon as the services from Consul are loaded.
+func (m *Serve) waitUntilReady(listenerAddrs []string) {
+	deadline := time.Now().Add(proxy.GetTimeout("LISTENER_READY_TIMEOUT", 60))
+	for len(listenerAddrs) > 0 {
+		missing, err := m.getMissingListenerServices(listenerAddrs)
+		if err == nil && len(missing) == 0 {
+			break
+		} else if time.Now().After(deadline) {
+			if err != nil {
+				logWarnf("Could not verify that the services of the Swarm Listener are configured\n%s", err.Error())
+			} else {
+				logWarnf("The services %v of the Swarm Listener were not configured in time", missing)
+			}
+			break
+		}
+		time.Sleep(listenerReadyInterval)
+	}
+	logPrintf("The proxy is ready")
+	atomic.StoreInt32(&ready, 1)
+}
+
+// getMissingListenerServices returns the services of all the Swarm Listeners that are not configured.
+// A service notified by more than one listener is reported once.
+func (m *Serve) getMissingListenerServices(listenerAddrs []string) ([]string, error) {
+	names := []string{}
+	for _, listenerAddr := range listenerAddrs {
+		listenerNames, err := getListenerServices(listenerAddr)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, listenerNames...)
+	}
+	configured := proxy.Instance.GetServices()
+	missing := []string{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if _, found := configured[name]; !found && !seen[name] {
+			missing = append(missing, name)
+		}
+		seen[name] = true
+	}
+	return missing, nil
+}
+
+// readiness responds with 200 once the initial configuration is loaded and with 503 before that or while the proxy is shutting down.
+// Health checks used during rolling updates should use it so that the old instances keep serving until the new ones are configured.
+func (m *Serve) readiness(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	response := server.Response{Status: "OK"}
+	if m.isShuttingDown() {
+		response.Status, response.Message = "NOK", "The proxy is shutting down"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else if !m.isReady() {
+		response.Status, response.Message = "NOK", "The proxy is loading the initial configuration"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
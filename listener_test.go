@@ -0,0 +1,206 @@
+// +build !integration
+
+package main
+
+import (
+	"./proxy"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ListenerTestSuite struct {
+	suite.Suite
+}
+
+func (s *ListenerTestSuite) SetupTest() {
+	atomic.StoreInt32(&ready, 0)
+	atomic.StoreInt32(&shuttingDown, 0)
+	listenerRetryInterval = time.Millisecond
+	listenerReadyInterval = time.Millisecond
+	getListenerServices = getListenerServicesOrig
+}
+
+func (s *ListenerTestSuite) TearDownTest() {
+	atomic.StoreInt32(&ready, 0)
+	atomic.StoreInt32(&shuttingDown, 0)
+}
+
+// reloadAllServices
+
+func (s *ListenerTestSuite) Test_ReloadAllServices_RetriesListener_UntilItResponds() {
+	mockObj := getReconfigureMock("ReloadAllServices")
+	mockObj.On("ReloadAllServices", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("This is an error")).Twice()
+	mockObj.On("ReloadAllServices", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	srv := Serve{}
+
+	err := srv.reloadAllServices(mockObj, "http://swarm-listener:8080")
+
+	s.NoError(err)
+	mockObj.AssertNumberOfCalls(s.T(), "ReloadAllServices", 3)
+}
+
+func (s *ListenerTestSuite) Test_ReloadAllServices_ReturnsError_WhenListenerDoesNotRespondInTime() {
+	defer os.Unsetenv("LISTENER_RETRY_TIMEOUT")
+	os.Setenv("LISTENER_RETRY_TIMEOUT", "1")
+	listenerRetryInterval = 2 * time.Second
+	mockObj := getReconfigureMock("ReloadAllServices")
+	mockObj.On("ReloadAllServices", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("This is an error"))
+	srv := Serve{}
+
+	err := srv.reloadAllServices(mockObj, "http://swarm-listener:8080")
+
+	s.Error(err)
+	mockObj.AssertNumberOfCalls(s.T(), "ReloadAllServices", 1)
+}
+
+func (s *ListenerTestSuite) Test_ReloadAllServices_DoesNotRetry_WhenListenerIsNotSet() {
+	mockObj := getReconfigureMock("ReloadAllServices")
+	mockObj.On("ReloadAllServices", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("This is an error"))
+	srv := Serve{}
+
+	err := srv.reloadAllServices(mockObj, "")
+
+	s.Error(err)
+	mockObj.AssertNumberOfCalls(s.T(), "ReloadAllServices", 1)
+}
+
+// waitUntilReady
+
+func (s *ListenerTestSuite) Test_WaitUntilReady_MarksProxyAsReady_WhenAllListenerServicesAreConfigured() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{}).Once()
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service"}})
+	proxy.Instance = proxyMock
+	getListenerServices = func(listenerAddr string) ([]string, error) {
+		return []string{"my-service"}, nil
+	}
+	srv := Serve{}
+
+	srv.waitUntilReady([]string{"http://swarm-listener:8080"})
+
+	s.True(srv.isReady())
+	proxyMock.AssertNumberOfCalls(s.T(), "GetServices", 2)
+}
+
+// getMissingListenerServices
+
+func (s *ListenerTestSuite) Test_GetMissingListenerServices_AggregatesServicesOfAllListeners() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service"}})
+	proxy.Instance = proxyMock
+	getListenerServices = func(listenerAddr string) ([]string, error) {
+		if listenerAddr == "http://listener-1:8080" {
+			return []string{"my-service", "other-service"}, nil
+		}
+		return []string{"my-service"}, nil
+	}
+	srv := Serve{}
+
+	actual, err := srv.getMissingListenerServices([]string{"http://listener-1:8080", "http://listener-2:8080"})
+
+	s.NoError(err)
+	s.Equal([]string{"other-service"}, actual)
+}
+
+func (s *ListenerTestSuite) Test_WaitUntilReady_MarksProxyAsReady_WhenTimeoutExpires() {
+	defer os.Unsetenv("LISTENER_READY_TIMEOUT")
+	os.Setenv("LISTENER_READY_TIMEOUT", "1")
+	listenerReadyInterval = 100 * time.Millisecond
+	getListenerServices = func(listenerAddr string) ([]string, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+	srv := Serve{}
+
+	srv.waitUntilReady([]string{"http://swarm-listener:8080"})
+
+	s.True(srv.isReady())
+}
+
+func (s *ListenerTestSuite) Test_WaitUntilReady_MarksProxyAsReady_WhenListenerIsNotSet() {
+	srv := Serve{}
+
+	srv.waitUntilReady([]string{})
+
+	s.True(srv.isReady())
+}
+
+// getListenerServices
+
+func (s *ListenerTestSuite) Test_GetListenerServices_ReturnsNamesOfServices() {
+	actualPath := ""
+	listener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actualPath = r.URL.Path
+		fmt.Fprint(w, `[{"serviceName":"my-service","port":"8080"},{"serviceName":"api","namespace":"team-a"},{"port":"80"}]`)
+	}))
+	defer listener.Close()
+
+	actual, err := getListenerServicesOrig(listener.URL)
+
+	s.NoError(err)
+	s.Equal("/v1/docker-flow-swarm-listener/get-services", actualPath)
+	s.Equal([]string{"my-service", proxy.GetNamespacedName("team-a", "api")}, actual)
+}
+
+// ServeHTTP > Ready
+
+func (s *ListenerTestSuite) Test_ServeHTTP_ReturnsStatus503_WhenProxyIsNotReady() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/ready", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusServiceUnavailable, rw.Code)
+}
+
+func (s *ListenerTestSuite) Test_ServeHTTP_ReturnsStatus200_WhenProxyIsReady() {
+	atomic.StoreInt32(&ready, 1)
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/ready", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusOK, rw.Code)
+}
+
+func (s *ListenerTestSuite) Test_ServeHTTP_ReturnsStatus503_WhenReadyProxyIsShuttingDown() {
+	atomic.StoreInt32(&ready, 1)
+	atomic.StoreInt32(&shuttingDown, 1)
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/ready", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusServiceUnavailable, rw.Code)
+}
+
+var getListenerServicesOrig = getListenerServices
+
+func TestListenerUnitTestSuite(t *testing.T) {
+	defer func() { getListenerServices = getListenerServicesOrig }()
+	listenerRetryIntervalOrig := listenerRetryInterval
+	defer func() { listenerRetryInterval = listenerRetryIntervalOrig }()
+	listenerReadyIntervalOrig := listenerReadyInterval
+	defer func() { listenerReadyInterval = listenerReadyIntervalOrig }()
+	logPrintfOrig := logPrintf
+	defer func() { logPrintf = logPrintfOrig }()
+	logPrintf = func(format string, v ...interface{}) {}
+	logWarnfOrig := logWarnf
+	defer func() { logWarnf = logWarnfOrig }()
+	logWarnf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(ListenerTestSuite))
+}
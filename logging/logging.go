@@ -0,0 +1,165 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	DEBUG Level = iota
+	INFO
+	WARN
+	ERROR
+)
+
+var levelNames = map[Level]string{
+	DEBUG: "debug",
+	INFO:  "info",
+	WARN:  "warn",
+	ERROR: "error",
+}
+
+// Fields are key/value pairs added to each log entry.
+// They are used, for example, to add request-scoped data to API logs.
+type Fields map[string]interface{}
+
+type Logger struct {
+	mu     *sync.Mutex
+	Level  Level
+	Format string
+	Out    io.Writer
+}
+
+type Entry struct {
+	logger *Logger
+	fields Fields
+}
+
+var timeNow = time.Now
+
+// Std is the logger used by the package functions.
+// It is configured through LOG_LEVEL and LOG_FORMAT environment variables.
+var Std = NewLogger(os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"), os.Stderr)
+
+func NewLogger(level, format string, out io.Writer) *Logger {
+	if !strings.EqualFold(format, "json") {
+		format = "text"
+	}
+	return &Logger{
+		mu:     &sync.Mutex{},
+		Level:  ParseLevel(level),
+		Format: strings.ToLower(format),
+		Out:    out,
+	}
+}
+
+// ParseLevel converts the name of a level (e.g. debug) into a Level.
+// INFO is returned if the name is not recognized.
+func ParseLevel(name string) Level {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level
+		}
+	}
+	if strings.EqualFold(name, "warning") {
+		return WARN
+	}
+	return INFO
+}
+
+func (l *Logger) WithFields(fields Fields) *Entry {
+	return &Entry{logger: l, fields: fields}
+}
+
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.log(DEBUG, Fields{}, format, v...)
+}
+
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.log(INFO, Fields{}, format, v...)
+}
+
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.log(WARN, Fields{}, format, v...)
+}
+
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.log(ERROR, Fields{}, format, v...)
+}
+
+func (e *Entry) Debugf(format string, v ...interface{}) {
+	e.logger.log(DEBUG, e.fields, format, v...)
+}
+
+func (e *Entry) Infof(format string, v ...interface{}) {
+	e.logger.log(INFO, e.fields, format, v...)
+}
+
+func (e *Entry) Warnf(format string, v ...interface{}) {
+	e.logger.log(WARN, e.fields, format, v...)
+}
+
+func (e *Entry) Errorf(format string, v ...interface{}) {
+	e.logger.log(ERROR, e.fields, format, v...)
+}
+
+func (l *Logger) log(level Level, fields Fields, format string, v ...interface{}) {
+	if level < l.Level {
+		return
+	}
+	msg := strings.TrimRight(fmt.Sprintf(format, v...), "\n")
+	now := timeNow()
+	var line string
+	if l.Format == "json" {
+		entry := map[string]interface{}{}
+		for k, v := range fields {
+			entry[k] = v
+		}
+		entry["time"] = now.Format(time.RFC3339)
+		entry["level"] = levelNames[level]
+		entry["msg"] = msg
+		js, _ := json.Marshal(entry)
+		line = string(js)
+	} else {
+		keys := []string{}
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		line = fmt.Sprintf("%s %s %s", now.Format("2006/01/02 15:04:05"), strings.ToUpper(levelNames[level]), msg)
+		for _, k := range keys {
+			line += fmt.Sprintf(" %s=%v", k, fields[k])
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.Out, line)
+}
+
+func WithFields(fields Fields) *Entry {
+	return Std.WithFields(fields)
+}
+
+func Debugf(format string, v ...interface{}) {
+	Std.Debugf(format, v...)
+}
+
+func Infof(format string, v ...interface{}) {
+	Std.Infof(format, v...)
+}
+
+func Warnf(format string, v ...interface{}) {
+	Std.Warnf(format, v...)
+}
+
+func Errorf(format string, v ...interface{}) {
+	Std.Errorf(format, v...)
+}
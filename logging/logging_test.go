@@ -0,0 +1,122 @@
+// +build !integration
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LoggingTestSuite struct {
+	suite.Suite
+	out *bytes.Buffer
+}
+
+func (s *LoggingTestSuite) SetupTest() {
+	s.out = &bytes.Buffer{}
+	timeNow = func() time.Time {
+		return time.Date(2017, 3, 2, 10, 20, 30, 0, time.UTC)
+	}
+}
+
+// Suite
+
+func TestLoggingUnitTestSuite(t *testing.T) {
+	timeNowOrig := timeNow
+	defer func() { timeNow = timeNowOrig }()
+	s := new(LoggingTestSuite)
+	suite.Run(t, s)
+}
+
+// ParseLevel
+
+func (s *LoggingTestSuite) Test_ParseLevel_ReturnsLevel() {
+	data := map[string]Level{
+		"debug":   DEBUG,
+		"INFO":    INFO,
+		"warn":    WARN,
+		"warning": WARN,
+		"Error":   ERROR,
+		"":        INFO,
+		"unknown": INFO,
+	}
+	for name, expected := range data {
+		s.Equal(expected, ParseLevel(name), name)
+	}
+}
+
+// NewLogger
+
+func (s *LoggingTestSuite) Test_NewLogger_DefaultsToTextFormat() {
+	l := NewLogger("", "something", s.out)
+
+	s.Equal("text", l.Format)
+	s.Equal(INFO, l.Level)
+}
+
+// Infof
+
+func (s *LoggingTestSuite) Test_Infof_WritesTextEntry() {
+	l := NewLogger("info", "text", s.out)
+
+	l.Infof("Processing request %s", "/v1/test")
+
+	s.Equal("2017/03/02 10:20:30 INFO Processing request /v1/test\n", s.out.String())
+}
+
+func (s *LoggingTestSuite) Test_Infof_WritesJsonEntry_WhenFormatIsJson() {
+	l := NewLogger("info", "JSON", s.out)
+
+	l.Infof("Processing request %s", "/v1/test")
+
+	actual := map[string]interface{}{}
+	json.Unmarshal(s.out.Bytes(), &actual)
+	s.Equal("info", actual["level"])
+	s.Equal("Processing request /v1/test", actual["msg"])
+	s.Equal("2017-03-02T10:20:30Z", actual["time"])
+}
+
+func (s *LoggingTestSuite) Test_Infof_DoesNotWrite_WhenLevelIsHigher() {
+	l := NewLogger("warn", "text", s.out)
+
+	l.Debugf("This is debug")
+	l.Infof("This is info")
+
+	s.Empty(s.out.String())
+}
+
+// Errorf
+
+func (s *LoggingTestSuite) Test_Errorf_WritesEntry_WhenLevelIsLower() {
+	l := NewLogger("debug", "text", s.out)
+
+	l.Errorf("This is an error\n")
+
+	s.Equal("2017/03/02 10:20:30 ERROR This is an error\n", s.out.String())
+}
+
+// WithFields
+
+func (s *LoggingTestSuite) Test_WithFields_AddsSortedFieldsToTextEntry() {
+	l := NewLogger("info", "text", s.out)
+
+	l.WithFields(Fields{"path": "/v1/test", "method": "GET"}).Warnf("Something happened")
+
+	s.Equal("2017/03/02 10:20:30 WARN Something happened method=GET path=/v1/test\n", s.out.String())
+}
+
+func (s *LoggingTestSuite) Test_WithFields_AddsFieldsToJsonEntry() {
+	l := NewLogger("info", "json", s.out)
+
+	l.WithFields(Fields{"path": "/v1/test", "method": "GET"}).Infof("Processing request")
+
+	actual := map[string]interface{}{}
+	json.Unmarshal(s.out.Bytes(), &actual)
+	s.Equal("GET", actual["method"])
+	s.Equal("/v1/test", actual["path"])
+	s.Equal("Processing request", actual["msg"])
+}
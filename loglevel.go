@@ -0,0 +1,159 @@
+package main
+
+import (
+	"./proxy"
+	"./server"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The timer that reverts the log level changed through the loglevel endpoint
+var logLevelTimer *time.Timer
+
+// Incremented with each change so that a timer that fired while the log level was changed again does not revert the new change
+var logLevelGeneration int
+
+// The overrides of the logLevel and debug global settings before the log level was changed.
+// The settings that were not overridden are stored as empty strings.
+var logLevelBaseline map[string]string
+
+// The time the log level is reverted
+var logLevelRevertTime time.Time
+
+var logLevelParams = []string{"logLevel", "debug"}
+
+// LogLevel holds the current log level of the proxy process and whether HAProxy runs in the debug mode.
+type LogLevel struct {
+	Level        string
+	HaproxyDebug bool
+	// The time when the log level is reverted. Empty when it does not change automatically.
+	RevertTime *time.Time `json:",omitempty"`
+}
+
+// manageLogLevel changes the log level of the proxy process and, when haproxy is true, the debug mode of HAProxy.
+// The change is reverted after the number of seconds specified through the period parameter or LOG_LEVEL_PERIOD.
+// Requests with methods other than PUT output the current log level.
+func (m *Serve) manageLogLevel(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	if req.Method == "PUT" {
+		response := server.Response{}
+		if status, err := m.setLogLevel(req); err != nil {
+			if status == http.StatusInternalServerError {
+				m.writeInternalServerError(w, &response, err.Error())
+			} else {
+				m.writeBadRequest(w, &response, err.Error())
+			}
+			js, _ := json.Marshal(response)
+			w.Write(js)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	js, _ := json.Marshal(m.getLogLevel())
+	w.Write(js)
+}
+
+// setLogLevel applies the log level of the request and returns the status code of the response.
+func (m *Serve) setLogLevel(req *http.Request) (int, error) {
+	level := req.URL.Query().Get("level")
+	if len(level) == 0 {
+		return http.StatusBadRequest, fmt.Errorf("level parameter is mandatory")
+	}
+	periodValue := req.URL.Query().Get("period")
+	if len(periodValue) == 0 {
+		periodValue = proxy.GetSecretOrEnvVar("LOG_LEVEL_PERIOD", "600")
+	}
+	period, err := strconv.Atoi(periodValue)
+	if err != nil || period < 0 {
+		return http.StatusBadRequest, fmt.Errorf("period must be a number of seconds")
+	}
+	values := map[string]string{"logLevel": level}
+	if haproxy := req.URL.Query().Get("haproxy"); len(haproxy) > 0 {
+		debug, err := strconv.ParseBool(haproxy)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("haproxy must be true or false")
+		}
+		values["debug"] = strconv.FormatBool(debug && strings.EqualFold(level, "debug"))
+	}
+	previous := proxy.GetGlobalOverrides()
+	reloadNeeded := len(values["debug"]) > 0 && values["debug"] != proxy.GetGlobal("debug")
+	if err := proxy.SetGlobals(values); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if reloadNeeded {
+		if err := reload.Execute(true, ""); err != nil {
+			proxy.ResetGlobals(previous)
+			reload.Execute(true, "")
+			return http.StatusInternalServerError, err
+		}
+	}
+	if logLevelTimer != nil {
+		logLevelTimer.Stop()
+	} else {
+		// The baseline is kept when the log level is changed again before it was reverted
+		logLevelBaseline = map[string]string{}
+		for _, param := range logLevelParams {
+			logLevelBaseline[param] = previous[param]
+		}
+	}
+	logLevelGeneration++
+	logLevelTimer = nil
+	logLevelRevertTime = time.Time{}
+	if period == 0 {
+		logLevelBaseline = nil
+	} else {
+		generation := logLevelGeneration
+		logLevelRevertTime = timeNow().Add(time.Duration(period) * time.Second)
+		logLevelTimer = time.AfterFunc(time.Duration(period)*time.Second, func() {
+			m.revertLogLevel(generation)
+		})
+	}
+	logPrintf("The log level was changed to %s", level)
+	return http.StatusOK, nil
+}
+
+// revertLogLevel restores the log level and the debug mode of HAProxy that were used before the change.
+func (m *Serve) revertLogLevel(generation int) {
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	if logLevelBaseline == nil || generation != logLevelGeneration {
+		return
+	}
+	debug := proxy.GetGlobal("debug")
+	overrides := proxy.GetGlobalOverrides()
+	for param, value := range logLevelBaseline {
+		if len(value) == 0 {
+			delete(overrides, param)
+		} else {
+			overrides[param] = value
+		}
+	}
+	proxy.ResetGlobals(overrides)
+	logLevelBaseline = nil
+	logLevelTimer = nil
+	logLevelRevertTime = time.Time{}
+	if debug != proxy.GetGlobal("debug") {
+		if err := reload.Execute(true, ""); err != nil {
+			logWarnf("Could not reload the proxy after reverting its log level\n%s", err.Error())
+		}
+	}
+	logPrintf("The log level was reverted to %s", proxy.GetGlobal("logLevel"))
+}
+
+func (m *Serve) getLogLevel() LogLevel {
+	logLevel := LogLevel{
+		Level:        strings.ToLower(proxy.GetGlobal("logLevel")),
+		HaproxyDebug: strings.EqualFold(proxy.GetGlobal("debug"), "true"),
+	}
+	if !logLevelRevertTime.IsZero() {
+		revertTime := logLevelRevertTime
+		logLevel.RevertTime = &revertTime
+	}
+	return logLevel
+}
@@ -0,0 +1,152 @@
+// +build !integration
+
+package main
+
+import (
+	"./logging"
+	"./proxy"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LogLevelTestSuite struct {
+	suite.Suite
+	reloads int
+}
+
+func (s *LogLevelTestSuite) SetupTest() {
+	proxy.ResetGlobals(map[string]string{})
+	logLevelBaseline = nil
+	logLevelRevertTime = time.Time{}
+	s.reloads = 0
+	reload = ReloadMock{
+		ExecuteMock: func(recreate bool, listenerAddr string) error {
+			s.reloads++
+			return nil
+		},
+	}
+}
+
+func (s *LogLevelTestSuite) TearDownTest() {
+	if logLevelTimer != nil {
+		logLevelTimer.Stop()
+		logLevelTimer = nil
+	}
+	proxy.ResetGlobals(map[string]string{})
+}
+
+// ServeHTTP > LogLevel
+
+func (s *LogLevelTestSuite) Test_ServeHTTP_ChangesLogLevelAndHaproxyDebug_WhenUrlIsLogLevel() {
+	actual, code := s.serve("PUT", "/v1/docker-flow-proxy/loglevel?level=debug&haproxy=true&period=60")
+
+	s.Equal(http.StatusOK, code)
+	s.Equal("debug", actual.Level)
+	s.True(actual.HaproxyDebug)
+	s.NotNil(actual.RevertTime)
+	s.Equal(logging.DEBUG, logging.Std.Level)
+	s.Equal(1, s.reloads)
+}
+
+func (s *LogLevelTestSuite) Test_ServeHTTP_DoesNotReload_WhenHaproxyIsNotSpecified() {
+	actual, code := s.serve("PUT", "/v1/docker-flow-proxy/loglevel?level=warn")
+
+	s.Equal(http.StatusOK, code)
+	s.Equal("warn", actual.Level)
+	s.False(actual.HaproxyDebug)
+	s.Equal(0, s.reloads)
+}
+
+func (s *LogLevelTestSuite) Test_ServeHTTP_DoesNotRevertLogLevel_WhenPeriodIsZero() {
+	actual, _ := s.serve("PUT", "/v1/docker-flow-proxy/loglevel?level=debug&period=0")
+
+	s.Nil(actual.RevertTime)
+	s.Nil(logLevelTimer)
+}
+
+func (s *LogLevelTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenLogLevelIsInvalid() {
+	_, code := s.serve("PUT", "/v1/docker-flow-proxy/loglevel?level=verbose")
+
+	s.Equal(http.StatusBadRequest, code)
+	s.Equal("info", proxy.GetGlobal("logLevel"))
+}
+
+func (s *LogLevelTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenLogLevelIsMissing() {
+	_, code := s.serve("PUT", "/v1/docker-flow-proxy/loglevel?haproxy=true")
+
+	s.Equal(http.StatusBadRequest, code)
+}
+
+func (s *LogLevelTestSuite) Test_ServeHTTP_RestoresLogLevel_WhenReloadFails() {
+	reload = ReloadMock{
+		ExecuteMock: func(recreate bool, listenerAddr string) error {
+			return fmt.Errorf("This is an error")
+		},
+	}
+
+	_, code := s.serve("PUT", "/v1/docker-flow-proxy/loglevel?level=debug&haproxy=true")
+
+	s.Equal(http.StatusInternalServerError, code)
+	s.Equal("info", proxy.GetGlobal("logLevel"))
+	s.Equal("false", proxy.GetGlobal("debug"))
+}
+
+func (s *LogLevelTestSuite) Test_ServeHTTP_ReturnsLogLevel_WhenMethodIsGet() {
+	actual, code := s.serve("GET", "/v1/docker-flow-proxy/loglevel")
+
+	s.Equal(http.StatusOK, code)
+	s.Equal("info", actual.Level)
+	s.Nil(actual.RevertTime)
+}
+
+// revertLogLevel
+
+func (s *LogLevelTestSuite) Test_RevertLogLevel_RestoresSettingsBeforeTheFirstChange() {
+	proxy.SetGlobals(map[string]string{"logLevel": "warn"})
+	s.serve("PUT", "/v1/docker-flow-proxy/loglevel?level=debug&haproxy=true")
+	s.serve("PUT", "/v1/docker-flow-proxy/loglevel?level=error")
+	srv := Serve{}
+
+	srv.revertLogLevel(logLevelGeneration)
+
+	s.Equal(map[string]string{"logLevel": "warn"}, proxy.GetGlobalOverrides())
+	s.Equal(logging.WARN, logging.Std.Level)
+	s.Equal(2, s.reloads)
+	s.Nil(srv.getLogLevel().RevertTime)
+}
+
+func (s *LogLevelTestSuite) Test_RevertLogLevel_DoesNothing_WhenLogLevelWasChangedAgain() {
+	s.serve("PUT", "/v1/docker-flow-proxy/loglevel?level=debug")
+	generation := logLevelGeneration
+	s.serve("PUT", "/v1/docker-flow-proxy/loglevel?level=error")
+	srv := Serve{}
+
+	srv.revertLogLevel(generation)
+
+	s.Equal("error", proxy.GetGlobal("logLevel"))
+}
+
+func (s *LogLevelTestSuite) serve(method, url string) (LogLevel, int) {
+	req, _ := http.NewRequest(method, url, nil)
+	rw := httptest.NewRecorder()
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+	actual := LogLevel{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	return actual, rw.Code
+}
+
+func TestLogLevelUnitTestSuite(t *testing.T) {
+	reloadOrig := reload
+	defer func() { reload = reloadOrig }()
+	logPrintfOrig := logPrintf
+	defer func() { logPrintf = logPrintfOrig }()
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(LogLevelTestSuite))
+}
@@ -0,0 +1,59 @@
+-- Answers CORS preflight requests without forwarding them to the backend.
+--
+-- The backend generated for services with corsPreflight sets the headers below before handing the request over to this service.
+--   X-Dfp-Cors-Allow-Origins comma separated list of the allowed origins (defaults to *)
+--   X-Dfp-Cors-Allow-Methods comma separated list of the allowed methods (defaults to the requested method)
+--   X-Dfp-Cors-Allow-Headers comma separated list of the allowed headers (defaults to the requested headers)
+--   X-Dfp-Cors-Max-Age       the number of seconds the response can be cached (defaults to 600)
+
+local default_max_age = "600"
+
+local function get_header(applet, name)
+    local values = applet.headers[name:lower()]
+    if values == nil then
+        return nil
+    end
+    return values[0]
+end
+
+local function is_allowed_origin(origin, allowed)
+    for value in allowed:gmatch("[^,%s]+") do
+        if value == "*" or value:lower() == origin:lower() then
+            return true
+        end
+    end
+    return false
+end
+
+local function cors_preflight(applet)
+    local origin = get_header(applet, "Origin")
+    local allowed_origins = get_header(applet, "X-Dfp-Cors-Allow-Origins") or "*"
+    if origin == nil or not is_allowed_origin(origin, allowed_origins) then
+        applet:set_status(403)
+        applet:add_header("Content-Length", "0")
+        applet:start_response()
+        return
+    end
+    local max_age = get_header(applet, "X-Dfp-Cors-Max-Age") or default_max_age
+    applet:set_status(204)
+    if allowed_origins == "*" then
+        applet:add_header("Access-Control-Allow-Origin", "*")
+    else
+        applet:add_header("Access-Control-Allow-Origin", origin)
+        applet:add_header("Vary", "Origin")
+    end
+    applet:add_header(
+        "Access-Control-Allow-Methods",
+        get_header(applet, "X-Dfp-Cors-Allow-Methods") or get_header(applet, "Access-Control-Request-Method")
+    )
+    local allowed_headers = get_header(applet, "X-Dfp-Cors-Allow-Headers") or get_header(applet, "Access-Control-Request-Headers")
+    if allowed_headers ~= nil then
+        applet:add_header("Access-Control-Allow-Headers", allowed_headers)
+    end
+    applet:add_header("Access-Control-Max-Age", max_age)
+    applet:add_header("Cache-Control", "public, max-age=" .. max_age)
+    applet:add_header("Content-Length", "0")
+    applet:start_response()
+end
+
+core.register_service("cors-preflight", "http", cors_preflight)
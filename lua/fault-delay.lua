@@ -0,0 +1,14 @@
+-- Delays requests to inject latency for resilience testing.
+--
+-- The backends generated with FAULT_INJECTION set the variable
+--   txn.dfp_fault_delay the number of milliseconds to wait before the request is forwarded
+-- and invoke this action when the delay of the service is greater than zero.
+
+local function fault_delay(txn)
+    local delay = tonumber(txn:get_var("txn.dfp_fault_delay"))
+    if delay ~= nil and delay > 0 then
+        core.msleep(delay)
+    end
+end
+
+core.register_action("fault-delay", { "http-req" }, fault_delay)
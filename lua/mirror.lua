@@ -0,0 +1,49 @@
+-- Sends a copy of the request to a shadow service and discards its response.
+--
+-- The backend generated for services with mirrorToService sets the variable
+--   txn.dfp_mirror host:port of the shadow service
+-- and invokes this action. The copy is sent from a separate task so the original request is not delayed.
+
+local timeout = 10
+
+local function mirror(txn)
+    local target = txn:get_var("txn.dfp_mirror")
+    if target == nil then
+        return
+    end
+    local host, port = target:match("^(.+):(%d+)$")
+    if host == nil then
+        return
+    end
+    local path = txn.sf:path()
+    local query = txn.sf:query()
+    if query ~= nil and query ~= "" then
+        path = path .. "?" .. query
+    end
+    local lines = { txn.sf:method() .. " " .. path .. " HTTP/1.0" }
+    for name, values in pairs(txn.http:req_get_headers()) do
+        local lower = name:lower()
+        if lower ~= "connection" and lower ~= "content-length" then
+            for _, value in pairs(values) do
+                table.insert(lines, name .. ": " .. value)
+            end
+        end
+    end
+    local body = txn.sf:req_body() or ""
+    table.insert(lines, "Content-Length: " .. #body)
+    table.insert(lines, "Connection: close")
+    local request = table.concat(lines, "\r\n") .. "\r\n\r\n" .. body
+
+    core.register_task(function()
+        local socket = core.tcp()
+        socket:settimeout(timeout)
+        if socket:connect(host, tonumber(port)) then
+            socket:send(request)
+            -- The response of the shadow service is discarded
+            socket:receive("*a")
+        end
+        socket:close()
+    end)
+end
+
+core.register_action("mirror", { "http-req" }, mirror)
@@ -0,0 +1,69 @@
+-- Returns the client identifier of the MQTT CONNECT packet at the start of the connection.
+--
+-- The backend generated for services with mqttStickiness waits for the packet and sticks the connections with
+--   stick on lua.mqtt_client_id
+-- so that the reconnections of a client reach the broker that keeps its session.
+-- Both MQTT 3.1.1 and MQTT 5 are supported. Nothing is returned until the packet is complete.
+
+-- readLength reads the variable byte integer (the remaining length or the length of the properties) at the position.
+local function readLength(payload, pos)
+    local length, multiplier = 0, 1
+    for i = 0, 3 do
+        local byte = payload:byte(pos + i)
+        if byte == nil then
+            return nil
+        end
+        length = length + (byte % 128) * multiplier
+        if byte < 128 then
+            return length, pos + i + 1
+        end
+        multiplier = multiplier * 128
+    end
+    return nil
+end
+
+local function readString(payload, pos)
+    local msb, lsb = payload:byte(pos, pos + 1)
+    if lsb == nil then
+        return nil
+    end
+    local length = msb * 256 + lsb
+    if #payload < pos + 1 + length then
+        return nil
+    end
+    return payload:sub(pos + 2, pos + 1 + length), pos + 2 + length
+end
+
+local function clientId(txn)
+    local payload = txn.req:dup()
+    if payload == nil or payload:byte(1) ~= 0x10 then
+        return nil
+    end
+    local remaining, pos = readLength(payload, 2)
+    if remaining == nil or #payload < pos - 1 + remaining then
+        return nil
+    end
+    local protocol
+    protocol, pos = readString(payload, pos)
+    if protocol ~= "MQTT" and protocol ~= "MQIsdp" then
+        return nil
+    end
+    local level = payload:byte(pos)
+    -- The protocol level and the connect flags are followed by the keep alive
+    pos = pos + 4
+    if level == 5 then
+        local properties
+        properties, pos = readLength(payload, pos)
+        if properties == nil then
+            return nil
+        end
+        pos = pos + properties
+    end
+    local id = readString(payload, pos)
+    if id == nil or id == "" then
+        return nil
+    end
+    return id
+end
+
+core.register_fetches("mqtt_client_id", clientId)
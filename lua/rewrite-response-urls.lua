@@ -0,0 +1,237 @@
+-- Forwards the request to the backend and rewrites the absolute URLs in HTML and JSON
+-- responses from the internal path of the service to its public path.
+--
+-- The backend generated for services with rewriteResponseUrls sets the variables
+--   txn.dfp_upstream      host:port of the service
+--   txn.dfp_backend       the name of the backend whose servers receive the request
+--   txn.dfp_max_size      the largest response, in bytes, that is buffered and rewritten
+--   txn.dfp_public_path   the service path the request matched (set when stripPath is used)
+--   txn.dfp_internal_path the prefix added to the path (set when addPathPrefix is used)
+-- and hands the request over to this service.
+
+local timeout = 30
+local chunk_size = 16384
+
+-- The servers of each backend are used in turn
+local next_server = {}
+
+local function trim_slash(path)
+    if path == nil or path == "/" then
+        return ""
+    end
+    return (path:gsub("/+$", ""))
+end
+
+local function escape_pattern(value)
+    return (value:gsub("[%^%$%(%)%%%.%[%]%*%+%-%?]", "%%%0"))
+end
+
+local function escape_replacement(value)
+    return (value:gsub("%%", "%%%%"))
+end
+
+local function rewrite(content, from, to)
+    local pattern = "([\"'=])" .. escape_pattern(from) .. "/(.?)"
+    local replacement = escape_replacement(to)
+    return (content:gsub(pattern, function(prefix, next)
+        if from == "" and next == "/" then
+            -- Protocol relative URL (//cdn.acme.com)
+            return nil
+        end
+        return prefix .. replacement .. "/" .. next
+    end))
+end
+
+-- Rewrites the path of the Location header. Absolute URLs are rewritten only when they point to the proxy or to the service.
+local function rewrite_location(value, from, to, hosts)
+    local origin, authority, path = value:match("^(%a[%w+.-]*://([^/?#]*))(.*)$")
+    if origin == nil then
+        return rewrite("=" .. value, from, to):sub(2)
+    elseif hosts[authority:lower()] then
+        return origin .. rewrite("=" .. path, from, to):sub(2)
+    end
+    return value
+end
+
+-- select_server returns the address of one of the servers of the backend that are up, so that the requests
+-- follow the servers HAProxy selected for the service (tasks, backups, and health checks).
+-- The backup servers are used only when none of the active ones is up.
+local function select_server(backend)
+    local proxy = core.proxies[backend]
+    if proxy == nil then
+        return nil
+    end
+    local names = {}
+    for name in pairs(proxy.servers) do
+        table.insert(names, name)
+    end
+    table.sort(names)
+    local active, backup = {}, {}
+    for _, name in ipairs(names) do
+        local server = proxy.servers[name]
+        local stats = server:get_stats()
+        local status = stats.status or ""
+        if status:sub(1, 2) == "UP" or status == "no check" then
+            if tonumber(stats.bck) == 1 then
+                table.insert(backup, server)
+            else
+                table.insert(active, server)
+            end
+        end
+    end
+    local candidates = active
+    if #candidates == 0 then
+        candidates = backup
+    end
+    if #candidates == 0 then
+        return nil
+    end
+    next_server[backend] = ((next_server[backend] or 0) % #candidates) + 1
+    return candidates[next_server[backend]]:get_addr()
+end
+
+-- receive reads up to size bytes. It returns the data and whether the connection was closed.
+local function receive(socket, size)
+    local data, err, partial = socket:receive(size)
+    if data == nil then
+        return partial or "", true
+    end
+    return data, err ~= nil
+end
+
+local function is_rewritable(content_type)
+    content_type = (content_type or ""):lower()
+    return content_type:find("text/html", 1, true) ~= nil or content_type:find("json", 1, true) ~= nil
+end
+
+local function send_error(applet, status, message)
+    applet:set_status(status)
+    applet:add_header("Content-Type", "text/plain")
+    applet:add_header("Content-Length", tostring(#message))
+    applet:start_response()
+    applet:send(message)
+end
+
+local function build_request(applet)
+    local path = applet.path
+    if applet.qs ~= nil and applet.qs ~= "" then
+        path = path .. "?" .. applet.qs
+    end
+    local lines = { applet.method .. " " .. path .. " HTTP/1.0" }
+    for name, values in pairs(applet.headers) do
+        local lower = name:lower()
+        -- Compressed and chunked responses cannot be rewritten
+        if lower ~= "connection" and lower ~= "accept-encoding" and lower ~= "te" then
+            for _, value in pairs(values) do
+                table.insert(lines, name .. ": " .. value)
+            end
+        end
+    end
+    table.insert(lines, "Connection: close")
+    return table.concat(lines, "\r\n") .. "\r\n\r\n"
+end
+
+local function rewrite_response_urls(applet)
+    local upstream = applet:get_var("txn.dfp_upstream")
+    local backend = applet:get_var("txn.dfp_backend")
+    if upstream == nil or backend == nil then
+        send_error(applet, 500, "The upstream of the service is not set\n")
+        return
+    end
+    local max_size = tonumber(applet:get_var("txn.dfp_max_size")) or 1048576
+    local from = trim_slash(applet:get_var("txn.dfp_internal_path"))
+    local to = trim_slash(applet:get_var("txn.dfp_public_path"))
+    -- The hosts of the absolute locations that are rewritten
+    local hosts = { [upstream:lower()] = true, [(upstream:match("^(.+):%d+$") or upstream):lower()] = true }
+    for name, values in pairs(applet.headers) do
+        if name:lower() == "host" then
+            for _, value in pairs(values) do
+                hosts[value:lower()] = true
+            end
+        end
+    end
+
+    local server = select_server(backend)
+    local host, port
+    if server ~= nil then
+        host, port = server:match("^(.+):(%d+)$")
+    end
+    local socket = core.tcp()
+    socket:settimeout(timeout)
+    if host == nil or not socket:connect(host, tonumber(port)) then
+        send_error(applet, 503, "The service is not available\n")
+        return
+    end
+    socket:send(build_request(applet))
+    local body = applet:receive()
+    if body ~= nil and #body > 0 then
+        socket:send(body)
+    end
+
+    local status_line = socket:receive("*l")
+    local status = status_line and tonumber(status_line:match("^HTTP/%d%.%d (%d%d%d)"))
+    if status == nil then
+        socket:close()
+        send_error(applet, 502, "The service returned an invalid response\n")
+        return
+    end
+    local headers = {}
+    local content_type
+    local content_length
+    while true do
+        local line = socket:receive("*l")
+        if line == nil or line == "" then
+            break
+        end
+        local name, value = line:match("^([^:]+):%s*(.*)$")
+        if name ~= nil then
+            local lower = name:lower()
+            if lower == "content-type" then
+                content_type = value
+            elseif lower == "content-length" then
+                content_length = tonumber(value)
+            end
+            if lower == "location" and from ~= to then
+                value = rewrite_location(value, from, to, hosts)
+            end
+            if lower ~= "content-length" and lower ~= "connection" and lower ~= "transfer-encoding" then
+                table.insert(headers, { name, value })
+            end
+        end
+    end
+    local rewritable = from ~= to and is_rewritable(content_type) and (content_length == nil or content_length <= max_size)
+    local content, closed = "", false
+    if rewritable then
+        -- One more byte than the limit tells whether the response is larger
+        content, closed = receive(socket, max_size + 1)
+        rewritable = #content <= max_size
+    end
+    if rewritable then
+        socket:close()
+        content = rewrite(content, from, to)
+        content_length = #content
+    end
+
+    applet:set_status(status)
+    for _, header in ipairs(headers) do
+        applet:add_header(header[1], header[2])
+    end
+    -- Without the length, the response is sent in chunks
+    if content_length ~= nil then
+        applet:add_header("Content-Length", tostring(content_length))
+    end
+    applet:start_response()
+    applet:send(content)
+    -- The responses that are too large are passed through as they are
+    while not rewritable and not closed do
+        content, closed = receive(socket, chunk_size)
+        if #content > 0 then
+            applet:send(content)
+        end
+    end
+    if not rewritable then
+        socket:close()
+    end
+end
+
+core.register_service("rewrite-response-urls", "http", rewrite_response_urls)
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"./proxy"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The request metrics collected from the HAProxy logs. Set only when LOG_METRICS is enabled.
+var logMetrics *proxy.LogMetrics
+
+var metricLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type backendMetric struct {
+	name, help, metricType string
+	// The label added to the labels of the backend (e.g. code="5xx").
+	label string
+	value func(be proxy.BackendStats) float64
+}
+
+var backendMetrics = []backendMetric{
+	{"dfp_backend_up", "Whether the backend is up.", "gauge", "", func(be proxy.BackendStats) float64 {
+		if strings.HasPrefix(be.Status, "UP") {
+			return 1
+		}
+		return 0
+	}},
+	{"dfp_backend_current_sessions", "The number of current sessions of the backend.", "gauge", "", func(be proxy.BackendStats) float64 {
+		return float64(be.CurrentSessions)
+	}},
+	{"dfp_backend_requests_total", "The number of requests sent to the backend.", "counter", "", func(be proxy.BackendStats) float64 {
+		return float64(be.TotalRequests)
+	}},
+	{"dfp_backend_responses_total", "The number of responses of the backend by the class of the status code.", "counter", `code="4xx"`, func(be proxy.BackendStats) float64 {
+		return float64(be.Http4xxResponses)
+	}},
+	{"dfp_backend_responses_total", "", "", `code="5xx"`, func(be proxy.BackendStats) float64 {
+		return float64(be.Http5xxResponses)
+	}},
+	{"dfp_backend_connection_errors_total", "The number of requests that could not connect to a server of the backend.", "counter", "", func(be proxy.BackendStats) float64 {
+		return float64(be.ConnectionErrors)
+	}},
+	{"dfp_backend_response_errors_total", "The number of responses of the backend that were aborted or invalid.", "counter", "", func(be proxy.BackendStats) float64 {
+		return float64(be.ResponseErrors)
+	}},
+	{"dfp_backend_response_time_seconds", "The average response time of the backend over the last 1024 requests.", "gauge", "", func(be proxy.BackendStats) float64 {
+		return float64(be.ResponseTime) / 1000
+	}},
+}
+
+// getBackendMetrics returns the metrics of the backends of the services read from the HAProxy stats socket.
+// Backends that do not belong to a service (e.g. the one that serves ACME challenges) are not included.
+func (m *Serve) getBackendMetrics() string {
+	stats, err := getAllBackendStats()
+	if err != nil {
+		logWarnf("Could not read the metrics of the backends\n%s", err.Error())
+		return ""
+	}
+	names := []string{}
+	for _, be := range stats {
+		names = append(names, be.Name)
+	}
+	labels := getBackendLabels(names)
+	out := ""
+	for _, metric := range backendMetrics {
+		if len(metric.help) > 0 {
+			out += fmt.Sprintf("# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.metricType)
+		}
+		for _, be := range stats {
+			beLabels, found := labels[be.Name]
+			if !found {
+				continue
+			}
+			if len(metric.label) > 0 {
+				beLabels += "," + metric.label
+			}
+			out += fmt.Sprintf("%s{%s} %s\n", metric.name, beLabels, strconv.FormatFloat(metric.value(be), 'g', -1, 64))
+		}
+	}
+	return out
+}
+
+// getLogMetrics returns the request duration histograms and the status codes of the backends collected from the HAProxy logs.
+func (m *Serve) getLogMetrics(logMetrics *proxy.LogMetrics) string {
+	backends := logMetrics.GetBackends()
+	names := []string{}
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	labels := getBackendLabels(names)
+	durations := "# HELP dfp_backend_request_duration_seconds The total duration of the requests of the backend.\n# TYPE dfp_backend_request_duration_seconds histogram\n"
+	codes := "# HELP dfp_backend_http_responses_total The number of responses of the backend by the status code.\n# TYPE dfp_backend_http_responses_total counter\n"
+	for _, name := range names {
+		beLabels, found := labels[name]
+		if !found {
+			continue
+		}
+		be := backends[name]
+		for i, le := range proxy.LogMetricsBuckets {
+			durations += fmt.Sprintf("dfp_backend_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", beLabels, strconv.FormatFloat(le, 'g', -1, 64), be.Buckets[i])
+		}
+		durations += fmt.Sprintf("dfp_backend_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", beLabels, be.Count)
+		durations += fmt.Sprintf("dfp_backend_request_duration_seconds_sum{%s} %s\n", beLabels, strconv.FormatFloat(be.Sum, 'g', -1, 64))
+		durations += fmt.Sprintf("dfp_backend_request_duration_seconds_count{%s} %d\n", beLabels, be.Count)
+		statusCodes := []string{}
+		for code := range be.Codes {
+			statusCodes = append(statusCodes, code)
+		}
+		sort.Strings(statusCodes)
+		for _, code := range statusCodes {
+			codes += fmt.Sprintf("dfp_backend_http_responses_total{%s,code=\"%s\"} %d\n", beLabels, code, be.Codes[code])
+		}
+	}
+	return durations + codes
+}
+
+// getBackendLabels returns the labels of the backends that belong to services keyed by the names of the backends.
+// The labels contain the name of the service, its domains separated with comma, and the name of the backend.
+func getBackendLabels(backends []string) map[string]string {
+	services := proxy.Instance.GetServices()
+	labels := map[string]string{}
+	for _, name := range backends {
+		if s, found := proxy.GetBackendService(name, services); found {
+			labels[name] = fmt.Sprintf(
+				`service="%s",domain="%s",backend="%s"`,
+				metricLabelReplacer.Replace(s.ServiceName),
+				metricLabelReplacer.Replace(strings.Join(s.ServiceDomain, ",")),
+				metricLabelReplacer.Replace(name),
+			)
+		}
+	}
+	return labels
+}
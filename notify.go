@@ -0,0 +1,69 @@
+package main
+
+import (
+	"./proxy"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var getNotifiers = proxy.GetNotifiers
+var getExpiringCerts = proxy.GetExpiringCerts
+
+// startNotifiers sends notifications about failed reloads, backends that went down, and expiring certificates
+// to the sinks configured through the NOTIFY_* environment variables.
+func (m *Serve) startNotifiers() {
+	notifiers := getNotifiers()
+	if len(notifiers) == 0 {
+		return
+	}
+	logPrintf("Sending notifications to %d sinks", len(notifiers))
+	events, _ := proxy.SubscribeEvents()
+	go m.notify(events, notifiers)
+	if interval, _ := strconv.Atoi(proxy.GetSecretOrEnvVar("NOTIFY_CERT_CHECK_INTERVAL", "24")); interval > 0 {
+		go m.watchCertExpiries(time.Duration(interval) * time.Hour)
+	}
+}
+
+func (m *Serve) notify(events <-chan proxy.Event, notifiers []proxy.Notifier) {
+	for event := range events {
+		subject, message, ok := proxy.GetNotification(event)
+		if !ok {
+			continue
+		}
+		for _, n := range notifiers {
+			if err := n.Notify(subject, message); err != nil {
+				logWarnf("%s", err.Error())
+			}
+		}
+	}
+}
+
+func (m *Serve) watchCertExpiries(interval time.Duration) {
+	m.publishCertExpiries()
+	for range time.Tick(interval) {
+		m.publishCertExpiries()
+	}
+}
+
+// publishCertExpiries publishes a cert-expiry event for each certificate that expires within NOTIFY_CERT_EXPIRY_DAYS.
+func (m *Serve) publishCertExpiries() {
+	days, err := strconv.Atoi(proxy.GetSecretOrEnvVar("NOTIFY_CERT_EXPIRY_DAYS", "14"))
+	if err != nil {
+		days = 14
+	}
+	expiries := getExpiringCerts(proxy.Instance.GetCerts(), timeNow().AddDate(0, 0, days))
+	paths := []string{}
+	for path := range expiries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		proxy.PublishEvent(proxy.Event{
+			Type:        proxy.EventCertExpiry,
+			ServiceName: path,
+			Message:     fmt.Sprintf("The certificate %s expires on %s.", path, expiries[path].UTC().Format(time.RFC3339)),
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// +build !integration
+
+package main
+
+import (
+	"./proxy"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type NotifyTestSuite struct {
+	suite.Suite
+}
+
+type NotifierMock struct {
+	subjects []string
+	err      error
+}
+
+func (m *NotifierMock) Notify(subject, message string) error {
+	m.subjects = append(m.subjects, subject)
+	return m.err
+}
+
+// notify
+
+func (s *NotifyTestSuite) Test_Notify_SendsNotificationsOfEventsThatNeedAttention() {
+	events := make(chan proxy.Event, 3)
+	events <- proxy.Event{Type: proxy.EventReload, Status: "OK"}
+	events <- proxy.Event{Type: proxy.EventReload, Status: "NOK", Message: "This is an error"}
+	events <- proxy.Event{Type: proxy.EventHealth, ServiceName: "my-service-be8080", Status: "DOWN", Message: "UP"}
+	close(events)
+	slack := &NotifierMock{}
+	smtp := &NotifierMock{err: fmt.Errorf("This is an error")}
+	srv := Serve{}
+
+	srv.notify(events, []proxy.Notifier{slack, smtp})
+
+	expected := []string{"The proxy could not be reloaded", "The backend my-service-be8080 is down"}
+	s.Equal(expected, slack.subjects)
+	s.Equal(expected, smtp.subjects)
+}
+
+// startNotifiers
+
+func (s *NotifyTestSuite) Test_StartNotifiers_DoesNotSubscribe_WhenNoNotifiersAreConfigured() {
+	getNotifiers = func() []proxy.Notifier {
+		return []proxy.Notifier{}
+	}
+	srv := Serve{}
+
+	srv.startNotifiers()
+
+	s.False(proxy.HasEventSubscribers())
+}
+
+// publishCertExpiries
+
+func (s *NotifyTestSuite) Test_PublishCertExpiries_PublishesEventsOfExpiringCerts() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetCerts")
+	proxyMock.On("GetCerts").Return(map[string]string{"/certs/acme.pem": "my-cert"})
+	proxy.Instance = proxyMock
+	now := time.Date(2017, 3, 2, 10, 20, 30, 0, time.UTC)
+	timeNow = func() time.Time {
+		return now
+	}
+	var actualBefore time.Time
+	getExpiringCerts = func(certs map[string]string, before time.Time) map[string]time.Time {
+		actualBefore = before
+		return map[string]time.Time{"/certs/acme.pem": now.AddDate(0, 0, 3)}
+	}
+	events, unsubscribe := proxy.SubscribeEvents()
+	defer unsubscribe()
+	srv := Serve{}
+
+	srv.publishCertExpiries()
+
+	s.Equal(now.AddDate(0, 0, 14), actualBefore)
+	s.Require().Len(events, 1)
+	event := <-events
+	s.Equal(proxy.EventCertExpiry, event.Type)
+	s.Equal("/certs/acme.pem", event.ServiceName)
+	s.Equal("The certificate /certs/acme.pem expires on 2017-03-05T10:20:30Z.", event.Message)
+}
+
+func TestNotifyUnitTestSuite(t *testing.T) {
+	getNotifiersOrig := getNotifiers
+	defer func() { getNotifiers = getNotifiersOrig }()
+	logWarnfOrig := logWarnf
+	defer func() { logWarnf = logWarnfOrig }()
+	logWarnf = func(format string, v ...interface{}) {}
+	getExpiringCertsOrig := getExpiringCerts
+	defer func() { getExpiringCerts = getExpiringCertsOrig }()
+	timeNowOrig := timeNow
+	defer func() { timeNow = timeNowOrig }()
+	suite.Run(t, new(NotifyTestSuite))
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"./proxy"
+	"./server"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+type openApiParameter struct {
+	Name        string            `json:"name"`
+	In          string            `json:"in"`
+	Description string            `json:"description,omitempty"`
+	Required    bool              `json:"required,omitempty"`
+	Schema      map[string]string `json:"schema"`
+}
+
+// The parameters of the reconfigure request that are not fields of proxy.Service or proxy.ServiceDest
+var openApiReconfigureParams = []openApiParameter{
+	{Name: "cloneFrom", In: "query", Description: "The name of the service whose parameters are used as defaults.", Schema: openApiString},
+	{Name: "preserveHost", In: "query", Description: "Whether to preserve the Host header of the request.", Schema: openApiBoolean},
+	{Name: "profile", In: "query", Description: "The name of the profile whose parameters are used as defaults.", Schema: openApiString},
+	{Name: "usersPassEncrypted", In: "query", Description: "Whether the passwords of the users are encrypted.", Schema: openApiBoolean},
+	{Name: "usersSecret", In: "query", Description: "The name of the Docker secret with the users.", Schema: openApiString},
+	{Name: "version", In: "query", Description: "The version of the service the request is applied to. Mismatches are rejected with the status 412.", Schema: openApiString},
+	{Name: "If-Match", In: "header", Description: "The version of the service the request is applied to. Used when the version query is not specified.", Schema: openApiString},
+}
+
+var openApiRemoveParams = []openApiParameter{
+	{Name: "serviceName", In: "query", Required: true, Description: "The name of the service.", Schema: openApiString},
+	{Name: "aclName", In: "query", Description: "The name of the ACL of the service.", Schema: openApiString},
+	{Name: "distribute", In: "query", Description: "Whether to distribute the request to all the instances of the proxy.", Schema: openApiBoolean},
+	{Name: "drainFirst", In: "query", Description: "Whether to drain the servers of the service before it is removed.", Schema: openApiBoolean},
+	{Name: "keepState", In: "query", Description: "Whether to keep the version, parameters, and state of the service.", Schema: openApiBoolean},
+	{Name: "namespace", In: "query", Description: "The namespace of the service.", Schema: openApiString},
+	{Name: "removeCerts", In: "query", Description: "Whether to remove the certificates of the service.", Schema: openApiBoolean},
+}
+
+var openApiCertParams = []openApiParameter{
+	{Name: "certName", In: "query", Required: true, Description: "The name of the certificate file.", Schema: openApiString},
+	{Name: "distribute", In: "query", Description: "Whether to distribute the request to all the instances of the proxy.", Schema: openApiBoolean},
+}
+
+var openApiString = map[string]string{"type": "string"}
+var openApiBoolean = map[string]string{"type": "boolean"}
+
+// openApi outputs the OpenAPI 3 document that describes the API.
+func (m *Serve) openApi(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	js, _ := json.MarshalIndent(getOpenApiDocument(), "", "  ")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// getOpenApiDocument returns the OpenAPI 3 document of the reconfigure, remove, cert, certs, and config endpoints.
+// The parameters of the reconfigure request are derived from the fields of proxy.Service and proxy.ServiceDest.
+func getOpenApiDocument() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	jsonResponse := func(description string, value interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"description": description,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": getOpenApiSchema(reflect.TypeOf(value), schemas)},
+			},
+		}
+	}
+	reconfigureParams := getOpenApiServiceParams()
+	reconfigureParams = append(reconfigureParams, openApiReconfigureParams...)
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]string{
+			"title":   "Docker Flow Proxy",
+			"version": "v1",
+		},
+		"paths": map[string]interface{}{
+			"/v1/docker-flow-proxy/reconfigure": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "reconfigure",
+					"summary":     "Adds or updates a service.",
+					"parameters":  reconfigureParams,
+					"responses": map[string]interface{}{
+						"200": jsonResponse("The service was reconfigured.", server.Response{}),
+						"400": jsonResponse("The parameters are invalid.", server.Response{}),
+						"412": jsonResponse("The version does not match the current version of the service.", server.Response{}),
+						"500": jsonResponse("The proxy could not be reconfigured.", server.Response{}),
+					},
+				},
+			},
+			"/v1/docker-flow-proxy/remove": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "remove",
+					"summary":     "Removes a service.",
+					"parameters":  openApiRemoveParams,
+					"responses": map[string]interface{}{
+						"200": jsonResponse("The service was removed.", server.Response{}),
+						"400": jsonResponse("The serviceName query is missing.", server.Response{}),
+					},
+				},
+			},
+			"/v1/docker-flow-proxy/cert": map[string]interface{}{
+				"put": map[string]interface{}{
+					"operationId": "putCert",
+					"summary":     "Adds or replaces a certificate.",
+					"parameters":  openApiCertParams,
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content": map[string]interface{}{
+							"text/plain": map[string]interface{}{"schema": openApiString},
+						},
+					},
+					"responses": map[string]interface{}{
+						"200": jsonResponse("The certificate was stored.", server.CertResponse{}),
+					},
+				},
+			},
+			"/v1/docker-flow-proxy/certs": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "getCerts",
+					"summary":     "Outputs the certificates.",
+					"responses": map[string]interface{}{
+						"200": jsonResponse("The certificates.", server.CertResponse{}),
+					},
+				},
+			},
+			"/v1/docker-flow-proxy/config": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "getConfig",
+					"summary":     "Outputs the configuration of the proxy.",
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{
+							"description": "The configuration.",
+							"content": map[string]interface{}{
+								"text/html": map[string]interface{}{"schema": openApiString},
+							},
+						},
+					},
+				},
+			},
+		},
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+// getOpenApiServiceParams returns the reconfigure parameters of the fields of proxy.ServiceDest and proxy.Service sorted by their names.
+func getOpenApiServiceParams() []openApiParameter {
+	params := map[string]openApiParameter{}
+	for _, t := range []reflect.Type{reflect.TypeOf(proxy.ServiceDest{}), reflect.TypeOf(proxy.Service{})} {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := proxy.GetParamName(field)
+			if len(name) == 0 {
+				continue
+			}
+			param := openApiParameter{Name: name, In: "query", Schema: getOpenApiParamSchema(field.Type)}
+			if name == "serviceName" {
+				param.Required = true
+			}
+			params[name] = param
+		}
+	}
+	names := []string{}
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sorted := []openApiParameter{}
+	for _, name := range names {
+		sorted = append(sorted, params[name])
+	}
+	return sorted
+}
+
+// getOpenApiParamSchema returns the schema of a query parameter. Lists are sent as comma separated strings.
+func getOpenApiParamSchema(t reflect.Type) map[string]string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return openApiBoolean
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]string{"type": "integer"}
+	}
+	return openApiString
+}
+
+// getOpenApiSchema returns the schema of the JSON representation of the type.
+// Structs are added to the schemas and referenced by their names.
+func getOpenApiSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return getOpenApiSchema(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": getOpenApiSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": getOpenApiSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, found := schemas[t.Name()]; found {
+			return ref
+		}
+		// Registered before the fields so that recursive types reference themselves
+		schemas[t.Name()] = map[string]interface{}{}
+		properties := map[string]interface{}{}
+		addOpenApiProperties(t, properties, schemas)
+		schemas[t.Name()] = map[string]interface{}{"type": "object", "properties": properties}
+		return ref
+	}
+	return map[string]interface{}{}
+}
+
+// addOpenApiProperties adds the JSON properties of the fields of the struct.
+// The fields of embedded structs are flattened the same way encoding/json does it and do not replace the fields of the struct.
+func addOpenApiProperties(t reflect.Type, properties map[string]interface{}, schemas map[string]interface{}) {
+	embedded := []reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && len(name) == 0 && field.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, field.Type)
+			continue
+		}
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		properties[name] = getOpenApiSchema(field.Type, schemas)
+	}
+	for _, e := range embedded {
+		embeddedProperties := map[string]interface{}{}
+		addOpenApiProperties(e, embeddedProperties, schemas)
+		for name, schema := range embeddedProperties {
+			if _, found := properties[name]; !found {
+				properties[name] = schema
+			}
+		}
+	}
+}
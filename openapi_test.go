@@ -0,0 +1,84 @@
+// +build !integration
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type OpenApiTestSuite struct {
+	suite.Suite
+}
+
+func TestOpenApiUnitTestSuite(t *testing.T) {
+	logPrintfOrig := logPrintf
+	defer func() { logPrintf = logPrintfOrig }()
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(OpenApiTestSuite))
+}
+
+// ServeHTTP > OpenApi
+
+func (s *OpenApiTestSuite) Test_ServeHTTP_ReturnsOpenApiDocument_WhenUrlIsOpenApi() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/openapi.json", nil)
+	rw := httptest.NewRecorder()
+	srv := Serve{}
+
+	srv.ServeHTTP(rw, req)
+
+	actual := map[string]interface{}{}
+	s.NoError(json.Unmarshal(rw.Body.Bytes(), &actual))
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal("3.0.0", actual["openapi"])
+	paths := actual["paths"].(map[string]interface{})
+	for _, path := range []string{"reconfigure", "remove", "cert", "certs", "config"} {
+		s.Contains(paths, "/v1/docker-flow-proxy/"+path)
+	}
+}
+
+// getOpenApiServiceParams
+
+func (s *OpenApiTestSuite) Test_GetOpenApiServiceParams_ReturnsParamsOfServiceFields() {
+	params := map[string]openApiParameter{}
+	for _, param := range getOpenApiServiceParams() {
+		params[param.Name] = param
+	}
+
+	s.Equal(openApiParameter{Name: "serviceName", In: "query", Required: true, Schema: openApiString}, params["serviceName"])
+	s.Equal(map[string]string{"type": "integer"}, params["srcPort"].Schema)
+	s.Equal(openApiBoolean, params["httpsOnly"].Schema)
+	s.Equal(openApiString, params["servicePath"].Schema)
+	s.Equal(openApiString, params["serviceDomain"].Schema)
+	s.Contains(params, "port")
+	s.Contains(params, "loggingEnabled")
+	s.Contains(params, "timeoutServer")
+	for _, internal := range []string{"loggingDisabled", "serviceDest", "tasks", "srcPortAcl", "serviceCerts"} {
+		s.NotContains(params, internal)
+	}
+}
+
+func (s *OpenApiTestSuite) Test_GetOpenApiServiceParams_ReturnsParamsSortedByName() {
+	params := getOpenApiServiceParams()
+
+	for i := 1; i < len(params); i++ {
+		s.True(params[i-1].Name < params[i].Name, "%s should be before %s", params[i-1].Name, params[i].Name)
+	}
+}
+
+// getOpenApiDocument
+
+func (s *OpenApiTestSuite) Test_GetOpenApiDocument_FlattensEmbeddedServiceIntoResponseSchema() {
+	doc := getOpenApiDocument()
+
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	properties := schemas["Response"].(map[string]interface{})["properties"].(map[string]interface{})
+	s.Contains(properties, "ConfigIssues")
+	s.Contains(properties, "HttpsOnly")
+	s.Equal(map[string]interface{}{"$ref": "#/components/schemas/ServiceDest"}, properties["ServiceDest"].(map[string]interface{})["items"])
+	s.Contains(schemas, "ServiceDest")
+}
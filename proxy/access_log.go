@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// The address HAProxy sends the access logs to when LOG_METRICS is enabled or a log shipper is configured
+var AccessLogAddress = "127.0.0.1:5140"
+
+// Matches the client, the time, the frontend, the backend, the server, the total time, the status code,
+// and the number of bytes of a line in the HAProxy HTTP log format
+// (e.g. "10.0.0.1:5555 [14/Oct/2026:12:00:00.123] services go-demo-be8080/go-demo_1 0/0/1/5/6 200 123 ...").
+var httpLogRegexp = regexp.MustCompile(`(\S+) \[([^\]]+)\] (\S+) ([^\s/]+)/(\S+) -?\d+/-?\d+/-?\d+/-?\d+/\+?(-?\d+) (-?\d+) \+?(\d+) `)
+
+// Matches the request line quoted at the end of a line in the HAProxy HTTP log format
+var httpLogRequestRegexp = regexp.MustCompile(`"([^"]*)"\s*$`)
+
+// IsAccessLogEnabled returns whether HAProxy sends the access logs to the proxy process.
+func IsAccessLogEnabled() bool {
+	return IsLogMetricsEnabled() || len(GetLogShippers()) > 0
+}
+
+// HttpLogEntry is a request parsed from the HAProxy access logs.
+type HttpLogEntry struct {
+	// The time the request was received.
+	Time time.Time
+	// The address (<ip>:<port>) of the client.
+	Client string
+	// The name of the frontend.
+	Frontend string
+	// The name of the backend.
+	Backend string
+	// The name of the server the request was sent to.
+	Server string
+	// The status code of the response.
+	Status string
+	// The total time of the request in milliseconds. It is negative when the request was aborted.
+	TotalTime int
+	// The number of bytes sent to the client.
+	Bytes int
+	// The request line (e.g. GET /demo HTTP/1.1).
+	Request string `json:",omitempty"`
+}
+
+// ParseHttpLog returns the request logged in the line and whether the line is in the HTTP log format.
+func ParseHttpLog(line string) (HttpLogEntry, bool) {
+	matches := httpLogRegexp.FindStringSubmatch(line)
+	if matches == nil {
+		return HttpLogEntry{}, false
+	}
+	entry := HttpLogEntry{
+		Client:   matches[1],
+		Frontend: matches[3],
+		Backend:  matches[4],
+		Server:   matches[5],
+		Status:   matches[7],
+	}
+	entry.Time, _ = time.ParseInLocation("02/Jan/2006:15:04:05.000", matches[2], time.Local)
+	entry.TotalTime, _ = strconv.Atoi(matches[6])
+	entry.Bytes, _ = strconv.Atoi(matches[8])
+	if request := httpLogRequestRegexp.FindStringSubmatch(line); request != nil {
+		entry.Request = request[1]
+	}
+	return entry, true
+}
+
+// ListenAccessLogs receives the access logs sent by HAProxy over syslog (UDP) until the connection fails.
+// Each request is passed to all the handlers. Lines in other formats are ignored.
+func ListenAccessLogs(address string, handlers ...func(entry HttpLogEntry)) error {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return fmt.Errorf("Could not listen for logs on %s\n%s", address, err.Error())
+	}
+	defer conn.Close()
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return fmt.Errorf("Could not receive logs on %s\n%s", address, err.Error())
+		}
+		if entry, ok := ParseHttpLog(string(buf[:n])); ok {
+			for _, handle := range handlers {
+				handle(entry)
+			}
+		}
+	}
+}
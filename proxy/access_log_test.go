@@ -0,0 +1,43 @@
+// +build !integration
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AccessLogTestSuite struct {
+	suite.Suite
+}
+
+func TestAccessLogUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(AccessLogTestSuite))
+}
+
+// ParseHttpLog
+
+func (s AccessLogTestSuite) Test_ParseHttpLog_ReturnsRequest() {
+	actual, ok := ParseHttpLog(`<134>Oct 14 12:00:00 haproxy[12]: 10.0.0.1:5555 [14/Oct/2026:12:00:00.123] services~ go-demo-be8080/go-demo_1 0/0/1/5/+60 404 123 - - ---- 1/1/0/0/0 0/0 {acme.com} "GET /demo HTTP/1.1"`)
+
+	s.True(ok)
+	s.Equal(HttpLogEntry{
+		Time:      time.Date(2026, 10, 14, 12, 0, 0, 123000000, time.Local),
+		Client:    "10.0.0.1:5555",
+		Frontend:  "services~",
+		Backend:   "go-demo-be8080",
+		Server:    "go-demo_1",
+		Status:    "404",
+		TotalTime: 60,
+		Bytes:     123,
+		Request:   "GET /demo HTTP/1.1",
+	}, actual)
+}
+
+func (s AccessLogTestSuite) Test_ParseHttpLog_ReturnsFalse_WhenLineIsNotInHttpLogFormat() {
+	_, ok := ParseHttpLog(`<133>Oct 14 12:00:00 haproxy[12]: Proxy services started.`)
+
+	s.False(ok)
+}
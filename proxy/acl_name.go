@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GetAclName returns the ACL name of the service or, if it is not set, the name of the service.
+func GetAclName(s Service) string {
+	if len(s.AclName) == 0 {
+		return s.ServiceName
+	}
+	return s.AclName
+}
+
+// GetAclNameCollision returns the name of another service that uses the same ACL name as the service sr.
+// HAProxy merges ACLs with the same name so the requests of both services would match the routes of each other.
+func GetAclNameCollision(sr Service, services map[string]Service) string {
+	aclName := GetAclName(sr)
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		existing := services[name]
+		if existing.ServiceName != sr.ServiceName && GetAclName(existing) == aclName {
+			return existing.ServiceName
+		}
+	}
+	return ""
+}
+
+// GetUniqueAclName returns the ACL name of the service suffixed with a hash of its name and domains.
+// The same service always gets the same name so that reconfiguring it again does not change its ACLs.
+func GetUniqueAclName(sr Service) string {
+	hash := sha1.Sum([]byte(sr.ServiceName + "," + strings.Join(sr.ServiceDomain, ",")))
+	return fmt.Sprintf("%s_%x", GetAclName(sr), hash[:4])
+}
@@ -0,0 +1,52 @@
+// +build !integration
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AclNameTestSuite struct {
+	suite.Suite
+}
+
+func TestAclNameUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(AclNameTestSuite))
+}
+
+// GetAclNameCollision
+
+func (s AclNameTestSuite) Test_GetAclNameCollision_ReturnsServiceWithTheSameAclName() {
+	services := map[string]Service{
+		"first-service":  {ServiceName: "first-service", AclName: "api"},
+		"second-service": {ServiceName: "second-service"},
+	}
+
+	s.Equal("first-service", GetAclNameCollision(Service{ServiceName: "my-service", AclName: "api"}, services))
+	s.Equal("second-service", GetAclNameCollision(Service{ServiceName: "my-service", AclName: "second-service"}, services))
+}
+
+func (s AclNameTestSuite) Test_GetAclNameCollision_ReturnsEmptyString_WhenThereIsNoCollision() {
+	services := map[string]Service{
+		"my-service":    {ServiceName: "my-service", AclName: "api"},
+		"other-service": {ServiceName: "other-service", AclName: "other"},
+	}
+
+	s.Empty(GetAclNameCollision(Service{ServiceName: "my-service", AclName: "api"}, services))
+	s.Empty(GetAclNameCollision(Service{ServiceName: "new-service"}, services))
+}
+
+// GetUniqueAclName
+
+func (s AclNameTestSuite) Test_GetUniqueAclName_SuffixesAclNameWithHash() {
+	sr := Service{ServiceName: "my-service", AclName: "api", ServiceDomain: []string{"acme.com"}}
+
+	actual := GetUniqueAclName(sr)
+
+	s.Regexp("^api_[0-9a-f]{8}$", actual)
+	s.Equal(actual, GetUniqueAclName(sr))
+	s.NotEqual(actual, GetUniqueAclName(Service{ServiceName: "other-service", AclName: "api", ServiceDomain: []string{"acme.com"}}))
+	s.NotEqual(actual, GetUniqueAclName(Service{ServiceName: "my-service", AclName: "api", ServiceDomain: []string{"example.com"}}))
+}
@@ -0,0 +1,31 @@
+package proxy
+
+import (
+	"strings"
+)
+
+// The path prefix of the ACME HTTP-01 challenges
+const AcmeChallengePath = "/.well-known/acme-challenge/"
+
+// getAcmeChallengeServer returns the address of the server that answers ACME HTTP-01 challenges.
+// It is ACME_CHALLENGE_ADDRESS or, when only ACME_CHALLENGE_PATH is set, the API of the proxy that serves the challenges from that directory.
+// The challenges are not routed if the result is empty.
+func getAcmeChallengeServer() string {
+	if address := GetSecretOrEnvVar("ACME_CHALLENGE_ADDRESS", ""); len(address) > 0 {
+		if !strings.Contains(address, ":") {
+			address += ":80"
+		}
+		return address
+	} else if len(GetSecretOrEnvVar("ACME_CHALLENGE_PATH", "")) > 0 {
+		return getApiAddress()
+	}
+	return ""
+}
+
+// getAcmeChallengeCondition excludes the ACME challenges from the redirects of the services so that they are answered over http.
+func getAcmeChallengeCondition() string {
+	if len(getAcmeChallengeServer()) > 0 {
+		return " !acme_challenge"
+	}
+	return ""
+}
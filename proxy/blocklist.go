@@ -0,0 +1,234 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Blocklist contains the clients whose requests are denied.
+type Blocklist struct {
+	// The IP addresses or CIDR ranges of the clients.
+	Ips []string
+	// The substrings of the User-Agent headers of the clients (e.g. sqlmap or Nikto).
+	UserAgents []string
+}
+
+// Blocklists contains the entries added to the blocklists at runtime.
+type Blocklists struct {
+	// The entries applied to the requests of all the services.
+	Global Blocklist
+	// The entries applied to the requests of a single service keyed by the service names.
+	Services map[string]Blocklist
+}
+
+var blocklistMu = &sync.Mutex{}
+
+// The entries added at runtime keyed by the service names. The entries of the global blocklist are stored with an empty name.
+var blocklists = map[string]Blocklist{}
+
+// IsBlocklistEnabled returns whether the requests of all the services are checked against the global blocklist.
+func IsBlocklistEnabled() bool {
+	return strings.EqualFold(GetSecretOrEnvVar("BLOCKLIST", "false"), "true")
+}
+
+// HasBlocklist returns whether the requests of the service are checked against its own blocklist.
+func (s Service) HasBlocklist() bool {
+	return s.Blocklist || len(s.BlocklistIpsPath) > 0 || len(s.BlocklistUserAgentsPath) > 0
+}
+
+// GetBlocklists returns the entries added to the blocklists at runtime.
+func GetBlocklists() Blocklists {
+	blocklistMu.Lock()
+	defer blocklistMu.Unlock()
+	lists := Blocklists{Services: map[string]Blocklist{}}
+	for name, list := range blocklists {
+		if len(name) == 0 {
+			lists.Global = list
+		} else {
+			lists.Services[name] = list
+		}
+	}
+	return lists
+}
+
+// ValidateBlocklist returns an error if any of the IPs is not an IP address or a CIDR range
+// or any of the User-Agents is empty or contains whitespace.
+func ValidateBlocklist(list Blocklist) error {
+	for _, ip := range list.Ips {
+		if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+			return fmt.Errorf("%s is not an IP address or a CIDR range", ip)
+		}
+	}
+	for _, userAgent := range list.UserAgents {
+		if len(userAgent) == 0 || strings.ContainsAny(userAgent, " \t\n\r") {
+			return fmt.Errorf("%q is not a User-Agent without whitespace", userAgent)
+		}
+	}
+	return nil
+}
+
+// AddBlocklistEntries adds the entries to the blocklist of the service or, if the name is empty, to the global blocklist
+// without reloading the proxy. The map files are updated through the stats socket.
+func AddBlocklistEntries(configsPath, serviceName string, entries Blocklist) error {
+	return updateBlocklist(configsPath, serviceName, entries, true)
+}
+
+// RemoveBlocklistEntries removes the entries from the blocklist of the service or, if the name is empty, from the global blocklist
+// without reloading the proxy. The entries of the mounted files are removed only until the next reload.
+func RemoveBlocklistEntries(configsPath, serviceName string, entries Blocklist) error {
+	return updateBlocklist(configsPath, serviceName, entries, false)
+}
+
+func updateBlocklist(configsPath, serviceName string, entries Blocklist, add bool) error {
+	if err := ValidateBlocklist(entries); err != nil {
+		return err
+	}
+	blocklistMu.Lock()
+	defer blocklistMu.Unlock()
+	list := blocklists[serviceName]
+	list.Ips = updateBlocklistEntries(list.Ips, entries.Ips, add)
+	list.UserAgents = updateBlocklistEntries(list.UserAgents, entries.UserAgents, add)
+	if len(list.Ips) == 0 && len(list.UserAgents) == 0 {
+		delete(blocklists, serviceName)
+	} else {
+		blocklists[serviceName] = list
+	}
+	values := []struct {
+		mapPath string
+		entries []string
+	}{
+		{getBlocklistMapPath(configsPath, serviceName, "ips"), entries.Ips},
+		{getBlocklistMapPath(configsPath, serviceName, "user-agents"), entries.UserAgents},
+	}
+	for _, v := range values {
+		for _, entry := range v.entries {
+			command := fmt.Sprintf("del map %s %s", v.mapPath, entry)
+			if add {
+				command = fmt.Sprintf("add map %s %s 1", v.mapPath, entry)
+			}
+			if _, err := sendSocketCommand(command); err != nil {
+				return fmt.Errorf("Could not update the map %s\n%s", v.mapPath, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func updateBlocklistEntries(current, entries []string, add bool) []string {
+	updated := []string{}
+	for _, entry := range current {
+		if !isOneOf(entry, entries) {
+			updated = append(updated, entry)
+		}
+	}
+	if add {
+		updated = append(updated, entries...)
+		sort.Strings(updated)
+	}
+	if len(updated) == 0 {
+		return nil
+	}
+	return updated
+}
+
+// getBlocklistMapPath returns the path of the map with the ips or user-agents of the blocklist of the service.
+func getBlocklistMapPath(configsPath, serviceName, kind string) string {
+	if len(serviceName) == 0 {
+		return fmt.Sprintf("%s/blocklist-%s.map", configsPath, kind)
+	}
+	return fmt.Sprintf("%s/blocklist-%s-%s.map", configsPath, kind, GetIdentifier(serviceName))
+}
+
+// getBlocklistTemplate returns the rules that deny the requests of the clients in the blocklist of the service.
+// The service needs to be rendered with its own url ACLs and conditions.
+func getBlocklistTemplate(configsPath string, s Service) string {
+	return fmt.Sprintf(`{{range .ServiceDest}}
+    http-request deny deny_status 403 if { src,map_ip(%s) -m found } url_{{$.AclName}}_{{.Port}}{{$.AclCondition}}{{.SrcPortAclName}}
+    http-request deny deny_status 403 if { req.hdr(user-agent),map_sub(%s) -m found } url_{{$.AclName}}_{{.Port}}{{$.AclCondition}}{{.SrcPortAclName}}{{end}}`,
+		getBlocklistMapPath(configsPath, s.ServiceName, "ips"),
+		getBlocklistMapPath(configsPath, s.ServiceName, "user-agents"),
+	)
+}
+
+// getGlobalBlocklistTemplate returns the rules that drop the connections and deny the requests of the clients in the global blocklist.
+func getGlobalBlocklistTemplate(configsPath string) string {
+	return fmt.Sprintf(`
+    tcp-request connection reject if { src,map_ip(%s) -m found }
+    http-request deny deny_status 403 if { req.hdr(user-agent),map_sub(%s) -m found }`,
+		getBlocklistMapPath(configsPath, "", "ips"),
+		getBlocklistMapPath(configsPath, "", "user-agents"),
+	)
+}
+
+// writeBlocklistMaps writes the entries of the mounted files and those added at runtime to the maps of the global blocklist,
+// if it is enabled, and of the blocklists of the services. HAProxy fails to start if the maps referenced by the rules do not exist.
+func writeBlocklistMaps(configsPath string, services map[string]Service) error {
+	blocklistMu.Lock()
+	defer blocklistMu.Unlock()
+	if IsBlocklistEnabled() {
+		ipsPath := ResolveDockerPath(GetSecretOrEnvVar("BLOCKLIST_IPS_PATH", ""))
+		userAgentsPath := ResolveDockerPath(GetSecretOrEnvVar("BLOCKLIST_USER_AGENTS_PATH", ""))
+		if err := writeBlocklistMap(configsPath, "", ipsPath, userAgentsPath); err != nil {
+			return err
+		}
+	}
+	for _, s := range services {
+		if !s.HasBlocklist() {
+			continue
+		}
+		if err := writeBlocklistMap(configsPath, s.ServiceName, ResolveDockerPath(s.BlocklistIpsPath), ResolveDockerPath(s.BlocklistUserAgentsPath)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBlocklistMap writes the maps of a single blocklist. The caller must hold blocklistMu.
+func writeBlocklistMap(configsPath, serviceName, ipsPath, userAgentsPath string) error {
+	values := []struct {
+		kind        string
+		mountedPath string
+		entries     []string
+	}{
+		{"ips", ipsPath, blocklists[serviceName].Ips},
+		{"user-agents", userAgentsPath, blocklists[serviceName].UserAgents},
+	}
+	for _, v := range values {
+		entries := append([]string{}, v.entries...)
+		if len(v.mountedPath) > 0 {
+			mounted, err := readBlocklistFile(v.mountedPath)
+			if err != nil {
+				return err
+			}
+			entries = append(mounted, entries...)
+		}
+		lines := []string{}
+		for _, entry := range entries {
+			lines = append(lines, entry+" 1\n")
+		}
+		if err := writeFile(getBlocklistMapPath(configsPath, serviceName, v.kind), []byte(strings.Join(lines, "")), 0664); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readBlocklistFile returns the entries of a mounted blocklist with one entry per line.
+// Empty lines and lines starting with # are ignored.
+func readBlocklistFile(path string) ([]string, error) {
+	content, err := ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the blocklist %s\n%s", path, err.Error())
+	}
+	entries := []string{}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) > 0 && !strings.HasPrefix(line, "#") {
+			entries = append(entries, line)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,143 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type BlocklistTestSuite struct {
+	suite.Suite
+}
+
+func TestBlocklistUnitTestSuite(t *testing.T) {
+	writeFileOrig := writeFile
+	defer func() { writeFile = writeFileOrig }()
+	sendSocketCommandOrig := sendSocketCommand
+	defer func() { sendSocketCommand = sendSocketCommandOrig }()
+	readFileOrig := ReadFile
+	defer func() { ReadFile = readFileOrig }()
+	suite.Run(t, new(BlocklistTestSuite))
+}
+
+func (s *BlocklistTestSuite) SetupTest() {
+	blocklists = map[string]Blocklist{}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		return nil
+	}
+	sendSocketCommand = func(command string) (string, error) {
+		return "", nil
+	}
+}
+
+// AddBlocklistEntries
+
+func (s BlocklistTestSuite) Test_AddBlocklistEntries_UpdatesMapsThroughSocket() {
+	actualCommands := []string{}
+	sendSocketCommand = func(command string) (string, error) {
+		actualCommands = append(actualCommands, command)
+		return "", nil
+	}
+
+	err := AddBlocklistEntries("/cfg", "my-service", Blocklist{Ips: []string{"10.0.0.0/8"}, UserAgents: []string{"sqlmap", "Nikto"}})
+
+	s.NoError(err)
+	s.Equal([]string{
+		"add map /cfg/blocklist-ips-my-service.map 10.0.0.0/8 1",
+		"add map /cfg/blocklist-user-agents-my-service.map sqlmap 1",
+		"add map /cfg/blocklist-user-agents-my-service.map Nikto 1",
+	}, actualCommands)
+}
+
+func (s BlocklistTestSuite) Test_AddBlocklistEntries_StoresEntriesWithoutDuplicates() {
+	AddBlocklistEntries("/cfg", "", Blocklist{Ips: []string{"1.2.3.4", "10.0.0.0/8"}})
+	AddBlocklistEntries("/cfg", "", Blocklist{Ips: []string{"1.2.3.4"}, UserAgents: []string{"masscan"}})
+
+	s.Equal(Blocklists{
+		Global:   Blocklist{Ips: []string{"1.2.3.4", "10.0.0.0/8"}, UserAgents: []string{"masscan"}},
+		Services: map[string]Blocklist{},
+	}, GetBlocklists())
+}
+
+func (s BlocklistTestSuite) Test_AddBlocklistEntries_ReturnsError_WhenEntriesAreInvalid() {
+	s.Error(AddBlocklistEntries("/cfg", "", Blocklist{Ips: []string{"10.0.0"}}))
+	s.Error(AddBlocklistEntries("/cfg", "", Blocklist{UserAgents: []string{"Mozilla/5.0 (compatible)"}}))
+	s.Empty(blocklists)
+}
+
+func (s BlocklistTestSuite) Test_AddBlocklistEntries_ReturnsError_WhenSocketCommandFails() {
+	sendSocketCommand = func(command string) (string, error) {
+		return "", fmt.Errorf("This is an error")
+	}
+
+	s.Error(AddBlocklistEntries("/cfg", "", Blocklist{Ips: []string{"1.2.3.4"}}))
+}
+
+// RemoveBlocklistEntries
+
+func (s BlocklistTestSuite) Test_RemoveBlocklistEntries_RemovesEntriesFromMaps() {
+	actualCommands := []string{}
+	sendSocketCommand = func(command string) (string, error) {
+		actualCommands = append(actualCommands, command)
+		return "", nil
+	}
+	blocklists["my-service"] = Blocklist{Ips: []string{"1.2.3.4"}, UserAgents: []string{"sqlmap"}}
+
+	RemoveBlocklistEntries("/cfg", "my-service", Blocklist{Ips: []string{"1.2.3.4"}})
+
+	s.Equal([]string{"del map /cfg/blocklist-ips-my-service.map 1.2.3.4"}, actualCommands)
+	s.Equal(map[string]Blocklist{"my-service": {UserAgents: []string{"sqlmap"}}}, GetBlocklists().Services)
+}
+
+// writeBlocklistMaps
+
+func (s BlocklistTestSuite) Test_WriteBlocklistMaps_WritesMountedAndAddedEntries() {
+	defer func() {
+		os.Unsetenv("BLOCKLIST")
+		os.Unsetenv("BLOCKLIST_IPS_PATH")
+	}()
+	os.Setenv("BLOCKLIST", "true")
+	os.Setenv("BLOCKLIST_IPS_PATH", "/blocklists/ips")
+	ReadFile = func(filename string) ([]byte, error) {
+		if filename == "/blocklists/ips" {
+			return []byte("# Scanners\n192.0.2.0/24\n\n198.51.100.7\n"), nil
+		}
+		return []byte("zgrab\n"), nil
+	}
+	actualMaps := map[string]string{}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualMaps[filename] = string(data)
+		return nil
+	}
+	blocklists[""] = Blocklist{Ips: []string{"1.2.3.4"}}
+	blocklists["my-service"] = Blocklist{UserAgents: []string{"sqlmap"}}
+
+	err := writeBlocklistMaps("/cfg", map[string]Service{
+		"my-service":    {ServiceName: "my-service", BlocklistUserAgentsPath: "/blocklists/user-agents"},
+		"other-service": {ServiceName: "other-service"},
+	})
+
+	s.NoError(err)
+	s.Equal(map[string]string{
+		"/cfg/blocklist-ips.map":                    "192.0.2.0/24 1\n198.51.100.7 1\n1.2.3.4 1\n",
+		"/cfg/blocklist-user-agents.map":            "",
+		"/cfg/blocklist-ips-my-service.map":         "",
+		"/cfg/blocklist-user-agents-my-service.map": "zgrab 1\nsqlmap 1\n",
+	}, actualMaps)
+}
+
+func (s BlocklistTestSuite) Test_WriteBlocklistMaps_ReturnsError_WhenMountedFileCannotBeRead() {
+	ReadFile = func(filename string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+
+	err := writeBlocklistMaps("/cfg", map[string]Service{
+		"my-service": {ServiceName: "my-service", BlocklistIpsPath: "/blocklists/ips"},
+	})
+
+	s.Error(err)
+}
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The length of the captured values. Longer values are truncated.
+const captureLength = 128
+
+var captureMu = &sync.Mutex{}
+var captureDisabled = map[string]bool{}
+
+// IsCaptureEnabled returns whether the headers and cookies of the service are captured.
+func IsCaptureEnabled(serviceName string) bool {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	return !captureDisabled[serviceName]
+}
+
+// SetCaptureEnabled enables or disables the capture of the headers and cookies of the service without reloading the proxy.
+// The services with disabled capture are stored in a map file that is updated through the stats socket.
+func SetCaptureEnabled(configsPath, serviceName string, enabled bool) error {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	if enabled {
+		delete(captureDisabled, serviceName)
+	} else {
+		captureDisabled[serviceName] = true
+	}
+	mapPath := getCaptureMapPath(configsPath)
+	if err := writeCaptureMap(mapPath); err != nil {
+		return err
+	}
+	command := fmt.Sprintf("del map %s %s", mapPath, GetIdentifier(serviceName))
+	if !enabled {
+		command = fmt.Sprintf("add map %s %s 1", mapPath, GetIdentifier(serviceName))
+	}
+	if _, err := sendSocketCommand(command); err != nil {
+		return fmt.Errorf("Could not update the map %s\n%s", mapPath, err.Error())
+	}
+	return nil
+}
+
+func getCaptureMapPath(configsPath string) string {
+	return fmt.Sprintf("%s/capture-disabled.map", configsPath)
+}
+
+// writeCaptureMap writes the services with disabled capture. The caller must hold captureMu.
+func writeCaptureMap(mapPath string) error {
+	names := []string{}
+	for name := range captureDisabled {
+		names = append(names, GetIdentifier(name)+" 1\n")
+	}
+	sort.Strings(names)
+	return writeFile(mapPath, []byte(strings.Join(names, "")), 0664)
+}
@@ -0,0 +1,65 @@
+// +build !integration
+
+package proxy
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CaptureTestSuite struct {
+	suite.Suite
+}
+
+func TestCaptureUnitTestSuite(t *testing.T) {
+	writeFileOrig := writeFile
+	defer func() { writeFile = writeFileOrig }()
+	sendSocketCommandOrig := sendSocketCommand
+	defer func() { sendSocketCommand = sendSocketCommandOrig }()
+	suite.Run(t, new(CaptureTestSuite))
+}
+
+func (s *CaptureTestSuite) SetupTest() {
+	captureDisabled = map[string]bool{}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		return nil
+	}
+}
+
+// SetCaptureEnabled
+
+func (s CaptureTestSuite) Test_SetCaptureEnabled_AddsServiceToMap_WhenDisabled() {
+	actualMap := ""
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualMap = string(data)
+		return nil
+	}
+	actualCommand := ""
+	sendSocketCommand = func(command string) (string, error) {
+		actualCommand = command
+		return "", nil
+	}
+
+	err := SetCaptureEnabled("/cfg", "my-service", false)
+
+	s.NoError(err)
+	s.False(IsCaptureEnabled("my-service"))
+	s.Equal("my-service 1\n", actualMap)
+	s.Equal("add map /cfg/capture-disabled.map my-service 1", actualCommand)
+}
+
+func (s CaptureTestSuite) Test_SetCaptureEnabled_RemovesServiceFromMap_WhenEnabled() {
+	captureDisabled["my-service"] = true
+	actualCommand := ""
+	sendSocketCommand = func(command string) (string, error) {
+		actualCommand = command
+		return "", nil
+	}
+
+	SetCaptureEnabled("/cfg", "my-service", true)
+
+	s.True(IsCaptureEnabled("my-service"))
+	s.Equal("del map /cfg/capture-disabled.map my-service", actualCommand)
+}
@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// getDefaultCertPaths returns the paths of the certificates with the default certificate first.
+// HAProxy serves the first certificate of a bind to clients whose SNI does not match any of the certificates.
+// The default certificate is defined through the defaultCertName global setting (with or without the extension).
+// If it is not set, the certificates are left in the alphabetical order of the directories they were read from.
+func getDefaultCertPaths(paths []string) []string {
+	name := getGlobal("defaultCertName")
+	if len(name) == 0 {
+		return paths
+	}
+	for i, path := range paths {
+		base := filepath.Base(path)
+		if base == name || strings.TrimSuffix(base, filepath.Ext(base)) == name {
+			ordered := []string{path}
+			ordered = append(ordered, paths[:i]...)
+			return append(ordered, paths[i+1:]...)
+		}
+	}
+	if len(paths) > 0 {
+		logWarnf("The default certificate %s could not be found. The certificate %s is used instead.", name, paths[0])
+	}
+	return paths
+}
+
+// isStrictSni returns whether the TLS handshake of clients whose SNI does not match any of the certificates is refused.
+func isStrictSni() bool {
+	return strings.EqualFold(getGlobal("strictSni"), "true")
+}
@@ -0,0 +1,58 @@
+// +build !integration
+
+package proxy
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CertsTestSuite struct {
+	suite.Suite
+}
+
+func TestCertsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(CertsTestSuite))
+}
+
+// getDefaultCertPaths
+
+func (s CertsTestSuite) Test_GetDefaultCertPaths_ReturnsPathsUnchanged_WhenDefaultCertNameIsNotSet() {
+	paths := []string{"/certs/a.pem", "/certs/b.pem"}
+
+	s.Equal(paths, getDefaultCertPaths(paths))
+}
+
+func (s CertsTestSuite) Test_GetDefaultCertPaths_PutsDefaultCertFirst() {
+	defer func() { os.Unsetenv("DEFAULT_CERT_NAME") }()
+	paths := []string{"/certs/a.pem", "/certs/b.pem", "/run/secrets/cert-c"}
+
+	os.Setenv("DEFAULT_CERT_NAME", "b")
+	s.Equal([]string{"/certs/b.pem", "/certs/a.pem", "/run/secrets/cert-c"}, getDefaultCertPaths(paths))
+
+	os.Setenv("DEFAULT_CERT_NAME", "cert-c")
+	s.Equal([]string{"/run/secrets/cert-c", "/certs/a.pem", "/certs/b.pem"}, getDefaultCertPaths(paths))
+	s.Equal([]string{"/certs/a.pem", "/certs/b.pem", "/run/secrets/cert-c"}, paths)
+}
+
+func (s CertsTestSuite) Test_GetDefaultCertPaths_UsesGlobalOverride() {
+	defer ResetGlobals(map[string]string{})
+	SetGlobals(map[string]string{"defaultCertName": "b.pem"})
+
+	s.Equal([]string{"/certs/b.pem", "/certs/a.pem"}, getDefaultCertPaths([]string{"/certs/a.pem", "/certs/b.pem"}))
+}
+
+func (s CertsTestSuite) Test_GetDefaultCertPaths_ReturnsPathsUnchanged_WhenDefaultCertDoesNotExist() {
+	defer func() { os.Unsetenv("DEFAULT_CERT_NAME") }()
+	logWarnfOrig := logWarnf
+	defer func() { logWarnf = logWarnfOrig }()
+	warnings := 0
+	logWarnf = func(format string, a ...interface{}) { warnings++ }
+	os.Setenv("DEFAULT_CERT_NAME", "missing")
+	paths := []string{"/certs/a.pem", "/certs/b.pem"}
+
+	s.Equal(paths, getDefaultCertPaths(paths))
+	s.Equal(1, warnings)
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"sort"
+	"strings"
+)
+
+// Conflict describes a route of an existing service that shadows, or is shadowed by, a route of another service.
+type Conflict struct {
+	ServiceName string
+	Domain      string
+	Path        string
+	SrcPort     int
+}
+
+// GetConflicts returns the routes of the services that overlap with the routes of the service sr.
+// Routes overlap when they share a domain (or at least one of them is not restricted to a domain),
+// the source port, and one of the paths is a prefix of the other.
+// Only services in the http request mode are compared.
+func GetConflicts(sr Service, services map[string]Service) []Conflict {
+	var conflicts []Conflict
+	if !isHttpMode(sr) {
+		return conflicts
+	}
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		existing := services[name]
+		if strings.EqualFold(existing.ServiceName, sr.ServiceName) || !isHttpMode(existing) {
+			continue
+		}
+		domain, overlap := getOverlappingDomain(sr, existing)
+		if !overlap {
+			continue
+		}
+		for _, esd := range existing.ServiceDest {
+			for _, existingPath := range esd.ServicePath {
+				if routeOverlaps(sr, esd.SrcPort, existingPath, existing.PathType) {
+					conflicts = append(conflicts, Conflict{
+						ServiceName: existing.ServiceName,
+						Domain:      domain,
+						Path:        existingPath,
+						SrcPort:     esd.SrcPort,
+					})
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+func routeOverlaps(sr Service, srcPort int, path, pathType string) bool {
+	for _, sd := range sr.ServiceDest {
+		if sd.SrcPort > 0 && srcPort > 0 && sd.SrcPort != srcPort {
+			continue
+		}
+		for _, srPath := range sd.ServicePath {
+			if pathsOverlap(srPath, sr.PathType, path, pathType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isHttpMode(s Service) bool {
+	return len(s.ReqMode) == 0 || strings.EqualFold(s.ReqMode, "http")
+}
+
+func getOverlappingDomain(s1, s2 Service) (string, bool) {
+	if len(s1.ServiceDomain) == 0 && len(s2.ServiceDomain) == 0 {
+		return "", true
+	} else if len(s1.ServiceDomain) == 0 {
+		return strings.Join(s2.ServiceDomain, ","), true
+	} else if len(s2.ServiceDomain) == 0 {
+		return strings.Join(s1.ServiceDomain, ","), true
+	}
+	for _, d1 := range s1.ServiceDomain {
+		for _, d2 := range s2.ServiceDomain {
+			if domainsOverlap(d1, s1.ServiceDomainMatchAll, d2, s2.ServiceDomainMatchAll) {
+				return d2, true
+			}
+		}
+	}
+	return "", false
+}
+
+func domainsOverlap(d1 string, matchAll1 bool, d2 string, matchAll2 bool) bool {
+	wildcard1 := matchAll1 || strings.HasPrefix(d1, "*")
+	wildcard2 := matchAll2 || strings.HasPrefix(d2, "*")
+	d1 = strings.ToLower(strings.TrimLeft(d1, "*"))
+	d2 = strings.ToLower(strings.TrimLeft(d2, "*"))
+	return d1 == d2 ||
+		(wildcard1 && strings.HasSuffix(d2, d1)) ||
+		(wildcard2 && strings.HasSuffix(d1, d2))
+}
+
+func pathsOverlap(path1, pathType1, path2, pathType2 string) bool {
+	isBeg := func(pathType string) bool {
+		return len(pathType) == 0 || strings.EqualFold(pathType, "path_beg")
+	}
+	if isBeg(pathType1) && isBeg(pathType2) {
+		return strings.HasPrefix(path1, path2) || strings.HasPrefix(path2, path1)
+	}
+	return path1 == path2
+}
@@ -0,0 +1,69 @@
+// +build !integration
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ConflictTestSuite struct {
+	suite.Suite
+	existing map[string]Service
+}
+
+func (s *ConflictTestSuite) SetupTest() {
+	s.existing = map[string]Service{
+		"my-service": {
+			ServiceName:   "my-service",
+			ServiceDomain: []string{"my-domain.com"},
+			ServiceDest:   []ServiceDest{{ServicePath: []string{"/api"}, SrcPort: 1234}},
+		},
+	}
+}
+
+func TestConflictUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ConflictTestSuite))
+}
+
+// GetConflicts
+
+func (s ConflictTestSuite) Test_GetConflicts_ReturnsConflict_WhenPathIsShadowed() {
+	sr := Service{
+		ServiceName:   "new-service",
+		ServiceDomain: []string{"MY-DOMAIN.com"},
+		ServiceDest:   []ServiceDest{{ServicePath: []string{"/api/v2"}}},
+	}
+
+	actual := GetConflicts(sr, s.existing)
+
+	s.Equal([]Conflict{{ServiceName: "my-service", Domain: "my-domain.com", Path: "/api", SrcPort: 1234}}, actual)
+}
+
+func (s ConflictTestSuite) Test_GetConflicts_ReturnsConflict_WhenPathShadowsExistingPath() {
+	sr := Service{
+		ServiceName:   "new-service",
+		ServiceDomain: []string{"*domain.com"},
+		ServiceDest:   []ServiceDest{{ServicePath: []string{"/"}, SrcPort: 1234}},
+	}
+
+	actual := GetConflicts(sr, s.existing)
+
+	s.Len(actual, 1)
+}
+
+func (s ConflictTestSuite) Test_GetConflicts_ReturnsNil_WhenRoutesDoNotOverlap() {
+	data := []Service{
+		{ServiceName: "new-service", ServiceDomain: []string{"other-domain.com"}, ServiceDest: []ServiceDest{{ServicePath: []string{"/api"}}}},
+		{ServiceName: "new-service", ServiceDest: []ServiceDest{{ServicePath: []string{"/other"}}}},
+		{ServiceName: "new-service", ServiceDest: []ServiceDest{{ServicePath: []string{"/api"}, SrcPort: 4321}}},
+		{ServiceName: "new-service", PathType: "path_reg", ServiceDest: []ServiceDest{{ServicePath: []string{"/api/v2"}}}},
+		{ServiceName: "new-service", ReqMode: "tcp", ServiceDest: []ServiceDest{{ServicePath: []string{"/api"}}}},
+		{ServiceName: "my-service", ServiceDest: []ServiceDest{{ServicePath: []string{"/api"}}}},
+	}
+
+	for _, sr := range data {
+		s.Nil(GetConflicts(sr, s.existing))
+	}
+}
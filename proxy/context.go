@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// GetTimeout returns the timeout defined in seconds through the environment variable or the secret.
+// The default is used when the value is not a positive number.
+func GetTimeout(name string, defaultSeconds int) time.Duration {
+	seconds, err := strconv.Atoi(GetSecretOrEnvVar(name, ""))
+	if err != nil || seconds <= 0 {
+		seconds = defaultSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// LookupHost resolves the host through the lookup function.
+// It gives up when the context is done or the lookup takes longer than LOOKUP_TIMEOUT so that a hung DNS server does not block the caller.
+func LookupHost(ctx context.Context, lookup func(host string) ([]string, error), host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, GetTimeout("LOOKUP_TIMEOUT", 5))
+	defer cancel()
+	type result struct {
+		addresses []string
+		err       error
+	}
+	c := make(chan result, 1)
+	go func() {
+		addresses, err := lookup(host)
+		c <- result{addresses, err}
+	}()
+	select {
+	case r := <-c:
+		return r.addresses, r.err
+	case <-ctx.Done():
+		return nil, lookupError{
+			msg:     fmt.Sprintf("Could not resolve %s\n%s", host, ctx.Err().Error()),
+			timeout: ctx.Err() == context.DeadlineExceeded,
+		}
+	}
+}
+
+// lookupError is returned when LookupHost gives up. It implements net.Error so that callers can tell timeouts apart.
+type lookupError struct {
+	msg     string
+	timeout bool
+}
+
+func (e lookupError) Error() string   { return e.msg }
+func (e lookupError) Timeout() bool   { return e.timeout }
+func (e lookupError) Temporary() bool { return e.timeout }
@@ -0,0 +1,90 @@
+// +build !integration
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ContextTestSuite struct {
+	suite.Suite
+}
+
+func TestContextUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ContextTestSuite))
+}
+
+// GetTimeout
+
+func (s *ContextTestSuite) Test_GetTimeout_ReturnsDefault_WhenVariableIsNotSet() {
+	s.Equal(5*time.Second, GetTimeout("MY_TIMEOUT", 5))
+}
+
+func (s *ContextTestSuite) Test_GetTimeout_ReturnsVariable() {
+	defer os.Unsetenv("MY_TIMEOUT")
+	os.Setenv("MY_TIMEOUT", "30")
+
+	s.Equal(30*time.Second, GetTimeout("MY_TIMEOUT", 5))
+}
+
+func (s *ContextTestSuite) Test_GetTimeout_ReturnsDefault_WhenVariableIsNotPositive() {
+	defer os.Unsetenv("MY_TIMEOUT")
+	for _, value := range []string{"0", "-1", "abc"} {
+		os.Setenv("MY_TIMEOUT", value)
+
+		s.Equal(5*time.Second, GetTimeout("MY_TIMEOUT", 5), value)
+	}
+}
+
+// LookupHost
+
+func (s *ContextTestSuite) Test_LookupHost_ReturnsResultOfTheLookup() {
+	lookup := func(host string) ([]string, error) {
+		return []string{"1.2.3.4"}, fmt.Errorf("This is an error of %s", host)
+	}
+
+	actual, err := LookupHost(context.Background(), lookup, "my-host")
+
+	s.Equal([]string{"1.2.3.4"}, actual)
+	s.EqualError(err, "This is an error of my-host")
+}
+
+func (s *ContextTestSuite) Test_LookupHost_ReturnsError_WhenContextIsDoneFirst() {
+	done := make(chan struct{})
+	defer close(done)
+	lookup := func(host string) ([]string, error) {
+		<-done
+		return []string{"1.2.3.4"}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := LookupHost(ctx, lookup, "my-host")
+
+	s.EqualError(err, "Could not resolve my-host\ncontext canceled")
+}
+
+func (s *ContextTestSuite) Test_LookupHost_ReturnsTimeoutError_WhenLookupTakesTooLong() {
+	done := make(chan struct{})
+	defer close(done)
+	lookup := func(host string) ([]string, error) {
+		<-done
+		return []string{"1.2.3.4"}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	_, err := LookupHost(ctx, lookup, "my-host")
+
+	s.Error(err)
+	netErr, ok := err.(net.Error)
+	s.True(ok)
+	s.True(netErr.Timeout())
+}
@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ControllerOptions defines the proxy managed by a controller.
+type ControllerOptions struct {
+	// The engine (haproxy, nginx, or envoy). Defaults to haproxy.
+	Engine string
+	// The directory with the template of the engine (e.g. haproxy.tmpl).
+	TemplatesPath string
+	// The directory the configuration is written to.
+	ConfigsPath string
+	// Whether Apply and Remove should only write the configuration without reloading the proxy.
+	DryRun bool
+}
+
+// Controller keeps the configuration of a proxy in sync with its services.
+// It has its own services and does not depend on the HTTP server so it can be embedded into other applications.
+// Only the swarm mode is supported, meaning that the servers of a service are its hosts or tasks.
+type Controller struct {
+	mu       *sync.Mutex
+	proxy    Proxy
+	services *Data
+	dryRun   bool
+}
+
+// NewController returns a controller of the proxy with the engine defined in the options.
+func NewController(options ControllerOptions) (*Controller, error) {
+	services := &Data{Services: map[string]Service{}}
+	var p Proxy
+	switch strings.ToLower(options.Engine) {
+	case "", "haproxy":
+		p = HaProxy{TemplatesPath: options.TemplatesPath, ConfigsPath: options.ConfigsPath, services: services}
+	case "nginx":
+		p = Nginx{TemplatesPath: options.TemplatesPath, ConfigsPath: options.ConfigsPath, services: services}
+	case "envoy":
+		p = Envoy{ConfigsPath: options.ConfigsPath, services: services}
+	default:
+		return nil, fmt.Errorf("The proxy engine %s is not supported. The engine must be haproxy, nginx, or envoy.", options.Engine)
+	}
+	return &Controller{mu: &sync.Mutex{}, proxy: p, services: services, dryRun: options.DryRun}, nil
+}
+
+// Proxy returns the proxy managed by the controller.
+func (m *Controller) Proxy() Proxy {
+	return m.proxy
+}
+
+// Start writes the configuration and starts the proxy.
+func (m *Controller) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := m.proxy.CreateConfigFromTemplates(); err != nil {
+		return err
+	}
+	return m.proxy.RunCmd([]string{})
+}
+
+// Apply adds the services, or replaces those with the same names, and reloads the proxy.
+// Nothing is changed if any of the services is invalid.
+// If the configuration cannot be applied, the previous services are restored.
+func (m *Controller) Apply(ctx context.Context, services ...Service) error {
+	for _, s := range services {
+		if len(s.ServiceName) == 0 {
+			return fmt.Errorf("The name of the service is mandatory")
+		}
+		if errs := ValidateService(s); len(errs) > 0 {
+			messages := []string{}
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+			return fmt.Errorf("The service %s is not valid\n%s", s.ServiceName, strings.Join(messages, "\n"))
+		}
+	}
+	return m.update(ctx, func() {
+		// The services are stored at once since each change copies all the services of the snapshot
+		formatted := []Service{}
+		for _, s := range services {
+			if len(s.ReqMode) == 0 {
+				s.ReqMode = "http"
+			}
+			formatService(&s)
+			formatted = append(formatted, s)
+		}
+		m.services.Put(formatted...)
+	})
+}
+
+// Remove removes the services with the names and reloads the proxy.
+// If the configuration cannot be applied, the previous services are restored.
+func (m *Controller) Remove(ctx context.Context, serviceNames ...string) error {
+	return m.update(ctx, func() {
+		m.services.Delete(serviceNames...)
+	})
+}
+
+// Services returns the services of the proxy sorted by name.
+func (m *Controller) Services() []Service {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	services := m.services.Snapshot()
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sorted := []Service{}
+	for _, name := range names {
+		sorted = append(sorted, services[name])
+	}
+	return sorted
+}
+
+// Subscribe returns a channel that receives the services after each change and a function that ends the subscription.
+// A change that is rolled back is followed by the restored services.
+func (m *Controller) Subscribe() (<-chan map[string]Service, func()) {
+	return m.services.Subscribe()
+}
+
+// Render returns the configuration generated from the services without applying it.
+func (m *Controller) Render() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.proxy.RenderConfig()
+}
+
+func (m *Controller) update(ctx context.Context, change func()) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	previous := m.services.Snapshot()
+	change()
+	err := m.proxy.CreateConfigFromTemplates()
+	if err == nil {
+		if err = ctx.Err(); err == nil && !m.dryRun {
+			err = m.proxy.Reload()
+		}
+	}
+	if err != nil {
+		m.services.Replace(previous)
+		m.proxy.CreateConfigFromTemplates()
+	}
+	return err
+}
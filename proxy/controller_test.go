@@ -0,0 +1,225 @@
+// +build !integration
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ControllerTestSuite struct {
+	suite.Suite
+	controller *Controller
+	written    map[string]string
+	commands   [][]string
+}
+
+func (s *ControllerTestSuite) SetupTest() {
+	s.controller, _ = NewController(ControllerOptions{TemplatesPath: "test_configs/tmpl", ConfigsPath: "/my/cfg"})
+	s.written = map[string]string{}
+	s.commands = [][]string{}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		s.written[filename] = string(data)
+		return nil
+	}
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		s.commands = append(s.commands, cmd.Args)
+		return nil
+	}
+	readPidFile = func(fileName string) ([]byte, error) {
+		return []byte("123"), nil
+	}
+	ReadDir = func(dirname string) ([]os.FileInfo, error) {
+		return []os.FileInfo{}, nil
+	}
+}
+
+func TestControllerUnitTestSuite(t *testing.T) {
+	logPrintfOrig := logPrintf
+	defer func() { logPrintf = logPrintfOrig }()
+	logPrintf = func(format string, v ...interface{}) {}
+	writeFileOrig := writeFile
+	defer func() { writeFile = writeFileOrig }()
+	cmdRunHaOrig := cmdRunHa
+	defer func() { cmdRunHa = cmdRunHaOrig }()
+	readPidFileOrig := readPidFile
+	defer func() { readPidFile = readPidFileOrig }()
+	readDirOrig := ReadDir
+	defer func() { ReadDir = readDirOrig }()
+	suite.Run(t, new(ControllerTestSuite))
+}
+
+// NewController
+
+func (s *ControllerTestSuite) Test_NewController_ReturnsProxyOfTheEngine() {
+	for engine, expected := range map[string]interface{}{"": HaProxy{}, "haproxy": HaProxy{}, "nginx": Nginx{}, "envoy": Envoy{}} {
+		c, err := NewController(ControllerOptions{Engine: engine})
+
+		s.NoError(err)
+		s.IsType(expected, c.Proxy())
+	}
+}
+
+func (s *ControllerTestSuite) Test_NewController_ReturnsError_WhenEngineIsNotSupported() {
+	_, err := NewController(ControllerOptions{Engine: "traefik"})
+
+	s.Error(err)
+}
+
+func (s *ControllerTestSuite) Test_NewController_DoesNotShareServices() {
+	other, _ := NewController(ControllerOptions{TemplatesPath: "test_configs/tmpl", ConfigsPath: "/my/cfg"})
+
+	s.controller.Apply(context.Background(), Service{ServiceName: "my-service", ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/"}}}})
+
+	s.Len(s.controller.Services(), 1)
+	s.Empty(other.Services())
+	s.Empty(data.Services)
+}
+
+// Start
+
+func (s *ControllerTestSuite) Test_Start_WritesConfigAndRunsProxy() {
+	err := s.controller.Start(context.Background())
+
+	s.NoError(err)
+	s.Contains(s.written, "/my/cfg/haproxy.cfg")
+	s.Equal([][]string{{"haproxy", "-f", "/my/cfg/haproxy.cfg", "-D", "-p", "/var/run/haproxy.pid"}}, s.commands)
+}
+
+func (s *ControllerTestSuite) Test_Start_ReturnsError_WhenContextIsCanceled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.controller.Start(ctx)
+
+	s.Equal(context.Canceled, err)
+	s.Empty(s.commands)
+}
+
+// Apply
+
+func (s *ControllerTestSuite) Test_Apply_RendersFrontendAndBackendAndReloads() {
+	err := s.controller.Apply(context.Background(), Service{
+		ServiceName: "my-service",
+		ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/api"}}},
+	})
+
+	s.NoError(err)
+	actual := s.written["/my/cfg/haproxy.cfg"]
+	s.Contains(actual, "use_backend my-service-be8080 if url_my-service_8080")
+	s.Contains(actual, `backend my-service-be8080
+    mode http
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }
+    server my-service my-service:8080`)
+	s.NotContains(actual, "dummy-be")
+	s.Equal([]string{"haproxy", "-f", "/my/cfg/haproxy.cfg", "-D", "-p", "/var/run/haproxy.pid", "-sf", "123"}, s.commands[0])
+	s.Equal("http", s.controller.Services()[0].ReqMode)
+}
+
+func (s *ControllerTestSuite) Test_Apply_StoresServicesWithASingleChange() {
+	changes, unsubscribe := s.controller.Subscribe()
+	defer unsubscribe()
+
+	s.controller.Apply(context.Background(), Service{ServiceName: "my-service", ServiceDest: []ServiceDest{{Port: "8080"}}}, Service{ServiceName: "other-service", ServiceDest: []ServiceDest{{Port: "8080"}}})
+
+	// Subscribers that fall behind receive only the latest snapshot so the first one is the result of the single change
+	s.Len(<-changes, 2)
+	s.Len(s.controller.Services(), 2)
+}
+
+func (s *ControllerTestSuite) Test_Apply_DoesNotReload_WhenDryRun() {
+	c, _ := NewController(ControllerOptions{TemplatesPath: "test_configs/tmpl", ConfigsPath: "/my/cfg", DryRun: true})
+
+	err := c.Apply(context.Background(), Service{ServiceName: "my-service", ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/api"}}}})
+
+	s.NoError(err)
+	s.Contains(s.written["/my/cfg/haproxy.cfg"], "backend my-service-be8080")
+	s.Empty(s.commands)
+}
+
+func (s *ControllerTestSuite) Test_Apply_ReturnsError_WhenServiceIsNotValid() {
+	err := s.controller.Apply(
+		context.Background(),
+		Service{ServiceName: "my-service", ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/"}}}},
+		Service{ServiceName: "other-service", ReqMode: "udp", ServiceDest: []ServiceDest{{Port: "8080"}}},
+	)
+
+	s.Error(err)
+	s.Contains(err.Error(), "reqMode")
+	s.Empty(s.controller.Services())
+	s.Empty(s.commands)
+}
+
+func (s *ControllerTestSuite) Test_Apply_ReturnsError_WhenServiceNameIsEmpty() {
+	err := s.controller.Apply(context.Background(), Service{})
+
+	s.Error(err)
+}
+
+func (s *ControllerTestSuite) Test_Apply_RestoresPreviousServices_WhenReloadFails() {
+	s.controller.Apply(context.Background(), Service{ServiceName: "my-service", ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/"}}}})
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		return fmt.Errorf("This is an error")
+	}
+
+	err := s.controller.Apply(context.Background(), Service{ServiceName: "other-service", ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/other"}}}})
+
+	s.Error(err)
+	actual := s.controller.Services()
+	s.Len(actual, 1)
+	s.Equal("my-service", actual[0].ServiceName)
+	s.NotContains(s.written["/my/cfg/haproxy.cfg"], "other-service")
+}
+
+func (s *ControllerTestSuite) Test_Apply_ReturnsError_WhenContextIsCanceled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.controller.Apply(ctx, Service{ServiceName: "my-service", ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/"}}}})
+
+	s.Equal(context.Canceled, err)
+	s.Empty(s.controller.Services())
+}
+
+// Remove
+
+func (s *ControllerTestSuite) Test_Remove_RemovesServicesAndReloads() {
+	s.controller.Apply(
+		context.Background(),
+		Service{ServiceName: "my-service", ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/"}}}},
+		Service{ServiceName: "other-service", ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/other"}}}},
+	)
+
+	err := s.controller.Remove(context.Background(), "my-service")
+
+	s.NoError(err)
+	s.Len(s.controller.Services(), 1)
+	s.NotContains(s.written["/my/cfg/haproxy.cfg"], "backend my-service-be8080")
+	s.Len(s.commands, 2)
+}
+
+// Subscribe
+
+func (s *ControllerTestSuite) Test_Subscribe_ReceivesServicesAfterApply() {
+	c, cancel := s.controller.Subscribe()
+	defer cancel()
+
+	s.controller.Apply(context.Background(), Service{ServiceName: "my-service", ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/"}}}})
+
+	s.Contains(<-c, "my-service")
+}
+
+// Render
+
+func (s *ControllerTestSuite) Test_Render_DoesNotWriteConfig() {
+	actual, err := s.controller.Render()
+
+	s.NoError(err)
+	s.Contains(actual, "dummy-be")
+	s.Empty(s.written)
+}
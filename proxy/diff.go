@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The number of unchanged lines shown around each change
+const diffContext = 3
+
+type diffLine struct {
+	// The type of the line: ' ' (unchanged), '-' (removed), or '+' (added).
+	kind byte
+	text string
+	// The indexes of the line in the old and the new content before the line is applied.
+	from, to int
+}
+
+// GetUnifiedDiff returns the differences between the old and the new content in the unified format.
+// An empty string is returned if the contents are the same.
+func GetUnifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	lines := getDiffLines(splitDiffLines(from), splitDiffLines(to))
+	diff := fmt.Sprintf("--- %s\n+++ %s\n", fromName, toName)
+	for i := 0; i < len(lines); {
+		if lines[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		// Changes separated by no more than twice the context are shown in the same hunk
+		for j := i + 1; j < len(lines) && j-end <= 2*diffContext+1; j++ {
+			if lines[j].kind != ' ' {
+				end = j
+			}
+		}
+		stop := end + diffContext + 1
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+		diff += getDiffHunk(lines[start:stop])
+		i = stop
+	}
+	return diff
+}
+
+func splitDiffLines(content string) []string {
+	if len(content) == 0 {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+func getDiffHunk(lines []diffLine) string {
+	fromCount, toCount := 0, 0
+	body := ""
+	for _, line := range lines {
+		if line.kind != '+' {
+			fromCount++
+		}
+		if line.kind != '-' {
+			toCount++
+		}
+		body += fmt.Sprintf("%c%s\n", line.kind, line.text)
+	}
+	return fmt.Sprintf(
+		"@@ -%s +%s @@\n%s",
+		getDiffRange(lines[0].from, fromCount),
+		getDiffRange(lines[0].to, toCount),
+		body,
+	)
+}
+
+func getDiffRange(index, count int) string {
+	if count == 0 {
+		// Empty ranges point to the line before the change
+		return fmt.Sprintf("%d,0", index)
+	} else if count == 1 {
+		return fmt.Sprintf("%d", index+1)
+	}
+	return fmt.Sprintf("%d,%d", index+1, count)
+}
+
+// getDiffLines returns the shortest edit script between the lines using the Myers algorithm.
+func getDiffLines(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2)
+	trace := [][]int{}
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		trace = append(trace, append([]int{}, v...))
+		for k := -d; k <= d; k += 2 {
+			x := 0
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	reversed := []diffLine{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[max+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, diffLine{kind: ' ', text: a[x-1], from: x - 1, to: y - 1})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, diffLine{kind: '+', text: b[y-1], from: x, to: y - 1})
+			} else {
+				reversed = append(reversed, diffLine{kind: '-', text: a[x-1], from: x - 1, to: y})
+			}
+			x, y = prevX, prevY
+		}
+	}
+	lines := []diffLine{}
+	for i := len(reversed) - 1; i >= 0; i-- {
+		lines = append(lines, reversed[i])
+	}
+	return lines
+}
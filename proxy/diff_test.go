@@ -0,0 +1,81 @@
+// +build !integration
+
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DiffTestSuite struct {
+	suite.Suite
+}
+
+func TestDiffUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(DiffTestSuite))
+}
+
+// GetUnifiedDiff
+
+func (s DiffTestSuite) Test_GetUnifiedDiff_ReturnsEmptyString_WhenContentsAreTheSame() {
+	s.Equal("", GetUnifiedDiff("a", "b", "line-1\nline-2\n", "line-1\nline-2\n"))
+}
+
+func (s DiffTestSuite) Test_GetUnifiedDiff_ReturnsChangesWithContext() {
+	from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	to := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n11\n"
+	expected := `--- current
++++ pending
+@@ -2,9 +2,10 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+ 9
+ 10
++11
+`
+
+	s.Equal(expected, GetUnifiedDiff("current", "pending", from, to))
+}
+
+func (s DiffTestSuite) Test_GetUnifiedDiff_SplitsHunks_WhenChangesAreFarApart() {
+	lines := []string{}
+	for i := 0; i < 20; i++ {
+		lines = append(lines, "line")
+	}
+	from := "first\n" + strings.Join(lines, "\n") + "\nlast\n"
+	to := strings.Join(lines, "\n") + "\n"
+	expected := `--- current
++++ pending
+@@ -1,4 +1,3 @@
+-first
+ line
+ line
+ line
+@@ -19,4 +18,3 @@
+ line
+ line
+ line
+-last
+`
+
+	s.Equal(expected, GetUnifiedDiff("current", "pending", from, to))
+}
+
+func (s DiffTestSuite) Test_GetUnifiedDiff_ReturnsAddedLines_WhenFromIsEmpty() {
+	expected := `--- current
++++ pending
+@@ -0,0 +1,2 @@
++line-1
++line-2
+`
+
+	s.Equal(expected, GetUnifiedDiff("current", "pending", "", "line-1\nline-2\n"))
+}
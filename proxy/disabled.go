@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DisabledServices tracks the services that were disabled through the API.
+// Disabled services stay configured but their requests are answered with the status 503 until they are enabled.
+type DisabledServices struct {
+	mu       *sync.Mutex
+	services map[string]bool
+	// The file the disabled services are persisted to. They are not persisted if it is empty.
+	path string
+}
+
+func NewDisabledServices() *DisabledServices {
+	return &DisabledServices{
+		mu:       &sync.Mutex{},
+		services: map[string]bool{},
+	}
+}
+
+// Load reads the services persisted to the file and persists the later changes to it
+// so that the disabled services stay disabled after restarts.
+func (m *DisabledServices) Load(path string) error {
+	m.mu.Lock()
+	m.path = path
+	m.mu.Unlock()
+	content, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	if err := json.Unmarshal(content, &names); err != nil {
+		return fmt.Errorf("Could not parse the disabled services file %s\n%s", path, err.Error())
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, name := range names {
+		m.services[name] = true
+	}
+	return nil
+}
+
+func (m *DisabledServices) Add(serviceName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.services[serviceName] = true
+	m.save()
+}
+
+func (m *DisabledServices) Delete(serviceName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.services[serviceName] {
+		delete(m.services, serviceName)
+		m.save()
+	}
+}
+
+func (m *DisabledServices) Contains(serviceName string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.services[serviceName]
+}
+
+// GetAll returns the names of the disabled services sorted alphabetically.
+func (m *DisabledServices) GetAll() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.getSorted()
+}
+
+func (m *DisabledServices) getSorted() []string {
+	names := []string{}
+	for name := range m.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *DisabledServices) save() {
+	if len(m.path) == 0 {
+		return
+	}
+	js, _ := json.Marshal(m.getSorted())
+	if err := writeFile(m.path, js, 0664); err != nil {
+		logPrintf("Could not write the disabled services file %s\n%s", m.path, err.Error())
+	}
+}
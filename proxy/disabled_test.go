@@ -0,0 +1,98 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DisabledTestSuite struct {
+	suite.Suite
+}
+
+func (s *DisabledTestSuite) SetupTest() {
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		return nil
+	}
+}
+
+func TestDisabledUnitTestSuite(t *testing.T) {
+	writeFileOrig := writeFile
+	defer func() { writeFile = writeFileOrig }()
+	readFileOrig := ReadFile
+	defer func() { ReadFile = readFileOrig }()
+	suite.Run(t, new(DisabledTestSuite))
+}
+
+// Load
+
+func (s *DisabledTestSuite) Test_Load_ReadsPersistedServicesAndPersistsChanges() {
+	actualFilename := ""
+	actualData := ""
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualFilename = filename
+		actualData = string(data)
+		return nil
+	}
+	ReadFile = func(filename string) ([]byte, error) {
+		return []byte(`["service-2"]`), nil
+	}
+	disabled := NewDisabledServices()
+
+	err := disabled.Load("/cfg/disabled-services.json")
+	disabled.Add("service-1")
+
+	s.NoError(err)
+	s.Equal([]string{"service-1", "service-2"}, disabled.GetAll())
+	s.Equal("/cfg/disabled-services.json", actualFilename)
+	s.Equal(`["service-1","service-2"]`, actualData)
+}
+
+func (s *DisabledTestSuite) Test_Load_ReturnsError_WhenFileIsNotJson() {
+	ReadFile = func(filename string) ([]byte, error) {
+		return []byte("service-1"), nil
+	}
+
+	err := NewDisabledServices().Load("/cfg/disabled-services.json")
+
+	s.Error(err)
+}
+
+func (s *DisabledTestSuite) Test_Load_PersistsChanges_WhenFileDoesNotExist() {
+	actualData := ""
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	ReadFile = func(filename string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+	disabled := NewDisabledServices()
+
+	disabled.Load("/cfg/disabled-services.json")
+	disabled.Add("service-1")
+	disabled.Add("service-2")
+	disabled.Delete("service-1")
+
+	s.Equal(`["service-2"]`, actualData)
+}
+
+// Add
+
+func (s *DisabledTestSuite) Test_Add_DoesNotPersistServices_WhenNotLoaded() {
+	invoked := false
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		invoked = true
+		return nil
+	}
+	disabled := NewDisabledServices()
+
+	disabled.Add("service-1")
+
+	s.True(disabled.Contains("service-1"))
+	s.False(invoked)
+}
@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const dockerConfigScheme = "docker-config://"
+const dockerSecretScheme = "docker-secret://"
+const dockerSecretsDir = "/run/secrets"
+
+// ResolveDockerPath returns the path of the file mounted from a Docker config (docker-config://<name>) or secret (docker-secret://<name>).
+// Configs are expected at their default target (/<name>) unless DOCKER_CONFIGS_PATH is set. Other paths are returned unchanged.
+func ResolveDockerPath(path string) string {
+	if strings.HasPrefix(path, dockerConfigScheme) {
+		dir := strings.TrimRight(GetSecretOrEnvVar("DOCKER_CONFIGS_PATH", ""), "/")
+		return fmt.Sprintf("%s/%s", dir, strings.TrimPrefix(path, dockerConfigScheme))
+	} else if strings.HasPrefix(path, dockerSecretScheme) {
+		return GetDockerSecretPath(strings.TrimPrefix(path, dockerSecretScheme))
+	}
+	return path
+}
+
+// GetDockerSecretPath returns the path the Docker secret is mounted to.
+func GetDockerSecretPath(name string) string {
+	return fmt.Sprintf("%s/%s", dockerSecretsDir, name)
+}
+
+// IsValidDockerSecretName returns whether the name refers to a file directly in the directory of the Docker secrets.
+func IsValidDockerSecretName(name string) bool {
+	return len(name) > 0 && !strings.ContainsAny(name, `/\`) && !strings.Contains(name, "..")
+}
+
+// IsDockerSecretPath returns whether the cleaned path is still located in the directory of the Docker secrets.
+func IsDockerSecretPath(path string) bool {
+	return strings.HasPrefix(filepath.Clean(path), dockerSecretsDir+"/")
+}
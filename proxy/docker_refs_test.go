@@ -0,0 +1,45 @@
+// +build !integration
+
+package proxy
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DockerRefsTestSuite struct {
+	suite.Suite
+}
+
+func (s *DockerRefsTestSuite) Test_ResolveDockerPath_ReturnsConfigPath() {
+	s.Equal("/my-snippet", ResolveDockerPath("docker-config://my-snippet"))
+}
+
+func (s *DockerRefsTestSuite) Test_ResolveDockerPath_UsesDockerConfigsPath() {
+	defer os.Unsetenv("DOCKER_CONFIGS_PATH")
+	os.Setenv("DOCKER_CONFIGS_PATH", "/configs/")
+
+	s.Equal("/configs/my-snippet", ResolveDockerPath("docker-config://my-snippet"))
+}
+
+func (s *DockerRefsTestSuite) Test_ResolveDockerPath_ReturnsSecretPath() {
+	s.Equal("/run/secrets/my-snippet", ResolveDockerPath("docker-secret://my-snippet"))
+}
+
+func (s *DockerRefsTestSuite) Test_ResolveDockerPath_ReturnsOtherPathsUnchanged() {
+	s.Equal("/tmpl/be.tmpl", ResolveDockerPath("/tmpl/be.tmpl"))
+}
+
+// IsDockerSecretPath
+
+func (s *DockerRefsTestSuite) Test_IsDockerSecretPath_ReturnsFalse_WhenPathLeavesSecrets() {
+	s.True(IsDockerSecretPath(GetDockerSecretPath("my_cert")))
+	s.False(IsDockerSecretPath(GetDockerSecretPath("../../etc/shadow")))
+	s.False(IsDockerSecretPath(GetDockerSecretPath("")))
+}
+
+func TestDockerRefsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(DockerRefsTestSuite))
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ServerStatePath is the server-state-file of haproxy.tmpl. The new HAProxy process loads the states of the servers
+// from it so that the servers drained or disabled through the socket keep their states after a reload.
+var ServerStatePath = "/var/run/haproxy-server-state"
+
+// saveServerState writes the states of the servers of the running HAProxy to ServerStatePath.
+func saveServerState() error {
+	out, err := sendSocketCommand("show servers state")
+	if err != nil {
+		return fmt.Errorf("Could not read the servers state from the socket %s\n%s", StatsSocketPath, err.Error())
+	}
+	if err := writeFile(ServerStatePath, []byte(out), 0664); err != nil {
+		return fmt.Errorf("Could not write the servers state to %s\n%s", ServerStatePath, err.Error())
+	}
+	return nil
+}
+
+// SetServerDrain drains the servers of the service with the address or, when drain is false, makes them ready again.
+// Draining servers finish their current sessions and stop receiving new ones while the rest of the backend is untouched.
+// It returns the names of the changed servers formatted as <backend>/<server>.
+func SetServerDrain(serviceName, address string, drain bool) ([]string, error) {
+	out, err := sendSocketCommand("show servers state")
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the servers state from the socket %s\n%s", StatsSocketPath, err.Error())
+	}
+	state := "ready"
+	if drain {
+		state = "drain"
+	}
+	servers := []string{}
+	for _, row := range parseServersState(out) {
+		if !isServiceBackend(row["be_name"], serviceName) || row["srv_addr"] != address {
+			continue
+		}
+		server := row["be_name"] + "/" + row["srv_name"]
+		if _, err := sendSocketCommand(fmt.Sprintf("set server %s state %s", server, state)); err != nil {
+			return servers, fmt.Errorf("Could not set the state of the server %s to %s\n%s", server, state, err.Error())
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+// parseServersState converts the output of the "show servers state" command into rows keyed by the column names.
+// The output starts with the version of the format followed by the header prefixed with #.
+func parseServersState(out string) []map[string]string {
+	rows := []map[string]string{}
+	header := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			header = strings.Fields(strings.TrimPrefix(line, "# "))
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(header) == 0 || len(fields) == 0 {
+			continue
+		}
+		row := map[string]string{}
+		for i, value := range fields {
+			if i < len(header) {
+				row[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
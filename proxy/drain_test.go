@@ -0,0 +1,106 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DrainTestSuite struct {
+	suite.Suite
+	commands []string
+}
+
+func TestDrainUnitTestSuite(t *testing.T) {
+	sendSocketCommandOrig := sendSocketCommand
+	defer func() { sendSocketCommand = sendSocketCommandOrig }()
+	suite.Run(t, new(DrainTestSuite))
+}
+
+func (s *DrainTestSuite) SetupTest() {
+	s.commands = []string{}
+	sendSocketCommand = func(command string) (string, error) {
+		s.commands = append(s.commands, command)
+		if command == "show servers state" {
+			return `1
+# be_id be_name srv_id srv_name srv_addr srv_op_state srv_admin_state
+3 my-service-be8080 1 my-service_a 10.0.0.5 2 0
+3 my-service-be8080 2 my-service_b 10.0.0.6 2 0
+4 https-my-service-be8443 1 my-service_a 10.0.0.5 2 0
+5 my-service-api-be8080 1 my-service-api_a 10.0.0.5 2 0
+
+`, nil
+		}
+		return "", nil
+	}
+}
+
+// SetServerDrain
+
+func (s *DrainTestSuite) Test_SetServerDrain_DrainsServersOfTheServiceWithTheAddress() {
+	actual, err := SetServerDrain("my-service", "10.0.0.5", true)
+
+	s.NoError(err)
+	s.Equal([]string{"my-service-be8080/my-service_a", "https-my-service-be8443/my-service_a"}, actual)
+	s.Equal([]string{
+		"show servers state",
+		"set server my-service-be8080/my-service_a state drain",
+		"set server https-my-service-be8443/my-service_a state drain",
+	}, s.commands)
+}
+
+func (s *DrainTestSuite) Test_SetServerDrain_MakesServersReady_WhenDrainIsFalse() {
+	actual, err := SetServerDrain("my-service", "10.0.0.6", false)
+
+	s.NoError(err)
+	s.Equal([]string{"my-service-be8080/my-service_b"}, actual)
+	s.Equal("set server my-service-be8080/my-service_b state ready", s.commands[1])
+}
+
+func (s *DrainTestSuite) Test_SetServerDrain_ReturnsEmpty_WhenNoServerHasTheAddress() {
+	actual, err := SetServerDrain("my-service", "10.0.0.7", true)
+
+	s.NoError(err)
+	s.Empty(actual)
+	s.Len(s.commands, 1)
+}
+
+func (s *DrainTestSuite) Test_SetServerDrain_ReturnsError_WhenSocketFails() {
+	sendSocketCommand = func(command string) (string, error) {
+		return "", fmt.Errorf("This is an error")
+	}
+
+	_, err := SetServerDrain("my-service", "10.0.0.5", true)
+
+	s.Error(err)
+}
+
+// saveServerState
+
+func (s *DrainTestSuite) Test_saveServerState_WritesStateOfServersToServerStateFile() {
+	writeFileOrig := writeFile
+	defer func() { writeFile = writeFileOrig }()
+	actualPath, actualData := "", ""
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualPath, actualData = filename, string(data)
+		return nil
+	}
+
+	err := saveServerState()
+
+	s.NoError(err)
+	s.Equal(ServerStatePath, actualPath)
+	s.Contains(actualData, "3 my-service-be8080 1 my-service_a 10.0.0.5 2 0")
+}
+
+func (s *DrainTestSuite) Test_saveServerState_ReturnsError_WhenSocketFails() {
+	sendSocketCommand = func(command string) (string, error) {
+		return "", fmt.Errorf("This is an error")
+	}
+
+	s.Error(saveServerState())
+}
@@ -0,0 +1,367 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The type URLs of the xDS resources served to Envoy.
+const (
+	XdsClusterType  = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+	XdsRouteType    = "type.googleapis.com/envoy.config.route.v3.RouteConfiguration"
+	XdsListenerType = "type.googleapis.com/envoy.config.listener.v3.Listener"
+)
+
+// Envoy is an engine that does not run a proxy itself.
+// It translates the configured services into xDS resources that Envoy instances fetch from the proxy API.
+type Envoy struct {
+	ConfigsPath string
+	services    *Data
+}
+
+// XdsResource is a JSON representation of an Envoy resource.
+type XdsResource map[string]interface{}
+
+// XdsResources are the resources Envoy fetches through the cluster, route, and listener discovery services.
+type XdsResources struct {
+	Version   string
+	Clusters  []XdsResource
+	Routes    []XdsResource
+	Listeners []XdsResource
+}
+
+// GetResources returns the resources of the type URL. If names are specified, only the resources with those names are returned.
+func (m XdsResources) GetResources(typeUrl string, names []string) []XdsResource {
+	all := []XdsResource{}
+	switch typeUrl {
+	case XdsClusterType:
+		all = m.Clusters
+	case XdsRouteType:
+		all = m.Routes
+	case XdsListenerType:
+		all = m.Listeners
+	}
+	if len(names) == 0 {
+		return all
+	}
+	resources := []XdsResource{}
+	for _, r := range all {
+		for _, name := range names {
+			if r["name"] == name {
+				resources = append(resources, r)
+				break
+			}
+		}
+	}
+	return resources
+}
+
+type xdsRoute struct {
+	path      string
+	pathType  string
+	cluster   string
+	httpsOnly bool
+	// The server timeout in seconds of the destination or the service.
+	timeout string
+}
+
+// xdsRoutes are sorted in the order Envoy should match them. Envoy uses the first route that matches, so the regular
+// expressions come first and the longer prefixes come before the shorter ones they start with (e.g. /api before /).
+type xdsRoutes []xdsRoute
+
+func (slice xdsRoutes) Len() int {
+	return len(slice)
+}
+
+func (slice xdsRoutes) Less(i, j int) bool {
+	iRegex := strings.EqualFold(slice[i].pathType, "path_reg")
+	jRegex := strings.EqualFold(slice[j].pathType, "path_reg")
+	if iRegex || jRegex {
+		return iRegex && !jRegex
+	}
+	return len(slice[i].path) > len(slice[j].path)
+}
+
+func (slice xdsRoutes) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func NewEnvoy(configsPath string) Proxy {
+	data.Replace(map[string]Service{})
+	return Envoy{ConfigsPath: configsPath}
+}
+
+func (m Envoy) GetCertPaths() []string {
+	return HaProxy{}.GetCertPaths()
+}
+
+func (m Envoy) GetCerts() map[string]string {
+	return HaProxy{}.GetCerts()
+}
+
+// RunCmd does nothing since Envoy runs outside of the proxy and fetches the resources on its own.
+func (m Envoy) RunCmd(extraArgs []string) error {
+	return nil
+}
+
+// CreateConfigFromTemplates writes the resources to envoy.json so that they can be inspected through the config endpoint.
+func (m Envoy) CreateConfigFromTemplates() error {
+	content, err := m.RenderConfig()
+	if err != nil {
+		return err
+	}
+	configPath := fmt.Sprintf("%s/envoy.json", m.ConfigsPath)
+	if err := writeFile(configPath, []byte(content), 0664); err != nil {
+		return err
+	}
+	recordConfig()
+	return nil
+}
+
+func (m Envoy) ReadConfig() (string, error) {
+	configPath := fmt.Sprintf("%s/envoy.json", m.ConfigsPath)
+	out, err := ReadFile(configPath)
+	if err != nil {
+		return "", err
+	}
+	return string(out[:]), nil
+}
+
+// RenderConfig returns the resources generated from the current services.
+func (m Envoy) RenderConfig() (string, error) {
+	js, err := json.MarshalIndent(m.GetXdsResources(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(js) + "\n", nil
+}
+
+// Reload does not need to do anything since Envoy polls for the changes of the resources.
+func (m Envoy) Reload() error {
+	recordReload(timeNow(), nil)
+	return nil
+}
+
+func (m Envoy) AddService(service Service) {
+	getData(m.services).Put(service)
+}
+
+func (m Envoy) RemoveService(service string) {
+	getData(m.services).Delete(service)
+}
+
+func (m Envoy) GetServices() map[string]Service {
+	return copyServices(getData(m.services).Snapshot())
+}
+
+// GetXdsResources returns the resources of the services shared by the process.
+func GetXdsResources() XdsResources {
+	return Envoy{}.GetXdsResources()
+}
+
+// GetXdsResources translates the configured services into Envoy resources.
+// Services in the *http* request mode share a listener and a route configuration per source port (80 by default).
+// Services in the *tcp* request mode get a listener per source port.
+// The version is a hash of the resources so that it changes only when the resources change.
+func (m Envoy) GetXdsResources() XdsResources {
+	services := getData(m.services).Snapshot()
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	resources := XdsResources{Clusters: []XdsResource{}, Routes: []XdsResource{}, Listeners: []XdsResource{}}
+	httpPorts := []int{}
+	domains := map[int][]string{}
+	routes := map[int]map[string][]xdsRoute{}
+	clusters := map[string]bool{}
+	for _, name := range names {
+		s := services[name]
+		for _, sd := range s.ServiceDest {
+			port, err := strconv.Atoi(sd.Port)
+			if err != nil {
+				continue
+			}
+			cluster := fmt.Sprintf("%s-be%s", s.ServiceName, sd.Port)
+			if !clusters[cluster] {
+				clusters[cluster] = true
+				resources.Clusters = append(resources.Clusters, getXdsCluster(cluster, s, port))
+			}
+			if strings.EqualFold(s.ReqMode, "tcp") {
+				resources.Listeners = append(resources.Listeners, getXdsTcpListener(cluster, sd.SrcPort))
+				continue
+			}
+			srcPort := sd.SrcPort
+			if srcPort == 0 {
+				srcPort = 80
+			}
+			if _, ok := routes[srcPort]; !ok {
+				httpPorts = append(httpPorts, srcPort)
+				routes[srcPort] = map[string][]xdsRoute{}
+			}
+			serviceDomains := s.ServiceDomain
+			if len(serviceDomains) == 0 {
+				serviceDomains = []string{"*"}
+			}
+			for _, domain := range serviceDomains {
+				if _, ok := routes[srcPort][domain]; !ok {
+					domains[srcPort] = append(domains[srcPort], domain)
+				}
+				for _, path := range sd.ServicePath {
+					routes[srcPort][domain] = append(routes[srcPort][domain], xdsRoute{
+						path:      path,
+						pathType:  s.PathType,
+						cluster:   cluster,
+						httpsOnly: s.HttpsOnly,
+						timeout:   sd.GetTimeoutServer(s),
+					})
+				}
+			}
+		}
+	}
+	sort.Ints(httpPorts)
+	for _, port := range httpPorts {
+		name := fmt.Sprintf("http-%d", port)
+		resources.Routes = append(resources.Routes, getXdsRouteConfiguration(name, domains[port], routes[port]))
+		resources.Listeners = append(resources.Listeners, getXdsHttpListener(name, port))
+	}
+	js, _ := json.Marshal(resources)
+	resources.Version = fmt.Sprintf("%x", sha256.Sum256(js))[:16]
+	return resources
+}
+
+func getXdsCluster(name string, s Service, port int) XdsResource {
+	endpoints := []XdsResource{}
+	for _, host := range s.GetHosts() {
+		endpoints = append(endpoints, XdsResource{
+			"endpoint": XdsResource{
+				"address": getXdsAddress(host, port),
+			},
+		})
+	}
+	connectTimeout := getGlobal("timeoutConnect")
+	return XdsResource{
+		"@type":           XdsClusterType,
+		"name":            name,
+		"connect_timeout": connectTimeout + "s",
+		"type":            "STRICT_DNS",
+		"lb_policy":       "ROUND_ROBIN",
+		"load_assignment": XdsResource{
+			"cluster_name": name,
+			"endpoints":    []XdsResource{{"lb_endpoints": endpoints}},
+		},
+	}
+}
+
+func getXdsAddress(host string, port int) XdsResource {
+	if IsUnixSocket(host) {
+		return XdsResource{"pipe": XdsResource{"path": strings.TrimPrefix(host, "unix://")}}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+	return XdsResource{"socket_address": XdsResource{"address": host, "port_value": port}}
+}
+
+// getXdsRouteConfiguration returns a virtual host per domain. Routes of services without a domain match all domains.
+func getXdsRouteConfiguration(name string, domains []string, routes map[string][]xdsRoute) XdsResource {
+	sort.Strings(domains)
+	virtualHosts := []XdsResource{}
+	for _, domain := range domains {
+		domainRoutes := append(xdsRoutes{}, routes[domain]...)
+		if domain != "*" {
+			// Envoy picks a single virtual host so the routes that match all domains need to be part of each of them
+			domainRoutes = append(domainRoutes, routes["*"]...)
+		}
+		sort.Stable(domainRoutes)
+		vhRoutes := []XdsResource{}
+		for _, r := range domainRoutes {
+			match := XdsResource{"prefix": r.path}
+			if strings.EqualFold(r.pathType, "path_reg") {
+				match = XdsResource{"safe_regex": XdsResource{"regex": r.path}}
+			}
+			route := XdsResource{"match": match}
+			if r.httpsOnly {
+				route["redirect"] = XdsResource{"https_redirect": true}
+			} else {
+				target := XdsResource{"cluster": r.cluster}
+				if len(r.timeout) > 0 {
+					target["timeout"] = r.timeout + "s"
+				}
+				route["route"] = target
+			}
+			vhRoutes = append(vhRoutes, route)
+		}
+		virtualHosts = append(virtualHosts, XdsResource{
+			"name":    fmt.Sprintf("%s-%s", name, domain),
+			"domains": []string{domain},
+			"routes":  vhRoutes,
+		})
+	}
+	return XdsResource{
+		"@type":         XdsRouteType,
+		"name":          name,
+		"virtual_hosts": virtualHosts,
+	}
+}
+
+// getXdsHttpListener returns the listener that fetches its routes through RDS from the cluster defined through XDS_CLUSTER_NAME.
+func getXdsHttpListener(name string, port int) XdsResource {
+	return XdsResource{
+		"@type":   XdsListenerType,
+		"name":    name,
+		"address": getXdsAddress("0.0.0.0", port),
+		"filter_chains": []XdsResource{{
+			"filters": []XdsResource{{
+				"name": "envoy.filters.network.http_connection_manager",
+				"typed_config": XdsResource{
+					"@type":       "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+					"stat_prefix": name,
+					// Requests like Host: acme.com:443 or Host: acme.com. match the domains of the virtual hosts
+					"strip_any_host_port":     true,
+					"strip_trailing_host_dot": true,
+					"rds": XdsResource{
+						"route_config_name": name,
+						"config_source": XdsResource{
+							"resource_api_version": "V3",
+							"api_config_source": XdsResource{
+								"api_type":              "REST",
+								"transport_api_version": "V3",
+								"cluster_names":         []string{GetSecretOrEnvVar("XDS_CLUSTER_NAME", "xds_cluster")},
+								"refresh_delay":         "1s",
+							},
+						},
+					},
+					"http_filters": []XdsResource{{
+						"name":         "envoy.filters.http.router",
+						"typed_config": XdsResource{"@type": "type.googleapis.com/envoy.extensions.filters.http.router.v3.Router"},
+					}},
+				},
+			}},
+		}},
+	}
+}
+
+func getXdsTcpListener(cluster string, port int) XdsResource {
+	name := fmt.Sprintf("tcp-%d", port)
+	return XdsResource{
+		"@type":   XdsListenerType,
+		"name":    name,
+		"address": getXdsAddress("0.0.0.0", port),
+		"filter_chains": []XdsResource{{
+			"filters": []XdsResource{{
+				"name": "envoy.filters.network.tcp_proxy",
+				"typed_config": XdsResource{
+					"@type":       "type.googleapis.com/envoy.extensions.filters.network.tcp_proxy.v3.TcpProxy",
+					"stat_prefix": name,
+					"cluster":     cluster,
+				},
+			}},
+		}},
+	}
+}
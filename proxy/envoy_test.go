@@ -0,0 +1,239 @@
+// +build !integration
+
+package proxy
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EnvoyTestSuite struct {
+	suite.Suite
+	envoy   Envoy
+	written map[string]string
+}
+
+func (s *EnvoyTestSuite) SetupTest() {
+	s.envoy = NewEnvoy("/cfg").(Envoy)
+	s.written = map[string]string{}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		s.written[filename] = string(data)
+		return nil
+	}
+}
+
+func TestEnvoyUnitTestSuite(t *testing.T) {
+	writeFileOrig := writeFile
+	defer func() { writeFile = writeFileOrig }()
+	defer func() { data.Services = map[string]Service{} }()
+	suite.Run(t, new(EnvoyTestSuite))
+}
+
+// NewEngine
+
+func (s *EnvoyTestSuite) Test_NewEngine_ReturnsEnvoy_WhenEngineIsEnvoy() {
+	defer func() { os.Unsetenv("PROXY_ENGINE") }()
+	os.Setenv("PROXY_ENGINE", "envoy")
+
+	actual, err := NewEngine("/cfg/tmpl", "/cfg")
+
+	s.NoError(err)
+	s.Equal(Envoy{ConfigsPath: "/cfg"}, actual)
+}
+
+// CreateConfigFromTemplates
+
+func (s *EnvoyTestSuite) Test_CreateConfigFromTemplates_WritesResources() {
+	s.envoy.AddService(Service{ServiceName: "my-service", ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/"}}}})
+
+	err := s.envoy.CreateConfigFromTemplates()
+
+	s.NoError(err)
+	actual := XdsResources{}
+	json.Unmarshal([]byte(s.written["/cfg/envoy.json"]), &actual)
+	s.Len(actual.Clusters, 1)
+	s.Len(actual.Routes, 1)
+	s.Len(actual.Listeners, 1)
+}
+
+// GetXdsResources
+
+func (s *EnvoyTestSuite) Test_GetXdsResources_ReturnsClusterForEachPort() {
+	s.envoy.AddService(Service{
+		ServiceName:      "my-service",
+		OutboundHostname: "10.0.0.1,fd00::1",
+		ServiceDest: []ServiceDest{
+			{Port: "8080", ServicePath: []string{"/api"}},
+			{Port: "8080", SrcPort: 8081, ServicePath: []string{"/api"}},
+		},
+	})
+
+	actual := GetXdsResources()
+
+	s.Equal([]XdsResource{{
+		"@type":           XdsClusterType,
+		"name":            "my-service-be8080",
+		"connect_timeout": "5s",
+		"type":            "STRICT_DNS",
+		"lb_policy":       "ROUND_ROBIN",
+		"load_assignment": XdsResource{
+			"cluster_name": "my-service-be8080",
+			"endpoints": []XdsResource{{"lb_endpoints": []XdsResource{
+				{"endpoint": XdsResource{"address": XdsResource{"socket_address": XdsResource{"address": "10.0.0.1", "port_value": 8080}}}},
+				{"endpoint": XdsResource{"address": XdsResource{"socket_address": XdsResource{"address": "fd00::1", "port_value": 8080}}}},
+			}}},
+		},
+	}}, actual.Clusters)
+}
+
+func (s *EnvoyTestSuite) Test_GetXdsResources_ReturnsVirtualHostForEachDomain() {
+	s.envoy.AddService(Service{
+		ServiceName: "my-service",
+		ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/api"}}},
+	})
+	s.envoy.AddService(Service{
+		ServiceName:   "other-service",
+		ServiceDomain: []string{"acme.com"},
+		HttpsOnly:     true,
+		ServiceDest:   []ServiceDest{{Port: "80", ServicePath: []string{"/"}}},
+	})
+	s.envoy.AddService(Service{
+		ServiceName: "regex-service",
+		PathType:    "path_reg",
+		ServiceDest: []ServiceDest{{Port: "80", ServicePath: []string{"^/v[0-9]+"}}},
+	})
+
+	actual := GetXdsResources()
+
+	s.Equal([]XdsResource{{
+		"@type": XdsRouteType,
+		"name":  "http-80",
+		"virtual_hosts": []XdsResource{
+			{
+				"name":    "http-80-*",
+				"domains": []string{"*"},
+				"routes": []XdsResource{
+					{"match": XdsResource{"safe_regex": XdsResource{"regex": "^/v[0-9]+"}}, "route": XdsResource{"cluster": "regex-service-be80"}},
+					{"match": XdsResource{"prefix": "/api"}, "route": XdsResource{"cluster": "my-service-be8080"}},
+				},
+			},
+			{
+				"name":    "http-80-acme.com",
+				"domains": []string{"acme.com"},
+				"routes": []XdsResource{
+					{"match": XdsResource{"safe_regex": XdsResource{"regex": "^/v[0-9]+"}}, "route": XdsResource{"cluster": "regex-service-be80"}},
+					{"match": XdsResource{"prefix": "/api"}, "route": XdsResource{"cluster": "my-service-be8080"}},
+					{"match": XdsResource{"prefix": "/"}, "redirect": XdsResource{"https_redirect": true}},
+				},
+			},
+		},
+	}}, actual.Routes)
+	s.Len(actual.Listeners, 1)
+	s.Equal("http-80", actual.Listeners[0]["name"])
+}
+
+func (s *EnvoyTestSuite) Test_GetXdsResources_SortsRoutesByDescendingPrefixLength() {
+	s.envoy.AddService(Service{
+		ServiceName: "a-service",
+		ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/"}}},
+	})
+	s.envoy.AddService(Service{
+		ServiceName: "b-service",
+		ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/api/v1", "/api"}}},
+	})
+
+	actual := GetXdsResources()
+
+	routes := actual.Routes[0]["virtual_hosts"].([]XdsResource)[0]["routes"].([]XdsResource)
+	s.Len(routes, 3)
+	s.Equal(XdsResource{"prefix": "/api/v1"}, routes[0]["match"])
+	s.Equal(XdsResource{"prefix": "/api"}, routes[1]["match"])
+	s.Equal(XdsResource{"prefix": "/"}, routes[2]["match"])
+}
+
+func (s *EnvoyTestSuite) Test_GetXdsResources_StripsPortAndTrailingDotOfHost() {
+	s.envoy.AddService(Service{ServiceName: "my-service", ServiceDomain: []string{"acme.com"}, ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/"}}}})
+
+	actual, _ := json.Marshal(GetXdsResources().Listeners)
+
+	s.Contains(string(actual), `"strip_any_host_port":true`)
+	s.Contains(string(actual), `"strip_trailing_host_dot":true`)
+}
+
+func (s *EnvoyTestSuite) Test_GetXdsResources_AddsRouteTimeout_WhenTimeoutServerIsSet() {
+	s.envoy.AddService(Service{
+		ServiceName:   "my-service",
+		TimeoutServer: "60",
+		ServiceDest: []ServiceDest{
+			{Port: "8080", ServicePath: []string{"/api"}},
+			{Port: "8081", ServicePath: []string{"/reports"}, TimeoutServer: "300"},
+		},
+	})
+
+	actual := GetXdsResources()
+
+	routes := actual.Routes[0]["virtual_hosts"].([]XdsResource)[0]["routes"].([]XdsResource)
+	s.Equal(XdsResource{"cluster": "my-service-be8081", "timeout": "300s"}, routes[0]["route"])
+	s.Equal(XdsResource{"cluster": "my-service-be8080", "timeout": "60s"}, routes[1]["route"])
+}
+
+func (s *EnvoyTestSuite) Test_GetXdsResources_ReturnsTcpListener_WhenReqModeIsTcp() {
+	s.envoy.AddService(Service{
+		ServiceName: "my-db",
+		ReqMode:     "tcp",
+		ServiceDest: []ServiceDest{{Port: "5432", SrcPort: 5432}},
+	})
+
+	actual := GetXdsResources()
+
+	s.Empty(actual.Routes)
+	s.Equal([]XdsResource{{
+		"@type":   XdsListenerType,
+		"name":    "tcp-5432",
+		"address": XdsResource{"socket_address": XdsResource{"address": "0.0.0.0", "port_value": 5432}},
+		"filter_chains": []XdsResource{{
+			"filters": []XdsResource{{
+				"name": "envoy.filters.network.tcp_proxy",
+				"typed_config": XdsResource{
+					"@type":       "type.googleapis.com/envoy.extensions.filters.network.tcp_proxy.v3.TcpProxy",
+					"stat_prefix": "tcp-5432",
+					"cluster":     "my-db-be5432",
+				},
+			}},
+		}},
+	}}, actual.Listeners)
+}
+
+func (s *EnvoyTestSuite) Test_GetXdsResources_UsesXdsClusterName() {
+	defer func() { os.Unsetenv("XDS_CLUSTER_NAME") }()
+	os.Setenv("XDS_CLUSTER_NAME", "dfp")
+	s.envoy.AddService(Service{ServiceName: "my-service", ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/"}}}})
+
+	actual, _ := json.Marshal(GetXdsResources().Listeners)
+
+	s.Contains(string(actual), `"cluster_names":["dfp"]`)
+}
+
+func (s *EnvoyTestSuite) Test_GetXdsResources_ChangesVersion_OnlyWhenResourcesChange() {
+	s.envoy.AddService(Service{ServiceName: "my-service", ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/"}}}})
+	first := GetXdsResources().Version
+
+	s.Equal(first, GetXdsResources().Version)
+
+	s.envoy.AddService(Service{ServiceName: "my-service", ServiceDest: []ServiceDest{{Port: "8081", ServicePath: []string{"/"}}}})
+
+	s.NotEqual(first, GetXdsResources().Version)
+}
+
+// GetResources
+
+func (s *EnvoyTestSuite) Test_GetResources_ReturnsResourcesWithRequestedNames() {
+	resources := XdsResources{Routes: []XdsResource{{"name": "http-80"}, {"name": "http-8080"}}}
+
+	s.Equal([]XdsResource{{"name": "http-8080"}}, resources.GetResources(XdsRouteType, []string{"http-8080"}))
+	s.Len(resources.GetResources(XdsRouteType, []string{}), 2)
+	s.Empty(resources.GetResources("unknown", []string{}))
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	EventReconfigure = "reconfigure"
+	EventRemove      = "remove"
+	EventReload      = "reload"
+	EventHealth      = "health"
+	EventCertExpiry  = "cert-expiry"
+)
+
+// The number of events buffered for each subscriber. Events sent to a subscriber that does not keep up are dropped.
+const eventsBufferSize = 100
+
+type Event struct {
+	// The time the event happened.
+	Time time.Time
+	// The type of the event (reconfigure, remove, reload, health, or cert-expiry).
+	Type string
+	// The name of the service (reconfigure and remove), of the backend (health), or the path of the certificate (cert-expiry) the event refers to.
+	ServiceName string `json:",omitempty"`
+	// The outcome of a reload (OK or NOK) or the new status of a backend (e.g. UP or DOWN).
+	Status string `json:",omitempty"`
+	// The error of a failed reload, the previous status of a backend, or the expiry of a certificate.
+	Message string `json:",omitempty"`
+}
+
+var eventsMu = &sync.Mutex{}
+var eventSubscribers = map[chan Event]bool{}
+
+// PublishEvent sends the event to all the subscribers without waiting for them.
+func PublishEvent(event Event) {
+	if event.Time.IsZero() {
+		event.Time = timeNow()
+	}
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	for subscriber := range eventSubscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// SubscribeEvents returns the channel that receives the events published from now on and the function that stops the subscription.
+func SubscribeEvents() (<-chan Event, func()) {
+	subscriber := make(chan Event, eventsBufferSize)
+	eventsMu.Lock()
+	eventSubscribers[subscriber] = true
+	eventsMu.Unlock()
+	return subscriber, func() {
+		eventsMu.Lock()
+		delete(eventSubscribers, subscriber)
+		eventsMu.Unlock()
+	}
+}
+
+// HasEventSubscribers returns whether anyone is subscribed to the events.
+// It allows skipping the work needed to detect events (e.g. polling the health of the backends) nobody would receive.
+func HasEventSubscribers() bool {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	return len(eventSubscribers) > 0
+}
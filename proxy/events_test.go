@@ -0,0 +1,79 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EventsTestSuite struct {
+	suite.Suite
+}
+
+func (s *EventsTestSuite) SetupTest() {
+	eventSubscribers = map[chan Event]bool{}
+}
+
+func TestEventsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(EventsTestSuite))
+}
+
+// PublishEvent
+
+func (s *EventsTestSuite) Test_PublishEvent_SendsEventToAllSubscribers() {
+	first, unsubscribeFirst := SubscribeEvents()
+	defer unsubscribeFirst()
+	second, unsubscribeSecond := SubscribeEvents()
+	defer unsubscribeSecond()
+
+	PublishEvent(Event{Type: EventReconfigure, ServiceName: "my-service"})
+
+	for _, subscriber := range []<-chan Event{first, second} {
+		event := <-subscriber
+		s.Equal(EventReconfigure, event.Type)
+		s.Equal("my-service", event.ServiceName)
+		s.False(event.Time.IsZero())
+	}
+}
+
+func (s *EventsTestSuite) Test_PublishEvent_DropsEvents_WhenSubscriberDoesNotKeepUp() {
+	events, unsubscribe := SubscribeEvents()
+	defer unsubscribe()
+
+	for i := 0; i < eventsBufferSize+10; i++ {
+		PublishEvent(Event{Type: EventReload})
+	}
+
+	s.Len(events, eventsBufferSize)
+}
+
+// SubscribeEvents
+
+func (s *EventsTestSuite) Test_SubscribeEvents_StopsSendingEvents_WhenUnsubscribed() {
+	events, unsubscribe := SubscribeEvents()
+	s.True(HasEventSubscribers())
+
+	unsubscribe()
+	PublishEvent(Event{Type: EventReload})
+
+	s.False(HasEventSubscribers())
+	s.Len(events, 0)
+}
+
+// recordReload
+
+func (s *EventsTestSuite) Test_RecordReload_PublishesReloadEvent() {
+	events, unsubscribe := SubscribeEvents()
+	defer unsubscribe()
+
+	recordReload(time.Now(), fmt.Errorf("This is an error"))
+
+	event := <-events
+	s.Equal(EventReload, event.Type)
+	s.Equal("NOK", event.Status)
+	s.Equal("This is an error", event.Message)
+}
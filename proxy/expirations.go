@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Expirations tracks the services registered with a TTL.
+type Expirations struct {
+	mu        *sync.Mutex
+	expiresAt map[string]time.Time
+}
+
+func NewExpirations() *Expirations {
+	return &Expirations{
+		mu:        &sync.Mutex{},
+		expiresAt: map[string]time.Time{},
+	}
+}
+
+// Refresh extends the expiry of the service by ttlSeconds.
+// Services refreshed with a TTL of zero never expire.
+func (m *Expirations) Refresh(serviceName string, ttlSeconds int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ttlSeconds > 0 {
+		m.expiresAt[serviceName] = timeNow().Add(time.Duration(ttlSeconds) * time.Second)
+	} else {
+		delete(m.expiresAt, serviceName)
+	}
+}
+
+func (m *Expirations) Delete(serviceName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.expiresAt, serviceName)
+}
+
+// GetExpired returns the names of the services that were not refreshed within their TTL.
+func (m *Expirations) GetExpired() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expired := []string{}
+	now := timeNow()
+	for serviceName, expiresAt := range m.expiresAt {
+		if !now.Before(expiresAt) {
+			expired = append(expired, serviceName)
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
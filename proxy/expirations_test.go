@@ -0,0 +1,64 @@
+// +build !integration
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ExpirationsTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func (s *ExpirationsTestSuite) SetupTest() {
+	s.now = time.Date(2017, 3, 2, 10, 20, 30, 0, time.UTC)
+	timeNow = func() time.Time {
+		return s.now
+	}
+}
+
+func TestExpirationsUnitTestSuite(t *testing.T) {
+	timeNowOrig := timeNow
+	defer func() { timeNow = timeNowOrig }()
+	suite.Run(t, new(ExpirationsTestSuite))
+}
+
+// GetExpired
+
+func (s *ExpirationsTestSuite) Test_GetExpired_ReturnsServicesThatWereNotRefreshed() {
+	e := NewExpirations()
+	e.Refresh("service-1", 60)
+	e.Refresh("service-2", 120)
+	e.Refresh("service-3", 0)
+
+	s.now = s.now.Add(90 * time.Second)
+
+	s.Equal([]string{"service-1"}, e.GetExpired())
+}
+
+func (s *ExpirationsTestSuite) Test_GetExpired_DoesNotReturnRefreshedServices() {
+	e := NewExpirations()
+	e.Refresh("service-1", 60)
+	s.now = s.now.Add(50 * time.Second)
+	e.Refresh("service-1", 60)
+
+	s.now = s.now.Add(50 * time.Second)
+
+	s.Empty(e.GetExpired())
+}
+
+func (s *ExpirationsTestSuite) Test_GetExpired_DoesNotReturnServices_WhenRefreshedWithoutTtl() {
+	e := NewExpirations()
+	e.Refresh("service-1", 60)
+	e.Refresh("service-1", 0)
+	e.Refresh("service-2", 60)
+	e.Delete("service-2")
+
+	s.now = s.now.Add(90 * time.Second)
+
+	s.Empty(e.GetExpired())
+}
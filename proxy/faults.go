@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Fault describes the faults injected into the requests to a service.
+type Fault struct {
+	// The percentage of the requests answered with the status 500 instead of being forwarded to the service.
+	AbortPercentage int
+	// The number of milliseconds requests are delayed before being forwarded to the service.
+	Delay int
+}
+
+var faultsMu = &sync.Mutex{}
+var faults = map[string]Fault{}
+
+// IsFaultInjectionEnabled returns whether the backends are generated with the fault injection rules.
+func IsFaultInjectionEnabled() bool {
+	return strings.EqualFold(GetSecretOrEnvVar("FAULT_INJECTION", "false"), "true")
+}
+
+// GetFaults returns the faults injected into the services.
+func GetFaults() map[string]Fault {
+	faultsMu.Lock()
+	defer faultsMu.Unlock()
+	copied := map[string]Fault{}
+	for name, f := range faults {
+		copied[name] = f
+	}
+	return copied
+}
+
+// SetFault changes the faults injected into the service without reloading the proxy.
+// A fault without abort percentage and delay removes the service from the fault maps.
+// The faults are stored in map files that are updated through the stats socket.
+func SetFault(configsPath, serviceName string, fault Fault) error {
+	if fault.AbortPercentage < 0 || fault.AbortPercentage > 100 {
+		return fmt.Errorf("The abort percentage must be between 0 and 100")
+	} else if fault.Delay < 0 {
+		return fmt.Errorf("The delay cannot be negative")
+	}
+	faultsMu.Lock()
+	defer faultsMu.Unlock()
+	if fault.AbortPercentage == 0 && fault.Delay == 0 {
+		delete(faults, serviceName)
+	} else {
+		faults[serviceName] = fault
+	}
+	if err := writeFaultMaps(configsPath); err != nil {
+		return err
+	}
+	values := []struct {
+		mapPath string
+		value   int
+	}{
+		{GetFaultAbortMapPath(configsPath), fault.AbortPercentage},
+		{GetFaultDelayMapPath(configsPath), fault.Delay},
+	}
+	for _, v := range values {
+		commands := []string{fmt.Sprintf("del map %s %s", v.mapPath, GetIdentifier(serviceName))}
+		if v.value > 0 {
+			commands = append(commands, fmt.Sprintf("add map %s %s %d", v.mapPath, GetIdentifier(serviceName), v.value))
+		}
+		for _, command := range commands {
+			if _, err := sendSocketCommand(command); err != nil {
+				return fmt.Errorf("Could not update the map %s\n%s", v.mapPath, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// GetFaultAbortMapPath returns the path of the map with the abort percentages of the services.
+func GetFaultAbortMapPath(configsPath string) string {
+	return fmt.Sprintf("%s/faults-abort.map", configsPath)
+}
+
+// GetFaultDelayMapPath returns the path of the map with the delays of the services.
+func GetFaultDelayMapPath(configsPath string) string {
+	return fmt.Sprintf("%s/faults-delay.map", configsPath)
+}
+
+// writeFaultMaps writes the abort percentages and the delays of the services. The caller must hold faultsMu.
+func writeFaultMaps(configsPath string) error {
+	aborts := []string{}
+	delays := []string{}
+	for name, f := range faults {
+		if f.AbortPercentage > 0 {
+			aborts = append(aborts, fmt.Sprintf("%s %d\n", GetIdentifier(name), f.AbortPercentage))
+		}
+		if f.Delay > 0 {
+			delays = append(delays, fmt.Sprintf("%s %d\n", GetIdentifier(name), f.Delay))
+		}
+	}
+	sort.Strings(aborts)
+	sort.Strings(delays)
+	if err := writeFile(GetFaultAbortMapPath(configsPath), []byte(strings.Join(aborts, "")), 0664); err != nil {
+		return err
+	}
+	return writeFile(GetFaultDelayMapPath(configsPath), []byte(strings.Join(delays, "")), 0664)
+}
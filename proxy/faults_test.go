@@ -0,0 +1,82 @@
+// +build !integration
+
+package proxy
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FaultsTestSuite struct {
+	suite.Suite
+}
+
+func TestFaultsUnitTestSuite(t *testing.T) {
+	writeFileOrig := writeFile
+	defer func() { writeFile = writeFileOrig }()
+	sendSocketCommandOrig := sendSocketCommand
+	defer func() { sendSocketCommand = sendSocketCommandOrig }()
+	suite.Run(t, new(FaultsTestSuite))
+}
+
+func (s *FaultsTestSuite) SetupTest() {
+	faults = map[string]Fault{}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		return nil
+	}
+	sendSocketCommand = func(command string) (string, error) {
+		return "", nil
+	}
+}
+
+// SetFault
+
+func (s FaultsTestSuite) Test_SetFault_WritesMaps() {
+	actualMaps := map[string]string{}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualMaps[filename] = string(data)
+		return nil
+	}
+	faults["other-service"] = Fault{Delay: 100}
+
+	err := SetFault("/cfg", "my-service", Fault{AbortPercentage: 10, Delay: 200})
+
+	s.NoError(err)
+	s.Equal(map[string]string{
+		"/cfg/faults-abort.map": "my-service 10\n",
+		"/cfg/faults-delay.map": "my-service 200\nother-service 100\n",
+	}, actualMaps)
+}
+
+func (s FaultsTestSuite) Test_SetFault_UpdatesMapsThroughSocket() {
+	actualCommands := []string{}
+	sendSocketCommand = func(command string) (string, error) {
+		actualCommands = append(actualCommands, command)
+		return "", nil
+	}
+
+	SetFault("/cfg", "my-service", Fault{AbortPercentage: 10})
+
+	s.Equal([]string{
+		"del map /cfg/faults-abort.map my-service",
+		"add map /cfg/faults-abort.map my-service 10",
+		"del map /cfg/faults-delay.map my-service",
+	}, actualCommands)
+}
+
+func (s FaultsTestSuite) Test_SetFault_RemovesService_WhenFaultIsEmpty() {
+	faults["my-service"] = Fault{AbortPercentage: 10}
+
+	SetFault("/cfg", "my-service", Fault{})
+
+	s.Equal(map[string]Fault{}, GetFaults())
+}
+
+func (s FaultsTestSuite) Test_SetFault_ReturnsError_WhenAbortPercentageIsOutOfRange() {
+	err := SetFault("/cfg", "my-service", Fault{AbortPercentage: 101})
+
+	s.Error(err)
+	s.Equal(map[string]Fault{}, GetFaults())
+}
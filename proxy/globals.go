@@ -0,0 +1,221 @@
+package proxy
+
+import (
+	"../logging"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type globalSetting struct {
+	// The name of the parameter of the globals endpoint.
+	param string
+	// The environment variable that defines the value when it is not overridden.
+	env          string
+	defaultValue string
+	validate     func(value string) error
+}
+
+// The global settings that can be changed without restarting the proxy
+var globalSettings = []globalSetting{
+	{"debug", "DEBUG", "false", validateBool},
+	{"defaultCertName", "DEFAULT_CERT_NAME", "", validateCertName},
+	{"logLevel", "LOG_LEVEL", "info", validateLogLevel},
+	{"maxConn", "MAXCONN", "5000", validatePositiveInt},
+	{"normalizeUri", "NORMALIZE_URI", "false", validateBool},
+	{"statsPass", "STATS_PASS", "admin", validateStatsCredential},
+	{"statsUser", "STATS_USER", "admin", validateStatsCredential},
+	{"strictSni", "STRICT_SNI", "false", validateBool},
+	{"timeoutClient", "TIMEOUT_CLIENT", "20", validatePositiveInt},
+	{"timeoutConnect", "TIMEOUT_CONNECT", "5", validatePositiveInt},
+	{"timeoutHttpKeepAlive", "TIMEOUT_HTTP_KEEP_ALIVE", "15", validatePositiveInt},
+	{"timeoutHttpRequest", "TIMEOUT_HTTP_REQUEST", "5", validatePositiveInt},
+	{"timeoutQueue", "TIMEOUT_QUEUE", "30", validatePositiveInt},
+	{"timeoutServer", "TIMEOUT_SERVER", "20", validatePositiveInt},
+	{"timeoutTunnel", "TIMEOUT_TUNNEL", "3600", validatePositiveInt},
+}
+
+// The global settings whose values are not output by GetGlobals
+var secretGlobals = map[string]bool{"statsPass": true}
+
+const redactedGlobal = "*****"
+
+var globalsMu = &sync.Mutex{}
+
+// The values set through SetGlobals keyed by the parameter names
+var globalOverrides = map[string]string{}
+
+// The file the overridden settings are persisted to. They are not persisted if it is empty.
+var globalsPath = ""
+
+// GetGlobals returns the current values of the global settings keyed by the parameter names.
+// The values of the secret settings (e.g. statsPass) are redacted.
+func GetGlobals() map[string]string {
+	globals := map[string]string{}
+	for _, setting := range globalSettings {
+		if secretGlobals[setting.param] {
+			globals[setting.param] = redactedGlobal
+		} else {
+			globals[setting.param] = getGlobal(setting.param)
+		}
+	}
+	return globals
+}
+
+// LoadGlobals reads the global settings persisted to the file and persists the later changes to it
+// so that the overridden settings survive restarts.
+func LoadGlobals(path string) error {
+	globalsMu.Lock()
+	globalsPath = path
+	globalsMu.Unlock()
+	content, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values := map[string]string{}
+	if err := json.Unmarshal(content, &values); err != nil {
+		return fmt.Errorf("Could not parse the globals file %s\n%s", path, err.Error())
+	}
+	return SetGlobals(values)
+}
+
+// GetGlobalOverrides returns the global settings changed through SetGlobals.
+func GetGlobalOverrides() map[string]string {
+	globalsMu.Lock()
+	defer globalsMu.Unlock()
+	overrides := map[string]string{}
+	for param, value := range globalOverrides {
+		overrides[param] = value
+	}
+	return overrides
+}
+
+// SetGlobals validates and overrides the global settings.
+// None of the values are changed if any of them is invalid.
+// The configuration needs to be recreated and reloaded for the changes to take effect.
+func SetGlobals(values map[string]string) error {
+	params := []string{}
+	for param := range values {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+	for _, param := range params {
+		setting, found := getGlobalSetting(param)
+		if !found {
+			return fmt.Errorf("%s is not a global setting that can be changed. Supported settings are %s", param, strings.Join(getGlobalParams(), ", "))
+		}
+		if err := setting.validate(values[param]); err != nil {
+			return fmt.Errorf("%s %s", param, err.Error())
+		}
+	}
+	globalsMu.Lock()
+	for param, value := range values {
+		globalOverrides[param] = value
+	}
+	saveGlobals()
+	globalsMu.Unlock()
+	applyLogLevel()
+	return nil
+}
+
+// GetGlobal returns the current value of the global setting.
+func GetGlobal(param string) string {
+	return getGlobal(param)
+}
+
+// ResetGlobals replaces all the overridden global settings, for example to revert a change that could not be applied.
+func ResetGlobals(overrides map[string]string) {
+	globalsMu.Lock()
+	globalOverrides = map[string]string{}
+	for param, value := range overrides {
+		globalOverrides[param] = value
+	}
+	saveGlobals()
+	globalsMu.Unlock()
+	applyLogLevel()
+}
+
+// saveGlobals writes the overridden settings to the globals file. It must be called with globalsMu locked.
+// The file is readable only by its owner since it can contain the password of the statistics page.
+func saveGlobals() {
+	if len(globalsPath) == 0 {
+		return
+	}
+	js, _ := json.Marshal(globalOverrides)
+	if err := writeFile(globalsPath, js, 0600); err != nil {
+		logPrintf("Could not write the globals file %s\n%s", globalsPath, err.Error())
+	}
+}
+
+// getGlobal returns the overridden value of the setting or, if it was not changed, the value of its environment variable.
+func getGlobal(param string) string {
+	globalsMu.Lock()
+	value, found := globalOverrides[param]
+	globalsMu.Unlock()
+	if found {
+		return value
+	}
+	setting, _ := getGlobalSetting(param)
+	return GetSecretOrEnvVar(setting.env, setting.defaultValue)
+}
+
+func getGlobalSetting(param string) (globalSetting, bool) {
+	for _, setting := range globalSettings {
+		if setting.param == param {
+			return setting, true
+		}
+	}
+	return globalSetting{}, false
+}
+
+func getGlobalParams() []string {
+	params := []string{}
+	for _, setting := range globalSettings {
+		params = append(params, setting.param)
+	}
+	return params
+}
+
+func applyLogLevel() {
+	logging.Std.Level = logging.ParseLevel(getGlobal("logLevel"))
+}
+
+func validateLogLevel(value string) error {
+	for _, level := range []string{"debug", "info", "warn", "error"} {
+		if strings.EqualFold(value, level) {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be debug, info, warn, or error")
+}
+
+func validatePositiveInt(value string) error {
+	if i, err := strconv.Atoi(value); err != nil || i <= 0 {
+		return fmt.Errorf("must be a positive integer")
+	}
+	return nil
+}
+
+func validateStatsCredential(value string) error {
+	if len(value) == 0 || strings.ContainsAny(value, ": \t") {
+		return fmt.Errorf("cannot be empty or contain colons or whitespace")
+	}
+	return nil
+}
+
+func validateCertName(value string) error {
+	if strings.ContainsAny(value, "/ \t") {
+		return fmt.Errorf("cannot contain slashes or whitespace")
+	}
+	return nil
+}
+
+func validateBool(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return fmt.Errorf("must be true or false")
+	}
+	return nil
+}
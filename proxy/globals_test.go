@@ -0,0 +1,124 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"../logging"
+	"github.com/stretchr/testify/suite"
+)
+
+type GlobalsTestSuite struct {
+	suite.Suite
+}
+
+func (s *GlobalsTestSuite) SetupTest() {
+	ResetGlobals(map[string]string{})
+}
+
+func TestGlobalsUnitTestSuite(t *testing.T) {
+	defer ResetGlobals(map[string]string{})
+	suite.Run(t, new(GlobalsTestSuite))
+}
+
+// GetGlobals
+
+func (s GlobalsTestSuite) Test_GetGlobals_ReturnsEnvVarsOrDefaults() {
+	timeoutClientOrig := os.Getenv("TIMEOUT_CLIENT")
+	defer func() { os.Setenv("TIMEOUT_CLIENT", timeoutClientOrig) }()
+	os.Setenv("TIMEOUT_CLIENT", "25")
+
+	actual := GetGlobals()
+
+	s.Equal("25", actual["timeoutClient"])
+	s.Equal("5000", actual["maxConn"])
+}
+
+func (s GlobalsTestSuite) Test_GetGlobals_RedactsStatsPass() {
+	SetGlobals(map[string]string{"statsPass": "my-pass"})
+
+	s.Equal("*****", GetGlobals()["statsPass"])
+	s.Equal("my-pass", GetGlobal("statsPass"))
+}
+
+// LoadGlobals
+
+func (s GlobalsTestSuite) Test_LoadGlobals_RestoresPersistedSettings() {
+	defer func() { globalsPath = "" }()
+	path := fmt.Sprintf("%s/globals-%d.json", os.TempDir(), time.Now().UnixNano())
+	defer os.Remove(path)
+	LoadGlobals(path)
+	SetGlobals(map[string]string{"timeoutClient": "30"})
+	ResetGlobals(map[string]string{"maxConn": "100"})
+	globalOverrides = map[string]string{}
+
+	err := LoadGlobals(path)
+
+	s.NoError(err)
+	s.Equal(map[string]string{"maxConn": "100"}, GetGlobalOverrides())
+	info, _ := os.Stat(path)
+	s.Equal(os.FileMode(0600), info.Mode().Perm())
+}
+
+func (s GlobalsTestSuite) Test_LoadGlobals_ReturnsError_WhenFileIsNotJson() {
+	defer func() { globalsPath = "" }()
+	path := fmt.Sprintf("%s/globals-%d.json", os.TempDir(), time.Now().UnixNano())
+	defer os.Remove(path)
+	ioutil.WriteFile(path, []byte("maxConn: 100"), 0600)
+
+	s.Error(LoadGlobals(path))
+}
+
+// SetGlobals
+
+func (s GlobalsTestSuite) Test_SetGlobals_OverridesEnvVars() {
+	timeoutClientOrig := os.Getenv("TIMEOUT_CLIENT")
+	defer func() { os.Setenv("TIMEOUT_CLIENT", timeoutClientOrig) }()
+	os.Setenv("TIMEOUT_CLIENT", "25")
+
+	err := SetGlobals(map[string]string{"timeoutClient": "30", "statsUser": "my-user"})
+
+	s.NoError(err)
+	s.Equal("30", GetGlobals()["timeoutClient"])
+	s.Equal(map[string]string{"timeoutClient": "30", "statsUser": "my-user"}, GetGlobalOverrides())
+}
+
+func (s GlobalsTestSuite) Test_SetGlobals_ChangesLogLevel() {
+	levelOrig := logging.Std.Level
+	defer func() { logging.Std.Level = levelOrig }()
+
+	SetGlobals(map[string]string{"logLevel": "debug"})
+
+	s.Equal(logging.DEBUG, logging.Std.Level)
+}
+
+func (s GlobalsTestSuite) Test_SetGlobals_ReturnsError_WhenAnyValueIsInvalid() {
+	invalid := []map[string]string{
+		{"timeoutClient": "30", "maxConn": "-1"},
+		{"logLevel": "verbose"},
+		{"statsUser": "my:user"},
+		{"mode": "swarm"},
+	}
+	for _, values := range invalid {
+		err := SetGlobals(values)
+
+		s.Error(err)
+		s.Equal(map[string]string{}, GetGlobalOverrides())
+	}
+}
+
+// getConfigData
+
+func (s GlobalsTestSuite) Test_GetConfigData_UsesOverriddenGlobals() {
+	SetGlobals(map[string]string{"maxConn": "10000", "timeoutServer": "60"})
+
+	d := HaProxy{}.getConfigData()
+
+	s.Equal("10000", d.MaxConn)
+	s.Equal("60", d.TimeoutServer)
+}
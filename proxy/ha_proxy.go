@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
+	"io"
 	"os"
 	"os/exec"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -14,11 +16,20 @@ type HaProxy struct {
 	TemplatesPath string
 	ConfigsPath   string
 	ConfigData    ConfigData
+	services      *Data
 }
 
 // TODO: Change to pointer
 var Instance Proxy
 
+// The port the API of the proxy listens to. The backends served by the API itself (e.g. preview-be) forward the requests to it.
+var ApiPort = "8080"
+
+// getApiAddress returns the address the proxy reaches its own API through.
+func getApiAddress() string {
+	return "127.0.0.1:" + ApiPort
+}
+
 // TODO: Move to data from proxy.go when static (e.g. env. vars.)
 type ConfigData struct {
 	CertsString          string
@@ -30,6 +41,7 @@ type ConfigData struct {
 	TimeoutTunnel        string
 	TimeoutHttpRequest   string
 	TimeoutHttpKeepAlive string
+	MaxConn              string
 	StatsUser            string
 	StatsPass            string
 	UserList             string
@@ -43,7 +55,7 @@ type ConfigData struct {
 }
 
 func NewHaProxy(templatesPath, configsPath string) Proxy {
-	data.Services = map[string]Service{}
+	data.Replace(map[string]Service{})
 	return HaProxy{
 		TemplatesPath: templatesPath,
 		ConfigsPath:   configsPath,
@@ -83,31 +95,57 @@ func (m HaProxy) GetCerts() map[string]string {
 }
 
 func (m HaProxy) RunCmd(extraArgs []string) error {
+	configPath := m.getConfigPath()
 	args := []string{
 		"-f",
-		"/cfg/haproxy.cfg",
+		configPath,
 		"-D",
 		"-p",
 		"/var/run/haproxy.pid",
 	}
 	args = append(args, extraArgs...)
 	cmd := exec.Command("haproxy", args...)
+	stderr := bytes.Buffer{}
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
 	if err := cmdRunHa(cmd); err != nil {
-		configData, _ := readConfigsFile("/cfg/haproxy.cfg")
+		configData, _ := readConfigsFile(configPath)
+		// The alerts are attributed to the services so that the whole configuration does not need to be dumped
+		if issues := GetConfigIssues(stderr.String(), string(configData), m.GetServices()); len(issues) > 0 {
+			return &ConfigError{Command: strings.Join(cmd.Args, " "), Err: err, Issues: issues}
+		}
 		return fmt.Errorf("Command %s\n%s\n%s", strings.Join(cmd.Args, " "), err.Error(), string(configData))
 	}
 	return nil
 }
 
 func (m HaProxy) CreateConfigFromTemplates() error {
-	configsContent, err := m.getConfigs()
-	if err != nil {
+	if hasCaptures(getData(m.services).Snapshot()) {
+		// HAProxy fails to start if the map referenced by the capture rules does not exist
+		captureMu.Lock()
+		err := writeCaptureMap(getCaptureMapPath(m.ConfigsPath))
+		captureMu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	if err := writeBlocklistMaps(m.ConfigsPath, getData(m.services).Snapshot()); err != nil {
 		return err
 	}
+	if IsFaultInjectionEnabled() {
+		faultsMu.Lock()
+		err := writeFaultMaps(m.ConfigsPath)
+		faultsMu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
 	configPath := fmt.Sprintf("%s/haproxy.cfg", m.ConfigsPath)
-	return writeFile(configPath, []byte(configsContent), 0664)
+	if err := writeFileFrom(configPath, 0664, m.writeConfigs); err != nil {
+		return err
+	}
+	recordConfig()
+	return nil
 }
 
 func (m HaProxy) ReadConfig() (string, error) {
@@ -119,87 +157,213 @@ func (m HaProxy) ReadConfig() (string, error) {
 	return string(out[:]), nil
 }
 
+// RenderConfig returns the configuration that would be generated from the current templates and services without writing it.
+func (m HaProxy) RenderConfig() (string, error) {
+	return m.getConfigs()
+}
+
 func (m HaProxy) Reload() error {
 	logPrintf("Reloading the proxy")
+	start := timeNow()
+	err := m.reload()
+	recordReload(start, err)
+	return err
+}
+
+func (m HaProxy) reload() error {
 	pidPath := "/var/run/haproxy.pid"
 	pid, err := readPidFile(pidPath)
 	if err != nil {
 		return fmt.Errorf("Could not read the %s file\n%s", pidPath, err.Error())
 	}
+	// The reload continues without the states, the same as when HAProxy starts for the first time
+	if err := saveServerState(); err != nil {
+		logPrintf("%s", err.Error())
+	}
 	cmdArgs := []string{"-sf", string(pid)}
-	return HaProxy{}.RunCmd(cmdArgs)
+	return m.RunCmd(cmdArgs)
+}
+
+// getConfigPath returns the path of haproxy.cfg inside ConfigsPath or, if it is not set, inside /cfg.
+func (m HaProxy) getConfigPath() string {
+	if len(m.ConfigsPath) == 0 {
+		return "/cfg/haproxy.cfg"
+	}
+	return fmt.Sprintf("%s/haproxy.cfg", m.ConfigsPath)
 }
 
 func (m HaProxy) AddService(service Service) {
-	data.Services[service.ServiceName] = service
+	getData(m.services).Put(service)
 }
 
 func (m HaProxy) RemoveService(service string) {
-	delete(data.Services, service)
+	getData(m.services).Delete(service)
+}
+
+func (m HaProxy) GetServices() map[string]Service {
+	return copyServices(getData(m.services).Snapshot())
 }
 
 func (m HaProxy) getConfigs() (string, error) {
-	contentArr := []string{}
-	configsFiles := []string{"haproxy.tmpl"}
-	configs, err := readConfigsDir(m.TemplatesPath)
-	if err != nil {
-		return "", fmt.Errorf("Could not read the directory %s\n%s", m.TemplatesPath, err.Error())
+	var content bytes.Buffer
+	if err := m.writeConfigs(&content); err != nil {
+		return "", err
 	}
-	for _, fi := range configs {
-		if strings.HasSuffix(fi.Name(), "-fe.cfg") {
-			configsFiles = append(configsFiles, fi.Name())
-		}
+	return content.String(), nil
+}
+
+// writeConfigs writes the configuration to w piece by piece so that the configuration of thousands of services
+// does not need to be kept in memory. The files are read and the backends rendered only when their pieces are written,
+// so a single piece is held at a time. Only the pieces with actions are executed as templates.
+func (m HaProxy) writeConfigs(w io.Writer) error {
+	pieces := []func() (string, error){}
+	addPiece := func(content string) {
+		pieces = append(pieces, func() (string, error) { return content, nil })
 	}
-	for _, fi := range configs {
-		if strings.HasSuffix(fi.Name(), "-be.cfg") {
-			configsFiles = append(configsFiles, fi.Name())
+	configsFiles := []string{"haproxy.tmpl"}
+	// Proxies with their own services render the backends instead of reading the files written by reconfigure requests
+	if m.services == nil {
+		configs, err := readConfigsDir(m.TemplatesPath)
+		if err != nil {
+			return fmt.Errorf("Could not read the directory %s\n%s", m.TemplatesPath, err.Error())
+		}
+		for _, fi := range configs {
+			if strings.HasSuffix(fi.Name(), "-fe.cfg") {
+				configsFiles = append(configsFiles, fi.Name())
+			}
+		}
+		for _, fi := range configs {
+			if strings.HasSuffix(fi.Name(), "-be.cfg") {
+				configsFiles = append(configsFiles, fi.Name())
+			}
 		}
 	}
 	for _, file := range configsFiles {
-		templateBytes, err := readConfigsFile(fmt.Sprintf("%s/%s", m.TemplatesPath, file))
-		if err != nil {
-			return "", fmt.Errorf("Could not read the file %s\n%s", file, err.Error())
+		file := file
+		pieces = append(pieces, func() (string, error) {
+			templateBytes, err := readConfigsFile(fmt.Sprintf("%s/%s", m.TemplatesPath, file))
+			if err != nil {
+				return "", fmt.Errorf("Could not read the file %s\n%s", file, err.Error())
+			}
+			return string(templateBytes), nil
+		})
+	}
+	if m.services != nil {
+		services := m.services.Snapshot()
+		fingerprint := getRenderFingerprint(m.ConfigsPath)
+		names := []string{}
+		for name := range services {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			s := services[name]
+			pieces = append(pieces, func() (string, error) {
+				back := m.services.renders.get("backend", s, fingerprint, func() string {
+					return m.RenderBackend(&s, "swarm")
+				})
+				return strings.TrimPrefix(back, "\n"), nil
+			})
 		}
-		contentArr = append(contentArr, string(templateBytes))
 	}
-	if len(configsFiles) == 1 {
-		contentArr = append(contentArr, `    acl url_dummy path_beg /dummy
+	if len(pieces) == 1 {
+		addPiece(`    acl url_dummy path_beg /dummy
     use_backend dummy-be if url_dummy
 
 backend dummy-be
     server dummy 1.1.1.1:1111 check`)
 	}
-	tmpl, _ := template.New("contentTemplate").Parse(
-		strings.Join(contentArr, "\n\n"),
-	)
-	var content bytes.Buffer
-	tmpl.Execute(&content, m.getConfigData())
-	return content.String(), nil
+	if len(GetSecretOrEnvVar("PREVIEW_DOMAIN", "")) > 0 {
+		addPiece(`backend preview-be
+    mode http
+    http-request set-header X-Preview-Uri %[url]
+    http-request set-path /v1/docker-flow-proxy/preview
+    server preview ` + getApiAddress())
+	}
+	if server := getAcmeChallengeServer(); len(server) > 0 {
+		backend := `backend acme-challenge-be
+    mode http`
+		if !strings.HasPrefix(server, "127.0.0.1:") {
+			backend += fmt.Sprintf(`
+    server acme-challenge %s`, server)
+		} else {
+			// The proxy serves the challenges from ACME_CHALLENGE_PATH
+			backend += `
+    http-request set-query token=%[path,field(4,/)]
+    http-request set-path /v1/docker-flow-proxy/acme-challenge
+    server acme-challenge ` + server
+		}
+		addPiece(backend)
+	}
+	if fallbackProxy := GetSecretOrEnvVar("FALLBACK_PROXY", ""); len(fallbackProxy) > 0 {
+		if !strings.Contains(fallbackProxy, ":") {
+			fallbackProxy += ":80"
+		}
+		addPiece(fmt.Sprintf(`backend fallback-be
+    mode http
+    http-request set-header X-Dfp-Fallback true
+    server fallback %s`, fallbackProxy))
+	}
+	data := m.getConfigData()
+	for i, piece := range pieces {
+		content, err := piece()
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n\n"); err != nil {
+				return err
+			}
+		}
+		if !strings.Contains(content, "{{") {
+			if _, err := io.WriteString(w, content); err != nil {
+				return err
+			}
+			continue
+		}
+		tmpl, err := template.New("contentTemplate").Funcs(TemplateFuncs(getData(m.services).Snapshot)).Parse(content)
+		if err != nil {
+			return fmt.Errorf("Could not parse the configuration template\n%s", err.Error())
+		}
+		if err := tmpl.Execute(w, data); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // TODO: Too big... Refactor it.
 func (m HaProxy) getConfigData() ConfigData {
-	certPaths := m.GetCertPaths()
+	certPaths := getDefaultCertPaths(m.GetCertPaths())
+	tlsGlobal, tlsBind := getTlsOptions(m.ConfigsPath)
 	certsString := []string{}
 	if len(certPaths) > 0 {
 		certsString = append(certsString, " ssl")
 		for _, certPath := range certPaths {
 			certsString = append(certsString, fmt.Sprintf("crt %s", certPath))
 		}
+		if isStrictSni() {
+			certsString = append(certsString, "strict-sni")
+		}
+		if len(tlsBind) > 0 {
+			certsString = append(certsString, tlsBind)
+		}
 	}
 	d := ConfigData{
 		CertsString: strings.Join(certsString, " "),
+		ExtraGlobal: tlsGlobal,
 	}
 	d.ConnectionMode = GetSecretOrEnvVar("CONNECTION_MODE", "http-server-close")
-	d.TimeoutConnect = GetSecretOrEnvVar("TIMEOUT_CONNECT", "5")
-	d.TimeoutClient = GetSecretOrEnvVar("TIMEOUT_CLIENT", "20")
-	d.TimeoutServer = GetSecretOrEnvVar("TIMEOUT_SERVER", "20")
-	d.TimeoutQueue = GetSecretOrEnvVar("TIMEOUT_QUEUE", "30")
-	d.TimeoutTunnel = GetSecretOrEnvVar("TIMEOUT_TUNNEL", "3600")
-	d.TimeoutHttpRequest = GetSecretOrEnvVar("TIMEOUT_HTTP_REQUEST", "5")
-	d.TimeoutHttpKeepAlive = GetSecretOrEnvVar("TIMEOUT_HTTP_KEEP_ALIVE", "15")
-	d.StatsUser = GetSecretOrEnvVar("STATS_USER", "admin")
-	d.StatsPass = GetSecretOrEnvVar("STATS_PASS", "admin")
+	d.TimeoutConnect = getGlobal("timeoutConnect")
+	d.TimeoutClient = getGlobal("timeoutClient")
+	d.TimeoutServer = getGlobal("timeoutServer")
+	d.TimeoutQueue = getGlobal("timeoutQueue")
+	d.TimeoutTunnel = getGlobal("timeoutTunnel")
+	d.TimeoutHttpRequest = getGlobal("timeoutHttpRequest")
+	d.TimeoutHttpKeepAlive = getGlobal("timeoutHttpKeepAlive")
+	d.MaxConn = getGlobal("maxConn")
+	d.StatsUser = getGlobal("statsUser")
+	d.StatsPass = getGlobal("statsPass")
 	usersString := GetSecretOrEnvVar("USERS", "")
 	encryptedString := GetSecretOrEnvVar("USERS_PASS_ENCRYPTED", "")
 	if len(usersString) > 0 {
@@ -217,20 +381,28 @@ func (m HaProxy) getConfigData() ConfigData {
 			d.UserList = fmt.Sprintf("%s    user %s %s %s\n", d.UserList, user.Username, passwordType, user.Password)
 		}
 	}
-	if strings.EqualFold(GetSecretOrEnvVar("DEBUG", ""), "true") {
+	if strings.EqualFold(getGlobal("debug"), "true") {
 		d.ExtraGlobal += `
     debug`
 	} else {
 		d.ExtraDefaults += `
-    option  dontlognull
+    option  dontlognull`
+		if !IsAccessLogEnabled() {
+			d.ExtraDefaults += `
     option  dontlog-normal`
+		}
+	}
+	if IsAccessLogEnabled() {
+		// All the requests are logged so that their metrics can be collected and the logs shipped
+		d.ExtraGlobal += "\n    log " + AccessLogAddress + " local0"
+		d.ExtraDefaults += "\n    log     global"
 	}
 
 	defaultPortsString := GetSecretOrEnvVar("DEFAULT_PORTS", "")
 	defaultPorts := strings.Split(defaultPortsString, ",")
 	for _, bindPort := range defaultPorts {
 		formattedPort := strings.Replace(bindPort, ":ssl", d.CertsString, -1)
-		d.DefaultBinds += fmt.Sprintf("\n    bind *:%s", formattedPort)
+		d.DefaultBinds += fmt.Sprintf("\n    bind %s", getBind(formattedPort))
 	}
 	d.ExtraFrontend = GetSecretOrEnvVar("EXTRA_FRONTEND", "")
 	extraGlobal := GetSecretOrEnvVar("EXTRA_GLOBAL", "")
@@ -241,33 +413,130 @@ func (m HaProxy) getConfigData() ConfigData {
 	if len(bindPortsString) > 0 {
 		bindPorts := strings.Split(bindPortsString, ",")
 		for _, bindPort := range bindPorts {
-			d.ExtraFrontend += fmt.Sprintf("\n    bind *:%s", bindPort)
+			d.ExtraFrontend += fmt.Sprintf("\n    bind %s", getBind(bindPort))
 		}
 	}
+	store := getData(m.services)
+	snapshot := store.Snapshot()
 	services := Services{}
-	for _, s := range data.Services {
-		if len(s.AclName) == 0 {
-			s.AclName = s.ServiceName
+	externalCheck := false
+	rewriteResponseUrls := false
+	corsPreflight := false
+	mirror := false
+	mqttStickiness := false
+	for _, s := range snapshot {
+		s.AclName = GetIdentifier(GetAclName(s))
+		if len(s.ExternalCheckCommand) > 0 || len(s.DbWriterCheckCommand) > 0 {
+			externalCheck = true
 		}
-		services = append(services, s)
+		if s.RewriteResponseUrls {
+			rewriteResponseUrls = true
+		}
+		if s.CorsPreflight {
+			corsPreflight = true
+		}
+		if len(s.MirrorToService) > 0 {
+			mirror = true
+		}
+		if s.MqttStickiness {
+			mqttStickiness = true
+		}
+		if len(s.ReqMode) == 0 || strings.EqualFold(s.ReqMode, "http") {
+			services = append(services, getDomainEntries(s)...)
+		} else {
+			services = append(services, s)
+		}
+	}
+	if externalCheck {
+		d.ExtraGlobal += "\n    external-check"
+	}
+	d.ExtraGlobal += getRequestLimitsGlobal()
+	if hasCaptures(snapshot) || IsAccessLogEnabled() {
+		// The HTTP log format includes the captured headers and cookies, the timers, and the status codes
+		d.ExtraDefaults += "\n    option  httplog"
+	}
+	if rewriteResponseUrls {
+		d.ExtraGlobal += "\n    lua-load /lua/rewrite-response-urls.lua"
+	}
+	if corsPreflight {
+		d.ExtraGlobal += "\n    lua-load /lua/cors-preflight.lua"
+	}
+	if mirror {
+		d.ExtraGlobal += "\n    lua-load /lua/mirror.lua"
+	}
+	if IsFaultInjectionEnabled() {
+		d.ExtraGlobal += "\n    lua-load /lua/fault-delay.lua"
+	}
+	if mqttStickiness {
+		d.ExtraGlobal += "\n    lua-load /lua/mqtt-client-id.lua"
 	}
 	sort.Sort(services)
-	snimap := make(map[int]string)
+	// Only the frontends of the services that changed since the previous render are rendered again
+	fingerprint := getRenderFingerprint(m.ConfigsPath)
+	if maxUrlLength := getRequestLimit("MAX_URL_LENGTH"); maxUrlLength > 0 {
+		d.ContentFrontend += getMaxUrlLengthTemplate(maxUrlLength)
+	}
+	if isNormalizeUriEnabled() {
+		d.ContentFrontend += getNormalizeUriTemplate("")
+	}
+	if IsBlocklistEnabled() {
+		d.ContentFrontend += getGlobalBlocklistTemplate(m.ConfigsPath)
+	}
+	if len(getAcmeChallengeServer()) > 0 {
+		// The challenges are matched before the services so that services with the path / do not receive them
+		d.ContentFrontend += `
+    acl acme_challenge path_beg ` + AcmeChallengePath + `
+    use_backend acme-challenge-be if acme_challenge`
+	}
+	// The snippets of thousands of services are written to buffers since appending them to the strings would copy the content for each service
+	frontend := bytes.NewBufferString(d.ContentFrontend)
+	frontendTcp := bytes.Buffer{}
+	snimap := make(map[int]*bytes.Buffer)
 	for _, s := range services {
+		s := s
 		if len(s.ReqMode) == 0 {
 			s.ReqMode = "http"
 		}
 		if strings.EqualFold(s.ReqMode, "http") {
-			d.ContentFrontend += m.getFrontTemplate(s)
+			kind := "frontend"
+			if s.AclName != GetIdentifier(GetAclName(snapshot[s.ServiceName])) {
+				kind += "-" + s.AclName
+			}
+			frontend.WriteString(store.renders.get(kind, s, fingerprint, func() string {
+				return m.getFrontTemplate(s)
+			}))
 		} else if strings.EqualFold(s.ReqMode, "sni") {
 			for _, sd := range s.ServiceDest {
 				_, header_exists := snimap[sd.SrcPort]
-				snimap[sd.SrcPort] += m.getFrontTemplateSNI(s, !header_exists)
+				if !header_exists {
+					snimap[sd.SrcPort] = &bytes.Buffer{}
+				}
+				kind := fmt.Sprintf("frontend-sni-%d-%t", sd.SrcPort, !header_exists)
+				snimap[sd.SrcPort].WriteString(store.renders.get(kind, s, fingerprint, func() string {
+					return m.getFrontTemplateSNI(s, !header_exists)
+				}))
 			}
+		} else if s.hasFrontendTemplates() {
+			// The templates can change without a change of the service so the frontends are not cached
+			frontendTcp.WriteString(m.getFrontTemplateTcp(s))
 		} else {
-			d.ContentFrontendTcp += m.getFrontTemplateTcp(s)
+			frontendTcp.WriteString(store.renders.get("frontend-tcp", s, fingerprint, func() string {
+				return m.getFrontTemplateTcp(s)
+			}))
 		}
-
+	}
+	store.renders.prune(snapshot)
+	d.ContentFrontend = frontend.String()
+	d.ContentFrontendTcp = frontendTcp.String()
+	if previewDomain := GetSecretOrEnvVar("PREVIEW_DOMAIN", ""); len(previewDomain) > 0 {
+		d.ContentFrontend += fmt.Sprintf(`
+    acl preview_domain %s -i .%s
+    use_backend preview-be if preview_domain`, getHostFetch("end"), previewDomain)
+	}
+	if len(GetSecretOrEnvVar("FALLBACK_PROXY", "")) > 0 {
+		// Requests that were already forwarded by a proxy are not sent back to prevent loops between peers
+		d.ContentFrontend += `
+    use_backend fallback-be unless { req.hdr(X-Dfp-Fallback) -m found }`
 	}
 	// Merge the SNI entries into one single string. Sorted by port.
 	var sniports []int
@@ -275,28 +544,55 @@ func (m HaProxy) getConfigData() ConfigData {
 		sniports = append(sniports, k)
 	}
 	sort.Ints(sniports)
+	sni := bytes.Buffer{}
 	for _, k := range sniports {
-		d.ContentFrontendSNI += snimap[k]
+		sni.Write(snimap[k].Bytes())
 	}
+	d.ContentFrontendSNI = sni.String()
 	return d
 }
 
 
 
+// RenderFrontend returns the frontend snippet that would be generated for the service.
+// It does not change the state of the proxy.
+func (m HaProxy) RenderFrontend(s Service) string {
+	s.AclName = GetIdentifier(GetAclName(s))
+	if len(s.ReqMode) == 0 {
+		s.ReqMode = "http"
+	}
+	if strings.EqualFold(s.ReqMode, "http") {
+		return m.getFrontTemplate(s)
+	} else if strings.EqualFold(s.ReqMode, "sni") {
+		return m.getFrontTemplateSNI(s, true)
+	}
+	return m.getFrontTemplateTcp(s)
+}
+
 func (m *HaProxy) getFrontTemplateSNI(s Service, gen_header bool) string {
 	tmplString := ``
 	if gen_header {
 		tmplString += `{{range .ServiceDest}}
 
 frontend service_{{.SrcPort}}
-    bind *:{{.SrcPort}}
+    bind ` + getBind("{{.SrcPort}}") + `
     mode tcp
     tcp-request inspect-delay 5s
     tcp-request content accept if { req_ssl_hello_type 1 }{{end}}`
 	}
+	tmplString += getSrcNetworksTemplate(&s)
+	if !IsFeatureSupported("alpn") {
+		dests := []ServiceDest{}
+		for _, sd := range s.ServiceDest {
+			sd.Alpn = nil
+			dests = append(dests, sd)
+		}
+		s.ServiceDest = dests
+	}
 	tmplString += `{{range .ServiceDest}}
-    acl sni_{{$.AclName}}{{.Port}}{{range .ServicePath}} {{$.PathType}} {{.}}{{end}}{{.SrcPortAcl}}{{end}}{{range .ServiceDest}}
-    use_backend {{$.ServiceName}}-be{{.Port}} if sni_{{$.AclName}}{{.Port}}{{$.AclCondition}}{{.SrcPortAclName}}{{end}}`
+    acl sni_{{$.AclName}}{{.Port}}{{range .ServicePath}} {{$.PathType}} {{.}}{{end}}{{.SrcPortAcl}}{{if .Alpn}}
+    acl alpn_{{$.AclName}}{{.Port}} req.ssl_alpn{{range .Alpn}} {{.}}{{end}}{{end}}{{end}}{{range .ServiceDest}}
+    use_backend {{$.ServiceName}}-be{{.Port}} if sni_{{$.AclName}}{{.Port}}{{if .Alpn}} alpn_{{$.AclName}}{{.Port}}{{end}}{{$.AclCondition}}{{.SrcPortAclName}}{{end}}`
 	return m.templateToString(tmplString, s)
 }
 
@@ -304,64 +600,290 @@ func (m *HaProxy) getFrontTemplateTcp(s Service) string {
 	tmplString := `{{range .ServiceDest}}
 
 frontend {{$.ServiceName}}_{{.SrcPort}}
-    bind *:{{.SrcPort}}
-    mode tcp
+    bind ` + getBind("{{.SrcPort}}") + `
+    mode tcp{{if $.SrcNetworks}}
+    tcp-request connection reject unless { src{{range $.SrcNetworks}} {{.}}{{end}} }{{end}}
     default_backend {{$.ServiceName}}-be{{.SrcPort}}{{end}}`
-	return m.templateToString(tmplString, s)
+	if !s.hasFrontendTemplates() {
+		return m.templateToString(tmplString, s)
+	}
+	front := ""
+	for _, sd := range s.ServiceDest {
+		if len(sd.FrontendTemplatePath) > 0 {
+			custom, err := m.getFrontendTemplateTcp(s, sd)
+			if err == nil {
+				front += custom
+				continue
+			}
+			logWarnf("The standard frontend of the port %d of the service %s is used instead of its template\n%s", sd.SrcPort, s.ServiceName, err.Error())
+		}
+		dest := s
+		dest.ServiceDest = []ServiceDest{sd}
+		front += m.templateToString(tmplString, dest)
+	}
+	return front
+}
+
+// frontendTemplateData is the data of the frontend templates of the destinations.
+// The fields of the service are available as well (e.g. {{.SrcNetworks}}).
+type frontendTemplateData struct {
+	Service
+	// The destination the frontend is rendered for.
+	Dest ServiceDest
+	// The name of the frontend generated for the destination (e.g. my-service_1883).
+	Name string
+	// The address of the bind generated for the destination (e.g. *:1883).
+	Bind string
+	// The name of the backend of the destination (e.g. my-service-be1883).
+	Backend string
+}
+
+// hasFrontendTemplates returns whether any of the destinations replaces its frontend with a template.
+func (s Service) hasFrontendTemplates() bool {
+	for _, sd := range s.ServiceDest {
+		if len(sd.FrontendTemplatePath) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// getFrontendTemplateTcp renders the template that replaces the frontend of the destination.
+func (m *HaProxy) getFrontendTemplateTcp(s Service, sd ServiceDest) (string, error) {
+	path := ResolveDockerPath(sd.FrontendTemplatePath)
+	content, err := ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Could not read the frontend template %s\n%s", path, err.Error())
+	}
+	tmpl, err := template.New("frontend").Funcs(TemplateFuncs(getData(m.services).Snapshot)).Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("Could not parse the frontend template %s\n%s", path, err.Error())
+	}
+	id := GetIdentifier(s.ServiceName)
+	data := frontendTemplateData{
+		Service: s,
+		Dest:    sd,
+		Name:    fmt.Sprintf("%s_%d", id, sd.SrcPort),
+		Bind:    getBind(strconv.Itoa(sd.SrcPort)),
+		Backend: fmt.Sprintf("%s-be%d", id, sd.SrcPort),
+	}
+	data.ServiceName = id
+	var front bytes.Buffer
+	if err := tmpl.Execute(&front, data); err != nil {
+		return "", fmt.Errorf("Could not render the frontend template %s\n%s", path, err.Error())
+	}
+	return "\n\n" + strings.Trim(front.String(), "\n"), nil
+}
+
+// The normalizers closing path confusion bypasses (e.g. /admin/../api, /%61dmin, or //admin) in the order they are applied
+var uriNormalizers = []string{"percent-decode-unreserved", "percent-to-uppercase", "path-strip-dotdot", "path-strip-dot", "path-merge-slashes"}
+
+// isNormalizeUriEnabled returns whether the URIs of all the requests are normalized.
+func isNormalizeUriEnabled() bool {
+	return strings.EqualFold(getGlobal("normalizeUri"), "true") && IsFeatureSupported("normalizeUri")
+}
+
+// getNormalizeUriTemplate returns the rules that normalize the URIs of the requests that match the condition or, if it is empty, of all the requests.
+// HAProxy runs the http-request rules before use_backend so the path ACLs are evaluated against the normalized URIs.
+func getNormalizeUriTemplate(condition string) string {
+	if len(condition) > 0 {
+		condition = " if" + condition
+	}
+	tmpl := ""
+	for _, normalizer := range uriNormalizers {
+		tmpl += fmt.Sprintf(`
+    http-request normalize-uri %s%s`, normalizer, condition)
+	}
+	return tmpl
+}
+
+// getSrcNetworksTemplate returns the ACL that matches the requests coming from the SrcNetworks of the service
+// and adds it to the conditions of the service so that it is used only for the requests from those networks.
+func getSrcNetworksTemplate(s *Service) string {
+	if len(s.SrcNetworks) == 0 {
+		return ""
+	}
+	s.AclCondition += fmt.Sprintf(" src_net_%s", s.AclName)
+	return `
+    acl src_net_{{.AclName}} src{{range .SrcNetworks}} {{.}}{{end}}`
 }
 
 func (m *HaProxy) getFrontTemplate(s Service) string {
 	if len(s.PathType) == 0 {
 		s.PathType = "path_beg"
 	}
+	if s.NormalizeTrailingSlash && (strings.EqualFold(s.PathType, "path") || strings.EqualFold(s.PathType, "path_end")) {
+		s.ServiceDest = getTrailingSlashDests(s.ServiceDest)
+	}
+	if s.PathMatchCaseInsensitive {
+		s.PathType += " -i"
+	}
 	tmplString := `{{range .ServiceDest}}
-    acl url_{{$.AclName}}{{.Port}}{{range .ServicePath}} {{$.PathType}} {{.}}{{end}}{{.SrcPortAcl}}{{end}}`
+    acl url_{{$.AclName}}_{{.Port}}{{range .ServicePath}} {{$.PathType}} {{.}}{{end}}{{.SrcPortAcl}}{{end}}`
 	if len(s.ServiceDomain) > 0 {
-		domFunc := "hdr"
+		domMatch := "str"
+		// The domains are copied so that trimming the wildcards does not modify the configured service
+		s.ServiceDomain = append([]string{}, s.ServiceDomain...)
 		if s.ServiceDomainMatchAll {
-			domFunc = "hdr_dom"
+			domMatch = "dom"
 		} else {
 			for i, domain := range s.ServiceDomain {
 				if strings.HasPrefix(domain, "*") {
 					s.ServiceDomain[i] = strings.Trim(domain, "*")
-					domFunc = "hdr_end"
+					domMatch = "end"
 				}
 			}
 		}
+		s.ServiceDomain = getIdnDomains(s.ServiceDomain)
 		tmplString += fmt.Sprintf(
 			`
-    acl domain_{{.AclName}} %s(host) -i{{range .ServiceDomain}} {{.}}{{end}}`,
-			domFunc,
+    acl domain_{{.AclName}} %s -i{{range .ServiceDomain}} {{.}}{{end}}`,
+			getHostFetch(domMatch),
 		)
 		s.AclCondition = fmt.Sprintf(" domain_%s", s.AclName)
 	}
+	tmplString += getSrcNetworksTemplate(&s)
+	if s.NormalizeUri && !isNormalizeUriEnabled() && IsFeatureSupported("normalizeUri") {
+		tmplString += getNormalizeUriTemplate(s.AclCondition)
+	}
+	if s.HasBlocklist() {
+		tmplString += getBlocklistTemplate(m.ConfigsPath, s)
+	}
+	if s.MaxUrlLength > 0 {
+		tmplString += getServiceMaxUrlLengthTemplate()
+	}
 	if s.HttpsPort > 0 {
 		tmplString += `
     acl http_{{.ServiceName}} src_port 80
     acl https_{{.ServiceName}} src_port 443`
 	}
+	if len(s.CanonicalDomain) > 0 {
+		tmplString += getCanonicalDomainTemplate(s)
+	}
 	if s.RedirectWhenHttpProto {
 		tmplString += `{{range .ServiceDest}}
     acl is_{{$.AclName}}_http hdr(X-Forwarded-Proto) http
-    redirect scheme https if is_{{$.AclName}}_http url_{{$.AclName}}{{.Port}}{{$.AclCondition}}{{.SrcPortAclName}}{{end}}`
+    redirect scheme https if is_{{$.AclName}}_http url_{{$.AclName}}_{{.Port}}{{$.AclCondition}}{{.SrcPortAclName}}` + getAcmeChallengeCondition() + `{{end}}`
 	} else if s.HttpsOnly {
 		tmplString += `{{range .ServiceDest}}
-    redirect scheme https if !{ ssl_fc } url_{{$.AclName}}{{.Port}}{{$.AclCondition}}{{.SrcPortAclName}}{{end}}`
+    redirect scheme https if !{ ssl_fc } url_{{$.AclName}}_{{.Port}}{{$.AclCondition}}{{.SrcPortAclName}}` + getAcmeChallengeCondition() + `{{end}}`
+	}
+	if len(s.CaptureRequestHeaders) > 0 || len(s.CaptureCookies) > 0 {
+		enabled := fmt.Sprintf(" !{ str({{$.ServiceName}}),map(%s) -m found }", getCaptureMapPath(m.ConfigsPath))
+		tmplString += fmt.Sprintf(`{{range $sd := .ServiceDest}}{{range $.CaptureRequestHeaders}}
+    http-request capture req.hdr({{.}}) len %d if url_{{$.AclName}}_{{$sd.Port}}{{$.AclCondition}}{{$sd.SrcPortAclName}}%s{{end}}{{range $.CaptureCookies}}
+    http-request capture req.cook({{.}}) len %d if url_{{$.AclName}}_{{$sd.Port}}{{$.AclCondition}}{{$sd.SrcPortAclName}}%s{{end}}{{end}}`,
+			captureLength, enabled, captureLength, enabled,
+		)
 	}
 	if s.HttpsPort > 0 {
 		tmplString += `{{range .ServiceDest}}
-    use_backend {{$.ServiceName}}-be{{.Port}} if url_{{$.AclName}}{{.Port}}{{$.AclCondition}}{{.SrcPortAclName}} http_{{$.ServiceName}}
-    use_backend https-{{$.ServiceName}}-be{{.Port}} if url_{{$.AclName}}{{.Port}}{{$.AclCondition}} https_{{$.ServiceName}}{{end}}`
+    use_backend {{$.ServiceName}}-be{{.Port}} if url_{{$.AclName}}_{{.Port}}{{$.AclCondition}}{{.SrcPortAclName}} http_{{$.ServiceName}}
+    use_backend https-{{$.ServiceName}}-be{{.Port}} if url_{{$.AclName}}_{{.Port}}{{$.AclCondition}} https_{{$.ServiceName}}{{end}}`
 	} else {
 		tmplString += `{{range .ServiceDest}}
-    use_backend {{$.ServiceName}}-be{{.Port}} if url_{{$.AclName}}{{.Port}}{{$.AclCondition}}{{.SrcPortAclName}}{{end}}`
+    use_backend {{$.ServiceName}}-be{{.Port}} if url_{{$.AclName}}_{{.Port}}{{$.AclCondition}}{{.SrcPortAclName}}{{end}}`
 	}
 	return m.templateToString(tmplString, s)
 }
 
+// getDomainEntries splits the service into an entry for each of the ACL priorities of its domains so that the domains
+// are ordered among the other services by their priorities. The domains without a priority stay with the service.
+func getDomainEntries(s Service) []Service {
+	if len(s.DomainAclPriority) == 0 || len(s.ServiceDomain) == 0 {
+		return []Service{s}
+	}
+	entries := []Service{}
+	indexes := map[int]int{}
+	for _, domain := range s.ServiceDomain {
+		priority, found := s.DomainAclPriority[domain]
+		if !found {
+			priority = s.AclPriority
+		}
+		index, found := indexes[priority]
+		if !found {
+			entry := s
+			entry.ServiceDomain = []string{}
+			entry.AclPriority = priority
+			if priority != s.AclPriority {
+				entry.AclName = fmt.Sprintf("%s_%d", s.AclName, len(entries))
+			}
+			index = len(entries)
+			indexes[priority] = index
+			entries = append(entries, entry)
+		}
+		entries[index].ServiceDomain = append(entries[index].ServiceDomain, domain)
+	}
+	return entries
+}
+
+// getHostFetch returns the Host header without the port and the trailing dot with the match method (str, end, or dom)
+// so that requests like Host: acme.com:443 or Host: acme.com. match the domains of the services.
+func getHostFetch(match string) string {
+	return fmt.Sprintf("req.hdr(host),field(1,:),regsub([.]$,) -m %s", match)
+}
+
+// getCanonicalDomainTemplate redirects the requests to the other domains of the service to the canonical domain with the same path and query.
+// Services that are served only through https are redirected straight to https to avoid a second redirect.
+func getCanonicalDomainTemplate(s Service) string {
+	canonical := getIdnDomains([]string{s.CanonicalDomain})[0]
+	condition := "url_{{$.AclName}}_{{.Port}}{{$.AclCondition}}{{.SrcPortAclName}} !canonical_{{$.AclName}}" + getAcmeChallengeCondition()
+	tmpl := fmt.Sprintf(`
+    acl canonical_{{.AclName}} %s -i %s{{range .ServiceDest}}`, getHostFetch("str"), canonical)
+	if s.HttpsOnly || s.RedirectWhenHttpProto {
+		tmpl += fmt.Sprintf(`
+    redirect prefix https://%s code 301 if %s`, canonical, condition)
+	} else {
+		tmpl += fmt.Sprintf(`
+    redirect prefix https://%s code 301 if { ssl_fc } %s
+    redirect prefix http://%s code 301 if !{ ssl_fc } %s`, canonical, condition, canonical, condition)
+	}
+	return tmpl + "{{end}}"
+}
+
+// getTrailingSlashDests returns copies of the destinations with each service path added both with and without the trailing slash.
+func getTrailingSlashDests(dests []ServiceDest) []ServiceDest {
+	normalized := []ServiceDest{}
+	for _, sd := range dests {
+		paths := []string{}
+		for _, path := range sd.ServicePath {
+			trimmed := strings.TrimRight(path, "/")
+			if len(trimmed) == 0 {
+				paths = append(paths, path)
+				continue
+			}
+			paths = append(paths, trimmed, trimmed+"/")
+		}
+		sd.ServicePath = paths
+		normalized = append(normalized, sd)
+	}
+	return normalized
+}
+
 func (m *HaProxy) templateToString(templateString string, service Service) string {
+	// The templates use the names of the service and its ACLs in the identifiers of the frontends
+	service.ServiceName = GetIdentifier(service.ServiceName)
+	service.AclName = GetIdentifier(service.AclName)
 	tmpl, _ := template.New("template").Parse(templateString)
 	var b bytes.Buffer
 	tmpl.Execute(&b, service)
 	return b.String()
 }
+
+func hasCaptures(services map[string]Service) bool {
+	for _, s := range services {
+		if len(s.CaptureRequestHeaders) > 0 || len(s.CaptureCookies) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// getBind returns the address of a bind on the port.
+// If BIND_IPV6 is set, the proxy listens on both IPv4 and IPv6 addresses.
+func getBind(port string) string {
+	if strings.EqualFold(GetSecretOrEnvVar("BIND_IPV6", "false"), "true") {
+		return fmt.Sprintf(":::%s v4v6", port)
+	}
+	return fmt.Sprintf("*:%s", port)
+}
@@ -0,0 +1,519 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+)
+
+// RenderBackend returns the users list and the backends of the service.
+// In the swarm (or service) mode, the servers are the hosts or tasks of the service.
+// In the other modes, the servers are consul-template placeholders filled by the services registered in Consul.
+// The fields derived from the parameters (e.g. AclName and Host) are set on the service.
+func (m HaProxy) RenderBackend(sr *Service, mode string) string {
+	if len(sr.ReqMode) == 0 {
+		sr.ReqMode = "http"
+	}
+	formatService(sr)
+	tmplUsersList, _ := template.New("template").Parse(getUsersList(sr))
+	tmplBack, _ := template.New("template").Funcs(template.FuncMap{
+		"quote":           quoteConfigString,
+		"stripPathRegexp": getStripPathRegexp,
+		"stripPathCondition": func(path string) template.HTML {
+			return getStripPathCondition(path, sr.PathMatchCaseInsensitive)
+		},
+	}).Parse(m.getBackTemplate(sr, mode))
+	var ctUsersList bytes.Buffer
+	var ctBack bytes.Buffer
+	// The templates use the name of the service in the names of the backends, userlists, ACLs, and servers
+	id := *sr
+	id.ServiceName = GetIdentifier(sr.ServiceName)
+	tmplUsersList.Execute(&ctUsersList, id)
+	tmplBack.Execute(&ctBack, id)
+	return ctUsersList.String() + ctBack.String()
+}
+
+// getStripPathRegexp returns the regular expression that matches the service path stripped from the requests.
+// The metacharacters are escaped with bracket expressions since HAProxy 1.7 does not accept backslashes
+// and closing parentheses in the arguments of regsub.
+func getStripPathRegexp(path string) template.HTML {
+	var escaped bytes.Buffer
+	for _, c := range strings.TrimSuffix(path, "/") {
+		if strings.ContainsRune(".+*?$|[({", c) {
+			escaped.WriteString("[" + string(c) + "]")
+		} else {
+			escaped.WriteRune(c)
+		}
+	}
+	return template.HTML(escaped.String())
+}
+
+// getStripPathCondition returns the condition of the requests the service path is stripped from.
+// The path is matched only up to a segment boundary so that, for example, /api is not stripped from /apiv2.
+func getStripPathCondition(path string, caseInsensitive bool) template.HTML {
+	flags := ""
+	if caseInsensitive {
+		flags = " -i"
+	}
+	path = strings.TrimSuffix(path, "/")
+	if len(path) == 0 {
+		return template.HTML(fmt.Sprintf("{ path_beg%s / }", flags))
+	}
+	return template.HTML(fmt.Sprintf("{ path%s %s } || { path_beg%s %s/ }", flags, path, flags, path))
+}
+
+// formatService sets the fields used by the templates that are derived from the parameters of the service.
+func formatService(sr *Service) {
+	sr.AclCondition = ""
+	sr.AclName = GetIdentifier(GetAclName(*sr))
+	hosts := []string{}
+	for _, host := range sr.GetHosts() {
+		hosts = append(hosts, GetServerHost(host))
+	}
+	sr.Host = hosts[0]
+	sr.Hosts = nil
+	if len(hosts) > 1 {
+		sr.Hosts = hosts
+	}
+	if len(sr.ServiceColor) > 0 {
+		sr.FullServiceName = fmt.Sprintf("%s-%s", sr.ServiceName, sr.ServiceColor)
+	} else {
+		sr.FullServiceName = sr.ServiceName
+	}
+	if len(sr.PathType) == 0 {
+		sr.PathType = "path_beg"
+	}
+	// The destinations are copied since the services of the snapshots of the store share them
+	if len(sr.ServiceDest) > 0 {
+		sr.ServiceDest = append([]ServiceDest{}, sr.ServiceDest...)
+	}
+	for i, sd := range sr.ServiceDest {
+		if sd.SrcPort > 0 {
+			sr.ServiceDest[i].SrcPortAclName = fmt.Sprintf(" srcPort_%s_%d", GetIdentifier(sr.ServiceName), sd.SrcPort)
+			sr.ServiceDest[i].SrcPortAcl = fmt.Sprintf(`
+    acl srcPort_%s_%d dst_port %d`, GetIdentifier(sr.ServiceName), sd.SrcPort, sd.SrcPort)
+		}
+	}
+}
+
+func (m HaProxy) getBackTemplate(sr *Service, mode string) string {
+	back := m.getBackTemplateProtocol("http", sr, mode)
+	if sr.HttpsPort > 0 {
+		back += fmt.Sprintf(
+			`
+%s`,
+			m.getBackTemplateProtocol("https", sr, mode))
+	}
+	return back
+}
+
+func (m HaProxy) getBackTemplateProtocol(protocol string, sr *Service, mode string) string {
+	prefix := ""
+	if strings.EqualFold(protocol, "https") {
+		prefix = "https-"
+	}
+	rmode := sr.ReqMode
+	if strings.EqualFold(sr.ReqMode, "sni") {
+		rmode = "tcp"
+	}
+	// The tcp frontends of database services use the backends of their source ports so that the writers and the readers can share a port
+	backendPort := "{{.Port}}"
+	if strings.EqualFold(sr.ReqMode, "tcp") && sr.HasDbRoles() {
+		backendPort = "{{if .SrcPort}}{{.SrcPort}}{{else}}{{.Port}}{{end}}"
+	}
+	tmpl := fmt.Sprintf(`{{range .ServiceDest}}
+backend %s{{$.ServiceName}}-be%s
+    mode %s`,
+		prefix, backendPort, rmode,
+	)
+	if strings.EqualFold(rmode, "http") {
+		tmpl += `
+    http-request add-header X-Forwarded-Proto https if { ssl_fc }`
+		if len(sr.SetHostHeader) > 0 {
+			tmpl += `
+    http-request set-header Host {{$.SetHostHeader}}`
+		}
+		if sr.LoggingDisabled {
+			tmpl += `
+    http-request set-log-level silent`
+		} else if sr.LogSampleRate > 0 && sr.LogSampleRate < 100 {
+			tmpl += `
+    http-request set-log-level silent if { rand(100) ge {{$.LogSampleRate}} }`
+		}
+		if sr.Maintenance {
+			tmpl += `
+    http-request deny deny_status 503`
+		}
+		if IsFaultInjectionEnabled() {
+			tmpl += m.getFaultTemplate()
+		}
+		if sr.CorsPreflight {
+			tmpl += getCorsPreflightTemplate(sr)
+		}
+		if sr.BandwidthLimitPerStream > 0 && IsFeatureSupported("bandwidthLimitPerStream") {
+			tmpl += `
+    filter bwlim-out {{$.ServiceName}}_stream default-limit {{$.BandwidthLimitPerStream}} default-period 1s
+    http-response set-bandwidth-limit {{$.ServiceName}}_stream`
+		}
+		if sr.BandwidthLimitTotal > 0 && IsFeatureSupported("bandwidthLimitTotal") {
+			// All the streams share the same key so the limit applies to the backend as a whole
+			tmpl += `
+    stick-table type integer size 1 expire 1h store bytes_out_rate(1s)
+    filter bwlim-out {{$.ServiceName}}_total limit {{$.BandwidthLimitTotal}} key be_id
+    http-response set-bandwidth-limit {{$.ServiceName}}_total`
+		}
+	}
+	// TODO: Deprecated (dec. 2016).
+	// The timeouts of a destination override those of the service since each destination has its own backend
+	tmpl += `{{if .TimeoutServer}}
+    timeout server {{.TimeoutServer}}s{{else if $.TimeoutServer}}
+    timeout server {{$.TimeoutServer}}s{{end}}{{if .TimeoutTunnel}}
+    timeout tunnel {{.TimeoutTunnel}}s{{else if $.TimeoutTunnel}}
+    timeout tunnel {{$.TimeoutTunnel}}s{{end}}`
+	if strings.EqualFold(rmode, "http") {
+		if len(sr.HttpReuse) > 0 && IsFeatureSupported("httpReuse") {
+			tmpl += `
+    http-reuse {{$.HttpReuse}}`
+		}
+		if len(sr.ConnectionMode) > 0 {
+			tmpl += `
+    option {{$.ConnectionMode}}`
+		} else if sr.Http10Compatibility {
+			tmpl += `
+    option httpclose`
+		}
+		if sr.Http10Compatibility {
+			if len(sr.HttpReuse) == 0 {
+				tmpl += `
+    http-reuse never`
+			}
+			tmpl += `
+    option http-buffer-request
+    http-request set-header Connection close`
+		}
+		if sr.AcceptInvalidHttpResponse {
+			tmpl += `
+    option accept-invalid-http-response`
+		}
+		for _, errorfile := range sr.GetErrorfiles() {
+			tmpl += fmt.Sprintf(`
+    errorfile %d %s`, errorfile.Code, ResolveDockerPath(errorfile.Path))
+		}
+	}
+	if preset, ok := TcpPresets[sr.TcpPreset]; ok && strings.EqualFold(rmode, "tcp") {
+		if len(sr.TimeoutTunnel) == 0 {
+			tmpl += fmt.Sprintf(`{{if not .TimeoutTunnel}}
+    timeout tunnel %ss{{end}}`, preset.TimeoutTunnel)
+		}
+		for _, option := range preset.Options {
+			tmpl += `
+    ` + option
+		}
+		if sr.MqttStickiness && sr.TcpPreset == "mqtt" {
+			tmpl += getMqttStickinessTemplate(preset.TimeoutTunnel)
+		}
+	}
+	if sr.Maintenance && !strings.EqualFold(rmode, "http") {
+		tmpl += `
+    tcp-request content reject`
+	}
+	if len(sr.DbWriterCheckCommand) > 0 {
+		// The writer backends check which writer is the primary instead of running the check of the service
+		tmpl += `{{if eq .DbRole "writer"}}
+    option external-check
+    external-check command {{$.DbWriterCheckCommand}}{{else if $.ExternalCheckCommand}}
+    option external-check
+    external-check command {{$.ExternalCheckCommand}}{{end}}`
+	} else if len(sr.ExternalCheckCommand) > 0 {
+		tmpl += `
+    option external-check
+    external-check command {{$.ExternalCheckCommand}}`
+	}
+	if len(sr.ReqRepSearch) > 0 && len(sr.ReqRepReplace) > 0 {
+		tmpl += `
+    reqrep {{$.ReqRepSearch}}     {{$.ReqRepReplace}}`
+	}
+	if len(sr.ReqPathSearch) > 0 && len(sr.ReqPathReplace) > 0 {
+		tmpl += `
+    http-request set-path %[path,regsub({{$.ReqPathSearch}},{{$.ReqPathReplace}})]`
+	}
+	if strings.EqualFold(rmode, "http") {
+		if sr.NormalizeTrailingSlash {
+			tmpl += `
+    http-request set-path %[path,regsub(/+$,)] if { path_reg ^/.*[^/]/+$ }`
+		}
+		if sr.StripPath {
+			regsubFlags := ""
+			if sr.PathMatchCaseInsensitive {
+				regsubFlags = ",i"
+			}
+			tmpl += `{{range .ServicePath}}`
+			if sr.RewriteResponseUrls {
+				tmpl += `
+    http-request set-var(txn.dfp_public_path) str({{.}}) if {{stripPathCondition .}}`
+			}
+			tmpl += `
+    http-request set-path %[path,regsub(^{{stripPathRegexp .}}/?,/` + regsubFlags + `)] if {{stripPathCondition .}}{{end}}`
+		}
+		if len(sr.AddPathPrefix) > 0 {
+			tmpl += `
+    http-request set-path {{$.AddPathPrefix}}%[path]`
+		}
+	}
+	if sr.IsStaticResponse() && strings.EqualFold(rmode, "http") {
+		// The requests never reach a server
+	} else if isSwarm(mode) {
+		start := len(tmpl)
+		// Unix sockets do not have ports
+		port, httpsPort := ":{{.Port}}", ":{{$.HttpsPort}}"
+		if strings.HasPrefix(sr.Host, "unix@") {
+			port, httpsPort = "", ""
+		}
+		if strings.EqualFold(protocol, "https") && len(sr.Hosts) > 0 {
+			tmpl += `{{range $i, $host := $.Hosts}}
+    server {{$.ServiceName}}_{{$i}} {{$host}}:{{$.HttpsPort}} check{{if gt $i 0}} backup{{end}}` + getHttpsServerOptions(sr) + `{{if gt $.MaxIdleConnections 0}} pool-max-conn {{$.MaxIdleConnections}}{{end}}{{if $.SendProxyProtocol}} send-proxy{{end}}{{end}}`
+		} else if strings.EqualFold(protocol, "https") {
+			tmpl += `
+    server {{$.ServiceName}} {{$.Host}}` + httpsPort + `{{if or (ne $.ExternalCheckCommand "") (ne $.TcpPreset "")}} check{{end}}` + getHttpsServerOptions(sr) + `{{if gt $.MaxIdleConnections 0}} pool-max-conn {{$.MaxIdleConnections}}{{end}}{{if $.SendProxyProtocol}} send-proxy{{end}}`
+		} else if split := getSplitServers(sr); len(split) > 0 {
+			tmpl += split
+		} else if len(sr.Tasks) > 0 {
+			tmpl += `{{$port := .Port}}{{range $.Tasks}}
+    server {{$.ServiceName}}_{{.Name}} {{.Address}}:{{$port}} check{{if .Backup}} backup{{end}}{{if eq $.SslVerifyNone true}} ssl verify none{{end}}{{if gt $.MaxIdleConnections 0}} pool-max-conn {{$.MaxIdleConnections}}{{end}}{{if $.SendProxyProtocol}} send-proxy{{end}}{{end}}`
+		} else if len(sr.Hosts) > 0 {
+			tmpl += `{{$port := .Port}}{{range $i, $host := $.Hosts}}
+    server {{$.ServiceName}}_{{$i}} {{$host}}:{{$port}} check{{if gt $i 0}} backup{{end}}{{if eq $.SslVerifyNone true}} ssl verify none{{end}}{{if gt $.MaxIdleConnections 0}} pool-max-conn {{$.MaxIdleConnections}}{{end}}{{if $.SendProxyProtocol}} send-proxy{{end}}{{end}}`
+		} else {
+			tmpl += `
+    server {{$.ServiceName}} {{$.Host}}` + port + `{{if or (ne $.ExternalCheckCommand "") (ne $.TcpPreset "")}} check{{end}}{{if eq $.SslVerifyNone true}} ssl verify none{{end}}{{if gt $.MaxIdleConnections 0}} pool-max-conn {{$.MaxIdleConnections}}{{end}}{{if $.SendProxyProtocol}} send-proxy{{end}}`
+		}
+		if sr.HasDbRoles() && !strings.EqualFold(protocol, "https") {
+			// The destinations without a role keep the servers of the service
+			tmpl = tmpl[:start] + getDbServersTemplate() + tmpl[start:] + `{{end}}`
+		}
+	} else { // It's Consul
+		tmpl += `
+    {{"{{"}}range $i, $e := service "{{$.FullServiceName}}" "any"{{"}}"}}
+    server {{"{{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}}"}}{{if or (eq $.SkipCheck false) (ne $.ExternalCheckCommand "") (ne $.TcpPreset "")}} check{{if eq $.SslVerifyNone true}} ssl verify none{{end}}{{end}}{{if gt $.MaxIdleConnections 0}} pool-max-conn {{$.MaxIdleConnections}}{{end}}{{if $.SendProxyProtocol}} send-proxy{{end}}
+    {{"{{end}}"}}`
+	}
+	if len(sr.Users) > 0 {
+		tmpl += `
+    acl {{$.ServiceName}}UsersAcl http_auth({{$.ServiceName}}Users)
+    http-request auth realm {{$.ServiceName}}Realm if !{{$.ServiceName}}UsersAcl
+    http-request del-header Authorization`
+	} else if len(GetSecretOrEnvVar("USERS", "")) > 0 {
+		tmpl += `
+    acl defaultUsersAcl http_auth(defaultUsers)
+    http-request auth realm defaultRealm if !defaultUsersAcl
+    http-request del-header Authorization`
+	}
+	if sr.IsStaticResponse() && strings.EqualFold(rmode, "http") && IsFeatureSupported("staticResponseStatus") {
+		tmpl += getStaticResponseTemplate(sr)
+	}
+	if len(sr.MirrorToService) > 0 && strings.EqualFold(rmode, "http") {
+		tmpl += getMirrorTemplate(sr)
+	}
+	if sr.RewriteResponseUrls && strings.EqualFold(rmode, "http") && !strings.EqualFold(protocol, "https") && isSwarm(mode) {
+		// The Lua service forwards the request itself so it needs to be the last rule
+		if len(sr.AddPathPrefix) > 0 {
+			tmpl += `
+    http-request set-var(txn.dfp_internal_path) str({{$.AddPathPrefix}})`
+		}
+		// The request is sent to one of the servers of the backend and only the responses up to the limit are rewritten
+		tmpl += fmt.Sprintf(`
+    http-request set-var(txn.dfp_upstream) str({{$.Host}}:{{.Port}})
+    http-request set-var(txn.dfp_backend) be_name
+    http-request set-var(txn.dfp_max_size) int(%d)
+    http-request use-service lua.rewrite-response-urls`, getRewriteResponseMaxSize())
+	}
+	tmpl += "{{end}}"
+	return tmpl
+}
+
+// getRewriteResponseMaxSize returns the largest response, in bytes, rewritten by the Lua service.
+// The default is 1 MB.
+func getRewriteResponseMaxSize() int {
+	size, err := strconv.Atoi(GetSecretOrEnvVar("REWRITE_RESPONSE_MAX_SIZE", ""))
+	if err != nil || size <= 0 {
+		return 1048576
+	}
+	return size
+}
+
+// getCorsPreflightTemplate answers the preflight requests through the bundled Lua service.
+// It is placed before the authentication rules since browsers do not send credentials with preflight requests.
+// The values are quoted since the lists of headers can contain spaces.
+func getCorsPreflightTemplate(sr *Service) string {
+	tmpl := `
+    acl {{$.ServiceName}}Preflight req.hdr(Access-Control-Request-Method) -m found`
+	headers := []struct{ name, field, value string }{
+		{"X-Dfp-Cors-Allow-Origins", "CorsAllowOrigins", sr.CorsAllowOrigins},
+		{"X-Dfp-Cors-Allow-Methods", "CorsAllowMethods", sr.CorsAllowMethods},
+		{"X-Dfp-Cors-Allow-Headers", "CorsAllowHeaders", sr.CorsAllowHeaders},
+	}
+	for _, header := range headers {
+		if len(header.value) > 0 {
+			tmpl += fmt.Sprintf(`
+    http-request set-header %s {{quote $.%s}} if METH_OPTIONS {{$.ServiceName}}Preflight`, header.name, header.field)
+		}
+	}
+	if sr.CorsMaxAge > 0 {
+		tmpl += `
+    http-request set-header X-Dfp-Cors-Max-Age {{$.CorsMaxAge}} if METH_OPTIONS {{$.ServiceName}}Preflight`
+	}
+	tmpl += `
+    http-request use-service lua.cors-preflight if METH_OPTIONS {{$.ServiceName}}Preflight`
+	return tmpl
+}
+
+// getFaultTemplate delays and aborts the requests according to the fault maps so that the faults can be changed without reloading the
+// The request is aborted when the random number below 100 minus the abort percentage of the service is negative.
+func (m HaProxy) getFaultTemplate() string {
+	return fmt.Sprintf(`
+    http-request set-var(txn.dfp_fault_delay) str({{$.ServiceName}}),map_str_int(%s,0)
+    http-request lua.fault-delay if { var(txn.dfp_fault_delay) -m int gt 0 }
+    http-request set-var(txn.dfp_fault_abort) str({{$.ServiceName}}),map_str_int(%s,0)
+    http-request deny deny_status 500 if { rand(100),sub(txn.dfp_fault_abort) -m int lt 0 }`,
+		GetFaultDelayMapPath(m.ConfigsPath),
+		GetFaultAbortMapPath(m.ConfigsPath),
+	)
+}
+
+// getStaticResponseTemplate answers the requests with the static response of the service.
+// It is placed after the authentication rules so that static responses of protected services still require credentials.
+func getStaticResponseTemplate(sr *Service) string {
+	tmpl := `
+    http-request return status {{$.StaticResponseStatus}}`
+	if len(sr.StaticResponseBody) > 0 {
+		contentType := `"text/plain"`
+		if len(sr.StaticResponseContentType) > 0 {
+			contentType = "{{quote $.StaticResponseContentType}}"
+		}
+		tmpl += ` content-type ` + contentType + ` string {{quote $.StaticResponseBody}}`
+	}
+	return tmpl
+}
+
+// getMqttStickinessTemplate waits for the CONNECT packet of the MQTT clients and sticks their connections by the client IDs.
+// The client IDs are parsed by the bundled Lua fetch. The connections are not delayed for longer than the inspect delay.
+// The entries expire after the tunnel timeout of the destination, the service, or the preset.
+func getMqttStickinessTemplate(timeoutTunnel string) string {
+	return `
+    tcp-request inspect-delay 5s
+    tcp-request content accept if { lua.mqtt_client_id -m found }
+    stick-table type string len 128 size 100k expire {{if .TimeoutTunnel}}{{.TimeoutTunnel}}{{else if $.TimeoutTunnel}}{{$.TimeoutTunnel}}{{else}}` + timeoutTunnel + `{{end}}s
+    stick on lua.mqtt_client_id`
+}
+
+// quoteConfigString encloses the value in double quotes and escapes the characters with a special meaning in the HAProxy configuration.
+// Dollar signs would otherwise be expanded as environment variables.
+func quoteConfigString(value string) template.HTML {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return template.HTML(`"` + replacer.Replace(value) + `"`)
+}
+
+// getDbServersTemplate returns the servers of the destinations with the writer and the reader roles of a database service.
+// It is followed by the servers of the destinations without a role and {{end}}.
+// The writers are used one at a time. Without DbWriterCheckCommand, the standbys are backups of the first writer.
+// With it, only the primary passes the check and balance first keeps the connections on one writer while the servers change their state.
+// The sessions of a writer marked down are closed so that the clients reconnect to the new primary.
+func getDbServersTemplate() string {
+	return `{{if eq .DbRole "writer"}}{{$port := .Port}}{{if $.DbWriterCheckCommand}}
+    balance first{{end}}{{range $i, $host := $.DbWriters}}
+    server {{$.ServiceName}}_writer_{{$i}} {{$host}}:{{$port}} check on-marked-down shutdown-sessions{{if and (gt $i 0) (not $.DbWriterCheckCommand)}} backup{{end}}{{if $.SendProxyProtocol}} send-proxy{{end}}{{end}}{{else if eq .DbRole "reader"}}{{$port := .Port}}
+    balance leastconn{{range $i, $host := $.DbReaders}}
+    server {{$.ServiceName}}_reader_{{$i}} {{$host}}:{{$port}} check{{if $.SendProxyProtocol}} send-proxy{{end}}{{end}}{{range $i, $host := $.DbWriters}}
+    server {{$.ServiceName}}_writer_{{$i}} {{$host}}:{{$port}} check backup{{if $.SendProxyProtocol}} send-proxy{{end}}{{end}}{{else}}`
+}
+
+// getMirrorTemplate copies the requests to the shadow service through the bundled Lua action.
+func getMirrorTemplate(sr *Service) string {
+	target := "{{$.MirrorToService}}"
+	if !strings.Contains(sr.MirrorToService, ":") {
+		target += ":{{.Port}}"
+	}
+	condition := ""
+	if sr.MirrorPercentage > 0 && sr.MirrorPercentage < 100 {
+		condition = " if { rand(100) lt {{$.MirrorPercentage}} }"
+	}
+	return fmt.Sprintf(`
+    option http-buffer-request
+    http-request set-var(txn.dfp_mirror) str(%s)
+    http-request lua.mirror%s`, target, condition)
+}
+
+// getHttpsServerOptions returns the TLS options of the servers of the https backend.
+// Certificates are verified against BackendCaFile, and BackendSni is sent to backends that route by SNI themselves.
+func getHttpsServerOptions(sr *Service) string {
+	options := ""
+	if sr.SslVerifyNone {
+		options = " ssl verify none"
+	} else if len(sr.BackendCaFile) > 0 {
+		options = fmt.Sprintf(" ssl verify required ca-file %s", ResolveDockerPath(sr.BackendCaFile))
+	}
+	if len(sr.BackendSni) > 0 && len(options) > 0 {
+		options += fmt.Sprintf(" sni str(%s)", sr.BackendSni)
+		if len(sr.BackendCaFile) > 0 {
+			// The certificate must match the name sent through SNI rather than the address of the server
+			options += fmt.Sprintf(" verifyhost %s", sr.BackendSni)
+		}
+	}
+	return options
+}
+
+// getSplitTemplate adds a server for each group of an A/B test.
+// With cookies, new clients are distributed among the groups and HAProxy inserts the cookie of the selected server so that they stick to it.
+// With headers, the group is selected by the value of the header and the requests without it are distributed among the groups.
+func getSplitTemplate(sr *Service) (string, error) {
+	splitBy := strings.SplitN(sr.SplitBy, ":", 2)
+	if len(splitBy) != 2 || len(splitBy[1]) == 0 {
+		return "", fmt.Errorf("%s must be formatted as cookie:<name> or header:<name>", sr.SplitBy)
+	}
+	kind, name := splitBy[0], splitBy[1]
+	tmpl := ""
+	if strings.EqualFold(kind, "cookie") {
+		tmpl += fmt.Sprintf(`
+    cookie %s insert indirect nocache`, name)
+	}
+	tmpl += `{{$port := .Port}}{{range $.SplitGroups}}
+    server {{$.ServiceName}}_{{.Name}} {{.Host}}:{{$port}}`
+	if strings.EqualFold(kind, "cookie") {
+		tmpl += ` cookie {{.Name}}`
+	}
+	tmpl += `{{if or (ne $.ExternalCheckCommand "") (ne $.TcpPreset "")}} check{{end}}{{if eq $.SslVerifyNone true}} ssl verify none{{end}}{{if gt $.MaxIdleConnections 0}} pool-max-conn {{$.MaxIdleConnections}}{{end}}{{if $.SendProxyProtocol}} send-proxy{{end}}{{end}}`
+	if strings.EqualFold(kind, "header") {
+		tmpl += fmt.Sprintf(`{{range $.SplitGroups}}
+    use-server {{$.ServiceName}}_{{.Name}} if { req.hdr(%s) -m str {{.Name}} }{{end}}`, name)
+	}
+	return tmpl, nil
+}
+
+// getSplitServers returns the servers of the groups of an A/B test or an empty string when the service is not split.
+// The services that bypassed the validation with a malformed splitBy keep the regular servers.
+func getSplitServers(sr *Service) string {
+	if len(sr.SplitBy) == 0 || len(sr.SplitGroups) == 0 {
+		return ""
+	}
+	tmpl, err := getSplitTemplate(sr)
+	if err != nil {
+		logPrintf("WARNING: Could not split the backend of %s. %s", sr.ServiceName, err.Error())
+	}
+	return tmpl
+}
+
+func getUsersList(sr *Service) string {
+	if len(sr.Users) > 0 {
+		return `userlist {{.ServiceName}}Users{{range .Users}}
+    user {{.Username}} {{if .PassEncrypted}}password{{end}}{{if not .PassEncrypted}}insecure-password{{end}} {{.Password}}{{end}}
+
+`
+	}
+	return ""
+}
+
+func isSwarm(mode string) bool {
+	return strings.EqualFold(mode, "service") || strings.EqualFold(mode, "swarm")
+}
@@ -3,8 +3,11 @@
 package proxy
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/stretchr/testify/suite"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"strings"
@@ -23,10 +26,25 @@ type HaProxyTestSuite struct {
 	ServicesContent string
 }
 
+// The function that writes the files without going through writeFile
+var writeFileFromOrig = writeFileFrom
+
+func init() {
+	// The tests stub writeFile to capture the files, including those that are written through writeFileFrom
+	writeFileFrom = func(filename string, perm os.FileMode, write func(w io.Writer) error) error {
+		var content bytes.Buffer
+		if err := write(&content); err != nil {
+			return err
+		}
+		return writeFile(filename, content.Bytes(), perm)
+	}
+}
+
 // Suite
 
 func TestHaProxyUnitTestSuite(t *testing.T) {
 	logPrintf = func(format string, v ...interface{}) {}
+	logWarnf = func(format string, v ...interface{}) {}
 	s := new(HaProxyTestSuite)
 	s.TemplateContent = `global
     pidfile /var/run/haproxy.pid
@@ -289,6 +307,67 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_WritesCfgContentsIntoFi
 	s.Equal(expectedData, actualData)
 }
 
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_StreamsCfgContentsIntoFile() {
+	writeFileFromMock := writeFileFrom
+	defer func() { writeFileFrom = writeFileFromMock }()
+	writeFileFrom = writeFileFromOrig
+	dir, _ := ioutil.TempDir("", "dfp")
+	defer os.RemoveAll(dir)
+	p := NewHaProxy(s.TemplatesPath, dir).(HaProxy)
+	expected, _ := p.getConfigs()
+
+	err := p.CreateConfigFromTemplates()
+
+	s.NoError(err)
+	actual, _ := ioutil.ReadFile(dir + "/haproxy.cfg")
+	s.Equal(expected, string(actual))
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_KeepsCfgFile_WhenTemplateCannotBeParsed() {
+	writeFileFromMock := writeFileFrom
+	defer func() { writeFileFrom = writeFileFromMock }()
+	writeFileFrom = writeFileFromOrig
+	readConfigsFileOrig := readConfigsFile
+	defer func() { readConfigsFile = readConfigsFileOrig }()
+	readConfigsFile = func(filename string) ([]byte, error) {
+		if strings.HasSuffix(filename, "-be.cfg") {
+			return []byte("backend {{.Broken"), nil
+		}
+		return readConfigsFileOrig(filename)
+	}
+	dir, _ := ioutil.TempDir("", "dfp")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(dir+"/haproxy.cfg", []byte("the previous configuration"), 0664)
+
+	err := NewHaProxy(s.TemplatesPath, dir).CreateConfigFromTemplates()
+
+	s.Error(err)
+	actual, _ := ioutil.ReadFile(dir + "/haproxy.cfg")
+	s.Equal("the previous configuration", string(actual))
+	_, err = os.Stat(dir + "/haproxy.cfg.tmp")
+	s.True(os.IsNotExist(err))
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ReturnsError_WhenWriteFails() {
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		return fmt.Errorf("This is an error")
+	}
+
+	err := NewHaProxy(s.TemplatesPath, s.ConfigsPath).CreateConfigFromTemplates()
+
+	s.Error(err)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_RecordsConfigTime() {
+	statusOrig := status
+	defer func() { status = statusOrig }()
+	status = Status{}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath).CreateConfigFromTemplates()
+
+	s.False(GetStatus().LastConfigTime.IsZero())
+}
+
 func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsDebug() {
 	debugOrig := os.Getenv("DEBUG")
 	defer func() { os.Setenv("DEBUG", debugOrig) }()
@@ -311,6 +390,27 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsDebug() {
 	s.Equal(expectedData, actualData)
 }
 
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_LogsAllHttpRequests_WhenLogMetricsIsTrue() {
+	defer os.Unsetenv("LOG_METRICS")
+	os.Setenv("LOG_METRICS", "true")
+	var actualData string
+	tmpl := strings.Replace(s.TemplateContent, "tune.ssl.default-dh-param 2048", "tune.ssl.default-dh-param 2048\n    log 127.0.0.1:5140 local0", -1)
+	tmpl = strings.Replace(tmpl, "    option  dontlog-normal\n", "    log     global\n    option  httplog\n", -1)
+	expectedData := fmt.Sprintf(
+		"%s%s",
+		tmpl,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath).CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
 func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsExtraGlobal() {
 	globalOrig := os.Getenv("EXTRA_GLOBAL")
 	defer func() { os.Setenv("EXTRA_GLOBAL", globalOrig) }()
@@ -332,6 +432,303 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsExtraGlobal() {
 	s.Equal(expectedData, actualData)
 }
 
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_RendersTheSameFrontend_WhenCalledAgain() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName:   "my-service",
+		ServiceDomain: []string{"*domain-1.com"},
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+	p.CreateConfigFromTemplates()
+	expected := actualData
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(expected, "acl domain_my-service req.hdr(host),field(1,:),regsub([.]$,) -m end -i domain-1.com")
+	s.Equal(expected, actualData)
+	s.Equal([]string{"*domain-1.com"}, data.Services["my-service"].ServiceDomain)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_RendersFrontendAgain_WhenEnvironmentChanges() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName: "my-service",
+		ReqMode:     "tcp",
+		ServiceDest: []ServiceDest{
+			{Port: "1111", SrcPort: 1234},
+		},
+	}
+	p.CreateConfigFromTemplates()
+	defer os.Unsetenv("BIND_IPV6")
+	os.Setenv("BIND_IPV6", "true")
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, "bind :::1234 v4v6")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsExternalCheck_WhenServiceHasExternalCheckCommand() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName:          "my-service",
+		ExternalCheckCommand: "/scripts/check-lag.sh",
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, "tune.ssl.default-dh-param 2048\n    external-check\n")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_LoadsLua_WhenServiceHasRewriteResponseUrls() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName:         "my-service",
+		RewriteResponseUrls: true,
+		StripPath:           true,
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, "tune.ssl.default-dh-param 2048\n    lua-load /lua/rewrite-response-urls.lua\n")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_LoadsLua_WhenServiceHasCorsPreflight() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName:   "my-service",
+		CorsPreflight: true,
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, "tune.ssl.default-dh-param 2048\n    lua-load /lua/cors-preflight.lua\n")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_LoadsLua_WhenServiceHasMirrorToService() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName:     "my-service",
+		MirrorToService: "my-service-canary",
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, "tune.ssl.default-dh-param 2048\n    lua-load /lua/mirror.lua\n")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsExternalCheck_WhenServiceHasDbWriterCheckCommand() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-db"] = Service{
+		ServiceName:          "my-db",
+		ReqMode:              "tcp",
+		DbWriters:            []string{"pg-0", "pg-1"},
+		DbWriterCheckCommand: "/scripts/is-primary.sh",
+		ServiceDest: []ServiceDest{
+			{Port: "5432", SrcPort: 5432, DbRole: "writer"},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, "tune.ssl.default-dh-param 2048\n    external-check\n")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_LoadsLua_WhenServiceHasMqttStickiness() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["mqtt"] = Service{
+		ServiceName:    "mqtt",
+		ReqMode:        "tcp",
+		TcpPreset:      "mqtt",
+		MqttStickiness: true,
+		ServiceDest: []ServiceDest{
+			{Port: "1883", SrcPort: 1883},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, "tune.ssl.default-dh-param 2048\n    lua-load /lua/mqtt-client-id.lua\n")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsPreviewFallback_WhenPreviewDomainIsSet() {
+	defer func() { os.Unsetenv("PREVIEW_DOMAIN") }()
+	os.Setenv("PREVIEW_DOMAIN", "preview.acme.com")
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName: "my-service",
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, `    use_backend my-service-be1111 if url_my-service_1111
+    acl preview_domain req.hdr(host),field(1,:),regsub([.]$,) -m end -i .preview.acme.com
+    use_backend preview-be if preview_domain`)
+	s.True(strings.HasSuffix(actualData, `backend preview-be
+    mode http
+    http-request set-header X-Preview-Uri %[url]
+    http-request set-path /v1/docker-flow-proxy/preview
+    server preview 127.0.0.1:8080`))
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_RoutesAcmeChallenges_WhenAcmeChallengeAddressIsSet() {
+	defer func() { os.Unsetenv("ACME_CHALLENGE_ADDRESS") }()
+	os.Setenv("ACME_CHALLENGE_ADDRESS", "certbot")
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName: "my-service",
+		HttpsOnly:   true,
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, `
+    acl acme_challenge path_beg /.well-known/acme-challenge/
+    use_backend acme-challenge-be if acme_challenge
+    acl url_my-service_1111 path_beg /
+    redirect scheme https if !{ ssl_fc } url_my-service_1111 !acme_challenge
+    use_backend my-service-be1111 if url_my-service_1111`)
+	s.True(strings.HasSuffix(actualData, `backend acme-challenge-be
+    mode http
+    server acme-challenge certbot:80`))
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ServesAcmeChallengesThroughProxy_WhenAcmeChallengePathIsSet() {
+	defer func() { os.Unsetenv("ACME_CHALLENGE_PATH") }()
+	os.Setenv("ACME_CHALLENGE_PATH", "/var/www/acme")
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+
+	p.CreateConfigFromTemplates()
+
+	s.True(strings.HasSuffix(actualData, `backend acme-challenge-be
+    mode http
+    http-request set-query token=%[path,field(4,/)]
+    http-request set-path /v1/docker-flow-proxy/acme-challenge
+    server acme-challenge 127.0.0.1:8080`))
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_SendsPreviewsAndAcmeChallengesToApiPort() {
+	defer func() {
+		os.Unsetenv("PREVIEW_DOMAIN")
+		os.Unsetenv("ACME_CHALLENGE_PATH")
+		ApiPort = "8080"
+	}()
+	os.Setenv("PREVIEW_DOMAIN", "preview.acme.com")
+	os.Setenv("ACME_CHALLENGE_PATH", "/var/www/acme")
+	ApiPort = "9090"
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, "\n    server preview 127.0.0.1:9090\n")
+	s.True(strings.HasSuffix(actualData, "\n    server acme-challenge 127.0.0.1:9090"))
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsFallbackProxy_WhenFallbackProxyIsSet() {
+	defer func() { os.Unsetenv("FALLBACK_PROXY") }()
+	os.Setenv("FALLBACK_PROXY", "proxy.cluster-2.acme.com")
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName: "my-service",
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, `    use_backend my-service-be1111 if url_my-service_1111
+    use_backend fallback-be unless { req.hdr(X-Dfp-Fallback) -m found }`)
+	s.True(strings.HasSuffix(actualData, `backend fallback-be
+    mode http
+    http-request set-header X-Dfp-Fallback true
+    server fallback proxy.cluster-2.acme.com:80`))
+}
+
 func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsExtraFrontEnd() {
 	extraFrontendOrig := os.Getenv("EXTRA_FRONTEND")
 	defer func() { os.Setenv("EXTRA_FRONTEND", extraFrontendOrig) }()
@@ -358,10 +755,10 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEnd() {
 	tmpl := s.TemplateContent
 	expectedData := fmt.Sprintf(
 		`%s
-    acl url_my-acl1111 path_beg /path-1 path_beg /path-2 port1111Acl
-    acl url_my-acl2222 path_beg /path-3 port2222Acl
-    use_backend my-service-1-be1111 if url_my-acl1111 my-src-port
-    use_backend my-service-1-be2222 if url_my-acl2222%s`,
+    acl url_my-acl_1111 path_beg /path-1 path_beg /path-2 port1111Acl
+    acl url_my-acl_2222 path_beg /path-3 port2222Acl
+    use_backend my-service-1-be1111 if url_my-acl_1111 my-src-port
+    use_backend my-service-1-be2222 if url_my-acl_2222%s`,
 		tmpl,
 		s.ServicesContent,
 	)
@@ -390,12 +787,12 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsSortedContentFrontE
 	tmpl := s.TemplateContent
 	expectedData := fmt.Sprintf(
 		`%s
-    acl url_acl11111 path_beg /path
-    use_backend my-second-service-be1111 if url_acl11111
-    acl url_acl21111 path_beg /path
-    use_backend my-first-service-be1111 if url_acl21111
-    acl url_the-last-service1111 path_beg /path
-    use_backend the-last-service-be1111 if url_the-last-service1111%s`,
+    acl url_acl1_1111 path_beg /path
+    use_backend my-second-service-be1111 if url_acl1_1111
+    acl url_acl2_1111 path_beg /path
+    use_backend my-first-service-be1111 if url_acl2_1111
+    acl url_the-last-service_1111 path_beg /path
+    use_backend the-last-service-be1111 if url_the-last-service_1111%s`,
 		tmpl,
 		s.ServicesContent,
 	)
@@ -404,45 +801,618 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsSortedContentFrontE
 		return nil
 	}
 	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
-	// Will be listed second because of AclName
-	data.Services["my-first-service"] = Service{
-		ServiceName: "my-first-service",
-		AclName:     "acl2",
-		ServiceDest: []ServiceDest{
-			{Port: "1111", ServicePath: []string{"/path"}},
-		},
-	}
-	// Will be listed last because of ServiceName (there is no AclName)
-	data.Services["the-last-service"] = Service{
-		ServiceName: "the-last-service",
+	// Will be listed second because of AclName
+	data.Services["my-first-service"] = Service{
+		ServiceName: "my-first-service",
+		AclName:     "acl2",
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+	// Will be listed last because of ServiceName (there is no AclName)
+	data.Services["the-last-service"] = Service{
+		ServiceName: "the-last-service",
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+	// Will be listed first because of AclName
+	data.Services["my-second-service"] = Service{
+		ServiceName: "my-second-service",
+		AclName:     "acl1",
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndSortedByAclPriority() {
+	var actualData string
+	tmpl := s.TemplateContent
+	expectedData := fmt.Sprintf(
+		`%s
+    acl url_acl2_1111 path_beg /path
+    use_backend my-first-service-be1111 if url_acl2_1111
+    acl url_acl1_1111 path_beg /path
+    use_backend my-second-service-be1111 if url_acl1_1111%s`,
+		tmpl,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	// Will be listed first because of AclPriority
+	data.Services["my-first-service"] = Service{
+		ServiceName: "my-first-service",
+		AclName:     "acl2",
+		AclPriority: 10,
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+	data.Services["my-second-service"] = Service{
+		ServiceName: "my-second-service",
+		AclName:     "acl1",
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndSortedByDomainAclPriority() {
+	var actualData string
+	tmpl := s.TemplateContent
+	expectedData := fmt.Sprintf(
+		`%s
+    acl url_my-first-service_0_1111 path_beg /path
+    acl domain_my-first-service_0 req.hdr(host),field(1,:),regsub([.]$,) -m str -i api.acme.com
+    use_backend my-first-service-be1111 if url_my-first-service_0_1111 domain_my-first-service_0
+    acl url_my-second-service_1111 path_beg /path
+    acl domain_my-second-service req.hdr(host),field(1,:),regsub([.]$,) -m end -i .acme.com
+    use_backend my-second-service-be1111 if url_my-second-service_1111 domain_my-second-service
+    acl url_my-first-service_1111 path_beg /path
+    acl domain_my-first-service req.hdr(host),field(1,:),regsub([.]$,) -m str -i www.acme.com
+    use_backend my-first-service-be1111 if url_my-first-service_1111 domain_my-first-service%s`,
+		tmpl,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	// api.acme.com is listed before the wildcard of the second service because of its AclPriority
+	data.Services["my-first-service"] = Service{
+		ServiceName:       "my-first-service",
+		AclPriority:       -1,
+		ServiceDomain:     []string{"api.acme.com", "www.acme.com"},
+		DomainAclPriority: map[string]int{"api.acme.com": 10},
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+	data.Services["my-second-service"] = Service{
+		ServiceName:   "my-second-service",
+		ServiceDomain: []string{"*.acme.com"},
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndTcp() {
+	var actualData string
+	tmpl := s.TemplateContent
+	expectedData := fmt.Sprintf(
+		`%s
+
+frontend my-service-1_1234
+    bind *:1234
+    mode tcp
+    default_backend my-service-1-be1234%s`,
+		tmpl,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service-1"] = Service{
+		ReqMode:     "tcp",
+		ServiceName: "my-service-1",
+		ServiceDest: []ServiceDest{
+			{SrcPort: 1234, Port: "4321"},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_RendersFrontendTemplateOfSrcPort_WhenFrontendTemplatePathIsSet() {
+	readFileOrig := ReadFile
+	defer func() { ReadFile = readFileOrig }()
+	template := `
+frontend {{.Name}}
+    bind {{.Bind}}
+    mode tcp
+    option tcplog
+    tcp-request inspect-delay 5s
+    tcp-request content reject unless { req.payload(0,1) -m bin 10 }
+    default_backend {{.Backend}}
+`
+	ReadFile = func(filename string) ([]byte, error) {
+		if filename == "/templates/mqtt-fe.tmpl" {
+			return []byte(template), nil
+		}
+		return nil, fmt.Errorf("This is an error")
+	}
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["mqtt"] = Service{
+		ReqMode:     "tcp",
+		ServiceName: "mqtt",
+		ServiceDest: []ServiceDest{
+			{SrcPort: 1883, Port: "1883", FrontendTemplatePath: "/templates/mqtt-fe.tmpl"},
+			{SrcPort: 8883, Port: "8883"},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, `
+
+frontend mqtt_1883
+    bind *:1883
+    mode tcp
+    option tcplog
+    tcp-request inspect-delay 5s
+    tcp-request content reject unless { req.payload(0,1) -m bin 10 }
+    default_backend mqtt-be1883
+
+frontend mqtt_8883
+    bind *:8883
+    mode tcp
+    default_backend mqtt-be8883`)
+
+	template = `
+frontend {{.Name}}
+    bind {{.Bind}}
+    mode tcp
+    option tcplog
+    default_backend {{.Backend}}`
+	p.CreateConfigFromTemplates()
+
+	s.NotContains(actualData, "inspect-delay")
+	s.Contains(actualData, "    option tcplog\n    default_backend mqtt-be1883")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_RendersStandardFrontend_WhenFrontendTemplateCannotBeRead() {
+	readFileOrig := ReadFile
+	defer func() { ReadFile = readFileOrig }()
+	ReadFile = func(filename string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["mqtt"] = Service{
+		ReqMode:     "tcp",
+		ServiceName: "mqtt",
+		ServiceDest: []ServiceDest{{SrcPort: 1883, Port: "1883", FrontendTemplatePath: "/templates/mqtt-fe.tmpl"}},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, `
+
+frontend mqtt_1883
+    bind *:1883
+    mode tcp
+    default_backend mqtt-be1883`)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_RendersServicesAgain_WhenGlobalsChange() {
+	defer ResetGlobals(map[string]string{})
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName:   "my-service",
+		ServiceDomain: []string{"acme.com"},
+		NormalizeUri:  true,
+		ServiceDest:   []ServiceDest{{Port: "1111", ServicePath: []string{"/path"}}},
+	}
+	p.CreateConfigFromTemplates()
+	s.Contains(actualData, "http-request normalize-uri path-merge-slashes if domain_my-service")
+
+	SetGlobals(map[string]string{"normalizeUri": "true"})
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, "http-request normalize-uri path-merge-slashes\n")
+	s.NotContains(actualData, "http-request normalize-uri path-merge-slashes if domain_my-service")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_NormalizesUrisOfAllRequests_WhenNormalizeUriIsTrue() {
+	defer os.Unsetenv("NORMALIZE_URI")
+	os.Setenv("NORMALIZE_URI", "true")
+	var actualData string
+	expectedData := fmt.Sprintf(
+		`%s
+    http-request normalize-uri percent-decode-unreserved
+    http-request normalize-uri percent-to-uppercase
+    http-request normalize-uri path-strip-dotdot
+    http-request normalize-uri path-strip-dot
+    http-request normalize-uri path-merge-slashes
+    acl url_my-service_1111 path_beg /path
+    use_backend my-service-be1111 if url_my-service_1111%s`,
+		s.TemplateContent,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName:  "my-service",
+		NormalizeUri: true,
+		ServiceDest:  []ServiceDest{{Port: "1111", ServicePath: []string{"/path"}}},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_LimitsRequestSize_WhenLimitsArePresent() {
+	defer func() {
+		os.Unsetenv("MAX_HEADER_COUNT")
+		os.Unsetenv("MAX_HEADER_SIZE")
+		os.Unsetenv("MAX_URL_LENGTH")
+	}()
+	os.Setenv("MAX_HEADER_COUNT", "50")
+	os.Setenv("MAX_HEADER_SIZE", "8192")
+	os.Setenv("MAX_URL_LENGTH", "4096")
+	var actualData string
+	tmpl := strings.Replace(
+		s.TemplateContent,
+		"tune.ssl.default-dh-param 2048",
+		"tune.ssl.default-dh-param 2048\n    tune.http.maxhdr 50\n    tune.bufsize 9216\n    tune.maxrewrite 1024",
+		-1,
+	)
+	expectedData := fmt.Sprintf(
+		`%s
+    http-request deny deny_status 414 if { url_len gt 4096 }%s`,
+		tmpl,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath).CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_DeniesClientsInGlobalBlocklist_WhenBlocklistIsTrue() {
+	defer os.Unsetenv("BLOCKLIST")
+	os.Setenv("BLOCKLIST", "true")
+	actualFiles := map[string]string{}
+	expectedData := fmt.Sprintf(
+		`%s
+    tcp-request connection reject if { src,map_ip(%s/blocklist-ips.map) -m found }
+    http-request deny deny_status 403 if { req.hdr(user-agent),map_sub(%s/blocklist-user-agents.map) -m found }
+    acl url_my-service_1111 path_beg /path
+    use_backend my-service-be1111 if url_my-service_1111%s`,
+		s.TemplateContent,
+		s.ConfigsPath,
+		s.ConfigsPath,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualFiles[filename] = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName: "my-service",
+		ServiceDest: []ServiceDest{{Port: "1111", ServicePath: []string{"/path"}}},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualFiles[s.ConfigsPath+"/haproxy.cfg"])
+	s.Contains(actualFiles, s.ConfigsPath+"/blocklist-ips.map")
+	s.Contains(actualFiles, s.ConfigsPath+"/blocklist-user-agents.map")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndSNI() {
+	var actualData string
+	tmpl := s.TemplateContent
+	expectedData := fmt.Sprintf(
+		`%s
+
+frontend service_1234
+    bind *:1234
+    mode tcp
+    tcp-request inspect-delay 5s
+    tcp-request content accept if { req_ssl_hello_type 1 }
+    acl sni_my-service-14321
+    use_backend my-service-1-be4321 if sni_my-service-14321%s`,
+		tmpl,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service-1"] = Service{
+		ReqMode:     "sni",
+		ServiceName: "my-service-1",
+		ServiceDest: []ServiceDest{
+			{SrcPort: 1234, Port: "4321"},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndSNI443() {
+	defaultPortsOrig := os.Getenv("DEFAULT_PORTS")
+	defer func() { os.Setenv("DEFAULT_PORTS", defaultPortsOrig) }()
+	os.Setenv("DEFAULT_PORTS", "80")
+	var actualData string
+	tmpl := strings.Replace(
+		s.TemplateContent,
+		"\n    bind *:443",
+		"",
+		-1)
+	expectedData := fmt.Sprintf(
+		`%s
+
+frontend service_443
+    bind *:443
+    mode tcp
+    tcp-request inspect-delay 5s
+    tcp-request content accept if { req_ssl_hello_type 1 }
+    acl sni_my-service-14321
+    use_backend my-service-1-be4321 if sni_my-service-14321%s`,
+		tmpl,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service-1"] = Service{
+		ReqMode:     "sni",
+		ServiceName: "my-service-1",
+		ServiceDest: []ServiceDest{
+			{SrcPort: 443, Port: "4321"},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsCaseInsensitiveAndTrailingSlashPaths() {
+	var actualData string
+	tmpl := s.TemplateContent
+	expectedData := fmt.Sprintf(
+		`%s
+    acl url_my-service_1111 path -i /api path -i /api/ path -i /
+    use_backend my-service-be1111 if url_my-service_1111%s`,
+		tmpl,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName:              "my-service",
+		AclName:                  "my-service",
+		PathType:                 "path",
+		PathMatchCaseInsensitive: true,
+		NormalizeTrailingSlash:   true,
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/api/", "/"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsCaptures_WhenCaptureRequestHeadersOrCookiesAreSet() {
+	var actualData string
+	actualFiles := []string{}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualFiles = append(actualFiles, filename)
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName:           "my-service",
+		AclName:               "my-service",
+		CaptureRequestHeaders: []string{"X-Request-Id"},
+		CaptureCookies:        []string{"session"},
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	mapPath := s.ConfigsPath + "/capture-disabled.map"
+	s.Equal([]string{mapPath, s.ConfigsPath + "/haproxy.cfg"}, actualFiles)
+	s.Contains(actualData, "\n    option  httplog")
+	s.Contains(actualData, fmt.Sprintf(`
+    http-request capture req.hdr(X-Request-Id) len 128 if url_my-service_1111 !{ str(my-service),map(%s) -m found }
+    http-request capture req.cook(session) len 128 if url_my-service_1111 !{ str(my-service),map(%s) -m found }
+    use_backend my-service-be1111 if url_my-service_1111`, mapPath, mapPath))
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsPunycodeDomain_WhenDomainIsInternationalized() {
+	var actualData string
+	tmpl := s.TemplateContent
+	expectedData := fmt.Sprintf(
+		`%s
+    acl url_my-service_1111 path_beg /path
+    acl domain_my-service req.hdr(host),field(1,:),regsub([.]$,) -m str -i münchen.de xn--mnchen-3ya.de acme.com
+    use_backend my-service-be1111 if url_my-service_1111 domain_my-service%s`,
+		tmpl,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName:   "my-service",
+		ServiceDomain: []string{"münchen.de", "acme.com"},
+		AclName:       "my-service",
+		PathType:      "path_beg",
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWithDomain() {
+	var actualData string
+	tmpl := s.TemplateContent
+	expectedData := fmt.Sprintf(
+		`%s
+    acl url_my-service_1111 path_beg /path
+    acl domain_my-service req.hdr(host),field(1,:),regsub([.]$,) -m str -i domain-1 domain-2
+    use_backend my-service-be1111 if url_my-service_1111 domain_my-service%s`,
+		tmpl,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my-service"] = Service{
+		ServiceName:   "my-service",
+		ServiceDomain: []string{"domain-1", "domain-2"},
+		AclName:       "my-service",
+		PathType:      "path_beg",
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+
+	p.CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_UsesIdentifiers_WhenServiceNameHasInvalidCharacters() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["my service"] = Service{
+		ServiceName: "my service",
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
+	}
+	id := GetIdentifier("my service")
+
+	p.CreateConfigFromTemplates()
+
+	s.Contains(actualData, fmt.Sprintf(`
+    acl url_%s_1111 path_beg /path
+    use_backend %s-be1111 if url_%s_1111`, id, id, id))
+	s.NotContains(actualData, "my service")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_SeparatesPortFromAclName() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
+	data.Services["app"] = Service{
+		ServiceName: "app",
 		ServiceDest: []ServiceDest{
-			{Port: "1111", ServicePath: []string{"/path"}},
+			{Port: "11111", ServicePath: []string{"/app"}},
 		},
 	}
-	// Will be listed first because of AclName
-	data.Services["my-second-service"] = Service{
-		ServiceName: "my-second-service",
-		AclName:     "acl1",
+	data.Services["app1"] = Service{
+		ServiceName: "app1",
 		ServiceDest: []ServiceDest{
-			{Port: "1111", ServicePath: []string{"/path"}},
+			{Port: "1111", ServicePath: []string{"/app1"}},
 		},
 	}
 
 	p.CreateConfigFromTemplates()
 
-	s.Equal(expectedData, actualData)
+	s.Contains(actualData, `
+    acl url_app_11111 path_beg /app`)
+	s.Contains(actualData, `
+    acl url_app1_1111 path_beg /app1`)
 }
 
-func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndTcp() {
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsSrcNetworksAcl_WhenServiceHasSrcNetworks() {
 	var actualData string
 	tmpl := s.TemplateContent
 	expectedData := fmt.Sprintf(
 		`%s
-
-frontend my-service-1_1234
-    bind *:1234
-    mode tcp
-    default_backend my-service-1-be1234%s`,
+    acl url_my-service_1111 path_beg /path
+    acl domain_my-service req.hdr(host),field(1,:),regsub([.]$,) -m str -i domain-1
+    acl src_net_my-service src 10.0.0.0/24 10.0.1.5
+    use_backend my-service-be1111 if url_my-service_1111 domain_my-service src_net_my-service%s`,
 		tmpl,
 		s.ServicesContent,
 	)
@@ -451,11 +1421,14 @@ frontend my-service-1_1234
 		return nil
 	}
 	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
-	data.Services["my-service-1"] = Service{
-		ReqMode:     "tcp",
-		ServiceName: "my-service-1",
+	data.Services["my-service"] = Service{
+		ServiceName:   "my-service",
+		ServiceDomain: []string{"domain-1"},
+		SrcNetworks:   []string{"10.0.0.0/24", "10.0.1.5"},
+		AclName:       "my-service",
+		PathType:      "path_beg",
 		ServiceDest: []ServiceDest{
-			{SrcPort: 1234, Port: "4321"},
+			{Port: "1111", ServicePath: []string{"/path"}},
 		},
 	}
 
@@ -464,30 +1437,17 @@ frontend my-service-1_1234
 	s.Equal(expectedData, actualData)
 }
 
-func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndSNI() {
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_RejectsOtherNetworks_WhenTcpServiceHasSrcNetworks() {
 	var actualData string
-	tmpl := s.TemplateContent
-	expectedData := fmt.Sprintf(
-		`%s
-
-frontend service_1234
-    bind *:1234
-    mode tcp
-    tcp-request inspect-delay 5s
-    tcp-request content accept if { req_ssl_hello_type 1 }
-    acl sni_my-service-14321
-    use_backend my-service-1-be4321 if sni_my-service-14321%s`,
-		tmpl,
-		s.ServicesContent,
-	)
 	writeFile = func(filename string, data []byte, perm os.FileMode) error {
 		actualData = string(data)
 		return nil
 	}
 	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
 	data.Services["my-service-1"] = Service{
-		ReqMode:     "sni",
+		ReqMode:     "tcp",
 		ServiceName: "my-service-1",
+		SrcNetworks: []string{"10.0.0.0/24"},
 		ServiceDest: []ServiceDest{
 			{SrcPort: 1234, Port: "4321"},
 		},
@@ -495,29 +1455,20 @@ frontend service_1234
 
 	p.CreateConfigFromTemplates()
 
-	s.Equal(expectedData, actualData)
+	s.Contains(actualData, `
+    mode tcp
+    tcp-request connection reject unless { src 10.0.0.0/24 }
+    default_backend my-service-1-be1234`)
 }
 
-func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndSNI443() {
-	defaultPortsOrig := os.Getenv("DEFAULT_PORTS")
-	defer func() { os.Setenv("DEFAULT_PORTS", defaultPortsOrig) }()
-	os.Setenv("DEFAULT_PORTS", "80")
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWithHdrDom_WhenServiceDomainMatchAllIsSet() {
 	var actualData string
-	tmpl := strings.Replace(
-		s.TemplateContent,
-		"\n    bind *:443",
-		"",
-		-1)
+	tmpl := s.TemplateContent
 	expectedData := fmt.Sprintf(
 		`%s
-
-frontend service_443
-    bind *:443
-    mode tcp
-    tcp-request inspect-delay 5s
-    tcp-request content accept if { req_ssl_hello_type 1 }
-    acl sni_my-service-14321
-    use_backend my-service-1-be4321 if sni_my-service-14321%s`,
+    acl url_my-service_1111 path_beg /path
+    acl domain_my-service req.hdr(host),field(1,:),regsub([.]$,) -m dom -i domain-1 domain-2
+    use_backend my-service-be1111 if url_my-service_1111 domain_my-service%s`,
 		tmpl,
 		s.ServicesContent,
 	)
@@ -526,11 +1477,14 @@ frontend service_443
 		return nil
 	}
 	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
-	data.Services["my-service-1"] = Service{
-		ReqMode:     "sni",
-		ServiceName: "my-service-1",
+	data.Services["my-service"] = Service{
+		ServiceName:   "my-service",
+		ServiceDomain: []string{"domain-1", "domain-2"},
+		ServiceDomainMatchAll: true,
+		AclName:       "my-service",
+		PathType:      "path_beg",
 		ServiceDest: []ServiceDest{
-			{SrcPort: 443, Port: "4321"},
+			{Port: "1111", ServicePath: []string{"/path"}},
 		},
 	}
 
@@ -539,14 +1493,12 @@ frontend service_443
 	s.Equal(expectedData, actualData)
 }
 
-func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWithDomain() {
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWithDomainWildcard() {
 	var actualData string
 	tmpl := s.TemplateContent
 	expectedData := fmt.Sprintf(
 		`%s
-    acl url_my-service1111 path_beg /path
-    acl domain_my-service hdr(host) -i domain-1 domain-2
-    use_backend my-service-be1111 if url_my-service1111 domain_my-service%s`,
+    acl domain_my-service req.hdr(host),field(1,:),regsub([.]$,) -m end -i domain-1%s`,
 		tmpl,
 		s.ServicesContent,
 	)
@@ -557,12 +1509,7 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWith
 	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
 	data.Services["my-service"] = Service{
 		ServiceName:   "my-service",
-		ServiceDomain: []string{"domain-1", "domain-2"},
-		AclName:       "my-service",
-		PathType:      "path_beg",
-		ServiceDest: []ServiceDest{
-			{Port: "1111", ServicePath: []string{"/path"}},
-		},
+		ServiceDomain: []string{"*domain-1"},
 	}
 
 	p.CreateConfigFromTemplates()
@@ -570,14 +1517,16 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWith
 	s.Equal(expectedData, actualData)
 }
 
-func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWithHdrDom_WhenServiceDomainMatchAllIsSet() {
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWithHttpsPort() {
 	var actualData string
 	tmpl := s.TemplateContent
 	expectedData := fmt.Sprintf(
 		`%s
-    acl url_my-service1111 path_beg /path
-    acl domain_my-service hdr_dom(host) -i domain-1 domain-2
-    use_backend my-service-be1111 if url_my-service1111 domain_my-service%s`,
+    acl url_my-service_1111 path_beg /path
+    acl http_my-service src_port 80
+    acl https_my-service src_port 443
+    use_backend my-service-be1111 if url_my-service_1111 http_my-service
+    use_backend https-my-service-be1111 if url_my-service_1111 https_my-service%s`,
 		tmpl,
 		s.ServicesContent,
 	)
@@ -587,11 +1536,10 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWith
 	}
 	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
 	data.Services["my-service"] = Service{
-		ServiceName:   "my-service",
-		ServiceDomain: []string{"domain-1", "domain-2"},
-		ServiceDomainMatchAll: true,
-		AclName:       "my-service",
-		PathType:      "path_beg",
+		ServiceName: "my-service",
+		PathType:    "path_beg",
+		HttpsPort:   2222,
+		AclName:     "my-service",
 		ServiceDest: []ServiceDest{
 			{Port: "1111", ServicePath: []string{"/path"}},
 		},
@@ -602,12 +1550,14 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWith
 	s.Equal(expectedData, actualData)
 }
 
-func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWithDomainWildcard() {
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ForwardsToHttpsWhenHttpsOnlyIsTrue() {
 	var actualData string
 	tmpl := s.TemplateContent
 	expectedData := fmt.Sprintf(
 		`%s
-    acl domain_my-service hdr_end(host) -i domain-1%s`,
+    acl url_my-service_1111 path_beg /path
+    redirect scheme https if !{ ssl_fc } url_my-service_1111
+    use_backend my-service-be1111 if url_my-service_1111%s`,
 		tmpl,
 		s.ServicesContent,
 	)
@@ -617,8 +1567,13 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWith
 	}
 	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
 	data.Services["my-service"] = Service{
-		ServiceName:   "my-service",
-		ServiceDomain: []string{"*domain-1"},
+		ServiceName: "my-service",
+		PathType:    "path_beg",
+		HttpsOnly:   true,
+		AclName:     "my-service",
+		ServiceDest: []ServiceDest{
+			{Port: "1111", ServicePath: []string{"/path"}},
+		},
 	}
 
 	p.CreateConfigFromTemplates()
@@ -626,16 +1581,17 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWith
 	s.Equal(expectedData, actualData)
 }
 
-func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWithHttpsPort() {
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_RedirectsToCanonicalDomain_WhenCanonicalDomainIsSet() {
 	var actualData string
 	tmpl := s.TemplateContent
 	expectedData := fmt.Sprintf(
 		`%s
-    acl url_my-service1111 path_beg /path
-    acl http_my-service src_port 80
-    acl https_my-service src_port 443
-    use_backend my-service-be1111 if url_my-service1111 http_my-service
-    use_backend https-my-service-be1111 if url_my-service1111 https_my-service%s`,
+    acl url_my-service_1111 path_beg /path
+    acl domain_my-service req.hdr(host),field(1,:),regsub([.]$,) -m str -i acme.com www.acme.com
+    acl canonical_my-service req.hdr(host),field(1,:),regsub([.]$,) -m str -i acme.com
+    redirect prefix https://acme.com code 301 if { ssl_fc } url_my-service_1111 domain_my-service !canonical_my-service
+    redirect prefix http://acme.com code 301 if !{ ssl_fc } url_my-service_1111 domain_my-service !canonical_my-service
+    use_backend my-service-be1111 if url_my-service_1111 domain_my-service%s`,
 		tmpl,
 		s.ServicesContent,
 	)
@@ -645,10 +1601,11 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWith
 	}
 	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
 	data.Services["my-service"] = Service{
-		ServiceName: "my-service",
-		PathType:    "path_beg",
-		HttpsPort:   2222,
-		AclName:     "my-service",
+		ServiceName:     "my-service",
+		PathType:        "path_beg",
+		AclName:         "my-service",
+		ServiceDomain:   []string{"acme.com", "www.acme.com"},
+		CanonicalDomain: "acme.com",
 		ServiceDest: []ServiceDest{
 			{Port: "1111", ServicePath: []string{"/path"}},
 		},
@@ -659,14 +1616,17 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsContentFrontEndWith
 	s.Equal(expectedData, actualData)
 }
 
-func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ForwardsToHttpsWhenHttpsOnlyIsTrue() {
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_RedirectsToCanonicalDomainWithHttps_WhenHttpsOnlyIsTrue() {
 	var actualData string
 	tmpl := s.TemplateContent
 	expectedData := fmt.Sprintf(
 		`%s
-    acl url_my-service1111 path_beg /path
-    redirect scheme https if !{ ssl_fc } url_my-service1111
-    use_backend my-service-be1111 if url_my-service1111%s`,
+    acl url_my-service_1111 path_beg /path
+    acl domain_my-service req.hdr(host),field(1,:),regsub([.]$,) -m str -i acme.com www.acme.com
+    acl canonical_my-service req.hdr(host),field(1,:),regsub([.]$,) -m str -i www.acme.com
+    redirect prefix https://www.acme.com code 301 if url_my-service_1111 domain_my-service !canonical_my-service
+    redirect scheme https if !{ ssl_fc } url_my-service_1111 domain_my-service
+    use_backend my-service-be1111 if url_my-service_1111 domain_my-service%s`,
 		tmpl,
 		s.ServicesContent,
 	)
@@ -676,10 +1636,12 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ForwardsToHttpsWhenHttp
 	}
 	p := NewHaProxy(s.TemplatesPath, s.ConfigsPath)
 	data.Services["my-service"] = Service{
-		ServiceName: "my-service",
-		PathType:    "path_beg",
-		HttpsOnly:   true,
-		AclName:     "my-service",
+		ServiceName:     "my-service",
+		PathType:        "path_beg",
+		AclName:         "my-service",
+		HttpsOnly:       true,
+		ServiceDomain:   []string{"acme.com", "www.acme.com"},
+		CanonicalDomain: "www.acme.com",
 		ServiceDest: []ServiceDest{
 			{Port: "1111", ServicePath: []string{"/path"}},
 		},
@@ -695,10 +1657,10 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ForwardsToHttpsWhenRedi
 	tmpl := s.TemplateContent
 	expectedData := fmt.Sprintf(
 		`%s
-    acl url_my-service1111 path_beg /path
+    acl url_my-service_1111 path_beg /path
     acl is_my-service_http hdr(X-Forwarded-Proto) http
-    redirect scheme https if is_my-service_http url_my-service1111
-    use_backend my-service-be1111 if url_my-service1111%s`,
+    redirect scheme https if is_my-service_http url_my-service_1111
+    use_backend my-service-be1111 if url_my-service_1111%s`,
 		tmpl,
 		s.ServicesContent,
 	)
@@ -746,6 +1708,26 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsBindPorts() {
 	s.Equal(expectedData, actualData)
 }
 
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_BindsIpv6_WhenBindIpv6IsTrue() {
+	defer func() { os.Unsetenv("BIND_IPV6") }()
+	os.Setenv("BIND_IPV6", "true")
+	var actualData string
+	tmpl := strings.Replace(
+		s.TemplateContent,
+		"\n    bind *:80\n    bind *:443",
+		"\n    bind :::80 v4v6\n    bind :::443 v4v6",
+		-1)
+	expectedData := fmt.Sprintf("%s%s", tmpl, s.ServicesContent)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath).CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
 func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsDefaultPorts() {
 	defaultPortsOrig := os.Getenv("DEFAULT_PORTS")
 	defer func() { os.Setenv("DEFAULT_PORTS", defaultPortsOrig) }()
@@ -819,6 +1801,44 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsCerts() {
 	s.Equal(expectedData, actualData)
 }
 
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_PutsDefaultCertFirstAndAddsStrictSni() {
+	readDirOrig := ReadDir
+	defer func() {
+		ReadDir = readDirOrig
+		os.Unsetenv("DEFAULT_CERT_NAME")
+		os.Unsetenv("STRICT_SNI")
+	}()
+	os.Setenv("DEFAULT_CERT_NAME", "my-cert-2")
+	os.Setenv("STRICT_SNI", "true")
+	mockedFiles := []os.FileInfo{}
+	for i := 1; i <= 3; i++ {
+		certName := fmt.Sprintf("my-cert-%d", i)
+		mockedFiles = append(mockedFiles, FileInfoMock{
+			NameMock: func() string {
+				return certName
+			},
+			IsDirMock: func() bool {
+				return false
+			},
+		})
+	}
+	ReadDir = func(dir string) ([]os.FileInfo, error) {
+		if dir == "/certs" {
+			return mockedFiles, nil
+		}
+		return []os.FileInfo{}, nil
+	}
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath).CreateConfigFromTemplates()
+
+	s.Contains(actualData, "\n    bind *:443 ssl crt /certs/my-cert-2 crt /certs/my-cert-1 crt /certs/my-cert-3 strict-sni\n")
+}
+
 func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsUserList() {
 	var actualData string
 	usersOrig := os.Getenv("USERS")
@@ -1020,6 +2040,155 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ReturnsError_WhenReadCo
 	s.Error(err)
 }
 
+// RenderFrontend
+
+func (s *HaProxyTestSuite) Test_RenderFrontend_ReturnsHttpFrontend() {
+	expected := `
+    acl url_my-service_1111 path_beg /path
+    use_backend my-service-be1111 if url_my-service_1111`
+
+	actual := HaProxy{}.RenderFrontend(Service{
+		ServiceName: "my-service",
+		ServiceDest: []ServiceDest{{Port: "1111", ServicePath: []string{"/path"}}},
+	})
+
+	s.Equal(expected, actual)
+}
+
+func (s *HaProxyTestSuite) Test_RenderFrontend_ReturnsTcpFrontend_WhenReqModeIsTcp() {
+	expected := `
+
+frontend my-service_1234
+    bind *:1234
+    mode tcp
+    default_backend my-service-be1234`
+
+	actual := HaProxy{}.RenderFrontend(Service{
+		ServiceName: "my-service",
+		ReqMode:     "tcp",
+		ServiceDest: []ServiceDest{{SrcPort: 1234, Port: "4321"}},
+	})
+
+	s.Equal(expected, actual)
+}
+
+func (s *HaProxyTestSuite) Test_RenderFrontend_NormalizesUrisOfServiceDomain_WhenNormalizeUriIsTrue() {
+	expected := `
+    acl url_my-service_1111 path_beg /admin
+    acl domain_my-service req.hdr(host),field(1,:),regsub([.]$,) -m str -i acme.com
+    http-request normalize-uri percent-decode-unreserved if domain_my-service
+    http-request normalize-uri percent-to-uppercase if domain_my-service
+    http-request normalize-uri path-strip-dotdot if domain_my-service
+    http-request normalize-uri path-strip-dot if domain_my-service
+    http-request normalize-uri path-merge-slashes if domain_my-service
+    use_backend my-service-be1111 if url_my-service_1111 domain_my-service`
+
+	actual := HaProxy{}.RenderFrontend(Service{
+		ServiceName:   "my-service",
+		ServiceDomain: []string{"acme.com"},
+		NormalizeUri:  true,
+		ServiceDest:   []ServiceDest{{Port: "1111", ServicePath: []string{"/admin"}}},
+	})
+
+	s.Equal(expected, actual)
+}
+
+func (s *HaProxyTestSuite) Test_RenderFrontend_DoesNotNormalizeUris_WhenHaProxyDoesNotSupportIt() {
+	defer SetHaProxyVersion(nil)
+	SetHaProxyVersion(&HaProxyVersion{Major: 2, Minor: 4})
+
+	actual := HaProxy{}.RenderFrontend(Service{
+		ServiceName:  "my-service",
+		NormalizeUri: true,
+		ServiceDest:  []ServiceDest{{Port: "1111", ServicePath: []string{"/admin"}}},
+	})
+
+	s.NotContains(actual, "normalize-uri")
+}
+
+func (s *HaProxyTestSuite) Test_RenderFrontend_DeniesClientsInBlocklist_WhenBlocklistIsTrue() {
+	expected := `
+    acl url_my-service_1111 path_beg /path
+    acl domain_my-service req.hdr(host),field(1,:),regsub([.]$,) -m str -i acme.com
+    http-request deny deny_status 403 if { src,map_ip(/cfg/blocklist-ips-my-service.map) -m found } url_my-service_1111 domain_my-service
+    http-request deny deny_status 403 if { req.hdr(user-agent),map_sub(/cfg/blocklist-user-agents-my-service.map) -m found } url_my-service_1111 domain_my-service
+    use_backend my-service-be1111 if url_my-service_1111 domain_my-service`
+
+	actual := HaProxy{ConfigsPath: "/cfg"}.RenderFrontend(Service{
+		ServiceName:   "my-service",
+		ServiceDomain: []string{"acme.com"},
+		Blocklist:     true,
+		ServiceDest:   []ServiceDest{{Port: "1111", ServicePath: []string{"/path"}}},
+	})
+
+	s.Equal(expected, actual)
+}
+
+func (s *HaProxyTestSuite) Test_RenderFrontend_DeniesLongUrls_WhenMaxUrlLengthIsPresent() {
+	expected := `
+    acl url_my-service_1111 path_beg /path
+    http-request deny deny_status 414 if { url_len gt 2048 } url_my-service_1111
+    use_backend my-service-be1111 if url_my-service_1111`
+
+	actual := HaProxy{}.RenderFrontend(Service{
+		ServiceName:  "my-service",
+		MaxUrlLength: 2048,
+		ServiceDest:  []ServiceDest{{Port: "1111", ServicePath: []string{"/path"}}},
+	})
+
+	s.Equal(expected, actual)
+}
+
+func (s *HaProxyTestSuite) Test_RenderFrontend_DeniesLongUrlsWithStatus400_WhenHaProxyDoesNotSupport414() {
+	defer SetHaProxyVersion(nil)
+	SetHaProxyVersion(&HaProxyVersion{Major: 2, Minor: 2})
+
+	actual := HaProxy{}.RenderFrontend(Service{
+		ServiceName:  "my-service",
+		MaxUrlLength: 2048,
+		ServiceDest:  []ServiceDest{{Port: "1111", ServicePath: []string{"/path"}}},
+	})
+
+	s.Contains(actual, "http-request deny deny_status 400 if { url_len gt 2048 } url_my-service_1111")
+}
+
+func (s *HaProxyTestSuite) Test_RenderFrontend_RoutesByAlpn_WhenReqModeIsSniAndAlpnIsPresent() {
+	expected := `
+
+frontend service_443
+    bind *:443
+    mode tcp
+    tcp-request inspect-delay 5s
+    tcp-request content accept if { req_ssl_hello_type 1 }
+    acl sni_my-grpc-service4321 req.ssl_sni -i acme.com
+    acl alpn_my-grpc-service4321 req.ssl_alpn h2 grpc-exp
+    use_backend my-grpc-service-be4321 if sni_my-grpc-service4321 alpn_my-grpc-service4321`
+
+	actual := HaProxy{}.RenderFrontend(Service{
+		ServiceName: "my-grpc-service",
+		ReqMode:     "sni",
+		PathType:    "req.ssl_sni -i",
+		ServiceDest: []ServiceDest{{SrcPort: 443, Port: "4321", ServicePath: []string{"acme.com"}, Alpn: []string{"h2", "grpc-exp"}}},
+	})
+
+	s.Equal(expected, actual)
+}
+
+func (s *HaProxyTestSuite) Test_RenderFrontend_IgnoresAlpn_WhenHaProxyDoesNotSupportIt() {
+	defer SetHaProxyVersion(nil)
+	SetHaProxyVersion(&HaProxyVersion{Major: 1, Minor: 7})
+
+	actual := HaProxy{}.RenderFrontend(Service{
+		ServiceName: "my-grpc-service",
+		ReqMode:     "sni",
+		PathType:    "req.ssl_sni -i",
+		ServiceDest: []ServiceDest{{SrcPort: 443, Port: "4321", ServicePath: []string{"acme.com"}, Alpn: []string{"h2"}}},
+	})
+
+	s.NotContains(actual, "alpn")
+	s.Contains(actual, "use_backend my-grpc-service-be4321 if sni_my-grpc-service4321")
+}
+
 // ReadConfig
 
 func (s *HaProxyTestSuite) Test_ReadConfig_ReturnsConfig() {
@@ -1079,6 +2248,30 @@ func (s *HaProxyTestSuite) Test_Reload_ReturnsError_WhenHaCommandFails() {
 	s.Error(err)
 }
 
+func (s *HaProxyTestSuite) Test_Reload_ReturnsConfigError_WhenHaproxyReportsAlerts() {
+	readConfigsFileOrig := readConfigsFile
+	defer func() { readConfigsFile = readConfigsFileOrig }()
+	readConfigsFile = func(filename string) ([]byte, error) {
+		return []byte("global\n    pidfile /var/run/haproxy.pid\n\nbackend my-service-be8080\n    timeout server abc\n"), nil
+	}
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		cmd.Stderr.Write([]byte("[ALERT] 286/120000 (12) : parsing [/cfg/haproxy.cfg:5] : 'timeout server' : expects an integer value\n"))
+		return fmt.Errorf("exit status 1")
+	}
+
+	err := HaProxy{}.Reload()
+
+	configErr, ok := err.(*ConfigError)
+	s.True(ok)
+	s.Equal([]ConfigIssue{{
+		Line:    5,
+		Message: "'timeout server' : expects an integer value",
+		Content: "timeout server abc",
+		Section: "backend my-service-be8080",
+	}}, configErr.Issues)
+	s.NotContains(err.Error(), "pidfile")
+}
+
 func (s *HaProxyTestSuite) Test_Reload_ReturnsError_WhenReadPidFails() {
 	readPidFile = func(fileName string) ([]byte, error) {
 		return []byte(""), fmt.Errorf("This is an error")
@@ -1089,6 +2282,37 @@ func (s *HaProxyTestSuite) Test_Reload_ReturnsError_WhenReadPidFails() {
 	s.Error(err)
 }
 
+func (s *HaProxyTestSuite) Test_Reload_RecordsStatus() {
+	statusOrig := status
+	defer func() { status = statusOrig }()
+	status = Status{ReloadCount: 2, LastReloadError: "This is an old error"}
+	s.mockHaExecCmd()
+
+	HaProxy{}.Reload()
+
+	actual := GetStatus()
+	s.Equal(3, actual.ReloadCount)
+	s.Equal(0, actual.ReloadFailureCount)
+	s.Empty(actual.LastReloadError)
+	s.False(actual.LastReloadTime.IsZero())
+}
+
+func (s *HaProxyTestSuite) Test_Reload_RecordsFailure_WhenReloadFails() {
+	statusOrig := status
+	defer func() { status = statusOrig }()
+	status = Status{}
+	readPidFile = func(fileName string) ([]byte, error) {
+		return []byte(""), fmt.Errorf("This is an error")
+	}
+
+	HaProxy{}.Reload()
+
+	actual := GetStatus()
+	s.Equal(1, actual.ReloadCount)
+	s.Equal(1, actual.ReloadFailureCount)
+	s.Contains(actual.LastReloadError, "This is an error")
+}
+
 func (s *HaProxyTestSuite) Test_Reload_RunsRunCmd() {
 	actual := HaProxyTestSuite{}.mockHaExecCmd()
 	expected := []string{
@@ -0,0 +1,22 @@
+package proxy
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+)
+
+// Matches the characters HAProxy does not allow in the names of backends, ACLs, userlists, and servers
+var invalidIdentifierRegexp = regexp.MustCompile(`[^A-Za-z0-9_.:-]`)
+
+// GetIdentifier returns the name in the form that can be used in HAProxy identifiers (e.g. <name>-be<port> or url_<name>_<port>).
+// Names that contain characters HAProxy does not allow have them replaced with underscores and are suffixed with a hash of the name
+// so that different names (e.g. "my service" and "my_service") do not result in the same identifiers.
+// The other names, including those with dots, dashes, underscores, or upper case letters, are returned unchanged.
+func GetIdentifier(name string) string {
+	if !invalidIdentifierRegexp.MatchString(name) {
+		return name
+	}
+	hash := sha1.Sum([]byte(name))
+	return fmt.Sprintf("%s_%x", invalidIdentifierRegexp.ReplaceAllString(name, "_"), hash[:4])
+}
@@ -0,0 +1,38 @@
+// +build !integration
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type IdentifierTestSuite struct {
+	suite.Suite
+}
+
+func TestIdentifierUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(IdentifierTestSuite))
+}
+
+// GetIdentifier
+
+func (s IdentifierTestSuite) Test_GetIdentifier_ReturnsName_WhenItIsValid() {
+	for _, name := range []string{"go-demo", "api.v2.acme", "My_Service", "consul:service"} {
+		s.Equal(name, GetIdentifier(name))
+	}
+}
+
+func (s IdentifierTestSuite) Test_GetIdentifier_ReplacesInvalidCharactersAndAddsHash() {
+	actual := GetIdentifier("my service/v2")
+
+	s.Regexp(`^my_service_v2_[0-9a-f]{8}$`, actual)
+	s.Equal(actual, GetIdentifier("my service/v2"))
+	s.Equal(actual, GetIdentifier(actual))
+}
+
+func (s IdentifierTestSuite) Test_GetIdentifier_ReturnsDifferentIdentifiers_WhenSanitizedNamesCollide() {
+	s.NotEqual(GetIdentifier("my service"), GetIdentifier("my_service"))
+	s.NotEqual(GetIdentifier("my service"), GetIdentifier("my@service"))
+}
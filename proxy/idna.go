@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Punycode parameters as defined in RFC 3492.
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+)
+
+// ToASCIIDomain converts an internationalized domain name (e.g. münchen.de) to its punycode form (e.g. xn--mnchen-3ya.de).
+// Labels that contain only ASCII characters are left intact so ASCII domains are returned unchanged.
+func ToASCIIDomain(domain string) string {
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if !isASCII(label) {
+			labels[i] = "xn--" + encodePunycode(strings.ToLower(label))
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// getIdnDomains returns the domains together with the punycode form of those that are internationalized.
+// Clients send the punycode form in the Host header so matching only the unicode form would never route.
+func getIdnDomains(domains []string) []string {
+	idnDomains := []string{}
+	for _, domain := range domains {
+		idnDomains = append(idnDomains, domain)
+		if asciiDomain := ToASCIIDomain(domain); asciiDomain != domain {
+			idnDomains = append(idnDomains, asciiDomain)
+		}
+	}
+	return idnDomains
+}
+
+func isASCII(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func encodePunycode(label string) string {
+	input := []rune(label)
+	output := []byte{}
+	for _, r := range input {
+		if r < punycodeInitialN {
+			output = append(output, byte(r))
+		}
+	}
+	basic := len(output)
+	handled := basic
+	if basic > 0 {
+		output = append(output, '-')
+	}
+	n := rune(punycodeInitialN)
+	delta := 0
+	bias := punycodeInitialBias
+	for handled < len(input) {
+		m := rune(utf8.MaxRune)
+		for _, r := range input {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += int(m-n) * (handled + 1)
+		n = m
+		for _, r := range input {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punycodeBase; ; k += punycodeBase {
+				t := k - bias
+				if t < punycodeTMin {
+					t = punycodeTMin
+				} else if t > punycodeTMax {
+					t = punycodeTMax
+				}
+				if q < t {
+					break
+				}
+				output = append(output, punycodeDigit(t+(q-t)%(punycodeBase-t)))
+				q = (q - t) / (punycodeBase - t)
+			}
+			output = append(output, punycodeDigit(q))
+			bias = adaptPunycodeBias(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return string(output)
+}
+
+func adaptPunycodeBias(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punycodeBase-punycodeTMin)*punycodeTMax)/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+	return k + (punycodeBase-punycodeTMin+1)*delta/(delta+punycodeSkew)
+}
+
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
@@ -0,0 +1,30 @@
+// +build !integration
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type IdnaTestSuite struct {
+	suite.Suite
+}
+
+func TestIdnaUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(IdnaTestSuite))
+}
+
+// ToASCIIDomain
+
+func (s IdnaTestSuite) Test_ToASCIIDomain_ReturnsPunycode() {
+	s.Equal("xn--mnchen-3ya.de", ToASCIIDomain("münchen.de"))
+	s.Equal("xn--bcher-kva.example.com", ToASCIIDomain("Bücher.example.com"))
+	s.Equal("xn--fiqs8s.xn--fiqz9s", ToASCIIDomain("中国.中國"))
+}
+
+func (s IdnaTestSuite) Test_ToASCIIDomain_ReturnsDomainUnchanged_WhenItIsASCII() {
+	s.Equal("acme.com", ToASCIIDomain("acme.com"))
+	s.Equal(".acme.com", ToASCIIDomain(".acme.com"))
+}
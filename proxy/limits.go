@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// The number of bytes of the buffers reserved for rewriting the headers. It is the default of HAProxy.
+const maxRewrite = 1024
+
+// getRequestLimit returns the limit defined through the environment variable or the secret.
+// Zero is returned when the value is not a positive number, in which case the default of HAProxy is used.
+func getRequestLimit(name string) int {
+	limit, err := strconv.Atoi(GetSecretOrEnvVar(name, ""))
+	if err != nil || limit <= 0 {
+		return 0
+	}
+	return limit
+}
+
+// getRequestLimitsGlobal returns the directives of the global section that limit the number and the size of the request headers.
+// The buffers are sized so that MAX_HEADER_SIZE bytes of the request line and headers fit next to the space reserved for rewrites.
+func getRequestLimitsGlobal() string {
+	global := ""
+	if maxHeaderCount := getRequestLimit("MAX_HEADER_COUNT"); maxHeaderCount > 0 {
+		global += fmt.Sprintf("\n    tune.http.maxhdr %d", maxHeaderCount)
+	}
+	if maxHeaderSize := getRequestLimit("MAX_HEADER_SIZE"); maxHeaderSize > 0 {
+		global += fmt.Sprintf("\n    tune.bufsize %d\n    tune.maxrewrite %d", maxHeaderSize+maxRewrite, maxRewrite)
+	}
+	return global
+}
+
+// getUrlLengthStatus returns the status of the requests denied because of the length of their URLs.
+// HAProxy versions that cannot respond with 414 URI Too Long respond with 400 Bad Request instead.
+func getUrlLengthStatus() int {
+	if IsFeatureSupported("maxUrlLength") {
+		return 414
+	}
+	return 400
+}
+
+// getMaxUrlLengthTemplate returns the rule that denies the requests with URLs longer than the limit of all the services.
+func getMaxUrlLengthTemplate(maxUrlLength int) string {
+	return fmt.Sprintf(`
+    http-request deny deny_status %d if { url_len gt %d }`, getUrlLengthStatus(), maxUrlLength)
+}
+
+// getServiceMaxUrlLengthTemplate returns the rules that deny the requests of the service with URLs longer than its MaxUrlLength.
+// The service needs to be rendered with its own url ACLs and conditions.
+func getServiceMaxUrlLengthTemplate() string {
+	return fmt.Sprintf(`{{range .ServiceDest}}
+    http-request deny deny_status %d if { url_len gt {{$.MaxUrlLength}} } url_{{$.AclName}}_{{.Port}}{{$.AclCondition}}{{.SrcPortAclName}}{{end}}`,
+		getUrlLengthStatus(),
+	)
+}
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Matches the alerts HAProxy reports about a line of the configuration
+// (e.g. "[ALERT] 286/120000 (12) : parsing [/cfg/haproxy.cfg:42] : 'timeout' : ...").
+var configLineAlertRegexp = regexp.MustCompile(`\[ALERT\].*?\[[^\]]*:(\d+)\]\s*:?\s*(.*)`)
+
+// Matches the alerts HAProxy reports about a frontend or a backend
+// (e.g. "[ALERT] 286/120000 (12) : Proxy 'go-demo-be8080': unable to find required default_backend: 'x'.").
+var configProxyAlertRegexp = regexp.MustCompile(`\[ALERT\].*?(?:[Pp]roxy|backend|frontend) '([^']+)'\s*:?\s*(.*)`)
+
+// The service parameters that generate the HAProxy directives, matched against the beginning of the directive
+var configDirectiveParams = []struct{ directive, param string }{
+	{"timeout server", "timeoutServer"},
+	{"timeout tunnel", "timeoutTunnel"},
+	{"http-request set-header Host", "setHostHeader"},
+	{"http-request set-header Connection", "http10Compatibility"},
+	{"http-request auth", "users"},
+	{"http-request del-header Authorization", "users"},
+	{"http-request deny deny_status 403", "blocklist"},
+	{"http-request deny deny_status 414", "maxUrlLength"},
+	{"http-request deny", "maintenance"},
+	{"http-request set-path", "reqPathSearch"},
+	{"http-request normalize-uri", "normalizeUri"},
+	{"reqrep", "reqRepSearch"},
+	{"redirect scheme", "httpsOnly"},
+	{"redirect prefix", "canonicalDomain"},
+	{"external-check command", "externalCheckCommand"},
+	{"balance", "dbRole"},
+	{"errorfile 500", "errorfile500Path"},
+	{"errorfile 502", "errorfile502Path"},
+	{"errorfile 503", "errorfile503Path"},
+	{"bind", "srcPort"},
+	{"server", "port"},
+	{"http-reuse", "httpReuse"},
+	{"tcp-check", "tcpPreset"},
+	{"tcp-request inspect-delay", "mqttStickiness"},
+	{"tcp-request content accept if { lua.mqtt_client_id", "mqttStickiness"},
+	{"stick", "mqttStickiness"},
+}
+
+// The service parameters that generate ACL criteria, matched against the content of the acl directive
+var configAclParams = []struct{ criterion, param string }{
+	{"req.hdr(host),field(1,:),regsub([.]$,)", "serviceDomain"},
+	{"req.ssl_sni", "serviceDomain"},
+	{"req.ssl_alpn", "alpn"},
+	{"path", "servicePath"},
+	{"dst_port", "srcPort"},
+	{"src", "srcNetworks"},
+}
+
+// ConfigIssue is an alert HAProxy reported about the configuration attributed to the service that generated it.
+type ConfigIssue struct {
+	// The number of the line of the configuration file. Zero when the alert does not refer to a line.
+	Line int
+	// The message of the alert.
+	Message string
+	// The content of the line.
+	Content string
+	// The frontend or the backend the line belongs to (e.g. backend go-demo-be8080).
+	Section string
+	// The name of the service that generated the line.
+	ServiceName string
+	// The reconfigure parameter that most likely generated the line.
+	Parameter string
+}
+
+func (m ConfigIssue) String() string {
+	attribution := []string{}
+	if len(m.Section) > 0 {
+		attribution = append(attribution, m.Section)
+	}
+	if len(m.ServiceName) > 0 {
+		attribution = append(attribution, "service "+m.ServiceName)
+	}
+	if len(m.Parameter) > 0 {
+		attribution = append(attribution, "parameter "+m.Parameter)
+	}
+	issue := m.Message
+	if len(attribution) > 0 {
+		issue = fmt.Sprintf("%s: %s", strings.Join(attribution, ", "), issue)
+	}
+	if m.Line > 0 {
+		issue = fmt.Sprintf("line %d (%s): %s", m.Line, m.Content, issue)
+	}
+	return issue
+}
+
+// ConfigError is returned when HAProxy rejects the configuration.
+// It holds the alerts attributed to the services so that the whole configuration does not need to be inspected.
+type ConfigError struct {
+	// The command that failed.
+	Command string
+	Err     error
+	Issues  []ConfigIssue
+}
+
+func (m *ConfigError) Error() string {
+	issues := []string{}
+	for _, issue := range m.Issues {
+		issues = append(issues, issue.String())
+	}
+	return fmt.Sprintf("Command %s\n%s\nThe configuration is invalid:\n%s", m.Command, m.Err.Error(), strings.Join(issues, "\n"))
+}
+
+// GetConfigIssues parses the alerts HAProxy reported while loading the configuration
+// and attributes them to the frontends, backends, services, and parameters that generated the failing lines.
+func GetConfigIssues(output, config string, services map[string]Service) []ConfigIssue {
+	lines := strings.Split(config, "\n")
+	issues := []ConfigIssue{}
+	for _, alert := range strings.Split(output, "\n") {
+		if matches := configLineAlertRegexp.FindStringSubmatch(alert); matches != nil {
+			line, _ := strconv.Atoi(matches[1])
+			issue := ConfigIssue{Line: line, Message: strings.TrimSpace(matches[2])}
+			if line > 0 && line <= len(lines) {
+				issue.Content = strings.TrimSpace(lines[line-1])
+				issue.Section = getConfigSection(lines, line-1)
+				issue.ServiceName = getConfigLineService(issue.Content, issue.Section, services)
+				if len(issue.ServiceName) > 0 {
+					issue.Parameter = getConfigLineParam(issue.Content)
+				}
+			}
+			issues = append(issues, issue)
+		} else if matches := configProxyAlertRegexp.FindStringSubmatch(alert); matches != nil {
+			issue := ConfigIssue{Message: strings.TrimSpace(matches[2]), Section: matches[1]}
+			if s, found := GetBackendService(matches[1], services); found {
+				issue.ServiceName = s.ServiceName
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// getConfigSection returns the header of the section (e.g. backend go-demo-be8080) the line belongs to.
+func getConfigSection(lines []string, index int) string {
+	for i := index; i >= 0; i-- {
+		line := lines[i]
+		if len(line) > 0 && line[0] != ' ' && line[0] != '\t' && line[0] != '#' {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
+// getConfigLineService returns the name of the service that generated the line.
+// Lines of the backends belong to the service of the backend.
+// Lines of the shared frontends belong to the service whose ACL or backend they reference.
+func getConfigLineService(content, section string, services map[string]Service) string {
+	fields := strings.Fields(section)
+	if len(fields) == 2 && fields[0] == "backend" {
+		if s, found := GetBackendService(fields[1], services); found {
+			return s.ServiceName
+		}
+	}
+	serviceName := ""
+	matched := ""
+	for _, s := range services {
+		for _, name := range []string{GetIdentifier(s.AclName), GetIdentifier(s.ServiceName)} {
+			// The longest name wins so that go-demo-api is not attributed to go-demo
+			if len(name) > len(matched) && strings.Contains(content, name) {
+				serviceName, matched = s.ServiceName, name
+			}
+		}
+	}
+	return serviceName
+}
+
+// getConfigLineParam returns the reconfigure parameter that most likely generated the line.
+func getConfigLineParam(content string) string {
+	if strings.HasPrefix(content, "acl ") {
+		fields := strings.Fields(content)
+		if len(fields) > 2 {
+			for _, acl := range configAclParams {
+				if strings.HasPrefix(fields[2], acl.criterion) {
+					return acl.param
+				}
+			}
+		}
+		return ""
+	}
+	for _, directive := range configDirectiveParams {
+		if content == directive.directive || strings.HasPrefix(content, directive.directive+" ") {
+			return directive.param
+		}
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LintTestSuite struct {
+	suite.Suite
+}
+
+func TestLintUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(LintTestSuite))
+}
+
+const lintConfig = `global
+    pidfile /var/run/haproxy.pid
+
+frontend services
+    bind *:80
+    acl url_go-demo_8080 path_beg /demo[
+    acl domain_go-demo-api req.hdr(host),field(1,:),regsub([.]$,) -m str -i api.acme.com
+    use_backend go-demo-be8080 if url_go-demo_8080
+
+backend go-demo-be8080
+    mode http
+    http-request set-header Host
+    server go-demo go-demo:8080
+
+backend go-demo-api-be8080
+    mode http
+    server go-demo-api go-demo-api:8080`
+
+var lintServices = map[string]Service{
+	"go-demo":     {ServiceName: "go-demo", AclName: "go-demo"},
+	"go-demo-api": {ServiceName: "go-demo-api", AclName: "go-demo-api"},
+}
+
+// GetConfigIssues
+
+func (s LintTestSuite) Test_GetConfigIssues_AttributesLinesOfBackendsToServices() {
+	output := "[ALERT] 286/120000 (12) : parsing [/cfg/haproxy.cfg:12] : 'http-request set-header' expects exactly 2 arguments.\n"
+
+	actual := GetConfigIssues(output, lintConfig, lintServices)
+
+	s.Equal([]ConfigIssue{{
+		Line:        12,
+		Message:     "'http-request set-header' expects exactly 2 arguments.",
+		Content:     "http-request set-header Host",
+		Section:     "backend go-demo-be8080",
+		ServiceName: "go-demo",
+		Parameter:   "setHostHeader",
+	}}, actual)
+}
+
+func (s LintTestSuite) Test_GetConfigIssues_AttributesLinesOfFrontendsToServicesOfAcls() {
+	output := fmt.Sprintf(
+		"%s\n%s\n",
+		"[ALERT] 286/120000 (12) : parsing [/cfg/haproxy.cfg:6] : error detected while parsing ACL 'url_go-demo_8080' : regex '/demo[' is invalid.",
+		"[ALERT] 286/120000 (12) : parsing [/cfg/haproxy.cfg:7] : error detected while parsing ACL 'domain_go-demo-api'.",
+	)
+
+	actual := GetConfigIssues(output, lintConfig, lintServices)
+
+	s.Len(actual, 2)
+	s.Equal("frontend services", actual[0].Section)
+	s.Equal("go-demo", actual[0].ServiceName)
+	s.Equal("servicePath", actual[0].Parameter)
+	s.Equal("go-demo-api", actual[1].ServiceName)
+	s.Equal("serviceDomain", actual[1].Parameter)
+}
+
+func (s LintTestSuite) Test_GetConfigIssues_AttributesAlertsAboutProxiesToServices() {
+	output := "[ALERT] 286/120000 (12) : Proxy 'go-demo-api-be8080': unable to find the server.\n[ALERT] 286/120000 (12) : Fatal errors found in configuration.\n"
+
+	actual := GetConfigIssues(output, lintConfig, lintServices)
+
+	s.Equal([]ConfigIssue{{Message: "unable to find the server.", Section: "go-demo-api-be8080", ServiceName: "go-demo-api"}}, actual)
+}
+
+func (s LintTestSuite) Test_GetConfigIssues_ReturnsEmptySlice_WhenOutputDoesNotContainAlerts() {
+	actual := GetConfigIssues("[WARNING] 286/120000 (12) : config : missing timeouts for frontend 'services'.\n", lintConfig, lintServices)
+
+	s.Empty(actual)
+}
+
+// ConfigIssue
+
+func (s LintTestSuite) Test_String_ReturnsAttributedIssue() {
+	issue := ConfigIssue{Line: 12, Message: "expects 2 arguments", Content: "http-request set-header Host", Section: "backend go-demo-be8080", ServiceName: "go-demo", Parameter: "setHostHeader"}
+
+	s.Equal("line 12 (http-request set-header Host): backend go-demo-be8080, service go-demo, parameter setHostHeader: expects 2 arguments", issue.String())
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"strings"
+	"sync"
+)
+
+// The upper bounds in seconds of the buckets of the request duration histograms
+var LogMetricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// IsLogMetricsEnabled returns whether HAProxy logs are parsed into request duration and status code metrics.
+func IsLogMetricsEnabled() bool {
+	return strings.EqualFold(GetSecretOrEnvVar("LOG_METRICS", "false"), "true")
+}
+
+// BackendRequestMetrics holds the request duration histogram and the status code distribution of a backend.
+type BackendRequestMetrics struct {
+	// The cumulative number of requests that took at most the duration of LogMetricsBuckets with the same index.
+	Buckets []int
+	// The number of requests with a known duration.
+	Count int
+	// The total duration of the requests in seconds.
+	Sum float64
+	// The number of responses keyed by the status code.
+	Codes map[string]int
+}
+
+// LogMetrics collects the request metrics of each backend from the access logs.
+type LogMetrics struct {
+	mu       *sync.Mutex
+	backends map[string]*BackendRequestMetrics
+}
+
+func NewLogMetrics() *LogMetrics {
+	return &LogMetrics{
+		mu:       &sync.Mutex{},
+		backends: map[string]*BackendRequestMetrics{},
+	}
+}
+
+// Observe adds the request to the metrics of its backend.
+func (m *LogMetrics) Observe(entry HttpLogEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	be, found := m.backends[entry.Backend]
+	if !found {
+		be = &BackendRequestMetrics{Buckets: make([]int, len(LogMetricsBuckets)), Codes: map[string]int{}}
+		m.backends[entry.Backend] = be
+	}
+	// Requests aborted before HAProxy could respond are logged with the status code -1
+	if !strings.HasPrefix(entry.Status, "-") {
+		be.Codes[entry.Status]++
+	}
+	if entry.TotalTime < 0 {
+		return
+	}
+	seconds := float64(entry.TotalTime) / 1000
+	for i, le := range LogMetricsBuckets {
+		if seconds <= le {
+			be.Buckets[i]++
+		}
+	}
+	be.Count++
+	be.Sum += seconds
+}
+
+// GetBackends returns a copy of the metrics collected so far keyed by the names of the backends.
+func (m *LogMetrics) GetBackends() map[string]BackendRequestMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	backends := map[string]BackendRequestMetrics{}
+	for name, be := range m.backends {
+		codes := map[string]int{}
+		for code, count := range be.Codes {
+			codes[code] = count
+		}
+		backends[name] = BackendRequestMetrics{
+			Buckets: append([]int{}, be.Buckets...),
+			Count:   be.Count,
+			Sum:     be.Sum,
+			Codes:   codes,
+		}
+	}
+	return backends
+}
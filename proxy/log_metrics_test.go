@@ -0,0 +1,35 @@
+// +build !integration
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LogMetricsTestSuite struct {
+	suite.Suite
+}
+
+func TestLogMetricsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(LogMetricsTestSuite))
+}
+
+// Observe
+
+func (s LogMetricsTestSuite) Test_Observe_AddsRequestsToHistogramAndCodes() {
+	metrics := NewLogMetrics()
+
+	metrics.Observe(HttpLogEntry{Backend: "go-demo-be8080", Status: "200", TotalTime: 20})
+	metrics.Observe(HttpLogEntry{Backend: "go-demo-be8080", Status: "500", TotalTime: 300})
+	metrics.Observe(HttpLogEntry{Backend: "go-demo-be8080", Status: "-1", TotalTime: -1})
+
+	actual := metrics.GetBackends()
+
+	s.Len(actual, 1)
+	s.Equal([]int{0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 2}, actual["go-demo-be8080"].Buckets)
+	s.Equal(2, actual["go-demo-be8080"].Count)
+	s.InDelta(0.32, actual["go-demo-be8080"].Sum, 0.0001)
+	s.Equal(map[string]int{"200": 1, "500": 1}, actual["go-demo-be8080"].Codes)
+}
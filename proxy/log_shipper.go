@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var logShipperClient = &http.Client{Timeout: 10 * time.Second}
+
+// The time the shipper waits before sending a batch again. It doubles after each failure.
+var logShipperRetryInterval = time.Second
+
+// The number of times a batch is sent before the shipper gives up until the next flush
+const logShipperAttempts = 3
+
+// The maximum number of entries sent in a single request
+const logShipperBatchSize = 500
+
+// LogSink stores the access logs in a log management system.
+type LogSink interface {
+	Ship(entries []HttpLogEntry) error
+}
+
+// ElasticsearchSink indexes the access logs through the Elasticsearch bulk API.
+type ElasticsearchSink struct {
+	// The URL of Elasticsearch (e.g. http://elasticsearch:9200).
+	Url string
+	// The prefix of the daily indices the logs are stored in (e.g. dfp for dfp-2026.10.14).
+	Index string
+}
+
+func (m ElasticsearchSink) Ship(entries []HttpLogEntry) error {
+	body := bytes.Buffer{}
+	for _, entry := range entries {
+		action, _ := json.Marshal(map[string]interface{}{
+			"index": map[string]string{"_index": fmt.Sprintf("%s-%s", m.Index, entry.Time.UTC().Format("2006.01.02"))},
+		})
+		doc, _ := json.Marshal(entry)
+		body.Write(action)
+		body.WriteString("\n")
+		body.Write(doc)
+		body.WriteString("\n")
+	}
+	return postLogs("Elasticsearch", strings.TrimSuffix(m.Url, "/")+"/_bulk", "application/x-ndjson", body.Bytes())
+}
+
+// LokiSink pushes the access logs through the Loki push API.
+// The entries are streamed with the job label docker-flow-proxy and the name of the backend.
+type LokiSink struct {
+	// The URL of Loki (e.g. http://loki:3100).
+	Url string
+}
+
+func (m LokiSink) Ship(entries []HttpLogEntry) error {
+	streams := map[string][][]string{}
+	backends := []string{}
+	for _, entry := range entries {
+		if _, found := streams[entry.Backend]; !found {
+			backends = append(backends, entry.Backend)
+		}
+		line, _ := json.Marshal(entry)
+		streams[entry.Backend] = append(streams[entry.Backend], []string{strconv.FormatInt(entry.Time.UnixNano(), 10), string(line)})
+	}
+	push := map[string][]interface{}{"streams": {}}
+	for _, backend := range backends {
+		push["streams"] = append(push["streams"], map[string]interface{}{
+			"stream": map[string]string{"job": "docker-flow-proxy", "backend": backend},
+			"values": streams[backend],
+		})
+	}
+	js, _ := json.Marshal(push)
+	return postLogs("Loki", strings.TrimSuffix(m.Url, "/")+"/loki/api/v1/push", "application/json", js)
+}
+
+func postLogs(sink, url, contentType string, body []byte) error {
+	resp, err := logShipperClient.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Could not ship the logs to %s\n%s", sink, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded to the logs with the status code %d", sink, resp.StatusCode)
+	}
+	return nil
+}
+
+// LogShipper buffers the access logs and ships them to a sink in batches.
+// Entries that could not be shipped stay in the buffer and are retried with the next flush.
+// When the buffer is full, the oldest entries are dropped.
+type LogShipper struct {
+	Sink LogSink
+	// The maximum number of entries kept in the buffer.
+	BufferSize int
+	mu         *sync.Mutex
+	buffer     []HttpLogEntry
+	// The number of entries dropped from the buffer since the shipper was created.
+	dropped int
+}
+
+func NewLogShipper(sink LogSink, bufferSize int) *LogShipper {
+	return &LogShipper{
+		Sink:       sink,
+		BufferSize: bufferSize,
+		mu:         &sync.Mutex{},
+		buffer:     []HttpLogEntry{},
+	}
+}
+
+// Add puts the entry into the buffer.
+func (m *LogShipper) Add(entry HttpLogEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.BufferSize > 0 && len(m.buffer) >= m.BufferSize {
+		m.dropped += len(m.buffer) - m.BufferSize + 1
+		m.buffer = m.buffer[len(m.buffer)-m.BufferSize+1:]
+	}
+	m.buffer = append(m.buffer, entry)
+}
+
+// Flush ships the buffered entries. Each batch is retried with an exponential backoff before Flush gives up.
+func (m *LogShipper) Flush() error {
+	for {
+		m.mu.Lock()
+		batch := m.buffer
+		if len(batch) > logShipperBatchSize {
+			batch = batch[:logShipperBatchSize]
+		}
+		dropped := m.dropped
+		m.mu.Unlock()
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := m.ship(batch); err != nil {
+			return err
+		}
+		m.mu.Lock()
+		// The oldest entries of the batch might have been dropped from the buffer while it was shipped
+		if shipped := len(batch) - (m.dropped - dropped); shipped > 0 {
+			m.buffer = m.buffer[shipped:]
+		}
+		m.mu.Unlock()
+	}
+}
+
+func (m *LogShipper) ship(batch []HttpLogEntry) error {
+	interval := logShipperRetryInterval
+	var err error
+	for attempt := 1; attempt <= logShipperAttempts; attempt++ {
+		if err = m.Sink.Ship(batch); err == nil {
+			return nil
+		}
+		if attempt < logShipperAttempts {
+			time.Sleep(interval)
+			interval *= 2
+		}
+	}
+	return err
+}
+
+// GetLogShippers returns the log shippers configured through the LOG_SHIPPER_* environment variables.
+func GetLogShippers() []*LogShipper {
+	bufferSize, err := strconv.Atoi(GetSecretOrEnvVar("LOG_SHIPPER_BUFFER_SIZE", "10000"))
+	if err != nil || bufferSize <= 0 {
+		bufferSize = 10000
+	}
+	shippers := []*LogShipper{}
+	if url := GetSecretOrEnvVar("LOG_SHIPPER_ELASTICSEARCH_URL", ""); len(url) > 0 {
+		sink := ElasticsearchSink{Url: url, Index: GetSecretOrEnvVar("LOG_SHIPPER_ELASTICSEARCH_INDEX", "docker-flow-proxy")}
+		shippers = append(shippers, NewLogShipper(sink, bufferSize))
+	}
+	if url := GetSecretOrEnvVar("LOG_SHIPPER_LOKI_URL", ""); len(url) > 0 {
+		shippers = append(shippers, NewLogShipper(LokiSink{Url: url}, bufferSize))
+	}
+	return shippers
+}
@@ -0,0 +1,156 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LogShipperTestSuite struct {
+	suite.Suite
+}
+
+func TestLogShipperUnitTestSuite(t *testing.T) {
+	logShipperRetryIntervalOrig := logShipperRetryInterval
+	defer func() { logShipperRetryInterval = logShipperRetryIntervalOrig }()
+	logShipperRetryInterval = time.Millisecond
+	suite.Run(t, new(LogShipperTestSuite))
+}
+
+type LogSinkMock struct {
+	errs    []error
+	batches [][]HttpLogEntry
+}
+
+func (m *LogSinkMock) Ship(entries []HttpLogEntry) error {
+	m.batches = append(m.batches, entries)
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return err
+	}
+	return nil
+}
+
+var shipperEntry = HttpLogEntry{
+	Time:      time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC),
+	Backend:   "go-demo-be8080",
+	Status:    "200",
+	TotalTime: 6,
+}
+
+// ElasticsearchSink
+
+func (s LogShipperTestSuite) Test_ElasticsearchSink_PostsEntriesToBulkApi() {
+	actualPath, actualBody := "", ""
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actualPath = r.URL.Path
+		body, _ := ioutil.ReadAll(r.Body)
+		actualBody = string(body)
+	}))
+	defer es.Close()
+
+	err := ElasticsearchSink{Url: es.URL, Index: "dfp"}.Ship([]HttpLogEntry{shipperEntry})
+
+	s.NoError(err)
+	s.Equal("/_bulk", actualPath)
+	s.Equal(`{"index":{"_index":"dfp-2026.10.14"}}
+{"Time":"2026-10-14T12:00:00Z","Client":"","Frontend":"","Backend":"go-demo-be8080","Server":"","Status":"200","TotalTime":6,"Bytes":0}
+`, actualBody)
+}
+
+func (s LogShipperTestSuite) Test_ElasticsearchSink_ReturnsError_WhenStatusIsNotOk() {
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer es.Close()
+
+	err := ElasticsearchSink{Url: es.URL, Index: "dfp"}.Ship([]HttpLogEntry{shipperEntry})
+
+	s.Error(err)
+}
+
+// LokiSink
+
+func (s LogShipperTestSuite) Test_LokiSink_PushesEntriesStreamedByBackend() {
+	actualPath, actualBody := "", ""
+	loki := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actualPath = r.URL.Path
+		body, _ := ioutil.ReadAll(r.Body)
+		actualBody = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer loki.Close()
+
+	err := LokiSink{Url: loki.URL + "/"}.Ship([]HttpLogEntry{shipperEntry})
+
+	s.NoError(err)
+	s.Equal("/loki/api/v1/push", actualPath)
+	s.Contains(actualBody, `"stream":{"backend":"go-demo-be8080","job":"docker-flow-proxy"}`)
+	s.Contains(actualBody, fmt.Sprintf(`"values":[["%d","{\"Time\":\"2026-10-14T12:00:00Z\"`, shipperEntry.Time.UnixNano()))
+}
+
+// Flush
+
+func (s LogShipperTestSuite) Test_Flush_RetriesSink() {
+	sink := &LogSinkMock{errs: []error{fmt.Errorf("This is an error"), fmt.Errorf("This is an error")}}
+	shipper := NewLogShipper(sink, 10)
+	shipper.Add(shipperEntry)
+
+	err := shipper.Flush()
+
+	s.NoError(err)
+	s.Len(sink.batches, 3)
+	s.Len(shipper.buffer, 0)
+}
+
+func (s LogShipperTestSuite) Test_Flush_KeepsEntries_WhenSinkFails() {
+	sink := &LogSinkMock{errs: []error{fmt.Errorf("This is an error"), fmt.Errorf("This is an error"), fmt.Errorf("This is an error")}}
+	shipper := NewLogShipper(sink, 10)
+	shipper.Add(shipperEntry)
+
+	err := shipper.Flush()
+
+	s.Error(err)
+	s.Len(shipper.buffer, 1)
+	s.NoError(shipper.Flush())
+	s.Len(shipper.buffer, 0)
+}
+
+// Add
+
+func (s LogShipperTestSuite) Test_Add_DropsOldestEntries_WhenBufferIsFull() {
+	shipper := NewLogShipper(&LogSinkMock{}, 2)
+
+	for _, status := range []string{"200", "404", "500"} {
+		shipper.Add(HttpLogEntry{Status: status})
+	}
+
+	s.Equal([]HttpLogEntry{{Status: "404"}, {Status: "500"}}, shipper.buffer)
+}
+
+// GetLogShippers
+
+func (s LogShipperTestSuite) Test_GetLogShippers_ReturnsConfiguredShippers() {
+	defer func() {
+		os.Unsetenv("LOG_SHIPPER_ELASTICSEARCH_URL")
+		os.Unsetenv("LOG_SHIPPER_LOKI_URL")
+	}()
+	os.Setenv("LOG_SHIPPER_ELASTICSEARCH_URL", "http://elasticsearch:9200")
+	os.Setenv("LOG_SHIPPER_LOKI_URL", "http://loki:3100")
+
+	actual := GetLogShippers()
+
+	s.Len(actual, 2)
+	s.Equal(ElasticsearchSink{Url: "http://elasticsearch:9200", Index: "docker-flow-proxy"}, actual[0].Sink)
+	s.Equal(LokiSink{Url: "http://loki:3100"}, actual[1].Sink)
+	s.Equal(10000, actual[0].BufferSize)
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"sort"
+	"strings"
+)
+
+// GetNamespacedName returns the name of a service (or an ACL) scoped to the namespace.
+// Names without a namespace and names already prefixed with the namespace are returned unchanged.
+func GetNamespacedName(namespace, name string) string {
+	if len(namespace) == 0 || len(name) == 0 || strings.HasPrefix(name, namespace+".") {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// IsInOtherNamespace returns whether the name is prefixed with one of the namespaces (e.g. team-b.my-service) other than the namespace.
+// The prefix up to the first dot of other names (e.g. my.service) is not a namespace, so they do not belong to other namespaces.
+func IsInOtherNamespace(namespace, name string, namespaces []string) bool {
+	prefix := strings.SplitN(name, ".", 2)
+	if len(prefix) != 2 || prefix[0] == namespace {
+		return false
+	}
+	for _, other := range namespaces {
+		if prefix[0] == other {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNamespaceCollisions returns the routes and domains of the services from other namespaces that collide with the service sr.
+// Unlike the conflicts between services of the same namespace, a domain can belong to a single namespace
+// since the certificates of a domain are shared by all its services.
+func GetNamespaceCollisions(sr Service, services map[string]Service) []Conflict {
+	var collisions []Conflict
+	others := map[string]Service{}
+	for name, s := range services {
+		if s.Namespace != sr.Namespace {
+			others[name] = s
+		}
+	}
+	collided := map[string]bool{}
+	for _, c := range GetConflicts(sr, others) {
+		collisions = append(collisions, c)
+		collided[c.ServiceName] = true
+	}
+	names := []string{}
+	for name := range others {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		existing := others[name]
+		if collided[existing.ServiceName] {
+			continue
+		}
+		for _, domain := range sr.ServiceDomain {
+			if isOneOf(domain, existing.ServiceDomain) {
+				collisions = append(collisions, Conflict{ServiceName: existing.ServiceName, Domain: domain})
+				break
+			}
+		}
+	}
+	return collisions
+}
+
+// GetNamespaceTokens parses a comma-separated list of <namespace>:<token> pairs into a map of tokens to namespaces.
+func GetNamespaceTokens(tokensString string) map[string]string {
+	tokens := map[string]string{}
+	for _, pair := range strings.Split(tokensString, ",") {
+		namespaceAndToken := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(namespaceAndToken) == 2 && len(namespaceAndToken[0]) > 0 && len(namespaceAndToken[1]) > 0 {
+			tokens[namespaceAndToken[1]] = namespaceAndToken[0]
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,112 @@
+// +build !integration
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type NamespaceTestSuite struct {
+	suite.Suite
+	existing map[string]Service
+}
+
+func (s *NamespaceTestSuite) SetupTest() {
+	s.existing = map[string]Service{
+		"team-a.my-service": {
+			ServiceName:   "team-a.my-service",
+			Namespace:     "team-a",
+			ServiceDomain: []string{"team-a.com"},
+			ServiceDest:   []ServiceDest{{ServicePath: []string{"/api"}}},
+		},
+	}
+}
+
+func TestNamespaceUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(NamespaceTestSuite))
+}
+
+// GetNamespacedName
+
+func (s NamespaceTestSuite) Test_GetNamespacedName_PrefixesNameWithNamespace() {
+	s.Equal("team-a.my-service", GetNamespacedName("team-a", "my-service"))
+}
+
+func (s NamespaceTestSuite) Test_GetNamespacedName_ReturnsName_WhenNameIsPrefixedWithNamespace() {
+	s.Equal("team-a.my-service", GetNamespacedName("team-a", "team-a.my-service"))
+}
+
+// IsInOtherNamespace
+
+func (s NamespaceTestSuite) Test_IsInOtherNamespace_ReturnsTrue_WhenNameIsPrefixedWithOtherNamespace() {
+	namespaces := []string{"team-a", "team-b"}
+
+	s.True(IsInOtherNamespace("team-a", "team-b.my-service", namespaces))
+	s.False(IsInOtherNamespace("team-a", "team-a.my-service", namespaces))
+	s.False(IsInOtherNamespace("team-a", "my-service", namespaces))
+}
+
+func (s NamespaceTestSuite) Test_IsInOtherNamespace_ReturnsFalse_WhenPrefixIsNotNamespace() {
+	namespaces := []string{"team-a", "team-b"}
+
+	s.False(IsInOtherNamespace("team-a", "my.service", namespaces))
+	s.False(IsInOtherNamespace("team-a", "team-bb.my-service", namespaces))
+}
+
+func (s NamespaceTestSuite) Test_GetNamespacedName_ReturnsName_WhenNamespaceIsEmpty() {
+	s.Equal("my-service", GetNamespacedName("", "my-service"))
+	s.Equal("", GetNamespacedName("team-a", ""))
+}
+
+// GetNamespaceCollisions
+
+func (s NamespaceTestSuite) Test_GetNamespaceCollisions_ReturnsCollision_WhenDomainBelongsToOtherNamespace() {
+	sr := Service{
+		ServiceName:   "team-b.other-service",
+		Namespace:     "team-b",
+		ServiceDomain: []string{"team-a.com"},
+		ServiceDest:   []ServiceDest{{ServicePath: []string{"/admin"}}},
+	}
+
+	actual := GetNamespaceCollisions(sr, s.existing)
+
+	s.Equal([]Conflict{{ServiceName: "team-a.my-service", Domain: "team-a.com"}}, actual)
+}
+
+func (s NamespaceTestSuite) Test_GetNamespaceCollisions_ReturnsCollision_WhenRouteIsShadowed() {
+	sr := Service{
+		ServiceName:   "team-b.other-service",
+		Namespace:     "team-b",
+		ServiceDomain: []string{"team-a.com"},
+		ServiceDest:   []ServiceDest{{ServicePath: []string{"/api"}}},
+	}
+
+	actual := GetNamespaceCollisions(sr, s.existing)
+
+	s.Len(actual, 1)
+	s.Equal("team-a.my-service", actual[0].ServiceName)
+	s.Equal("/api", actual[0].Path)
+}
+
+func (s NamespaceTestSuite) Test_GetNamespaceCollisions_ReturnsEmpty_WhenServicesAreInTheSameNamespace() {
+	sr := Service{
+		ServiceName:   "team-a.other-service",
+		Namespace:     "team-a",
+		ServiceDomain: []string{"team-a.com"},
+		ServiceDest:   []ServiceDest{{ServicePath: []string{"/admin"}}},
+	}
+
+	actual := GetNamespaceCollisions(sr, s.existing)
+
+	s.Empty(actual)
+}
+
+// GetNamespaceTokens
+
+func (s NamespaceTestSuite) Test_GetNamespaceTokens_ReturnsNamespacesKeyedByTokens() {
+	actual := GetNamespaceTokens("team-a:token-a, team-b:token-b,invalid")
+
+	s.Equal(map[string]string{"token-a": "team-a", "token-b": "team-b"}, actual)
+}
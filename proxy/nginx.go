@@ -0,0 +1,267 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Nginx is an experimental engine that serves the configured services through nginx instead of HAProxy.
+// The configuration is rendered from the stored services so the templates generated for HAProxy are not used.
+// It supports the *http* and *tcp* request modes with the serviceDomain, servicePath, pathType, srcPort, and httpsOnly parameters.
+type Nginx struct {
+	TemplatesPath string
+	ConfigsPath   string
+	services      *Data
+}
+
+type NginxConfigData struct {
+	MaxConn              string
+	TimeoutConnect       string
+	TimeoutClient        string
+	TimeoutServer        string
+	TimeoutHttpKeepAlive string
+	ContentHttp          string
+	ContentStream        string
+}
+
+type nginxLocation struct {
+	path      string
+	upstream  string
+	httpsOnly bool
+	// The server timeout in seconds of the destination or the service.
+	timeoutServer string
+}
+
+func NewNginx(templatesPath, configsPath string) Proxy {
+	data.Replace(map[string]Service{})
+	return Nginx{
+		TemplatesPath: templatesPath,
+		ConfigsPath:   configsPath,
+	}
+}
+
+func (m Nginx) GetCertPaths() []string {
+	return HaProxy{}.GetCertPaths()
+}
+
+func (m Nginx) GetCerts() map[string]string {
+	return HaProxy{}.GetCerts()
+}
+
+func (m Nginx) RunCmd(extraArgs []string) error {
+	configPath := fmt.Sprintf("%s/nginx.conf", m.ConfigsPath)
+	if len(extraArgs) == 0 {
+		// Unlike HAProxy, the image does not contain an initial configuration for nginx
+		if err := m.CreateConfigFromTemplates(); err != nil {
+			return err
+		}
+	}
+	args := []string{"-c", configPath}
+	args = append(args, extraArgs...)
+	cmd := exec.Command("nginx", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmdRunHa(cmd); err != nil {
+		configData, _ := readConfigsFile(configPath)
+		return fmt.Errorf("Command %s\n%s\n%s", strings.Join(cmd.Args, " "), err.Error(), string(configData))
+	}
+	return nil
+}
+
+func (m Nginx) CreateConfigFromTemplates() error {
+	content, err := m.getConfig()
+	if err != nil {
+		return err
+	}
+	configPath := fmt.Sprintf("%s/nginx.conf", m.ConfigsPath)
+	if err := writeFile(configPath, []byte(content), 0664); err != nil {
+		return err
+	}
+	recordConfig()
+	return nil
+}
+
+func (m Nginx) ReadConfig() (string, error) {
+	configPath := fmt.Sprintf("%s/nginx.conf", m.ConfigsPath)
+	out, err := ReadFile(configPath)
+	if err != nil {
+		return "", err
+	}
+	return string(out[:]), nil
+}
+
+// RenderConfig returns the configuration that would be generated from the current template and services without writing it.
+func (m Nginx) RenderConfig() (string, error) {
+	return m.getConfig()
+}
+
+func (m Nginx) Reload() error {
+	logPrintf("Reloading the proxy")
+	start := timeNow()
+	err := m.RunCmd([]string{"-s", "reload"})
+	recordReload(start, err)
+	return err
+}
+
+func (m Nginx) AddService(service Service) {
+	getData(m.services).Put(service)
+}
+
+func (m Nginx) RemoveService(service string) {
+	getData(m.services).Delete(service)
+}
+
+func (m Nginx) GetServices() map[string]Service {
+	return copyServices(getData(m.services).Snapshot())
+}
+
+func (m Nginx) getConfig() (string, error) {
+	path := fmt.Sprintf("%s/nginx.tmpl", m.TemplatesPath)
+	templateBytes, err := readConfigsFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Could not read the file %s\n%s", path, err.Error())
+	}
+	tmpl, err := template.New("nginx").Funcs(TemplateFuncs(getData(m.services).Snapshot)).Parse(string(templateBytes))
+	if err != nil {
+		return "", fmt.Errorf("Could not parse the template %s\n%s", path, err.Error())
+	}
+	var content bytes.Buffer
+	if err := tmpl.Execute(&content, m.getConfigData()); err != nil {
+		return "", fmt.Errorf("Could not render the template %s\n%s", path, err.Error())
+	}
+	return content.String(), nil
+}
+
+func (m Nginx) getConfigData() NginxConfigData {
+	services := getData(m.services).Snapshot()
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	httpContent := []string{}
+	streamContent := []string{}
+	domains := []string{}
+	locations := map[string][]nginxLocation{}
+	for _, name := range names {
+		s := services[name]
+		for _, sd := range s.ServiceDest {
+			if len(sd.Port) == 0 {
+				continue
+			}
+			upstream := fmt.Sprintf("%s-be%s", s.ServiceName, sd.Port)
+			if strings.EqualFold(s.ReqMode, "tcp") {
+				streamContent = append(streamContent, getNginxUpstream(upstream, s, sd.Port), fmt.Sprintf(`    server {
+        listen %d;
+        proxy_pass %s;
+    }`, sd.SrcPort, upstream))
+				continue
+			}
+			httpContent = append(httpContent, getNginxUpstream(upstream, s, sd.Port))
+			serviceDomains := s.ServiceDomain
+			if len(serviceDomains) == 0 {
+				serviceDomains = []string{""}
+			}
+			for _, domain := range serviceDomains {
+				if _, ok := locations[domain]; !ok {
+					domains = append(domains, domain)
+				}
+				for _, path := range sd.ServicePath {
+					if strings.EqualFold(s.PathType, "path_reg") {
+						path = "~ " + path
+					}
+					locations[domain] = append(locations[domain], nginxLocation{
+						path:          path,
+						upstream:      upstream,
+						httpsOnly:     s.HttpsOnly,
+						timeoutServer: sd.GetTimeoutServer(s),
+					})
+				}
+			}
+		}
+	}
+	if _, ok := locations[""]; !ok {
+		domains = append(domains, "")
+	}
+	sort.Strings(domains)
+	for _, domain := range domains {
+		httpContent = append(httpContent, m.getServer(domain, locations[domain]))
+	}
+	cd := NginxConfigData{
+		MaxConn:              getGlobal("maxConn"),
+		TimeoutConnect:       getGlobal("timeoutConnect"),
+		TimeoutClient:        getGlobal("timeoutClient"),
+		TimeoutServer:        getGlobal("timeoutServer"),
+		TimeoutHttpKeepAlive: getGlobal("timeoutHttpKeepAlive"),
+		ContentHttp:          strings.Join(httpContent, "\n\n"),
+	}
+	if len(streamContent) > 0 {
+		cd.ContentStream = fmt.Sprintf("stream {\n%s\n}", strings.Join(streamContent, "\n\n"))
+	}
+	return cd
+}
+
+// getServer returns the server block of the domain.
+// Requests without a matching domain are served by the block without a domain which responds with 503 if no service uses it.
+func (m Nginx) getServer(domain string, locations []nginxLocation) string {
+	listen := "listen 80;"
+	serverName := domain
+	if len(domain) == 0 {
+		listen = "listen 80 default_server;"
+		serverName = "_"
+	}
+	lines := []string{"    server {", "        " + listen}
+	if certPaths := getDefaultCertPaths(m.GetCertPaths()); len(certPaths) > 0 {
+		// Combined PEM files work both as the certificate and as the key
+		lines = append(
+			lines,
+			"        "+strings.Replace(listen, "80", "443 ssl", 1),
+			fmt.Sprintf("        ssl_certificate %s;", certPaths[0]),
+			fmt.Sprintf("        ssl_certificate_key %s;", certPaths[0]),
+		)
+	}
+	lines = append(lines, fmt.Sprintf("        server_name %s;", serverName))
+	if len(locations) == 0 {
+		lines = append(lines, "        return 503;")
+	}
+	for _, l := range locations {
+		lines = append(lines, fmt.Sprintf("        location %s {", l.path))
+		if l.httpsOnly {
+			lines = append(lines, `            if ($scheme = http) {
+                return 302 https://$host$request_uri;
+            }`)
+		}
+		if len(l.timeoutServer) > 0 {
+			lines = append(lines, fmt.Sprintf("            proxy_read_timeout %ss;", l.timeoutServer))
+		}
+		lines = append(
+			lines,
+			fmt.Sprintf("            proxy_pass http://%s;", l.upstream),
+			"        }",
+		)
+	}
+	lines = append(lines, "    }")
+	return strings.Join(lines, "\n")
+}
+
+// getNginxUpstream returns the upstream block with a server for each outbound host of the service.
+func getNginxUpstream(name string, s Service, port string) string {
+	lines := []string{fmt.Sprintf("    upstream %s {", name)}
+	for _, host := range s.GetHosts() {
+		address := fmt.Sprintf("%s:%s", host, port)
+		if IsUnixSocket(host) {
+			address = "unix:" + strings.TrimPrefix(host, "unix://")
+		} else if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			address = fmt.Sprintf("[%s]:%s", ip.String(), port)
+		}
+		lines = append(lines, fmt.Sprintf("        server %s;", address))
+	}
+	lines = append(lines, "    }")
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,279 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type NginxTestSuite struct {
+	suite.Suite
+	nginx   Nginx
+	written map[string]string
+}
+
+func (s *NginxTestSuite) SetupTest() {
+	s.nginx = NewNginx("test_configs/tmpl", "/cfg").(Nginx)
+	s.written = map[string]string{}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		s.written[filename] = string(data)
+		return nil
+	}
+	ReadDir = func(dirname string) ([]os.FileInfo, error) {
+		return []os.FileInfo{}, nil
+	}
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		return nil
+	}
+}
+
+func TestNginxUnitTestSuite(t *testing.T) {
+	logPrintfOrig := logPrintf
+	defer func() { logPrintf = logPrintfOrig }()
+	logPrintf = func(format string, v ...interface{}) {}
+	writeFileOrig := writeFile
+	defer func() { writeFile = writeFileOrig }()
+	readDirOrig := ReadDir
+	defer func() { ReadDir = readDirOrig }()
+	cmdRunHaOrig := cmdRunHa
+	defer func() { cmdRunHa = cmdRunHaOrig }()
+	defer func() { data.Services = map[string]Service{} }()
+	suite.Run(t, new(NginxTestSuite))
+}
+
+// NewEngine
+
+func (s *NginxTestSuite) Test_NewEngine_ReturnsHaProxy_WhenEngineIsNotSet() {
+	actual, err := NewEngine("/cfg/tmpl", "/cfg")
+
+	s.NoError(err)
+	s.IsType(HaProxy{}, actual)
+}
+
+func (s *NginxTestSuite) Test_NewEngine_ReturnsNginx_WhenEngineIsNginx() {
+	defer func() { os.Unsetenv("PROXY_ENGINE") }()
+	os.Setenv("PROXY_ENGINE", "nginx")
+
+	actual, err := NewEngine("/cfg/tmpl", "/cfg")
+
+	s.NoError(err)
+	s.Equal(Nginx{TemplatesPath: "/cfg/tmpl", ConfigsPath: "/cfg"}, actual)
+}
+
+func (s *NginxTestSuite) Test_NewEngine_ReturnsError_WhenEngineIsNotSupported() {
+	defer func() { os.Unsetenv("PROXY_ENGINE") }()
+	os.Setenv("PROXY_ENGINE", "traefik")
+
+	_, err := NewEngine("/cfg/tmpl", "/cfg")
+
+	s.Error(err)
+}
+
+// CreateConfigFromTemplates
+
+func (s *NginxTestSuite) Test_CreateConfigFromTemplates_WritesNginxConf() {
+	err := s.nginx.CreateConfigFromTemplates()
+
+	s.NoError(err)
+	s.Contains(s.written, "/cfg/nginx.conf")
+	s.Contains(s.written["/cfg/nginx.conf"], "worker_connections 5000;")
+}
+
+func (s *NginxTestSuite) Test_CreateConfigFromTemplates_ReturnsError_WhenTemplateDoesNotExist() {
+	s.nginx.TemplatesPath = "/this/path/does/not/exist"
+
+	err := s.nginx.CreateConfigFromTemplates()
+
+	s.Error(err)
+}
+
+// RenderConfig
+
+func (s *NginxTestSuite) Test_RenderConfig_RespondsWith503_WhenThereAreNoServices() {
+	actual, _ := s.nginx.RenderConfig()
+
+	s.Contains(actual, `    server {
+        listen 80 default_server;
+        server_name _;
+        return 503;
+    }`)
+}
+
+func (s *NginxTestSuite) Test_RenderConfig_AddsUpstreamsAndLocations() {
+	s.nginx.AddService(Service{
+		ServiceName: "my-service",
+		ReqMode:     "http",
+		ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"/api", "/admin"}}},
+	})
+	s.nginx.AddService(Service{
+		ServiceName:   "other-service",
+		ReqMode:       "http",
+		ServiceDomain: []string{"acme.com"},
+		HttpsOnly:     true,
+		ServiceDest:   []ServiceDest{{Port: "80", ServicePath: []string{"/"}}},
+	})
+
+	actual, _ := s.nginx.RenderConfig()
+
+	s.Contains(actual, `    upstream my-service-be8080 {
+        server my-service:8080;
+    }
+
+    upstream other-service-be80 {
+        server other-service:80;
+    }
+
+    server {
+        listen 80 default_server;
+        server_name _;
+        location /api {
+            proxy_pass http://my-service-be8080;
+        }
+        location /admin {
+            proxy_pass http://my-service-be8080;
+        }
+    }
+
+    server {
+        listen 80;
+        server_name acme.com;
+        location / {
+            if ($scheme = http) {
+                return 302 https://$host$request_uri;
+            }
+            proxy_pass http://other-service-be80;
+        }
+    }`)
+	s.NotContains(actual, "stream {")
+}
+
+func (s *NginxTestSuite) Test_RenderConfig_AddsRegexLocations_WhenPathTypeIsPathReg() {
+	s.nginx.AddService(Service{
+		ServiceName: "my-service",
+		PathType:    "path_reg",
+		ServiceDest: []ServiceDest{{Port: "8080", ServicePath: []string{"^/api/v[0-9]+"}}},
+	})
+
+	actual, _ := s.nginx.RenderConfig()
+
+	s.Contains(actual, "        location ~ ^/api/v[0-9]+ {")
+}
+
+func (s *NginxTestSuite) Test_RenderConfig_AddsProxyReadTimeout_WhenTimeoutServerIsSet() {
+	s.nginx.AddService(Service{
+		ServiceName:   "my-service",
+		TimeoutServer: "60",
+		ServiceDest: []ServiceDest{
+			{Port: "8080", ServicePath: []string{"/api"}},
+			{Port: "8081", ServicePath: []string{"/reports"}, TimeoutServer: "300"},
+		},
+	})
+
+	actual, _ := s.nginx.RenderConfig()
+
+	s.Contains(actual, "proxy_read_timeout 60s;\n            proxy_pass http://my-service-be8080;")
+	s.Contains(actual, "proxy_read_timeout 300s;\n            proxy_pass http://my-service-be8081;")
+}
+
+func (s *NginxTestSuite) Test_RenderConfig_AddsServerForEachOutboundHostname() {
+	s.nginx.AddService(Service{
+		ServiceName:      "my-service",
+		OutboundHostname: "10.0.0.1,fd00::1,unix:///var/run/app.sock",
+		ServiceDest:      []ServiceDest{{Port: "8080", ServicePath: []string{"/"}}},
+	})
+
+	actual, _ := s.nginx.RenderConfig()
+
+	s.Contains(actual, `    upstream my-service-be8080 {
+        server 10.0.0.1:8080;
+        server [fd00::1]:8080;
+        server unix:/var/run/app.sock;
+    }`)
+}
+
+func (s *NginxTestSuite) Test_RenderConfig_AddsHttpsListeners_WhenCertsArePresent() {
+	ReadDir = func(dirname string) ([]os.FileInfo, error) {
+		if dirname == "/certs" {
+			return []os.FileInfo{FileInfoMock{NameMock: func() string { return "acme.pem" }, IsDirMock: func() bool { return false }}}, nil
+		}
+		return []os.FileInfo{}, nil
+	}
+
+	actual, _ := s.nginx.RenderConfig()
+
+	s.Contains(actual, `        listen 80 default_server;
+        listen 443 ssl default_server;
+        ssl_certificate /certs/acme.pem;
+        ssl_certificate_key /certs/acme.pem;`)
+}
+
+func (s *NginxTestSuite) Test_RenderConfig_AddsStreams_WhenReqModeIsTcp() {
+	s.nginx.AddService(Service{
+		ServiceName: "my-db",
+		ReqMode:     "tcp",
+		ServiceDest: []ServiceDest{{Port: "5432", SrcPort: 5432}},
+	})
+
+	actual, _ := s.nginx.RenderConfig()
+
+	s.True(strings.HasSuffix(actual, `stream {
+    upstream my-db-be5432 {
+        server my-db:5432;
+    }
+
+    server {
+        listen 5432;
+        proxy_pass my-db-be5432;
+    }
+}
+`))
+	s.NotContains(actual, "location")
+}
+
+// RunCmd
+
+func (s *NginxTestSuite) Test_RunCmd_CreatesConfigAndStartsNginx() {
+	var actual []string
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		actual = cmd.Args
+		return nil
+	}
+
+	err := s.nginx.RunCmd([]string{})
+
+	s.NoError(err)
+	s.Contains(s.written, "/cfg/nginx.conf")
+	s.Equal([]string{"nginx", "-c", "/cfg/nginx.conf"}, actual)
+}
+
+func (s *NginxTestSuite) Test_RunCmd_ReturnsError_WhenCommandFails() {
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		return fmt.Errorf("This is an error")
+	}
+
+	err := s.nginx.RunCmd([]string{})
+
+	s.Error(err)
+}
+
+// Reload
+
+func (s *NginxTestSuite) Test_Reload_SendsReloadSignal() {
+	var actual []string
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		actual = cmd.Args
+		return nil
+	}
+
+	err := s.nginx.Reload()
+
+	s.NoError(err)
+	s.Equal([]string{"nginx", "-c", "/cfg/nginx.conf", "-s", "reload"}, actual)
+	s.Empty(s.written)
+}
@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+var smtpSendMail = smtp.SendMail
+var pagerDutyUrl = "https://events.pagerduty.com/v2/enqueue"
+
+// Notifier sends notifications about the events that need the attention of operators.
+type Notifier interface {
+	Notify(subject, message string) error
+}
+
+// SlackNotifier posts notifications to a Slack incoming webhook.
+type SlackNotifier struct {
+	WebhookUrl string
+}
+
+func (m SlackNotifier) Notify(subject, message string) error {
+	js, _ := json.Marshal(map[string]string{"text": fmt.Sprintf("*%s*\n%s", subject, message)})
+	return postNotification("Slack", m.WebhookUrl, js)
+}
+
+// SmtpNotifier sends notifications as emails.
+type SmtpNotifier struct {
+	// The address (host:port) of the SMTP server.
+	Address  string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+func (m SmtpNotifier) Notify(subject, message string) error {
+	var auth smtp.Auth
+	if len(m.Username) > 0 {
+		auth = smtp.PlainAuth("", m.Username, m.Password, strings.Split(m.Address, ":")[0])
+	}
+	msg := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		m.From,
+		strings.Join(m.To, ", "),
+		subject,
+		message,
+	)
+	if err := smtpSendMail(m.Address, auth, m.From, m.To, []byte(msg)); err != nil {
+		return fmt.Errorf("Could not send the notification to %s\n%s", strings.Join(m.To, ", "), err.Error())
+	}
+	return nil
+}
+
+// PagerDutyNotifier triggers PagerDuty incidents through the Events API v2.
+type PagerDutyNotifier struct {
+	RoutingKey string
+}
+
+func (m PagerDutyNotifier) Notify(subject, message string) error {
+	hostname, _ := os.Hostname()
+	js, _ := json.Marshal(map[string]interface{}{
+		"routing_key":  m.RoutingKey,
+		"event_action": "trigger",
+		"payload": map[string]string{
+			"summary":        subject,
+			"source":         hostname,
+			"severity":       "error",
+			"custom_details": message,
+		},
+	})
+	return postNotification("PagerDuty", pagerDutyUrl, js)
+}
+
+func postNotification(sink, url string, js []byte) error {
+	resp, err := notifyClient.Post(url, "application/json", bytes.NewReader(js))
+	if err != nil {
+		return fmt.Errorf("Could not send the notification to %s\n%s", sink, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded to the notification with the status code %d", sink, resp.StatusCode)
+	}
+	return nil
+}
+
+// GetNotifiers returns the notifiers configured through the NOTIFY_* environment variables.
+func GetNotifiers() []Notifier {
+	notifiers := []Notifier{}
+	if url := GetSecretOrEnvVar("NOTIFY_SLACK_WEBHOOK_URL", ""); len(url) > 0 {
+		notifiers = append(notifiers, SlackNotifier{WebhookUrl: url})
+	}
+	if address := GetSecretOrEnvVar("NOTIFY_SMTP_ADDRESS", ""); len(address) > 0 {
+		to := []string{}
+		for _, recipient := range strings.Split(GetSecretOrEnvVar("NOTIFY_SMTP_TO", ""), ",") {
+			if recipient = strings.TrimSpace(recipient); len(recipient) > 0 {
+				to = append(to, recipient)
+			}
+		}
+		notifiers = append(notifiers, SmtpNotifier{
+			Address:  address,
+			From:     GetSecretOrEnvVar("NOTIFY_SMTP_FROM", "docker-flow-proxy@localhost"),
+			To:       to,
+			Username: GetSecretOrEnvVar("NOTIFY_SMTP_USERNAME", ""),
+			Password: GetSecretOrEnvVar("NOTIFY_SMTP_PASSWORD", ""),
+		})
+	}
+	if key := GetSecretOrEnvVar("NOTIFY_PAGERDUTY_ROUTING_KEY", ""); len(key) > 0 {
+		notifiers = append(notifiers, PagerDutyNotifier{RoutingKey: key})
+	}
+	return notifiers
+}
+
+// GetNotification returns the subject and the message of the notification about the event
+// and whether the event needs one. Failed reloads, backends that went down, and expiring certificates are notified.
+func GetNotification(event Event) (string, string, bool) {
+	switch {
+	case event.Type == EventReload && event.Status == "NOK":
+		return "The proxy could not be reloaded", event.Message, true
+	case event.Type == EventHealth && event.Status == "DOWN":
+		return fmt.Sprintf("The backend %s is down", event.ServiceName), fmt.Sprintf("The status of the backend %s changed from %s to %s.", event.ServiceName, event.Message, event.Status), true
+	case event.Type == EventCertExpiry:
+		return fmt.Sprintf("The certificate %s expires soon", event.ServiceName), event.Message, true
+	}
+	return "", "", false
+}
+
+// GetExpiringCerts returns the expiry times of the certificates that expire before the time keyed by their paths.
+// Contents that do not contain a certificate are ignored.
+func GetExpiringCerts(certs map[string]string, before time.Time) map[string]time.Time {
+	expiries := map[string]time.Time{}
+	for path, content := range certs {
+		rest := []byte(content)
+		for {
+			var block *pem.Block
+			if block, rest = pem.Decode(rest); block == nil {
+				break
+			} else if block.Type != "CERTIFICATE" {
+				continue
+			}
+			// The first certificate of the bundle is the certificate of the server
+			if cert, err := x509.ParseCertificate(block.Bytes); err == nil && cert.NotAfter.Before(before) {
+				expiries[path] = cert.NotAfter
+			}
+			break
+		}
+	}
+	return expiries
+}
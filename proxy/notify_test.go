@@ -0,0 +1,189 @@
+// +build !integration
+
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type NotifyTestSuite struct {
+	suite.Suite
+}
+
+func TestNotifyUnitTestSuite(t *testing.T) {
+	smtpSendMailOrig := smtpSendMail
+	defer func() { smtpSendMail = smtpSendMailOrig }()
+	pagerDutyUrlOrig := pagerDutyUrl
+	defer func() { pagerDutyUrl = pagerDutyUrlOrig }()
+	suite.Run(t, new(NotifyTestSuite))
+}
+
+// SlackNotifier
+
+func (s *NotifyTestSuite) Test_SlackNotifier_PostsMessageToWebhook() {
+	actualBody := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		actualBody = string(body)
+	}))
+	defer srv.Close()
+
+	err := SlackNotifier{WebhookUrl: srv.URL}.Notify("The proxy could not be reloaded", "This is an error")
+
+	s.NoError(err)
+	s.Equal(`{"text":"*The proxy could not be reloaded*\nThis is an error"}`, actualBody)
+}
+
+func (s *NotifyTestSuite) Test_SlackNotifier_ReturnsError_WhenWebhookFails() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	err := SlackNotifier{WebhookUrl: srv.URL}.Notify("subject", "message")
+
+	s.Error(err)
+}
+
+// SmtpNotifier
+
+func (s *NotifyTestSuite) Test_SmtpNotifier_SendsEmail() {
+	var actualAddress, actualFrom string
+	var actualTo []string
+	var actualMsg string
+	smtpSendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		actualAddress, actualFrom, actualTo, actualMsg = addr, from, to, string(msg)
+		return nil
+	}
+	n := SmtpNotifier{Address: "smtp.acme.com:587", From: "proxy@acme.com", To: []string{"ops@acme.com", "dev@acme.com"}}
+
+	err := n.Notify("The backend my-service-be8080 is down", "The status changed.")
+
+	s.NoError(err)
+	s.Equal("smtp.acme.com:587", actualAddress)
+	s.Equal("proxy@acme.com", actualFrom)
+	s.Equal([]string{"ops@acme.com", "dev@acme.com"}, actualTo)
+	s.Equal("From: proxy@acme.com\r\nTo: ops@acme.com, dev@acme.com\r\nSubject: The backend my-service-be8080 is down\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\nThe status changed.\r\n", actualMsg)
+}
+
+func (s *NotifyTestSuite) Test_SmtpNotifier_ReturnsError_WhenSendingFails() {
+	smtpSendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		return fmt.Errorf("This is an error")
+	}
+
+	err := SmtpNotifier{Address: "smtp.acme.com:587", To: []string{"ops@acme.com"}}.Notify("subject", "message")
+
+	s.Error(err)
+}
+
+// PagerDutyNotifier
+
+func (s *NotifyTestSuite) Test_PagerDutyNotifier_TriggersIncident() {
+	actualBody := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		actualBody = string(body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+	pagerDutyUrl = srv.URL
+
+	err := PagerDutyNotifier{RoutingKey: "my-key"}.Notify("subject", "message")
+
+	s.NoError(err)
+	s.Contains(actualBody, `"routing_key":"my-key"`)
+	s.Contains(actualBody, `"event_action":"trigger"`)
+	s.Contains(actualBody, `"summary":"subject"`)
+}
+
+// GetNotifiers
+
+func (s *NotifyTestSuite) Test_GetNotifiers_ReturnsConfiguredNotifiers() {
+	defer func() {
+		os.Unsetenv("NOTIFY_SLACK_WEBHOOK_URL")
+		os.Unsetenv("NOTIFY_SMTP_ADDRESS")
+		os.Unsetenv("NOTIFY_SMTP_TO")
+	}()
+	os.Setenv("NOTIFY_SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/my-hook")
+	os.Setenv("NOTIFY_SMTP_ADDRESS", "smtp.acme.com:587")
+	os.Setenv("NOTIFY_SMTP_TO", "ops@acme.com, dev@acme.com")
+
+	actual := GetNotifiers()
+
+	s.Equal([]Notifier{
+		SlackNotifier{WebhookUrl: "https://hooks.slack.com/services/my-hook"},
+		SmtpNotifier{Address: "smtp.acme.com:587", From: "docker-flow-proxy@localhost", To: []string{"ops@acme.com", "dev@acme.com"}},
+	}, actual)
+}
+
+func (s *NotifyTestSuite) Test_GetNotifiers_ReturnsEmptySlice_WhenNothingIsConfigured() {
+	s.Empty(GetNotifiers())
+}
+
+// GetNotification
+
+func (s *NotifyTestSuite) Test_GetNotification_ReturnsNotificationsOfEventsThatNeedAttention() {
+	_, message, ok := GetNotification(Event{Type: EventReload, Status: "NOK", Message: "This is an error"})
+	s.True(ok)
+	s.Equal("This is an error", message)
+
+	subject, _, ok := GetNotification(Event{Type: EventHealth, ServiceName: "my-service-be8080", Status: "DOWN", Message: "UP"})
+	s.True(ok)
+	s.Equal("The backend my-service-be8080 is down", subject)
+
+	_, _, ok = GetNotification(Event{Type: EventCertExpiry, ServiceName: "/certs/acme.pem"})
+	s.True(ok)
+}
+
+func (s *NotifyTestSuite) Test_GetNotification_ReturnsFalse_WhenEventDoesNotNeedAttention() {
+	for _, event := range []Event{
+		{Type: EventReload, Status: "OK"},
+		{Type: EventHealth, Status: "UP", Message: "DOWN"},
+		{Type: EventReconfigure, ServiceName: "my-service"},
+	} {
+		_, _, ok := GetNotification(event)
+		s.False(ok)
+	}
+}
+
+// GetExpiringCerts
+
+func (s *NotifyTestSuite) Test_GetExpiringCerts_ReturnsCertsThatExpireBeforeTime() {
+	now := time.Now()
+	certs := map[string]string{
+		"/certs/soon.pem":  s.getCert(now.Add(24 * time.Hour)),
+		"/certs/later.pem": s.getCert(now.AddDate(1, 0, 0)),
+		"/certs/empty.pem": "",
+	}
+
+	actual := GetExpiringCerts(certs, now.AddDate(0, 0, 14))
+
+	s.Len(actual, 1)
+	s.Equal(now.Add(24*time.Hour).Unix(), actual["/certs/soon.pem"].Unix())
+}
+
+// getCert returns a self-signed certificate that expires at the time followed by its private key.
+func (s *NotifyTestSuite) getCert(notAfter time.Time) string {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: notAfter.AddDate(-1, 0, 0), NotAfter: notAfter}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	s.Require().NoError(err)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
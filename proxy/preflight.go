@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// The oldest HAProxy version that supports all the directives used in the generated configuration
+var minHaProxyVersion = HaProxyVersion{1, 7}
+
+var preflightHttpGet = func(url string) (*http.Response, error) {
+	client := http.Client{Timeout: 5 * time.Second}
+	return client.Get(url)
+}
+var preflightListen = net.Listen
+var removeFile = os.Remove
+var getHaProxyVersionOutput = func() (string, error) {
+	out, err := exec.Command("haproxy", "-v").CombinedOutput()
+	return string(out), err
+}
+
+// PreflightOptions defines what is verified before the proxy starts.
+type PreflightOptions struct {
+	// The addresses of the Consul instances that need to be reachable.
+	ConsulAddresses []string
+	// The engine (haproxy, nginx, or envoy) the proxy runs.
+	Engine string
+	// The directory the certificates sent through the API are stored in.
+	CertsPath string
+	// The directory with the template of the engine (haproxy.tmpl or nginx.tmpl).
+	TemplatesPath string
+	// The ports the proxy and its API listen to.
+	Ports []string
+}
+
+// RunPreflightChecks verifies that the proxy can start and returns an error with a suggested fix for each failed check.
+func RunPreflightChecks(options PreflightOptions) []error {
+	errs := []error{}
+	for _, address := range options.ConsulAddresses {
+		if err := checkConsul(address); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	checks := []func() error{
+		func() error { return checkCertsPath(options.CertsPath) },
+	}
+	switch options.Engine {
+	case "envoy":
+		// Envoy runs outside of the proxy and does not use templates
+	case "nginx":
+		checks = append(checks, func() error { return checkTemplate(options.TemplatesPath, options.Engine) })
+	default:
+		checks = append(checks, func() error { return checkTemplate(options.TemplatesPath, options.Engine) }, checkHaProxyVersion)
+	}
+	for _, check := range checks {
+		if err := check(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, port := range options.Ports {
+		if err := checkPort(port); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// GetPreflightPorts returns the ports the proxy binds based on DEFAULT_PORTS and BIND_PORTS.
+func GetPreflightPorts() []string {
+	ports := []string{}
+	values := strings.Split(GetSecretOrEnvVar("DEFAULT_PORTS", "80,443:ssl"), ",")
+	if bindPorts := GetSecretOrEnvVar("BIND_PORTS", ""); len(bindPorts) > 0 {
+		values = append(values, strings.Split(bindPorts, ",")...)
+	}
+	for _, value := range values {
+		port := strings.TrimSpace(strings.Split(value, ":")[0])
+		if len(port) > 0 {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+func checkConsul(address string) error {
+	url := strings.ToLower(address)
+	if !strings.HasPrefix(url, "http") {
+		url = fmt.Sprintf("http://%s", url)
+	}
+	resp, err := preflightHttpGet(url + "/v1/status/leader")
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("Consul responded with the status code %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Could not reach Consul at %s. Make sure that CONSUL_ADDRESS points to a running Consul instance reachable from the proxy.\n%s", address, err.Error())
+	}
+	return nil
+}
+
+func checkCertsPath(certsPath string) error {
+	path := fmt.Sprintf("%s/.preflight", certsPath)
+	if err := writeFile(path, []byte{}, 0664); err != nil {
+		return fmt.Errorf("The certificates directory %s is not writable so certificates sent through the API cannot be stored. Mount a writable volume to %s.\n%s", certsPath, certsPath, err.Error())
+	}
+	removeFile(path)
+	return nil
+}
+
+func checkTemplate(templatesPath, engine string) error {
+	if len(engine) == 0 {
+		engine = "haproxy"
+	}
+	path := fmt.Sprintf("%s/%s.tmpl", templatesPath, engine)
+	content, err := readConfigsFile(path)
+	if err != nil {
+		return fmt.Errorf("Could not read the template %s. Make sure that the file exists if the image was customized.\n%s", path, err.Error())
+	}
+	// The services are not looked up since the template is only parsed
+	if _, err := template.New(engine).Funcs(TemplateFuncs(nil)).Parse(string(content)); err != nil {
+		return fmt.Errorf("The template %s is not valid. Fix the syntax of the custom template.\n%s", path, err.Error())
+	}
+	return nil
+}
+
+func checkHaProxyVersion() error {
+	out, err := getHaProxyVersionOutput()
+	if err != nil {
+		return fmt.Errorf("Could not run haproxy -v. Make sure that HAProxy is installed and in the PATH.\n%s", err.Error())
+	}
+	version, err := ParseHaProxyVersion(out)
+	if err != nil {
+		return err
+	}
+	if !version.AtLeast(minHaProxyVersion) {
+		return fmt.Errorf("HAProxy %s is not supported. Use HAProxy %s or newer.", version, minHaProxyVersion)
+	}
+	return nil
+}
+
+func checkPort(port string) error {
+	listener, err := preflightListen("tcp", ":"+port)
+	if err != nil {
+		return fmt.Errorf("The port %s is already in use. Stop the process using it or change DEFAULT_PORTS, BIND_PORTS, or PORT.\n%s", port, err.Error())
+	}
+	listener.Close()
+	return nil
+}
@@ -0,0 +1,260 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PreflightTestSuite struct {
+	suite.Suite
+	options      PreflightOptions
+	requestedUrl string
+	listened     []string
+	written      []string
+	removed      []string
+}
+
+func (s *PreflightTestSuite) SetupTest() {
+	s.options = PreflightOptions{
+		ConsulAddresses: []string{"consul:8500"},
+		CertsPath:       "/certs",
+		TemplatesPath:   "/cfg/tmpl",
+		Ports:           []string{"80", "443"},
+	}
+	s.requestedUrl = ""
+	s.listened = []string{}
+	s.written = []string{}
+	s.removed = []string{}
+	preflightHttpGet = func(url string) (*http.Response, error) {
+		s.requestedUrl = url
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`"10.0.0.1:8300"`))}, nil
+	}
+	preflightListen = func(network, address string) (net.Listener, error) {
+		s.listened = append(s.listened, address)
+		return listenerMock{}, nil
+	}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		s.written = append(s.written, filename)
+		return nil
+	}
+	removeFile = func(name string) error {
+		s.removed = append(s.removed, name)
+		return nil
+	}
+	readConfigsFile = func(filename string) ([]byte, error) {
+		return []byte("global\n    maxconn {{.MaxConn}}\n"), nil
+	}
+	getHaProxyVersionOutput = func() (string, error) {
+		return "HA-Proxy version 1.7.9 2017/08/18\nCopyright 2000-2017 Willy Tarreau <willy@haproxy.org>\n", nil
+	}
+}
+
+func TestPreflightUnitTestSuite(t *testing.T) {
+	preflightHttpGetOrig := preflightHttpGet
+	defer func() { preflightHttpGet = preflightHttpGetOrig }()
+	preflightListenOrig := preflightListen
+	defer func() { preflightListen = preflightListenOrig }()
+	writeFileOrig := writeFile
+	defer func() { writeFile = writeFileOrig }()
+	removeFileOrig := removeFile
+	defer func() { removeFile = removeFileOrig }()
+	readConfigsFileOrig := readConfigsFile
+	defer func() { readConfigsFile = readConfigsFileOrig }()
+	getHaProxyVersionOutputOrig := getHaProxyVersionOutput
+	defer func() { getHaProxyVersionOutput = getHaProxyVersionOutputOrig }()
+	suite.Run(t, new(PreflightTestSuite))
+}
+
+// RunPreflightChecks
+
+func (s *PreflightTestSuite) Test_RunPreflightChecks_ReturnsNoErrors_WhenAllChecksPass() {
+	actual := RunPreflightChecks(s.options)
+
+	s.Empty(actual)
+	s.Equal("http://consul:8500/v1/status/leader", s.requestedUrl)
+	s.Equal([]string{":80", ":443"}, s.listened)
+	s.Equal([]string{"/certs/.preflight"}, s.written)
+	s.Equal([]string{"/certs/.preflight"}, s.removed)
+}
+
+func (s *PreflightTestSuite) Test_RunPreflightChecks_DoesNotPrefixConsulAddress_WhenItContainsProtocol() {
+	s.options.ConsulAddresses = []string{"https://consul:8500"}
+
+	RunPreflightChecks(s.options)
+
+	s.Equal("https://consul:8500/v1/status/leader", s.requestedUrl)
+}
+
+func (s *PreflightTestSuite) Test_RunPreflightChecks_ReturnsError_WhenConsulIsNotReachable() {
+	preflightHttpGet = func(url string) (*http.Response, error) {
+		return nil, fmt.Errorf("dial tcp: lookup consul: no such host")
+	}
+
+	actual := RunPreflightChecks(s.options)
+
+	s.Len(actual, 1)
+	s.Contains(actual[0].Error(), "CONSUL_ADDRESS")
+}
+
+func (s *PreflightTestSuite) Test_RunPreflightChecks_ReturnsError_WhenConsulRespondsWithError() {
+	preflightHttpGet = func(url string) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusInternalServerError, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}
+
+	actual := RunPreflightChecks(s.options)
+
+	s.Len(actual, 1)
+	s.Contains(actual[0].Error(), "500")
+}
+
+func (s *PreflightTestSuite) Test_RunPreflightChecks_ReturnsError_WhenCertsPathIsNotWritable() {
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		return fmt.Errorf("read-only file system")
+	}
+
+	actual := RunPreflightChecks(s.options)
+
+	s.Len(actual, 1)
+	s.Contains(actual[0].Error(), "The certificates directory /certs is not writable")
+	s.Empty(s.removed)
+}
+
+func (s *PreflightTestSuite) Test_RunPreflightChecks_ReturnsError_WhenTemplateCannotBeRead() {
+	readConfigsFile = func(filename string) ([]byte, error) {
+		return nil, fmt.Errorf("no such file or directory")
+	}
+
+	actual := RunPreflightChecks(s.options)
+
+	s.Len(actual, 1)
+	s.Contains(actual[0].Error(), "/cfg/tmpl/haproxy.tmpl")
+}
+
+func (s *PreflightTestSuite) Test_RunPreflightChecks_ReturnsError_WhenTemplateIsNotValid() {
+	readConfigsFile = func(filename string) ([]byte, error) {
+		return []byte("global\n    maxconn {{.MaxConn\n"), nil
+	}
+
+	actual := RunPreflightChecks(s.options)
+
+	s.Len(actual, 1)
+	s.Contains(actual[0].Error(), "The template /cfg/tmpl/haproxy.tmpl is not valid")
+}
+
+func (s *PreflightTestSuite) Test_RunPreflightChecks_ReturnsError_WhenHaProxyCannotBeRun() {
+	getHaProxyVersionOutput = func() (string, error) {
+		return "", fmt.Errorf("executable file not found in $PATH")
+	}
+
+	actual := RunPreflightChecks(s.options)
+
+	s.Len(actual, 1)
+	s.Contains(actual[0].Error(), "Could not run haproxy -v")
+}
+
+func (s *PreflightTestSuite) Test_RunPreflightChecks_ReturnsError_WhenHaProxyVersionIsNotSupported() {
+	getHaProxyVersionOutput = func() (string, error) {
+		return "HA-Proxy version 1.6.13 2017/06/19\n", nil
+	}
+
+	actual := RunPreflightChecks(s.options)
+
+	s.Len(actual, 1)
+	s.Contains(actual[0].Error(), "HAProxy 1.6 is not supported")
+}
+
+func (s *PreflightTestSuite) Test_RunPreflightChecks_ReturnsNoErrors_WhenHaProxyVersionIsNewer() {
+	getHaProxyVersionOutput = func() (string, error) {
+		return "HA-Proxy version 2.0.1 2019/06/26 - https://haproxy.org/\n", nil
+	}
+
+	actual := RunPreflightChecks(s.options)
+
+	s.Empty(actual)
+}
+
+func (s *PreflightTestSuite) Test_RunPreflightChecks_ChecksNginxTemplateAndSkipsHaProxyVersion_WhenEngineIsNginx() {
+	s.options.Engine = "nginx"
+	actualPath := ""
+	readConfigsFile = func(filename string) ([]byte, error) {
+		actualPath = filename
+		return []byte("events {}\n"), nil
+	}
+	getHaProxyVersionOutput = func() (string, error) {
+		return "", fmt.Errorf("executable file not found in $PATH")
+	}
+
+	actual := RunPreflightChecks(s.options)
+
+	s.Empty(actual)
+	s.Equal("/cfg/tmpl/nginx.tmpl", actualPath)
+}
+
+func (s *PreflightTestSuite) Test_RunPreflightChecks_ReturnsError_WhenPortIsInUse() {
+	preflightListen = func(network, address string) (net.Listener, error) {
+		if address == ":443" {
+			return nil, fmt.Errorf("listen tcp :443: bind: address already in use")
+		}
+		return listenerMock{}, nil
+	}
+
+	actual := RunPreflightChecks(s.options)
+
+	s.Len(actual, 1)
+	s.Contains(actual[0].Error(), "The port 443 is already in use")
+}
+
+func (s *PreflightTestSuite) Test_RunPreflightChecks_ReturnsAllErrors() {
+	preflightHttpGet = func(url string) (*http.Response, error) {
+		return nil, fmt.Errorf("connection refused")
+	}
+	preflightListen = func(network, address string) (net.Listener, error) {
+		return nil, fmt.Errorf("address already in use")
+	}
+
+	actual := RunPreflightChecks(s.options)
+
+	s.Len(actual, 3)
+}
+
+// GetPreflightPorts
+
+func (s *PreflightTestSuite) Test_GetPreflightPorts_ReturnsDefaultPorts() {
+	s.Equal([]string{"80", "443"}, GetPreflightPorts())
+}
+
+func (s *PreflightTestSuite) Test_GetPreflightPorts_ReturnsDefaultAndBindPorts() {
+	defer func() {
+		os.Unsetenv("DEFAULT_PORTS")
+		os.Unsetenv("BIND_PORTS")
+	}()
+	os.Setenv("DEFAULT_PORTS", "8080,8443:ssl")
+	os.Setenv("BIND_PORTS", "8085, 8086")
+
+	s.Equal([]string{"8080", "8443", "8085", "8086"}, GetPreflightPorts())
+}
+
+// Mock
+
+type listenerMock struct{}
+
+func (m listenerMock) Accept() (net.Conn, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (m listenerMock) Close() error {
+	return nil
+}
+
+func (m listenerMock) Addr() net.Addr {
+	return &net.TCPAddr{}
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import "sort"
+
+type TcpPreset struct {
+	// The backend options that configure the health check of the protocol.
+	Options []string
+	// The default tunnel timeout in seconds, long enough for idle connections of the protocol.
+	TimeoutTunnel string
+}
+
+// TcpPresets are the protocol-specific configurations of tcp services selected through TcpPreset.
+var TcpPresets = map[string]TcpPreset{
+	"imap": {
+		Options: []string{
+			"option tcp-check",
+			`tcp-check expect string *\ OK`,
+		},
+		// RFC 3501 requires the autologout timer to be at least 30 minutes
+		TimeoutTunnel: "1800",
+	},
+	"mqtt": {
+		// The check connects as the client dfp-check and expects a CONNACK, whatever its return code, before it disconnects
+		// so that brokers that require authentication are checked as well
+		Options: []string{
+			"option tcp-check",
+			"tcp-check send-binary 101500044d5154540402003c00096466702d636865636b",
+			"tcp-check expect binary 2002",
+			"tcp-check send-binary e000",
+		},
+		// Brokers disconnect the clients that are idle for 1.5 times their keep alive, so the timeout is longer than the keep alive of most devices
+		TimeoutTunnel: "3600",
+	},
+	"mysql": {
+		Options: []string{
+			"option mysql-check",
+		},
+		TimeoutTunnel: "28800",
+	},
+	"redis": {
+		Options: []string{
+			"option tcp-check",
+			`tcp-check send PING\r\n`,
+			"tcp-check expect string +PONG",
+		},
+		TimeoutTunnel: "3600",
+	},
+	"smtp": {
+		Options: []string{
+			"option smtpchk",
+		},
+		// RFC 5321 recommends waiting at least 5 minutes for the commands of the client
+		TimeoutTunnel: "300",
+	},
+}
+
+func getTcpPresetNames() []string {
+	names := []string{}
+	for name := range TcpPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,82 @@
+// +build !integration
+
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PresetsTestSuite struct {
+	suite.Suite
+}
+
+func TestPresetsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(PresetsTestSuite))
+}
+
+// The number of the arguments HAProxy expects for the tcp-check directives, including the keywords
+var tcpCheckArgs = map[string]int{
+	"connect":     2,
+	"expect":      4,
+	"send":        3,
+	"send-binary": 3,
+}
+
+// TcpPresets
+
+func (s PresetsTestSuite) Test_TcpPresets_GenerateDirectivesWithExpectedNumberOfArguments() {
+	for name, preset := range TcpPresets {
+		for _, option := range preset.Options {
+			args := splitConfigArgs(option)
+			if args[0] != "tcp-check" {
+				continue
+			}
+			expected, ok := tcpCheckArgs[args[1]]
+			if !s.True(ok, "%s: %s is not a known tcp-check directive", name, option) {
+				continue
+			}
+			if args[1] == "expect" && args[2] == "!" {
+				expected++
+			}
+			s.Len(args, expected, "%s: %s", name, option)
+		}
+	}
+}
+
+func (s PresetsTestSuite) Test_TcpPresets_ExpectImapGreeting() {
+	s.Equal([]string{"tcp-check", "expect", "string", "* OK"}, splitConfigArgs(TcpPresets["imap"].Options[1]))
+}
+
+// splitConfigArgs splits the line of the configuration into arguments the way HAProxy does,
+// on whitespace that is not escaped with a backslash.
+func splitConfigArgs(line string) []string {
+	args := []string{}
+	arg := ""
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			if c != ' ' && c != '\\' {
+				arg += "\\"
+			}
+			arg += string(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case strings.ContainsRune(" \t", c):
+			if len(arg) > 0 {
+				args = append(args, arg)
+				arg = ""
+			}
+		default:
+			arg += string(c)
+		}
+	}
+	if len(arg) > 0 {
+		args = append(args, arg)
+	}
+	return args
+}
@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// Profiles stores named sets of reconfigure parameters that services can reference with the profile parameter.
+type Profiles struct {
+	mu       *sync.Mutex
+	profiles map[string]map[string]string
+}
+
+func NewProfiles() *Profiles {
+	return &Profiles{
+		mu:       &sync.Mutex{},
+		profiles: map[string]map[string]string{},
+	}
+}
+
+// LoadFile adds the profiles defined in the YAML file.
+// Each top level key is the name of a profile and its nested keys are the parameters, for example
+//
+//	public-api:
+//	  httpsOnly: true
+//	  timeoutServer: 30
+func (m *Profiles) LoadFile(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	profiles, err := ParseProfiles(string(content))
+	if err != nil {
+		return fmt.Errorf("Could not parse the profiles file %s\n%s", path, err.Error())
+	}
+	for name, params := range profiles {
+		m.Put(name, params)
+	}
+	return nil
+}
+
+func (m *Profiles) Get(name string) (map[string]string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	params, found := m.profiles[name]
+	return params, found
+}
+
+func (m *Profiles) GetAll() map[string]map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	all := map[string]map[string]string{}
+	for name, params := range m.profiles {
+		all[name] = params
+	}
+	return all
+}
+
+func (m *Profiles) Put(name string, params map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.profiles[name] = params
+}
+
+func (m *Profiles) Delete(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.profiles, name)
+}
+
+// ParseProfiles parses the subset of YAML used by the profiles file: a map of profiles with scalar parameters.
+func ParseProfiles(content string) (map[string]map[string]string, error) {
+	profiles := map[string]map[string]string{}
+	var current map[string]string
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		keyValue := strings.SplitN(trimmed, ":", 2)
+		if len(keyValue) != 2 || len(strings.TrimSpace(keyValue[0])) == 0 {
+			return nil, fmt.Errorf("Line %d is not formatted as <key>: <value>", i+1)
+		}
+		key := strings.TrimSpace(keyValue[0])
+		value := unquoteYamlValue(strings.TrimSpace(keyValue[1]))
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			if len(value) > 0 {
+				return nil, fmt.Errorf("Line %d defines the profile %s without parameters", i+1, key)
+			}
+			current = map[string]string{}
+			profiles[key] = current
+		} else if current == nil {
+			return nil, fmt.Errorf("Line %d defines the parameter %s outside of a profile", i+1, key)
+		} else {
+			current[key] = value
+		}
+	}
+	return profiles, nil
+}
+
+func unquoteYamlValue(value string) string {
+	if len(value) >= 2 {
+		if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
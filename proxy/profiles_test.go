@@ -0,0 +1,63 @@
+// +build !integration
+
+package proxy
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ProfilesTestSuite struct {
+	suite.Suite
+}
+
+func TestProfilesUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ProfilesTestSuite))
+}
+
+// ParseProfiles
+
+func (s ProfilesTestSuite) Test_ParseProfiles_ReturnsProfiles() {
+	content := `# Shared parameters
+public-api:
+  httpsOnly: true
+  timeoutServer: "30"
+
+websocket-app:
+  timeoutTunnel: 3600
+`
+
+	actual, err := ParseProfiles(content)
+
+	s.NoError(err)
+	s.Equal(map[string]map[string]string{
+		"public-api":    {"httpsOnly": "true", "timeoutServer": "30"},
+		"websocket-app": {"timeoutTunnel": "3600"},
+	}, actual)
+}
+
+func (s ProfilesTestSuite) Test_ParseProfiles_ReturnsError_WhenParameterIsOutsideOfProfile() {
+	_, err := ParseProfiles("  httpsOnly: true")
+
+	s.Error(err)
+}
+
+// LoadFile
+
+func (s ProfilesTestSuite) Test_LoadFile_AddsProfiles() {
+	file, _ := ioutil.TempFile("", "profiles")
+	defer os.Remove(file.Name())
+	file.WriteString("public-api:\n  httpsOnly: true\n")
+	file.Close()
+	profiles := NewProfiles()
+
+	err := profiles.LoadFile(file.Name())
+	actual, found := profiles.Get("public-api")
+
+	s.NoError(err)
+	s.True(found)
+	s.Equal(map[string]string{"httpsOnly": "true"}, actual)
+}
@@ -1,20 +1,39 @@
 package proxy
 
-var ProxyInstance Proxy = HaProxy{}
-
-type Data struct {
-	Services map[string]Service
-}
+import (
+	"fmt"
+	"strings"
+)
 
-var data = Data{}
+var ProxyInstance Proxy = HaProxy{}
 
 type Proxy interface {
 	RunCmd(extraArgs []string) error
 	CreateConfigFromTemplates() error
 	ReadConfig() (string, error)
+	RenderConfig() (string, error)
 	Reload() error
 	GetCertPaths() []string
 	GetCerts() map[string]string
 	AddService(service Service)
 	RemoveService(service string)
+	GetServices() map[string]Service
+}
+
+// GetEngine returns the name of the engine selected through PROXY_ENGINE.
+func GetEngine() string {
+	return strings.ToLower(GetSecretOrEnvVar("PROXY_ENGINE", "haproxy"))
+}
+
+// NewEngine returns the proxy of the engine selected through PROXY_ENGINE.
+func NewEngine(templatesPath, configsPath string) (Proxy, error) {
+	switch GetEngine() {
+	case "haproxy":
+		return NewHaProxy(templatesPath, configsPath), nil
+	case "nginx":
+		return NewNginx(templatesPath, configsPath), nil
+	case "envoy":
+		return NewEnvoy(configsPath), nil
+	}
+	return nil, fmt.Errorf("The proxy engine %s is not supported. PROXY_ENGINE must be haproxy, nginx, or envoy.", GetEngine())
 }
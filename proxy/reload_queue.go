@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ReloadPriority is the lane of a reload request.
+type ReloadPriority int
+
+const (
+	// ReloadBulk is the priority of the reloads after services are added or changed.
+	// They wait for RELOAD_BULK_DELAY milliseconds so that the changes of a batch (e.g. an import of many services) share a reload.
+	ReloadBulk ReloadPriority = iota
+	// ReloadUrgent is the priority of the reloads after services are removed or drained, certificates change,
+	// or critical services are changed. They end the wait of the bulk reloads.
+	ReloadUrgent
+)
+
+// ReloadQueue reloads the proxy on request. The requests that arrive while a reload is running or waiting are coalesced,
+// meaning that all of them are served by the next reload, so that the queue never grows longer than a single reload.
+// A reload applies everything written before it starts, so the changes of the coalesced requests are not lost.
+type ReloadQueue struct {
+	mu      sync.Mutex
+	once    sync.Once
+	reload  func() error
+	pending [2][]chan error
+	// Wakes up the worker when a request is added.
+	added chan struct{}
+	// Ends the wait of the bulk reloads when an urgent request is added.
+	urgent chan struct{}
+}
+
+// NewReloadQueue returns a queue that reloads the proxy through the reload function.
+func NewReloadQueue(reload func() error) *ReloadQueue {
+	return &ReloadQueue{
+		reload: reload,
+		added:  make(chan struct{}, 1),
+		urgent: make(chan struct{}, 1),
+	}
+}
+
+// Request waits until the proxy is reloaded by a reload that starts after the request and returns the error of the reload.
+func (q *ReloadQueue) Request(priority ReloadPriority) error {
+	q.once.Do(func() { go q.run() })
+	c := make(chan error, 1)
+	q.mu.Lock()
+	q.pending[priority] = append(q.pending[priority], c)
+	q.mu.Unlock()
+	signal(q.added)
+	if priority == ReloadUrgent {
+		signal(q.urgent)
+	}
+	return <-c
+}
+
+func (q *ReloadQueue) run() {
+	for range q.added {
+		for {
+			bulk, urgent := q.countPending()
+			if bulk+urgent == 0 {
+				break
+			}
+			if urgent == 0 {
+				if delay := getReloadBulkDelay(); delay > 0 {
+					select {
+					case <-q.urgent:
+					case <-time.After(delay):
+					}
+				}
+			}
+			// The signal of an urgent request served by this reload must not end the wait of the next bulk reload
+			select {
+			case <-q.urgent:
+			default:
+			}
+			batch := q.takePending()
+			if len(batch) > 1 {
+				logPrintf("Reloading the proxy once for %d requests", len(batch))
+			}
+			err := q.reload()
+			for _, c := range batch {
+				c <- err
+			}
+		}
+	}
+}
+
+func (q *ReloadQueue) countPending() (int, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending[ReloadBulk]), len(q.pending[ReloadUrgent])
+}
+
+// takePending removes the pending requests of all the lanes, the urgent ones first.
+func (q *ReloadQueue) takePending() []chan error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	batch := append(q.pending[ReloadUrgent], q.pending[ReloadBulk]...)
+	q.pending = [2][]chan error{}
+	return batch
+}
+
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// getReloadBulkDelay returns the time the bulk reloads wait for other requests.
+func getReloadBulkDelay() time.Duration {
+	delay, err := strconv.Atoi(GetSecretOrEnvVar("RELOAD_BULK_DELAY", "0"))
+	if err != nil || delay < 0 {
+		return 0
+	}
+	return time.Duration(delay) * time.Millisecond
+}
@@ -0,0 +1,102 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReloadQueueTestSuite struct {
+	suite.Suite
+}
+
+func TestReloadQueueUnitTestSuite(t *testing.T) {
+	logPrintfOrig := logPrintf
+	defer func() { logPrintf = logPrintfOrig }()
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(ReloadQueueTestSuite))
+}
+
+// Request
+
+func (s *ReloadQueueTestSuite) Test_Request_ReturnsErrorOfTheReload() {
+	q := NewReloadQueue(func() error {
+		return fmt.Errorf("This is an error")
+	})
+
+	s.Error(q.Request(ReloadBulk))
+}
+
+func (s *ReloadQueueTestSuite) Test_Request_CoalescesRequests_WhenReloadIsRunning() {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	mu := sync.Mutex{}
+	reloads := 0
+	q := NewReloadQueue(func() error {
+		mu.Lock()
+		reloads++
+		mu.Unlock()
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	go q.Request(ReloadBulk)
+	<-started
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.Request(ReloadBulk)
+		}()
+	}
+	// The requests are pending once all of them are added to the lane
+	for bulk, _ := q.countPending(); bulk < 5; bulk, _ = q.countPending() {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	s.Equal(2, reloads)
+}
+
+func (s *ReloadQueueTestSuite) Test_Request_DoesNotWaitForBulkDelay_WhenRequestIsUrgent() {
+	defer os.Unsetenv("RELOAD_BULK_DELAY")
+	os.Setenv("RELOAD_BULK_DELAY", "60000")
+	reloads := 0
+	q := NewReloadQueue(func() error {
+		reloads++
+		return nil
+	})
+	bulk := make(chan error)
+	go func() { bulk <- q.Request(ReloadBulk) }()
+	for pending, _ := q.countPending(); pending == 0; pending, _ = q.countPending() {
+		time.Sleep(time.Millisecond)
+	}
+
+	err := q.Request(ReloadUrgent)
+
+	s.NoError(err)
+	s.NoError(<-bulk)
+	s.Equal(1, reloads)
+}
+
+// getReloadBulkDelay
+
+func (s *ReloadQueueTestSuite) Test_GetReloadBulkDelay_ReturnsZero_WhenDelayIsNotValid() {
+	defer os.Unsetenv("RELOAD_BULK_DELAY")
+	os.Setenv("RELOAD_BULK_DELAY", "soon")
+
+	s.Equal(time.Duration(0), getReloadBulkDelay())
+
+	os.Setenv("RELOAD_BULK_DELAY", "250")
+
+	s.Equal(250*time.Millisecond, getReloadBulkDelay())
+}
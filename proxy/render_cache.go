@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// renderCache keeps the snippets rendered for each service so that only the services that changed since
+// the previous render are rendered again.
+// A snippet is reused only if the service and the fingerprint of everything else the snippet depends on did not change.
+type renderCache struct {
+	mu sync.Mutex
+	// The snippets keyed by <kind>:<service name>.
+	entries map[string]renderEntry
+}
+
+type renderEntry struct {
+	key     string
+	snippet string
+}
+
+// get returns the cached snippet of the kind (e.g. frontend) or, if the service or the fingerprint changed, renders it.
+func (c *renderCache) get(kind string, s Service, fingerprint string, render func() string) string {
+	id := kind + ":" + s.ServiceName
+	key := GetServiceHash(s) + fingerprint
+	c.mu.Lock()
+	entry, ok := c.entries[id]
+	c.mu.Unlock()
+	if ok && entry.key == key {
+		return entry.snippet
+	}
+	snippet := render()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]renderEntry{}
+	}
+	c.entries[id] = renderEntry{key: key, snippet: snippet}
+	return snippet
+}
+
+// prune removes the snippets of the services that are not configured anymore.
+func (c *renderCache) prune(services map[string]Service) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.entries {
+		name := id[strings.Index(id, ":")+1:]
+		if _, ok := services[name]; !ok {
+			delete(c.entries, id)
+		}
+	}
+}
+
+// getRenderFingerprint returns the hash of the environment variables, the global settings changed through SetGlobals,
+// the HAProxy version, and the directory the proxy writes to, which together with the service determine the snippets.
+// Secrets are not included since they cannot change while the proxy is running.
+func getRenderFingerprint(configsPath string) string {
+	haProxyVersionMu.Lock()
+	version := ""
+	if haProxyVersion != nil {
+		version = haProxyVersion.String()
+	}
+	haProxyVersionMu.Unlock()
+	overrides := []string{}
+	for param, value := range GetGlobalOverrides() {
+		overrides = append(overrides, param+"="+value)
+	}
+	sort.Strings(overrides)
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s\n%s\n%s\n%s", configsPath, version, strings.Join(os.Environ(), "\n"), strings.Join(overrides, "\n"))))
+	return hex.EncodeToString(sum[:])
+}
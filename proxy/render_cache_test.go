@@ -0,0 +1,105 @@
+// +build !integration
+
+package proxy
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RenderCacheTestSuite struct {
+	suite.Suite
+	cache   *renderCache
+	renders int
+}
+
+func (s *RenderCacheTestSuite) SetupTest() {
+	s.cache = &renderCache{}
+	s.renders = 0
+}
+
+func TestRenderCacheUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(RenderCacheTestSuite))
+}
+
+func (s *RenderCacheTestSuite) render() string {
+	s.renders++
+	return "my-snippet"
+}
+
+// get
+
+func (s *RenderCacheTestSuite) Test_get_RendersOnlyOnce_WhenServiceDidNotChange() {
+	sr := Service{ServiceName: "my-service", ReqMode: "http"}
+
+	s.Equal("my-snippet", s.cache.get("frontend", sr, "fp", s.render))
+	s.Equal("my-snippet", s.cache.get("frontend", sr, "fp", s.render))
+
+	s.Equal(1, s.renders)
+}
+
+func (s *RenderCacheTestSuite) Test_get_Renders_WhenServiceChanged() {
+	s.cache.get("frontend", Service{ServiceName: "my-service", ReqMode: "http"}, "fp", s.render)
+
+	s.cache.get("frontend", Service{ServiceName: "my-service", ReqMode: "tcp"}, "fp", s.render)
+
+	s.Equal(2, s.renders)
+}
+
+func (s *RenderCacheTestSuite) Test_get_Renders_WhenFingerprintChanged() {
+	sr := Service{ServiceName: "my-service"}
+	s.cache.get("frontend", sr, "fp", s.render)
+
+	s.cache.get("frontend", sr, "other-fp", s.render)
+
+	s.Equal(2, s.renders)
+}
+
+func (s *RenderCacheTestSuite) Test_get_KeepsKindsSeparately() {
+	sr := Service{ServiceName: "my-service"}
+	s.cache.get("frontend", sr, "fp", s.render)
+
+	s.cache.get("backend", sr, "fp", s.render)
+	s.cache.get("frontend", sr, "fp", s.render)
+
+	s.Equal(2, s.renders)
+}
+
+// prune
+
+func (s *RenderCacheTestSuite) Test_prune_RemovesSnippetsOfServicesThatAreNotConfigured() {
+	s.cache.get("frontend", Service{ServiceName: "my-service"}, "fp", s.render)
+	s.cache.get("frontend", Service{ServiceName: "other-service"}, "fp", s.render)
+
+	s.cache.prune(map[string]Service{"other-service": {ServiceName: "other-service"}})
+
+	s.Len(s.cache.entries, 1)
+	s.Contains(s.cache.entries, "frontend:other-service")
+}
+
+// getRenderFingerprint
+
+func (s *RenderCacheTestSuite) Test_getRenderFingerprint_ChangesWithEnvironmentAndConfigsPath() {
+	defer os.Unsetenv("BIND_IPV6")
+	expected := getRenderFingerprint("/cfg")
+
+	s.Equal(expected, getRenderFingerprint("/cfg"))
+	s.NotEqual(expected, getRenderFingerprint("/other"))
+	os.Setenv("BIND_IPV6", "true")
+	s.NotEqual(expected, getRenderFingerprint("/cfg"))
+}
+
+func (s *RenderCacheTestSuite) Test_getRenderFingerprint_ChangesWithGlobalOverrides() {
+	defer ResetGlobals(map[string]string{})
+	expected := getRenderFingerprint("/cfg")
+
+	s.NoError(SetGlobals(map[string]string{"normalizeUri": "true"}))
+	overridden := getRenderFingerprint("/cfg")
+	s.NotEqual(expected, overridden)
+	s.NoError(SetGlobals(map[string]string{"normalizeUri": "false"}))
+	s.NotEqual(overridden, getRenderFingerprint("/cfg"))
+	ResetGlobals(map[string]string{})
+	s.Equal(expected, getRenderFingerprint("/cfg"))
+}
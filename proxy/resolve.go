@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// HostResolution is the result of the lookup of a host.
+type HostResolution struct {
+	Addresses []string
+	Err       error
+}
+
+// ResolveHosts resolves the hosts concurrently through the lookup function.
+// The number of concurrent lookups is limited to LOOKUP_WORKERS (10 by default) and each of them to LOOKUP_TIMEOUT.
+func ResolveHosts(ctx context.Context, lookup func(host string) ([]string, error), hosts []string) map[string]HostResolution {
+	workers, err := strconv.Atoi(GetSecretOrEnvVar("LOOKUP_WORKERS", ""))
+	if err != nil || workers <= 0 {
+		workers = 10
+	}
+	unique := []string{}
+	resolutions := map[string]HostResolution{}
+	for _, host := range hosts {
+		if _, ok := resolutions[host]; !ok {
+			resolutions[host] = HostResolution{}
+			unique = append(unique, host)
+		}
+	}
+	if workers > len(unique) {
+		workers = len(unique)
+	}
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	queue := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range queue {
+				addresses, err := LookupHost(ctx, lookup, host)
+				mu.Lock()
+				resolutions[host] = HostResolution{Addresses: addresses, Err: err}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, host := range unique {
+		queue <- host
+	}
+	close(queue)
+	wg.Wait()
+	return resolutions
+}
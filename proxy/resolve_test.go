@@ -0,0 +1,91 @@
+// +build !integration
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ResolveTestSuite struct {
+	suite.Suite
+}
+
+func TestResolveUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ResolveTestSuite))
+}
+
+// ResolveHosts
+
+func (s *ResolveTestSuite) Test_ResolveHosts_ReturnsResolutionOfEachHost() {
+	lookup := func(host string) ([]string, error) {
+		if host == "unknown-service" {
+			return nil, fmt.Errorf("no such host")
+		}
+		return []string{"10.0.0.1-" + host}, nil
+	}
+
+	actual := ResolveHosts(context.Background(), lookup, []string{"my-service", "unknown-service", "my-service"})
+
+	s.Len(actual, 2)
+	s.Equal([]string{"10.0.0.1-my-service"}, actual["my-service"].Addresses)
+	s.NoError(actual["my-service"].Err)
+	s.EqualError(actual["unknown-service"].Err, "no such host")
+}
+
+func (s *ResolveTestSuite) Test_ResolveHosts_LimitsConcurrentLookupsToLookupWorkers() {
+	defer os.Unsetenv("LOOKUP_WORKERS")
+	os.Setenv("LOOKUP_WORKERS", "3")
+	mu := sync.Mutex{}
+	current, max := 0, 0
+	lookup := func(host string) ([]string, error) {
+		mu.Lock()
+		current++
+		if current > max {
+			max = current
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		current--
+		mu.Unlock()
+		return []string{}, nil
+	}
+	hosts := []string{}
+	for i := 0; i < 12; i++ {
+		hosts = append(hosts, fmt.Sprintf("my-service-%d", i))
+	}
+
+	actual := ResolveHosts(context.Background(), lookup, hosts)
+
+	s.Len(actual, 12)
+	s.Equal(3, max)
+}
+
+func (s *ResolveTestSuite) Test_ResolveHosts_ReturnsErrors_WhenContextIsDone() {
+	done := make(chan struct{})
+	defer close(done)
+	lookup := func(host string) ([]string, error) {
+		<-done
+		return []string{}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	actual := ResolveHosts(ctx, lookup, []string{"my-service", "other-service"})
+
+	s.Error(actual["my-service"].Err)
+	s.Error(actual["other-service"].Err)
+}
+
+func (s *ResolveTestSuite) Test_ResolveHosts_ReturnsEmptyMap_WhenThereAreNoHosts() {
+	actual := ResolveHosts(context.Background(), nil, []string{})
+
+	s.Empty(actual)
+}
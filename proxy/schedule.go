@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// ScheduleMaintenance puts the service in maintenance between the start and the end of the action.
+	ScheduleMaintenance = "maintenance"
+	// ScheduleSwitchColor changes the color of the service at the start of the action.
+	ScheduleSwitchColor = "switchColor"
+)
+
+// ScheduledAction is a change of a service executed at a given time.
+type ScheduledAction struct {
+	// The ID of the action assigned when it is scheduled.
+	ID string
+	// The name of the service the action applies to.
+	ServiceName string
+	// The type of the action (maintenance or switchColor).
+	Type string
+	// The time the action starts.
+	Start time.Time
+	// The time the maintenance ends. Used only with the maintenance type.
+	End time.Time
+	// The color the service switches to. Used only with the switchColor type.
+	ServiceColor string
+	// Whether the maintenance started.
+	Started bool
+}
+
+// Schedule stores the scheduled actions and persists them to a file so that they survive restarts.
+type Schedule struct {
+	mu      *sync.Mutex
+	path    string
+	lastID  int
+	actions map[string]ScheduledAction
+}
+
+func NewSchedule(path string) *Schedule {
+	return &Schedule{
+		mu:      &sync.Mutex{},
+		path:    path,
+		actions: map[string]ScheduledAction{},
+	}
+}
+
+// Load reads the actions persisted in the schedule file.
+func (m *Schedule) Load() error {
+	content, err := ReadFile(m.path)
+	if err != nil {
+		return err
+	}
+	actions := []ScheduledAction{}
+	if err := json.Unmarshal(content, &actions); err != nil {
+		return fmt.Errorf("Could not parse the schedule file %s\n%s", m.path, err.Error())
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range actions {
+		m.actions[a.ID] = a
+		if id, _ := strconv.Atoi(a.ID); id > m.lastID {
+			m.lastID = id
+		}
+	}
+	return nil
+}
+
+// Put validates the action, assigns it an ID, and persists the schedule.
+func (m *Schedule) Put(action ScheduledAction) (ScheduledAction, error) {
+	if len(action.ServiceName) == 0 {
+		return action, fmt.Errorf("The service name is mandatory")
+	} else if action.Start.IsZero() {
+		return action, fmt.Errorf("The start time is mandatory")
+	}
+	switch action.Type {
+	case ScheduleMaintenance:
+		if !action.End.After(action.Start) {
+			return action, fmt.Errorf("The end of the maintenance must be after its start")
+		}
+	case ScheduleSwitchColor:
+		if len(action.ServiceColor) == 0 {
+			return action, fmt.Errorf("The service color is mandatory when switching colors")
+		}
+	default:
+		return action, fmt.Errorf("The action type must be %s or %s", ScheduleMaintenance, ScheduleSwitchColor)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastID++
+	action.ID = strconv.Itoa(m.lastID)
+	action.Started = false
+	m.actions[action.ID] = action
+	return action, m.save()
+}
+
+// Update replaces the action with the same ID and persists the schedule.
+func (m *Schedule) Update(action ScheduledAction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, found := m.actions[action.ID]; !found {
+		return fmt.Errorf("The action %s is not scheduled", action.ID)
+	}
+	m.actions[action.ID] = action
+	return m.save()
+}
+
+func (m *Schedule) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.actions, id)
+	return m.save()
+}
+
+// GetAll returns the scheduled actions ordered by their start.
+func (m *Schedule) GetAll() []ScheduledAction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.getSorted()
+}
+
+// GetDue returns the actions that should start or, in the case of maintenance, end.
+func (m *Schedule) GetDue() []ScheduledAction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := timeNow()
+	due := []ScheduledAction{}
+	for _, a := range m.getSorted() {
+		if a.Type == ScheduleMaintenance && !a.Started && !now.Before(a.End) {
+			// The proxy was not running during the whole maintenance window
+			logWarnf("The maintenance %s of the service %s ended before it could start. Removing it from the schedule.", a.ID, a.ServiceName)
+			delete(m.actions, a.ID)
+			m.save()
+		} else if (!a.Started && !now.Before(a.Start)) || (a.Started && !now.Before(a.End)) {
+			due = append(due, a)
+		}
+	}
+	return due
+}
+
+// IsInMaintenance returns whether a maintenance of the service is in progress.
+func (m *Schedule) IsInMaintenance(serviceName string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range m.actions {
+		if a.Type == ScheduleMaintenance && a.Started && a.ServiceName == serviceName {
+			return true
+		}
+	}
+	return false
+}
+
+type scheduledActions []ScheduledAction
+
+func (s scheduledActions) Len() int {
+	return len(s)
+}
+func (s scheduledActions) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+func (s scheduledActions) Less(i, j int) bool {
+	if s[i].Start.Equal(s[j].Start) {
+		return s[i].ID < s[j].ID
+	}
+	return s[i].Start.Before(s[j].Start)
+}
+
+// getSorted returns the actions ordered by their start. The caller must hold mu.
+func (m *Schedule) getSorted() []ScheduledAction {
+	actions := scheduledActions{}
+	for _, a := range m.actions {
+		actions = append(actions, a)
+	}
+	sort.Sort(actions)
+	return actions
+}
+
+// save writes the actions to the schedule file. The caller must hold mu.
+func (m *Schedule) save() error {
+	js, _ := json.Marshal(m.getSorted())
+	if err := writeFile(m.path, js, 0664); err != nil {
+		return fmt.Errorf("Could not write the schedule file %s\n%s", m.path, err.Error())
+	}
+	return nil
+}
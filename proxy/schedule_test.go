@@ -0,0 +1,142 @@
+// +build !integration
+
+package proxy
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ScheduleTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func (s *ScheduleTestSuite) SetupTest() {
+	s.now = time.Date(2017, 6, 1, 1, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time {
+		return s.now
+	}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		return nil
+	}
+}
+
+func TestScheduleUnitTestSuite(t *testing.T) {
+	timeNowOrig := timeNow
+	defer func() { timeNow = timeNowOrig }()
+	writeFileOrig := writeFile
+	defer func() { writeFile = writeFileOrig }()
+	readFileOrig := ReadFile
+	defer func() { ReadFile = readFileOrig }()
+	suite.Run(t, new(ScheduleTestSuite))
+}
+
+// Put
+
+func (s *ScheduleTestSuite) Test_Put_AssignsIdsAndPersistsActions() {
+	actualFilename := ""
+	actualData := ""
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualFilename = filename
+		actualData = string(data)
+		return nil
+	}
+	sch := NewSchedule("/cfg/schedule.json")
+
+	first, _ := sch.Put(s.getMaintenance())
+	second, err := sch.Put(ScheduledAction{ServiceName: "my-service", Type: ScheduleSwitchColor, Start: s.now, ServiceColor: "green"})
+
+	s.NoError(err)
+	s.Equal("1", first.ID)
+	s.Equal("2", second.ID)
+	s.Equal("/cfg/schedule.json", actualFilename)
+	s.Contains(actualData, `"ServiceColor":"green"`)
+}
+
+func (s *ScheduleTestSuite) Test_Put_ReturnsError_WhenMaintenanceEndsBeforeItStarts() {
+	action := s.getMaintenance()
+	action.End = action.Start.Add(-time.Minute)
+
+	_, err := NewSchedule("/cfg/schedule.json").Put(action)
+
+	s.Error(err)
+}
+
+func (s *ScheduleTestSuite) Test_Put_ReturnsError_WhenColorIsMissing() {
+	_, err := NewSchedule("/cfg/schedule.json").Put(ScheduledAction{ServiceName: "my-service", Type: ScheduleSwitchColor, Start: s.now})
+
+	s.Error(err)
+}
+
+func (s *ScheduleTestSuite) Test_Put_ReturnsError_WhenTypeIsUnknown() {
+	action := s.getMaintenance()
+	action.Type = "restart"
+
+	_, err := NewSchedule("/cfg/schedule.json").Put(action)
+
+	s.Error(err)
+}
+
+// Load
+
+func (s *ScheduleTestSuite) Test_Load_ReadsPersistedActions() {
+	var persisted []byte
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		persisted = data
+		return nil
+	}
+	ReadFile = func(filename string) ([]byte, error) {
+		return persisted, nil
+	}
+	scheduled, _ := NewSchedule("/cfg/schedule.json").Put(s.getMaintenance())
+	sch := NewSchedule("/cfg/schedule.json")
+
+	err := sch.Load()
+	next, _ := sch.Put(s.getMaintenance())
+
+	s.NoError(err)
+	s.Equal([]ScheduledAction{scheduled, next}, sch.GetAll())
+	s.Equal("2", next.ID)
+}
+
+// GetDue
+
+func (s *ScheduleTestSuite) Test_GetDue_ReturnsActionsThatShouldStartOrEnd() {
+	sch := NewSchedule("/cfg/schedule.json")
+	maintenance, _ := sch.Put(s.getMaintenance())
+
+	s.Equal([]ScheduledAction{}, sch.GetDue())
+
+	s.now = maintenance.Start
+	s.Equal([]ScheduledAction{maintenance}, sch.GetDue())
+
+	maintenance.Started = true
+	sch.Update(maintenance)
+	s.Equal([]ScheduledAction{}, sch.GetDue())
+	s.True(sch.IsInMaintenance("my-service"))
+
+	s.now = maintenance.End
+	s.Equal([]ScheduledAction{maintenance}, sch.GetDue())
+}
+
+func (s *ScheduleTestSuite) Test_GetDue_RemovesMaintenance_WhenItEndedBeforeItStarted() {
+	sch := NewSchedule("/cfg/schedule.json")
+	maintenance, _ := sch.Put(s.getMaintenance())
+	s.now = maintenance.End.Add(time.Minute)
+
+	s.Equal([]ScheduledAction{}, sch.GetDue())
+	s.Equal([]ScheduledAction{}, sch.GetAll())
+}
+
+func (s *ScheduleTestSuite) getMaintenance() ScheduledAction {
+	return ScheduledAction{
+		ServiceName: "my-service",
+		Type:        ScheduleMaintenance,
+		Start:       time.Date(2017, 6, 1, 2, 0, 0, 0, time.UTC),
+		End:         time.Date(2017, 6, 1, 2, 30, 0, 0, time.UTC),
+	}
+}
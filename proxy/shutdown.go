@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var signalProcess = syscall.Kill
+var isProcessRunning = func(pid int) bool {
+	// Reap the process in case it is a zombie child of the proxy running as the init process of the container
+	var status syscall.WaitStatus
+	syscall.Wait4(pid, &status, syscall.WNOHANG, nil)
+	return syscall.Kill(pid, 0) == nil
+}
+var softStopPollInterval = 100 * time.Millisecond
+
+// SoftStop asks HAProxy to stop listening and to exit once the in-flight requests are finished.
+// The processes that are still running after the grace period are terminated.
+func SoftStop(gracePeriod time.Duration) error {
+	pidPath := "/var/run/haproxy.pid"
+	content, err := readPidFile(pidPath)
+	if err != nil {
+		return fmt.Errorf("Could not read the %s file\n%s", pidPath, err.Error())
+	}
+	pids := []int{}
+	for _, field := range strings.Fields(string(content)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	for _, pid := range pids {
+		signalProcess(pid, syscall.SIGUSR1)
+	}
+	deadline := timeNow().Add(gracePeriod)
+	for {
+		running := []int{}
+		for _, pid := range pids {
+			if isProcessRunning(pid) {
+				running = append(running, pid)
+			}
+		}
+		pids = running
+		if len(pids) == 0 {
+			return nil
+		} else if !timeNow().Before(deadline) {
+			break
+		}
+		time.Sleep(softStopPollInterval)
+	}
+	for _, pid := range pids {
+		signalProcess(pid, syscall.SIGTERM)
+	}
+	return fmt.Errorf("HAProxy did not finish the in-flight requests within %s", gracePeriod.String())
+}
@@ -0,0 +1,74 @@
+// +build !integration
+
+package proxy
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ShutdownTestSuite struct {
+	suite.Suite
+	now     time.Time
+	signals map[int][]syscall.Signal
+}
+
+func (s *ShutdownTestSuite) SetupTest() {
+	s.now = time.Date(2017, 6, 1, 2, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time {
+		return s.now
+	}
+	s.signals = map[int][]syscall.Signal{}
+	signalProcess = func(pid int, sig syscall.Signal) error {
+		s.signals[pid] = append(s.signals[pid], sig)
+		return nil
+	}
+	readPidFile = func(fileName string) ([]byte, error) {
+		return []byte("12\n34\n"), nil
+	}
+	softStopPollInterval = 0
+}
+
+func TestShutdownUnitTestSuite(t *testing.T) {
+	timeNowOrig := timeNow
+	defer func() { timeNow = timeNowOrig }()
+	signalProcessOrig := signalProcess
+	defer func() { signalProcess = signalProcessOrig }()
+	isProcessRunningOrig := isProcessRunning
+	defer func() { isProcessRunning = isProcessRunningOrig }()
+	readPidFileOrig := readPidFile
+	defer func() { readPidFile = readPidFileOrig }()
+	softStopPollIntervalOrig := softStopPollInterval
+	defer func() { softStopPollInterval = softStopPollIntervalOrig }()
+	suite.Run(t, new(ShutdownTestSuite))
+}
+
+// SoftStop
+
+func (s *ShutdownTestSuite) Test_SoftStop_SendsSoftStopSignal_AndWaitsForProcessesToExit() {
+	checks := 0
+	isProcessRunning = func(pid int) bool {
+		checks++
+		return checks < 4
+	}
+
+	err := SoftStop(5 * time.Second)
+
+	s.NoError(err)
+	s.Equal(map[int][]syscall.Signal{12: {syscall.SIGUSR1}, 34: {syscall.SIGUSR1}}, s.signals)
+}
+
+func (s *ShutdownTestSuite) Test_SoftStop_TerminatesProcesses_WhenGracePeriodExpires() {
+	isProcessRunning = func(pid int) bool {
+		s.now = s.now.Add(time.Second)
+		return pid == 34
+	}
+
+	err := SoftStop(5 * time.Second)
+
+	s.Error(err)
+	s.Equal(map[int][]syscall.Signal{12: {syscall.SIGUSR1}, 34: {syscall.SIGUSR1, syscall.SIGTERM}}, s.signals)
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import "sort"
+
+// State is the export of the proxy used to restore it or to configure another proxy the same way.
+type State struct {
+	// The services configured in the proxy.
+	Services []Service
+	// The paths of the certificates loaded by the proxy. The content of the certificates is not exported.
+	Certs []string
+	// The global settings changed through the globals endpoint.
+	Globals map[string]string
+	// The reconfigure parameters of the services referenced by cloneFrom. They are set by the state endpoint.
+	Params map[string]map[string]string `json:",omitempty"`
+	// The services disabled through the disable endpoint. They are set by the state endpoint.
+	Disabled []string `json:",omitempty"`
+}
+
+// GetState returns the services, the paths of the certificates, and the changed global settings.
+func GetState() State {
+	services := Services{}
+	for _, s := range Instance.GetServices() {
+		services = append(services, s)
+	}
+	sort.Sort(services)
+	certs := Instance.GetCertPaths()
+	sort.Strings(certs)
+	return State{
+		Services: services,
+		Certs:    certs,
+		Globals:  GetGlobalOverrides(),
+	}
+}
+
+// GetMissingCerts returns the certificates of the state that are not loaded by the proxy.
+func GetMissingCerts(state State) []string {
+	loaded := map[string]bool{}
+	for _, path := range Instance.GetCertPaths() {
+		loaded[path] = true
+	}
+	missing := []string{}
+	for _, path := range state.Certs {
+		if !loaded[path] {
+			missing = append(missing, path)
+		}
+	}
+	return missing
+}
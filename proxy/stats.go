@@ -0,0 +1,251 @@
+package proxy
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The path of the HAProxy stats socket defined in haproxy.tmpl
+var StatsSocketPath = "/var/run/haproxy.sock"
+
+// sendSocketCommand runs the command through the HAProxy stats socket and returns its output
+var sendSocketCommand = func(command string) (string, error) {
+	conn, err := net.DialTimeout("unix", StatsSocketPath, 5*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(command + "\n")); err != nil {
+		return "", err
+	}
+	out, err := ioutil.ReadAll(conn)
+	return string(out), err
+}
+
+var readStatsCsv = func() (string, error) {
+	return sendSocketCommand("show stat")
+}
+
+type ServiceStats struct {
+	// The name of the service.
+	ServiceName string
+	// The stats of each backend of the service (one per port and protocol).
+	Backends []BackendStats
+}
+
+type BackendStats struct {
+	// The name of the backend.
+	Name string
+	// The status of the backend (e.g. UP or DOWN).
+	Status string
+	// The number of current sessions.
+	CurrentSessions int
+	// The number of sessions per second over the last second.
+	SessionRate int
+	// The number of HTTP requests per second over the last second.
+	// HAProxy versions that do not report the request rate of backends use the session rate instead.
+	RequestRate int
+	// The total number of HTTP requests.
+	// HAProxy versions that do not report the requests of backends use the total number of sessions instead.
+	TotalRequests int
+	// The average response time in milliseconds over the last 1024 requests.
+	ResponseTime int
+	// The number of responses with 4xx codes.
+	Http4xxResponses int
+	// The number of responses with 5xx codes.
+	Http5xxResponses int
+	// The number of requests that encountered an error trying to connect to a server.
+	ConnectionErrors int
+	// The number of response errors.
+	ResponseErrors int
+	// The stats of each server of the backend.
+	Servers []ServerStats
+}
+
+type ServerStats struct {
+	// The name of the server.
+	Name string
+	// The status of the server (e.g. UP, DOWN, MAINT, or no check).
+	Status string
+	// The status of the last health check.
+	CheckStatus string
+	// The number of current sessions.
+	CurrentSessions int
+	// The number of sessions per second over the last second.
+	SessionRate int
+}
+
+// GetServiceStats returns the stats of the backends of the service read from the HAProxy stats socket.
+func GetServiceStats(serviceName string) (ServiceStats, error) {
+	backends, err := getBackendStats(func(pxname string) bool {
+		return isServiceBackend(pxname, serviceName)
+	})
+	return ServiceStats{ServiceName: serviceName, Backends: backends}, err
+}
+
+// GetAllBackendStats returns the stats of all the backends read from the HAProxy stats socket.
+func GetAllBackendStats() ([]BackendStats, error) {
+	return getBackendStats(func(pxname string) bool {
+		return true
+	})
+}
+
+func getBackendStats(include func(pxname string) bool) ([]BackendStats, error) {
+	stats := []BackendStats{}
+	out, err := readStatsCsv()
+	if err != nil {
+		return stats, fmt.Errorf("Could not read stats from the socket %s\n%s", StatsSocketPath, err.Error())
+	}
+	rows, err := parseStatsCsv(out)
+	if err != nil {
+		return stats, err
+	}
+	backends := map[string]*BackendStats{}
+	names := []string{}
+	getBackend := func(name string) *BackendStats {
+		if _, ok := backends[name]; !ok {
+			backends[name] = &BackendStats{Name: name, Servers: []ServerStats{}}
+			names = append(names, name)
+		}
+		return backends[name]
+	}
+	for _, row := range rows {
+		if !include(row["pxname"]) {
+			continue
+		}
+		switch row["svname"] {
+		case "FRONTEND":
+		case "BACKEND":
+			be := getBackend(row["pxname"])
+			be.Status = row["status"]
+			be.CurrentSessions = getStatsInt(row["scur"])
+			be.SessionRate = getStatsInt(row["rate"])
+			be.RequestRate = be.SessionRate
+			if len(row["req_rate"]) > 0 {
+				be.RequestRate = getStatsInt(row["req_rate"])
+			}
+			be.TotalRequests = getStatsInt(row["stot"])
+			if len(row["req_tot"]) > 0 {
+				be.TotalRequests = getStatsInt(row["req_tot"])
+			}
+			be.ResponseTime = getStatsInt(row["rtime"])
+			be.Http4xxResponses = getStatsInt(row["hrsp_4xx"])
+			be.Http5xxResponses = getStatsInt(row["hrsp_5xx"])
+			be.ConnectionErrors = getStatsInt(row["econ"])
+			be.ResponseErrors = getStatsInt(row["eresp"])
+		default:
+			be := getBackend(row["pxname"])
+			be.Servers = append(be.Servers, ServerStats{
+				Name:            row["svname"],
+				Status:          row["status"],
+				CheckStatus:     row["check_status"],
+				CurrentSessions: getStatsInt(row["scur"]),
+				SessionRate:     getStatsInt(row["rate"]),
+			})
+		}
+	}
+	for _, name := range names {
+		stats = append(stats, *backends[name])
+	}
+	return stats, nil
+}
+
+type BackendsHealth struct {
+	// Whether the percentage of healthy services reached the required percentage.
+	Healthy bool
+	// The percentage of healthy services.
+	Percentage float64
+	// The names of the services with all their backends up.
+	HealthyServices []string
+	// The names of the services with at least one backend that is not up or without any backends.
+	UnhealthyServices []string
+}
+
+// GetBackendsHealth returns whether at least the required percentage of the services have all their backends up.
+func GetBackendsHealth(serviceNames []string, requiredPercentage float64) (BackendsHealth, error) {
+	health := BackendsHealth{HealthyServices: []string{}, UnhealthyServices: []string{}}
+	backends, err := GetAllBackendStats()
+	if err != nil {
+		return health, err
+	}
+	sort.Strings(serviceNames)
+	for _, serviceName := range serviceNames {
+		healthy := false
+		for _, be := range backends {
+			if isServiceBackend(be.Name, serviceName) {
+				healthy = strings.HasPrefix(be.Status, "UP")
+				if !healthy {
+					break
+				}
+			}
+		}
+		if healthy {
+			health.HealthyServices = append(health.HealthyServices, serviceName)
+		} else {
+			health.UnhealthyServices = append(health.UnhealthyServices, serviceName)
+		}
+	}
+	health.Percentage = 100
+	if len(serviceNames) > 0 {
+		health.Percentage = float64(len(health.HealthyServices)) * 100 / float64(len(serviceNames))
+	}
+	health.Healthy = health.Percentage >= requiredPercentage
+	return health, nil
+}
+
+// GetBackendService returns the service the backend belongs to.
+func GetBackendService(pxname string, services map[string]Service) (Service, bool) {
+	for _, s := range services {
+		if isServiceBackend(pxname, s.ServiceName) {
+			return s, true
+		}
+	}
+	return Service{}, false
+}
+
+// parseStatsCsv converts the output of the "show stat" command into rows keyed by the column names.
+func parseStatsCsv(out string) ([]map[string]string, error) {
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(out, "# ")))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse stats\n%s", err.Error())
+	}
+	rows := []map[string]string{}
+	if len(records) == 0 {
+		return rows, nil
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// isServiceBackend returns whether the proxy is one of the backends (<service>-be<port> or https-<service>-be<port>) of the service.
+func isServiceBackend(pxname, serviceName string) bool {
+	serviceName = GetIdentifier(serviceName)
+	name := strings.TrimPrefix(pxname, "https-")
+	if !strings.HasPrefix(name, serviceName+"-be") {
+		return false
+	}
+	_, err := strconv.Atoi(strings.TrimPrefix(name, serviceName+"-be"))
+	return err == nil
+}
+
+func getStatsInt(value string) int {
+	i, _ := strconv.Atoi(value)
+	return i
+}
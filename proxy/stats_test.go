@@ -0,0 +1,140 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type StatsTestSuite struct {
+	suite.Suite
+}
+
+func TestStatsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(StatsTestSuite))
+}
+
+// GetServiceStats
+
+func (s StatsTestSuite) Test_GetServiceStats_ReturnsBackendsOfTheService() {
+	readStatsCsvOrig := readStatsCsv
+	defer func() { readStatsCsv = readStatsCsvOrig }()
+	readStatsCsv = func() (string, error) {
+		return `# pxname,svname,scur,econ,eresp,status,rate,check_status,hrsp_4xx,hrsp_5xx,req_rate,
+services,FRONTEND,10,,,OPEN,5,,1,2,7,
+my-service-be8080,my-service_1,3,,0,UP,2,L4OK,,,,
+my-service-be8080,my-service_2,0,,0,DOWN,0,L4CON,,,,
+my-service-be8080,BACKEND,3,4,1,UP,2,,5,6,,
+my-service-beta-be8080,BACKEND,8,0,0,UP,1,,0,0,,
+`, nil
+	}
+
+	actual, err := GetServiceStats("my-service")
+
+	s.NoError(err)
+	s.Equal(ServiceStats{
+		ServiceName: "my-service",
+		Backends: []BackendStats{{
+			Name:             "my-service-be8080",
+			Status:           "UP",
+			CurrentSessions:  3,
+			SessionRate:      2,
+			RequestRate:      2,
+			Http4xxResponses: 5,
+			Http5xxResponses: 6,
+			ConnectionErrors: 4,
+			ResponseErrors:   1,
+			Servers: []ServerStats{
+				{Name: "my-service_1", Status: "UP", CheckStatus: "L4OK", CurrentSessions: 3, SessionRate: 2},
+				{Name: "my-service_2", Status: "DOWN", CheckStatus: "L4CON"},
+			},
+		}},
+	}, actual)
+}
+
+func (s StatsTestSuite) Test_GetServiceStats_ReturnsTotalRequestsAndResponseTime() {
+	readStatsCsvOrig := readStatsCsv
+	defer func() { readStatsCsv = readStatsCsvOrig }()
+	readStatsCsv = func() (string, error) {
+		return `# pxname,svname,status,stot,req_tot,rtime,
+my-service-be8080,BACKEND,UP,10,25,12,
+my-service-be8081,BACKEND,UP,10,,3,
+`, nil
+	}
+
+	actual, err := GetServiceStats("my-service")
+
+	s.NoError(err)
+	s.Equal(25, actual.Backends[0].TotalRequests)
+	s.Equal(12, actual.Backends[0].ResponseTime)
+	s.Equal(10, actual.Backends[1].TotalRequests)
+}
+
+func (s StatsTestSuite) Test_GetServiceStats_ReturnsError_WhenSocketCannotBeRead() {
+	readStatsCsvOrig := readStatsCsv
+	defer func() { readStatsCsv = readStatsCsvOrig }()
+	readStatsCsv = func() (string, error) {
+		return "", fmt.Errorf("This is an error")
+	}
+
+	_, err := GetServiceStats("my-service")
+
+	s.Error(err)
+}
+
+// GetBackendService
+
+func (s StatsTestSuite) Test_GetBackendService_ReturnsServiceOfTheBackend() {
+	services := map[string]Service{
+		"my-service":      {ServiceName: "my-service"},
+		"my-service-beta": {ServiceName: "my-service-beta"},
+	}
+
+	actual, found := GetBackendService("https-my-service-be8080", services)
+	s.True(found)
+	s.Equal("my-service", actual.ServiceName)
+
+	actual, found = GetBackendService("my-service-beta-be8080", services)
+	s.True(found)
+	s.Equal("my-service-beta", actual.ServiceName)
+
+	_, found = GetBackendService("acme-challenge-be", services)
+	s.False(found)
+}
+
+// GetBackendsHealth
+
+func (s StatsTestSuite) Test_GetBackendsHealth_ReturnsPercentageOfHealthyServices() {
+	readStatsCsvOrig := readStatsCsv
+	defer func() { readStatsCsv = readStatsCsvOrig }()
+	readStatsCsv = func() (string, error) {
+		return `# pxname,svname,status,
+my-service-be8080,BACKEND,UP,
+other-service-be8080,BACKEND,UP,
+other-service-be8081,BACKEND,DOWN,
+`, nil
+	}
+
+	actual, err := GetBackendsHealth([]string{"other-service", "my-service", "missing-service"}, 30)
+
+	s.NoError(err)
+	s.True(actual.Healthy)
+	s.InDelta(33.3, actual.Percentage, 0.1)
+	s.Equal([]string{"my-service"}, actual.HealthyServices)
+	s.Equal([]string{"missing-service", "other-service"}, actual.UnhealthyServices)
+}
+
+func (s StatsTestSuite) Test_GetBackendsHealth_ReturnsUnhealthy_WhenPercentageIsBelowRequired() {
+	readStatsCsvOrig := readStatsCsv
+	defer func() { readStatsCsv = readStatsCsvOrig }()
+	readStatsCsv = func() (string, error) {
+		return "# pxname,svname,status,\nmy-service-be8080,BACKEND,DOWN,\n", nil
+	}
+
+	actual, _ := GetBackendsHealth([]string{"my-service"}, 100)
+
+	s.False(actual.Healthy)
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// The maximum size of a StatsD packet that fits into a single datagram on most networks
+const statsdPacketSize = 1432
+
+var statsdDial = net.Dial
+var getAllBackendStats = GetAllBackendStats
+
+// StatsD pushes the metrics of the backends to a StatsD endpoint.
+type StatsD struct {
+	// The address (<host>:<port>) of the StatsD endpoint.
+	Address string
+	// The prefix of the metric names.
+	Prefix string
+	mu     *sync.Mutex
+	// The counters sent during the previous push, used to send only the increments.
+	counters map[string]int
+}
+
+func NewStatsD(address, prefix string) *StatsD {
+	return &StatsD{
+		Address:  address,
+		Prefix:   prefix,
+		mu:       &sync.Mutex{},
+		counters: map[string]int{},
+	}
+}
+
+// Push sends the request rate, the number of 5xx responses, and the health of each backend over UDP.
+func (m *StatsD) Push() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	backends, err := getAllBackendStats()
+	if err != nil {
+		return err
+	}
+	conn, err := statsdDial("udp", m.Address)
+	if err != nil {
+		return fmt.Errorf("Could not connect to StatsD %s\n%s", m.Address, err.Error())
+	}
+	defer conn.Close()
+	for _, packet := range getStatsdPackets(m.getMetrics(backends)) {
+		if _, err := conn.Write([]byte(packet)); err != nil {
+			return fmt.Errorf("Could not send metrics to StatsD %s\n%s", m.Address, err.Error())
+		}
+	}
+	return nil
+}
+
+func (m *StatsD) getMetrics(backends []BackendStats) []string {
+	metrics := []string{}
+	for _, be := range backends {
+		name := fmt.Sprintf("%s.%s", m.Prefix, getStatsdName(be.Name))
+		up := 0
+		if strings.HasPrefix(be.Status, "UP") {
+			up = 1
+		}
+		serversUp := 0
+		for _, srv := range be.Servers {
+			if strings.HasPrefix(srv.Status, "UP") {
+				serversUp++
+			}
+		}
+		metrics = append(
+			metrics,
+			fmt.Sprintf("%s.request_rate:%d|g", name, be.RequestRate),
+			fmt.Sprintf("%s.current_sessions:%d|g", name, be.CurrentSessions),
+			fmt.Sprintf("%s.up:%d|g", name, up),
+			fmt.Sprintf("%s.servers_up:%d|g", name, serversUp),
+		)
+		if increment, ok := m.getIncrement(name+".http_5xx", be.Http5xxResponses); ok {
+			metrics = append(metrics, fmt.Sprintf("%s.http_5xx:%d|c", name, increment))
+		}
+	}
+	return metrics
+}
+
+// getIncrement returns the difference between the counter and its value during the previous push.
+// The first value of a counter is only recorded since it includes everything that happened before the push started.
+// HAProxy resets its counters on reload so a counter lower than the previous value is sent as it is.
+func (m *StatsD) getIncrement(name string, value int) (int, bool) {
+	previous, found := m.counters[name]
+	m.counters[name] = value
+	if !found {
+		return 0, false
+	} else if value < previous {
+		return value, true
+	}
+	return value - previous, true
+}
+
+// getStatsdName replaces the characters with a special meaning in StatsD metric names.
+func getStatsdName(name string) string {
+	return strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", " ", "_").Replace(name)
+}
+
+// getStatsdPackets joins metrics into newline separated packets that do not exceed the packet size.
+func getStatsdPackets(metrics []string) []string {
+	packets := []string{}
+	packet := ""
+	for _, metric := range metrics {
+		if len(packet) > 0 && len(packet)+len(metric)+1 > statsdPacketSize {
+			packets = append(packets, packet)
+			packet = ""
+		}
+		if len(packet) > 0 {
+			packet += "\n"
+		}
+		packet += metric
+	}
+	if len(packet) > 0 {
+		packets = append(packets, packet)
+	}
+	return packets
+}
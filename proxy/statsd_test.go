@@ -0,0 +1,103 @@
+// +build !integration
+
+package proxy
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type StatsDTestSuite struct {
+	suite.Suite
+	backends []BackendStats
+}
+
+func (s *StatsDTestSuite) SetupTest() {
+	s.backends = []BackendStats{{
+		Name:             "team-a.my-service-be8080",
+		Status:           "UP",
+		CurrentSessions:  3,
+		RequestRate:      12,
+		Http5xxResponses: 5,
+		Servers: []ServerStats{
+			{Name: "my-service_1", Status: "UP"},
+			{Name: "my-service_2", Status: "DOWN"},
+		},
+	}}
+}
+
+func TestStatsDUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(StatsDTestSuite))
+}
+
+// Push
+
+func (s StatsDTestSuite) Test_Push_SendsMetricsOverUdp() {
+	conn, _ := net.ListenPacket("udp", "127.0.0.1:0")
+	defer conn.Close()
+	getAllBackendStatsOrig := getAllBackendStats
+	defer func() { getAllBackendStats = getAllBackendStatsOrig }()
+	getAllBackendStats = func() ([]BackendStats, error) {
+		return s.backends, nil
+	}
+	statsd := NewStatsD(conn.LocalAddr().String(), "dfp")
+
+	err := statsd.Push()
+
+	s.NoError(err)
+	buf := make([]byte, statsdPacketSize)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, _ := conn.ReadFrom(buf)
+	s.Equal(`dfp.team-a_my-service-be8080.request_rate:12|g
+dfp.team-a_my-service-be8080.current_sessions:3|g
+dfp.team-a_my-service-be8080.up:1|g
+dfp.team-a_my-service-be8080.servers_up:1|g`, string(buf[:n]))
+}
+
+func (s StatsDTestSuite) Test_Push_ReturnsError_WhenStatsCannotBeRead() {
+	readStatsCsvOrig := readStatsCsv
+	defer func() { readStatsCsv = readStatsCsvOrig }()
+	readStatsCsv = func() (string, error) {
+		return "", net.UnknownNetworkError("unix")
+	}
+
+	err := NewStatsD("127.0.0.1:8125", "dfp").Push()
+
+	s.Error(err)
+}
+
+// getMetrics
+
+func (s StatsDTestSuite) Test_GetMetrics_SendsIncrementsOf5xxResponses() {
+	statsd := NewStatsD("127.0.0.1:8125", "dfp")
+	statsd.getMetrics(s.backends)
+	s.backends[0].Http5xxResponses = 8
+
+	actual := statsd.getMetrics(s.backends)
+
+	s.Contains(actual, "dfp.team-a_my-service-be8080.http_5xx:3|c")
+}
+
+func (s StatsDTestSuite) Test_GetMetrics_SendsCounter_WhenCounterWasReset() {
+	statsd := NewStatsD("127.0.0.1:8125", "dfp")
+	statsd.getMetrics(s.backends)
+	s.backends[0].Http5xxResponses = 2
+
+	actual := statsd.getMetrics(s.backends)
+
+	s.Contains(actual, "dfp.team-a_my-service-be8080.http_5xx:2|c")
+}
+
+// getStatsdPackets
+
+func (s StatsDTestSuite) Test_GetStatsdPackets_SplitsMetricsIntoPackets() {
+	metric := strings.Repeat("a", 1000)
+
+	actual := getStatsdPackets([]string{metric, metric, "b"})
+
+	s.Equal([]string{metric, metric + "\nb"}, actual)
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+var timeNow = time.Now
+var statusMu = &sync.Mutex{}
+var status = Status{}
+
+type Status struct {
+	// The number of reloads since the proxy started.
+	ReloadCount int
+	// The number of failed reloads since the proxy started.
+	ReloadFailureCount int
+	// The time of the last reload.
+	LastReloadTime time.Time
+	// The duration of the last reload in seconds.
+	LastReloadDuration float64
+	// The error returned by the last reload. Empty if the last reload succeeded.
+	LastReloadError string
+	// The time when the configuration was generated for the last time.
+	LastConfigTime time.Time
+}
+
+// GetStatus returns a copy of the reload and config generation status.
+func GetStatus() Status {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	return status
+}
+
+func recordReload(start time.Time, err error) {
+	statusMu.Lock()
+	status.ReloadCount++
+	status.LastReloadTime = start
+	status.LastReloadDuration = timeNow().Sub(start).Seconds()
+	event := Event{Type: EventReload, Status: "OK"}
+	if err != nil {
+		status.ReloadFailureCount++
+		status.LastReloadError = err.Error()
+		event.Status, event.Message = "NOK", err.Error()
+	} else {
+		status.LastReloadError = ""
+	}
+	statusMu.Unlock()
+	PublishEvent(event)
+}
+
+func recordConfig() {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	status.LastConfigTime = timeNow()
+}
@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"sync"
+)
+
+// Data is the store of the services of a proxy.
+// It is safe for concurrent use. Updates never modify a published snapshot but replace it with a modified copy
+// so that the snapshot returned by Snapshot can be read (e.g. while rendering the configuration) without holding a lock.
+type Data struct {
+	// The current snapshot. It must not be modified and should be read through Snapshot.
+	Services    map[string]Service
+	mu          sync.RWMutex
+	subscribers map[int]chan map[string]Service
+	lastID      int
+	// The snippets rendered from the services.
+	renders renderCache
+	// The values shared by many services (e.g. http or path_beg) so that each is stored only once.
+	values map[string]string
+}
+
+// The services shared by the proxies of the process. Proxies created through NewController have their own.
+var data = Data{}
+
+// getData returns the services of the proxy or, if it does not have its own, the services shared by the process.
+func getData(d *Data) *Data {
+	if d != nil {
+		return d
+	}
+	return &data
+}
+
+// Snapshot returns the current services. The map must not be modified.
+func (d *Data) Snapshot() map[string]Service {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.Services == nil {
+		return map[string]Service{}
+	}
+	return d.Services
+}
+
+// Put adds the services or replaces those with the same names.
+func (d *Data) Put(services ...Service) {
+	d.update(func(snapshot map[string]Service) {
+		for _, s := range services {
+			snapshot[s.ServiceName] = d.intern(s)
+		}
+	})
+}
+
+// Delete removes the services with the names.
+func (d *Data) Delete(serviceNames ...string) {
+	d.update(func(snapshot map[string]Service) {
+		for _, name := range serviceNames {
+			delete(snapshot, name)
+		}
+	})
+}
+
+// Replace replaces all the services (e.g. with a snapshot taken before a change that could not be applied).
+func (d *Data) Replace(services map[string]Service) {
+	d.update(func(snapshot map[string]Service) {
+		for name := range snapshot {
+			delete(snapshot, name)
+		}
+		for name, s := range services {
+			snapshot[name] = d.intern(s)
+		}
+	})
+}
+
+// Subscribe returns a channel that receives the snapshot after each change and a function that ends the subscription.
+// Subscribers that fall behind receive only the latest snapshot so that a slow subscriber never blocks updates.
+func (d *Data) Subscribe() (<-chan map[string]Service, func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.subscribers == nil {
+		d.subscribers = map[int]chan map[string]Service{}
+	}
+	d.lastID++
+	id := d.lastID
+	c := make(chan map[string]Service, 1)
+	d.subscribers[id] = c
+	return c, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if _, ok := d.subscribers[id]; ok {
+			delete(d.subscribers, id)
+			close(c)
+		}
+	}
+}
+
+// update applies the change to a copy of the current snapshot, publishes the copy, and notifies the subscribers.
+func (d *Data) update(change func(snapshot map[string]Service)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	snapshot := copyServices(d.Services)
+	change(snapshot)
+	d.Services = snapshot
+	for _, c := range d.subscribers {
+		select {
+		case <-c:
+		default:
+		}
+		c <- snapshot
+	}
+}
+
+// intern replaces the values of the fields that are usually the same for many services with the copies stored in the pool.
+// Services decoded from requests have their own copies of each value which, with thousands of services, take more memory than the values.
+// The ServiceDest slice is copied so that the slice of the caller is not modified. The caller must hold the lock.
+func (d *Data) intern(s Service) Service {
+	if d.values == nil {
+		d.values = map[string]string{}
+	}
+	for _, field := range []*string{&s.ReqMode, &s.PathType, &s.Namespace, &s.ConnectionMode, &s.TimeoutServer, &s.TimeoutTunnel} {
+		*field = d.internValue(*field)
+	}
+	if len(s.ServiceDest) > 0 {
+		s.ServiceDest = append([]ServiceDest{}, s.ServiceDest...)
+		for i := range s.ServiceDest {
+			sd := &s.ServiceDest[i]
+			for _, field := range []*string{&sd.Port, &sd.TimeoutServer, &sd.TimeoutTunnel} {
+				*field = d.internValue(*field)
+			}
+		}
+	}
+	return s
+}
+
+func (d *Data) internValue(value string) string {
+	if len(value) == 0 {
+		return value
+	}
+	if interned, ok := d.values[value]; ok {
+		return interned
+	}
+	d.values[value] = value
+	return value
+}
+
+// copyServices returns copies of the services in a new map so that callers cannot modify the state.
+func copyServices(snapshot map[string]Service) map[string]Service {
+	services := map[string]Service{}
+	for name, s := range snapshot {
+		services[name] = copyService(s)
+	}
+	return services
+}
+
+// copyService returns a copy of the service that does not share its slices and maps with the original.
+// Changing a copy (e.g. the certificates of a reconfigure request) would otherwise change the published snapshot.
+func copyService(s Service) Service {
+	for _, field := range []*[]string{
+		&s.CaptureCookies, &s.CaptureRequestHeaders, &s.DbReaders, &s.DbWriters, &s.Hosts, &s.ServiceDomain, &s.SrcNetworks,
+	} {
+		*field = copyStrings(*field)
+	}
+	if s.DomainAclPriority != nil {
+		priorities := map[string]int{}
+		for domain, priority := range s.DomainAclPriority {
+			priorities[domain] = priority
+		}
+		s.DomainAclPriority = priorities
+	}
+	if s.ServiceCerts != nil {
+		certs := map[string]string{}
+		for domain, cert := range s.ServiceCerts {
+			certs[domain] = cert
+		}
+		s.ServiceCerts = certs
+	}
+	if s.ServiceDest != nil {
+		s.ServiceDest = append([]ServiceDest{}, s.ServiceDest...)
+		for i := range s.ServiceDest {
+			s.ServiceDest[i].Alpn = copyStrings(s.ServiceDest[i].Alpn)
+			s.ServiceDest[i].ServicePath = copyStrings(s.ServiceDest[i].ServicePath)
+		}
+	}
+	if s.SplitGroups != nil {
+		s.SplitGroups = append([]SplitGroup{}, s.SplitGroups...)
+	}
+	if s.Tasks != nil {
+		s.Tasks = append([]Task{}, s.Tasks...)
+	}
+	if s.Users != nil {
+		s.Users = append([]User{}, s.Users...)
+	}
+	return s
+}
+
+// RedactService returns a copy of the service without the passwords of its users and the contents of its certificates
+// so that the stored services can be output by the API.
+func RedactService(s Service) Service {
+	s = copyService(s)
+	for i := range s.Users {
+		if len(s.Users[i].Password) > 0 {
+			s.Users[i].Password = redactedGlobal
+		}
+	}
+	if len(s.ServiceCert) > 0 {
+		s.ServiceCert = redactedGlobal
+	}
+	for domain := range s.ServiceCerts {
+		s.ServiceCerts[domain] = redactedGlobal
+	}
+	return s
+}
+
+func copyStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string{}, values...)
+}
@@ -0,0 +1,195 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type StoreTestSuite struct {
+	suite.Suite
+	store *Data
+}
+
+func (s *StoreTestSuite) SetupTest() {
+	s.store = &Data{}
+}
+
+func TestStoreUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(StoreTestSuite))
+}
+
+// Snapshot
+
+func (s *StoreTestSuite) Test_Snapshot_ReturnsEmptyMap_WhenThereAreNoServices() {
+	s.Equal(map[string]Service{}, s.store.Snapshot())
+}
+
+func (s *StoreTestSuite) Test_Snapshot_IsNotModifiedByUpdates() {
+	s.store.Put(Service{ServiceName: "my-service"})
+	snapshot := s.store.Snapshot()
+
+	s.store.Put(Service{ServiceName: "other-service"})
+	s.store.Delete("my-service")
+
+	s.Equal(map[string]Service{"my-service": {ServiceName: "my-service"}}, snapshot)
+	s.Equal(map[string]Service{"other-service": {ServiceName: "other-service"}}, s.store.Snapshot())
+}
+
+// Put
+
+func (s *StoreTestSuite) Test_Put_ReplacesServicesWithTheSameName() {
+	s.store.Put(Service{ServiceName: "my-service", ReqMode: "http"}, Service{ServiceName: "other-service"})
+
+	s.store.Put(Service{ServiceName: "my-service", ReqMode: "tcp"})
+
+	actual := s.store.Snapshot()
+	s.Len(actual, 2)
+	s.Equal("tcp", actual["my-service"].ReqMode)
+}
+
+func (s *StoreTestSuite) Test_Put_DoesNotModifyServiceDestOfTheCaller() {
+	dest := []ServiceDest{{Port: string([]byte("8080"))}}
+	s.store.Put(Service{ServiceName: "my-service", ServiceDest: dest, PathType: "path_beg"})
+	s.store.Put(Service{ServiceName: "other-service", ServiceDest: []ServiceDest{{Port: "8080"}}, PathType: "path_beg"})
+
+	actual := s.store.Snapshot()
+	s.Equal("8080", actual["my-service"].ServiceDest[0].Port)
+	s.Equal("path_beg", actual["other-service"].PathType)
+	dest[0].Port = "9090"
+	s.Equal("8080", s.store.Snapshot()["my-service"].ServiceDest[0].Port)
+}
+
+// copyServices
+
+func (s *StoreTestSuite) Test_copyServices_DoesNotShareSlicesAndMapsWithSnapshot() {
+	s.store.Put(Service{
+		ServiceName:   "my-service",
+		ServiceCerts:  map[string]string{"acme.com": "cert"},
+		ServiceDomain: []string{"acme.com"},
+		ServiceDest:   []ServiceDest{{Port: "8080", ServicePath: []string{"/demo"}}},
+	})
+
+	actual := copyServices(s.store.Snapshot())["my-service"]
+	actual.ServiceCerts["acme.com"] = "other-cert"
+	actual.ServiceDomain[0] = "other.com"
+	actual.ServiceDest[0].ServicePath[0] = "/other"
+	actual.ServiceDest[0].SrcPortAcl = "acl"
+
+	snapshot := s.store.Snapshot()["my-service"]
+	s.Equal("cert", snapshot.ServiceCerts["acme.com"])
+	s.Equal([]string{"acme.com"}, snapshot.ServiceDomain)
+	s.Equal([]ServiceDest{{Port: "8080", ServicePath: []string{"/demo"}}}, snapshot.ServiceDest)
+}
+
+func (s *StoreTestSuite) Test_formatService_DoesNotModifyServiceDestOfSnapshot() {
+	s.store.Put(Service{ServiceName: "my-service", ServiceDest: []ServiceDest{{Port: "8080", SrcPort: 8080}}})
+
+	actual := s.store.Snapshot()["my-service"]
+	formatService(&actual)
+
+	s.NotEmpty(actual.ServiceDest[0].SrcPortAcl)
+	s.Empty(s.store.Snapshot()["my-service"].ServiceDest[0].SrcPortAcl)
+}
+
+// RedactService
+
+func (s *StoreTestSuite) Test_RedactService_RedactsPasswordsAndCertificatesOfCopy() {
+	sr := Service{
+		ServiceName:  "my-service",
+		ServiceCert:  "cert",
+		ServiceCerts: map[string]string{"acme.com": "acme-cert"},
+		Users:        []User{{Username: "user", Password: "pass"}, {Username: "other"}},
+	}
+
+	actual := RedactService(sr)
+
+	s.Equal("*****", actual.ServiceCert)
+	s.Equal(map[string]string{"acme.com": "*****"}, actual.ServiceCerts)
+	s.Equal([]User{{Username: "user", Password: "*****"}, {Username: "other"}}, actual.Users)
+	s.Equal("pass", sr.Users[0].Password)
+	s.Equal("acme-cert", sr.ServiceCerts["acme.com"])
+}
+
+// Delete
+
+func (s *StoreTestSuite) Test_Delete_RemovesServices() {
+	s.store.Put(Service{ServiceName: "my-service"}, Service{ServiceName: "other-service"}, Service{ServiceName: "last-service"})
+
+	s.store.Delete("my-service", "last-service", "unknown-service")
+
+	s.Equal(map[string]Service{"other-service": {ServiceName: "other-service"}}, s.store.Snapshot())
+}
+
+// Replace
+
+func (s *StoreTestSuite) Test_Replace_ReplacesAllServices() {
+	s.store.Put(Service{ServiceName: "my-service"})
+	services := map[string]Service{"other-service": {ServiceName: "other-service"}}
+
+	s.store.Replace(services)
+	services["last-service"] = Service{ServiceName: "last-service"}
+
+	s.Equal(map[string]Service{"other-service": {ServiceName: "other-service"}}, s.store.Snapshot())
+}
+
+// Subscribe
+
+func (s *StoreTestSuite) Test_Subscribe_ReceivesSnapshotAfterEachChange() {
+	c, cancel := s.store.Subscribe()
+	defer cancel()
+
+	s.store.Put(Service{ServiceName: "my-service"})
+
+	s.Equal(map[string]Service{"my-service": {ServiceName: "my-service"}}, <-c)
+}
+
+func (s *StoreTestSuite) Test_Subscribe_ReceivesOnlyTheLatestSnapshot_WhenSubscriberFallsBehind() {
+	c, cancel := s.store.Subscribe()
+	defer cancel()
+
+	s.store.Put(Service{ServiceName: "my-service"})
+	s.store.Put(Service{ServiceName: "other-service"})
+
+	s.Len(<-c, 2)
+	s.Len(c, 0)
+}
+
+func (s *StoreTestSuite) Test_Subscribe_ClosesChannel_WhenSubscriptionEnds() {
+	c, cancel := s.store.Subscribe()
+
+	cancel()
+	cancel()
+	s.store.Put(Service{ServiceName: "my-service"})
+
+	_, ok := <-c
+	s.False(ok)
+}
+
+// Concurrency
+
+func (s *StoreTestSuite) Test_Store_IsSafeForConcurrentUse() {
+	c, cancel := s.store.Subscribe()
+	defer cancel()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			s.store.Put(Service{ServiceName: fmt.Sprintf("my-service-%d", i)})
+		}(i)
+		go func() {
+			defer wg.Done()
+			for range s.store.Snapshot() {
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.Len(s.store.Snapshot(), 10)
+	s.Len(<-c, 10)
+}
@@ -1 +1,118 @@
 package proxy
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+)
+
+// The prefix of the names of the environment variables and secrets the templates can read through env
+const templateEnvPrefix = "TEMPLATE_"
+
+// TemplateFuncs returns the functions available in the proxy templates (e.g. haproxy.tmpl) and in the templates of the services
+// (templateFePath and templateBePath). The service functions look up the services returned by services.
+//
+// service <name> returns the service with the name.
+// port <name> [<index>] returns the port of the destination with the index (0 by default) of the service with the name.
+// add, sub, mul, div, min, and max compute integers from numbers or strings (e.g. {{mul .TimeoutServer 2}}).
+// base64Encode and base64Decode encode and decode strings.
+// env <name> [<default>] returns the environment variable or the Docker secret dfp_<name> or, if neither is set, the default.
+// Only the names prefixed with templateEnvPrefix can be read so that the templates cannot expose the settings of the proxy.
+// quote encloses a value in double quotes and escapes the characters with a special meaning in the configuration.
+func TemplateFuncs(services func() map[string]Service) template.FuncMap {
+	lookup := func(name string) (Service, error) {
+		s, ok := services()[name]
+		if !ok {
+			return Service{}, fmt.Errorf("The service %s is not configured", name)
+		}
+		return s, nil
+	}
+	return template.FuncMap{
+		"service": lookup,
+		"port": func(name string, index ...int) (string, error) {
+			s, err := lookup(name)
+			if err != nil {
+				return "", err
+			}
+			i := 0
+			if len(index) > 0 {
+				i = index[0]
+			}
+			if i < 0 || i >= len(s.ServiceDest) {
+				return "", fmt.Errorf("The service %s does not have the destination %d", name, i)
+			}
+			return s.ServiceDest[i].Port, nil
+		},
+		"add": templateArithmetic(func(a, b int) (int, error) { return a + b, nil }),
+		"sub": templateArithmetic(func(a, b int) (int, error) { return a - b, nil }),
+		"mul": templateArithmetic(func(a, b int) (int, error) { return a * b, nil }),
+		"div": templateArithmetic(func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, fmt.Errorf("%d cannot be divided by zero", a)
+			}
+			return a / b, nil
+		}),
+		"min": templateArithmetic(func(a, b int) (int, error) {
+			if b < a {
+				return b, nil
+			}
+			return a, nil
+		}),
+		"max": templateArithmetic(func(a, b int) (int, error) {
+			if b > a {
+				return b, nil
+			}
+			return a, nil
+		}),
+		// The values are not HTML so they are not escaped
+		"base64Encode": func(value string) template.HTML {
+			return template.HTML(base64.StdEncoding.EncodeToString([]byte(value)))
+		},
+		"base64Decode": func(value string) (template.HTML, error) {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			return template.HTML(decoded), err
+		},
+		"env": func(name string, defaultValue ...string) (template.HTML, error) {
+			if !strings.HasPrefix(name, templateEnvPrefix) {
+				return "", fmt.Errorf("The variable %s cannot be read since its name is not prefixed with %s", name, templateEnvPrefix)
+			}
+			return template.HTML(GetSecretOrEnvVar(name, strings.Join(defaultValue, ""))), nil
+		},
+		"quote": quoteConfigString,
+	}
+}
+
+func templateArithmetic(compute func(a, b int) (int, error)) func(a, b interface{}) (int, error) {
+	return func(a, b interface{}) (int, error) {
+		x, err := toTemplateInt(a)
+		if err != nil {
+			return 0, err
+		}
+		y, err := toTemplateInt(b)
+		if err != nil {
+			return 0, err
+		}
+		return compute(x, y)
+	}
+}
+
+// toTemplateInt converts the numbers and the strings with numbers (e.g. the timeouts of the services) to integers.
+func toTemplateInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return i, nil
+	case template.HTML:
+		return toTemplateInt(string(v))
+	}
+	return 0, fmt.Errorf("%v is not a number", value)
+}
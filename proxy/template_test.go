@@ -0,0 +1,85 @@
+// +build !integration
+
+package proxy
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TemplateTestSuite struct {
+	suite.Suite
+}
+
+func TestTemplateUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(TemplateTestSuite))
+}
+
+func (s *TemplateTestSuite) execute(tmpl string, data interface{}) (string, error) {
+	services := map[string]Service{
+		"api": {ServiceName: "api", ServiceDest: []ServiceDest{{Port: "8080"}, {Port: "9090"}}, TimeoutServer: "20"},
+	}
+	t, err := template.New("template").Funcs(TemplateFuncs(func() map[string]Service { return services })).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var content bytes.Buffer
+	err = t.Execute(&content, data)
+	return content.String(), err
+}
+
+// TemplateFuncs
+
+func (s *TemplateTestSuite) Test_TemplateFuncs_LooksUpServices() {
+	actual, err := s.execute(`server api api:{{port "api"}} {{port "api" 1}}{{range (service "api").ServiceDest}} {{.Port}}{{end}}`, nil)
+
+	s.NoError(err)
+	s.Equal("server api api:8080 9090 8080 9090", actual)
+}
+
+func (s *TemplateTestSuite) Test_TemplateFuncs_ReturnsError_WhenServiceOrDestinationDoesNotExist() {
+	_, err := s.execute(`{{port "unknown"}}`, nil)
+	s.Error(err)
+
+	_, err = s.execute(`{{port "api" 2}}`, nil)
+	s.Error(err)
+}
+
+func (s *TemplateTestSuite) Test_TemplateFuncs_ComputesIntegers() {
+	actual, err := s.execute(`{{add .TimeoutServer 5}} {{sub 10 3}} {{mul (service "api").TimeoutServer 2}} {{div 7 2}} {{min 3 .TimeoutServer}} {{max 3 .TimeoutServer}}`, Service{TimeoutServer: "20"})
+
+	s.NoError(err)
+	s.Equal("25 7 40 3 3 20", actual)
+}
+
+func (s *TemplateTestSuite) Test_TemplateFuncs_ReturnsError_WhenValuesCannotBeComputed() {
+	_, err := s.execute(`{{div 7 0}}`, nil)
+	s.Error(err)
+
+	_, err = s.execute(`{{add .TimeoutServer 5}}`, Service{TimeoutServer: "20s"})
+	s.Error(err)
+}
+
+func (s *TemplateTestSuite) Test_TemplateFuncs_EncodesBase64AndReadsEnvironment() {
+	defer os.Unsetenv("TEMPLATE_MY_VAR")
+	os.Setenv("TEMPLATE_MY_VAR", "a&b")
+
+	actual, err := s.execute(`{{base64Encode "user:pass?"}} {{base64Decode "dXNlcjpwYXNz"}} {{env "TEMPLATE_MY_VAR"}} {{env "TEMPLATE_UNDEFINED_VAR" "default"}} {{quote "x y"}}`, nil)
+
+	s.NoError(err)
+	s.Equal(`dXNlcjpwYXNzPw== user:pass a&b default "x y"`, actual)
+}
+
+func (s *TemplateTestSuite) Test_TemplateFuncs_ReturnsError_WhenEnvIsNotPrefixed() {
+	defer os.Unsetenv("STATS_PASS")
+	os.Setenv("STATS_PASS", "secret")
+
+	actual, err := s.execute(`{{env "STATS_PASS"}}`, nil)
+
+	s.Error(err)
+	s.NotContains(actual, "secret")
+}
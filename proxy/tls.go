@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// The number of TLS ticket keys HAProxy loads from the keys file (TLS_TICKETS_NO).
+// The penultimate key encrypts the tickets while the others only decrypt them.
+const tlsTicketKeysCount = 3
+
+var statFile = os.Stat
+var renameFile = os.Rename
+var runOpenssl = func(args ...string) error {
+	cmd := exec.Command("openssl", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+var tlsTicketKeysMu = &sync.Mutex{}
+
+// GetDhParamsPath returns the path of the DH parameters generated by GenerateDhParams.
+func GetDhParamsPath(configsPath string) string {
+	return fmt.Sprintf("%s/dhparams.pem", configsPath)
+}
+
+// GetTlsTicketKeysPath returns the path of the file with the TLS session ticket keys.
+func GetTlsTicketKeysPath(configsPath string) string {
+	return fmt.Sprintf("%s/tls-ticket-keys", configsPath)
+}
+
+// GenerateDhParams generates DH parameters of the size (in bits) unless they were already generated and returns whether it did.
+// The parameters are generated into a temporary file first so that an interrupted generation is not mistaken for valid parameters.
+func GenerateDhParams(configsPath string, bits int) (bool, error) {
+	path := GetDhParamsPath(configsPath)
+	if _, err := statFile(path); err == nil {
+		return false, nil
+	}
+	tmpPath := path + ".tmp"
+	if err := runOpenssl("dhparam", "-out", tmpPath, fmt.Sprintf("%d", bits)); err != nil {
+		return false, fmt.Errorf("Could not generate DH parameters\n%s", err.Error())
+	}
+	if err := renameFile(tmpPath, path); err != nil {
+		return false, fmt.Errorf("Could not generate DH parameters\n%s", err.Error())
+	}
+	return true, nil
+}
+
+// NewTlsTicketKey returns a random TLS session ticket key encoded as HAProxy expects it.
+func NewTlsTicketKey() (string, error) {
+	key := make([]byte, 48)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("Could not generate a TLS ticket key\n%s", err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// WriteTlsTicketKeys writes the keys (the oldest first) used by the binds after the next reload.
+func WriteTlsTicketKeys(configsPath string, keys []string) error {
+	tlsTicketKeysMu.Lock()
+	defer tlsTicketKeysMu.Unlock()
+	return writeTlsTicketKeys(configsPath, keys)
+}
+
+// RotateTlsTicketKey adds the newest key to the running HAProxy and to the keys file.
+// The key that was the newest starts encrypting the tickets, and the oldest key is dropped.
+func RotateTlsTicketKey(configsPath, key string) error {
+	tlsTicketKeysMu.Lock()
+	defer tlsTicketKeysMu.Unlock()
+	path := GetTlsTicketKeysPath(configsPath)
+	content, err := ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Could not read the TLS ticket keys %s\n%s", path, err.Error())
+	}
+	keys := append(strings.Fields(string(content)), key)
+	if err := writeTlsTicketKeys(configsPath, keys); err != nil {
+		return err
+	}
+	if _, err := sendSocketCommand(fmt.Sprintf("set ssl tls-key %s %s", path, key)); err != nil {
+		return fmt.Errorf("Could not rotate the TLS ticket keys %s\n%s", path, err.Error())
+	}
+	return nil
+}
+
+// writeTlsTicketKeys keeps only the newest keys. The caller must hold tlsTicketKeysMu.
+func writeTlsTicketKeys(configsPath string, keys []string) error {
+	if len(keys) > tlsTicketKeysCount {
+		keys = keys[len(keys)-tlsTicketKeysCount:]
+	}
+	path := GetTlsTicketKeysPath(configsPath)
+	if err := writeFile(path, []byte(strings.Join(keys, "\n")+"\n"), 0600); err != nil {
+		return fmt.Errorf("Could not write the TLS ticket keys %s\n%s", path, err.Error())
+	}
+	return nil
+}
+
+// getTlsOptions returns the global and bind options that use the generated DH parameters and TLS ticket keys, if they exist.
+func getTlsOptions(configsPath string) (global, bind string) {
+	if _, err := statFile(GetDhParamsPath(configsPath)); err == nil {
+		global = fmt.Sprintf("\n    ssl-dh-param-file %s", GetDhParamsPath(configsPath))
+	}
+	if _, err := statFile(GetTlsTicketKeysPath(configsPath)); err == nil {
+		bind = fmt.Sprintf("tls-ticket-keys %s", GetTlsTicketKeysPath(configsPath))
+	}
+	return global, bind
+}
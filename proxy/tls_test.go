@@ -0,0 +1,147 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TlsTestSuite struct {
+	suite.Suite
+	files map[string]string
+}
+
+func (s *TlsTestSuite) SetupTest() {
+	s.files = map[string]string{}
+	statFile = func(name string) (os.FileInfo, error) {
+		if _, ok := s.files[name]; ok {
+			return FileInfoMock{}, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		s.files[filename] = string(data)
+		return nil
+	}
+	ReadFile = func(filename string) ([]byte, error) {
+		if content, ok := s.files[filename]; ok {
+			return []byte(content), nil
+		}
+		return nil, os.ErrNotExist
+	}
+}
+
+func TestTlsUnitTestSuite(t *testing.T) {
+	statFileOrig := statFile
+	writeFileOrig := writeFile
+	readFileOrig := ReadFile
+	runOpensslOrig := runOpenssl
+	renameFileOrig := renameFile
+	sendSocketCommandOrig := sendSocketCommand
+	defer func() {
+		statFile = statFileOrig
+		writeFile = writeFileOrig
+		ReadFile = readFileOrig
+		runOpenssl = runOpensslOrig
+		renameFile = renameFileOrig
+		sendSocketCommand = sendSocketCommandOrig
+	}()
+	suite.Run(t, new(TlsTestSuite))
+}
+
+// GenerateDhParams
+
+func (s *TlsTestSuite) Test_GenerateDhParams_RunsOpensslAndRenamesTheOutput() {
+	actualArgs := []string{}
+	runOpenssl = func(args ...string) error {
+		actualArgs = args
+		return nil
+	}
+	renamed := []string{}
+	renameFile = func(oldpath, newpath string) error {
+		renamed = append(renamed, oldpath, newpath)
+		return nil
+	}
+
+	generated, err := GenerateDhParams("/cfg", 4096)
+
+	s.NoError(err)
+	s.True(generated)
+	s.Equal([]string{"dhparam", "-out", "/cfg/dhparams.pem.tmp", "4096"}, actualArgs)
+	s.Equal([]string{"/cfg/dhparams.pem.tmp", "/cfg/dhparams.pem"}, renamed)
+}
+
+func (s *TlsTestSuite) Test_GenerateDhParams_DoesNothing_WhenParamsExist() {
+	s.files["/cfg/dhparams.pem"] = "params"
+	runOpenssl = func(args ...string) error {
+		s.Fail("openssl should not run")
+		return nil
+	}
+
+	generated, err := GenerateDhParams("/cfg", 4096)
+
+	s.NoError(err)
+	s.False(generated)
+}
+
+func (s *TlsTestSuite) Test_GenerateDhParams_ReturnsError_WhenOpensslFails() {
+	runOpenssl = func(args ...string) error {
+		return fmt.Errorf("This is an error")
+	}
+
+	_, err := GenerateDhParams("/cfg", 4096)
+
+	s.Error(err)
+}
+
+// NewTlsTicketKey
+
+func (s *TlsTestSuite) Test_NewTlsTicketKey_ReturnsBase64EncodedKeysOf48Bytes() {
+	first, err := NewTlsTicketKey()
+	s.NoError(err)
+	second, _ := NewTlsTicketKey()
+
+	s.Len(first, 64)
+	s.NotEqual(first, second)
+}
+
+// RotateTlsTicketKey
+
+func (s *TlsTestSuite) Test_RotateTlsTicketKey_KeepsTheNewestKeysAndSetsTheKeyThroughTheSocket() {
+	command := ""
+	sendSocketCommand = func(c string) (string, error) {
+		command = c
+		return "", nil
+	}
+	WriteTlsTicketKeys("/cfg", []string{"key-1", "key-2", "key-3", "key-4"})
+	s.Equal("key-2\nkey-3\nkey-4\n", s.files["/cfg/tls-ticket-keys"])
+
+	err := RotateTlsTicketKey("/cfg", "key-5")
+
+	s.NoError(err)
+	s.Equal("key-3\nkey-4\nkey-5\n", s.files["/cfg/tls-ticket-keys"])
+	s.Equal("set ssl tls-key /cfg/tls-ticket-keys key-5", command)
+}
+
+func (s *TlsTestSuite) Test_RotateTlsTicketKey_ReturnsError_WhenKeysWereNotWritten() {
+	s.Error(RotateTlsTicketKey("/cfg", "key-1"))
+}
+
+// getTlsOptions
+
+func (s *TlsTestSuite) Test_GetTlsOptions_ReturnsOptionsOfExistingFiles() {
+	global, bind := getTlsOptions("/cfg")
+	s.Empty(global)
+	s.Empty(bind)
+
+	s.files["/cfg/dhparams.pem"] = "params"
+	s.files["/cfg/tls-ticket-keys"] = "keys"
+	global, bind = getTlsOptions("/cfg")
+
+	s.Equal("\n    ssl-dh-param-file /cfg/dhparams.pem", global)
+	s.Equal("tls-ticket-keys /cfg/tls-ticket-keys", bind)
+}
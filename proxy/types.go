@@ -1,12 +1,28 @@
 package proxy
 
 import (
+	"net"
+	"reflect"
 	"strings"
 	"strconv"
 	"math/rand"
+	"unicode"
 )
 
 type ServiceDest struct {
+	// The ALPN protocols (e.g. h2 or http/1.1) offered by the clients of the destination.
+	// Used only in the *sni* mode to route connections with the same SNI to different backends.
+	Alpn []string
+	// The role (writer or reader) of the destination of a database service.
+	// The writer destinations are routed to DbWriters and the reader destinations to DbReaders. Used only in the *tcp* mode.
+	DbRole string
+	// The path to the template of the frontend of the destination. It replaces the frontend generated for the SrcPort.
+	// Used only in the *tcp* mode (e.g. a frontend with the options of a protocol like MQTT).
+	FrontendTemplatePath string
+	// The index of the reconfigure parameters of the destination (e.g. 2 for port.2).
+	// It is zero for the parameters without an index (e.g. port).
+	// It is used only to report the validation errors, so it is neither output nor stored.
+	Index int `json:"-" param:"-"`
 	// The internal port of a service that should be reconfigured.
 	// The port is used only in the *swarm* mode.
 	Port string
@@ -15,35 +31,152 @@ type ServiceDest struct {
 	// The source (entry) port of a service.
 	// Useful only when specifying multiple destinations of a single service.
 	SrcPort        int
-	SrcPortAcl     string
-	SrcPortAclName string
+	SrcPortAcl     string `param:"-"`
+	SrcPortAclName string `param:"-"`
+	// The server timeout in seconds of the destination. If empty, the timeout of the service is used.
+	TimeoutServer string
+	// The tunnel timeout in seconds of the destination. If empty, the timeout of the service is used.
+	TimeoutTunnel string
+}
+
+// GetTimeoutServer returns the server timeout of the destination or, if it does not have one, the timeout of the service.
+func (sd ServiceDest) GetTimeoutServer(s Service) string {
+	if len(sd.TimeoutServer) > 0 {
+		return sd.TimeoutServer
+	}
+	return s.TimeoutServer
 }
 
 type Service struct {
 	// ACLs are ordered alphabetically by their names.
 	// If not specified, serviceName is used instead.
 	AclName string
+	// ACLs of services with higher priority are placed before those with lower priority.
+	// Services with the same priority are ordered by AclName.
+	AclPriority int
+	// The ACL priorities of the ServiceDomain entries keyed by the domain.
+	// The domains with a priority other than AclPriority get ACLs of their own that are ordered by that priority.
+	DomainAclPriority map[string]int `param:"-"`
+	// The prefix added to the path of the request before it is forwarded to the backend.
+	// It is added after the service path is stripped.
+	AddPathPrefix string
+	// The HTTP connection mode used with the backend (e.g. http-keep-alive or http-server-close).
+	// If empty, the CONNECTION_MODE of the proxy is used.
+	ConnectionMode string
+	// Whether to talk to the backend the way HTTP/1.0 servers expect it.
+	// Each request is buffered, sent on its own connection that is never reused, and marked with Connection: close.
+	Http10Compatibility bool
+	// Whether to accept responses that violate the HTTP specification (e.g. invalid characters in headers) from the backend.
+	AcceptInvalidHttpResponse bool
 	// The path to the Consul Template representing a snippet of the backend configuration.
 	// If set, proxy template will be loaded from the specified file.
 	ConsulTemplateFePath string
 	// The path to the Consul Template representing a snippet of the frontend configuration.
 	// If specified, proxy template will be loaded from the specified file.
 	ConsulTemplateBePath string
+	// Whether the service is taken into account by the backends health endpoint.
+	// If none of the services are critical, all of them are taken into account.
+	Critical bool
 	// Whether to distribute a request to all the instances of the proxy.
 	// Used only in the swarm mode.
 	Distribute bool
+	// The maximum number of bytes per second sent to each client of the service.
+	BandwidthLimitPerStream int
+	// The maximum number of bytes per second sent to all the clients of the service combined.
+	BandwidthLimitTotal int
+	// Whether to deny the requests of the clients in the blocklist of the service.
+	// The entries are added through the blocklist endpoint or read from the BlocklistIpsPath and BlocklistUserAgentsPath files.
+	Blocklist bool
+	// The paths to the files with the IP ranges and the User-Agents denied by the blocklist of the service, one per line.
+	// Docker configs and secrets can be referenced through docker-config://<name> and docker-secret://<name>.
+	BlocklistIpsPath        string
+	BlocklistUserAgentsPath string
+	// The names of the cookies captured and added to the logs of the requests of the service.
+	CaptureCookies []string
+	// The names of the request headers captured and added to the logs of the requests of the service.
+	CaptureRequestHeaders []string
+	// Comma separated list of the headers allowed in cross-origin requests. Defaults to the requested headers.
+	// Used only when CorsPreflight is set.
+	CorsAllowHeaders string
+	// Comma separated list of the methods allowed in cross-origin requests. Defaults to the requested method.
+	// Used only when CorsPreflight is set.
+	CorsAllowMethods string
+	// Comma separated list of the origins allowed to send cross-origin requests. Defaults to any origin.
+	// Used only when CorsPreflight is set.
+	CorsAllowOrigins string
+	// The number of seconds clients can cache the preflight response. Defaults to 600.
+	// Used only when CorsPreflight is set.
+	CorsMaxAge int
+	// Whether the proxy should answer CORS preflight requests instead of forwarding them to the service.
+	CorsPreflight bool
+	// The hosts of the replicas of a database service that serve the destinations with the reader role.
+	// The writers are their backup so that the reads continue when all the replicas are down.
+	DbReaders []string
+	// The path to the script that succeeds only for the writer that is the primary (e.g. checking pg_is_in_recovery).
+	// The command must be one of those listed in the EXTERNAL_CHECK_COMMANDS variable.
+	DbWriterCheckCommand string
+	// The hosts of the primary and the standbys of a database service that serve the destinations with the writer role.
+	// Without DbWriterCheckCommand, the first host is the primary and the others are used only when it is down.
+	DbWriters []string
+	// The paths to the files with the HTTP responses HAProxy sends instead of its own errors of the service.
+	// Docker configs and secrets can be referenced through docker-config://<name> and docker-secret://<name>.
+	Errorfile500Path string
+	Errorfile502Path string
+	Errorfile503Path string
+	// The path to the script used to check the health of the backend servers.
+	// The command must be one of those listed in the EXTERNAL_CHECK_COMMANDS variable.
+	ExternalCheckCommand string
+	// Whether idle connections to the backend can be reused by other clients (never, safe, aggressive or always).
+	HttpReuse string
 	// Whether to redirect all http requests to https
 	HttpsOnly bool
 	// The internal HTTPS port of a service that should be reconfigured.
 	// The port is used only in the swarm mode.
 	// If not specified, the `port` parameter will be used instead.
 	HttpsPort int
+	// The percentage of requests of the service that are logged. Zero means that all requests are logged.
+	LogSampleRate int
+	// Whether the requests of the service should not be logged (e.g. health check or metrics endpoints).
+	LoggingDisabled bool `param:"loggingEnabled"`
+	// Whether the service is in maintenance. Requests to services in maintenance are answered with the status 503.
+	Maintenance bool
+	// The maximum number of idle connections kept open toward each backend server.
+	MaxIdleConnections int
+	// The percentage of requests copied to MirrorToService. Defaults to 100.
+	MirrorPercentage int
+	// The address (<host>:<port>) of the shadow service that receives a copy of the requests.
+	// The responses of the shadow service are discarded.
+	// If the port is not specified, the port of the service destination is used.
+	MirrorToService string
+	// The maximum length of the URLs of the requests of the service.
+	// Longer requests are denied with the status 414 before they reach the backend.
+	MaxUrlLength int
+	// Whether the connections of an MQTT client stick to the same server, identified by the client ID of the CONNECT packet,
+	// so that it reconnects to the broker that keeps its session. Used only with the tcpPreset mqtt.
+	MqttStickiness bool
+	// The namespace (tenant) of the service.
+	// The names of the service and its ACLs are prefixed with the namespace and its routes cannot collide with those of other namespaces.
+	Namespace string
+	// Whether to normalize the URIs of the requests of the service domain before the paths are matched.
+	// Percent-encoded unreserved characters are decoded, dot segments are removed, and duplicate slashes are merged.
+	NormalizeUri bool
+	// Whether to treat paths with and without the trailing slash the same.
+	// The trailing slash is removed before the request is forwarded to the backend.
+	NormalizeTrailingSlash bool
 	// The hostname where the service is running, for instance on a separate swarm.
 	// If specified, the proxy will dispatch requests to that domain.
+	// Multiple hostnames (e.g. of the same service in different swarms) are separated with comma.
+	// The first one is used while it is healthy and the others are backups.
+	// Co-located services can be reached through a unix socket (unix://<path>).
 	OutboundHostname string
 	// The ACL derivative. Defaults to path_beg.
 	// See https://cbonte.github.io/haproxy-dconv/configuration-1.5.html#7.3.6-path for more info.
 	PathType string
+	// Whether the service path should be matched regardless of its case.
+	PathMatchCaseInsensitive bool
+	// The domain the requests to the other domains of the service are permanently redirected to.
+	// It must be one of the ServiceDomain entries.
+	CanonicalDomain string
 	// Whether to redirect to https when X-Forwarded-Proto is http
 	RedirectWhenHttpProto bool
 	// The request mode. The proxy should be able to work with any mode supported by HAProxy. However, actively supported and tested modes are *http* and *tcp*. Please open an GitHub issue if the mode you're using does not work as expected. The default value is *http*.
@@ -59,8 +192,18 @@ type Service struct {
 	// A regular expression to search the content to be replaced.
 	// If specified, `reqPathReplace` needs to be set as well.
 	ReqPathSearch string
+	// Whether to rewrite the absolute URLs in HTML and JSON responses from the internal path of the service to its public path.
+	// The internal path is defined through AddPathPrefix and the public one through ServicePath when StripPath is set.
+	// Used only in the swarm mode.
+	RewriteResponseUrls bool
 	// Content of the PEM-encoded certificate to be used by the proxy when serving traffic over SSL.
 	ServiceCert string
+	// PEM-encoded certificates of the service domains, keyed by the domain they are used for.
+	// HAProxy selects the certificate that matches the SNI sent by the client.
+	ServiceCerts map[string]string `param:"-"`
+	// The name of the Docker secret with the PEM-encoded certificate used instead of ServiceCert.
+	// The secret must be attached to the proxy service.
+	CertSecret string
 	// The domain of the service.
 	// If set, the proxy will allow access only to requests coming to that domain.
 	ServiceDomain []string
@@ -69,11 +212,42 @@ type Service struct {
 	// The name of the service.
 	// It must match the name of the Swarm service or the one stored in Consul.
 	ServiceName string
+	// The value of the Host header sent to the backend.
+	// If empty, the Host header of the request is preserved.
+	SetHostHeader string
+	// Whether to remove the matched service path from the request before it is forwarded to the backend.
+	StripPath bool
+	// Whether to send the PROXY protocol header to the backend so that it can see the address of the client.
+	SendProxyProtocol bool
+	// The networks (CIDRs or IPs) the requests must come from, e.g. the subnet of an overlay network.
+	// Requests from other networks are routed to the other services with the same paths and domains or rejected in the tcp mode.
+	SrcNetworks []string
 	// Whether to skip adding proxy checks.
 	// This option is used only in the default mode.
 	SkipCheck bool
+	// The request attribute used to assign requests to the SplitGroups, formatted as cookie:<name> or header:<name>.
+	// Requests without the cookie are distributed among the groups and receive the cookie of the group they were assigned to.
+	// Used only in the swarm mode.
+	SplitBy string
+	// The groups of an A/B test and the services that receive their requests.
+	SplitGroups []SplitGroup
+	// The protocol of a tcp service (imap, mqtt, mysql, redis, or smtp) that configures its health checks and timeouts.
+	TcpPreset string
 	// If set to true, server certificates are not verified. This flag should be set for SSL enabled backend services.
 	SslVerifyNone bool
+	// The path to the CA certificates used to verify the certificates of the https backend (HttpsPort).
+	// Docker configs and secrets can be referenced through docker-config://<name> and docker-secret://<name>.
+	BackendCaFile string
+	// The server name sent through SNI to the https backend (HttpsPort).
+	// Requires BackendCaFile or SslVerifyNone.
+	BackendSni string
+	// The body of the static response served by the proxy itself.
+	StaticResponseBody string
+	// The content type of the static response. Defaults to text/plain.
+	StaticResponseContentType string
+	// The status of the static response served by the proxy instead of forwarding the requests to a backend.
+	// Services with a static response do not need any running containers.
+	StaticResponseStatus int
 	// The path to the template representing a snippet of the backend configuration.
 	// If specified, the backend template will be loaded from the specified file.
 	// If specified, `templateFePath` must be set as well.
@@ -88,16 +262,110 @@ type Service struct {
 	TimeoutServer string
 	// The tunnel timeout in seconds
 	TimeoutTunnel string
+	// Whether to add a server for each task of the service and use the tasks running in other zones only as backups.
+	// Used only in the swarm mode.
+	ZoneAware bool
+	// The number of seconds after which the service is removed unless it is reconfigured again.
+	// Zero means that the service never expires.
+	TtlSeconds int
 	// A comma-separated list of credentials(<user>:<pass>) for HTTP basic auth, which applies only to the service that will be reconfigured.
 	Users               []User
 	ServiceColor        string
-	ServicePort         string
-	AclCondition        string
-	FullServiceName     string
-	Host                string
-	LookupRetry         int
-	LookupRetryInterval int
-	ServiceDest         []ServiceDest
+	ServicePort         string        `param:"-"`
+	AclCondition        string        `param:"-"`
+	FullServiceName     string        `param:"-"`
+	Host                string        `param:"-"`
+	Hosts               []string      `param:"-"`
+	LookupRetry         int           `param:"-"`
+	LookupRetryInterval int           `param:"-"`
+	ServiceDest         []ServiceDest `param:"-"`
+	Tasks               []Task        `param:"-"`
+}
+
+// GetParamName returns the name of the reconfigure parameter of a field of Service or ServiceDest.
+// It is the name of the field starting with a lower case letter unless the param tag of the field specifies it.
+// Fields tagged with param:"-" are not parameters and unexported fields are ignored (an empty string is returned).
+func GetParamName(field reflect.StructField) string {
+	if len(field.PkgPath) > 0 {
+		return ""
+	}
+	if tag := field.Tag.Get("param"); tag == "-" {
+		return ""
+	} else if len(tag) > 0 {
+		return tag
+	}
+	name := []rune(field.Name)
+	name[0] = unicode.ToLower(name[0])
+	return string(name)
+}
+
+// Errorfile is a file with the HTTP response HAProxy sends instead of an error with the status code.
+type Errorfile struct {
+	Code int
+	Path string
+}
+
+// GetErrorfiles returns the error files of the service ordered by their status codes.
+func (s Service) GetErrorfiles() []Errorfile {
+	errorfiles := []Errorfile{}
+	for _, errorfile := range []Errorfile{
+		{500, s.Errorfile500Path},
+		{502, s.Errorfile502Path},
+		{503, s.Errorfile503Path},
+	} {
+		if len(errorfile.Path) > 0 {
+			errorfiles = append(errorfiles, errorfile)
+		}
+	}
+	return errorfiles
+}
+
+// HasDbRoles returns whether some destinations of the service are routed to the writers or the readers of a database.
+func (s Service) HasDbRoles() bool {
+	for _, sd := range s.ServiceDest {
+		if len(sd.DbRole) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStaticResponse returns whether the requests to the service are answered by the proxy without a backend.
+func (s Service) IsStaticResponse() bool {
+	return s.StaticResponseStatus > 0
+}
+
+// GetHosts returns the outbound hostnames of the service or, if they are not set, its name.
+func (s Service) GetHosts() []string {
+	hosts := []string{}
+	for _, host := range strings.Split(s.OutboundHostname, ",") {
+		// IPv6 literals can be enclosed in brackets
+		host = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(host), "["), "]")
+		if len(host) > 0 {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		hosts = append(hosts, s.ServiceName)
+	}
+	return hosts
+}
+
+// IsUnixSocket returns whether the host is the path of a unix socket (unix://<path>).
+func IsUnixSocket(host string) bool {
+	return strings.HasPrefix(host, "unix://")
+}
+
+// GetServerHost returns the host formatted for server lines.
+// IPv6 literals are prefixed with ipv6@ since HAProxy uses the last colon of an address as the port separator.
+func GetServerHost(host string) string {
+	if IsUnixSocket(host) {
+		return "unix@" + strings.TrimPrefix(host, "unix://")
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "ipv6@" + ip.String()
+	}
+	return host
 }
 
 type Services []Service
@@ -107,6 +375,9 @@ func (slice Services) Len() int {
 }
 
 func (slice Services) Less(i, j int) bool {
+	if slice[i].AclPriority != slice[j].AclPriority {
+		return slice[i].AclPriority > slice[j].AclPriority
+	}
 	return slice[i].AclName < slice[j].AclName
 }
 
@@ -114,6 +385,40 @@ func (slice Services) Swap(i, j int) {
 	slice[i], slice[j] = slice[j], slice[i]
 }
 
+type Task struct {
+	// The name of the server of the task.
+	Name string
+	// The address of the task in a network shared with the proxy.
+	Address string
+	// Whether the task runs in a different zone than the proxy.
+	Backup bool
+}
+
+type SplitGroup struct {
+	// The value of the cookie or the header that identifies the group.
+	Name string
+	// The host of the service that receives the requests of the group.
+	Host string
+}
+
+// ExtractSplitGroupsFromString parses a comma-separated list of <group>:<host> pairs.
+func ExtractSplitGroupsFromString(groupsString string) []SplitGroup {
+	groups := []SplitGroup{}
+	for _, group := range strings.Split(groupsString, ",") {
+		group = strings.TrimSpace(group)
+		if len(group) == 0 {
+			continue
+		}
+		nameAndHost := strings.SplitN(group, ":", 2)
+		splitGroup := SplitGroup{Name: strings.TrimSpace(nameAndHost[0])}
+		if len(nameAndHost) > 1 {
+			splitGroup.Host = strings.TrimSpace(nameAndHost[1])
+		}
+		groups = append(groups, splitGroup)
+	}
+	return groups
+}
+
 type User struct {
 	Username      string
 	Password      string
@@ -150,17 +455,17 @@ func ExtractUsersFromString(context, usersString string, encrypted, skipEmptyPas
 			userName := strings.Trim(user[0:colonIndex], "\t ")
 			userPass := strings.Trim(user[colonIndex+1:], "\t ")
 			if len(userName) == 0 || len(userPass) == 0 {
-				logPrintf("For service %s there is an invalid user with no name or invalid format",
+				logWarnf("For service %s there is an invalid user with no name or invalid format",
 					context)
 			} else {
 				collectedUsers = append(collectedUsers, &User{Username: userName, Password: userPass, PassEncrypted: encrypted})
 			}
 		} else {
 			if len(user) == 0 {
-				logPrintf("For service %s there is an invalid user with no name or invalid format",
+				logWarnf("For service %s there is an invalid user with no name or invalid format",
 					context)
 			} else if skipEmptyPassword {
-				logPrintf("For service %s there is an user %s with no password which is not allowed here",
+				logWarnf("For service %s there is an user %s with no password which is not allowed here",
 					context, user)
 			} else if !skipEmptyPassword {
 				collectedUsers = append(collectedUsers, &User{Username: user})
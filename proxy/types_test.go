@@ -1,7 +1,12 @@
 package proxy
 
 import (
+	"encoding/json"
 	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 	"github.com/docker/docker/pkg/testutil/assert"
 )
@@ -12,6 +17,24 @@ type TypesTestSuite struct {
 
 func (s *TypesTestSuite) SetupTest() {
 	logPrintf = func(format string, v ...interface{}) {}
+	logWarnf = func(format string, v ...interface{}) {}
+}
+
+// Services
+
+func (s TypesTestSuite) Test_ServicesLess_OrdersByAclName() {
+	services := Services{{AclName: "b"}, {AclName: "a"}}
+
+	s.True(services.Less(1, 0))
+	s.False(services.Less(0, 1))
+}
+
+func (s TypesTestSuite) Test_ServicesLess_OrdersByAclPriorityBeforeAclName() {
+	services := Services{{AclName: "a"}, {AclName: "b", AclPriority: 10}, {AclName: "c", AclPriority: -1}}
+
+	s.True(services.Less(1, 0))
+	s.True(services.Less(0, 2))
+	s.False(services.Less(2, 1))
 }
 
 // NewRun
@@ -88,8 +111,64 @@ x:X`, false, false)
 	})
 }
 
+func (s TypesTestSuite) Test_ExtractSplitGroupsFromString() {
+	groups := ExtractSplitGroupsFromString("a:my-service, b:my-service-v2,")
+
+	s.Equal([]SplitGroup{{Name: "a", Host: "my-service"}, {Name: "b", Host: "my-service-v2"}}, groups)
+}
+
 // Suite
 
+func (s TypesTestSuite) Test_GetHosts_ReturnsOutboundHostnames() {
+	sr := Service{ServiceName: "my-service", OutboundHostname: "my-service.cluster-1.com, my-service.cluster-2.com"}
+
+	s.Equal([]string{"my-service.cluster-1.com", "my-service.cluster-2.com"}, sr.GetHosts())
+}
+
+func (s TypesTestSuite) Test_GetHosts_ReturnsServiceName_WhenOutboundHostnameIsEmpty() {
+	sr := Service{ServiceName: "my-service"}
+
+	s.Equal([]string{"my-service"}, sr.GetHosts())
+}
+
+func (s TypesTestSuite) Test_GetHosts_RemovesBracketsOfIpv6Literals() {
+	sr := Service{ServiceName: "my-service", OutboundHostname: "[2001:db8::1]"}
+
+	s.Equal([]string{"2001:db8::1"}, sr.GetHosts())
+}
+
+func (s TypesTestSuite) Test_GetServerHost_PrefixesIpv6Literals() {
+	s.Equal("ipv6@2001:db8::1", GetServerHost("2001:db8:0::1"))
+	s.Equal("10.0.0.1", GetServerHost("10.0.0.1"))
+	s.Equal("my-service", GetServerHost("my-service"))
+	s.Equal("unix@/var/run/app.sock", GetServerHost("unix:///var/run/app.sock"))
+}
+
+func (s TypesTestSuite) Test_ServiceDest_DoesNotOutputIndex() {
+	actual, _ := json.Marshal(ServiceDest{Port: "1234", Index: 2})
+
+	s.NotContains(string(actual), "Index")
+}
+
+// api/admin.proto
+
+func (s TypesTestSuite) Test_AdminProto_ContainsParamsOfService() {
+	content, err := ioutil.ReadFile("../api/admin.proto")
+	s.NoError(err)
+	message := regexp.MustCompile(`(?s)message Service \{(.*?)\n\}`).FindStringSubmatch(string(content))
+	s.Len(message, 2)
+	snakeCase := regexp.MustCompile(`([a-z0-9])([A-Z])`)
+	t := reflect.TypeOf(Service{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if len(GetParamName(field)) == 0 {
+			continue
+		}
+		name := strings.ToLower(snakeCase.ReplaceAllString(field.Name, "${1}_${2}"))
+		s.Regexp(regexp.MustCompile(` `+name+` = \d+;`), message[1], "%s is missing in api/admin.proto", name)
+	}
+}
+
 func TestRunUnitTestSuite(t *testing.T) {
 	suite.Run(t, new(TypesTestSuite))
 }
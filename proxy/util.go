@@ -1,8 +1,10 @@
 package proxy
 
 import (
+	"../logging"
+	"bufio"
+	"io"
 	"io/ioutil"
-	"log"
 	"os/exec"
 	"fmt"
 	"strings"
@@ -15,9 +17,35 @@ var cmdRunHa = func(cmd *exec.Cmd) error {
 var readConfigsFile = ioutil.ReadFile
 var readSecretsFile = ioutil.ReadFile
 var writeFile = ioutil.WriteFile
+
+// writeFileFrom writes the content produced by write to the file through a buffer so that large files
+// (e.g. the configuration of thousands of services) do not need to be built in memory.
+// The content is written to <filename>.tmp that replaces the file only when write succeeds,
+// so the file is never left partially written.
+var writeFileFrom = func(filename string, perm os.FileMode, write func(w io.Writer) error) error {
+	tmpFilename := filename + ".tmp"
+	f, err := os.OpenFile(tmpFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFilename)
+		return err
+	}
+	return os.Rename(tmpFilename, filename)
+}
 var ReadFile = ioutil.ReadFile
 var ReadDir = ioutil.ReadDir
-var logPrintf = log.Printf
+var logPrintf = logging.Infof
+var logWarnf = logging.Warnf
 var readPidFile = ioutil.ReadFile
 var readConfigsDir = ioutil.ReadDir
 var GetSecretOrEnvVar = func(key, defaultValue string) string {
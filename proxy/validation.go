@@ -0,0 +1,334 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var reqModes = []string{"http", "tcp", "sni"}
+var httpReuseModes = []string{"never", "safe", "aggressive", "always"}
+var backendSniRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?$`)
+var connectionModes = []string{"http-keep-alive", "http-server-close", "http-tunnel", "httpclose", "forceclose"}
+var pathTypes = []string{"path", "path_beg", "path_dir", "path_dom", "path_end", "path_len", "path_reg", "path_sub"}
+
+// getBackendPort returns the port the backend of the destination is named after, the same way as getBackTemplateProtocol.
+func getBackendPort(s Service, sd ServiceDest) string {
+	if strings.EqualFold(s.ReqMode, "tcp") && s.HasDbRoles() && sd.SrcPort > 0 {
+		return strconv.Itoa(sd.SrcPort)
+	}
+	return sd.Port
+}
+
+// ValidationError describes an invalid service parameter.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidateService returns the errors of the service parameters that would result in an invalid HAProxy configuration.
+// Nil is returned when the service is valid.
+func ValidateService(s Service) []ValidationError {
+	var errs []ValidationError
+	addErr := func(field, format string, v ...interface{}) {
+		errs = append(errs, ValidationError{Field: field, Message: fmt.Sprintf(format, v...)})
+	}
+	if len(s.ReqMode) > 0 && !isOneOf(s.ReqMode, reqModes) {
+		addErr("reqMode", "%s is not one of %s", s.ReqMode, strings.Join(reqModes, ", "))
+	}
+	if len(s.PathType) > 0 && !isOneOf(s.PathType, pathTypes) {
+		addErr("pathType", "%s is not one of %s", s.PathType, strings.Join(pathTypes, ", "))
+	}
+	validateTimeout := func(field, value string) {
+		if len(value) > 0 {
+			if timeout, err := strconv.Atoi(value); err != nil || timeout < 0 {
+				addErr(field, "%s is not a number of seconds", value)
+			}
+		}
+	}
+	// The backends of the destinations are named after their ports (see getBackendPort)
+	backendPorts := map[string]bool{}
+	for _, sd := range s.ServiceDest {
+		// The errors refer to the parameters of the request the destination was created from
+		suffix := ""
+		if sd.Index > 0 {
+			suffix = fmt.Sprintf(".%d", sd.Index)
+		}
+		if len(sd.Port) > 0 {
+			if port, err := strconv.Atoi(sd.Port); err != nil || !isValidPort(port) {
+				addErr("port"+suffix, "%s is not a valid port", sd.Port)
+			}
+			if backendPort := getBackendPort(s, sd); backendPorts[backendPort] {
+				addErr("port"+suffix, "%s is already used by another destination. Use a single destination with multiple servicePath values instead", backendPort)
+			} else {
+				backendPorts[backendPort] = true
+			}
+		}
+		if sd.SrcPort != 0 && !isValidPort(sd.SrcPort) {
+			addErr("srcPort"+suffix, "%d is not a valid port", sd.SrcPort)
+		}
+		validateTimeout("timeoutServer"+suffix, sd.TimeoutServer)
+		validateTimeout("timeoutTunnel"+suffix, sd.TimeoutTunnel)
+		if len(sd.Alpn) > 0 && !strings.EqualFold(s.ReqMode, "sni") {
+			addErr("alpn"+suffix, "alpn can be used only when reqMode is sni")
+		}
+		if len(sd.FrontendTemplatePath) > 0 && !strings.EqualFold(s.ReqMode, "tcp") {
+			addErr("frontendTemplatePath"+suffix, "frontendTemplatePath can be used only when reqMode is tcp")
+		}
+		switch {
+		case len(sd.DbRole) == 0:
+		case sd.DbRole != "writer" && sd.DbRole != "reader":
+			addErr("dbRole"+suffix, "%s is not one of writer, reader", sd.DbRole)
+		case !strings.EqualFold(s.ReqMode, "tcp"):
+			addErr("dbRole"+suffix, "dbRole can be used only when reqMode is tcp")
+		case sd.SrcPort == 0:
+			addErr("dbRole"+suffix, "dbRole requires srcPort")
+		case sd.DbRole == "writer" && len(s.DbWriters) == 0:
+			addErr("dbRole"+suffix, "The writer role requires dbWriters")
+		case sd.DbRole == "reader" && len(s.DbReaders) == 0:
+			addErr("dbRole"+suffix, "The reader role requires dbReaders")
+		}
+		for _, protocol := range sd.Alpn {
+			if len(protocol) == 0 || strings.ContainsAny(protocol, " \t\n\r") {
+				addErr("alpn"+suffix, "%q is not a valid ALPN protocol", protocol)
+			}
+		}
+	}
+	if s.HttpsPort != 0 && !isValidPort(s.HttpsPort) {
+		addErr("httpsPort", "%d is not a valid port", s.HttpsPort)
+	}
+	if len(s.BackendCaFile) > 0 {
+		if s.HttpsPort == 0 {
+			addErr("backendCaFile", "httpsPort is mandatory when backendCaFile is set")
+		} else if s.SslVerifyNone {
+			addErr("backendCaFile", "backendCaFile cannot be combined with sslVerifyNone")
+		} else if strings.ContainsAny(s.BackendCaFile, " \t\n\r") {
+			addErr("backendCaFile", "%q must not contain whitespace", s.BackendCaFile)
+		}
+	}
+	for _, network := range s.SrcNetworks {
+		if _, _, err := net.ParseCIDR(network); err != nil && net.ParseIP(network) == nil {
+			addErr("srcNetworks", "%s is not a valid CIDR or IP", network)
+		}
+	}
+	for _, errorfile := range s.GetErrorfiles() {
+		param := fmt.Sprintf("errorfile%dPath", errorfile.Code)
+		if !strings.EqualFold(s.ReqMode, "http") && len(s.ReqMode) > 0 {
+			addErr(param, "%s can be used only with the reqMode http", param)
+		} else if strings.ContainsAny(errorfile.Path, " \t\n\r") {
+			addErr(param, "%q must not contain whitespace", errorfile.Path)
+		}
+	}
+	if s.MaxUrlLength < 0 {
+		addErr("maxUrlLength", "%d is not a positive number of characters", s.MaxUrlLength)
+	} else if s.MaxUrlLength > 0 && !strings.EqualFold(s.ReqMode, "http") && len(s.ReqMode) > 0 {
+		addErr("maxUrlLength", "maxUrlLength can be used only with the reqMode http")
+	}
+	if s.HasBlocklist() && !strings.EqualFold(s.ReqMode, "http") && len(s.ReqMode) > 0 {
+		addErr("blocklist", "blocklist can be used only with the reqMode http")
+	}
+	if len(s.BackendSni) > 0 {
+		if !backendSniRegexp.MatchString(s.BackendSni) {
+			addErr("backendSni", "%s is not a valid server name", s.BackendSni)
+		} else if s.HttpsPort == 0 {
+			addErr("backendSni", "httpsPort is mandatory when backendSni is set")
+		} else if len(s.BackendCaFile) == 0 && !s.SslVerifyNone {
+			addErr("backendSni", "backendCaFile or sslVerifyNone is mandatory when backendSni is set")
+		}
+	}
+	if hosts := s.GetHosts(); len(hosts) > 0 {
+		for _, host := range hosts {
+			if !IsUnixSocket(host) {
+				continue
+			} else if len(hosts) > 1 {
+				addErr("outboundHostname", "the unix socket %s cannot be combined with other hosts", host)
+			} else if !strings.HasPrefix(strings.TrimPrefix(host, "unix://"), "/") {
+				addErr("outboundHostname", "%s is not an absolute path of a unix socket", host)
+			}
+		}
+	}
+	if len(s.TcpPreset) > 0 {
+		if _, ok := TcpPresets[s.TcpPreset]; !ok {
+			addErr("tcpPreset", "%s is not one of %s", s.TcpPreset, strings.Join(getTcpPresetNames(), ", "))
+		} else if !strings.EqualFold(s.ReqMode, "tcp") {
+			addErr("tcpPreset", "tcpPreset can be used only with the reqMode tcp")
+		}
+	}
+	validateDbHosts := func(field string, hosts []string) {
+		for _, host := range hosts {
+			if len(host) == 0 || strings.ContainsAny(host, " \t\r\n") {
+				addErr(field, "%q is not a valid host", host)
+			}
+		}
+	}
+	validateDbHosts("dbReaders", s.DbReaders)
+	validateDbHosts("dbWriters", s.DbWriters)
+	if len(s.DbWriterCheckCommand) > 0 && len(s.DbWriters) == 0 {
+		addErr("dbWriterCheckCommand", "dbWriterCheckCommand requires dbWriters")
+	}
+	if s.MqttStickiness && s.TcpPreset != "mqtt" {
+		addErr("mqttStickiness", "mqttStickiness can be used only with the tcpPreset mqtt")
+	}
+	if strings.ContainsAny(s.SetHostHeader, " \t\r\n") {
+		addErr("setHostHeader", "%s is not a valid host", s.SetHostHeader)
+	}
+	if len(s.AddPathPrefix) > 0 && (!strings.HasPrefix(s.AddPathPrefix, "/") || strings.ContainsAny(s.AddPathPrefix, " \t\r\n")) {
+		addErr("addPathPrefix", "%s is not a valid path prefix", s.AddPathPrefix)
+	}
+	if s.StripPath {
+		for _, sd := range s.ServiceDest {
+			for _, path := range sd.ServicePath {
+				if strings.ContainsAny(path, "^)\\ \t\r\n") {
+					addErr("servicePath", "%s cannot be stripped since it contains one of the characters ^)\\ or whitespace", path)
+				}
+			}
+		}
+	}
+	if s.RewriteResponseUrls && !s.StripPath && len(s.AddPathPrefix) == 0 {
+		addErr("rewriteResponseUrls", "rewriteResponseUrls requires stripPath or addPathPrefix")
+	}
+	if len(s.CanonicalDomain) > 0 && (strings.HasPrefix(s.CanonicalDomain, "*") || !isOneOf(s.CanonicalDomain, s.ServiceDomain)) {
+		addErr("canonicalDomain", "%s is not one of the service domains", s.CanonicalDomain)
+	}
+	certDomains := []string{}
+	for domain := range s.ServiceCerts {
+		certDomains = append(certDomains, domain)
+	}
+	sort.Strings(certDomains)
+	for _, domain := range certDomains {
+		if !isOneOf(domain, s.ServiceDomain) {
+			addErr("serviceCert."+domain, "%s is not one of the service domains", domain)
+		}
+	}
+	if len(s.CertSecret) > 0 && !IsValidDockerSecretName(s.CertSecret) {
+		addErr("certSecret", "%s is not a valid secret name", s.CertSecret)
+	}
+	if s.BandwidthLimitPerStream < 0 {
+		addErr("bandwidthLimitPerStream", "%d is not a positive number of bytes", s.BandwidthLimitPerStream)
+	}
+	if s.BandwidthLimitTotal < 0 {
+		addErr("bandwidthLimitTotal", "%d is not a positive number of bytes", s.BandwidthLimitTotal)
+	}
+	if len(s.HttpReuse) > 0 && !isOneOf(s.HttpReuse, httpReuseModes) {
+		addErr("httpReuse", "%s is not one of %s", s.HttpReuse, strings.Join(httpReuseModes, ", "))
+	}
+	if len(s.ConnectionMode) > 0 && !isOneOf(s.ConnectionMode, connectionModes) {
+		addErr("connectionMode", "%s is not one of %s", s.ConnectionMode, strings.Join(connectionModes, ", "))
+	}
+	if s.Http10Compatibility && (strings.EqualFold(s.ConnectionMode, "http-keep-alive") || (len(s.HttpReuse) > 0 && !strings.EqualFold(s.HttpReuse, "never"))) {
+		addErr("http10Compatibility", "http10Compatibility cannot be combined with connection keep-alive or reuse")
+	}
+	if s.MaxIdleConnections < 0 {
+		addErr("maxIdleConnections", "%d is not a positive number", s.MaxIdleConnections)
+	}
+	for _, cors := range []struct{ field, value string }{
+		{"corsAllowOrigins", s.CorsAllowOrigins},
+		{"corsAllowMethods", s.CorsAllowMethods},
+		{"corsAllowHeaders", s.CorsAllowHeaders},
+	} {
+		if strings.ContainsAny(cors.value, "\r\n") {
+			addErr(cors.field, "The value cannot contain line breaks")
+		}
+	}
+	if s.CorsMaxAge < 0 {
+		addErr("corsMaxAge", "%d is not a positive number of seconds", s.CorsMaxAge)
+	}
+	if s.LogSampleRate < 0 || s.LogSampleRate > 100 {
+		addErr("logSampleRate", "%d is not a percentage", s.LogSampleRate)
+	}
+	if s.MirrorPercentage < 0 || s.MirrorPercentage > 100 {
+		addErr("mirrorPercentage", "%d is not a percentage", s.MirrorPercentage)
+	}
+	if strings.ContainsAny(s.MirrorToService, " \t\r\n") {
+		addErr("mirrorToService", "%s is not a valid address", s.MirrorToService)
+	}
+	if len(s.SplitBy) > 0 {
+		splitBy := strings.SplitN(s.SplitBy, ":", 2)
+		if len(splitBy) != 2 || !isOneOf(splitBy[0], []string{"cookie", "header"}) || len(splitBy[1]) == 0 {
+			addErr("splitBy", "%s must be formatted as cookie:<name> or header:<name>", s.SplitBy)
+		}
+		if len(s.SplitGroups) == 0 {
+			addErr("splitGroups", "splitGroups is mandatory when splitBy is set")
+		}
+	}
+	// The names are placed in the fetches of the capture rules, so anything else would break the configuration
+	for _, header := range s.CaptureRequestHeaders {
+		if !isHttpToken(header) {
+			addErr("captureRequestHeaders", "%s is not a valid header name", header)
+		}
+	}
+	for _, cookie := range s.CaptureCookies {
+		if !isHttpToken(cookie) {
+			addErr("captureCookies", "%s is not a valid cookie name", cookie)
+		}
+	}
+	for _, group := range s.SplitGroups {
+		if len(group.Name) == 0 || len(group.Host) == 0 || strings.ContainsAny(group.Name+group.Host, " \t\r\n") {
+			addErr("splitGroups", "%s:%s is not a valid group", group.Name, group.Host)
+		}
+	}
+	if s.StaticResponseStatus != 0 && (s.StaticResponseStatus < 200 || s.StaticResponseStatus > 599) {
+		addErr("staticResponseStatus", "%d is not a valid status code", s.StaticResponseStatus)
+	} else if s.IsStaticResponse() && len(s.ReqMode) > 0 && !strings.EqualFold(s.ReqMode, "http") {
+		addErr("staticResponseStatus", "staticResponseStatus can be used only with the reqMode http")
+	}
+	if strings.ContainsAny(s.StaticResponseContentType, "\r\n") {
+		addErr("staticResponseContentType", "%s is not a valid content type", s.StaticResponseContentType)
+	}
+	if s.TtlSeconds < 0 {
+		addErr("ttlSeconds", "%d is not a positive number of seconds", s.TtlSeconds)
+	}
+	validateTimeout("timeoutServer", s.TimeoutServer)
+	validateTimeout("timeoutTunnel", s.TimeoutTunnel)
+	validateRegexp := func(field, value string) {
+		if _, err := regexp.Compile(value); err != nil {
+			addErr(field, "%s is not a valid regular expression", value)
+		}
+	}
+	validateRegexp("reqPathSearch", s.ReqPathSearch)
+	validateRegexp("reqRepSearch", s.ReqRepSearch)
+	requirePair := func(field1, value1, field2, value2 string) {
+		if len(value1) > 0 && len(value2) == 0 {
+			addErr(field2, "%s is mandatory when %s is set", field2, field1)
+		} else if len(value1) == 0 && len(value2) > 0 {
+			addErr(field1, "%s is mandatory when %s is set", field1, field2)
+		}
+	}
+	requirePair("templateFePath", s.TemplateFePath, "templateBePath", s.TemplateBePath)
+	requirePair("consulTemplateFePath", s.ConsulTemplateFePath, "consulTemplateBePath", s.ConsulTemplateBePath)
+	requirePair("reqPathSearch", s.ReqPathSearch, "reqPathReplace", s.ReqPathReplace)
+	return errs
+}
+
+func isOneOf(value string, values []string) bool {
+	for _, v := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHttpToken returns whether the value is a token as defined by RFC 7230, the syntax of the header and cookie names.
+func isHttpToken(value string) bool {
+	if len(value) == 0 {
+		return false
+	}
+	for _, c := range value {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func isValidPort(port int) bool {
+	return port > 0 && port <= 65535
+}
@@ -0,0 +1,345 @@
+// +build !integration
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ValidationTestSuite struct {
+	suite.Suite
+}
+
+func TestValidationUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ValidationTestSuite))
+}
+
+// ValidateService
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsNil_WhenServiceIsValid() {
+	sr := Service{
+		ReqMode:        "HTTP",
+		PathType:       "path_reg",
+		ReqPathSearch:  "^/api/(.*)",
+		ReqPathReplace: "/\\1",
+		TimeoutServer:  "25",
+		HttpsPort:      443,
+		ServiceDest:    []ServiceDest{{Port: "8080", SrcPort: 80}},
+	}
+
+	s.Nil(ValidateService(sr))
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsFieldErrors() {
+	sr := Service{
+		ReqMode:        "udp",
+		PathType:       "path_something",
+		ReqPathSearch:  "^/api/(.*",
+		ReqPathReplace: "/",
+		TimeoutTunnel:  "1h",
+		HttpsPort:      70000,
+		TemplateFePath: "/templates/fe.tmpl",
+		ServiceDest:    []ServiceDest{{Port: "8080"}, {Index: 1, Port: "abc", SrcPort: -1, TimeoutServer: "1m"}},
+	}
+
+	actual := ValidateService(sr)
+
+	fields := []string{}
+	for _, err := range actual {
+		fields = append(fields, err.Field)
+	}
+	s.Equal(
+		[]string{"reqMode", "pathType", "port.1", "srcPort.1", "timeoutServer.1", "httpsPort", "timeoutTunnel", "reqPathSearch", "templateBePath"},
+		fields,
+	)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenDestinationsShareBackend() {
+	http := Service{ServiceDest: []ServiceDest{
+		{Port: "8080", ServicePath: []string{"/api"}},
+		{Index: 1, Port: "8080", ServicePath: []string{"/reports"}, TimeoutServer: "300"},
+	}}
+	tcp := Service{ReqMode: "tcp", ServiceDest: []ServiceDest{{Port: "5432", SrcPort: 5432}, {Index: 1, Port: "5432", SrcPort: 5433}}}
+	db := Service{
+		ReqMode:     "tcp",
+		DbWriters:   []string{"db-0"},
+		ServiceDest: []ServiceDest{{Port: "5432", SrcPort: 5432, DbRole: "writer"}, {Index: 1, Port: "5432", SrcPort: 5433}},
+	}
+
+	s.Equal([]ValidationError{{
+		Field:   "port.1",
+		Message: "8080 is already used by another destination. Use a single destination with multiple servicePath values instead",
+	}}, ValidateService(http))
+	s.Equal("port.1", ValidateService(tcp)[0].Field)
+	s.Nil(ValidateService(db))
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenStaticResponseStatusIsInvalid() {
+	s.Equal(
+		[]ValidationError{{Field: "staticResponseStatus", Message: "100 is not a valid status code"}},
+		ValidateService(Service{StaticResponseStatus: 100}),
+	)
+	s.Equal(
+		[]ValidationError{{Field: "staticResponseStatus", Message: "staticResponseStatus can be used only with the reqMode http"}},
+		ValidateService(Service{ReqMode: "tcp", StaticResponseStatus: 200, ServiceDest: []ServiceDest{{Port: "8080", SrcPort: 80}}}),
+	)
+	s.Nil(ValidateService(Service{StaticResponseStatus: 404}))
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenReqPathReplaceIsMissing() {
+	actual := ValidateService(Service{ReqPathSearch: "/api"})
+
+	s.Equal(
+		[]ValidationError{{Field: "reqPathReplace", Message: "reqPathReplace is mandatory when reqPathSearch is set"}},
+		actual,
+	)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenAddPathPrefixIsNotAbsolute() {
+	actual := ValidateService(Service{AddPathPrefix: "internal"})
+
+	s.Equal(
+		[]ValidationError{{Field: "addPathPrefix", Message: "internal is not a valid path prefix"}},
+		actual,
+	)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenRewriteResponseUrlsIsSetWithoutPathChanges() {
+	actual := ValidateService(Service{RewriteResponseUrls: true})
+
+	s.Equal(
+		[]ValidationError{{Field: "rewriteResponseUrls", Message: "rewriteResponseUrls requires stripPath or addPathPrefix"}},
+		actual,
+	)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenServiceCertsContainUnknownDomain() {
+	sr := Service{
+		ServiceDomain: []string{"acme.com"},
+		ServiceCerts:  map[string]string{"acme.com": "cert-1", "acme.org": "cert-2"},
+	}
+
+	actual := ValidateService(sr)
+
+	s.Equal(
+		[]ValidationError{{Field: "serviceCert.acme.org", Message: "acme.org is not one of the service domains"}},
+		actual,
+	)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenCorsValueContainsLineBreak() {
+	actual := ValidateService(Service{CorsAllowHeaders: "Content-Type\r\nhttp-request deny"})
+
+	s.Equal(
+		[]ValidationError{{Field: "corsAllowHeaders", Message: "The value cannot contain line breaks"}},
+		actual,
+	)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenMirrorPercentageIsOutOfRange() {
+	actual := ValidateService(Service{MirrorToService: "shadow", MirrorPercentage: 150})
+
+	s.Equal(
+		[]ValidationError{{Field: "mirrorPercentage", Message: "150 is not a percentage"}},
+		actual,
+	)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsErrors_WhenSplitByIsInvalid() {
+	actual := ValidateService(Service{SplitBy: "query:group"})
+
+	s.Equal(
+		[]ValidationError{
+			{Field: "splitBy", Message: "query:group must be formatted as cookie:<name> or header:<name>"},
+			{Field: "splitGroups", Message: "splitGroups is mandatory when splitBy is set"},
+		},
+		actual,
+	)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsErrors_WhenConnectionReuseIsInvalid() {
+	actual := ValidateService(Service{HttpReuse: "sometimes", ConnectionMode: "keep-alive", MaxIdleConnections: -1})
+
+	fields := []string{}
+	for _, err := range actual {
+		fields = append(fields, err.Field)
+	}
+	s.Equal([]string{"httpReuse", "connectionMode", "maxIdleConnections"}, fields)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenCanonicalDomainIsNotServiceDomain() {
+	valid := ValidateService(Service{ServiceDomain: []string{"acme.com", "www.acme.com"}, CanonicalDomain: "acme.com"})
+	actual := ValidateService(Service{ServiceDomain: []string{"acme.com"}, CanonicalDomain: "acme.org"})
+
+	s.Empty(valid)
+	s.Len(actual, 1)
+	s.Equal("canonicalDomain", actual[0].Field)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsErrors_WhenCertSecretIsOutsideSecrets() {
+	valid := ValidateService(Service{CertSecret: "my_cert.pem"})
+
+	s.Empty(valid)
+	for _, name := range []string{"../../etc/shadow", "certs/my_cert", `my\cert`, ".."} {
+		actual := ValidateService(Service{CertSecret: name})
+		s.Len(actual, 1, name)
+		s.Equal("certSecret", actual[0].Field)
+	}
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsErrors_WhenBackendSniOrCaFileIsInvalid() {
+	valid := ValidateService(Service{HttpsPort: 4321, BackendCaFile: "/certs/ca.pem", BackendSni: "api.acme.com"})
+	withoutHttpsPort := ValidateService(Service{BackendCaFile: "/certs/ca.pem"})
+	withSslVerifyNone := ValidateService(Service{HttpsPort: 4321, BackendCaFile: "/certs/ca.pem", SslVerifyNone: true})
+	withoutVerification := ValidateService(Service{HttpsPort: 4321, BackendSni: "api.acme.com"})
+	invalidSni := ValidateService(Service{HttpsPort: 4321, SslVerifyNone: true, BackendSni: "api.acme.com) verify none"})
+
+	s.Empty(valid)
+	for _, actual := range [][]ValidationError{withoutHttpsPort, withSslVerifyNone, withoutVerification, invalidSni} {
+		s.Len(actual, 1)
+	}
+	s.Equal("backendCaFile", withSslVerifyNone[0].Field)
+	s.Equal("backendSni", invalidSni[0].Field)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenHttp10CompatibilityIsCombinedWithKeepAlive() {
+	valid := ValidateService(Service{Http10Compatibility: true, ConnectionMode: "httpclose", HttpReuse: "never"})
+	keepAlive := ValidateService(Service{Http10Compatibility: true, ConnectionMode: "http-keep-alive"})
+	reuse := ValidateService(Service{Http10Compatibility: true, HttpReuse: "safe"})
+
+	s.Empty(valid)
+	s.Len(keepAlive, 1)
+	s.Equal("http10Compatibility", keepAlive[0].Field)
+	s.Len(reuse, 1)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenSrcNetworkIsInvalid() {
+	valid := ValidateService(Service{SrcNetworks: []string{"10.0.0.0/24", "10.0.1.5", "fd00::/8"}})
+	invalid := ValidateService(Service{SrcNetworks: []string{"10.0.0.0/24", "overlay"}})
+
+	s.Empty(valid)
+	s.Len(invalid, 1)
+	s.Equal("srcNetworks", invalid[0].Field)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenErrorfilePathIsInvalid() {
+	valid := ValidateService(Service{ReqMode: "http", Errorfile502Path: "/errorfiles/502.http"})
+	tcp := ValidateService(Service{ReqMode: "tcp", Errorfile502Path: "/errorfiles/502.http"})
+	whitespace := ValidateService(Service{Errorfile500Path: "/errorfiles/internal error.http"})
+
+	s.Empty(valid)
+	s.Len(tcp, 1)
+	s.Equal("errorfile502Path", tcp[0].Field)
+	s.Len(whitespace, 1)
+	s.Equal("errorfile500Path", whitespace[0].Field)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenUnixSocketIsCombinedWithOtherHosts() {
+	actual := ValidateService(Service{ServiceName: "my-service", OutboundHostname: "unix:///var/run/app.sock,my-service.acme.com"})
+
+	s.Len(actual, 1)
+	s.Equal("outboundHostname", actual[0].Field)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenUnixSocketPathIsRelative() {
+	actual := ValidateService(Service{ServiceName: "my-service", OutboundHostname: "unix://app.sock"})
+
+	s.Len(actual, 1)
+	s.Equal("outboundHostname", actual[0].Field)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsErrors_WhenTcpPresetIsInvalid() {
+	s.Equal("tcpPreset", ValidateService(Service{ReqMode: "tcp", TcpPreset: "ftp"})[0].Field)
+	s.Equal("tcpPreset", ValidateService(Service{ReqMode: "http", TcpPreset: "smtp"})[0].Field)
+	s.Empty(ValidateService(Service{ReqMode: "tcp", TcpPreset: "smtp"}))
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenDbRoleIsInvalid() {
+	service := Service{ReqMode: "tcp", DbWriters: []string{"pg-0"}, ServiceDest: []ServiceDest{{Port: "5432", SrcPort: 5432, DbRole: "primary"}}}
+	s.Equal("dbRole", ValidateService(service)[0].Field)
+
+	service.ServiceDest[0].DbRole = "reader"
+	s.Equal("dbRole", ValidateService(service)[0].Field)
+
+	service.ServiceDest[0].DbRole = "writer"
+	service.ServiceDest[0].SrcPort = 0
+	s.Equal("dbRole", ValidateService(service)[0].Field)
+
+	service.ServiceDest[0].SrcPort = 5432
+	service.ReqMode = "http"
+	s.Equal("dbRole", ValidateService(service)[0].Field)
+
+	service.ReqMode = "tcp"
+	s.Empty(ValidateService(service))
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenDbHostsAreInvalid() {
+	s.Equal("dbReaders", ValidateService(Service{ReqMode: "tcp", DbReaders: []string{"pg-1 pg-2"}})[0].Field)
+	s.Equal("dbWriters", ValidateService(Service{ReqMode: "tcp", DbWriters: []string{""}})[0].Field)
+	s.Equal("dbWriterCheckCommand", ValidateService(Service{ReqMode: "tcp", DbWriterCheckCommand: "/scripts/is-primary.sh"})[0].Field)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenMqttStickinessIsUsedWithoutMqttPreset() {
+	s.Equal("mqttStickiness", ValidateService(Service{ReqMode: "tcp", TcpPreset: "redis", MqttStickiness: true})[0].Field)
+	s.Empty(ValidateService(Service{ReqMode: "tcp", TcpPreset: "mqtt", MqttStickiness: true}))
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsErrors_WhenMaxUrlLengthIsInvalid() {
+	s.Equal("maxUrlLength", ValidateService(Service{MaxUrlLength: -1})[0].Field)
+	s.Equal("maxUrlLength", ValidateService(Service{ReqMode: "tcp", MaxUrlLength: 2048})[0].Field)
+	s.Empty(ValidateService(Service{MaxUrlLength: 2048}))
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenStrippedPathCannotBeMatched() {
+	sr := Service{StripPath: true, ServiceDest: []ServiceDest{{Port: "1234", ServicePath: []string{"/api", "/v(1)"}}}}
+
+	actual := ValidateService(sr)
+
+	s.Len(actual, 1)
+	s.Equal("servicePath", actual[0].Field)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenBlocklistIsUsedWithTcp() {
+	actual := ValidateService(Service{ReqMode: "tcp", Blocklist: true})
+
+	s.Len(actual, 1)
+	s.Equal("blocklist", actual[0].Field)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsErrors_WhenAlpnIsInvalid() {
+	dest := func(alpn ...string) []ServiceDest {
+		return []ServiceDest{{Port: "1234"}, {Index: 1, Port: "4321", Alpn: alpn}}
+	}
+
+	s.Equal("alpn.1", ValidateService(Service{ReqMode: "tcp", ServiceDest: dest("h2")})[0].Field)
+	s.Equal("alpn.1", ValidateService(Service{ReqMode: "sni", ServiceDest: dest("http/1.1", "h 2")})[0].Field)
+	s.Empty(ValidateService(Service{ReqMode: "sni", ServiceDest: dest("h2", "http/1.1")}))
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenFrontendTemplatePathIsNotUsedWithTcp() {
+	dest := []ServiceDest{{Port: "1234", SrcPort: 1883, FrontendTemplatePath: "/templates/mqtt-fe.tmpl"}}
+
+	s.Equal("frontendTemplatePath", ValidateService(Service{ReqMode: "http", ServiceDest: dest})[0].Field)
+	s.Empty(ValidateService(Service{ReqMode: "tcp", ServiceDest: dest}))
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsError_WhenLogSampleRateIsOutOfRange() {
+	actual := ValidateService(Service{LogSampleRate: 101})
+
+	s.Len(actual, 1)
+	s.Equal("logSampleRate", actual[0].Field)
+}
+
+func (s ValidationTestSuite) Test_ValidateService_ReturnsErrors_WhenCaptureNamesAreNotTokens() {
+	actual := ValidateService(Service{
+		CaptureRequestHeaders: []string{"X-Request-Id", "User Agent"},
+		CaptureCookies:        []string{"session_id", "a)b", ""},
+	})
+
+	s.Len(actual, 3)
+	s.Equal("captureRequestHeaders", actual[0].Field)
+	s.Equal("captureCookies", actual[1].Field)
+	s.Equal("captureCookies", actual[2].Field)
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// HaProxyVersion is the major and minor version of the HAProxy binary.
+type HaProxyVersion struct {
+	Major int
+	Minor int
+}
+
+func (v HaProxyVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// AtLeast returns whether the version is the same as or newer than the required one.
+func (v HaProxyVersion) AtLeast(required HaProxyVersion) bool {
+	return v.Major > required.Major || (v.Major == required.Major && v.Minor >= required.Minor)
+}
+
+type haProxyFeature struct {
+	// The reconfigure parameter that generates the directive.
+	param    string
+	required HaProxyVersion
+	// Returns whether the service requests the feature.
+	isUsed func(s Service) bool
+}
+
+var haProxyFeatures = []haProxyFeature{
+	{"httpReuse", HaProxyVersion{1, 6}, func(s Service) bool { return len(s.HttpReuse) > 0 }},
+	{"bandwidthLimitPerStream", HaProxyVersion{2, 7}, func(s Service) bool { return s.BandwidthLimitPerStream > 0 }},
+	{"bandwidthLimitTotal", HaProxyVersion{2, 7}, func(s Service) bool { return s.BandwidthLimitTotal > 0 }},
+	{"staticResponseStatus", HaProxyVersion{2, 2}, func(s Service) bool { return s.StaticResponseStatus > 0 }},
+	// Older versions deny long URLs with the status 400 instead of 414 so the parameter is never ignored
+	{"maxUrlLength", HaProxyVersion{2, 4}, func(s Service) bool { return false }},
+	{"normalizeUri", HaProxyVersion{2, 6}, func(s Service) bool { return s.NormalizeUri }},
+	{"alpn", HaProxyVersion{1, 8}, func(s Service) bool {
+		for _, sd := range s.ServiceDest {
+			if len(sd.Alpn) > 0 {
+				return true
+			}
+		}
+		return false
+	}},
+}
+
+var haProxyVersionMu = &sync.Mutex{}
+
+// The version detected on start. Nil if it is unknown, in which case all features are considered supported.
+var haProxyVersion *HaProxyVersion
+
+// ParseHaProxyVersion returns the version from the output of the haproxy -v command.
+func ParseHaProxyVersion(out string) (HaProxyVersion, error) {
+	matches := regexp.MustCompile(`version (\d+)\.(\d+)`).FindStringSubmatch(out)
+	if len(matches) != 3 {
+		return HaProxyVersion{}, fmt.Errorf("Could not detect the HAProxy version from the output of haproxy -v\n%s", out)
+	}
+	major, _ := strconv.Atoi(matches[1])
+	minor, _ := strconv.Atoi(matches[2])
+	return HaProxyVersion{Major: major, Minor: minor}, nil
+}
+
+// DetectHaProxyVersion runs haproxy -v and stores the version used to decide which directives are generated.
+func DetectHaProxyVersion() (HaProxyVersion, error) {
+	out, err := getHaProxyVersionOutput()
+	if err != nil {
+		return HaProxyVersion{}, fmt.Errorf("Could not run haproxy -v\n%s", err.Error())
+	}
+	version, err := ParseHaProxyVersion(out)
+	if err != nil {
+		return version, err
+	}
+	SetHaProxyVersion(&version)
+	return version, nil
+}
+
+// SetHaProxyVersion sets the version of HAProxy. Nil means that the version is unknown.
+func SetHaProxyVersion(version *HaProxyVersion) {
+	haProxyVersionMu.Lock()
+	defer haProxyVersionMu.Unlock()
+	haProxyVersion = version
+}
+
+// IsFeatureSupported returns whether the HAProxy version supports the directive generated by the parameter.
+func IsFeatureSupported(param string) bool {
+	haProxyVersionMu.Lock()
+	defer haProxyVersionMu.Unlock()
+	if haProxyVersion == nil {
+		return true
+	}
+	for _, f := range haProxyFeatures {
+		if f.param == param {
+			return haProxyVersion.AtLeast(f.required)
+		}
+	}
+	return true
+}
+
+// GetFeatureWarnings returns a warning for each parameter of the service that is ignored because it needs a newer HAProxy.
+func GetFeatureWarnings(s Service) []string {
+	warnings := []string{}
+	haProxyVersionMu.Lock()
+	defer haProxyVersionMu.Unlock()
+	if haProxyVersion == nil {
+		return warnings
+	}
+	for _, f := range haProxyFeatures {
+		if f.isUsed(s) && !haProxyVersion.AtLeast(f.required) {
+			warnings = append(warnings, fmt.Sprintf(
+				"The parameter %s of the service %s requires HAProxy %s or newer. It is ignored since the proxy runs HAProxy %s.",
+				f.param,
+				s.ServiceName,
+				f.required,
+				haProxyVersion,
+			))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,121 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type VersionTestSuite struct {
+	suite.Suite
+}
+
+func (s *VersionTestSuite) SetupTest() {
+	SetHaProxyVersion(nil)
+}
+
+func TestVersionUnitTestSuite(t *testing.T) {
+	getHaProxyVersionOutputOrig := getHaProxyVersionOutput
+	defer func() { getHaProxyVersionOutput = getHaProxyVersionOutputOrig }()
+	defer SetHaProxyVersion(nil)
+	suite.Run(t, new(VersionTestSuite))
+}
+
+// ParseHaProxyVersion
+
+func (s *VersionTestSuite) Test_ParseHaProxyVersion_ReturnsMajorAndMinorVersion() {
+	actual, err := ParseHaProxyVersion("HA-Proxy version 1.7.9 2017/08/18\nCopyright 2000-2017 Willy Tarreau <willy@haproxy.org>\n")
+
+	s.NoError(err)
+	s.Equal(HaProxyVersion{Major: 1, Minor: 7}, actual)
+}
+
+func (s *VersionTestSuite) Test_ParseHaProxyVersion_ReturnsError_WhenOutputDoesNotContainVersion() {
+	_, err := ParseHaProxyVersion("command not found")
+
+	s.Error(err)
+}
+
+// AtLeast
+
+func (s *VersionTestSuite) Test_AtLeast_ComparesMajorAndMinorVersions() {
+	v := HaProxyVersion{Major: 1, Minor: 7}
+
+	s.True(v.AtLeast(HaProxyVersion{Major: 1, Minor: 6}))
+	s.True(v.AtLeast(HaProxyVersion{Major: 1, Minor: 7}))
+	s.False(v.AtLeast(HaProxyVersion{Major: 1, Minor: 8}))
+	s.False(v.AtLeast(HaProxyVersion{Major: 2, Minor: 0}))
+	s.True(HaProxyVersion{Major: 2, Minor: 0}.AtLeast(v))
+}
+
+// DetectHaProxyVersion
+
+func (s *VersionTestSuite) Test_DetectHaProxyVersion_StoresVersion() {
+	getHaProxyVersionOutput = func() (string, error) {
+		return "HA-Proxy version 1.7.9 2017/08/18\n", nil
+	}
+
+	actual, err := DetectHaProxyVersion()
+
+	s.NoError(err)
+	s.Equal(HaProxyVersion{Major: 1, Minor: 7}, actual)
+	s.False(IsFeatureSupported("bandwidthLimitTotal"))
+}
+
+func (s *VersionTestSuite) Test_DetectHaProxyVersion_ReturnsError_WhenHaProxyCannotBeRun() {
+	getHaProxyVersionOutput = func() (string, error) {
+		return "", fmt.Errorf("executable file not found in $PATH")
+	}
+
+	_, err := DetectHaProxyVersion()
+
+	s.Error(err)
+	s.True(IsFeatureSupported("bandwidthLimitTotal"))
+}
+
+// IsFeatureSupported
+
+func (s *VersionTestSuite) Test_IsFeatureSupported_ReturnsTrue_WhenVersionIsUnknown() {
+	s.True(IsFeatureSupported("bandwidthLimitPerStream"))
+}
+
+func (s *VersionTestSuite) Test_IsFeatureSupported_ComparesVersions() {
+	SetHaProxyVersion(&HaProxyVersion{Major: 2, Minor: 7})
+
+	s.True(IsFeatureSupported("httpReuse"))
+	s.True(IsFeatureSupported("bandwidthLimitPerStream"))
+
+	SetHaProxyVersion(&HaProxyVersion{Major: 1, Minor: 5})
+
+	s.False(IsFeatureSupported("httpReuse"))
+	s.False(IsFeatureSupported("bandwidthLimitPerStream"))
+}
+
+func (s *VersionTestSuite) Test_IsFeatureSupported_ReturnsTrue_WhenParameterIsNotGated() {
+	SetHaProxyVersion(&HaProxyVersion{Major: 1, Minor: 5})
+
+	s.True(IsFeatureSupported("serviceDomain"))
+}
+
+// GetFeatureWarnings
+
+func (s *VersionTestSuite) Test_GetFeatureWarnings_ReturnsWarningForEachUnsupportedParameter() {
+	SetHaProxyVersion(&HaProxyVersion{Major: 1, Minor: 7})
+	service := Service{ServiceName: "my-service", HttpReuse: "safe", BandwidthLimitPerStream: 1, BandwidthLimitTotal: 2}
+
+	actual := GetFeatureWarnings(service)
+
+	s.Equal([]string{
+		"The parameter bandwidthLimitPerStream of the service my-service requires HAProxy 2.7 or newer. It is ignored since the proxy runs HAProxy 1.7.",
+		"The parameter bandwidthLimitTotal of the service my-service requires HAProxy 2.7 or newer. It is ignored since the proxy runs HAProxy 1.7.",
+	}, actual)
+}
+
+func (s *VersionTestSuite) Test_GetFeatureWarnings_ReturnsEmptySlice_WhenVersionIsUnknown() {
+	service := Service{ServiceName: "my-service", BandwidthLimitTotal: 2}
+
+	s.Empty(GetFeatureWarnings(service))
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// ServiceVersions tracks the version and the content hash of each configured service.
+// It is used to short-circuit repeated identical reconfigure requests and to reject updates based on stale versions.
+type ServiceVersions struct {
+	mu       *sync.Mutex
+	versions map[string]ServiceVersion
+}
+
+type ServiceVersion struct {
+	// The number of times the service was reconfigured. Zero if the service is not configured.
+	Version int
+	// The hash of the service parameters used in the last reconfiguration.
+	Hash string
+}
+
+// ETag returns the entity tag that corresponds to the version.
+func (m ServiceVersion) ETag() string {
+	return fmt.Sprintf(`"%d"`, m.Version)
+}
+
+func NewServiceVersions() *ServiceVersions {
+	return &ServiceVersions{
+		mu:       &sync.Mutex{},
+		versions: map[string]ServiceVersion{},
+	}
+}
+
+func (m *ServiceVersions) Get(serviceName string) ServiceVersion {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.versions[serviceName]
+}
+
+// Put increments the version of the service and stores the hash of its parameters.
+func (m *ServiceVersions) Put(serviceName, hash string) ServiceVersion {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	version := ServiceVersion{
+		Version: m.versions[serviceName].Version + 1,
+		Hash:    hash,
+	}
+	m.versions[serviceName] = version
+	return version
+}
+
+// ClearHash removes the hash of the version of the service so that a request with the same parameters is not short-circuited.
+// It is used when the proxy could not be reloaded with the version. Newer versions are left unchanged.
+func (m *ServiceVersions) ClearHash(serviceName string, version ServiceVersion) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.versions[serviceName] == version {
+		m.versions[serviceName] = ServiceVersion{Version: version.Version}
+	}
+}
+
+func (m *ServiceVersions) Delete(serviceName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.versions, serviceName)
+}
+
+// GetServiceHash returns the hash of the service parameters.
+// Services with the same parameters produce the same hash.
+func GetServiceHash(s Service) string {
+	js, _ := json.Marshal(s)
+	sum := sha1.Sum(js)
+	return hex.EncodeToString(sum[:])
+}
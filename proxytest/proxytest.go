@@ -0,0 +1,165 @@
+// Package proxytest renders proxy configurations for service definitions and compares them with golden files.
+// It lets users of custom templates catch the changes of the generated configuration when they upgrade the proxy.
+package proxytest
+
+import (
+	"../proxy"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TestingT is the subset of testing.T used by the helpers.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// Case is a named set of services the configuration is rendered for.
+type Case struct {
+	Name     string
+	Services []proxy.Service
+}
+
+// Render returns the HAProxy configuration generated from the haproxy.tmpl file in templatesPath and the services.
+// The services are validated and formatted the same way as through reconfigure requests. The proxy is not reloaded.
+// The configuration depends on the environment variables of the process (e.g. DEFAULT_PORTS) the same way as in the proxy.
+func Render(templatesPath string, services ...proxy.Service) (string, error) {
+	configsPath, err := ioutil.TempDir("", "proxytest")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(configsPath)
+	controller, err := proxy.NewController(proxy.ControllerOptions{
+		TemplatesPath: templatesPath,
+		ConfigsPath:   configsPath,
+		DryRun:        true,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(services) > 0 {
+		if err := controller.Apply(context.Background(), services...); err != nil {
+			return "", err
+		}
+	}
+	return controller.Render()
+}
+
+// AssertGolden compares the configuration with the content of the golden file.
+// When the UPDATE_GOLDEN environment variable is set to true, the golden file is written instead.
+func AssertGolden(t TestingT, goldenPath, actual string) bool {
+	if strings.EqualFold(os.Getenv("UPDATE_GOLDEN"), "true") {
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0755); err != nil {
+			t.Errorf("Could not create the directory of the golden file %s\n%s", goldenPath, err.Error())
+			return false
+		}
+		if err := ioutil.WriteFile(goldenPath, []byte(actual), 0644); err != nil {
+			t.Errorf("Could not write the golden file %s\n%s", goldenPath, err.Error())
+			return false
+		}
+		return true
+	}
+	expected, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		t.Errorf("Could not read the golden file %s. Run the tests with UPDATE_GOLDEN=true to create it.\n%s", goldenPath, err.Error())
+		return false
+	}
+	if diff := getDiff(string(expected), actual); len(diff) > 0 {
+		t.Errorf("The configuration does not match the golden file %s\n%s", goldenPath, diff)
+		return false
+	}
+	return true
+}
+
+// getDiff returns the lines that differ between the expected and the actual configuration.
+func getDiff(expected, actual string) string {
+	if expected == actual {
+		return ""
+	}
+	expectedLines := strings.Split(expected, "\n")
+	actualLines := strings.Split(actual, "\n")
+	diff := []string{}
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		e, a := "", ""
+		if i < len(expectedLines) {
+			e = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			a = actualLines[i]
+		}
+		if e != a {
+			diff = append(diff, fmt.Sprintf("line %d\n-%s\n+%s", i+1, e, a))
+		}
+	}
+	return strings.Join(diff, "\n")
+}
+
+// RunConformance renders the configuration of each of the cases (the conformance cases when none are specified)
+// with the templates in templatesPath and compares them with the golden files <case name>.cfg in goldenDir.
+func RunConformance(t TestingT, templatesPath, goldenDir string, cases ...Case) bool {
+	if len(cases) == 0 {
+		cases = ConformanceCases()
+	}
+	ok := true
+	for _, c := range cases {
+		actual, err := Render(templatesPath, c.Services...)
+		if err != nil {
+			t.Errorf("Could not render the case %s\n%s", c.Name, err.Error())
+			ok = false
+			continue
+		}
+		if !AssertGolden(t, filepath.Join(goldenDir, c.Name+".cfg"), actual) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// ConformanceCases returns the cases that cover the features most custom templates depend on, sorted by name.
+func ConformanceCases() []Case {
+	return []Case{
+		{"auth", []proxy.Service{{
+			ServiceName: "go-demo",
+			Users:       []proxy.User{{Username: "admin", Password: "secret"}},
+			ServiceDest: []proxy.ServiceDest{{Port: "8080", ServicePath: []string{"/demo"}}},
+		}}},
+		{"domain", []proxy.Service{{
+			ServiceName:   "go-demo",
+			ServiceDomain: []string{"acme.com", "www.acme.com"},
+			ServiceDest:   []proxy.ServiceDest{{Port: "8080", ServicePath: []string{"/"}}},
+		}}},
+		{"host-header", []proxy.Service{{
+			ServiceName:   "go-demo",
+			SetHostHeader: "internal.acme.com",
+			ServiceDest:   []proxy.ServiceDest{{Port: "8080", ServicePath: []string{"/demo"}}},
+		}}},
+		{"http", []proxy.Service{{
+			ServiceName: "go-demo",
+			ServiceDest: []proxy.ServiceDest{{Port: "8080", ServicePath: []string{"/demo"}}},
+		}}},
+		{"https-only", []proxy.Service{{
+			ServiceName: "go-demo",
+			HttpsOnly:   true,
+			ServiceDest: []proxy.ServiceDest{{Port: "8080", ServicePath: []string{"/demo"}}},
+		}}},
+		{"multiple-destinations", []proxy.Service{{
+			ServiceName: "go-demo",
+			ServiceDest: []proxy.ServiceDest{
+				{Port: "8080", ServicePath: []string{"/demo"}},
+				{Port: "8081", ServicePath: []string{"/admin"}},
+			},
+		}}},
+		{"multiple-services", []proxy.Service{
+			{ServiceName: "go-demo", ServiceDest: []proxy.ServiceDest{{Port: "8080", ServicePath: []string{"/demo"}}}},
+			{ServiceName: "go-demo-api", ServiceDest: []proxy.ServiceDest{{Port: "8080", ServicePath: []string{"/api"}}}},
+		}},
+		{"tcp", []proxy.Service{{
+			ServiceName: "redis",
+			ReqMode:     "tcp",
+			ServiceDest: []proxy.ServiceDest{{Port: "6379", SrcPort: 6379}},
+		}}},
+	}
+}
@@ -0,0 +1,90 @@
+// +build !integration
+
+package proxytest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ProxyTestTestSuite struct {
+	suite.Suite
+	defaultPortsOrig string
+}
+
+func (s *ProxyTestTestSuite) SetupSuite() {
+	s.defaultPortsOrig = os.Getenv("DEFAULT_PORTS")
+	os.Setenv("DEFAULT_PORTS", "80")
+}
+
+func (s *ProxyTestTestSuite) TearDownSuite() {
+	os.Setenv("DEFAULT_PORTS", s.defaultPortsOrig)
+}
+
+func TestProxyTestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ProxyTestTestSuite))
+}
+
+type testingTMock struct {
+	errors []string
+}
+
+func (m *testingTMock) Errorf(format string, args ...interface{}) {
+	m.errors = append(m.errors, format)
+}
+
+// RunConformance
+
+func (s ProxyTestTestSuite) Test_RunConformance_MatchesGoldenFilesOfTheDefaultTemplate() {
+	s.True(RunConformance(s.T(), "..", "testdata"))
+}
+
+// AssertGolden
+
+func (s ProxyTestTestSuite) Test_AssertGolden_ReturnsFalse_WhenConfigurationDiffers() {
+	dir, _ := ioutil.TempDir("", "proxytest")
+	defer os.RemoveAll(dir)
+	goldenPath := filepath.Join(dir, "golden.cfg")
+	ioutil.WriteFile(goldenPath, []byte("line 1\nline 2"), 0644)
+	t := &testingTMock{}
+
+	s.False(AssertGolden(t, goldenPath, "line 1\nline 3"))
+	s.Len(t.errors, 1)
+}
+
+func (s ProxyTestTestSuite) Test_AssertGolden_ReturnsFalse_WhenGoldenFileDoesNotExist() {
+	t := &testingTMock{}
+
+	s.False(AssertGolden(t, "/this/file/does/not/exist.cfg", "line 1"))
+	s.Len(t.errors, 1)
+}
+
+func (s ProxyTestTestSuite) Test_AssertGolden_WritesGoldenFile_WhenUpdateGoldenIsTrue() {
+	dir, _ := ioutil.TempDir("", "proxytest")
+	defer os.RemoveAll(dir)
+	goldenPath := filepath.Join(dir, "nested", "golden.cfg")
+	updateOrig := os.Getenv("UPDATE_GOLDEN")
+	defer func() { os.Setenv("UPDATE_GOLDEN", updateOrig) }()
+	os.Setenv("UPDATE_GOLDEN", "true")
+	t := &testingTMock{}
+
+	s.True(AssertGolden(t, goldenPath, "line 1"))
+	actual, _ := ioutil.ReadFile(goldenPath)
+	s.Equal("line 1", string(actual))
+}
+
+// getDiff
+
+func (s ProxyTestTestSuite) Test_getDiff_ReturnsChangedLines() {
+	actual := getDiff("a\nb\nc", "a\nx\nc\nd")
+
+	s.Equal("line 2\n-b\n+x\nline 4\n-\n+d", actual)
+}
+
+func (s ProxyTestTestSuite) Test_getDiff_ReturnsEmptyString_WhenConfigurationsAreEqual() {
+	s.Empty(getDiff("a\nb", "a\nb"))
+}
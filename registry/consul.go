@@ -1,12 +1,15 @@
 package registry
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Consul struct{}
@@ -18,6 +21,15 @@ var cmdRunConsulTemplate = func(cmd *exec.Cmd) error {
 }
 var WriteConsulTemplateFile = ioutil.WriteFile
 
+// getClient returns the client of the requests sent to Consul limited to REGISTRY_TIMEOUT seconds (10 by default).
+func getClient() *http.Client {
+	seconds, err := strconv.Atoi(os.Getenv("REGISTRY_TIMEOUT"))
+	if err != nil || seconds <= 0 {
+		seconds = 10
+	}
+	return &http.Client{Timeout: time.Duration(seconds) * time.Second}
+}
+
 type CreateConfigsArgs struct {
 	Addresses     []string
 	TemplatesPath string
@@ -28,7 +40,8 @@ type CreateConfigsArgs struct {
 	ServiceName   string
 }
 
-func (m Consul) PutService(addresses []string, instanceName string, r Registry) error {
+// PutService stores the parameters of the service in Consul. The requests are canceled when the context is done.
+func (m Consul) PutService(ctx context.Context, addresses []string, instanceName string, r Registry) error {
 	consulChannel := make(chan error)
 	type data struct{ key, value string }
 	d := []data{
@@ -43,9 +56,13 @@ func (m Consul) PutService(addresses []string, instanceName string, r Registry)
 		data{PORT, r.Port},
 	}
 	for _, e := range d {
-		go m.SendPutRequest(addresses, r.ServiceName, e.key, e.value, instanceName, consulChannel)
+		go func(key, value string) {
+			consulChannel <- m.sendRequest(ctx, "PUT", addresses, r.ServiceName, key, value, instanceName)
+		}(e.key, e.value)
 	}
-	go m.SendPutRequest(addresses, "service", r.ServiceName, "swarm", instanceName, consulChannel)
+	go func() {
+		consulChannel <- m.sendRequest(ctx, "PUT", addresses, "service", r.ServiceName, "swarm", instanceName)
+	}()
 	for i := 0; i < len(d)+1; i++ {
 		err := <-consulChannel
 		if err != nil {
@@ -56,7 +73,7 @@ func (m Consul) PutService(addresses []string, instanceName string, r Registry)
 }
 
 func (m Consul) SendPutRequest(addresses []string, serviceName, key, value, instanceName string, c chan error) {
-	c <- m.sendRequest("PUT", addresses, serviceName, key, value, instanceName)
+	c <- m.sendRequest(context.Background(), "PUT", addresses, serviceName, key, value, instanceName)
 }
 
 func (m Consul) DeleteService(addresses []string, serviceName, instanceName string) error {
@@ -66,9 +83,8 @@ func (m Consul) DeleteService(addresses []string, serviceName, instanceName stri
 			address = fmt.Sprintf("http://%s", address)
 		}
 		url := fmt.Sprintf("%s/v1/kv/%s/%s?recurse", address, instanceName, serviceName)
-		client := &http.Client{}
 		request, _ := http.NewRequest("DELETE", url, nil)
-		_, err = client.Do(request)
+		_, err = getClient().Do(request)
 		if err == nil {
 			return nil
 		}
@@ -76,8 +92,10 @@ func (m Consul) DeleteService(addresses []string, serviceName, instanceName stri
 	return err
 }
 
-func (m Consul) CreateConfigs(args *CreateConfigsArgs) error {
+// CreateConfigs renders the templates of the service. Consul is no longer queried once the context is done.
+func (m Consul) CreateConfigs(ctx context.Context, args *CreateConfigsArgs) error {
 	if err := m.createConfig(
+		ctx,
 		args.Addresses,
 		args.TemplatesPath,
 		args.FeFile,
@@ -88,6 +106,7 @@ func (m Consul) CreateConfigs(args *CreateConfigsArgs) error {
 		return err
 	}
 	if err := m.createConfig(
+		ctx,
 		args.Addresses,
 		args.TemplatesPath,
 		args.BeFile,
@@ -104,7 +123,7 @@ func (m Consul) GetServiceAttribute(addresses []string, serviceName, key, instan
 	var err error
 	for _, address := range addresses {
 		url := fmt.Sprintf("%s/v1/kv/%s/%s/%s?raw", address, instanceName, serviceName, key)
-		resp, err := http.Get(url)
+		resp, err := getClient().Get(url)
 		if err == nil && resp.StatusCode == http.StatusOK {
 			defer resp.Body.Close()
 			body, _ := ioutil.ReadAll(resp.Body)
@@ -114,14 +133,67 @@ func (m Consul) GetServiceAttribute(addresses []string, serviceName, key, instan
 	return "", fmt.Errorf("Could not retrieve the attribute %s\n%s", key, err)
 }
 
-func (m Consul) createConfig(addresses []string, templatesPath, file, template, serviceName, confType string) error {
+// PutServiceAttributeIfAbsent stores the value unless the key already exists and returns the value stored in Consul.
+// Instances that put different values at the same time all get the value of the one that was stored first.
+func (m Consul) PutServiceAttributeIfAbsent(addresses []string, serviceName, key, value, instanceName string) (string, error) {
+	var err error
+	for _, address := range addresses {
+		if !strings.HasPrefix(address, "http") {
+			address = fmt.Sprintf("http://%s", address)
+		}
+		url := fmt.Sprintf("%s/v1/kv/%s/%s/%s?cas=0", address, instanceName, serviceName, key)
+		request, _ := http.NewRequest("PUT", url, strings.NewReader(value))
+		var resp *http.Response
+		if resp, err = getClient().Do(request); err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("Consul responded with the status code %d", resp.StatusCode)
+			continue
+		}
+		return m.GetServiceAttribute([]string{address}, serviceName, key, instanceName)
+	}
+	return "", fmt.Errorf("Could not put the attribute %s\n%s", key, err)
+}
+
+// DeleteServiceAttribute removes the key of the service from Consul. Keys that do not exist are ignored.
+func (m Consul) DeleteServiceAttribute(addresses []string, serviceName, key, instanceName string) error {
+	var err error
+	for _, address := range addresses {
+		if !strings.HasPrefix(address, "http") {
+			address = fmt.Sprintf("http://%s", address)
+		}
+		url := fmt.Sprintf("%s/v1/kv/%s/%s/%s", address, instanceName, serviceName, key)
+		request, _ := http.NewRequest("DELETE", url, nil)
+		var resp *http.Response
+		if resp, err = getClient().Do(request); err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("Consul responded with the status code %d", resp.StatusCode)
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("Could not delete the attribute %s\n%s", key, err)
+}
+
+func (m Consul) createConfig(ctx context.Context, addresses []string, templatesPath, file, template, serviceName, confType string) error {
 	if len(template) > 0 {
 		src := fmt.Sprintf("%s/%s", templatesPath, file)
 		WriteConsulTemplateFile(src, []byte(template), 0664)
 		dest := fmt.Sprintf("%s/%s-%s", templatesPath, serviceName, confType)
 		var err error
+		if IsNativeRenderer() {
+			if err = renderConsulConfig(ctx, addresses, src, dest, template); err == nil {
+				return nil
+			}
+			return fmt.Errorf("Could not create Consul configuration %s from the template %s\n%s", dest, src, err.Error())
+		}
 		for _, address := range addresses {
-			if err = m.runConsulTemplateCmd(src, dest, address); err == nil {
+			if err = m.runConsulTemplateCmd(ctx, src, dest, address); err == nil {
 				return nil
 			}
 		}
@@ -130,31 +202,31 @@ func (m Consul) createConfig(addresses []string, templatesPath, file, template,
 	return nil
 }
 
-func (m Consul) sendRequest(requestType string, addresses []string, serviceName, key, value, instanceName string) error {
+func (m Consul) sendRequest(ctx context.Context, requestType string, addresses []string, serviceName, key, value, instanceName string) error {
 	var err error
 	for _, address := range addresses {
 		if !strings.HasPrefix(address, "http") {
 			address = fmt.Sprintf("http://%s", address)
 		}
 		url := fmt.Sprintf("%s/v1/kv/%s/%s/%s", address, instanceName, serviceName, key)
-		client := &http.Client{}
 		request, _ := http.NewRequest(requestType, url, strings.NewReader(value))
-		_, err = client.Do(request)
-		if err == nil {
+		var resp *http.Response
+		if resp, err = getClient().Do(request.WithContext(ctx)); err == nil {
+			resp.Body.Close()
 			return nil
 		}
 	}
 	return err
 }
 
-func (m Consul) runConsulTemplateCmd(src, dest, address string) error {
+func (m Consul) runConsulTemplateCmd(ctx context.Context, src, dest, address string) error {
 	template := fmt.Sprintf(`%s:%s.cfg`, src, dest)
 	cmdArgs := []string{
 		"-consul", m.getConsulAddress(address),
 		"-template", template,
 		"-once",
 	}
-	cmd := exec.Command("consul-template", cmdArgs...)
+	cmd := exec.CommandContext(ctx, "consul-template", cmdArgs...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmdRunConsulTemplate(cmd); err != nil {
@@ -0,0 +1,328 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// The time the watcher waits after a change in Consul before rendering the templates so that changes made together cause a single reload.
+var consulWatchDebounce = time.Second
+
+// The time the watcher waits before retrying after Consul could not be reached.
+var consulWatchRetry = 5 * time.Second
+
+// The client of the blocking queries. It must not time out before Consul responds to a query that waited for five minutes.
+var consulWatchClient = &http.Client{Timeout: 6 * time.Minute}
+
+var statConsulConfig = os.Stat
+
+type renderedTemplate struct {
+	addresses []string
+	src       string
+	template  string
+	content   string
+}
+
+var renderedTemplatesMu = &sync.Mutex{}
+
+// The templates rendered natively keyed by the paths of their configurations
+var renderedTemplates = map[string]renderedTemplate{}
+
+// ConsulServiceEntry is an instance of a service returned by the service function of a Consul template.
+type ConsulServiceEntry struct {
+	// The name of the node the instance is running on.
+	Node string
+	// The address of the instance or, if it is not set, the address of the node.
+	Address string
+	// The ID of the instance.
+	ID string
+	// The name of the service.
+	Name string
+	// The port of the instance.
+	Port int
+	// The tags of the instance.
+	Tags []string
+	// The aggregated status of the health checks of the instance (passing, warning, or critical).
+	Status string
+}
+
+// IsNativeRenderer returns whether Consul templates are rendered by the proxy (CONSUL_TEMPLATE_RENDERER=native) instead of the consul-template command.
+func IsNativeRenderer() bool {
+	return strings.EqualFold(os.Getenv("CONSUL_TEMPLATE_RENDERER"), "native")
+}
+
+// renderConsulConfig renders the template into dest.cfg and keeps it so that the watcher can render it again when Consul changes.
+func renderConsulConfig(ctx context.Context, addresses []string, src, dest, tmpl string) error {
+	var err error
+	for _, address := range addresses {
+		var content string
+		if content, err = renderConsulTemplate(ctx, address, src, tmpl); err != nil {
+			continue
+		}
+		if err = WriteConsulTemplateFile(dest+".cfg", []byte(content), 0664); err != nil {
+			return err
+		}
+		renderedTemplatesMu.Lock()
+		renderedTemplates[dest] = renderedTemplate{addresses: addresses, src: src, template: tmpl, content: content}
+		renderedTemplatesMu.Unlock()
+		return nil
+	}
+	return err
+}
+
+// renderConsulTemplate renders the subset of the Consul Template language used by the proxy templates.
+// Supported functions are service, key, keyOrDefault, and env. The queries are canceled when the context is done.
+func renderConsulTemplate(ctx context.Context, address, name, tmpl string) (string, error) {
+	address = getConsulUrl(address)
+	t, err := template.New(name).Funcs(template.FuncMap{
+		"service": func(name string, options ...string) ([]ConsulServiceEntry, error) {
+			return getConsulServiceEntries(ctx, address, name, options...)
+		},
+		"key": func(key string) (string, error) {
+			value, _, err := getConsulKey(ctx, address, key)
+			return value, err
+		},
+		"keyOrDefault": func(key, defaultValue string) (string, error) {
+			value, found, err := getConsulKey(ctx, address, key)
+			if !found {
+				return defaultValue, err
+			}
+			return value, err
+		},
+		"env": os.Getenv,
+	}).Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("Could not parse the template %s\n%s", name, err.Error())
+	}
+	var content bytes.Buffer
+	if err := t.Execute(&content, nil); err != nil {
+		return "", fmt.Errorf("Could not render the template %s\n%s", name, err.Error())
+	}
+	return content.String(), nil
+}
+
+// getConsulServiceEntries returns the instances of the service with passing health checks or, with the any option, all of them.
+// The name can be prefixed with a tag (e.g. "production.my-service").
+func getConsulServiceEntries(ctx context.Context, address, name string, options ...string) ([]ConsulServiceEntry, error) {
+	query := url.Values{}
+	if parts := strings.SplitN(name, ".", 2); len(parts) == 2 {
+		query.Set("tag", parts[0])
+		name = parts[1]
+	}
+	any := false
+	for _, option := range options {
+		if strings.EqualFold(option, "any") {
+			any = true
+		}
+	}
+	if !any {
+		query.Set("passing", "")
+	}
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/v1/health/service/%s?%s", address, name, query.Encode()), nil)
+	resp, err := getClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Consul responded to the query of the service %s with the status code %d", name, resp.StatusCode)
+	}
+	data := []struct {
+		Node struct {
+			Node    string
+			Address string
+		}
+		Service struct {
+			ID      string
+			Service string
+			Tags    []string
+			Address string
+			Port    int
+		}
+		Checks []struct {
+			Status string
+		}
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("Could not decode the instances of the service %s\n%s", name, err.Error())
+	}
+	entries := []ConsulServiceEntry{}
+	for _, d := range data {
+		e := ConsulServiceEntry{
+			Node:    d.Node.Node,
+			Address: d.Service.Address,
+			ID:      d.Service.ID,
+			Name:    d.Service.Service,
+			Port:    d.Service.Port,
+			Tags:    d.Service.Tags,
+			Status:  "passing",
+		}
+		if len(e.Address) == 0 {
+			e.Address = d.Node.Address
+		}
+		for _, check := range d.Checks {
+			if check.Status == "critical" || (check.Status == "warning" && e.Status == "passing") {
+				e.Status = check.Status
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// getConsulKey returns the value of the key and whether it exists.
+func getConsulKey(ctx context.Context, address, key string) (string, bool, error) {
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/v1/kv/%s?raw", address, strings.TrimPrefix(key, "/")), nil)
+	resp, err := getClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("Consul responded to the query of the key %s with the status code %d", key, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	return string(body), true, err
+}
+
+// The Consul endpoints whose indexes change when services, their health, or the keys change.
+var consulWatchPaths = []string{"/v1/health/state/any", "/v1/kv/?keys"}
+
+// WatchConsulTemplates renders the templates rendered natively again whenever services, their health, or the keys change in Consul.
+// The changed function is called once for each batch of changes that modified at least one configuration.
+// The watcher never returns and reports errors through the failed function.
+func WatchConsulTemplates(addresses []string, changed func(), failed func(error)) {
+	changes := make(chan bool, 1)
+	for _, path := range consulWatchPaths {
+		go watchConsulIndex(addresses, path, changes, failed)
+	}
+	for range changes {
+		time.Sleep(consulWatchDebounce)
+		select {
+		case <-changes:
+		default:
+		}
+		updated, errs := rerenderConsulTemplates()
+		for _, err := range errs {
+			failed(err)
+		}
+		if updated {
+			changed()
+		}
+	}
+}
+
+// watchConsulIndex sends to the changes channel whenever the index of the endpoint changes.
+func watchConsulIndex(addresses []string, path string, changes chan bool, failed func(error)) {
+	index := ""
+	for {
+		next, err := waitForConsulChange(addresses, path, index)
+		if err != nil {
+			failed(err)
+			time.Sleep(consulWatchRetry)
+			continue
+		}
+		if len(index) > 0 && next != index {
+			select {
+			case changes <- true:
+			default:
+			}
+		}
+		index = next
+	}
+}
+
+// waitForConsulChange blocks until the index of the endpoint differs from the index or until five minutes pass and returns the new index.
+func waitForConsulChange(addresses []string, path, index string) (string, error) {
+	var err error
+	for _, address := range addresses {
+		var next string
+		if next, err = waitForConsulIndex(getConsulUrl(address)+path, index); err == nil {
+			return next, nil
+		}
+	}
+	return index, fmt.Errorf("Could not watch Consul for changes\n%s", err)
+}
+
+func waitForConsulIndex(queryUrl, index string) (string, error) {
+	separator := "?"
+	if strings.Contains(queryUrl, "?") {
+		separator = "&"
+	}
+	if len(index) > 0 {
+		queryUrl = fmt.Sprintf("%s%sindex=%s&wait=5m", queryUrl, separator, index)
+	}
+	resp, err := consulWatchClient.Get(queryUrl)
+	if err != nil {
+		return index, err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return index, fmt.Errorf("Consul responded to %s with the status code %d", queryUrl, resp.StatusCode)
+	}
+	return resp.Header.Get("X-Consul-Index"), nil
+}
+
+// rerenderConsulTemplates renders all the templates rendered natively and returns whether any configuration changed.
+// Configurations that were removed (e.g. because the service was removed from the proxy) are forgotten.
+func rerenderConsulTemplates() (bool, []error) {
+	renderedTemplatesMu.Lock()
+	defer renderedTemplatesMu.Unlock()
+	dests := []string{}
+	for dest := range renderedTemplates {
+		dests = append(dests, dest)
+	}
+	sort.Strings(dests)
+	updated := false
+	errs := []error{}
+	for _, dest := range dests {
+		rt := renderedTemplates[dest]
+		if _, err := statConsulConfig(dest + ".cfg"); err != nil {
+			delete(renderedTemplates, dest)
+			continue
+		}
+		var content string
+		var err error
+		for _, address := range rt.addresses {
+			if content, err = renderConsulTemplate(context.Background(), address, rt.src, rt.template); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Could not create Consul configuration %s from the template %s\n%s", dest, rt.src, err.Error()))
+			continue
+		}
+		if content == rt.content {
+			continue
+		}
+		if err := WriteConsulTemplateFile(dest+".cfg", []byte(content), 0664); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rt.content = content
+		renderedTemplates[dest] = rt
+		updated = true
+	}
+	return updated, errs
+}
+
+func getConsulUrl(address string) string {
+	if !strings.HasPrefix(address, "http") {
+		return fmt.Sprintf("http://%s", address)
+	}
+	return address
+}
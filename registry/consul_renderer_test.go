@@ -0,0 +1,239 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ConsulRendererTestSuite struct {
+	suite.Suite
+	server  *httptest.Server
+	written map[string]string
+	keys    map[string]string
+}
+
+func (s *ConsulRendererTestSuite) SetupTest() {
+	s.written = map[string]string{}
+	s.keys = map[string]string{"config/timeout": "10s"}
+	renderedTemplates = map[string]renderedTemplate{}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/health/service/my-service":
+			if _, ok := r.URL.Query()["passing"]; ok {
+				fmt.Fprint(w, `[{"Node":{"Node":"node-1","Address":"10.0.0.1"},"Service":{"ID":"my-service-1","Service":"my-service","Port":8080},"Checks":[{"Status":"passing"}]}]`)
+			} else {
+				fmt.Fprint(w, `[{"Node":{"Node":"node-1","Address":"10.0.0.1"},"Service":{"ID":"my-service-1","Service":"my-service","Port":8080},"Checks":[{"Status":"passing"}]},{"Node":{"Node":"node-2","Address":"10.0.0.2"},"Service":{"ID":"my-service-2","Service":"my-service","Address":"10.0.1.2","Port":8081},"Checks":[{"Status":"passing"},{"Status":"critical"}]}]`)
+			}
+		case "/v1/kv/config/timeout", "/v1/kv/config/missing":
+			if value, ok := s.keys[r.URL.Path[len("/v1/kv/"):]]; ok {
+				fmt.Fprint(w, value)
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	WriteConsulTemplateFile = func(filename string, data []byte, perm os.FileMode) error {
+		s.written[filename] = string(data)
+		return nil
+	}
+	statConsulConfig = func(name string) (os.FileInfo, error) {
+		if _, ok := s.written[name]; !ok {
+			return nil, fmt.Errorf("%s does not exist", name)
+		}
+		return nil, nil
+	}
+}
+
+func (s *ConsulRendererTestSuite) TearDownTest() {
+	s.server.Close()
+	renderedTemplates = map[string]renderedTemplate{}
+}
+
+// renderConsulTemplate
+
+func (s *ConsulRendererTestSuite) Test_RenderConsulTemplate_RendersPassingInstances() {
+	actual, err := renderConsulTemplate(context.Background(), s.server.URL, "be", `{{range $i, $e := service "my-service"}}server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}}
+{{end}}`)
+
+	s.NoError(err)
+	s.Equal("server node-1_0_8080 10.0.0.1:8080\n", actual)
+}
+
+func (s *ConsulRendererTestSuite) Test_RenderConsulTemplate_RendersAllInstances_WhenAnyIsSpecified() {
+	actual, err := renderConsulTemplate(context.Background(), s.server.URL, "be", `{{range service "my-service" "any"}}{{.ID}} {{.Address}} {{.Status}}
+{{end}}`)
+
+	s.NoError(err)
+	s.Equal("my-service-1 10.0.0.1 passing\nmy-service-2 10.0.1.2 critical\n", actual)
+}
+
+func (s *ConsulRendererTestSuite) Test_RenderConsulTemplate_RendersKeys() {
+	actual, err := renderConsulTemplate(context.Background(), s.server.URL, "be", `timeout server {{key "config/timeout"}} {{keyOrDefault "config/missing" "5s"}}`)
+
+	s.NoError(err)
+	s.Equal("timeout server 10s 5s", actual)
+}
+
+func (s *ConsulRendererTestSuite) Test_RenderConsulTemplate_ReturnsError_WhenTemplateIsInvalid() {
+	_, err := renderConsulTemplate(context.Background(), s.server.URL, "be", `{{range service "my-service"}}`)
+
+	s.Error(err)
+}
+
+func (s *ConsulRendererTestSuite) Test_RenderConsulTemplate_ReturnsError_WhenConsulFails() {
+	_, err := renderConsulTemplate(context.Background(), s.server.URL, "be", `{{range service "other-service"}}{{end}}`)
+
+	s.Error(err)
+}
+
+// CreateConfigs
+
+func (s *ConsulRendererTestSuite) Test_CreateConfigs_RendersTemplatesNatively_WhenRendererIsNative() {
+	defer os.Unsetenv("CONSUL_TEMPLATE_RENDERER")
+	os.Setenv("CONSUL_TEMPLATE_RENDERER", "native")
+	cmdRunConsulTemplateOrig := cmdRunConsulTemplate
+	defer func() { cmdRunConsulTemplate = cmdRunConsulTemplateOrig }()
+	executed := false
+	cmdRunConsulTemplate = func(cmd *exec.Cmd) error {
+		executed = true
+		return nil
+	}
+	args := CreateConfigsArgs{
+		Addresses:     []string{"http://127.0.0.1:1", s.server.URL},
+		TemplatesPath: "/path/to/templates",
+		FeFile:        "fe.ctmpl",
+		FeTemplate:    "acl url_my-service path_beg /my-service",
+		BeFile:        "be.ctmpl",
+		BeTemplate:    `{{range service "my-service"}}server {{.Address}}:{{.Port}}{{end}}`,
+		ServiceName:   "my-service",
+	}
+
+	err := Consul{}.CreateConfigs(context.Background(), &args)
+
+	s.NoError(err)
+	s.False(executed)
+	s.Equal("acl url_my-service path_beg /my-service", s.written["/path/to/templates/my-service-fe.cfg"])
+	s.Equal("server 10.0.0.1:8080", s.written["/path/to/templates/my-service-be.cfg"])
+}
+
+func (s *ConsulRendererTestSuite) Test_CreateConfigs_ReturnsError_WhenNativeRenderingFails() {
+	defer os.Unsetenv("CONSUL_TEMPLATE_RENDERER")
+	os.Setenv("CONSUL_TEMPLATE_RENDERER", "native")
+	args := CreateConfigsArgs{
+		Addresses:     []string{s.server.URL},
+		TemplatesPath: "/path/to/templates",
+		BeFile:        "be.ctmpl",
+		BeTemplate:    `{{range service "other-service"}}{{end}}`,
+		ServiceName:   "my-service",
+	}
+
+	err := Consul{}.CreateConfigs(context.Background(), &args)
+
+	s.Error(err)
+}
+
+func (s *ConsulRendererTestSuite) Test_CreateConfigs_ReturnsError_WhenContextIsCanceled() {
+	defer os.Unsetenv("CONSUL_TEMPLATE_RENDERER")
+	os.Setenv("CONSUL_TEMPLATE_RENDERER", "native")
+	args := CreateConfigsArgs{
+		Addresses:     []string{s.server.URL},
+		TemplatesPath: "/path/to/templates",
+		BeFile:        "be.ctmpl",
+		BeTemplate:    `{{range service "my-service"}}server {{.Address}}:{{.Port}}{{end}}`,
+		ServiceName:   "my-service",
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Consul{}.CreateConfigs(ctx, &args)
+
+	s.Error(err)
+	s.NotContains(s.written, "/path/to/templates/my-service-be.cfg")
+}
+
+// rerenderConsulTemplates
+
+func (s *ConsulRendererTestSuite) Test_RerenderConsulTemplates_WritesChangedConfigs() {
+	tmpl := `timeout {{key "config/timeout"}}`
+	renderConsulConfig(context.Background(), []string{s.server.URL}, "be.ctmpl", "/path/to/templates/my-service-be", tmpl)
+
+	updated, errs := rerenderConsulTemplates()
+
+	s.False(updated)
+	s.Empty(errs)
+
+	s.keys["config/timeout"] = "20s"
+	updated, errs = rerenderConsulTemplates()
+
+	s.True(updated)
+	s.Empty(errs)
+	s.Equal("timeout 20s", s.written["/path/to/templates/my-service-be.cfg"])
+}
+
+func (s *ConsulRendererTestSuite) Test_RerenderConsulTemplates_ForgetsRemovedConfigs() {
+	renderConsulConfig(context.Background(), []string{s.server.URL}, "be.ctmpl", "/path/to/templates/my-service-be", `timeout {{key "config/timeout"}}`)
+	delete(s.written, "/path/to/templates/my-service-be.cfg")
+	s.keys["config/timeout"] = "20s"
+
+	updated, _ := rerenderConsulTemplates()
+
+	s.False(updated)
+	s.Empty(renderedTemplates)
+	s.NotContains(s.written, "/path/to/templates/my-service-be.cfg")
+}
+
+func (s *ConsulRendererTestSuite) Test_RerenderConsulTemplates_ReturnsErrors() {
+	renderedTemplates["/path/to/templates/my-service-be"] = renderedTemplate{
+		addresses: []string{s.server.URL},
+		src:       "be.ctmpl",
+		template:  `{{range service "other-service"}}{{end}}`,
+	}
+	s.written["/path/to/templates/my-service-be.cfg"] = ""
+
+	_, errs := rerenderConsulTemplates()
+
+	s.Len(errs, 1)
+}
+
+// waitForConsulChange
+
+func (s *ConsulRendererTestSuite) Test_WaitForConsulChange_ReturnsConsulIndex() {
+	var actualQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actualQuery = r.URL.RawQuery
+		w.Header().Set("X-Consul-Index", "43")
+	}))
+	defer srv.Close()
+
+	actual, err := waitForConsulChange([]string{srv.URL}, "/v1/kv/?keys", "42")
+
+	s.NoError(err)
+	s.Equal("43", actual)
+	s.Equal("keys&index=42&wait=5m", actualQuery)
+}
+
+func (s *ConsulRendererTestSuite) Test_WaitForConsulChange_ReturnsError_WhenConsulFails() {
+	actual, err := waitForConsulChange([]string{s.server.URL}, "/v1/health/state/any", "42")
+
+	s.Error(err)
+	s.Equal("42", actual)
+}
+
+// Suite
+
+func TestConsulRendererUnitTestSuite(t *testing.T) {
+	writeConsulTemplateFileOrig := WriteConsulTemplateFile
+	defer func() { WriteConsulTemplateFile = writeConsulTemplateFileOrig }()
+	statConsulConfigOrig := statConsulConfig
+	defer func() { statConsulConfig = statConsulConfigOrig }()
+	suite.Run(t, new(ConsulRendererTestSuite))
+}
@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"context"
 	"fmt"
 	"github.com/stretchr/testify/suite"
 	"io/ioutil"
@@ -71,7 +72,7 @@ func (s *ConsulTestSuite) Test_PutService_PutsDataToConsul() {
 		mu.Unlock()
 	}))
 	defer server.Close()
-	err := Consul{}.PutService([]string{server.URL}, instanceName, s.registry)
+	err := Consul{}.PutService(context.Background(), []string{server.URL}, instanceName, s.registry)
 
 	s.NoError(err)
 
@@ -96,7 +97,19 @@ func (s *ConsulTestSuite) Test_PutService_PutsDataToConsul() {
 }
 
 func (s *ConsulTestSuite) Test_PutService_ReturnsError_WhenFailure() {
-	err := Consul{}.PutService([]string{"http:///THIS/URL/DOES/NOT/EXIST"}, "my-instance", s.registry)
+	err := Consul{}.PutService(context.Background(), []string{"http:///THIS/URL/DOES/NOT/EXIST"}, "my-instance", s.registry)
+
+	s.Error(err)
+}
+
+func (s *ConsulTestSuite) Test_PutService_ReturnsError_WhenContextIsCanceled() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Consul{}.PutService(ctx, []string{server.URL}, "my-instance", s.registry)
 
 	s.Error(err)
 }
@@ -107,7 +120,7 @@ func (s *ConsulTestSuite) Test_PutService_DoesNotReturnError_WhenOneOfTheAddress
 	defer server.Close()
 
 	addresses := []string{"http:///THIS/URL/DOES/NOT/EXIST", server.URL, "http:///THIS/URL/ALSO/DOES/NOT/EXIST"}
-	err := Consul{}.PutService(addresses, "my-instance", s.registry)
+	err := Consul{}.PutService(context.Background(), addresses, "my-instance", s.registry)
 
 	s.NoError(err)
 }
@@ -119,7 +132,7 @@ func (s *ConsulTestSuite) Test_SendPutRequest_AddsHttp_WhenNotPresent() {
 	defer server.Close()
 	url := strings.Replace(server.URL, "http://", "", -1)
 
-	err := Consul{}.PutService([]string{url}, instanceName, s.registry)
+	err := Consul{}.PutService(context.Background(), []string{url}, instanceName, s.registry)
 
 	s.NoError(err)
 }
@@ -259,6 +272,75 @@ func (s *ConsulTestSuite) Test_GetServiceAttribute_ReturnsConsulResponse() {
 	s.Equal(expected, actual)
 }
 
+// PutServiceAttributeIfAbsent
+
+func (s *ConsulTestSuite) Test_PutServiceAttributeIfAbsent_ReturnsValueStoredFirst() {
+	stored := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("/v1/kv/my-instance/my-service/my-key", r.URL.Path)
+		if r.Method == "PUT" {
+			s.Equal("0", r.URL.Query().Get("cas"))
+			body, _ := ioutil.ReadAll(r.Body)
+			created := len(stored) == 0
+			if created {
+				stored = string(body)
+			}
+			w.Write([]byte(fmt.Sprintf("%t", created)))
+		} else {
+			w.Write([]byte(stored))
+		}
+	}))
+	defer server.Close()
+	address := strings.Replace(server.URL, "http://", "", -1)
+
+	first, err := Consul{}.PutServiceAttributeIfAbsent([]string{address}, "my-service", "my-key", "first", "my-instance")
+	s.NoError(err)
+	second, err := Consul{}.PutServiceAttributeIfAbsent([]string{address}, "my-service", "my-key", "second", "my-instance")
+	s.NoError(err)
+
+	s.Equal("first", first)
+	s.Equal("first", second)
+}
+
+func (s *ConsulTestSuite) Test_PutServiceAttributeIfAbsent_ReturnsError_WhenConsulReturnsNon200Code() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := Consul{}.PutServiceAttributeIfAbsent([]string{server.URL}, "my-service", "my-key", "value", "my-instance")
+
+	s.Error(err)
+}
+
+// DeleteServiceAttribute
+
+func (s *ConsulTestSuite) Test_DeleteServiceAttribute_SendsDeleteRequest() {
+	actualMethod, actualPath := "", ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actualMethod, actualPath = r.Method, r.URL.Path
+		w.Write([]byte("true"))
+	}))
+	defer server.Close()
+
+	err := Consul{}.DeleteServiceAttribute([]string{server.URL}, "my-service", "my-key", "my-instance")
+
+	s.NoError(err)
+	s.Equal("DELETE", actualMethod)
+	s.Equal("/v1/kv/my-instance/my-service/my-key", actualPath)
+}
+
+func (s *ConsulTestSuite) Test_DeleteServiceAttribute_ReturnsError_WhenConsulReturnsNon200Code() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := Consul{}.DeleteServiceAttribute([]string{server.URL}, "my-service", "my-key", "my-instance")
+
+	s.Error(err)
+}
+
 // CreateConfigs
 
 func (s *ConsulTestSuite) Test_CreateConfigs_ReturnsError_WhenConsulTemplateFeCommandFails() {
@@ -266,7 +348,7 @@ func (s *ConsulTestSuite) Test_CreateConfigs_ReturnsError_WhenConsulTemplateFeCo
 		return fmt.Errorf("This is an error")
 	}
 
-	err := Consul{}.CreateConfigs(&s.createConfigsArgs)
+	err := Consul{}.CreateConfigs(context.Background(), &s.createConfigsArgs)
 
 	s.Error(err)
 }
@@ -282,7 +364,7 @@ func (s *ConsulTestSuite) Test_CreateConfigs_ReturnsError_WhenConsulTemplateBeCo
 		}
 	}
 
-	err := Consul{}.CreateConfigs(&s.createConfigsArgs)
+	err := Consul{}.CreateConfigs(context.Background(), &s.createConfigsArgs)
 
 	s.Error(err)
 }
@@ -324,7 +406,7 @@ func (s *ConsulTestSuite) Test_CreateConfigs_RunsConsulTemplate() {
 		"-once",
 	}
 
-	Consul{}.CreateConfigs(&s.createConfigsArgs)
+	Consul{}.CreateConfigs(context.Background(), &s.createConfigsArgs)
 
 	s.Equal(2, len(actual))
 	s.Equal(expectedFe, actual[0])
@@ -338,7 +420,7 @@ func (s *ConsulTestSuite) Test_CreateConfigs_CreatesConsulTemplate() {
 		return nil
 	}
 
-	Consul{}.CreateConfigs(&s.createConfigsArgs)
+	Consul{}.CreateConfigs(context.Background(), &s.createConfigsArgs)
 
 	s.Equal(s.feTemplate, actual)
 }
@@ -381,7 +463,7 @@ func (s *ConsulTestSuite) Test_CreateConfigs_RunsConsulTemplateWithTrimmedHttp()
 	}
 
 	s.createConfigsArgs.Addresses = []string{strings.Replace(s.consulAddress, "http://", "hTtP://", -1)}
-	Consul{}.CreateConfigs(&s.createConfigsArgs)
+	Consul{}.CreateConfigs(context.Background(), &s.createConfigsArgs)
 
 	s.Equal(2, len(actual))
 	s.Equal(expectedFe, actual[0])
@@ -426,7 +508,7 @@ func (s *ConsulTestSuite) Test_CreateConfigs_RunsConsulTemplateWithTrimmedHttps(
 	}
 
 	s.createConfigsArgs.Addresses = []string{strings.Replace(s.consulAddress, "http://", "hTTPs://", -1)}
-	Consul{}.CreateConfigs(&s.createConfigsArgs)
+	Consul{}.CreateConfigs(context.Background(), &s.createConfigsArgs)
 
 	s.Equal(2, len(actual))
 	s.Equal(expectedFe, actual[0])
@@ -445,7 +527,7 @@ func (s *ConsulTestSuite) Test_CreateConfigs_WritesTemplateToFile() {
 	}
 
 	s.createConfigsArgs.Addresses = []string{fmt.Sprintf("HttP://%s", s.consulAddress)}
-	Consul{}.CreateConfigs(&s.createConfigsArgs)
+	Consul{}.CreateConfigs(context.Background(), &s.createConfigsArgs)
 
 	s.Equal(expected, actual)
 }
@@ -470,7 +552,7 @@ func (s *ConsulTestSuite) Test_CreateConfigs_DoesNotWriteFrontTemplate_WhenEmpty
 	}
 
 	s.createConfigsArgs.Addresses = []string{fmt.Sprintf("HttP://%s", s.consulAddress)}
-	Consul{}.CreateConfigs(&args)
+	Consul{}.CreateConfigs(context.Background(), &args)
 
 	s.Equal(expected, actual)
 }
@@ -484,7 +566,7 @@ func (s *ConsulTestSuite) Test_CreateConfigs_SetsFilePermissions() {
 	}
 
 	s.createConfigsArgs.Addresses = []string{fmt.Sprintf("HttP://%s", s.consulAddress)}
-	Consul{}.CreateConfigs(&s.createConfigsArgs)
+	Consul{}.CreateConfigs(context.Background(), &s.createConfigsArgs)
 
 	s.Equal(expected, actual)
 }
@@ -1,5 +1,7 @@
 package registry
 
+import "context"
+
 const (
 	COLOR_KEY                   = "color"
 	PATH_KEY                    = "path"
@@ -28,9 +30,11 @@ type Registry struct {
 }
 
 type Registrarable interface {
-	PutService(addresses []string, instanceName string, r Registry) error
+	PutService(ctx context.Context, addresses []string, instanceName string, r Registry) error
 	SendPutRequest(addresses []string, serviceName, key, value, instanceName string, c chan error)
 	DeleteService(addresses []string, serviceName, instanceName string) error
-	CreateConfigs(args *CreateConfigsArgs) error
+	CreateConfigs(ctx context.Context, args *CreateConfigsArgs) error
 	GetServiceAttribute(addresses []string, serviceName, key, instanceName string) (string, error)
+	PutServiceAttributeIfAbsent(addresses []string, serviceName, key, value, instanceName string) (string, error)
+	DeleteServiceAttribute(addresses []string, serviceName, key, instanceName string) error
 }
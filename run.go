@@ -15,5 +15,8 @@ var NewRun = func() Executable {
 }
 
 func (m *Run) Execute(args []string) error {
+	if haproxy.Instance != nil {
+		return haproxy.Instance.RunCmd([]string{})
+	}
 	return haproxy.HaProxy{}.RunCmd([]string{})
 }
@@ -0,0 +1,30 @@
+#!/usr/bin/env bash
+# Builds and pushes the image for amd64, arm64, and armv7 (e.g. Raspberry Pi clusters) under a single multi-architecture tag.
+# Requires docker buildx with QEMU emulation of the foreign platforms.
+set -e
+if [[ -z ${DOCKER_HUB_USER} ]]; then
+    echo "set DOCKER_HUB_USER variable to your docker hub account before running"
+    exit 1
+fi
+TAG=${TAG:-beta}
+IMAGE=$DOCKER_HUB_USER/docker-flow-proxy
+PLATFORMS=${PLATFORMS:-"amd64 arm64 arm/v7"}
+
+images=()
+for platform in $PLATFORMS; do
+    arch=${platform%%/*}
+    variant=${platform#*/}
+    goarm=""
+    suffix=$arch
+    if [[ $variant != $platform ]]; then
+        goarm=${variant#v}
+        suffix=$arch$variant
+    fi
+    echo "Building $IMAGE:$TAG-$suffix"
+    # Static binaries run on the musl based image of any architecture
+    docker run --rm -v $PWD:/usr/src/myapp -w /usr/src/myapp -v go:/go golang:1.7 bash -c \
+        "go get -d -v -t && CGO_ENABLED=0 GOOS=linux GOARCH=$arch GOARM=$goarm go build -v -o docker-flow-proxy-$suffix"
+    docker buildx build --platform linux/$platform --build-arg BINARY=docker-flow-proxy-$suffix -t $IMAGE:$TAG-$suffix --push .
+    images+=("$IMAGE:$TAG-$suffix")
+done
+docker buildx imagetools create -t $IMAGE:$TAG "${images[@]}"
@@ -0,0 +1,14 @@
+#!/bin/bash
+set -e
+# Generates the Python client from the OpenAPI document of a running proxy.
+# Usage: scripts/generate-python-client.sh [PROXY_ADDRESS] [OUTPUT_DIR]
+PROXY_ADDRESS=${1:-http://localhost:8080}
+OUTPUT_DIR=${2:-$PWD/clients/python}
+
+mkdir -p $OUTPUT_DIR
+curl -sSf $PROXY_ADDRESS/v1/docker-flow-proxy/openapi.json -o $OUTPUT_DIR/openapi.json
+docker run --rm -v $OUTPUT_DIR:/out openapitools/openapi-generator-cli generate \
+    -i /out/openapi.json \
+    -g python \
+    -o /out \
+    --package-name docker_flow_proxy
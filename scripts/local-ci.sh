@@ -6,7 +6,7 @@ if [[ ( -z ${DOCKER_HUB_USER} ) || ( -z ${HOST_IP} ) ]]; then
 fi
 
 echo Running in $PWD
-docker run --rm -v $PWD:/usr/src/myapp -w /usr/src/myapp -v go:/go golang:1.6 bash -c "go get -d -v -t && go test --cover ./... --run UnitTest && go build -v -o docker-flow-proxy"
+docker run --rm -v $PWD:/usr/src/myapp -w /usr/src/myapp -v go:/go golang:1.7 bash -c "go get -d -v -t && go test --cover ./... --run UnitTest && go build -v -o docker-flow-proxy"
 docker build -t $DOCKER_HUB_USER/docker-flow-proxy .
 docker-compose -f docker-compose-test.yml up -d staging-dep
 docker-compose -f docker-compose-test.yml run --rm staging
@@ -4,13 +4,22 @@ import (
 	"./actions"
 	"./proxy"
 	"./server"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
-	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 // TODO: Move to server package
@@ -40,31 +49,123 @@ type Serve struct {
 var serverImpl = Serve{}
 var cert server.Certer = server.NewCert("/certs")
 var reload actions.Reloader = actions.NewReload()
+var urgentReload actions.Reloader = &actions.Reload{Priority: proxy.ReloadUrgent}
+var serviceVersions = proxy.NewServiceVersions()
+var orphans actions.OrphanCollector
+var expirations = proxy.NewExpirations()
+var disabledServices = proxy.NewDisabledServices()
+var profiles = proxy.NewProfiles()
+var serviceParams = proxy.NewProfiles() // The reconfigure parameters of the configured services used by cloneFrom
+var schedule = proxy.NewSchedule("/cfg/schedule.json")
+var reconfigureMu = &sync.Mutex{}
+var readCertSecret = ioutil.ReadFile
+var drainSleep = time.Sleep
+var shuttingDown int32               // Set to 1 once the proxy starts shutting down
+var shutdownCh = make(chan struct{}) // Closed once the proxy starts shutting down so that the streams can end
 //exposed as global so can be changed in tests
 var usersBasePath string = "/run/secrets/dfp_users_%s"
 
 func (m *Serve) Execute(args []string) error {
 	if proxy.Instance == nil {
-		proxy.Instance = proxy.NewHaProxy(m.TemplatesPath, m.ConfigsPath)
+		instance, err := proxy.NewEngine(m.TemplatesPath, m.ConfigsPath)
+		if err != nil {
+			return err
+		}
+		proxy.Instance = instance
+	}
+	// The configuration created by the run already routes the previews and the ACME challenges to the API
+	if len(m.Port) > 0 {
+		proxy.ApiPort = m.Port
 	}
-	logPrintf("Starting HAProxy")
 	m.setConsulAddresses()
+	if err := m.preflight(); err != nil {
+		return err
+	}
+	if proxy.GetEngine() == "haproxy" {
+		if version, err := detectHaProxyVersion(); err != nil {
+			logWarnf("%s\nAll the directives are generated regardless of the HAProxy version.", err.Error())
+		} else {
+			logPrintf("Detected HAProxy %s", version)
+		}
+		m.initTls()
+		logPrintf("Starting HAProxy")
+	} else {
+		logPrintf("Starting %s", proxy.GetEngine())
+	}
 	NewRun().Execute([]string{})
 	address := fmt.Sprintf("%s:%s", m.IP, m.Port)
-	lAddr := ""
-	if len(m.ListenerAddress) > 0 {
-		lAddr = fmt.Sprintf("http://%s:8080", m.ListenerAddress)
-	}
+	lAddrs := m.getListenerUrls()
 	cert.Init()
+	profilesPath := proxy.GetSecretOrEnvVar("PROFILES_PATH", "/cfg/profiles.yml")
+	if err := profiles.LoadFile(profilesPath); err != nil && !os.IsNotExist(err) {
+		logWarnf("%s", err.Error())
+	}
+	if err := proxy.LoadGlobals(proxy.GetSecretOrEnvVar("GLOBALS_PATH", "/cfg/globals.json")); err != nil && !os.IsNotExist(err) {
+		logWarnf("%s", err.Error())
+	}
+	// Loaded before the services so that the disabled services are configured as disabled
+	if err := disabledServices.Load(proxy.GetSecretOrEnvVar("DISABLED_SERVICES_PATH", "/cfg/disabled-services.json")); err != nil && !os.IsNotExist(err) {
+		logWarnf("%s", err.Error())
+	}
 	recon := actions.NewReconfigure(m.BaseReconfigure, proxy.Service{}, m.Mode)
-	if err := recon.ReloadAllServices(
-		m.ConsulAddresses,
-		m.InstanceName,
-		m.Mode,
-		lAddr,
-	); err != nil {
-		return err
+	if len(lAddrs) == 0 {
+		if err := m.reloadAllServices(recon, ""); err != nil {
+			return err
+		}
+	}
+	// The other listeners are still asked for their services when one of them cannot be reached.
+	// The services of the unreachable listener are configured once it notifies the proxy about them.
+	for _, lAddr := range lAddrs {
+		if err := m.reloadAllServices(recon, lAddr); err != nil {
+			logWarnf("Could not reach the Swarm Listener %s. Its services will be configured once it is running.\n%s", lAddr, err.Error())
+		}
+	}
+	go m.waitUntilReady(lAddrs)
+	m.notifyRemoteListeners(recon)
+	m.startConsulWatch()
+	if interval, _ := strconv.Atoi(proxy.GetSecretOrEnvVar("ORPHANS_CHECK_INTERVAL", "0")); interval > 0 {
+		gracePeriod, _ := strconv.Atoi(proxy.GetSecretOrEnvVar("ORPHANS_GRACE_PERIOD", "300"))
+		orphans = actions.NewOrphans(
+			m.BaseReconfigure,
+			m.Mode,
+			time.Duration(gracePeriod)*time.Second,
+			strings.EqualFold(proxy.GetSecretOrEnvVar("ORPHANS_REMOVE", "false"), "true"),
+			m.removeOrphan,
+		)
+		go m.collectOrphans(time.Duration(interval) * time.Second)
 	}
+	go m.expireServices(time.Second * 10)
+	m.startNotifiers()
+	m.startGrpc()
+	if interval, _ := strconv.Atoi(proxy.GetSecretOrEnvVar("EVENTS_HEALTH_INTERVAL", "10")); interval > 0 && proxy.GetEngine() == "haproxy" {
+		go m.watchBackendsHealth(time.Duration(interval) * time.Second)
+	}
+	if proxy.IsAccessLogEnabled() && proxy.GetEngine() == "haproxy" {
+		m.startAccessLog()
+	}
+	if interval, _ := strconv.Atoi(proxy.GetSecretOrEnvVar("TEMPLATE_WATCH_INTERVAL", "5")); interval > 0 {
+		go m.watchTemplateFiles(time.Duration(interval) * time.Second)
+	}
+	if strings.EqualFold(proxy.GetSecretOrEnvVar("DOCKER_EVENTS", "false"), "true") && m.isSwarm(m.Mode) {
+		debounce, _ := strconv.Atoi(proxy.GetSecretOrEnvVar("DOCKER_EVENTS_DEBOUNCE", "1"))
+		go m.watchDockerEvents(time.Duration(debounce)*time.Second, time.Second*10)
+	}
+	schedule = proxy.NewSchedule(proxy.GetSecretOrEnvVar("SCHEDULE_PATH", "/cfg/schedule.json"))
+	if err := schedule.Load(); err != nil && !os.IsNotExist(err) {
+		logWarnf("%s", err.Error())
+	}
+	go m.runSchedule(time.Second * 10)
+	if address := proxy.GetSecretOrEnvVar("STATSD_ADDRESS", ""); len(address) > 0 {
+		interval, _ := strconv.Atoi(proxy.GetSecretOrEnvVar("STATSD_INTERVAL", "10"))
+		statsd := proxy.NewStatsD(address, proxy.GetSecretOrEnvVar("STATSD_PREFIX", "dfp"))
+		go m.pushStatsD(statsd, time.Duration(interval)*time.Second)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	go func() {
+		<-signals
+		m.shutdown()
+	}()
 	logPrintf(`Starting "Docker Flow: Proxy"`)
 	if err := httpListenAndServe(address, m); err != nil {
 		return err
@@ -72,37 +173,517 @@ func (m *Serve) Execute(args []string) error {
 	return nil
 }
 
+// notifyRemoteListeners asks the Swarm Listeners of other clusters (REMOTE_LISTENER_ADDRESSES) to send their services.
+// Unlike the local listener, a remote listener that cannot be reached does not prevent the proxy from starting.
+func (m *Serve) notifyRemoteListeners(recon actions.Reconfigurable) {
+	for _, address := range strings.Split(proxy.GetSecretOrEnvVar("REMOTE_LISTENER_ADDRESSES", ""), ",") {
+		if address = strings.TrimSpace(address); len(address) == 0 {
+			continue
+		}
+		if !strings.HasPrefix(address, "http") {
+			address = fmt.Sprintf("http://%s:8080", address)
+		}
+		if err := recon.ReloadAllServices([]string{}, m.InstanceName, m.Mode, address); err != nil {
+			logWarnf("Could not notify the remote Swarm Listener %s\n%s", address, err.Error())
+		}
+	}
+}
+
+func (m *Serve) collectOrphans(interval time.Duration) {
+	for range time.Tick(interval) {
+		orphans.Execute([]string{})
+	}
+}
+
+// pushStatsD sends the metrics of the backends to StatsD.
+func (m *Serve) pushStatsD(statsd *proxy.StatsD, interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := statsd.Push(); err != nil {
+			logWarnf("%s", err.Error())
+		}
+	}
+}
+
+// expireServices removes the services that were not reconfigured within their TTL.
+func (m *Serve) expireServices(interval time.Duration) {
+	for range time.Tick(interval) {
+		m.removeExpiredServices()
+	}
+}
+
+func (m *Serve) removeExpiredServices() {
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	services := proxy.Instance.GetServices()
+	for _, serviceName := range expirations.GetExpired() {
+		logWarnf("The TTL of the service %s expired. Removing it from the proxy.", serviceName)
+		m.removeService(serviceName, services[serviceName].AclName, actions.RemoveOptions{})
+	}
+}
+
+// removeOrphan removes a service whose source does not exist anymore.
+func (m *Serve) removeOrphan(serviceName, aclName string) error {
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	_, err := m.removeService(serviceName, aclName, actions.RemoveOptions{})
+	return err
+}
+
+// removeService removes the service from the proxy together with its TTL and, unless the state is kept,
+// its version, parameters, and whether it is disabled, so that it is configured again when it is registered with the same parameters.
+// The state is kept if the service could not be removed. reconfigureMu must be held.
+func (m *Serve) removeService(serviceName, aclName string, options actions.RemoveOptions) (actions.Removed, error) {
+	return m.executeRemove(m.newRemove(serviceName, aclName, options), serviceName, options)
+}
+
+// drainAndRemoveService removes the service the same way as removeService. When the service is drained first,
+// reconfigureMu is released while the backend serves the requests in flight so that other requests are not blocked meanwhile.
+// If the expected version is set, the service is removed only if it matches the current version of the service, checked
+// under reconfigureMu together with the removal so that the service cannot be reconfigured in between.
+func (m *Serve) drainAndRemoveService(serviceName, aclName, expected string, options actions.RemoveOptions) (actions.Removed, error) {
+	action := m.newRemove(serviceName, aclName, options)
+	if options.DrainFirst {
+		reconfigureMu.Lock()
+		if err := m.checkVersion(serviceName, expected); err != nil {
+			reconfigureMu.Unlock()
+			return actions.Removed{}, err
+		}
+		err := action.Drain()
+		reconfigureMu.Unlock()
+		if err != nil {
+			return action.GetRemoved(), err
+		}
+		drainSleep(time.Second * time.Duration(options.DrainTimeout))
+	}
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	if err := m.checkVersion(serviceName, expected); err != nil {
+		return action.GetRemoved(), err
+	}
+	return m.executeRemove(action, serviceName, options)
+}
+
+// versionMismatchError is returned when the expected version does not match the current version of the service
+type versionMismatchError struct {
+	expected string
+	current  proxy.ServiceVersion
+}
+
+func (e versionMismatchError) Error() string {
+	return fmt.Sprintf("The version %s does not match the current version %s of the service", e.expected, e.current.ETag())
+}
+
+// checkVersion returns versionMismatchError if the expected version is set and does not match the current version of the service.
+func (m *Serve) checkVersion(serviceName, expected string) error {
+	current := serviceVersions.Get(serviceName)
+	if len(expected) > 0 && !m.matchesVersion(expected, current) {
+		return versionMismatchError{expected: expected, current: current}
+	}
+	return nil
+}
+
+func (m *Serve) newRemove(serviceName, aclName string, options actions.RemoveOptions) actions.Removable {
+	return actions.NewRemove(
+		serviceName,
+		aclName,
+		m.BaseReconfigure.ConfigsPath,
+		m.BaseReconfigure.TemplatesPath,
+		m.ConsulAddresses,
+		m.InstanceName,
+		m.Mode,
+		options,
+	)
+}
+
+func (m *Serve) executeRemove(action actions.Removable, serviceName string, options actions.RemoveOptions) (actions.Removed, error) {
+	err := action.Execute([]string{})
+	if _, found := proxy.Instance.GetServices()[serviceName]; err == nil || !found {
+		expirations.Delete(serviceName)
+		if !options.KeepState {
+			serviceVersions.Delete(serviceName)
+			serviceParams.Delete(serviceName)
+			disabledServices.Delete(serviceName)
+		}
+	}
+	return action.GetRemoved(), err
+}
+
+// shutdown stops accepting reconfigure and remove requests, announces the drain to SHUTDOWN_NOTIFY_URL,
+// and lets HAProxy finish the in-flight requests within SHUTDOWN_GRACE_PERIOD before exiting.
+func (m *Serve) shutdown() {
+	if !atomic.CompareAndSwapInt32(&shuttingDown, 0, 1) {
+		return
+	}
+	close(shutdownCh)
+	logPrintf("Shutting down the proxy")
+	if url := proxy.GetSecretOrEnvVar("SHUTDOWN_NOTIFY_URL", ""); len(url) > 0 {
+		m.notifyDrain(url)
+	}
+	if delay, _ := strconv.Atoi(proxy.GetSecretOrEnvVar("SHUTDOWN_DELAY", "0")); delay > 0 {
+		// Gives the load balancer the time to stop sending new requests before the proxy stops listening
+		time.Sleep(time.Duration(delay) * time.Second)
+	}
+	// Waits for the reconfiguration in progress so that HAProxy is not reloaded while it is stopping
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	gracePeriod, _ := strconv.Atoi(proxy.GetSecretOrEnvVar("SHUTDOWN_GRACE_PERIOD", "8"))
+	if err := softStopProxy(time.Duration(gracePeriod) * time.Second); err != nil {
+		logWarnf("%s", err.Error())
+	}
+	osExit(0)
+}
+
+func (m *Serve) notifyDrain(url string) {
+	js, _ := json.Marshal(map[string]string{"InstanceName": m.InstanceName, "Status": "draining"})
+	resp, err := httpPost(url, "application/json", strings.NewReader(string(js)))
+	if err != nil {
+		logWarnf("Could not announce the drain to %s\n%s", url, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logWarnf("%s responded to the drain announcement with the status code %d", url, resp.StatusCode)
+	}
+}
+
+func (m *Serve) isShuttingDown() bool {
+	return atomic.LoadInt32(&shuttingDown) == 1
+}
+
+func (m *Serve) runSchedule(interval time.Duration) {
+	for range time.Tick(interval) {
+		m.runScheduledActions()
+	}
+}
+
+// runScheduledActions reconfigures the services with the maintenance or the color defined by the actions that are due.
+// The services are reconfigured the same way as through reconfigure requests with their last parameters,
+// so their versions change and the reloads are coalesced with those of the requests.
+// Actions that fail are retried during the next run.
+func (m *Serve) runScheduledActions() {
+	services := proxy.Instance.GetServices()
+	for _, a := range schedule.GetDue() {
+		sr, found := services[a.ServiceName]
+		if !found {
+			logWarnf("The service %s of the scheduled action %s is not configured. Removing the action from the schedule.", a.ServiceName, a.ID)
+			schedule.Delete(a.ID)
+			continue
+		}
+		if a.Type == proxy.ScheduleSwitchColor {
+			logPrintf("Switching the color of the service %s to %s", a.ServiceName, a.ServiceColor)
+			sr.ServiceColor = a.ServiceColor
+		} else if !a.Started {
+			logPrintf("Starting the maintenance of the service %s", a.ServiceName)
+			sr.Maintenance = true
+		} else {
+			logPrintf("Ending the maintenance of the service %s", a.ServiceName)
+			sr.Maintenance = disabledServices.Contains(a.ServiceName)
+		}
+		query := url.Values{}
+		if params, found := serviceParams.Get(a.ServiceName); found {
+			for param, value := range params {
+				query.Set(param, value)
+			}
+		}
+		req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/reconfigure?"+query.Encode(), nil)
+		resp := &grpcResponse{header: http.Header{}}
+		response := server.Response{}
+		m.executeReconfigure(resp, req, &response, sr)
+		if resp.status >= http.StatusMultipleChoices {
+			logWarnf("Could not execute the scheduled action %s of the service %s\n%s", a.ID, a.ServiceName, response.Message)
+			continue
+		}
+		if a.Type == proxy.ScheduleMaintenance && !a.Started {
+			a.Started = true
+			schedule.Update(a)
+		} else {
+			schedule.Delete(a.ID)
+		}
+	}
+}
+
 func (m *Serve) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if !strings.EqualFold(req.URL.Path, "/v1/test") {
-		logPrintf("Processing request %s", req.URL)
+		logRequestf(req, "Processing request %s", req.URL)
+	}
+	switch req.URL.Path {
+	case "/v1/docker-flow-proxy/reconfigure", "/v1/docker-flow-proxy/remove", "/v1/docker-flow-proxy/backends/health":
+		if m.isShuttingDown() {
+			httpWriterSetContentType(w, "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			js, _ := json.Marshal(server.Response{Status: "NOK", Message: "The proxy is shutting down"})
+			w.Write(js)
+			return
+		}
 	}
 	switch req.URL.Path {
+	case "/v1/docker-flow-proxy/acme-challenge":
+		m.acmeChallenge(w, req)
+	case "/v1/docker-flow-proxy/backends/health":
+		m.backendsHealth(w, req)
+	case "/v1/docker-flow-proxy/capture":
+		m.capture(w, req)
 	case "/v1/docker-flow-proxy/cert":
+		if !m.authorizeAdmin(w, req) {
+			return
+		}
 		if req.Method == "PUT" {
 			cert.Put(w, req)
 		} else {
-			logPrintf("/v1/docker-flow-proxy/cert endpoint allows only PUT requests. Yours was %s", req.Method)
+			logWarnf("/v1/docker-flow-proxy/cert endpoint allows only PUT requests. Yours was %s", req.Method)
 			w.WriteHeader(http.StatusNotFound)
 		}
 	case "/v1/docker-flow-proxy/certs":
 		cert.GetAll(w, req)
 	case "/v1/docker-flow-proxy/config":
 		m.config(w, req)
+	case "/v1/docker-flow-proxy/config/diff":
+		m.configDiff(w, req)
+	case "/v1/docker-flow-proxy/debug/render":
+		m.debugRender(w, req)
+	case "/v1/docker-flow-proxy/events":
+		m.events(w, req)
+	case "/v1/docker-flow-proxy/blocklist":
+		m.manageBlocklist(w, req)
+	case "/v1/docker-flow-proxy/faults":
+		m.manageFaults(w, req)
+	case "/v1/docker-flow-proxy/globals":
+		m.manageGlobals(w, req)
+	case "/v1/docker-flow-proxy/loglevel":
+		m.manageLogLevel(w, req)
+	case "/v1/docker-flow-proxy/metrics":
+		m.metrics(w, req)
+	case "/v1/docker-flow-proxy/openapi.json":
+		m.openApi(w, req)
+	case "/v1/docker-flow-proxy/orphans":
+		m.getOrphans(w, req)
+	case "/v1/docker-flow-proxy/preview":
+		m.preview(w, req)
+	case "/v1/docker-flow-proxy/profiles":
+		m.manageProfiles(w, req)
+	case "/v1/docker-flow-proxy/ready":
+		m.readiness(w, req)
 	case "/v1/docker-flow-proxy/reconfigure":
 		m.reconfigure(w, req)
 	case "/v1/docker-flow-proxy/remove":
 		m.remove(w, req)
 	case "/v1/docker-flow-proxy/reload":
 		m.reload(w, req)
+	case "/v1/docker-flow-proxy/schedule":
+		m.manageSchedule(w, req)
+	case "/v1/docker-flow-proxy/services":
+		m.listServices(w, req)
+	case "/v1/docker-flow-proxy/state":
+		m.manageState(w, req)
+	case "/v1/docker-flow-proxy/stats":
+		m.stats(w, req)
+	case "/v1/docker-flow-proxy/status":
+		m.status(w, req)
+	case "/v3/discovery:clusters", "/v3/discovery:routes", "/v3/discovery:listeners":
+		m.xdsDiscovery(w, req)
 	case "/v1/test", "/v2/test":
 		js, _ := json.Marshal(server.Response{Status: "OK"})
 		httpWriterSetContentType(w, "application/json")
 		w.WriteHeader(http.StatusOK)
 		w.Write(js)
 	default:
-		logPrintf("The endpoint %s is not supported", req.URL.Path)
+		if serviceName, enabled, ok := m.getServiceToggle(req.URL.Path); ok && req.Method == "PUT" {
+			m.toggleService(w, req, serviceName, enabled)
+			return
+		}
+		if serviceName, address, ok := m.getServerDrain(req.URL.Path); ok {
+			m.drainServer(w, req, serviceName, address)
+			return
+		}
+		if serviceName, ok := m.getServiceResource(req.URL.Path); ok {
+			m.serviceResource(w, req, serviceName)
+			return
+		}
+		logWarnf("The endpoint %s is not supported", req.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// getServiceToggle returns the name of the service and whether it should be enabled from paths formatted as
+// /v1/docker-flow-proxy/service/<name>/enable or /v1/docker-flow-proxy/service/<name>/disable.
+func (m *Serve) getServiceToggle(path string) (string, bool, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/v1/docker-flow-proxy/service/"), "/")
+	if !strings.HasPrefix(path, "/v1/docker-flow-proxy/service/") || len(parts) != 2 || len(parts[0]) == 0 {
+		return "", false, false
+	}
+	switch parts[1] {
+	case "enable":
+		return parts[0], true, true
+	case "disable":
+		return parts[0], false, true
+	}
+	return "", false, false
+}
+
+// getServerDrain returns the name of the service and the address of the server from paths formatted as
+// /v1/docker-flow-proxy/service/<name>/server/<address>/drain.
+func (m *Serve) getServerDrain(path string) (string, string, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/v1/docker-flow-proxy/service/"), "/")
+	if !strings.HasPrefix(path, "/v1/docker-flow-proxy/service/") || len(parts) != 4 || len(parts[0]) == 0 || parts[1] != "server" || len(parts[2]) == 0 || parts[3] != "drain" {
+		return "", "", false
+	}
+	return parts[0], parts[2], true
+}
+
+// getServiceResource returns the name of the service from paths formatted as /v1/docker-flow-proxy/service/<name>.
+func (m *Serve) getServiceResource(path string) (string, bool) {
+	name := strings.TrimPrefix(path, "/v1/docker-flow-proxy/service/")
+	if !strings.HasPrefix(path, "/v1/docker-flow-proxy/service/") || len(name) == 0 || strings.Contains(name, "/") {
+		return "", false
+	}
+	return name, true
+}
+
+// serviceResource manages the service as a resource for infrastructure as code tools.
+// GET outputs the service with the defaults applied and its version in the ETag header,
+// PUT reconfigures it with the query parameters, and DELETE removes it.
+// Removing a service that is not configured succeeds without reloading the proxy.
+func (m *Serve) serviceResource(w http.ResponseWriter, req *http.Request, name string) {
+	query := req.URL.Query()
+	query.Set("serviceName", name)
+	req.URL.RawQuery = query.Encode()
+	switch req.Method {
+	case "PUT":
+		m.reconfigure(w, req)
+		return
+	case "GET", "DELETE":
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !m.authorizeNamespace(w, req, name) {
+		return
+	}
+	serviceName := proxy.GetNamespacedName(req.URL.Query().Get("namespace"), name)
+	response := server.Response{
+		Mode:        m.Mode,
+		Status:      "OK",
+		ServiceName: serviceName,
+	}
+	httpWriterSetContentType(w, "application/json")
+	current := serviceVersions.Get(serviceName)
+	sr, found := proxy.Instance.GetServices()[serviceName]
+	if found {
+		w.Header().Set("ETag", current.ETag())
+	}
+	if req.Method == "DELETE" && found {
+		// The version is checked by the removal itself, under the same lock
+		m.remove(w, req)
+		return
+	} else if err := m.checkVersion(serviceName, m.getExpectedVersion(req)); err != nil {
+		response.Status = "NOK"
+		response.Message = err.Error()
+		w.WriteHeader(http.StatusPreconditionFailed)
+	} else if req.Method == "DELETE" {
+		response.Message = fmt.Sprintf("The service %s is not configured", serviceName)
+		response.Removed = &actions.Removed{}
+		w.WriteHeader(http.StatusOK)
+	} else if !found {
+		response.Status = "NOK"
+		response.Message = fmt.Sprintf("The service %s is not configured", serviceName)
+		w.WriteHeader(http.StatusNotFound)
+	} else if req.Header.Get("If-None-Match") == current.ETag() {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	} else {
+		response.Service = proxy.RedactService(sr)
+		w.WriteHeader(http.StatusOK)
+	}
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+// toggleService disables the service by answering its requests with the status 503 or enables it again.
+// The service stays configured so that it can be enabled without sending its reconfigure parameters again.
+func (m *Serve) toggleService(w http.ResponseWriter, req *http.Request, name string, enabled bool) {
+	if !m.authorizeNamespace(w, req, name) {
+		return
+	}
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	serviceName := proxy.GetNamespacedName(req.URL.Query().Get("namespace"), name)
+	response := server.Response{
+		Mode:        m.Mode,
+		Status:      "OK",
+		ServiceName: serviceName,
+	}
+	httpWriterSetContentType(w, "application/json")
+	sr, found := proxy.Instance.GetServices()[serviceName]
+	if !found {
+		response.Status = "NOK"
+		response.Message = fmt.Sprintf("The service %s is not configured", serviceName)
+		w.WriteHeader(http.StatusNotFound)
+	} else {
+		wasDisabled := disabledServices.Contains(serviceName)
+		if enabled {
+			logPrintf("Enabling the service %s", serviceName)
+			disabledServices.Delete(serviceName)
+			sr.Maintenance = schedule.IsInMaintenance(serviceName)
+		} else {
+			logPrintf("Disabling the service %s", serviceName)
+			disabledServices.Add(serviceName)
+			sr.Maintenance = true
+		}
+		response.Service = proxy.RedactService(sr)
+		action := actions.NewReconfigure(m.BaseReconfigure, sr, m.Mode)
+		if err := action.Execute([]string{}); err != nil {
+			if wasDisabled {
+				disabledServices.Add(serviceName)
+			} else {
+				disabledServices.Delete(serviceName)
+			}
+			m.writeInternalServerError(w, &response, err.Error())
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+// drainServer drains the servers of the service with the address through the stats socket (PUT) or makes them ready again (DELETE).
+// The other servers of the service are not affected and the proxy is not reloaded.
+func (m *Serve) drainServer(w http.ResponseWriter, req *http.Request, name, address string) {
+	if req.Method != "PUT" && req.Method != "DELETE" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !m.authorizeNamespace(w, req, name) {
+		return
+	}
+	serviceName := proxy.GetNamespacedName(req.URL.Query().Get("namespace"), name)
+	response := server.Response{
+		Mode:        m.Mode,
+		Status:      "OK",
+		ServiceName: serviceName,
+	}
+	httpWriterSetContentType(w, "application/json")
+	if _, found := proxy.Instance.GetServices()[serviceName]; !found {
+		response.Status = "NOK"
+		response.Message = fmt.Sprintf("The service %s is not configured", serviceName)
+		w.WriteHeader(http.StatusNotFound)
+	} else if servers, err := setServerDrain(serviceName, address, req.Method == "PUT"); err != nil {
+		m.writeInternalServerError(w, &response, err.Error())
+	} else if len(servers) == 0 {
+		response.Status = "NOK"
+		response.Message = fmt.Sprintf("The service %s does not have servers with the address %s", serviceName, address)
 		w.WriteHeader(http.StatusNotFound)
+	} else {
+		state := "ready"
+		if req.Method == "PUT" {
+			state = "draining"
+		}
+		response.Message = fmt.Sprintf("The servers %s are %s", strings.Join(servers, ", "), state)
+		logPrintf("%s", response.Message)
+		w.WriteHeader(http.StatusOK)
 	}
+	js, _ := json.Marshal(response)
+	w.Write(js)
 }
 
 func (m *Serve) isValidReconf(service *proxy.Service) (bool, string) {
@@ -119,9 +700,87 @@ func (m *Serve) isValidReconf(service *proxy.Service) (bool, string) {
 	} else if !hasSrcPort || !hasPort {
 		return false, "When NOT using reqMode http (e.g. tcp), srcPort and port parameters are mandatory."
 	}
+	for _, command := range []string{service.ExternalCheckCommand, service.DbWriterCheckCommand} {
+		if len(command) > 0 && !m.isAllowedExternalCheck(command) {
+			return false, fmt.Sprintf("The external check command %s is not allowed. It must be listed in EXTERNAL_CHECK_COMMANDS.", command)
+		}
+	}
 	return true, ""
 }
 
+// getValidationErrors returns field-level errors of the reconfigure parameters.
+func (m *Serve) getValidationErrors(req *http.Request, sr proxy.Service) []proxy.ValidationError {
+	var errs []proxy.ValidationError
+	params := []string{
+		"srcPort", "httpsPort", "aclPriority", "ttlSeconds", "corsMaxAge", "mirrorPercentage",
+		"bandwidthLimitPerStream", "bandwidthLimitTotal", "maxIdleConnections", "logSampleRate",
+		"staticResponseStatus", "maxUrlLength",
+	}
+	for i := 1; i <= 10; i++ {
+		params = append(params, fmt.Sprintf("srcPort.%d", i))
+	}
+	for param := range req.URL.Query() {
+		if strings.HasPrefix(param, "aclPriority.") {
+			params = append(params, param)
+		}
+	}
+	for _, param := range params {
+		if value := req.URL.Query().Get(param); len(value) > 0 {
+			if _, err := strconv.Atoi(value); err != nil {
+				errs = append(errs, proxy.ValidationError{Field: param, Message: fmt.Sprintf("%s is not a number", value)})
+			}
+		}
+	}
+	if profile := req.URL.Query().Get("profile"); len(profile) > 0 {
+		if _, found := profiles.Get(profile); !found {
+			errs = append(errs, proxy.ValidationError{Field: "profile", Message: fmt.Sprintf("%s is not a known profile", profile)})
+		}
+	}
+	if cloneFrom := req.URL.Query().Get("cloneFrom"); len(cloneFrom) > 0 {
+		if _, found := serviceParams.Get(proxy.GetNamespacedName(req.URL.Query().Get("namespace"), cloneFrom)); !found {
+			errs = append(errs, proxy.ValidationError{Field: "cloneFrom", Message: fmt.Sprintf("%s is not a configured service", cloneFrom)})
+		}
+	}
+	return append(append(errs, m.getModeValidationErrors(sr)...), proxy.ValidateService(sr)...)
+}
+
+// getModeValidationErrors returns the errors of the parameters that are not supported in the mode of the proxy.
+// The servers of the Consul mode come from the Consul catalog so the database hosts cannot be used.
+func (m *Serve) getModeValidationErrors(sr proxy.Service) []proxy.ValidationError {
+	var errs []proxy.ValidationError
+	if m.isSwarm(m.Mode) {
+		return errs
+	}
+	if len(sr.DbWriters) > 0 {
+		errs = append(errs, proxy.ValidationError{Field: "dbWriters", Message: `dbWriters can be used only when MODE is set to "swarm"`})
+	}
+	if len(sr.DbReaders) > 0 {
+		errs = append(errs, proxy.ValidationError{Field: "dbReaders", Message: `dbReaders can be used only when MODE is set to "swarm"`})
+	}
+	return errs
+}
+
+func (m *Serve) getValidationMessage(errs []proxy.ValidationError) string {
+	if len(errs) == 0 {
+		return ""
+	}
+	msgs := []string{}
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("Invalid parameters: %s", strings.Join(msgs, "; "))
+}
+
+func (m *Serve) isAllowedExternalCheck(command string) bool {
+	allowed := proxy.GetSecretOrEnvVar("EXTERNAL_CHECK_COMMANDS", "")
+	for _, c := range strings.Split(allowed, ",") {
+		if strings.TrimSpace(c) == command {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *Serve) isSwarm(mode string) bool {
 	return strings.EqualFold("service", m.Mode) || strings.EqualFold("swarm", m.Mode)
 }
@@ -131,50 +790,97 @@ func (m *Serve) hasPort(sd []proxy.ServiceDest) bool {
 }
 
 func (m *Serve) reload(w http.ResponseWriter, req *http.Request) {
-	listenerAddr := ""
-	recreate := m.getBoolParam(req, "recreate")
-	if m.getBoolParam(req, "fromListener") {
-		listenerAddr = m.ListenerAddress
+	if !m.authorizeAdmin(w, req) {
+		return
 	}
-	reload.Execute(recreate, listenerAddr)
-	w.WriteHeader(http.StatusOK)
-	httpWriterSetContentType(w, "application/json")
 	response := server.Response{
 		Status: "OK",
 	}
+	listenerAddrs, err := m.getReloadListeners(req)
+	if err != nil {
+		httpWriterSetContentType(w, "application/json")
+		m.writeBadRequest(w, &response, err.Error())
+		js, _ := json.Marshal(response)
+		w.Write(js)
+		return
+	}
+	recreate := m.getBoolParam(req, "recreate")
+	if len(listenerAddrs) > 0 {
+		for _, listenerAddr := range listenerAddrs {
+			reload.Execute(recreate, listenerAddr)
+		}
+	} else {
+		reload.Execute(recreate, "")
+	}
+	w.WriteHeader(http.StatusOK)
+	httpWriterSetContentType(w, "application/json")
 	js, _ := json.Marshal(response)
 	w.Write(js)
 }
 
-func (m *Serve) reconfigure(w http.ResponseWriter, req *http.Request) {
+func (m *Serve) getServiceDest(req *http.Request) []proxy.ServiceDest {
 	path := []string{}
 	if len(req.URL.Query().Get("servicePath")) > 0 {
 		path = strings.Split(req.URL.Query().Get("servicePath"), ",")
 	}
 	port := req.URL.Query().Get("port")
 	srcPort, _ := strconv.Atoi(req.URL.Query().Get("srcPort"))
+	var alpn []string
+	if len(req.URL.Query().Get("alpn")) > 0 {
+		alpn = strings.Split(req.URL.Query().Get("alpn"), ",")
+	}
 	sd := []proxy.ServiceDest{}
 	ctmplFePath := req.URL.Query().Get("consulTemplateFePath")
 	ctmplBePath := req.URL.Query().Get("consulTemplateBePath")
 	if len(path) > 0 || len(port) > 0 || (len(ctmplFePath) > 0 && len(ctmplBePath) > 0) {
 		sd = append(
 			sd,
-			proxy.ServiceDest{Port: port, SrcPort: srcPort, ServicePath: path},
+			proxy.ServiceDest{
+				Port:                 port,
+				SrcPort:              srcPort,
+				ServicePath:          path,
+				Alpn:                 alpn,
+				DbRole:               req.URL.Query().Get("dbRole"),
+				FrontendTemplatePath: req.URL.Query().Get("frontendTemplatePath"),
+			},
 		)
 	}
 	for i := 1; i <= 10; i++ {
 		port := req.URL.Query().Get(fmt.Sprintf("port.%d", i))
 		path := req.URL.Query().Get(fmt.Sprintf("servicePath.%d", i))
 		srcPort, _ := strconv.Atoi(req.URL.Query().Get(fmt.Sprintf("srcPort.%d", i)))
+		var alpn []string
+		if value := req.URL.Query().Get(fmt.Sprintf("alpn.%d", i)); len(value) > 0 {
+			alpn = strings.Split(value, ",")
+		}
 		if len(path) > 0 && len(port) > 0 {
 			sd = append(
 				sd,
-				proxy.ServiceDest{Port: port, SrcPort: srcPort, ServicePath: strings.Split(path, ",")},
+				proxy.ServiceDest{
+					Port:                 port,
+					SrcPort:              srcPort,
+					ServicePath:          strings.Split(path, ","),
+					Alpn:                 alpn,
+					DbRole:               req.URL.Query().Get(fmt.Sprintf("dbRole.%d", i)),
+					FrontendTemplatePath: req.URL.Query().Get(fmt.Sprintf("frontendTemplatePath.%d", i)),
+					Index:                i,
+					TimeoutServer:        req.URL.Query().Get(fmt.Sprintf("timeoutServer.%d", i)),
+					TimeoutTunnel:        req.URL.Query().Get(fmt.Sprintf("timeoutTunnel.%d", i)),
+				},
 			)
 		} else {
 			break
 		}
 	}
+	return sd
+}
+
+func (m *Serve) reconfigure(w http.ResponseWriter, req *http.Request) {
+	m.addDefaultParams(req)
+	if !m.authorizeNamespace(w, req) {
+		return
+	}
+	sd := m.getServiceDest(req)
 	sr := m.getService(sd, req)
 	response := server.Response{
 		Mode:        m.Mode,
@@ -184,7 +890,11 @@ func (m *Serve) reconfigure(w http.ResponseWriter, req *http.Request) {
 	}
 	ok, msg := m.isValidReconf(&sr)
 	if ok {
-		if m.isSwarm(m.Mode) && !m.hasPort(sd) {
+		response.Errors = m.getValidationErrors(req, sr)
+		ok, msg = len(response.Errors) == 0, m.getValidationMessage(response.Errors)
+	}
+	if ok {
+		if m.isSwarm(m.Mode) && !m.hasPort(sd) && !sr.IsStaticResponse() {
 			m.writeBadRequest(w, &response, `When MODE is set to "service" or "swarm", the port query is mandatory`)
 		} else if sr.Distribute {
 			srv := server.Serve{}
@@ -194,22 +904,20 @@ func (m *Serve) reconfigure(w http.ResponseWriter, req *http.Request) {
 				response.Message = DISTRIBUTED
 				w.WriteHeader(http.StatusOK)
 			}
+		} else if m.hasNamespaceCollisions(w, &response) {
+			logWarnf("%s", response.Message)
+		} else if m.hasAclNameCollision(w, &response) {
+			logWarnf("%s", response.Message)
+		} else if m.hasRejectedConflicts(w, &response) {
+			logWarnf("%s", response.Message)
 		} else {
-			if len(sr.ServiceCert) > 0 {
-				// Replace \n with proper carriage return as new lines are not supported in labels
-				sr.ServiceCert = strings.Replace(sr.ServiceCert, "\\n", "\n", -1)
-				if len(sr.ServiceDomain) > 0 {
-					cert.PutCert(sr.ServiceDomain[0], []byte(sr.ServiceCert))
-				} else {
-					cert.PutCert(sr.ServiceName, []byte(sr.ServiceCert))
-				}
+			if len(response.Conflicts) > 0 {
+				logWarnf("%s", response.Message)
 			}
-			action := actions.NewReconfigure(m.BaseReconfigure, sr, m.Mode)
-			if err := action.Execute([]string{}); err != nil {
-				m.writeInternalServerError(w, &response, err.Error())
-			} else {
-				w.WriteHeader(http.StatusOK)
+			if warnings := proxy.GetFeatureWarnings(response.Service); len(warnings) > 0 {
+				response.Warnings = append(response.Warnings, warnings...)
 			}
+			m.executeReconfigure(w, req, &response, response.Service)
 		}
 	} else {
 		m.writeBadRequest(w, &response, msg)
@@ -221,21 +929,61 @@ func (m *Serve) reconfigure(w http.ResponseWriter, req *http.Request) {
 
 
 
-func getUsersFromFile(serviceName, fileName string, passEncrypted bool) ([]*proxy.User, error) {
-	if len(fileName) > 0 {
-		usersFile := fmt.Sprintf(usersBasePath, fileName)
-
-		if content, err := ioutil.ReadFile(usersFile); err == nil {
-			userContents := strings.TrimRight(string(content[:]), "\n")
-			return proxy.ExtractUsersFromString(serviceName,userContents, passEncrypted, true), nil
-		} else {
-			logPrintf("For service %s it was impossible to load userFile %s due to error %s",
-				serviceName, usersFile, err.Error())
-			return []*proxy.User{}, err
-		}
-	} else {
-		return []*proxy.User{}, nil
-	}
+func (m *Serve) debugRender(w http.ResponseWriter, req *http.Request) {
+	m.addDefaultParams(req)
+	sd := m.getServiceDest(req)
+	sr := m.getService(sd, req)
+	response := server.RenderResponse{
+		Status:      "OK",
+		ServiceName: sr.ServiceName,
+	}
+	ok, msg := m.isValidReconf(&sr)
+	if ok {
+		response.Errors = m.getValidationErrors(req, sr)
+		ok, msg = len(response.Errors) == 0, m.getValidationMessage(response.Errors)
+	}
+	if ok && m.isSwarm(m.Mode) && !m.hasPort(sd) {
+		ok, msg = false, `When MODE is set to "service" or "swarm", the port query is mandatory`
+	}
+	if ok {
+		action := actions.NewReconfigure(m.BaseReconfigure, sr, m.Mode)
+		if front, back, err := action.GetTemplates(&sr); err != nil {
+			response.Status = "NOK"
+			response.Message = err.Error()
+			w.WriteHeader(http.StatusInternalServerError)
+		} else {
+			if len(front) == 0 {
+				front = proxy.HaProxy{}.RenderFrontend(sr)
+			}
+			response.Frontend = front
+			response.Backend = back
+			w.WriteHeader(http.StatusOK)
+		}
+	} else {
+		response.Status = "NOK"
+		response.Message = msg
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	httpWriterSetContentType(w, "application/json")
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+func getUsersFromFile(serviceName, fileName string, passEncrypted bool) ([]*proxy.User, error) {
+	if len(fileName) > 0 {
+		usersFile := fmt.Sprintf(usersBasePath, fileName)
+
+		if content, err := ioutil.ReadFile(usersFile); err == nil {
+			userContents := strings.TrimRight(string(content[:]), "\n")
+			return proxy.ExtractUsersFromString(serviceName,userContents, passEncrypted, true), nil
+		} else {
+			logWarnf("For service %s it was impossible to load userFile %s due to error %s",
+				serviceName, usersFile, err.Error())
+			return []*proxy.User{}, err
+		}
+	} else {
+		return []*proxy.User{}, nil
+	}
 }
 
 func allUsersHavePasswords(users []*proxy.User) bool {
@@ -272,7 +1020,7 @@ func mergeUsers(serviceName, usersParam, usersSecret string, usersPassEncrypted
 						u.Password = userByName.Password
 						u.PassEncrypted = userByName.PassEncrypted
 					} else {
-						logPrintf("For service %s it was impossible to find password for user %s.",
+						logWarnf("For service %s it was impossible to find password for user %s.",
 							serviceName, u.Username)
 					}
 				}
@@ -300,16 +1048,119 @@ func mergeUsers(serviceName, usersParam, usersSecret string, usersPassEncrypted
 
 }
 
+// The prefix of the environment variables that define the default values of the reconfigure parameters
+const serviceDefaultPrefix = "SERVICE_DEFAULT_"
+
+// The parameters that identify a service and are, therefore, not inherited from the service referenced by cloneFrom
+var notClonedParams = []string{"serviceName", "aclName", "namespace", "cloneFrom", "version", "distribute"}
+
+// addDefaultParams adds the parameters of the service referenced by cloneFrom, of the profile referenced by the request, and those defined through
+// the SERVICE_DEFAULT_<PARAM> environment variables (e.g. SERVICE_DEFAULT_TIMEOUT_SERVER for timeoutServer) unless they are specified in the request.
+// The prefix is distinct from the variables of the proxy itself (e.g. DEFAULT_PORTS) so that they are never mistaken for parameters.
+func (m *Serve) addDefaultParams(req *http.Request) {
+	query := req.URL.Query()
+	changed := false
+	if cloneFrom := query.Get("cloneFrom"); len(cloneFrom) > 0 {
+		params, _ := serviceParams.Get(proxy.GetNamespacedName(m.getRequestNamespace(req), cloneFrom))
+		for param, value := range params {
+			if _, found := query[param]; !found && !m.isNotClonedParam(param) {
+				query.Set(param, value)
+				changed = true
+			}
+		}
+	}
+	if params, found := profiles.Get(query.Get("profile")); found {
+		for param, value := range params {
+			if _, found := query[param]; !found {
+				query.Set(param, value)
+				changed = true
+			}
+		}
+	}
+	for _, env := range os.Environ() {
+		keyValue := strings.SplitN(env, "=", 2)
+		if len(keyValue) != 2 || len(keyValue[1]) == 0 || !strings.HasPrefix(keyValue[0], serviceDefaultPrefix) {
+			continue
+		}
+		param := m.getParamName(strings.TrimPrefix(keyValue[0], serviceDefaultPrefix))
+		if _, found := query[param]; !found {
+			query.Set(param, keyValue[1])
+			changed = true
+		}
+	}
+	if changed {
+		req.URL.RawQuery = query.Encode()
+	}
+}
+
+func (m *Serve) isNotClonedParam(param string) bool {
+	for _, p := range notClonedParams {
+		if p == param {
+			return true
+		}
+	}
+	return false
+}
+
+// getRequestNamespace returns the namespace of the request or, if it is not specified, the namespace of its token.
+func (m *Serve) getRequestNamespace(req *http.Request) string {
+	if namespace := req.URL.Query().Get("namespace"); len(namespace) > 0 {
+		return namespace
+	}
+	tokens := proxy.GetNamespaceTokens(proxy.GetSecretOrEnvVar("NAMESPACE_TOKENS", ""))
+	return tokens[strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")]
+}
+
+// getParams returns the first value of each of the query parameters of the request.
+func (m *Serve) getParams(req *http.Request) map[string]string {
+	params := map[string]string{}
+	for param, values := range req.URL.Query() {
+		if len(values) > 0 {
+			params[param] = values[0]
+		}
+	}
+	return params
+}
+
+// getParamName converts the name of an environment variable (e.g. TIMEOUT_SERVER) to the name of a parameter (e.g. timeoutServer).
+func (m *Serve) getParamName(envName string) string {
+	words := strings.Split(strings.ToLower(envName), "_")
+	for i := 1; i < len(words); i++ {
+		if len(words[i]) > 0 {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	return strings.Join(words, "")
+}
+
 func (m *Serve) getService(sd []proxy.ServiceDest, req *http.Request) proxy.Service {
 	sr := proxy.Service{
 		ServiceDest:          sd,
 		ServiceName:          req.URL.Query().Get("serviceName"),
 		AclName:              req.URL.Query().Get("aclName"),
+		AddPathPrefix:        strings.TrimSuffix(req.URL.Query().Get("addPathPrefix"), "/"),
 		ServiceColor:         req.URL.Query().Get("serviceColor"),
 		ServiceCert:          req.URL.Query().Get("serviceCert"),
+		CertSecret:           req.URL.Query().Get("certSecret"),
+		SetHostHeader:        req.URL.Query().Get("setHostHeader"),
+		SplitBy:              req.URL.Query().Get("splitBy"),
+		StaticResponseBody:   req.URL.Query().Get("staticResponseBody"),
+		HttpReuse:            req.URL.Query().Get("httpReuse"),
+		MirrorToService:      req.URL.Query().Get("mirrorToService"),
+		Namespace:            req.URL.Query().Get("namespace"),
+		TcpPreset:            req.URL.Query().Get("tcpPreset"),
 		OutboundHostname:     req.URL.Query().Get("outboundHostname"),
+		ConnectionMode:       req.URL.Query().Get("connectionMode"),
 		ConsulTemplateFePath: req.URL.Query().Get("consulTemplateFePath"),
 		ConsulTemplateBePath: req.URL.Query().Get("consulTemplateBePath"),
+		CorsAllowHeaders:     req.URL.Query().Get("corsAllowHeaders"),
+		CorsAllowMethods:     req.URL.Query().Get("corsAllowMethods"),
+		CorsAllowOrigins:     req.URL.Query().Get("corsAllowOrigins"),
+		DbWriterCheckCommand: req.URL.Query().Get("dbWriterCheckCommand"),
+		Errorfile500Path:     req.URL.Query().Get("errorfile500Path"),
+		Errorfile502Path:     req.URL.Query().Get("errorfile502Path"),
+		Errorfile503Path:     req.URL.Query().Get("errorfile503Path"),
+		ExternalCheckCommand: req.URL.Query().Get("externalCheckCommand"),
 		PathType:             req.URL.Query().Get("pathType"),
 		ReqRepSearch:         req.URL.Query().Get("reqRepSearch"),  // TODO: Deprecated (dec. 2016).
 		ReqRepReplace:        req.URL.Query().Get("reqRepReplace"), // TODO: Deprecated (dec. 2016).
@@ -320,6 +1171,14 @@ func (m *Serve) getService(sd []proxy.ServiceDest, req *http.Request) proxy.Serv
 		TimeoutServer:        req.URL.Query().Get("timeoutServer"),
 		TimeoutTunnel:        req.URL.Query().Get("timeoutTunnel"),
 	}
+	if len(sr.Namespace) > 0 {
+		// The service keeps resolving to the Swarm service while its name in the proxy is scoped to the namespace
+		if len(sr.OutboundHostname) == 0 {
+			sr.OutboundHostname = sr.ServiceName
+		}
+		sr.ServiceName = proxy.GetNamespacedName(sr.Namespace, sr.ServiceName)
+		sr.AclName = proxy.GetNamespacedName(sr.Namespace, sr.AclName)
+	}
 	if len(req.URL.Query().Get("reqMode")) > 0 {
 		sr.ReqMode = req.URL.Query().Get("reqMode")
 	} else {
@@ -330,16 +1189,117 @@ func (m *Serve) getService(sd []proxy.ServiceDest, req *http.Request) proxy.Serv
 	if len(req.URL.Query().Get("httpsPort")) > 0 {
 		sr.HttpsPort, _ = strconv.Atoi(req.URL.Query().Get("httpsPort"))
 	}
+	if len(req.URL.Query().Get("aclPriority")) > 0 {
+		sr.AclPriority, _ = strconv.Atoi(req.URL.Query().Get("aclPriority"))
+	}
+	for key, values := range req.URL.Query() {
+		if strings.HasPrefix(key, "aclPriority.") && len(values[0]) > 0 {
+			if sr.DomainAclPriority == nil {
+				sr.DomainAclPriority = map[string]int{}
+			}
+			sr.DomainAclPriority[strings.TrimPrefix(key, "aclPriority.")], _ = strconv.Atoi(values[0])
+		}
+	}
+	if len(req.URL.Query().Get("bandwidthLimitPerStream")) > 0 {
+		sr.BandwidthLimitPerStream, _ = strconv.Atoi(req.URL.Query().Get("bandwidthLimitPerStream"))
+	}
+	if len(req.URL.Query().Get("bandwidthLimitTotal")) > 0 {
+		sr.BandwidthLimitTotal, _ = strconv.Atoi(req.URL.Query().Get("bandwidthLimitTotal"))
+	}
+	if len(req.URL.Query().Get("maxUrlLength")) > 0 {
+		sr.MaxUrlLength, _ = strconv.Atoi(req.URL.Query().Get("maxUrlLength"))
+	}
+	if len(req.URL.Query().Get("corsMaxAge")) > 0 {
+		sr.CorsMaxAge, _ = strconv.Atoi(req.URL.Query().Get("corsMaxAge"))
+	}
+	if len(req.URL.Query().Get("logSampleRate")) > 0 {
+		sr.LogSampleRate, _ = strconv.Atoi(req.URL.Query().Get("logSampleRate"))
+	}
+	if len(req.URL.Query().Get("loggingEnabled")) > 0 {
+		sr.LoggingDisabled = !m.getBoolParam(req, "loggingEnabled")
+	}
+	if len(req.URL.Query().Get("maxIdleConnections")) > 0 {
+		sr.MaxIdleConnections, _ = strconv.Atoi(req.URL.Query().Get("maxIdleConnections"))
+	}
+	if len(req.URL.Query().Get("mirrorPercentage")) > 0 {
+		sr.MirrorPercentage, _ = strconv.Atoi(req.URL.Query().Get("mirrorPercentage"))
+	}
+	if len(req.URL.Query().Get("staticResponseStatus")) > 0 {
+		sr.StaticResponseStatus, _ = strconv.Atoi(req.URL.Query().Get("staticResponseStatus"))
+	}
+	sr.StaticResponseContentType = req.URL.Query().Get("staticResponseContentType")
+	if len(req.URL.Query().Get("ttlSeconds")) > 0 {
+		sr.TtlSeconds, _ = strconv.Atoi(req.URL.Query().Get("ttlSeconds"))
+	}
 	if len(req.URL.Query().Get("serviceDomain")) > 0 {
 		sr.ServiceDomain = strings.Split(req.URL.Query().Get("serviceDomain"), ",")
 	}
+	for key, values := range req.URL.Query() {
+		if strings.HasPrefix(key, "serviceCert.") && len(values[0]) > 0 {
+			if sr.ServiceCerts == nil {
+				sr.ServiceCerts = map[string]string{}
+			}
+			sr.ServiceCerts[strings.TrimPrefix(key, "serviceCert.")] = values[0]
+		}
+	}
+	if len(req.URL.Query().Get("captureRequestHeaders")) > 0 {
+		sr.CaptureRequestHeaders = strings.Split(req.URL.Query().Get("captureRequestHeaders"), ",")
+	}
+	if len(req.URL.Query().Get("srcNetworks")) > 0 {
+		sr.SrcNetworks = strings.Split(req.URL.Query().Get("srcNetworks"), ",")
+	}
+	if len(req.URL.Query().Get("dbReaders")) > 0 {
+		sr.DbReaders = strings.Split(req.URL.Query().Get("dbReaders"), ",")
+	}
+	if len(req.URL.Query().Get("dbWriters")) > 0 {
+		sr.DbWriters = strings.Split(req.URL.Query().Get("dbWriters"), ",")
+	}
+	if len(req.URL.Query().Get("captureCookies")) > 0 {
+		sr.CaptureCookies = strings.Split(req.URL.Query().Get("captureCookies"), ",")
+	}
+	if len(req.URL.Query().Get("splitGroups")) > 0 {
+		sr.SplitGroups = proxy.ExtractSplitGroupsFromString(req.URL.Query().Get("splitGroups"))
+	}
 	sr.SkipCheck = m.getBoolParam(req, "skipCheck")
+	sr.Critical = m.getBoolParam(req, "critical")
+	// Reconfigure requests do not end a scheduled maintenance that is in progress nor enable a disabled service
+	sr.Maintenance = m.getBoolParam(req, "maintenance") || schedule.IsInMaintenance(sr.ServiceName) || disabledServices.Contains(sr.ServiceName)
+	sr.SendProxyProtocol = m.getBoolParam(req, "sendProxyProtocol")
+	sr.ZoneAware = m.getBoolParam(req, "zoneAware")
 	sr.Distribute = m.getBoolParam(req, "distribute")
 	sr.SslVerifyNone = m.getBoolParam(req, "sslVerifyNone")
+	sr.BackendCaFile = req.URL.Query().Get("backendCaFile")
+	sr.BackendSni = req.URL.Query().Get("backendSni")
+	sr.CanonicalDomain = req.URL.Query().Get("canonicalDomain")
 	sr.ServiceDomainMatchAll = m.getBoolParam(req, "serviceDomainMatchAll")
+	sr.StripPath = m.getBoolParam(req, "stripPath")
+	sr.CorsPreflight = m.getBoolParam(req, "corsPreflight")
+	sr.PathMatchCaseInsensitive = m.getBoolParam(req, "pathMatchCaseInsensitive")
+	sr.NormalizeTrailingSlash = m.getBoolParam(req, "normalizeTrailingSlash")
+	sr.NormalizeUri = m.getBoolParam(req, "normalizeUri")
+	sr.Blocklist = m.getBoolParam(req, "blocklist")
+	sr.MqttStickiness = m.getBoolParam(req, "mqttStickiness")
+	sr.BlocklistIpsPath = req.URL.Query().Get("blocklistIpsPath")
+	sr.BlocklistUserAgentsPath = req.URL.Query().Get("blocklistUserAgentsPath")
+	sr.RewriteResponseUrls = m.getBoolParam(req, "rewriteResponseUrls")
+	sr.Http10Compatibility = m.getBoolParam(req, "http10Compatibility")
+	sr.AcceptInvalidHttpResponse = m.getBoolParam(req, "acceptInvalidHttpResponse")
 
 	globalUsersString := proxy.GetSecretOrEnvVar("USERS", "")
 	globalUsersEncrypted := strings.EqualFold(proxy.GetSecretOrEnvVar("USERS_PASS_ENCRYPTED", ""), "true")
+	if len(sr.ServiceDomain) == 0 {
+		sr.ServiceDomain = m.getPreviewDomain(sr.ServiceName)
+	}
+	if len(sr.SetHostHeader) == 0 && len(req.URL.Query().Get("preserveHost")) > 0 && !m.getBoolParam(req, "preserveHost") {
+		// Send the host the proxy connects to instead of the Host of the request
+		sr.SetHostHeader = sr.GetHosts()[0]
+		if proxy.IsUnixSocket(sr.SetHostHeader) {
+			sr.SetHostHeader = sr.ServiceName
+		} else if strings.Contains(sr.SetHostHeader, ":") {
+			// IPv6 literals are enclosed in brackets in the Host header
+			sr.SetHostHeader = fmt.Sprintf("[%s]", sr.SetHostHeader)
+		}
+	}
 	sr.Users = mergeUsers(sr.ServiceName,
 		req.URL.Query().Get("users"),
 		req.URL.Query().Get("usersSecret"),
@@ -348,6 +1308,212 @@ func (m *Serve) getService(sd []proxy.ServiceDest, req *http.Request) proxy.Serv
 	return sr
 }
 
+// executeReconfigure applies the service unless the same parameters were already applied.
+// Requests that specify a version (through the version query or the If-Match header)
+// are rejected with 412 when the version does not match the current one.
+// If-Match: * requires the service to be configured and If-None-Match: * requires it not to be.
+func (m *Serve) executeReconfigure(w http.ResponseWriter, req *http.Request, response *server.Response, sr proxy.Service) {
+	version, ok := m.writeReconfigure(w, req, response, sr)
+	if !ok {
+		return
+	}
+	// The reload is awaited without the lock so that the reloads of concurrent reconfigurations are coalesced
+	reloader := reload
+	if sr.Critical {
+		reloader = urgentReload
+	}
+	if err := reloader.Execute(false, ""); err != nil {
+		serviceVersions.ClearHash(sr.ServiceName, version)
+		if configErr, ok := err.(*proxy.ConfigError); ok {
+			response.ConfigIssues = configErr.Issues
+		}
+		m.writeInternalServerError(w, response, err.Error())
+		return
+	}
+	w.Header().Set("ETag", version.ETag())
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeReconfigure writes the configuration of the service without reloading the proxy and returns its new version.
+// It returns false if the request was already responded to (e.g. the service is already configured with the same parameters).
+func (m *Serve) writeReconfigure(w http.ResponseWriter, req *http.Request, response *server.Response, sr proxy.Service) (proxy.ServiceVersion, bool) {
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	current := serviceVersions.Get(sr.ServiceName)
+	hash := proxy.GetServiceHash(sr)
+	if err := m.checkVersion(sr.ServiceName, m.getExpectedVersion(req)); err != nil {
+		response.Status = "NOK"
+		response.Message = err.Error()
+		w.Header().Set("ETag", current.ETag())
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return proxy.ServiceVersion{}, false
+	} else if req.Header.Get("If-None-Match") == "*" && current.Version > 0 {
+		response.Status = "NOK"
+		response.Message = fmt.Sprintf("The service %s is already configured", sr.ServiceName)
+		w.Header().Set("ETag", current.ETag())
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return proxy.ServiceVersion{}, false
+	} else if current.Hash == hash && !sr.ZoneAware && m.isServiceConfigured(sr.ServiceName) {
+		// The tasks of zone aware services are resolved when they are configured, so they can change with the same parameters
+		expirations.Refresh(sr.ServiceName, sr.TtlSeconds)
+		response.Message = "The service is already configured with the same parameters"
+		w.Header().Set("ETag", current.ETag())
+		w.WriteHeader(http.StatusOK)
+		return proxy.ServiceVersion{}, false
+	}
+	certName := sr.ServiceName
+	if len(sr.ServiceDomain) > 0 {
+		certName = sr.ServiceDomain[0]
+	}
+	if len(sr.ServiceCert) > 0 {
+		// Replace \n with proper carriage return as new lines are not supported in labels
+		sr.ServiceCert = strings.Replace(sr.ServiceCert, "\\n", "\n", -1)
+		cert.PutCert(certName, []byte(sr.ServiceCert))
+	} else if len(sr.CertSecret) > 0 {
+		path := proxy.GetDockerSecretPath(sr.CertSecret)
+		if !proxy.IsDockerSecretPath(path) {
+			m.writeBadRequest(w, response, fmt.Sprintf("The certificate secret %s is not located in the secrets directory", sr.CertSecret))
+			return proxy.ServiceVersion{}, false
+		}
+		content, err := readCertSecret(path)
+		if err != nil {
+			m.writeBadRequest(w, response, fmt.Sprintf("Could not read the certificate secret %s\n%s", sr.CertSecret, err.Error()))
+			return proxy.ServiceVersion{}, false
+		}
+		cert.PutCert(certName, content)
+	}
+	for domain, domainCert := range sr.ServiceCerts {
+		sr.ServiceCerts[domain] = strings.Replace(domainCert, "\\n", "\n", -1)
+		cert.PutCert(domain, []byte(sr.ServiceCerts[domain]))
+	}
+	// A hung DNS or registry lookup must not keep the client (and the requests queued behind it) waiting indefinitely
+	ctx, cancel := context.WithTimeout(req.Context(), proxy.GetTimeout("RECONFIGURE_TIMEOUT", 60))
+	defer cancel()
+	action := actions.NewReconfigure(m.BaseReconfigure, sr, m.Mode)
+	if err := action.Import(ctx); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			response.Status = "NOK"
+			response.Message = fmt.Sprintf("The service %s could not be configured in time\n%s", sr.ServiceName, err.Error())
+			w.WriteHeader(http.StatusGatewayTimeout)
+		} else {
+			if configErr, ok := err.(*proxy.ConfigError); ok {
+				response.ConfigIssues = configErr.Issues
+			}
+			m.writeInternalServerError(w, response, err.Error())
+		}
+		return proxy.ServiceVersion{}, false
+	}
+	expirations.Refresh(sr.ServiceName, sr.TtlSeconds)
+	serviceParams.Put(sr.ServiceName, m.getParams(req))
+	return serviceVersions.Put(sr.ServiceName, hash), true
+}
+
+// isServiceConfigured returns whether the service is in the configuration of the proxy.
+// A service removed with keepState keeps its version but has to be configured again.
+func (m *Serve) isServiceConfigured(serviceName string) bool {
+	_, found := proxy.Instance.GetServices()[serviceName]
+	return found
+}
+
+func (m *Serve) getExpectedVersion(req *http.Request) string {
+	if version := req.URL.Query().Get("version"); len(version) > 0 {
+		return fmt.Sprintf(`"%s"`, version)
+	}
+	return req.Header.Get("If-Match")
+}
+
+// matchesVersion returns whether the expected version (an ETag or *) matches the current version of the service.
+// The * matches any version of a configured service.
+func (m *Serve) matchesVersion(expected string, current proxy.ServiceVersion) bool {
+	if expected == "*" {
+		return current.Version > 0
+	}
+	return expected == current.ETag()
+}
+
+func (m *Serve) getRemoveOptions(req *http.Request) actions.RemoveOptions {
+	drainTimeout, _ := strconv.Atoi(proxy.GetSecretOrEnvVar("DRAIN_TIMEOUT", "5"))
+	return actions.RemoveOptions{
+		RemoveCerts:  m.getBoolParam(req, "removeCerts"),
+		DrainFirst:   m.getBoolParam(req, "drainFirst"),
+		DrainTimeout: drainTimeout,
+		KeepState:    m.getBoolParam(req, "keepState"),
+	}
+}
+
+// getPreviewDomain returns the preview subdomain of services named after the PREVIEW_SERVICE_SUFFIX
+// (e.g. feature-x.preview.acme.com for feature-x_web). Nil is returned when PREVIEW_DOMAIN is not set.
+func (m *Serve) getPreviewDomain(serviceName string) []string {
+	domain := proxy.GetSecretOrEnvVar("PREVIEW_DOMAIN", "")
+	suffix := proxy.GetSecretOrEnvVar("PREVIEW_SERVICE_SUFFIX", "_web")
+	if len(domain) == 0 || !strings.HasSuffix(serviceName, suffix) || serviceName == suffix {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s.%s", strings.TrimSuffix(serviceName, suffix), domain)}
+}
+
+// preview configures the service named after the subdomain of a request that reached the preview backend
+// and redirects the client to the original URL which is, from now on, served by that service.
+func (m *Serve) preview(w http.ResponseWriter, req *http.Request) {
+	host := strings.ToLower(req.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	domain := "." + strings.ToLower(proxy.GetSecretOrEnvVar("PREVIEW_DOMAIN", ""))
+	subdomain := strings.TrimSuffix(host, domain)
+	response := server.Response{Mode: m.Mode, Status: "OK"}
+	if len(domain) == 1 || !strings.HasSuffix(host, domain) || len(subdomain) == 0 || strings.Contains(subdomain, ".") {
+		response.Status = "NOK"
+		response.Message = fmt.Sprintf("%s is not a preview domain", host)
+		w.WriteHeader(http.StatusNotFound)
+	} else {
+		sr := proxy.Service{
+			ServiceName:   subdomain + proxy.GetSecretOrEnvVar("PREVIEW_SERVICE_SUFFIX", "_web"),
+			ServiceDomain: []string{host},
+			ReqMode:       "http",
+			ServiceDest: []proxy.ServiceDest{{
+				Port:        proxy.GetSecretOrEnvVar("PREVIEW_PORT", "80"),
+				ServicePath: []string{"/"},
+			}},
+		}
+		response.ServiceName = sr.ServiceName
+		response.Service = sr
+		if _, err := lookupHost(sr.ServiceName); err != nil {
+			response.Status = "NOK"
+			response.Message = fmt.Sprintf("The preview service %s does not exist", sr.ServiceName)
+			w.WriteHeader(http.StatusNotFound)
+		} else if err := m.configurePreview(req.Context(), sr); err != nil {
+			m.writeInternalServerError(w, &response, err.Error())
+		} else {
+			location := req.Header.Get("X-Preview-Uri")
+			if !strings.HasPrefix(location, "/") {
+				location = "/"
+			}
+			w.Header().Set("Location", location)
+			w.WriteHeader(http.StatusTemporaryRedirect)
+		}
+	}
+	httpWriterSetContentType(w, "application/json")
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+func (m *Serve) configurePreview(ctx context.Context, sr proxy.Service) error {
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	hash := proxy.GetServiceHash(sr)
+	if serviceVersions.Get(sr.ServiceName).Hash == hash {
+		return nil
+	}
+	logPrintf("Configuring the preview service %s", sr.ServiceName)
+	action := actions.NewReconfigure(m.BaseReconfigure, sr, m.Mode)
+	if err := action.ExecuteContext(ctx); err != nil {
+		return err
+	}
+	serviceVersions.Put(sr.ServiceName, hash)
+	return nil
+}
+
 func (m *Serve) getBoolParam(req *http.Request, param string) bool {
 	value := false
 	if len(req.URL.Query().Get(param)) > 0 {
@@ -362,6 +1528,164 @@ func (m *Serve) writeBadRequest(w http.ResponseWriter, resp *server.Response, ms
 	w.WriteHeader(http.StatusBadRequest)
 }
 
+// hasRejectedConflicts adds the routes of the existing services that overlap with the service to the response.
+// Conflicts are rejected with 409 when ROUTE_CONFLICTS is set to "reject". Otherwise, they are reported as a warning.
+func (m *Serve) hasRejectedConflicts(w http.ResponseWriter, resp *server.Response) bool {
+	resp.Conflicts = proxy.GetConflicts(resp.Service, proxy.Instance.GetServices())
+	if len(resp.Conflicts) == 0 {
+		return false
+	}
+	names := []string{}
+	for _, c := range resp.Conflicts {
+		if len(names) == 0 || names[len(names)-1] != c.ServiceName {
+			names = append(names, c.ServiceName)
+		}
+	}
+	resp.Message = fmt.Sprintf(
+		"Routes of the service %s overlap with routes of the services %s",
+		resp.ServiceName,
+		strings.Join(names, ", "),
+	)
+	if !strings.EqualFold(proxy.GetSecretOrEnvVar("ROUTE_CONFLICTS", "warn"), "reject") {
+		return false
+	}
+	resp.Status = "NOK"
+	w.WriteHeader(http.StatusConflict)
+	return true
+}
+
+// hasNamespaceCollisions rejects services whose routes or domains collide with those of services from other namespaces.
+func (m *Serve) hasNamespaceCollisions(w http.ResponseWriter, resp *server.Response) bool {
+	collisions := proxy.GetNamespaceCollisions(resp.Service, proxy.Instance.GetServices())
+	if len(collisions) == 0 {
+		return false
+	}
+	names := []string{}
+	for _, c := range collisions {
+		names = append(names, c.ServiceName)
+	}
+	resp.Conflicts = collisions
+	resp.Status = "NOK"
+	resp.Message = fmt.Sprintf(
+		"Routes of the service %s collide with routes of the services %s from other namespaces",
+		resp.ServiceName,
+		strings.Join(names, ", "),
+	)
+	w.WriteHeader(http.StatusConflict)
+	return true
+}
+
+// hasAclNameCollision rejects services whose ACL name is already used by another service when ACL_NAME_COLLISIONS is set to reject.
+// Otherwise, the ACL name of the service is suffixed with a hash so that the ACLs of the two services are not merged.
+func (m *Serve) hasAclNameCollision(w http.ResponseWriter, resp *server.Response) bool {
+	existing := proxy.GetAclNameCollision(resp.Service, proxy.Instance.GetServices())
+	if len(existing) == 0 {
+		return false
+	}
+	aclName := proxy.GetAclName(resp.Service)
+	if !strings.EqualFold(proxy.GetSecretOrEnvVar("ACL_NAME_COLLISIONS", "suffix"), "reject") {
+		resp.Service.AclName = proxy.GetUniqueAclName(resp.Service)
+		resp.Warnings = append(resp.Warnings, fmt.Sprintf(
+			"The ACL name %s is already used by the service %s. The ACL name %s is used instead.",
+			aclName,
+			existing,
+			resp.Service.AclName,
+		))
+		return false
+	}
+	resp.Status = "NOK"
+	resp.Message = fmt.Sprintf("The ACL name %s of the service %s is already used by the service %s", aclName, resp.ServiceName, existing)
+	w.WriteHeader(http.StatusConflict)
+	return true
+}
+
+// authorizeNamespace verifies that the token sent in the Authorization header is bound to the namespace of the request.
+// If the request does not specify the namespace, the namespace of the token is used.
+// The names of the request (e.g. serviceName) and the names from the path must not belong to other namespaces.
+// The NAMESPACE_ADMIN_TOKEN gives access to all the namespaces and to the services without a namespace.
+func (m *Serve) authorizeNamespace(w http.ResponseWriter, req *http.Request, names ...string) bool {
+	tokens := proxy.GetNamespaceTokens(proxy.GetSecretOrEnvVar("NAMESPACE_TOKENS", ""))
+	if len(tokens) == 0 {
+		return true
+	}
+	namespace := req.URL.Query().Get("namespace")
+	token := getBearerToken(req)
+	status, msg := http.StatusUnauthorized, "A valid namespace or admin token is required"
+	adminToken := proxy.GetSecretOrEnvVar("NAMESPACE_ADMIN_TOKEN", "")
+	tokenNamespace, found := tokens[token]
+	otherName := m.getNameInOtherNamespace(req, tokenNamespace, tokens, names)
+	switch {
+	case len(adminToken) > 0 && token == adminToken:
+		return true
+	case !found:
+	case len(namespace) > 0 && namespace != tokenNamespace:
+		status, msg = http.StatusForbidden, fmt.Sprintf("The token is not allowed to access the namespace %s", namespace)
+	case len(otherName) > 0:
+		status, msg = http.StatusForbidden, fmt.Sprintf("The token is not allowed to access %s of another namespace", otherName)
+	default:
+		if len(namespace) == 0 {
+			query := req.URL.Query()
+			query.Set("namespace", tokenNamespace)
+			req.URL.RawQuery = query.Encode()
+		}
+		return true
+	}
+	m.writeUnauthorized(w, status, msg)
+	return false
+}
+
+// authorizeAdmin verifies that the NAMESPACE_ADMIN_TOKEN is sent once namespace tokens are configured.
+// It guards the requests that change the proxy as a whole instead of the services of a namespace.
+func (m *Serve) authorizeAdmin(w http.ResponseWriter, req *http.Request) bool {
+	tokens := proxy.GetNamespaceTokens(proxy.GetSecretOrEnvVar("NAMESPACE_TOKENS", ""))
+	if len(tokens) == 0 {
+		return true
+	}
+	token := getBearerToken(req)
+	adminToken := proxy.GetSecretOrEnvVar("NAMESPACE_ADMIN_TOKEN", "")
+	if len(adminToken) > 0 && token == adminToken {
+		return true
+	}
+	if _, found := tokens[token]; found {
+		m.writeUnauthorized(w, http.StatusForbidden, "The request requires the admin token")
+	} else {
+		m.writeUnauthorized(w, http.StatusUnauthorized, "A valid admin token is required")
+	}
+	return false
+}
+
+func (m *Serve) writeUnauthorized(w http.ResponseWriter, status int, msg string) {
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(status)
+	js, _ := json.Marshal(server.Response{Status: "NOK", Message: msg})
+	w.Write(js)
+}
+
+func getBearerToken(req *http.Request) string {
+	if authorization := req.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+		return strings.TrimPrefix(authorization, "Bearer ")
+	}
+	return ""
+}
+
+// getNameInOtherNamespace returns the first of the names and of the names of the request that belongs to a namespace other than the namespace.
+// The namespaces are those the tokens are bound to.
+func (m *Serve) getNameInOtherNamespace(req *http.Request, namespace string, tokens map[string]string, names []string) string {
+	namespaces := []string{}
+	for _, tokenNamespace := range tokens {
+		namespaces = append(namespaces, tokenNamespace)
+	}
+	for _, param := range []string{"serviceName", "aclName", "cloneFrom"} {
+		names = append(names, req.URL.Query().Get(param))
+	}
+	for _, name := range names {
+		if proxy.IsInOtherNamespace(namespace, name, namespaces) {
+			return name
+		}
+	}
+	return ""
+}
+
 func (m *Serve) writeInternalServerError(w http.ResponseWriter, resp *server.Response, msg string) {
 	resp.Status = "NOK"
 	resp.Message = msg
@@ -369,7 +1693,11 @@ func (m *Serve) writeInternalServerError(w http.ResponseWriter, resp *server.Res
 }
 
 func (m *Serve) remove(w http.ResponseWriter, req *http.Request) {
-	serviceName := req.URL.Query().Get("serviceName")
+	if !m.authorizeNamespace(w, req) {
+		return
+	}
+	namespace := req.URL.Query().Get("namespace")
+	serviceName := proxy.GetNamespacedName(namespace, req.URL.Query().Get("serviceName"))
 	distribute := m.getBoolParam(req, "distribute")
 	response := server.Response{
 		Status:      "OK",
@@ -392,19 +1720,18 @@ func (m *Serve) remove(w http.ResponseWriter, req *http.Request) {
 			w.WriteHeader(http.StatusOK)
 		}
 	} else {
-		logPrintf("Processing remove request %s", req.URL.Path)
-		aclName := req.URL.Query().Get("aclName")
-		action := actions.NewRemove(
-			serviceName,
-			aclName,
-			m.BaseReconfigure.ConfigsPath,
-			m.BaseReconfigure.TemplatesPath,
-			m.ConsulAddresses,
-			m.InstanceName,
-			m.Mode,
-		)
-		action.Execute([]string{})
-		w.WriteHeader(http.StatusOK)
+		logRequestf(req, "Processing remove request %s", req.URL.Path)
+		aclName := proxy.GetNamespacedName(namespace, req.URL.Query().Get("aclName"))
+		removed, err := m.drainAndRemoveService(serviceName, aclName, m.getExpectedVersion(req), m.getRemoveOptions(req))
+		if mismatch, ok := err.(versionMismatchError); ok {
+			response.Status = "NOK"
+			response.Message = mismatch.Error()
+			w.Header().Set("ETag", mismatch.current.ETag())
+			w.WriteHeader(http.StatusPreconditionFailed)
+		} else {
+			response.Removed = &removed
+			w.WriteHeader(http.StatusOK)
+		}
 	}
 	httpWriterSetContentType(w, "application/json")
 	js, _ := json.Marshal(response)
@@ -422,6 +1749,602 @@ func (m *Serve) config(w http.ResponseWriter, req *http.Request) {
 	w.Write([]byte(out))
 }
 
+// configDiff outputs the differences between the loaded configuration and the one that would be generated now.
+func (m *Serve) configDiff(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "text/plain")
+	current, err := proxy.Instance.ReadConfig()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("Could not read the current configuration\n%s", err.Error())))
+		return
+	}
+	pending, err := proxy.Instance.RenderConfig()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("Could not render the configuration\n%s", err.Error())))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(proxy.GetUnifiedDiff("current/haproxy.cfg", "pending/haproxy.cfg", current, pending)))
+}
+
+// xdsDiscovery serves the resources requested by Envoy instances that poll the proxy through the REST xDS transport.
+// Requests with the version Envoy already applied get the status 304.
+func (m *Serve) xdsDiscovery(w http.ResponseWriter, req *http.Request) {
+	if proxy.GetEngine() != "envoy" {
+		logWarnf("The endpoint %s is available only when PROXY_ENGINE is set to envoy", req.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	typeUrls := map[string]string{
+		"/v3/discovery:clusters":  proxy.XdsClusterType,
+		"/v3/discovery:routes":    proxy.XdsRouteType,
+		"/v3/discovery:listeners": proxy.XdsListenerType,
+	}
+	discoveryReq := struct {
+		VersionInfo   string   `json:"version_info"`
+		ResourceNames []string `json:"resource_names"`
+	}{}
+	if req.Body != nil {
+		if err := json.NewDecoder(req.Body).Decode(&discoveryReq); err != nil && err != io.EOF {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	resources := proxy.GetXdsResources()
+	if discoveryReq.VersionInfo == resources.Version {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	typeUrl := typeUrls[req.URL.Path]
+	js, _ := json.Marshal(map[string]interface{}{
+		"version_info": resources.Version,
+		"resources":    resources.GetResources(typeUrl, discoveryReq.ResourceNames),
+		"type_url":     typeUrl,
+	})
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+func (m *Serve) getOrphans(w http.ResponseWriter, req *http.Request) {
+	response := map[string]time.Time{}
+	if orphans != nil {
+		response = orphans.GetOrphans()
+	}
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(http.StatusOK)
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+// manageProfiles lists the profiles (GET), adds or replaces the profile with the parameters sent as JSON (PUT),
+// or removes the profile (DELETE). The name of a profile is specified through the name query.
+func (m *Serve) manageProfiles(w http.ResponseWriter, req *http.Request) {
+	if req.Method == "PUT" || req.Method == "DELETE" {
+		if !m.authorizeAdmin(w, req) {
+			return
+		}
+	} else if !m.authorizeNamespace(w, req) {
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	name := req.URL.Query().Get("name")
+	switch req.Method {
+	case "PUT":
+		params := map[string]string{}
+		body, _ := ioutil.ReadAll(req.Body)
+		if err := json.Unmarshal(body, &params); err != nil || len(name) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			js, _ := json.Marshal(server.Response{Status: "NOK", Message: "The name query and a JSON object with the parameters are mandatory"})
+			w.Write(js)
+			return
+		}
+		profiles.Put(name, params)
+	case "DELETE":
+		profiles.Delete(name)
+	}
+	w.WriteHeader(http.StatusOK)
+	js, _ := json.Marshal(profiles.GetAll())
+	w.Write(js)
+}
+
+// manageBlocklist adds entries to and removes them from the global blocklist or the blocklist of a service without reloading the proxy.
+// The global entries require the admin token and the entries of a service require a token of its namespace.
+func (m *Serve) manageBlocklist(w http.ResponseWriter, req *http.Request) {
+	if (req.Method == "PUT" || req.Method == "DELETE") && len(req.URL.Query().Get("serviceName")) == 0 {
+		if !m.authorizeAdmin(w, req) {
+			return
+		}
+	} else if !m.authorizeNamespace(w, req) {
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	if req.Method == "PUT" || req.Method == "DELETE" {
+		serviceName := ""
+		if len(req.URL.Query().Get("serviceName")) > 0 {
+			serviceName = proxy.GetNamespacedName(req.URL.Query().Get("namespace"), req.URL.Query().Get("serviceName"))
+		}
+		entries := proxy.Blocklist{}
+		if len(req.URL.Query().Get("ip")) > 0 {
+			entries.Ips = strings.Split(req.URL.Query().Get("ip"), ",")
+		}
+		if len(req.URL.Query().Get("userAgent")) > 0 {
+			entries.UserAgents = strings.Split(req.URL.Query().Get("userAgent"), ",")
+		}
+		update := addBlocklistEntries
+		if req.Method == "DELETE" {
+			update = removeBlocklistEntries
+		}
+		sr, found := proxy.Instance.GetServices()[serviceName]
+		response := server.Response{ServiceName: serviceName}
+		failed := true
+		if len(entries.Ips) == 0 && len(entries.UserAgents) == 0 {
+			m.writeBadRequest(w, &response, "ip or userAgent parameter is mandatory")
+		} else if len(serviceName) == 0 && !proxy.IsBlocklistEnabled() {
+			m.writeBadRequest(w, &response, "The global blocklist is disabled. Set the BLOCKLIST environment variable to true to enable it")
+		} else if len(serviceName) > 0 && (!found || !sr.HasBlocklist()) {
+			m.writeBadRequest(w, &response, fmt.Sprintf("The service %s is not reconfigured with blocklist", serviceName))
+		} else if err := proxy.ValidateBlocklist(entries); err != nil {
+			m.writeBadRequest(w, &response, err.Error())
+		} else if err := update(m.ConfigsPath, serviceName, entries); err != nil {
+			m.writeInternalServerError(w, &response, err.Error())
+		} else {
+			failed = false
+		}
+		if failed {
+			js, _ := json.Marshal(response)
+			w.Write(js)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	js, _ := json.Marshal(proxy.GetBlocklists())
+	w.Write(js)
+}
+
+// manageFaults injects delays and errors into the requests to a service without reloading the proxy.
+// It is meant for resilience testing and requires the backends to be generated with FAULT_INJECTION.
+func (m *Serve) manageFaults(w http.ResponseWriter, req *http.Request) {
+	if !m.authorizeNamespace(w, req) {
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	if req.Method == "PUT" || req.Method == "DELETE" {
+		serviceName := proxy.GetNamespacedName(req.URL.Query().Get("namespace"), req.URL.Query().Get("serviceName"))
+		fault := proxy.Fault{}
+		if req.Method == "PUT" {
+			fault.AbortPercentage, _ = strconv.Atoi(req.URL.Query().Get("abortPercentage"))
+			fault.Delay, _ = strconv.Atoi(req.URL.Query().Get("delay"))
+		}
+		response := server.Response{ServiceName: serviceName}
+		failed := true
+		if !proxy.IsFaultInjectionEnabled() {
+			m.writeBadRequest(w, &response, "Fault injection is disabled. Set the FAULT_INJECTION environment variable to true to enable it")
+		} else if len(serviceName) == 0 {
+			m.writeBadRequest(w, &response, "serviceName parameter is mandatory")
+		} else if fault.AbortPercentage < 0 || fault.AbortPercentage > 100 || fault.Delay < 0 {
+			m.writeBadRequest(w, &response, "abortPercentage must be between 0 and 100 and delay cannot be negative")
+		} else if err := setFault(m.ConfigsPath, serviceName, fault); err != nil {
+			m.writeInternalServerError(w, &response, err.Error())
+		} else {
+			failed = false
+		}
+		if failed {
+			js, _ := json.Marshal(response)
+			w.Write(js)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	js, _ := json.Marshal(proxy.GetFaults())
+	w.Write(js)
+}
+
+// manageGlobals changes the global settings and reloads the proxy without restarting it.
+// The previous settings are restored if the proxy cannot be reloaded with the new ones.
+func (m *Serve) manageGlobals(w http.ResponseWriter, req *http.Request) {
+	if !m.authorizeAdmin(w, req) {
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	if req.Method == "PUT" {
+		values := map[string]string{}
+		for param := range req.URL.Query() {
+			values[param] = req.URL.Query().Get(param)
+		}
+		reconfigureMu.Lock()
+		defer reconfigureMu.Unlock()
+		previous := proxy.GetGlobalOverrides()
+		response := server.Response{}
+		failed := true
+		if err := proxy.SetGlobals(values); err != nil {
+			m.writeBadRequest(w, &response, err.Error())
+		} else if err := reload.Execute(true, ""); err != nil {
+			// Recreates the configuration file since the running HAProxy still uses the previous settings
+			proxy.ResetGlobals(previous)
+			reload.Execute(true, "")
+			m.writeInternalServerError(w, &response, err.Error())
+		} else {
+			failed = false
+		}
+		if failed {
+			js, _ := json.Marshal(response)
+			w.Write(js)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	js, _ := json.Marshal(proxy.GetGlobals())
+	w.Write(js)
+}
+
+// manageState exports the state of the proxy or imports a state exported by another proxy.
+// Imported services are reconfigured one by one and the services that fail do not prevent the others from being imported.
+func (m *Serve) manageState(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	if req.Method != "PUT" {
+		state := proxy.GetState()
+		for _, sr := range state.Services {
+			if params, found := serviceParams.Get(sr.ServiceName); found {
+				if state.Params == nil {
+					state.Params = map[string]map[string]string{}
+				}
+				state.Params[sr.ServiceName] = params
+			}
+			if disabledServices.Contains(sr.ServiceName) {
+				state.Disabled = append(state.Disabled, sr.ServiceName)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		js, _ := json.Marshal(state)
+		w.Write(js)
+		return
+	}
+	state := proxy.State{}
+	response := server.Response{Status: "OK"}
+	body, _ := ioutil.ReadAll(req.Body)
+	if err := json.Unmarshal(body, &state); err != nil {
+		m.writeBadRequest(w, &response, fmt.Sprintf("The body must be a state exported through GET requests\n%s", err.Error()))
+		js, _ := json.Marshal(response)
+		w.Write(js)
+		return
+	}
+	names := []string{}
+	for _, sr := range state.Services {
+		names = append(names, sr.ServiceName, sr.AclName)
+	}
+	if !m.authorizeNamespace(w, req, names...) {
+		return
+	}
+	// The namespace is set by the token of the namespace
+	namespace := req.URL.Query().Get("namespace")
+	if len(namespace) > 0 && len(state.Globals) > 0 {
+		w.WriteHeader(http.StatusForbidden)
+		response.Status = "NOK"
+		response.Message = fmt.Sprintf("The global settings cannot be imported into the namespace %s", namespace)
+		js, _ := json.Marshal(response)
+		w.Write(js)
+		return
+	}
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	if err := proxy.SetGlobals(state.Globals); err != nil {
+		m.writeBadRequest(w, &response, err.Error())
+		js, _ := json.Marshal(response)
+		w.Write(js)
+		return
+	}
+	failed := []string{}
+	disabled := map[string]bool{}
+	for _, name := range state.Disabled {
+		disabled[name] = true
+	}
+	release := actions.PrefetchHosts(req.Context(), state.Services, m.Mode)
+	defer release()
+	for _, sr := range state.Services {
+		if msg := m.getImportError(&sr, namespace); len(msg) > 0 {
+			logWarnf("Could not import the service %s\n%s", sr.ServiceName, msg)
+			failed = append(failed, sr.ServiceName)
+			continue
+		}
+		// The services are written one by one and served by a single reload
+		action := actions.NewReconfigure(m.BaseReconfigure, sr, m.Mode)
+		if err := action.Import(req.Context()); err != nil {
+			logWarnf("Could not import the service %s\n%s", sr.ServiceName, err.Error())
+			failed = append(failed, sr.ServiceName)
+			continue
+		}
+		expirations.Refresh(sr.ServiceName, sr.TtlSeconds)
+		serviceVersions.Put(sr.ServiceName, proxy.GetServiceHash(sr))
+		if params, found := state.Params[sr.ServiceName]; found {
+			serviceParams.Put(sr.ServiceName, params)
+		}
+		if disabled[sr.ServiceName] {
+			disabledServices.Add(sr.ServiceName)
+		} else {
+			disabledServices.Delete(sr.ServiceName)
+		}
+	}
+	if len(failed) < len(state.Services) {
+		if err := reload.Execute(false, ""); err != nil {
+			m.writeInternalServerError(w, &response, fmt.Sprintf("Could not reload the proxy after importing the services\n%s", err.Error()))
+			js, _ := json.Marshal(response)
+			w.Write(js)
+			return
+		}
+	}
+	messages := []string{fmt.Sprintf("Imported %d of %d services", len(state.Services)-len(failed), len(state.Services))}
+	if len(failed) > 0 {
+		messages = append(messages, fmt.Sprintf("Could not import the services %s", strings.Join(failed, ", ")))
+	}
+	if missing := proxy.GetMissingCerts(state); len(missing) > 0 {
+		messages = append(messages, fmt.Sprintf("The certificates %s need to be added to the proxy", strings.Join(missing, ", ")))
+	}
+	response.Message = strings.Join(messages, ". ")
+	if len(failed) > 0 {
+		m.writeInternalServerError(w, &response, response.Message)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+// getImportError returns why the imported service is rejected by the same checks as the reconfigure requests.
+// A namespace restricts the import to the services of the namespace.
+func (m *Serve) getImportError(sr *proxy.Service, namespace string) string {
+	if len(namespace) > 0 && (sr.Namespace != namespace || !strings.HasPrefix(sr.ServiceName, namespace+".")) {
+		return fmt.Sprintf("The service does not belong to the namespace %s", namespace)
+	}
+	if ok, msg := m.isValidReconf(sr); !ok {
+		return msg
+	}
+	return m.getValidationMessage(append(m.getModeValidationErrors(*sr), proxy.ValidateService(*sr)...))
+}
+
+// manageSchedule schedules maintenance windows and color switches of services.
+func (m *Serve) manageSchedule(w http.ResponseWriter, req *http.Request) {
+	if !m.authorizeNamespace(w, req) {
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	namespace := req.URL.Query().Get("namespace")
+	switch req.Method {
+	case "PUT":
+		action, err := m.getScheduledAction(req)
+		if err == nil {
+			action, err = schedule.Put(action)
+		}
+		if err != nil {
+			response := server.Response{ServiceName: action.ServiceName}
+			m.writeBadRequest(w, &response, err.Error())
+			js, _ := json.Marshal(response)
+			w.Write(js)
+			return
+		}
+	case "DELETE":
+		id := req.URL.Query().Get("id")
+		for _, action := range schedule.GetAll() {
+			if action.ID == id && !isInNamespace(namespace, action.ServiceName) {
+				m.writeUnauthorized(w, http.StatusForbidden, fmt.Sprintf("The token is not allowed to access %s of another namespace", action.ServiceName))
+				return
+			}
+		}
+		schedule.Delete(id)
+	}
+	actions := []proxy.ScheduledAction{}
+	for _, action := range schedule.GetAll() {
+		if isInNamespace(namespace, action.ServiceName) {
+			actions = append(actions, action)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	js, _ := json.Marshal(actions)
+	w.Write(js)
+}
+
+// isInNamespace returns whether the name belongs to the namespace. All the names belong to the empty namespace of the admin token.
+func isInNamespace(namespace, name string) bool {
+	return len(namespace) == 0 || strings.HasPrefix(name, namespace+".")
+}
+
+func (m *Serve) getScheduledAction(req *http.Request) (proxy.ScheduledAction, error) {
+	action := proxy.ScheduledAction{
+		ServiceName:  proxy.GetNamespacedName(req.URL.Query().Get("namespace"), req.URL.Query().Get("serviceName")),
+		Type:         req.URL.Query().Get("action"),
+		ServiceColor: req.URL.Query().Get("serviceColor"),
+	}
+	for param, t := range map[string]*time.Time{"start": &action.Start, "end": &action.End} {
+		if value := req.URL.Query().Get(param); len(value) > 0 {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return action, fmt.Errorf("The %s parameter must be formatted as RFC 3339 (e.g. 2017-06-01T02:00:00Z)", param)
+			}
+			*t = parsed
+		}
+	}
+	return action, nil
+}
+
+// backendsHealth responds with 200 only if the required percentage (BACKENDS_HEALTHY_PERCENTAGE) of the critical services is healthy.
+// External load balancers can use it to stop sending traffic to a proxy that cannot reach the services.
+func (m *Serve) backendsHealth(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	critical := []string{}
+	all := []string{}
+	for name, s := range proxy.Instance.GetServices() {
+		all = append(all, name)
+		if s.Critical {
+			critical = append(critical, name)
+		}
+	}
+	if len(critical) == 0 {
+		critical = all
+	}
+	percentage, err := strconv.ParseFloat(proxy.GetSecretOrEnvVar("BACKENDS_HEALTHY_PERCENTAGE", "100"), 64)
+	if err != nil {
+		percentage = 100
+	}
+	health, err := getBackendsHealth(critical, percentage)
+	if err != nil {
+		logWarnf("%s", err.Error())
+		w.WriteHeader(http.StatusServiceUnavailable)
+		js, _ := json.Marshal(server.Response{Status: "NOK", Message: err.Error()})
+		w.Write(js)
+		return
+	} else if health.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	js, _ := json.Marshal(health)
+	w.Write(js)
+}
+
+// capture enables or disables the capture of the headers and cookies of a service without reloading the proxy.
+func (m *Serve) capture(w http.ResponseWriter, req *http.Request) {
+	if !m.authorizeNamespace(w, req) {
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	serviceName := proxy.GetNamespacedName(req.URL.Query().Get("namespace"), req.URL.Query().Get("serviceName"))
+	response := server.Response{Status: "OK", ServiceName: serviceName}
+	if len(serviceName) == 0 || len(req.URL.Query().Get("enabled")) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Status = "NOK"
+		response.Message = "serviceName and enabled parameters are mandatory"
+	} else if err := setCaptureEnabled(m.ConfigsPath, serviceName, m.getBoolParam(req, "enabled")); err != nil {
+		m.writeInternalServerError(w, &response, err.Error())
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+// stats returns the sessions, rates, errors, and server health of the backends of a service.
+func (m *Serve) stats(w http.ResponseWriter, req *http.Request) {
+	if !m.authorizeNamespace(w, req) {
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	serviceName := proxy.GetNamespacedName(req.URL.Query().Get("namespace"), req.URL.Query().Get("serviceName"))
+	if len(serviceName) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		js, _ := json.Marshal(server.Response{Status: "NOK", Message: "serviceName parameter is mandatory"})
+		w.Write(js)
+		return
+	}
+	stats, err := getServiceStats(serviceName)
+	if err != nil {
+		response := server.Response{ServiceName: serviceName}
+		m.writeInternalServerError(w, &response, err.Error())
+		js, _ := json.Marshal(response)
+		w.Write(js)
+		return
+	} else if len(stats.Backends) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		js, _ := json.Marshal(server.Response{Status: "NOK", ServiceName: serviceName, Message: fmt.Sprintf("The service %s does not have any backends", serviceName)})
+		w.Write(js)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	js, _ := json.Marshal(stats)
+	w.Write(js)
+}
+
+func (m *Serve) status(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(http.StatusOK)
+	js, _ := json.Marshal(proxy.GetStatus())
+	w.Write(js)
+}
+
+func (m *Serve) metrics(w http.ResponseWriter, req *http.Request) {
+	st := proxy.GetStatus()
+	timestamp := func(t time.Time) float64 {
+		if t.IsZero() {
+			return 0
+		}
+		return float64(t.UnixNano()) / float64(time.Second)
+	}
+	// Until the first reload, there is no successful reload to report
+	lastReloadSuccess := 0.0
+	if st.ReloadCount > 0 && len(st.LastReloadError) == 0 {
+		lastReloadSuccess = 1
+	}
+	metrics := []struct {
+		name, help, metricType string
+		value                  float64
+	}{
+		{"dfp_reloads_total", "The number of proxy reloads.", "counter", float64(st.ReloadCount)},
+		{"dfp_reload_failures_total", "The number of failed proxy reloads.", "counter", float64(st.ReloadFailureCount)},
+		{"dfp_last_reload_success", "Whether the last reload succeeded.", "gauge", lastReloadSuccess},
+		{"dfp_last_reload_duration_seconds", "The duration of the last reload.", "gauge", st.LastReloadDuration},
+		{"dfp_last_reload_timestamp_seconds", "The time of the last reload.", "gauge", timestamp(st.LastReloadTime)},
+		{"dfp_last_config_timestamp_seconds", "The time when the configuration was generated.", "gauge", timestamp(st.LastConfigTime)},
+	}
+	out := ""
+	for _, metric := range metrics {
+		out += fmt.Sprintf(
+			"# HELP %s %s\n# TYPE %s %s\n%s %s\n",
+			metric.name,
+			metric.help,
+			metric.name,
+			metric.metricType,
+			metric.name,
+			strconv.FormatFloat(metric.value, 'g', -1, 64),
+		)
+	}
+	if proxy.GetEngine() == "haproxy" {
+		out += m.getBackendMetrics()
+		if logMetrics != nil {
+			out += m.getLogMetrics(logMetrics)
+		}
+	}
+	httpWriterSetContentType(w, "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(out))
+}
+
+// preflight verifies that the proxy can start.
+// Depending on PREFLIGHT_POLICY, failed checks stop the proxy (fail), are only logged (warn), or are not run at all (skip).
+func (m *Serve) preflight() error {
+	policy := strings.ToLower(proxy.GetSecretOrEnvVar("PREFLIGHT_POLICY", "fail"))
+	if policy == "skip" {
+		return nil
+	}
+	ports := []string{}
+	if proxy.GetEngine() != "envoy" {
+		ports = proxy.GetPreflightPorts()
+	}
+	if len(m.Port) > 0 {
+		ports = append(ports, m.Port)
+	}
+	errs := runPreflightChecks(proxy.PreflightOptions{
+		ConsulAddresses: m.ConsulAddresses,
+		Engine:          proxy.GetEngine(),
+		CertsPath:       "/certs",
+		TemplatesPath:   m.TemplatesPath,
+		Ports:           ports,
+	})
+	if len(errs) == 0 {
+		return nil
+	}
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	if policy == "warn" {
+		for _, msg := range messages {
+			logWarnf("%s", msg)
+		}
+		return nil
+	}
+	return fmt.Errorf("Pre-flight checks failed. Set PREFLIGHT_POLICY to warn to start the proxy regardless.\n%s", strings.Join(messages, "\n"))
+}
+
 func (m *Serve) setConsulAddresses() {
 	m.ConsulAddresses = []string{}
 	if len(os.Getenv("CONSUL_ADDRESS")) > 0 {
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"sync"
 
+	"../actions"
 	"../proxy"
 )
 
@@ -81,8 +82,8 @@ func (m *Cert) Put(w http.ResponseWriter, req *http.Request) (string, error) {
 		return "", err
 	}
 
-	proxy.Instance.CreateConfigFromTemplates()
-	proxy.Instance.Reload()
+	reload := actions.Reload{Priority: proxy.ReloadUrgent}
+	reload.Execute(true, "")
 
 	msg := CertResponse{Status: "OK", Message: ""}
 	m.writeOK(w, msg)
@@ -118,8 +119,8 @@ func (m *Cert) Init() error {
 			for _, cert := range certs {
 				m.writeFile(cert.ProxyServiceName, []byte(cert.CertContent))
 			}
-			proxy.Instance.CreateConfigFromTemplates()
-			proxy.Instance.Reload()
+			reload := actions.Reload{Priority: proxy.ReloadUrgent}
+			reload.Execute(true, "")
 		}
 	}
 	return nil
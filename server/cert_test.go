@@ -687,6 +687,11 @@ func (m *ProxyMock) ReadConfig() (string, error) {
 	return params.String(0), params.Error(1)
 }
 
+func (m *ProxyMock) RenderConfig() (string, error) {
+	params := m.Called()
+	return params.String(0), params.Error(1)
+}
+
 func (m *ProxyMock) Reload() error {
 	params := m.Called()
 	return params.Error(0)
@@ -714,6 +719,12 @@ func (m *ProxyMock) GetCertPaths() []string {
 	return params.Get(0).([]string)
 }
 
+func (m *ProxyMock) GetServices() map[string]proxy.Service {
+	params := m.Called()
+	return params.Get(0).(map[string]proxy.Service)
+}
+
+
 func getProxyMock(skipMethod string) *ProxyMock {
 	mockObj := new(ProxyMock)
 	if skipMethod != "RunCmd" {
@@ -725,6 +736,9 @@ func getProxyMock(skipMethod string) *ProxyMock {
 	if skipMethod != "ReadConfig" {
 		mockObj.On("ReadConfig").Return("", nil)
 	}
+	if skipMethod != "RenderConfig" {
+		mockObj.On("RenderConfig").Return("", nil)
+	}
 	if skipMethod != "Reload" {
 		mockObj.On("Reload").Return(nil)
 	}
@@ -743,5 +757,8 @@ func getProxyMock(skipMethod string) *ProxyMock {
 	if skipMethod != "GetCertPaths" {
 		mockObj.On("GetCertPaths")
 	}
+	if skipMethod != "GetServices" {
+		mockObj.On("GetServices").Return(map[string]proxy.Service{})
+	}
 	return mockObj
 }
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"../actions"
 	"../proxy"
 	"fmt"
 	"io/ioutil"
@@ -25,29 +26,53 @@ type Response struct {
 	Status      string
 	Message     string
 	ServiceName string
+	Conflicts   []proxy.Conflict
+	Warnings    []string
+	Errors      []proxy.ValidationError
+	// The alerts HAProxy reported when it rejected the configuration, attributed to the services that generated them.
+	ConfigIssues []proxy.ConfigIssue `json:",omitempty"`
+	Removed      *actions.Removed
 	proxy.Service
 }
 
+type RenderResponse struct {
+	Status      string
+	Message     string
+	ServiceName string
+	Frontend    string
+	Backend     string
+	Errors      []proxy.ValidationError
+}
+
+// SendDistributeRequests sends the request to all the instances of the proxy.
+// The requests are canceled together with the original request and each of them is limited to DISTRIBUTE_TIMEOUT.
 func (m *Serve) SendDistributeRequests(req *http.Request, port, proxyServiceName string) (status int, err error) {
+	ctx := req.Context()
 	values := req.URL.Query()
 	values.Set("distribute", "false")
 	req.URL.RawQuery = values.Encode()
 	dns := fmt.Sprintf("tasks.%s", proxyServiceName)
 	failedDns := []string{}
 	method := req.Method
+	// Namespace tokens need to reach the other instances as well
+	authorization := req.Header.Get("Authorization")
 	body := ""
 	if req.Body != nil {
 		defer func() { req.Body.Close() }()
 		reqBody, _ := ioutil.ReadAll(req.Body)
 		body = string(reqBody)
 	}
-	if ips, err := lookupHost(dns); err == nil {
+	if ips, err := proxy.LookupHost(ctx, lookupHost, dns); err == nil {
+		client := &http.Client{Timeout: proxy.GetTimeout("DISTRIBUTE_TIMEOUT", 10)}
 		for i := 0; i < len(ips); i++ {
 			req.URL.Host = fmt.Sprintf("%s:%s", ips[i], port)
-			client := &http.Client{}
 			addr := fmt.Sprintf("http://%s:%s%s?%s", ips[i], port, req.URL.Path, req.URL.RawQuery)
 			logPrintf("Sending distribution request to %s", addr)
 			req, _ := http.NewRequest(method, addr, strings.NewReader(body))
+			req = req.WithContext(ctx)
+			if len(authorization) > 0 {
+				req.Header.Set("Authorization", authorization)
+			}
 			if resp, err := client.Do(req); err != nil || resp.StatusCode >= 300 {
 				failedDns = append(failedDns, ips[i])
 			}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -108,6 +109,26 @@ func (s *ServerTestSuite) Test_SednDistributeRequests_ReturnsError_WhenLookupHos
 	s.Assertions.Error(err)
 }
 
+func (s *ServerTestSuite) Test_SendDistributeRequests_ReturnsError_WhenRequestIsCanceledDuringLookup() {
+	lookupHostOrig := lookupHost
+	defer func() { lookupHost = lookupHostOrig }()
+	done := make(chan struct{})
+	defer close(done)
+	lookupHost = func(host string) (addrs []string, err error) {
+		<-done
+		return []string{}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+
+	srv := Serve{}
+	status, err := srv.SendDistributeRequests(req.WithContext(ctx), "8080", s.ServiceName)
+
+	s.Equal(http.StatusBadRequest, status)
+	s.Error(err)
+}
+
 func (s *ServerTestSuite) Test_SendDistributeRequests_SendsHttpRequestForEachIp() {
 	var actualPath string
 	var actualQuery url.Values
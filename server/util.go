@@ -1,7 +1,7 @@
 package server
 
 import (
-	"log"
+	"../logging"
 	"net"
 	"net/http"
 )
@@ -9,5 +9,5 @@ import (
 var httpWriterSetContentType = func(w http.ResponseWriter, value string) {
 	w.Header().Set("Content-Type", value)
 }
-var logPrintf = log.Printf
+var logPrintf = logging.Infof
 var lookupHost = net.LookupHost
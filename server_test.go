@@ -3,12 +3,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"./actions"
@@ -70,12 +73,23 @@ func (s *ServerTestSuite) SetupTest() {
 	s.CertsUrl = fmt.Sprintf("%s/certs", s.BaseUrl)
 	s.ConfigUrl = "/v1/docker-flow-proxy/config"
 	s.ResponseWriter = getResponseWriterMock()
+	serviceVersions = proxy.NewServiceVersions()
+	profiles = proxy.NewProfiles()
+	serviceParams = proxy.NewProfiles()
+	expirations = proxy.NewExpirations()
+	disabledServices = proxy.NewDisabledServices()
 	s.RequestReconfigure, _ = http.NewRequest("GET", s.ReconfigureUrl, nil)
 	s.RequestRemove, _ = http.NewRequest("GET", s.RemoveUrl, nil)
 	usersBasePath = "./test_configs/%s.txt"
 	httpListenAndServe = func(addr string, handler http.Handler) error {
 		return nil
 	}
+	runPreflightChecks = func(options proxy.PreflightOptions) []error {
+		return []error{}
+	}
+	detectHaProxyVersion = func() (proxy.HaProxyVersion, error) {
+		return proxy.HaProxyVersion{}, fmt.Errorf("haproxy is not installed")
+	}
 	serverImpl = Serve{
 		BaseReconfigure: actions.BaseReconfigure{
 			ConsulAddresses: []string{s.ConsulAddress},
@@ -85,6 +99,8 @@ func (s *ServerTestSuite) SetupTest() {
 	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
 		return getReconfigureMock("")
 	}
+	reload = ReloadMock{ExecuteMock: func(recreate bool, listenerAddr string) error { return nil }}
+	urgentReload = reload
 	logPrintfOrig := logPrintf
 	defer func() { logPrintf = logPrintfOrig }()
 	logPrintf = func(format string, v ...interface{}) {}
@@ -187,6 +203,11 @@ func (s *ServerTestSuite) Test_Execute_InvokesReloadAllServicesWithListenerAddre
 	}()
 	os.Setenv("CONSUL_ADDRESS", s.ConsulAddress)
 	serverImpl.ListenerAddress = listenerAddress
+	getListenerServicesOrig := getListenerServices
+	defer func() { getListenerServices = getListenerServicesOrig }()
+	getListenerServices = func(listenerAddr string) ([]string, error) {
+		return []string{}, nil
+	}
 
 	serverImpl.Execute([]string{})
 
@@ -200,6 +221,60 @@ func (s *ServerTestSuite) Test_Execute_InvokesReloadAllServicesWithListenerAddre
 	)
 }
 
+func (s *ServerTestSuite) Test_Execute_InvokesReloadAllServicesWithEachListenerAddress() {
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	getListenerServicesOrig := getListenerServices
+	defer func() { getListenerServices = getListenerServicesOrig }()
+	getListenerServices = func(listenerAddr string) ([]string, error) {
+		return []string{}, nil
+	}
+	serverImpl.ListenerAddress = "listener-stack-1, http://listener-stack-2:9090"
+
+	serverImpl.Execute([]string{})
+
+	mockObj.AssertCalled(s.T(), "ReloadAllServices", mock.Anything, s.InstanceName, "", "http://listener-stack-1:8080")
+	mockObj.AssertCalled(s.T(), "ReloadAllServices", mock.Anything, s.InstanceName, "", "http://listener-stack-2:9090")
+}
+
+func (s *ServerTestSuite) Test_Execute_InvokesReloadAllServicesWithOtherListeners_WhenListenerCannotBeReached() {
+	mockObj := getReconfigureMock("ReloadAllServices")
+	mockObj.On("ReloadAllServices", mock.Anything, mock.Anything, mock.Anything, "http://listener-stack-1:8080").Return(fmt.Errorf("This is an error"))
+	mockObj.On("ReloadAllServices", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	getListenerServicesOrig := getListenerServices
+	defer func() { getListenerServices = getListenerServicesOrig }()
+	getListenerServices = func(listenerAddr string) ([]string, error) {
+		return []string{}, nil
+	}
+	defer func() { os.Unsetenv("LISTENER_RETRY_TIMEOUT") }()
+	os.Setenv("LISTENER_RETRY_TIMEOUT", "0")
+	serverImpl.ListenerAddress = "listener-stack-1,listener-stack-2"
+
+	err := serverImpl.Execute([]string{})
+
+	s.NoError(err)
+	mockObj.AssertCalled(s.T(), "ReloadAllServices", mock.Anything, s.InstanceName, "", "http://listener-stack-2:8080")
+}
+
+func (s *ServerTestSuite) Test_Execute_InvokesReloadAllServicesWithRemoteListenerAddresses() {
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	defer func() { os.Unsetenv("REMOTE_LISTENER_ADDRESSES") }()
+	os.Setenv("REMOTE_LISTENER_ADDRESSES", "listener.cluster-2.com,http://listener.cluster-3.com:9090")
+
+	serverImpl.Execute([]string{})
+
+	mockObj.AssertCalled(s.T(), "ReloadAllServices", []string{}, s.InstanceName, "", "http://listener.cluster-2.com:8080")
+	mockObj.AssertCalled(s.T(), "ReloadAllServices", []string{}, s.InstanceName, "", "http://listener.cluster-3.com:9090")
+}
+
 func (s *ServerTestSuite) Test_Execute_DoesNotInvokeReloadAllServices_WhenModeIsService() {
 	serverImpl.Mode = "seRviCe"
 	mockObj := getReconfigureMock("")
@@ -236,6 +311,76 @@ func (s *ServerTestSuite) Test_Execute_ReturnsError_WhenReloadAllServicesFails()
 	s.Error(actual)
 }
 
+func (s *ServerTestSuite) Test_Execute_RunsPreflightChecks() {
+	actual := proxy.PreflightOptions{}
+	runPreflightChecks = func(options proxy.PreflightOptions) []error {
+		actual = options
+		return []error{}
+	}
+	defer func() { os.Unsetenv("CONSUL_ADDRESS") }()
+	os.Setenv("CONSUL_ADDRESS", s.ConsulAddress)
+	srv := Serve{Port: "1234"}
+	srv.TemplatesPath = "/cfg/tmpl"
+
+	srv.Execute([]string{})
+
+	s.Equal([]string{s.ConsulAddress}, actual.ConsulAddresses)
+	s.Equal("/cfg/tmpl", actual.TemplatesPath)
+	s.Equal("/certs", actual.CertsPath)
+	s.Equal([]string{"80", "443", "1234"}, actual.Ports)
+}
+
+func (s *ServerTestSuite) Test_Execute_ReturnsError_WhenPreflightChecksFail() {
+	runPreflightChecks = func(options proxy.PreflightOptions) []error {
+		return []error{fmt.Errorf("The port 80 is already in use")}
+	}
+	orig := NewRun
+	defer func() { NewRun = orig }()
+	mockObj := getRunMock("")
+	NewRun = func() Executable {
+		return mockObj
+	}
+
+	actual := serverImpl.Execute([]string{})
+
+	s.Error(actual)
+	s.Contains(actual.Error(), "The port 80 is already in use")
+	mockObj.AssertNotCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_Execute_LogsPreflightErrors_WhenPolicyIsWarn() {
+	defer func() { os.Unsetenv("PREFLIGHT_POLICY") }()
+	os.Setenv("PREFLIGHT_POLICY", "warn")
+	runPreflightChecks = func(options proxy.PreflightOptions) []error {
+		return []error{fmt.Errorf("The port 80 is already in use")}
+	}
+	logWarnfOrig := logWarnf
+	defer func() { logWarnf = logWarnfOrig }()
+	actual := []string{}
+	logWarnf = func(format string, v ...interface{}) {
+		actual = append(actual, fmt.Sprintf(format, v...))
+	}
+
+	err := serverImpl.Execute([]string{})
+
+	s.NoError(err)
+	s.Contains(actual, "The port 80 is already in use")
+}
+
+func (s *ServerTestSuite) Test_Execute_DoesNotRunPreflightChecks_WhenPolicyIsSkip() {
+	defer func() { os.Unsetenv("PREFLIGHT_POLICY") }()
+	os.Setenv("PREFLIGHT_POLICY", "skip")
+	invoked := false
+	runPreflightChecks = func(options proxy.PreflightOptions) []error {
+		invoked = true
+		return []error{}
+	}
+
+	serverImpl.Execute([]string{})
+
+	s.False(invoked)
+}
+
 func (s *ServerTestSuite) Test_Execute_SetsConsulAddressesToEmptySlice_WhenEnvVarIsNotset() {
 	srv := Serve{}
 
@@ -420,7 +565,69 @@ func (s *ServerTestSuite) Test_ServeHTTP_InvokesReloadWithParams() {
 	srv.ServeHTTP(s.ResponseWriter, req)
 
 	s.True(actualRecreate)
-	s.Equal(srv.ListenerAddress, actualListenerAddress)
+	s.Equal("http://listener-addr:8080", actualListenerAddress)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReloadWithEachListenerAddress() {
+	actualListenerAddresses := []string{}
+	reloadOrig := reload
+	defer func() { reload = reloadOrig }()
+	reload = ReloadMock{
+		ExecuteMock: func(recreate bool, listenerAddr string) error {
+			actualListenerAddresses = append(actualListenerAddresses, listenerAddr)
+			return nil
+		},
+	}
+	addr := fmt.Sprintf("%s/reload?fromListener=true", s.BaseUrl)
+	req, _ := http.NewRequest("GET", addr, nil)
+
+	srv := Serve{}
+	srv.ListenerAddress = "listener-1,listener-2"
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal([]string{"http://listener-1:8080", "http://listener-2:8080"}, actualListenerAddresses)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReloadWithListenerAddress_WhenFromListenerIsConfiguredAddress() {
+	actualListenerAddresses := []string{}
+	reloadOrig := reload
+	defer func() { reload = reloadOrig }()
+	reload = ReloadMock{
+		ExecuteMock: func(recreate bool, listenerAddr string) error {
+			actualListenerAddresses = append(actualListenerAddresses, listenerAddr)
+			return nil
+		},
+	}
+	addr := fmt.Sprintf("%s/reload?fromListener=listener-2", s.BaseUrl)
+	req, _ := http.NewRequest("GET", addr, nil)
+
+	srv := Serve{}
+	srv.ListenerAddress = "listener-1,listener-2"
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal([]string{"http://listener-2:8080"}, actualListenerAddresses)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenFromListenerIsNotConfiguredAddress() {
+	invoked := false
+	reloadOrig := reload
+	defer func() { reload = reloadOrig }()
+	reload = ReloadMock{
+		ExecuteMock: func(recreate bool, listenerAddr string) error {
+			invoked = true
+			return nil
+		},
+	}
+	addr := fmt.Sprintf("%s/reload?fromListener=http://169.254.169.254", s.BaseUrl)
+	req, _ := http.NewRequest("GET", addr, nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ListenerAddress = "listener-1"
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusBadRequest, rw.Code)
+	s.False(invoked)
 }
 
 func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus200_WhenUrlIsReload() {
@@ -499,16 +706,19 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJSON_WhenUrlIsReconfigure() {
 func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJSONWithAllPortsAndPaths() {
 	sd := []proxy.ServiceDest{
 		{
+			Index:       1,
 			ServicePath: []string{"/path/to/my-service"},
 			Port:        "1111",
 			SrcPort:     2222,
 		},
 		{
+			Index:       2,
 			ServicePath: []string{"/path/to/my-service-1"},
 			Port:        "3333",
 			SrcPort:     4444,
 		},
 		{
+			Index:       3,
 			ServicePath: []string{"/path/to/my-service-2"},
 			Port:        "4444",
 		},
@@ -898,19 +1108,22 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonTimeoutTunnel_WhenPresent()
 	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonSslVerifyNone_WhenPresent() {
-	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&sslVerifyNone=true", nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithServiceDestTimeouts_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath.1=/api&port.1=1234&timeoutServer.1=30&timeoutTunnel.1=3600", nil)
 	expected, _ := json.Marshal(server.Response{
 		Status:      "OK",
-		ServiceName: s.ServiceName,
+		ServiceName: "my-service",
 		Service: proxy.Service{
-			ServiceName:      s.ServiceName,
-			ReqMode:          "http",
-			ServiceColor:     s.ServiceColor,
-			ServiceDomain:    s.ServiceDomain,
-			OutboundHostname: s.OutboundHostname,
-			ServiceDest:      []proxy.ServiceDest{s.sd},
-			SslVerifyNone:    true,
+			ServiceName: "my-service",
+			ReqMode:     "http",
+			PathType:    s.PathType,
+			ServiceDest: []proxy.ServiceDest{{
+				Index:         1,
+				ServicePath:   []string{"/api"},
+				Port:          "1234",
+				TimeoutServer: "30",
+				TimeoutTunnel: "3600",
+			}},
 		},
 	})
 
@@ -920,19 +1133,22 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonSslVerifyNone_WhenPresent()
 	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithServiceDomainMatchAll_WhenPresent() {
-	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&serviceDomainMatchAll=true", nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithServiceDestAlpn_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&reqMode=sni&servicePath.1=acme.com&port.1=1234&srcPort.1=443&alpn.1=h2,grpc-exp", nil)
 	expected, _ := json.Marshal(server.Response{
 		Status:      "OK",
-		ServiceName: s.ServiceName,
+		ServiceName: "my-service",
 		Service: proxy.Service{
-			ServiceName:           s.ServiceName,
-			ReqMode:               "http",
-			ServiceColor:          s.ServiceColor,
-			ServiceDomain:         s.ServiceDomain,
-			OutboundHostname:      s.OutboundHostname,
-			ServiceDest:           []proxy.ServiceDest{s.sd},
-			ServiceDomainMatchAll: true,
+			ServiceName: "my-service",
+			ReqMode:     "sni",
+			PathType:    s.PathType,
+			ServiceDest: []proxy.ServiceDest{{
+				Index:       1,
+				ServicePath: []string{"acme.com"},
+				Port:        "1234",
+				SrcPort:     443,
+				Alpn:        []string{"h2", "grpc-exp"},
+			}},
 		},
 	})
 
@@ -942,50 +1158,74 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithServiceDomainMatchAll_Wh
 	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_WritesErrorHeader_WhenReconfigureDistributeIsTrueAndError() {
-	serve := Serve{}
-	serve.Port = s.ServiceDest[0].Port
-	addr := fmt.Sprintf("http://127.0.0.1:8080%s&distribute=true&returnError=true", s.ReconfigureUrl)
-	req, _ := http.NewRequest("GET", addr, nil)
-
-	serve.ServeHTTP(s.ResponseWriter, req)
-
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
-}
-
-func (s *ServerTestSuite) Test_ServeHTTP_WritesErrorHeader_WhenRemoveDistributeIsTrueAndError() {
-	serve := Serve{}
-	serve.Port = s.ServiceDest[0].Port
-	addr := fmt.Sprintf("http://127.0.0.1:8080%s&distribute=true&returnError=true", s.RemoveUrl)
-	req, _ := http.NewRequest("GET", addr, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithServiceDestFrontendTemplatePath_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&reqMode=tcp&port=1883&srcPort=1883&frontendTemplatePath=/templates/mqtt-fe.tmpl&servicePath.1=/&port.1=8883&srcPort.1=8883&frontendTemplatePath.1=/templates/mqtts-fe.tmpl", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: "my-service",
+		Service: proxy.Service{
+			ServiceName: "my-service",
+			ReqMode:     "tcp",
+			PathType:    s.PathType,
+			ServiceDest: []proxy.ServiceDest{
+				{Port: "1883", SrcPort: 1883, ServicePath: []string{}, FrontendTemplatePath: "/templates/mqtt-fe.tmpl"},
+				{Index: 1, Port: "8883", SrcPort: 8883, ServicePath: []string{"/"}, FrontendTemplatePath: "/templates/mqtts-fe.tmpl"},
+			},
+		},
+	})
 
-	serve.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsReconfigureAndServiceNameQueryIsNotPresent() {
-	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithDbRoles_WhenPresent() {
+	commandsOrig := os.Getenv("EXTERNAL_CHECK_COMMANDS")
+	defer func() { os.Setenv("EXTERNAL_CHECK_COMMANDS", commandsOrig) }()
+	os.Setenv("EXTERNAL_CHECK_COMMANDS", "/scripts/is-primary.sh")
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-db&reqMode=tcp&port=5432&srcPort=5432&dbRole=writer&servicePath.1=/&port.1=5432&srcPort.1=5433&dbRole.1=reader&dbWriters=pg-0,pg-1&dbReaders=pg-1&dbWriterCheckCommand=/scripts/is-primary.sh", nil)
+	expected, _ := json.Marshal(server.Response{
+		Mode:        "swarm",
+		Status:      "OK",
+		ServiceName: "my-db",
+		Service: proxy.Service{
+			ServiceName:          "my-db",
+			ReqMode:              "tcp",
+			PathType:             s.PathType,
+			DbReaders:            []string{"pg-1"},
+			DbWriterCheckCommand: "/scripts/is-primary.sh",
+			DbWriters:            []string{"pg-0", "pg-1"},
+			ServiceDest: []proxy.ServiceDest{
+				{Port: "5432", SrcPort: 5432, ServicePath: []string{}, DbRole: "writer"},
+				{Index: 1, Port: "5432", SrcPort: 5433, ServicePath: []string{"/"}, DbRole: "reader"},
+			},
+		},
+	})
 
-	srv := Serve{}
+	srv := Serve{Mode: "swarm"}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus200_WhenUrlIsReconfigureAndReqModeIsTcp() {
-	addr := fmt.Sprintf("%s?serviceName=redis&port=6379&srcPort=6379&reqMode=tcp", s.ReconfigureBaseUrl)
-	req, _ := http.NewRequest("GET", addr, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenDbHostsAreUsedInConsulMode() {
+	for _, hosts := range []string{"dbWriters=pg-0", "dbReaders=pg-1"} {
+		rw := getResponseWriterMock()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-db&reqMode=tcp&port=5432&srcPort=5432&dbRole=writer&"+hosts, nil)
 
-	srv := Serve{}
-	srv.ServeHTTP(s.ResponseWriter, req)
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+		rw.AssertCalled(s.T(), "WriteHeader", 400)
+	}
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsReconfigureAndReqModeIsTcpAndSrcPortIsNotPresent() {
-	addr := fmt.Sprintf("%s?serviceName=redis&port=6379&reqMode=tcp", s.ReconfigureBaseUrl)
-	req, _ := http.NewRequest("GET", addr, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenDbWriterCheckCommandIsNotAllowed() {
+	commandsOrig := os.Getenv("EXTERNAL_CHECK_COMMANDS")
+	defer func() { os.Setenv("EXTERNAL_CHECK_COMMANDS", commandsOrig) }()
+	os.Setenv("EXTERNAL_CHECK_COMMANDS", "/scripts/other.sh")
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-db&reqMode=tcp&port=5432&srcPort=5432&dbRole=writer&dbWriters=pg-0&dbWriterCheckCommand=/scripts/is-primary.sh", nil)
 
 	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
@@ -993,81 +1233,2123 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsReconfigureAn
 	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsReconfigureAndReqModeIsTcpAndPortIsNotPresent() {
-	addr := fmt.Sprintf("%s?serviceName=redis&srcPort=6379&reqMode=tcp", s.ReconfigureBaseUrl)
-	req, _ := http.NewRequest("GET", addr, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithStaticResponse_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&staticResponseStatus=200&staticResponseBody=ok&staticResponseContentType=text/html", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:               s.ServiceName,
+			ReqMode:                   "http",
+			ServiceColor:              s.ServiceColor,
+			ServiceDomain:             s.ServiceDomain,
+			OutboundHostname:          s.OutboundHostname,
+			ServiceDest:               []proxy.ServiceDest{s.sd},
+			StaticResponseBody:        "ok",
+			StaticResponseContentType: "text/html",
+			StaticResponseStatus:      200,
+		},
+	})
 
 	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenServicePathQueryIsNotPresent() {
-	url := fmt.Sprintf("%s?serviceName=my-service", s.ReconfigureBaseUrl)
-	req, _ := http.NewRequest("GET", url, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotRequirePort_WhenServiceHasStaticResponse() {
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=robots&servicePath=/robots.txt&staticResponseStatus=200", nil)
+	rw := httptest.NewRecorder()
 
-	srv := Serve{}
-	srv.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{Mode: "swarm"}
+	srv.ServeHTTP(rw, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+	s.Equal(http.StatusOK, rw.Code)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenModeIsServiceAndPortIsNotPresent() {
-	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonSslVerifyNone_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&sslVerifyNone=true", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:      s.ServiceName,
+			ReqMode:          "http",
+			ServiceColor:     s.ServiceColor,
+			ServiceDomain:    s.ServiceDomain,
+			OutboundHostname: s.OutboundHostname,
+			ServiceDest:      []proxy.ServiceDest{s.sd},
+			SslVerifyNone:    true,
+		},
+	})
 
-	srv := Serve{Mode: "service"}
+	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenModeIsSwarmAndPortIsNotPresent() {
-	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithServiceDomainMatchAll_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&serviceDomainMatchAll=true", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:           s.ServiceName,
+			ReqMode:               "http",
+			ServiceColor:          s.ServiceColor,
+			ServiceDomain:         s.ServiceDomain,
+			OutboundHostname:      s.OutboundHostname,
+			ServiceDest:           []proxy.ServiceDest{s.sd},
+			ServiceDomainMatchAll: true,
+		},
+	})
 
-	srv := Serve{Mode: "swARM"}
+	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute() {
-	s.Service.AclName = "my-acl"
-	url := fmt.Sprintf("%s&aclName=my-acl", s.ReconfigureUrl)
-	req, _ := http.NewRequest("GET", url, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithAclPriority_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&aclPriority=10", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:      s.ServiceName,
+			ReqMode:          "http",
+			ServiceColor:     s.ServiceColor,
+			ServiceDomain:    s.ServiceDomain,
+			OutboundHostname: s.OutboundHostname,
+			ServiceDest:      []proxy.ServiceDest{s.sd},
+			AclPriority:      10,
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithTtlSeconds_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&ttlSeconds=600", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:      s.ServiceName,
+			ReqMode:          "http",
+			ServiceColor:     s.ServiceColor,
+			ServiceDomain:    s.ServiceDomain,
+			OutboundHostname: s.OutboundHostname,
+			ServiceDest:      []proxy.ServiceDest{s.sd},
+			TtlSeconds:       600,
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithSetHostHeader_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&setHostHeader=my-saas.com", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:      s.ServiceName,
+			ReqMode:          "http",
+			ServiceColor:     s.ServiceColor,
+			ServiceDomain:    s.ServiceDomain,
+			OutboundHostname: s.OutboundHostname,
+			ServiceDest:      []proxy.ServiceDest{s.sd},
+			SetHostHeader:    "my-saas.com",
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_SetsHostHeaderToOutboundHostname_WhenPreserveHostIsFalse() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&preserveHost=false", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:      s.ServiceName,
+			ReqMode:          "http",
+			ServiceColor:     s.ServiceColor,
+			ServiceDomain:    s.ServiceDomain,
+			OutboundHostname: s.OutboundHostname,
+			ServiceDest:      []proxy.ServiceDest{s.sd},
+			SetHostHeader:    s.OutboundHostname,
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithStripPathAndAddPathPrefix_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&stripPath=true&addPathPrefix=/internal/", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:      s.ServiceName,
+			ReqMode:          "http",
+			ServiceColor:     s.ServiceColor,
+			ServiceDomain:    s.ServiceDomain,
+			OutboundHostname: s.OutboundHostname,
+			ServiceDest:      []proxy.ServiceDest{s.sd},
+			StripPath:        true,
+			AddPathPrefix:    "/internal",
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithHttp10Compatibility_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&http10Compatibility=true&acceptInvalidHttpResponse=true", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:               s.ServiceName,
+			ReqMode:                   "http",
+			ServiceColor:              s.ServiceColor,
+			ServiceDomain:             s.ServiceDomain,
+			OutboundHostname:          s.OutboundHostname,
+			ServiceDest:               []proxy.ServiceDest{s.sd},
+			Http10Compatibility:       true,
+			AcceptInvalidHttpResponse: true,
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithErrorfilePaths_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&errorfile502Path=/errorfiles/acme/502.http&errorfile503Path=docker-config://acme-503", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:      s.ServiceName,
+			ReqMode:          "http",
+			ServiceColor:     s.ServiceColor,
+			ServiceDomain:    s.ServiceDomain,
+			OutboundHostname: s.OutboundHostname,
+			ServiceDest:      []proxy.ServiceDest{s.sd},
+			Errorfile502Path: "/errorfiles/acme/502.http",
+			Errorfile503Path: "docker-config://acme-503",
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithSrcNetworks_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&srcNetworks=10.0.0.0/24,10.0.1.0/24", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:      s.ServiceName,
+			ReqMode:          "http",
+			ServiceColor:     s.ServiceColor,
+			ServiceDomain:    s.ServiceDomain,
+			OutboundHostname: s.OutboundHostname,
+			ServiceDest:      []proxy.ServiceDest{s.sd},
+			SrcNetworks:      []string{"10.0.0.0/24", "10.0.1.0/24"},
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_UsesDefaultParams_WhenNotSpecifiedInRequest() {
+	defer func() {
+		os.Unsetenv("SERVICE_DEFAULT_TIMEOUT_SERVER")
+		os.Unsetenv("SERVICE_DEFAULT_HTTPS_ONLY")
+		os.Unsetenv("DEFAULT_SERVICE_DOMAIN_MATCH_ALL")
+	}()
+	os.Setenv("SERVICE_DEFAULT_TIMEOUT_SERVER", "30")
+	os.Setenv("SERVICE_DEFAULT_HTTPS_ONLY", "true")
+	// The variables without the prefix are not parameter defaults
+	os.Setenv("DEFAULT_SERVICE_DOMAIN_MATCH_ALL", "true")
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&httpsOnly=false", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:      s.ServiceName,
+			ReqMode:          "http",
+			ServiceColor:     s.ServiceColor,
+			ServiceDomain:    s.ServiceDomain,
+			OutboundHostname: s.OutboundHostname,
+			ServiceDest:      []proxy.ServiceDest{s.sd},
+			TimeoutServer:    "30",
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_UsesProfileParams_WhenProfileIsSpecified() {
+	profiles.Put("public-api", map[string]string{"timeoutServer": "30", "httpsOnly": "true"})
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&profile=public-api&timeoutServer=60", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:      s.ServiceName,
+			ReqMode:          "http",
+			ServiceColor:     s.ServiceColor,
+			ServiceDomain:    s.ServiceDomain,
+			OutboundHostname: s.OutboundHostname,
+			ServiceDest:      []proxy.ServiceDest{s.sd},
+			HttpsOnly:        true,
+			TimeoutServer:    "60",
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenProfileIsUnknown() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&profile=unknown", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_UsesParamsOfClonedService_WhenCloneFromIsSpecified() {
+	serviceParams.Put("my-source", map[string]string{
+		"serviceName":   "my-source",
+		"aclName":       "my-source-acl",
+		"serviceDomain": "prod.acme.com",
+		"timeoutServer": "30",
+		"httpsOnly":     "true",
+	})
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&cloneFrom=my-source&timeoutServer=60", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:      s.ServiceName,
+			ReqMode:          "http",
+			ServiceColor:     s.ServiceColor,
+			ServiceDomain:    s.ServiceDomain,
+			OutboundHostname: s.OutboundHostname,
+			ServiceDest:      []proxy.ServiceDest{s.sd},
+			HttpsOnly:        true,
+			TimeoutServer:    "60",
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_StoresParamsOfReconfiguredService() {
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&timeoutServer=30", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	actual, found := serviceParams.Get(s.ServiceName)
+	s.True(found)
+	s.Equal("30", actual["timeoutServer"])
+	s.Equal(strings.Join(s.ServiceDomain, ","), actual["serviceDomain"])
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenClonedServiceIsNotConfigured() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&cloneFrom=unknown", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_PutsProfile_WhenUrlIsProfiles() {
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/profiles?name=websocket-app", strings.NewReader(`{"timeoutTunnel":"3600"}`))
+	expected, _ := json.Marshal(map[string]map[string]string{"websocket-app": {"timeoutTunnel": "3600"}})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithNamespacedServiceName_WhenNamespaceIsSpecified() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&namespace=team-a", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: "team-a." + s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:      "team-a." + s.ServiceName,
+			Namespace:        "team-a",
+			ReqMode:          "http",
+			ServiceColor:     s.ServiceColor,
+			ServiceDomain:    s.ServiceDomain,
+			OutboundHostname: s.OutboundHostname,
+			ServiceDest:      []proxy.ServiceDest{s.sd},
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus401_WhenNamespaceTokenIsMissing() {
+	defer func() { os.Unsetenv("NAMESPACE_TOKENS") }()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a")
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&namespace=team-a", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusUnauthorized, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus403_WhenNamespaceTokenBelongsToOtherNamespace() {
+	defer func() { os.Unsetenv("NAMESPACE_TOKENS") }()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a,team-b:token-b")
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&namespace=team-a", nil)
+	req.Header.Set("Authorization", "Bearer token-b")
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusForbidden, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus401_WhenNamespaceTokensAreSetAndRequestHasNeitherTokenNorNamespace() {
+	defer func() { os.Unsetenv("NAMESPACE_TOKENS") }()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a")
+	for _, url := range []string{
+		s.ReconfigureBaseUrl + "?serviceName=team-a.api&servicePath=/api&port=8080",
+		s.RemoveBaseUrl + "?serviceName=team-a.api",
+	} {
+		req, _ := http.NewRequest("GET", url, nil)
+		rw := httptest.NewRecorder()
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(http.StatusUnauthorized, rw.Code, url)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus403_WhenServiceNameBelongsToOtherNamespace() {
+	defer func() { os.Unsetenv("NAMESPACE_TOKENS") }()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a,team-b:token-b")
+	req, _ := http.NewRequest("GET", s.RemoveBaseUrl+"?serviceName=team-a.api", nil)
+	req.Header.Set("Authorization", "Bearer token-b")
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusForbidden, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_AllowsAllNamespaces_WhenAdminTokenIsSent() {
+	defer func() {
+		os.Unsetenv("NAMESPACE_TOKENS")
+		os.Unsetenv("NAMESPACE_ADMIN_TOKEN")
+	}()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a")
+	os.Setenv("NAMESPACE_ADMIN_TOKEN", "token-admin")
+	for _, url := range []string{s.ReconfigureUrl, s.ReconfigureUrl + "&namespace=team-a"} {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", "Bearer token-admin")
+		rw := httptest.NewRecorder()
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(http.StatusOK, rw.Code, url)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_RequiresAdminToken_WhenProxyIsChangedAsWhole() {
+	defer func() {
+		os.Unsetenv("NAMESPACE_TOKENS")
+		os.Unsetenv("NAMESPACE_ADMIN_TOKEN")
+	}()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a")
+	os.Setenv("NAMESPACE_ADMIN_TOKEN", "token-admin")
+	for _, url := range []string{"/globals?maxConn=10", "/reload", "/cert?certName=my-cert.pem", "/profiles?name=my-profile"} {
+		for token, expected := range map[string]int{"": http.StatusUnauthorized, "token-a": http.StatusForbidden} {
+			req, _ := http.NewRequest("PUT", s.BaseUrl+url, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rw := httptest.NewRecorder()
+
+			srv := Serve{}
+			srv.ServeHTTP(rw, req)
+
+			s.Equal(expected, rw.Code, url)
+		}
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus401_WhenServiceRequestHasNoNamespaceToken() {
+	defer func() { os.Unsetenv("NAMESPACE_TOKENS") }()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a")
+	for _, url := range []string{
+		"/schedule?serviceName=my-service&action=maintenance",
+		"/faults?serviceName=my-service&delay=10",
+		"/capture?serviceName=my-service&enabled=true",
+		"/stats?serviceName=my-service",
+		"/events",
+	} {
+		req, _ := http.NewRequest("PUT", s.BaseUrl+url, nil)
+		rw := httptest.NewRecorder()
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(http.StatusUnauthorized, rw.Code, url)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsScheduledActionsOfNamespaceOfToken() {
+	defer func() { os.Unsetenv("NAMESPACE_TOKENS") }()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a,team-b:token-b")
+	scheduleOrig := schedule
+	defer func() { schedule = scheduleOrig }()
+	schedule = proxy.NewSchedule(fmt.Sprintf("%s/schedule-%d.json", os.TempDir(), time.Now().UnixNano()))
+	start := time.Date(2017, 6, 1, 2, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	schedule.Put(proxy.ScheduledAction{ServiceName: "team-a.my-service", Type: proxy.ScheduleMaintenance, Start: start, End: end})
+	other, _ := schedule.Put(proxy.ScheduledAction{ServiceName: "team-b.my-service", Type: proxy.ScheduleMaintenance, Start: start, End: end})
+	req, _ := http.NewRequest("GET", s.BaseUrl+"/schedule", nil)
+	req.Header.Set("Authorization", "Bearer token-a")
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := []proxy.ScheduledAction{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Len(actual, 1)
+	s.Equal("team-a.my-service", actual[0].ServiceName)
+
+	req, _ = http.NewRequest("DELETE", s.BaseUrl+"/schedule?id="+other.ID, nil)
+	req.Header.Set("Authorization", "Bearer token-a")
+	rw = httptest.NewRecorder()
+
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusForbidden, rw.Code)
+	s.Len(schedule.GetAll(), 2)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_AllowsServiceNamesWithDots_WhenNamespaceTokenIsSent() {
+	defer func() { os.Unsetenv("NAMESPACE_TOKENS") }()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a,team-b:token-b")
+	setCaptureEnabledOrig := setCaptureEnabled
+	defer func() { setCaptureEnabled = setCaptureEnabledOrig }()
+	actualServiceName := ""
+	setCaptureEnabled = func(configsPath, serviceName string, enabled bool) error {
+		actualServiceName = serviceName
+		return nil
+	}
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/capture?serviceName=my.service&enabled=true", nil)
+	req.Header.Set("Authorization", "Bearer token-a")
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal("team-a.my.service", actualServiceName)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_UsesNamespaceOfToken_WhenNamespaceIsNotSpecified() {
+	defer func() { os.Unsetenv("NAMESPACE_TOKENS") }()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a")
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+	req.Header.Set("Authorization", "Bearer token-a")
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal("team-a."+s.ServiceName, actual.ServiceName)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus409_WhenRoutesCollideWithOtherNamespace() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxy.Instance = getConflictingProxyMock()
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/admin&port=1234&serviceDomain=my-domain.com&namespace=team-a", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusConflict, rw.Code)
+	s.Equal([]proxy.Conflict{{ServiceName: "other-service", Domain: "my-domain.com"}}, actual.Conflicts)
+	mockObj.AssertNotCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsServiceStats_WhenUrlIsStats() {
+	getServiceStatsOrig := getServiceStats
+	defer func() { getServiceStats = getServiceStatsOrig }()
+	stats := proxy.ServiceStats{
+		ServiceName: "my-service",
+		Backends:    []proxy.BackendStats{{Name: "my-service-be8080", Status: "UP", CurrentSessions: 3}},
+	}
+	actualServiceName := ""
+	getServiceStats = func(serviceName string) (proxy.ServiceStats, error) {
+		actualServiceName = serviceName
+		return stats, nil
+	}
+	req, _ := http.NewRequest("GET", s.BaseUrl+"/stats?serviceName=my-service", nil)
+	expected, _ := json.Marshal(stats)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal("my-service", actualServiceName)
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenServiceDoesNotHaveStats() {
+	getServiceStatsOrig := getServiceStats
+	defer func() { getServiceStats = getServiceStatsOrig }()
+	getServiceStats = func(serviceName string) (proxy.ServiceStats, error) {
+		return proxy.ServiceStats{ServiceName: serviceName, Backends: []proxy.BackendStats{}}, nil
+	}
+	req, _ := http.NewRequest("GET", s.BaseUrl+"/stats?serviceName=my-service", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 404)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenStatsServiceNameIsMissing() {
+	req, _ := http.NewRequest("GET", s.BaseUrl+"/stats", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ChecksHealthOfCriticalServices_WhenUrlIsBackendsHealth() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{
+		"my-service":    {ServiceName: "my-service", Critical: true},
+		"other-service": {ServiceName: "other-service"},
+	})
+	proxy.Instance = proxyMock
+	defer func() { os.Unsetenv("BACKENDS_HEALTHY_PERCENTAGE") }()
+	os.Setenv("BACKENDS_HEALTHY_PERCENTAGE", "50")
+	getBackendsHealthOrig := getBackendsHealth
+	defer func() { getBackendsHealth = getBackendsHealthOrig }()
+	actualNames := []string{}
+	actualPercentage := 0.0
+	getBackendsHealth = func(serviceNames []string, percentage float64) (proxy.BackendsHealth, error) {
+		actualNames = serviceNames
+		actualPercentage = percentage
+		return proxy.BackendsHealth{Healthy: true, Percentage: 100}, nil
+	}
+	req, _ := http.NewRequest("GET", s.BaseUrl+"/backends/health", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal([]string{"my-service"}, actualNames)
+	s.Equal(50.0, actualPercentage)
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus503_WhenBackendsAreUnhealthy() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{})
+	proxy.Instance = proxyMock
+	getBackendsHealthOrig := getBackendsHealth
+	defer func() { getBackendsHealth = getBackendsHealthOrig }()
+	getBackendsHealth = func(serviceNames []string, percentage float64) (proxy.BackendsHealth, error) {
+		return proxy.BackendsHealth{Healthy: false}, nil
+	}
+	req, _ := http.NewRequest("GET", s.BaseUrl+"/backends/health", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 503)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DisablesLogging_WhenLoggingEnabledIsFalse() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&loggingEnabled=false&logSampleRate=5", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:      s.ServiceName,
+			ReqMode:          "http",
+			ServiceColor:     s.ServiceColor,
+			ServiceDomain:    s.ServiceDomain,
+			OutboundHostname: s.OutboundHostname,
+			ServiceDest:      []proxy.ServiceDest{s.sd},
+			LoggingDisabled:  true,
+			LogSampleRate:    5,
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_SetsCaptureEnabled_WhenUrlIsCapture() {
+	setCaptureEnabledOrig := setCaptureEnabled
+	defer func() { setCaptureEnabled = setCaptureEnabledOrig }()
+	actualServiceName := ""
+	actualEnabled := true
+	setCaptureEnabled = func(configsPath, serviceName string, enabled bool) error {
+		actualServiceName = serviceName
+		actualEnabled = enabled
+		return nil
+	}
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/capture?serviceName=my-service&enabled=false", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal("my-service", actualServiceName)
+	s.False(actualEnabled)
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenCaptureEnabledIsMissing() {
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/capture?serviceName=my-service", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_SetsFault_WhenUrlIsFaults() {
+	faultInjectionOrig := os.Getenv("FAULT_INJECTION")
+	defer func() { os.Setenv("FAULT_INJECTION", faultInjectionOrig) }()
+	os.Setenv("FAULT_INJECTION", "true")
+	setFaultOrig := setFault
+	defer func() { setFault = setFaultOrig }()
+	actualServiceName := ""
+	actualFault := proxy.Fault{}
+	setFault = func(configsPath, serviceName string, fault proxy.Fault) error {
+		actualServiceName = serviceName
+		actualFault = fault
+		return nil
+	}
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/faults?serviceName=my-service&abortPercentage=10&delay=200", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal("my-service", actualServiceName)
+	s.Equal(proxy.Fault{AbortPercentage: 10, Delay: 200}, actualFault)
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenFaultInjectionIsDisabled() {
+	faultInjectionOrig := os.Getenv("FAULT_INJECTION")
+	defer func() { os.Setenv("FAULT_INJECTION", faultInjectionOrig) }()
+	os.Unsetenv("FAULT_INJECTION")
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/faults?serviceName=my-service&abortPercentage=10", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenFaultAbortPercentageIsOutOfRange() {
+	faultInjectionOrig := os.Getenv("FAULT_INJECTION")
+	defer func() { os.Setenv("FAULT_INJECTION", faultInjectionOrig) }()
+	os.Setenv("FAULT_INJECTION", "true")
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/faults?serviceName=my-service&abortPercentage=150", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_AddsBlocklistEntries_WhenUrlIsBlocklist() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{
+		"my-service": {ServiceName: "my-service", Blocklist: true},
+	})
+	proxy.Instance = proxyMock
+	addBlocklistEntriesOrig := addBlocklistEntries
+	defer func() { addBlocklistEntries = addBlocklistEntriesOrig }()
+	actualServiceName := ""
+	actualEntries := proxy.Blocklist{}
+	addBlocklistEntries = func(configsPath, serviceName string, entries proxy.Blocklist) error {
+		actualServiceName = serviceName
+		actualEntries = entries
+		return nil
+	}
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/blocklist?serviceName=my-service&ip=10.0.0.0/8,1.2.3.4&userAgent=sqlmap", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal("my-service", actualServiceName)
+	s.Equal(proxy.Blocklist{Ips: []string{"10.0.0.0/8", "1.2.3.4"}, UserAgents: []string{"sqlmap"}}, actualEntries)
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_RemovesBlocklistEntries_WhenMethodIsDelete() {
+	defer os.Unsetenv("BLOCKLIST")
+	os.Setenv("BLOCKLIST", "true")
+	removeBlocklistEntriesOrig := removeBlocklistEntries
+	defer func() { removeBlocklistEntries = removeBlocklistEntriesOrig }()
+	actualEntries := proxy.Blocklist{}
+	removeBlocklistEntries = func(configsPath, serviceName string, entries proxy.Blocklist) error {
+		actualEntries = entries
+		return nil
+	}
+	req, _ := http.NewRequest("DELETE", s.BaseUrl+"/blocklist?userAgent=sqlmap", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(proxy.Blocklist{UserAgents: []string{"sqlmap"}}, actualEntries)
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_RequiresAdminToken_WhenGlobalBlocklistIsChanged() {
+	defer func() {
+		os.Unsetenv("BLOCKLIST")
+		os.Unsetenv("NAMESPACE_TOKENS")
+		os.Unsetenv("NAMESPACE_ADMIN_TOKEN")
+	}()
+	os.Setenv("BLOCKLIST", "true")
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a")
+	os.Setenv("NAMESPACE_ADMIN_TOKEN", "token-admin")
+	addBlocklistEntriesOrig := addBlocklistEntries
+	defer func() { addBlocklistEntries = addBlocklistEntriesOrig }()
+	invoked := false
+	addBlocklistEntries = func(configsPath, serviceName string, entries proxy.Blocklist) error {
+		invoked = true
+		return nil
+	}
+	for token, expected := range map[string]int{"": http.StatusUnauthorized, "token-a": http.StatusForbidden, "token-admin": http.StatusOK} {
+		invoked = false
+		req, _ := http.NewRequest("PUT", s.BaseUrl+"/blocklist?ip=1.2.3.4", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rw := httptest.NewRecorder()
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(expected, rw.Code, token)
+		s.Equal(expected == http.StatusOK, invoked, token)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ChangesBlocklistOfNamespaceOfToken() {
+	defer func() { os.Unsetenv("NAMESPACE_TOKENS") }()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a")
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{
+		"my-service":        {ServiceName: "my-service", Blocklist: true},
+		"team-a.my-service": {ServiceName: "team-a.my-service", Blocklist: true},
+	})
+	proxy.Instance = proxyMock
+	addBlocklistEntriesOrig := addBlocklistEntries
+	defer func() { addBlocklistEntries = addBlocklistEntriesOrig }()
+	actualServiceName := ""
+	addBlocklistEntries = func(configsPath, serviceName string, entries proxy.Blocklist) error {
+		actualServiceName = serviceName
+		return nil
+	}
+	for query, expected := range map[string]int{"": http.StatusOK, "&namespace=team-b": http.StatusForbidden} {
+		actualServiceName = ""
+		req, _ := http.NewRequest("PUT", s.BaseUrl+"/blocklist?serviceName=my-service&ip=1.2.3.4"+query, nil)
+		req.Header.Set("Authorization", "Bearer token-a")
+		rw := httptest.NewRecorder()
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(expected, rw.Code, query)
+		if expected == http.StatusOK {
+			s.Equal("team-a.my-service", actualServiceName)
+		} else {
+			s.Empty(actualServiceName)
+		}
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenBlocklistRequestIsInvalid() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{
+		"my-service": {ServiceName: "my-service"},
+	})
+	proxy.Instance = proxyMock
+	for _, query := range []string{"ip=1.2.3.4", "serviceName=my-service&ip=1.2.3.4", "serviceName=other&ip=1.2.3.4", "serviceName=my-service"} {
+		rw := getResponseWriterMock()
+		req, _ := http.NewRequest("PUT", s.BaseUrl+"/blocklist?"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		rw.AssertCalled(s.T(), "WriteHeader", 400)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_SchedulesMaintenance_WhenUrlIsSchedule() {
+	scheduleOrig := schedule
+	defer func() { schedule = scheduleOrig }()
+	schedule = proxy.NewSchedule(fmt.Sprintf("%s/schedule-%d.json", os.TempDir(), time.Now().UnixNano()))
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/schedule?serviceName=my-service&action=maintenance&start=2017-06-01T02:00:00Z&end=2017-06-01T02:30:00Z", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := []proxy.ScheduledAction{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal([]proxy.ScheduledAction{{
+		ID:          "1",
+		ServiceName: "my-service",
+		Type:        proxy.ScheduleMaintenance,
+		Start:       time.Date(2017, 6, 1, 2, 0, 0, 0, time.UTC),
+		End:         time.Date(2017, 6, 1, 2, 30, 0, 0, time.UTC),
+	}}, actual)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenScheduleTimeIsInvalid() {
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/schedule?serviceName=my-service&action=maintenance&start=tomorrow", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_RunScheduledActions_ReconfiguresServicesWithMaintenance() {
+	scheduleOrig := schedule
+	defer func() { schedule = scheduleOrig }()
+	schedule = proxy.NewSchedule(fmt.Sprintf("%s/schedule-%d.json", os.TempDir(), time.Now().UnixNano()))
+	schedule.Put(proxy.ScheduledAction{
+		ServiceName: "my-service",
+		Type:        proxy.ScheduleMaintenance,
+		Start:       time.Now().Add(-time.Minute),
+		End:         time.Now().Add(time.Hour),
+	})
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service"}})
+	proxy.Instance = proxyMock
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	actualService := proxy.Service{}
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		actualService = serviceData
+		return getReconfigureMock("")
+	}
+
+	srv := Serve{}
+	srv.runScheduledActions()
+
+	s.True(actualService.Maintenance)
+	s.True(schedule.IsInMaintenance("my-service"))
+	s.Equal(proxy.GetServiceHash(actualService), serviceVersions.Get("my-service").Hash)
+}
+
+func (s *ServerTestSuite) Test_RunScheduledActions_RetriesAction_WhenReloadFails() {
+	scheduleOrig := schedule
+	defer func() { schedule = scheduleOrig }()
+	schedule = proxy.NewSchedule(fmt.Sprintf("%s/schedule-%d.json", os.TempDir(), time.Now().UnixNano()))
+	schedule.Put(proxy.ScheduledAction{
+		ServiceName:  "my-service",
+		Type:         proxy.ScheduleSwitchColor,
+		Start:        time.Now().Add(-time.Minute),
+		ServiceColor: "green",
+	})
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service"}})
+	proxy.Instance = proxyMock
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return getReconfigureMock("")
+	}
+	reload = ReloadMock{ExecuteMock: func(recreate bool, listenerAddr string) error {
+		return fmt.Errorf("This is an error")
+	}}
+
+	srv := Serve{}
+	srv.runScheduledActions()
+
+	s.Len(schedule.GetDue(), 1)
+}
+
+// ServeHTTP > Service
+
+func (s *ServerTestSuite) Test_ServeHTTP_DisablesService() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service"}})
+	proxy.Instance = proxyMock
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	actualService := proxy.Service{}
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		actualService = serviceData
+		return getReconfigureMock("")
+	}
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/service/my-service/disable", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusOK, rw.Code)
+	s.True(actualService.Maintenance)
+	s.True(disabledServices.Contains("my-service"))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_EnablesService() {
+	disabledServices.Add("my-service")
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service", Maintenance: true}})
+	proxy.Instance = proxyMock
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	actualService := proxy.Service{}
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		actualService = serviceData
+		return getReconfigureMock("")
+	}
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/service/my-service/enable", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusOK, rw.Code)
+	s.False(actualService.Maintenance)
+	s.False(disabledServices.Contains("my-service"))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenDisabledServiceIsNotConfigured() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{})
+	proxy.Instance = proxyMock
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/service/my-service/disable", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusNotFound, rw.Code)
+	s.False(disabledServices.Contains("my-service"))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_KeepsServiceDisabled_WhenServiceIsReconfigured() {
+	disabledServices.Add(s.ServiceName)
+	var actualService proxy.Service
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		actualService = serviceData
+		return getReconfigureMock("")
+	}
+
+	srv := Serve{}
+	srv.ServeHTTP(httptest.NewRecorder(), s.RequestReconfigure)
+
+	s.True(actualService.Maintenance)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus503_WhenProxyIsShuttingDown() {
+	defer atomic.StoreInt32(&shuttingDown, 0)
+	atomic.StoreInt32(&shuttingDown, 1)
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	for _, url := range []string{s.ReconfigureUrl, s.BaseUrl + "/remove?serviceName=my-service", s.BaseUrl + "/backends/health"} {
+		req, _ := http.NewRequest("GET", url, nil)
+		rw := httptest.NewRecorder()
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(http.StatusServiceUnavailable, rw.Code, url)
+	}
+	mockObj.AssertNotCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_Shutdown_AnnouncesDrainAndStopsProxy() {
+	defer func() {
+		atomic.StoreInt32(&shuttingDown, 0)
+		shutdownCh = make(chan struct{})
+	}()
+	notifyUrlOrig := os.Getenv("SHUTDOWN_NOTIFY_URL")
+	defer func() { os.Setenv("SHUTDOWN_NOTIFY_URL", notifyUrlOrig) }()
+	gracePeriodOrig := os.Getenv("SHUTDOWN_GRACE_PERIOD")
+	defer func() { os.Setenv("SHUTDOWN_GRACE_PERIOD", gracePeriodOrig) }()
+	os.Setenv("SHUTDOWN_GRACE_PERIOD", "20")
+	actualBody := ""
+	lb := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		actualBody = string(body)
+	}))
+	defer lb.Close()
+	os.Setenv("SHUTDOWN_NOTIFY_URL", lb.URL)
+	softStopProxyOrig := softStopProxy
+	defer func() { softStopProxy = softStopProxyOrig }()
+	actualGracePeriod := time.Duration(0)
+	softStopProxy = func(gracePeriod time.Duration) error {
+		actualGracePeriod = gracePeriod
+		return nil
+	}
+	osExitOrig := osExit
+	defer func() { osExit = osExitOrig }()
+	actualCode := -1
+	osExit = func(code int) {
+		actualCode = code
+	}
+
+	srv := Serve{BaseReconfigure: actions.BaseReconfigure{InstanceName: "my-proxy"}}
+	srv.shutdown()
+
+	s.True(srv.isShuttingDown())
+	select {
+	case <-shutdownCh:
+	default:
+		s.Fail("The shutdown channel is not closed")
+	}
+	s.Equal(`{"InstanceName":"my-proxy","Status":"draining"}`, actualBody)
+	s.Equal(20*time.Second, actualGracePeriod)
+	s.Equal(0, actualCode)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_SetsGlobalsAndReloads_WhenUrlIsGlobals() {
+	defer proxy.ResetGlobals(map[string]string{})
+	reloadOrig := reload
+	defer func() { reload = reloadOrig }()
+	actualRecreate := false
+	reload = ReloadMock{
+		ExecuteMock: func(recreate bool, listenerAddr string) error {
+			actualRecreate = recreate
+			return nil
+		},
+	}
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/globals?timeoutClient=30&maxConn=10000", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := map[string]string{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusOK, rw.Code)
+	s.True(actualRecreate)
+	s.Equal("30", actual["timeoutClient"])
+	s.Equal("10000", actual["maxConn"])
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenGlobalIsInvalid() {
+	defer proxy.ResetGlobals(map[string]string{})
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/globals?timeoutClient=soon", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+	s.Equal(map[string]string{}, proxy.GetGlobalOverrides())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_RestoresGlobals_WhenReloadFails() {
+	defer proxy.ResetGlobals(map[string]string{})
+	reloadOrig := reload
+	defer func() { reload = reloadOrig }()
+	reload = ReloadMock{
+		ExecuteMock: func(recreate bool, listenerAddr string) error {
+			if len(proxy.GetGlobalOverrides()) > 0 {
+				return fmt.Errorf("This is an error")
+			}
+			return nil
+		},
+	}
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/globals?timeoutClient=30", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+	s.Equal(map[string]string{}, proxy.GetGlobalOverrides())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ExportsState_WhenUrlIsState() {
+	defer proxy.ResetGlobals(map[string]string{})
+	proxy.SetGlobals(map[string]string{"timeoutClient": "30"})
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := new(ProxyMock)
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service", AclName: "my-service"}})
+	proxyMock.On("GetCertPaths").Return([]string{"/certs/my-cert.pem"})
+	proxy.Instance = proxyMock
+	disabledServices.Add("my-service")
+	req, _ := http.NewRequest("GET", s.BaseUrl+"/state", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := proxy.State{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal(proxy.State{
+		Services: []proxy.Service{{ServiceName: "my-service", AclName: "my-service"}},
+		Certs:    []string{"/certs/my-cert.pem"},
+		Globals:  map[string]string{"timeoutClient": "30"},
+		Disabled: []string{"my-service"},
+	}, actual)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ImportsState_WhenUrlIsStateAndMethodIsPut() {
+	defer proxy.ResetGlobals(map[string]string{})
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := new(ProxyMock)
+	proxyMock.On("GetCertPaths").Return([]string{})
+	proxy.Instance = proxyMock
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	actualServices := []string{}
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		actualServices = append(actualServices, serviceData.ServiceName)
+		return getReconfigureMock("")
+	}
+	reloads := 0
+	reloadOrig := reload
+	defer func() { reload = reloadOrig }()
+	reload = ReloadMock{
+		ExecuteMock: func(recreate bool, listenerAddr string) error {
+			reloads++
+			return nil
+		},
+	}
+	defer func() {
+		serviceVersions.Delete("service-1")
+		serviceVersions.Delete("service-2")
+		serviceParams.Delete("service-1")
+	}()
+	body := `{
+		"Services":[
+			{"ServiceName":"service-1","ReqMode":"http","ServiceDest":[{"ServicePath":["/demo-1"],"Port":"8080"}]},
+			{"ServiceName":"service-2","ReqMode":"http","ServiceDest":[{"ServicePath":["/demo-2"],"Port":"8080"}]}
+		],
+		"Certs":["/certs/my-cert.pem"],
+		"Globals":{"maxConn":"10000"},
+		"Params":{"service-1":{"serviceName":"service-1","servicePath":"/demo-1"}},
+		"Disabled":["service-2"]
+	}`
+	disabledServices.Add("service-1")
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/state", strings.NewReader(body))
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal([]string{"service-1", "service-2"}, actualServices)
+	s.Equal(map[string]string{"maxConn": "10000"}, proxy.GetGlobalOverrides())
+	s.Equal("Imported 2 of 2 services. The certificates /certs/my-cert.pem need to be added to the proxy", actual.Message)
+	s.Equal(1, reloads)
+	s.Equal(1, serviceVersions.Get("service-1").Version)
+	s.Equal(1, serviceVersions.Get("service-2").Version)
+	params, _ := serviceParams.Get("service-1")
+	s.Equal(map[string]string{"serviceName": "service-1", "servicePath": "/demo-1"}, params)
+	s.Equal([]string{"service-2"}, disabledServices.GetAll())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotImportServices_WhenTheyAreNotValid() {
+	os.Setenv("EXTERNAL_CHECK_COMMANDS", "/usr/bin/check")
+	defer os.Unsetenv("EXTERNAL_CHECK_COMMANDS")
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := new(ProxyMock)
+	proxyMock.On("GetCertPaths").Return([]string{})
+	proxy.Instance = proxyMock
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	actualServices := []string{}
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		actualServices = append(actualServices, serviceData.ServiceName)
+		return getReconfigureMock("")
+	}
+	reloaded := false
+	reloadOrig := reload
+	defer func() { reload = reloadOrig }()
+	reload = ReloadMock{
+		ExecuteMock: func(recreate bool, listenerAddr string) error {
+			reloaded = true
+			return nil
+		},
+	}
+	body := `{"Services":[
+		{"ServiceName":"service-1"},
+		{"ServiceName":"service-2","ReqMode":"http","ExternalCheckCommand":"/bin/rm","ServiceDest":[{"ServicePath":["/demo"],"Port":"8080"}]}
+	]}`
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/state", strings.NewReader(body))
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusInternalServerError, rw.Code)
+	s.Empty(actualServices)
+	s.False(reloaded)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus403_WhenImportedServiceBelongsToOtherNamespace() {
+	defer func() { os.Unsetenv("NAMESPACE_TOKENS") }()
+	os.Setenv("NAMESPACE_TOKENS", "team-a:token-a,team-b:token-b")
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	invoked := false
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		invoked = true
+		return getReconfigureMock("")
+	}
+	body := `{"Services":[{"ServiceName":"team-b.api","Namespace":"team-b","ReqMode":"http","ServiceDest":[{"ServicePath":["/api"],"Port":"8080"}]}]}`
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/state", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token-a")
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusForbidden, rw.Code)
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenStateIsNotJson() {
+	req, _ := http.NewRequest("PUT", s.BaseUrl+"/state", strings.NewReader("services"))
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsConfigDiff_WhenUrlIsConfigDiff() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := new(ProxyMock)
+	proxyMock.On("ReadConfig").Return("frontend services\n    bind *:80\n", nil)
+	proxyMock.On("RenderConfig").Return("frontend services\n    bind *:8080\n", nil)
+	proxy.Instance = proxyMock
+	req, _ := http.NewRequest("GET", s.BaseUrl+"/config/diff", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal(`--- current/haproxy.cfg
++++ pending/haproxy.cfg
+@@ -1,2 +1,2 @@
+ frontend services
+-    bind *:80
++    bind *:8080
+`, rw.Body.String())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenConfigDiffCannotReadConfig() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := new(ProxyMock)
+	proxyMock.On("ReadConfig").Return("", fmt.Errorf("This is an error"))
+	proxy.Instance = proxyMock
+	req, _ := http.NewRequest("GET", s.BaseUrl+"/config/diff", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusInternalServerError, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsXdsResources_WhenEngineIsEnvoy() {
+	defer func() { os.Unsetenv("PROXY_ENGINE") }()
+	os.Setenv("PROXY_ENGINE", "envoy")
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxy.Instance = proxy.NewEnvoy("/cfg")
+	defer proxy.Instance.RemoveService("my-service")
+	proxy.Instance.AddService(proxy.Service{ServiceName: "my-service", ServiceDest: []proxy.ServiceDest{{Port: "8080", ServicePath: []string{"/"}}}})
+	req, _ := http.NewRequest("POST", "/v3/discovery:clusters", strings.NewReader(`{"version_info":"","node":{"id":"envoy-1"}}`))
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := struct {
+		VersionInfo string `json:"version_info"`
+		Resources   []map[string]interface{}
+		TypeUrl     string `json:"type_url"`
+	}{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal(proxy.GetXdsResources().Version, actual.VersionInfo)
+	s.Equal(proxy.XdsClusterType, actual.TypeUrl)
+	s.Len(actual.Resources, 1)
+	s.Equal("my-service-be8080", actual.Resources[0]["name"])
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus304_WhenEnvoyHasLatestXdsVersion() {
+	defer func() { os.Unsetenv("PROXY_ENGINE") }()
+	os.Setenv("PROXY_ENGINE", "envoy")
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxy.Instance = proxy.NewEnvoy("/cfg")
+	body := fmt.Sprintf(`{"version_info":"%s","resource_names":["http-80"]}`, proxy.GetXdsResources().Version)
+	req, _ := http.NewRequest("POST", "/v3/discovery:routes", strings.NewReader(body))
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusNotModified, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenXdsRequestIsNotValid() {
+	defer func() { os.Unsetenv("PROXY_ENGINE") }()
+	os.Setenv("PROXY_ENGINE", "envoy")
+	req, _ := http.NewRequest("POST", "/v3/discovery:listeners", strings.NewReader("{"))
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusBadRequest, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenXdsIsRequestedAndEngineIsNotEnvoy() {
+	req, _ := http.NewRequest("POST", "/v3/discovery:listeners", strings.NewReader("{}"))
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusNotFound, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_RemoveExpiredServices_DoesNotRemoveServices_WhenTtlDidNotExpire() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxy.Instance = getProxyMock("")
+	invoked := false
+	newRemoveOrig := actions.NewRemove
+	defer func() { actions.NewRemove = newRemoveOrig }()
+	actions.NewRemove = func(
+		serviceName, aclName, configsPath, templatesPath string,
+		consulAddresses []string,
+		instanceName, mode string,
+		options actions.RemoveOptions,
+	) actions.Removable {
+		invoked = true
+		return getRemoveMock("")
+	}
+	expirations.Refresh(s.ServiceName, 600)
+
+	srv := Serve{}
+	srv.removeExpiredServices()
+
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_RemoveOrphan_ForgetsStateOfService() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxy.Instance = getProxyMock("")
+	actualServiceName := ""
+	newRemoveOrig := actions.NewRemove
+	defer func() { actions.NewRemove = newRemoveOrig }()
+	actions.NewRemove = func(
+		serviceName, aclName, configsPath, templatesPath string,
+		consulAddresses []string,
+		instanceName, mode string,
+		options actions.RemoveOptions,
+	) actions.Removable {
+		actualServiceName = serviceName
+		return getRemoveMock("")
+	}
+	serviceVersions.Put(s.ServiceName, "my-hash")
+	disabledServices.Add(s.ServiceName)
+
+	srv := Serve{}
+	err := srv.removeOrphan(s.ServiceName, "")
+
+	s.NoError(err)
+	s.Equal(s.ServiceName, actualServiceName)
+	s.Equal(0, serviceVersions.Get(s.ServiceName).Version)
+	s.False(disabledServices.Contains(s.ServiceName))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithDomainAclPriority_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&aclPriority.api.acme.com=20", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:       s.ServiceName,
+			ReqMode:           "http",
+			ServiceColor:      s.ServiceColor,
+			ServiceDomain:     s.ServiceDomain,
+			OutboundHostname:  s.OutboundHostname,
+			ServiceDest:       []proxy.ServiceDest{s.sd},
+			DomainAclPriority: map[string]int{"api.acme.com": 20},
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenDomainAclPriorityIsNotNumber() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&aclPriority.api.acme.com=high", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusBadRequest, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithExternalCheckCommand_WhenAllowed() {
+	commandsOrig := os.Getenv("EXTERNAL_CHECK_COMMANDS")
+	defer func() { os.Setenv("EXTERNAL_CHECK_COMMANDS", commandsOrig) }()
+	os.Setenv("EXTERNAL_CHECK_COMMANDS", "/scripts/other.sh,/scripts/check-lag.sh")
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&externalCheckCommand=/scripts/check-lag.sh", nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Service: proxy.Service{
+			ServiceName:          s.ServiceName,
+			ReqMode:              "http",
+			ServiceColor:         s.ServiceColor,
+			ServiceDomain:        s.ServiceDomain,
+			OutboundHostname:     s.OutboundHostname,
+			ServiceDest:          []proxy.ServiceDest{s.sd},
+			ExternalCheckCommand: "/scripts/check-lag.sh",
+		},
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenExternalCheckCommandIsNotAllowed() {
+	commandsOrig := os.Getenv("EXTERNAL_CHECK_COMMANDS")
+	defer func() { os.Setenv("EXTERNAL_CHECK_COMMANDS", commandsOrig) }()
+	os.Setenv("EXTERNAL_CHECK_COMMANDS", "/scripts/other.sh")
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&externalCheckCommand=/scripts/check-lag.sh", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400WithFieldErrors_WhenParametersAreInvalid() {
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&pathType=path_something&httpsPort=abc", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusBadRequest, rw.Code)
+	s.Equal("NOK", actual.Status)
+	s.Equal("httpsPort", actual.Errors[0].Field)
+	s.Equal("pathType", actual.Errors[1].Field)
+	s.Contains(actual.Message, "httpsPort: abc is not a number")
+	mockObj.AssertNotCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsFieldErrorsWithIndexOfParameters_WhenPortIsNotSent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath.1=/api&port.1=1234&servicePath.2=/admin&port.2=abc", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusBadRequest, rw.Code)
+	s.Len(actual.Errors, 1)
+	s.Equal("port.2", actual.Errors[0].Field)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_WritesErrorHeader_WhenReconfigureDistributeIsTrueAndError() {
+	serve := Serve{}
+	serve.Port = s.ServiceDest[0].Port
+	addr := fmt.Sprintf("http://127.0.0.1:8080%s&distribute=true&returnError=true", s.ReconfigureUrl)
+	req, _ := http.NewRequest("GET", addr, nil)
+
+	serve.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_WritesErrorHeader_WhenRemoveDistributeIsTrueAndError() {
+	serve := Serve{}
+	serve.Port = s.ServiceDest[0].Port
+	addr := fmt.Sprintf("http://127.0.0.1:8080%s&distribute=true&returnError=true", s.RemoveUrl)
+	req, _ := http.NewRequest("GET", addr, nil)
+
+	serve.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsReconfigureAndServiceNameQueryIsNotPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus200_WhenUrlIsReconfigureAndReqModeIsTcp() {
+	addr := fmt.Sprintf("%s?serviceName=redis&port=6379&srcPort=6379&reqMode=tcp", s.ReconfigureBaseUrl)
+	req, _ := http.NewRequest("GET", addr, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsReconfigureAndReqModeIsTcpAndSrcPortIsNotPresent() {
+	addr := fmt.Sprintf("%s?serviceName=redis&port=6379&reqMode=tcp", s.ReconfigureBaseUrl)
+	req, _ := http.NewRequest("GET", addr, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsReconfigureAndReqModeIsTcpAndPortIsNotPresent() {
+	addr := fmt.Sprintf("%s?serviceName=redis&srcPort=6379&reqMode=tcp", s.ReconfigureBaseUrl)
+	req, _ := http.NewRequest("GET", addr, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenServicePathQueryIsNotPresent() {
+	url := fmt.Sprintf("%s?serviceName=my-service", s.ReconfigureBaseUrl)
+	req, _ := http.NewRequest("GET", url, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenModeIsServiceAndPortIsNotPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+
+	srv := Serve{Mode: "service"}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenModeIsSwarmAndPortIsNotPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+
+	srv := Serve{Mode: "swARM"}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute() {
+	s.Service.AclName = "my-acl"
+	url := fmt.Sprintf("%s&aclName=my-acl", s.ReconfigureUrl)
+	req, _ := http.NewRequest("GET", url, nil)
+
+	s.invokesReconfigure(req, true)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeReconfigureExecute_WhenDistributeIsTrue() {
+	req, _ := http.NewRequest(
+		"GET",
+		fmt.Sprintf("%s&distribute=true", s.ReconfigureUrl),
+		nil,
+	)
+	s.invokesReconfigure(req, false)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeRemoveExecute_WhenDistributeIsTrue() {
+	req, _ := http.NewRequest(
+		"GET",
+		fmt.Sprintf("%s&distribute=true", s.RemoveUrl),
+		nil,
+	)
+	s.invokesReconfigure(req, false)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenReconfigureExecuteFails() {
+	mockObj := getReconfigureMock("Execute")
+	mockObj.On("Execute", []string{}).Return(fmt.Errorf("This is an error"))
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, s.RequestReconfigure)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsConfigIssues_WhenHaproxyRejectsConfiguration() {
+	mockObj := getReconfigureMock("Execute")
+	mockObj.On("Execute", []string{}).Return(&proxy.ConfigError{
+		Command: "haproxy -f /cfg/haproxy.cfg",
+		Err:     fmt.Errorf("exit status 1"),
+		Issues:  []proxy.ConfigIssue{{Line: 42, Message: "'timeout' : expects an integer", ServiceName: "my-service", Parameter: "timeoutServer"}},
+	})
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, s.RequestReconfigure)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusInternalServerError, rw.Code)
+	s.Equal([]proxy.ConfigIssue{{Line: 42, Message: "'timeout' : expects an integer", ServiceName: "my-service", Parameter: "timeoutServer"}}, actual.ConfigIssues)
+	s.Contains(actual.Message, "service my-service, parameter timeoutServer")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus504_WhenReconfigureDoesNotFinishInTime() {
+	mockObj := getReconfigureMock("Execute")
+	mockObj.On("Execute", []string{}).Return(fmt.Errorf("This is an error"))
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, s.RequestReconfigure.WithContext(ctx))
+
+	s.Equal(http.StatusGatewayTimeout, rw.Code)
+	s.Contains(rw.Body.String(), "could not be configured in time")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus409_WhenRoutesConflictAndRouteConflictsIsReject() {
+	defer func() { os.Unsetenv("ROUTE_CONFLICTS") }()
+	os.Setenv("ROUTE_CONFLICTS", "reject")
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxy.Instance = getConflictingProxyMock()
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, s.RequestReconfigure)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusConflict, rw.Code)
+	s.Equal("NOK", actual.Status)
+	s.Equal([]proxy.Conflict{{ServiceName: "other-service", Domain: "my-domain.com", Path: "/path/to"}}, actual.Conflicts)
+	mockObj.AssertNotCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_SuffixesAclName_WhenAclNameIsUsedByAnotherService() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxy.Instance = getConflictingProxyMock()
+	mockObj := getReconfigureMock("")
+	var actualService proxy.Service
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		actualService = serviceData
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/admin&port=1234&aclName=other-service", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	expected := proxy.GetUniqueAclName(proxy.Service{ServiceName: "my-service", AclName: "other-service"})
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal(expected, actualService.AclName)
+	s.Equal(expected, actual.Service.AclName)
+	s.Equal(
+		[]string{fmt.Sprintf("The ACL name other-service is already used by the service other-service. The ACL name %s is used instead.", expected)},
+		actual.Warnings,
+	)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus409_WhenAclNameIsUsedByAnotherServiceAndAclNameCollisionsIsReject() {
+	defer func() { os.Unsetenv("ACL_NAME_COLLISIONS") }()
+	os.Setenv("ACL_NAME_COLLISIONS", "reject")
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxy.Instance = getConflictingProxyMock()
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/admin&port=1234&aclName=other-service", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusConflict, rw.Code)
+	s.Equal("NOK", actual.Status)
+	s.Equal("The ACL name other-service of the service my-service is already used by the service other-service", actual.Message)
+	mockObj.AssertNotCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecuteAndReturnsConflicts_WhenRoutesConflict() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxy.Instance = getConflictingProxyMock()
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, s.RequestReconfigure)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal("OK", actual.Status)
+	s.Contains(actual.Message, "other-service")
+	s.Len(actual.Conflicts, 1)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsWarnings_WhenParametersRequireNewerHaProxy() {
+	defer proxy.SetHaProxyVersion(nil)
+	proxy.SetHaProxyVersion(&proxy.HaProxyVersion{Major: 1, Minor: 7})
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&bandwidthLimitTotal=12500000", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusOK, rw.Code)
+	s.Len(actual.Warnings, 1)
+	s.Contains(actual.Warnings[0], "bandwidthLimitTotal")
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_Execute_DetectsHaProxyVersion() {
+	invoked := false
+	detectHaProxyVersion = func() (proxy.HaProxyVersion, error) {
+		invoked = true
+		return proxy.HaProxyVersion{Major: 1, Minor: 7}, nil
+	}
+
+	serverImpl.Execute([]string{})
+
+	s.True(invoked)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeReconfigureExecute_WhenServiceIsAlreadyConfiguredWithSameParameters() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{s.ServiceName: {ServiceName: s.ServiceName}})
+	proxy.Instance = proxyMock
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	srv := Serve{}
+	rw := httptest.NewRecorder()
+	srv.ServeHTTP(rw, s.RequestReconfigure)
+	s.Equal(`"1"`, rw.Header().Get("ETag"))
+
+	rw = httptest.NewRecorder()
+	srv.ServeHTTP(rw, s.RequestReconfigure)
+
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal(`"1"`, rw.Header().Get("ETag"))
+	mockObj.AssertNumberOfCalls(s.T(), "Execute", 1)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenServiceWithSameParametersWasRemovedWithKeepState() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{})
+	proxy.Instance = proxyMock
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	srv := Serve{}
+	srv.ServeHTTP(httptest.NewRecorder(), s.RequestReconfigure)
 
-	s.invokesReconfigure(req, true)
+	rw := httptest.NewRecorder()
+	srv.ServeHTTP(rw, s.RequestReconfigure)
+
+	s.Equal(http.StatusOK, rw.Code)
+	mockObj.AssertNumberOfCalls(s.T(), "Execute", 2)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeReconfigureExecute_WhenDistributeIsTrue() {
-	req, _ := http.NewRequest(
-		"GET",
-		fmt.Sprintf("%s&distribute=true", s.ReconfigureUrl),
-		nil,
-	)
-	s.invokesReconfigure(req, false)
+func (s *ServerTestSuite) Test_ServeHTTP_ReleasesReconfigureLock_WhileWaitingForReload() {
+	started := make(chan bool)
+	release := make(chan bool)
+	reload = ReloadMock{ExecuteMock: func(recreate bool, listenerAddr string) error {
+		started <- true
+		<-release
+		return nil
+	}}
+	done := make(chan int)
+	go func() {
+		rw := httptest.NewRecorder()
+		srv := Serve{}
+		srv.ServeHTTP(rw, s.RequestReconfigure)
+		done <- rw.Code
+	}()
+	<-started
+
+	locked := make(chan bool)
+	go func() {
+		reconfigureMu.Lock()
+		reconfigureMu.Unlock()
+		locked <- true
+	}()
+
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		s.Fail("The reconfigure lock is held while the reload is awaited")
+	}
+	close(release)
+	s.Equal(http.StatusOK, <-done)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeRemoveExecute_WhenDistributeIsTrue() {
-	req, _ := http.NewRequest(
-		"GET",
-		fmt.Sprintf("%s&distribute=true", s.RemoveUrl),
-		nil,
-	)
-	s.invokesReconfigure(req, false)
+func (s *ServerTestSuite) Test_ServeHTTP_ReconfiguresServiceAgain_WhenReloadFailed() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{s.ServiceName: {ServiceName: s.ServiceName}})
+	proxy.Instance = proxyMock
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	reload = ReloadMock{ExecuteMock: func(recreate bool, listenerAddr string) error {
+		return fmt.Errorf("This is an error")
+	}}
+	srv := Serve{}
+	rw := httptest.NewRecorder()
+	srv.ServeHTTP(rw, s.RequestReconfigure)
+	s.Equal(http.StatusInternalServerError, rw.Code)
+	reload = ReloadMock{ExecuteMock: func(recreate bool, listenerAddr string) error { return nil }}
+
+	rw = httptest.NewRecorder()
+	srv.ServeHTTP(rw, s.RequestReconfigure)
+
+	s.Equal(http.StatusOK, rw.Code)
+	mockObj.AssertNumberOfCalls(s.T(), "Execute", 2)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenReconfigureExecuteFails() {
-	mockObj := getReconfigureMock("Execute")
-	mockObj.On("Execute", []string{}).Return(fmt.Errorf("This is an error"))
+func (s *ServerTestSuite) Test_ServeHTTP_ReloadsUrgently_WhenServiceIsCritical() {
+	invoked := false
+	urgentReload = ReloadMock{ExecuteMock: func(recreate bool, listenerAddr string) error {
+		invoked = true
+		return nil
+	}}
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&critical=true", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusOK, rw.Code)
+	s.True(invoked)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenZoneAwareServiceIsConfiguredWithSameParameters() {
+	mockObj := getReconfigureMock("")
 	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
 		return mockObj
 	}
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&zoneAware=true", nil)
+	srv := Serve{}
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	rw := httptest.NewRecorder()
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusOK, rw.Code)
+	mockObj.AssertNumberOfCalls(s.T(), "Execute", 2)
+}
 
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus412_WhenVersionDoesNotMatch() {
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
 	srv := Serve{}
-	srv.ServeHTTP(s.ResponseWriter, s.RequestReconfigure)
+	srv.ServeHTTP(httptest.NewRecorder(), s.RequestReconfigure)
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&aclName=blue", nil)
+	req.Header.Set("If-Match", `"0"`)
+	rw := httptest.NewRecorder()
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusPreconditionFailed, rw.Code)
+	s.Equal(`"1"`, rw.Header().Get("ETag"))
+	mockObj.AssertNumberOfCalls(s.T(), "Execute", 1)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenVersionMatches() {
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	srv := Serve{}
+	srv.ServeHTTP(httptest.NewRecorder(), s.RequestReconfigure)
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&aclName=blue&version=1", nil)
+	rw := httptest.NewRecorder()
+
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal(`"2"`, rw.Header().Get("ETag"))
+	mockObj.AssertNumberOfCalls(s.T(), "Execute", 2)
 }
 
 func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJson_WhenConsulTemplatePathIsPresent() {
@@ -1121,76 +3403,446 @@ func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenConsulTem
 		ReqMode:              "http",
 		ServiceDest:          []proxy.ServiceDest{sd},
 	}
-	var actualService proxy.Service
+	var actualService proxy.Service
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		actualBase = baseData
+		actualService = serviceData
+		return mockObj
+	}
+	serverImpl := Serve{BaseReconfigure: expectedBase}
+	address := fmt.Sprintf(
+		"%s?serviceName=%s&consulTemplateFePath=%s&consulTemplateBePath=%s",
+		s.ReconfigureBaseUrl,
+		s.ServiceName,
+		pathFe,
+		pathBe)
+	req, _ := http.NewRequest("GET", address, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(expectedBase, actualBase)
+	s.Equal(expectedService, actualService)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCert_WhenServiceCertIsPresent() {
+	actualCertName := ""
+	expectedCert := "my-cert with new line \\n"
+	actualCert := ""
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		PutCertMock: func(certName string, certContent []byte) (string, error) {
+			actualCertName = certName
+			actualCert = string(certContent[:])
+			return "", nil
+		},
+	}
+	address := fmt.Sprintf(
+		"%s?serviceName=%s&servicePath=%s&serviceCert=%s",
+		s.ReconfigureBaseUrl,
+		s.ServiceName,
+		strings.Join(s.sd.ServicePath, ","),
+		expectedCert,
+	)
+	req, _ := http.NewRequest("GET", address, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(s.ServiceName, actualCertName)
+	s.Equal(strings.Replace(expectedCert, "\\n", "\n", -1), actualCert)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCertWithDomainName_WhenServiceCertIsPresent() {
+	actualCertName := ""
+	expectedCert := "my-cert"
+	actualCert := ""
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		PutCertMock: func(certName string, certContent []byte) (string, error) {
+			actualCertName = certName
+			actualCert = string(certContent[:])
+			return "", nil
+		},
+	}
+	address := fmt.Sprintf("%s&serviceDomain=%s&serviceCert=%s", s.ReconfigureUrl, s.ServiceDomain[0], expectedCert)
+	req, _ := http.NewRequest("GET", address, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(s.ServiceDomain[0], actualCertName)
+	s.Equal(expectedCert, actualCert)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCertForEachDomain_WhenServiceCertsArePresent() {
+	actualCerts := map[string]string{}
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		PutCertMock: func(certName string, certContent []byte) (string, error) {
+			actualCerts[certName] = string(certContent[:])
+			return "", nil
+		},
+	}
+	address := fmt.Sprintf(
+		"%s?serviceName=%s&servicePath=%s&serviceDomain=acme.com,acme.org&serviceCert.acme.com=cert-1\\n&serviceCert.acme.org=cert-2",
+		s.ReconfigureBaseUrl,
+		s.ServiceName,
+		strings.Join(s.sd.ServicePath, ","),
+	)
+	req, _ := http.NewRequest("GET", address, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(map[string]string{"acme.com": "cert-1\n", "acme.org": "cert-2"}, actualCerts)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCertWithSecretContent_WhenCertSecretIsPresent() {
+	actualCertName := ""
+	actualCert := ""
+	actualPath := ""
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		PutCertMock: func(certName string, certContent []byte) (string, error) {
+			actualCertName = certName
+			actualCert = string(certContent[:])
+			return "", nil
+		},
+	}
+	readCertSecretOrig := readCertSecret
+	defer func() { readCertSecret = readCertSecretOrig }()
+	readCertSecret = func(filename string) ([]byte, error) {
+		actualPath = filename
+		return []byte("my-cert"), nil
+	}
+	address := fmt.Sprintf("%s&serviceDomain=%s&certSecret=my_cert", s.ReconfigureUrl, s.ServiceDomain[0])
+	req, _ := http.NewRequest("GET", address, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal("/run/secrets/my_cert", actualPath)
+	s.Equal(s.ServiceDomain[0], actualCertName)
+	s.Equal("my-cert", actualCert)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenCertSecretCannotBeRead() {
+	readCertSecretOrig := readCertSecret
+	defer func() { readCertSecret = readCertSecretOrig }()
+	readCertSecret = func(filename string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+	address := fmt.Sprintf("%s&certSecret=my_cert", s.ReconfigureUrl)
+	req, _ := http.NewRequest("GET", address, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotReadCertSecret_WhenNameLeavesSecrets() {
+	readCertSecretOrig := readCertSecret
+	defer func() { readCertSecret = readCertSecretOrig }()
+	invoked := false
+	readCertSecret = func(filename string) ([]byte, error) {
+		invoked = true
+		return []byte("my-cert"), nil
+	}
+	address := fmt.Sprintf("%s&certSecret=../../etc/shadow", s.ReconfigureUrl)
+	req, _ := http.NewRequest("GET", address, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.False(invoked)
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+// ServeHTTP > Service Resource
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsServiceWithETag_WhenServiceResourceIsRequested() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service", ReqMode: "http"}})
+	proxy.Instance = proxyMock
+	serviceVersions.Put("my-service", "hash")
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/service/my-service", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal(`"1"`, rw.Header().Get("ETag"))
+	s.Equal("http", actual.ReqMode)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus304_WhenServiceResourceMatchesIfNoneMatch() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service"}})
+	proxy.Instance = proxyMock
+	serviceVersions.Put("my-service", "hash")
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/service/my-service", nil)
+	req.Header.Set("If-None-Match", `"1"`)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusNotModified, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenServiceResourceIsNotConfigured() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{})
+	proxy.Instance = proxyMock
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/service/my-service", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusNotFound, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeRemove_WhenDeletedServiceResourceIsNotConfigured() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{})
+	proxy.Instance = proxyMock
+	newRemoveOrig := actions.NewRemove
+	defer func() { actions.NewRemove = newRemoveOrig }()
+	invoked := false
+	actions.NewRemove = func(
+		serviceName, aclName, configsPath, templatesPath string,
+		consulAddresses []string,
+		instanceName, mode string,
+		options actions.RemoveOptions,
+	) actions.Removable {
+		invoked = true
+		return getRemoveMock("")
+	}
+	req, _ := http.NewRequest("DELETE", "/v1/docker-flow-proxy/service/my-service", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusOK, rw.Code)
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotRemoveServiceResource_WhenIfMatchDoesNotMatch() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service"}})
+	proxy.Instance = proxyMock
+	serviceVersions.Put("my-service", "hash")
+	mockObj := getRemoveMock("")
+	newRemoveOrig := actions.NewRemove
+	defer func() { actions.NewRemove = newRemoveOrig }()
+	actions.NewRemove = func(
+		serviceName, aclName, configsPath, templatesPath string,
+		consulAddresses []string,
+		instanceName, mode string,
+		options actions.RemoveOptions,
+	) actions.Removable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("DELETE", "/v1/docker-flow-proxy/service/my-service", nil)
+	req.Header.Set("If-Match", `"5"`)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusPreconditionFailed, rw.Code)
+	s.Equal(`"1"`, rw.Header().Get("ETag"))
+	mockObj.AssertNotCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_RemovesServiceResource_WhenIfMatchMatches() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service"}})
+	proxy.Instance = proxyMock
+	serviceVersions.Put("my-service", "hash")
+	mockObj := getRemoveMock("")
+	newRemoveOrig := actions.NewRemove
+	defer func() { actions.NewRemove = newRemoveOrig }()
+	actions.NewRemove = func(
+		serviceName, aclName, configsPath, templatesPath string,
+		consulAddresses []string,
+		instanceName, mode string,
+		options actions.RemoveOptions,
+	) actions.Removable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("DELETE", "/v1/docker-flow-proxy/service/my-service", nil)
+	req.Header.Set("If-Match", `"1"`)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusOK, rw.Code)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReconfiguresServiceOfPath_WhenServiceResourceIsPut() {
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	var actualService proxy.Service
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		actualService = serviceData
+		return getReconfigureMock("")
+	}
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/service/my-service?servicePath=/demo&port=8080", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal("my-service", actualService.ServiceName)
+	s.Equal(`"1"`, rw.Header().Get("ETag"))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus412_WhenIfNoneMatchIsAnyAndServiceIsConfigured() {
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	srv := Serve{}
+	srv.ServeHTTP(httptest.NewRecorder(), s.RequestReconfigure)
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&aclName=blue", nil)
+	req.Header.Set("If-None-Match", "*")
+	rw := httptest.NewRecorder()
+
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusPreconditionFailed, rw.Code)
+	mockObj.AssertNumberOfCalls(s.T(), "Execute", 1)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus412_WhenIfMatchIsAnyAndServiceIsNotConfigured() {
+	mockObj := getReconfigureMock("")
 	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
-		actualBase = baseData
-		actualService = serviceData
 		return mockObj
 	}
-	serverImpl := Serve{BaseReconfigure: expectedBase}
-	address := fmt.Sprintf(
-		"%s?serviceName=%s&consulTemplateFePath=%s&consulTemplateBePath=%s",
-		s.ReconfigureBaseUrl,
-		s.ServiceName,
-		pathFe,
-		pathBe)
-	req, _ := http.NewRequest("GET", address, nil)
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+	req.Header.Set("If-Match", "*")
+	rw := httptest.NewRecorder()
 
-	serverImpl.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
 
-	s.Equal(expectedBase, actualBase)
-	s.Equal(expectedService, actualService)
-	mockObj.AssertCalled(s.T(), "Execute", []string{})
+	s.Equal(http.StatusPreconditionFailed, rw.Code)
+	mockObj.AssertNumberOfCalls(s.T(), "Execute", 0)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCert_WhenServiceCertIsPresent() {
-	actualCertName := ""
-	expectedCert := "my-cert with new line \\n"
-	actualCert := ""
-	certOrig := cert
-	defer func() { cert = certOrig }()
-	cert = CertMock{
-		PutCertMock: func(certName string, certContent []byte) (string, error) {
-			actualCertName = certName
-			actualCert = string(certContent[:])
-			return "", nil
-		},
+// ServeHTTP > Drain Server
+
+func (s *ServerTestSuite) Test_ServeHTTP_DrainsServersWithTheAddress_WhenDrainIsPut() {
+	proxyOrig := proxy.Instance
+	setServerDrainOrig := setServerDrain
+	defer func() {
+		proxy.Instance = proxyOrig
+		setServerDrain = setServerDrainOrig
+	}()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service"}})
+	proxy.Instance = proxyMock
+	actualServiceName, actualAddress, actualDrain := "", "", false
+	setServerDrain = func(serviceName, address string, drain bool) ([]string, error) {
+		actualServiceName, actualAddress, actualDrain = serviceName, address, drain
+		return []string{"my-service-be8080/my-service_a"}, nil
 	}
-	address := fmt.Sprintf(
-		"%s?serviceName=%s&servicePath=%s&serviceCert=%s",
-		s.ReconfigureBaseUrl,
-		s.ServiceName,
-		strings.Join(s.sd.ServicePath, ","),
-		expectedCert,
-	)
-	req, _ := http.NewRequest("GET", address, nil)
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/service/my-service/server/10.0.0.5/drain", nil)
+	rw := httptest.NewRecorder()
 
-	serverImpl.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal("my-service", actualServiceName)
+	s.Equal("10.0.0.5", actualAddress)
+	s.True(actualDrain)
+	s.Equal("The servers my-service-be8080/my-service_a are draining", actual.Message)
+}
 
-	s.Equal(s.ServiceName, actualCertName)
-	s.Equal(strings.Replace(expectedCert, "\\n", "\n", -1), actualCert)
+func (s *ServerTestSuite) Test_ServeHTTP_MakesServersReady_WhenDrainIsDeleted() {
+	proxyOrig := proxy.Instance
+	setServerDrainOrig := setServerDrain
+	defer func() {
+		proxy.Instance = proxyOrig
+		setServerDrain = setServerDrainOrig
+	}()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service"}})
+	proxy.Instance = proxyMock
+	actualDrain := true
+	setServerDrain = func(serviceName, address string, drain bool) ([]string, error) {
+		actualDrain = drain
+		return []string{"my-service-be8080/my-service_a"}, nil
+	}
+	req, _ := http.NewRequest("DELETE", "/v1/docker-flow-proxy/service/my-service/server/10.0.0.5/drain", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusOK, rw.Code)
+	s.False(actualDrain)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCertWithDomainName_WhenServiceCertIsPresent() {
-	actualCertName := ""
-	expectedCert := "my-cert"
-	actualCert := ""
-	certOrig := cert
-	defer func() { cert = certOrig }()
-	cert = CertMock{
-		PutCertMock: func(certName string, certContent []byte) (string, error) {
-			actualCertName = certName
-			actualCert = string(certContent[:])
-			return "", nil
-		},
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenServiceDoesNotHaveServersWithTheAddress() {
+	proxyOrig := proxy.Instance
+	setServerDrainOrig := setServerDrain
+	defer func() {
+		proxy.Instance = proxyOrig
+		setServerDrain = setServerDrainOrig
+	}()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{"my-service": {ServiceName: "my-service"}})
+	proxy.Instance = proxyMock
+	setServerDrain = func(serviceName, address string, drain bool) ([]string, error) {
+		return []string{}, nil
 	}
-	address := fmt.Sprintf("%s&serviceDomain=%s&serviceCert=%s", s.ReconfigureUrl, s.ServiceDomain[0], expectedCert)
-	req, _ := http.NewRequest("GET", address, nil)
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/service/my-service/server/10.0.0.5/drain", nil)
+	rw := httptest.NewRecorder()
 
-	serverImpl.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
 
-	s.Equal(s.ServiceDomain[0], actualCertName)
-	s.Equal(expectedCert, actualCert)
+	s.Equal(http.StatusNotFound, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenDrainedServiceIsNotConfigured() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{})
+	proxy.Instance = proxyMock
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/service/my-service/server/10.0.0.5/drain", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusNotFound, rw.Code)
 }
 
 // ServeHTTP > Remove
@@ -1212,6 +3864,7 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJSON_WhenUrlIsRemove() {
 	expected, _ := json.Marshal(server.Response{
 		Status:      "OK",
 		ServiceName: s.ServiceName,
+		Removed:     &actions.Removed{},
 	})
 
 	srv := Serve{}
@@ -1245,6 +3898,7 @@ func (s *ServerTestSuite) Test_ServeHTTP_InvokesRemoveExecute() {
 		serviceName, aclName, configsPath, templatesPath string,
 		consulAddresses []string,
 		instanceName, mode string,
+		options actions.RemoveOptions,
 	) actions.Removable {
 		actual = actions.Remove{
 			ServiceName:     serviceName,
@@ -1266,6 +3920,242 @@ func (s *ServerTestSuite) Test_ServeHTTP_InvokesRemoveExecute() {
 	mockObj.AssertCalled(s.T(), "Execute", []string{})
 }
 
+func (s *ServerTestSuite) Test_ServeHTTP_DrainsServiceWithoutBlockingOtherRequests_WhenDrainFirstIsTrue() {
+	mockObj := getRemoveMock("")
+	newRemoveOrig := actions.NewRemove
+	defer func() { actions.NewRemove = newRemoveOrig }()
+	actions.NewRemove = func(
+		serviceName, aclName, configsPath, templatesPath string,
+		consulAddresses []string,
+		instanceName, mode string,
+		options actions.RemoveOptions,
+	) actions.Removable {
+		return mockObj
+	}
+	drainSleepOrig := drainSleep
+	defer func() { drainSleep = drainSleepOrig }()
+	var actualTimeout time.Duration
+	unlocked := false
+	drainSleep = func(d time.Duration) {
+		actualTimeout = d
+		mockObj.AssertCalled(s.T(), "Drain")
+		mockObj.AssertNotCalled(s.T(), "Execute", []string{})
+		locked := make(chan bool)
+		go func() {
+			reconfigureMu.Lock()
+			reconfigureMu.Unlock()
+			locked <- true
+		}()
+		select {
+		case unlocked = <-locked:
+		case <-time.After(time.Second):
+		}
+	}
+	req, _ := http.NewRequest("GET", s.RemoveUrl+"&drainFirst=true", nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(5*time.Second, actualTimeout)
+	s.True(unlocked)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotRemoveService_WhenItIsReconfiguredWhileDraining() {
+	mockObj := getRemoveMock("")
+	newRemoveOrig := actions.NewRemove
+	defer func() { actions.NewRemove = newRemoveOrig }()
+	actions.NewRemove = func(
+		serviceName, aclName, configsPath, templatesPath string,
+		consulAddresses []string,
+		instanceName, mode string,
+		options actions.RemoveOptions,
+	) actions.Removable {
+		return mockObj
+	}
+	drainSleepOrig := drainSleep
+	defer func() { drainSleep = drainSleepOrig }()
+	drainSleep = func(d time.Duration) {
+		serviceVersions.Put(s.ServiceName, "new-hash")
+	}
+	serviceVersions.Put(s.ServiceName, "hash")
+	req, _ := http.NewRequest("GET", s.RemoveUrl+"&drainFirst=true&version=1", nil)
+	rw := httptest.NewRecorder()
+
+	serverImpl.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusPreconditionFailed, rw.Code)
+	s.Equal(`"2"`, rw.Header().Get("ETag"))
+	mockObj.AssertCalled(s.T(), "Drain")
+	mockObj.AssertNotCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_PassesRemoveOptionsAndReturnsRemovedArtifacts() {
+	mockObj := getRemoveMock("GetRemoved")
+	removed := actions.Removed{Frontend: true, Backend: true, Certs: []string{"/certs/myService"}}
+	mockObj.On("GetRemoved").Return(removed)
+	var actual actions.RemoveOptions
+	newRemoveOrig := actions.NewRemove
+	defer func() { actions.NewRemove = newRemoveOrig }()
+	actions.NewRemove = func(
+		serviceName, aclName, configsPath, templatesPath string,
+		consulAddresses []string,
+		instanceName, mode string,
+		options actions.RemoveOptions,
+	) actions.Removable {
+		actual = options
+		return mockObj
+	}
+	drainSleepOrig := drainSleep
+	defer func() { drainSleep = drainSleepOrig }()
+	drainSleep = func(d time.Duration) {}
+	url := fmt.Sprintf("%s&removeCerts=true&drainFirst=true&keepState=true", s.RemoveUrl)
+	req, _ := http.NewRequest("GET", url, nil)
+	expected, _ := json.Marshal(server.Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Removed:     &removed,
+	})
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(actions.RemoveOptions{RemoveCerts: true, DrainFirst: true, DrainTimeout: 5, KeepState: true}, actual)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+// ServeHTTP > AcmeChallenge
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsAcmeChallenge() {
+	defer func() { os.Unsetenv("ACME_CHALLENGE_PATH") }()
+	os.Setenv("ACME_CHALLENGE_PATH", "/var/www/acme")
+	readAcmeChallengeOrig := readAcmeChallenge
+	defer func() { readAcmeChallenge = readAcmeChallengeOrig }()
+	actualFilename := ""
+	readAcmeChallenge = func(filename string) ([]byte, error) {
+		actualFilename = filename
+		return []byte("my-token.my-thumbprint"), nil
+	}
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/acme-challenge?token=my-token", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusOK, rw.Code)
+	s.Equal("my-token.my-thumbprint", rw.Body.String())
+	s.Equal("/var/www/acme/.well-known/acme-challenge/my-token", actualFilename)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenAcmeChallengeTokenIsInvalid() {
+	defer func() { os.Unsetenv("ACME_CHALLENGE_PATH") }()
+	os.Setenv("ACME_CHALLENGE_PATH", "/var/www/acme")
+	readAcmeChallengeOrig := readAcmeChallenge
+	defer func() { readAcmeChallenge = readAcmeChallengeOrig }()
+	readAcmeChallenge = func(filename string) ([]byte, error) {
+		return []byte("secret"), nil
+	}
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/acme-challenge?token=../../etc/passwd", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusNotFound, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenAcmeChallengeDoesNotExist() {
+	defer func() { os.Unsetenv("ACME_CHALLENGE_PATH") }()
+	os.Setenv("ACME_CHALLENGE_PATH", "/var/www/acme")
+	readAcmeChallengeOrig := readAcmeChallenge
+	defer func() { readAcmeChallenge = readAcmeChallengeOrig }()
+	readAcmeChallenge = func(filename string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/acme-challenge?token=my-token", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusNotFound, rw.Code)
+}
+
+// ServeHTTP > Preview
+
+func (s *ServerTestSuite) Test_ServeHTTP_ConfiguresPreviewServiceAndRedirects() {
+	defer func() { os.Unsetenv("PREVIEW_DOMAIN") }()
+	os.Setenv("PREVIEW_DOMAIN", "preview.acme.com")
+	mockObj := getReconfigureMock("")
+	var actualService proxy.Service
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		actualService = serviceData
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/preview", nil)
+	req.Host = "Feature-X.preview.acme.com:80"
+	req.Header.Set("X-Preview-Uri", "/demo/hello?x=1")
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusTemporaryRedirect, rw.Code)
+	s.Equal("/demo/hello?x=1", rw.Header().Get("Location"))
+	s.Equal(proxy.Service{
+		ServiceName:   "feature-x_web",
+		ServiceDomain: []string{"feature-x.preview.acme.com"},
+		ReqMode:       "http",
+		ServiceDest:   []proxy.ServiceDest{{Port: "80", ServicePath: []string{"/"}}},
+	}, actualService)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenPreviewServiceDoesNotExist() {
+	defer func() { os.Unsetenv("PREVIEW_DOMAIN") }()
+	os.Setenv("PREVIEW_DOMAIN", "preview.acme.com")
+	lookupHostOrig := lookupHost
+	defer func() { lookupHost = lookupHostOrig }()
+	lookupHost = func(host string) ([]string, error) {
+		return []string{}, fmt.Errorf("No such host")
+	}
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/preview", nil)
+	req.Host = "feature-x.preview.acme.com"
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(http.StatusNotFound, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenHostIsNotPreviewDomain() {
+	defer func() { os.Unsetenv("PREVIEW_DOMAIN") }()
+	os.Setenv("PREVIEW_DOMAIN", "preview.acme.com")
+	for _, host := range []string{"acme.com", "a.b.preview.acme.com", "preview.acme.com"} {
+		req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/preview", nil)
+		req.Host = host
+		rw := httptest.NewRecorder()
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(http.StatusNotFound, rw.Code, host)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_SetsPreviewDomain_WhenServiceDomainIsNotPresent() {
+	defer func() { os.Unsetenv("PREVIEW_DOMAIN") }()
+	os.Setenv("PREVIEW_DOMAIN", "preview.acme.com")
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=feature-x_web&servicePath=/&port=8080&distribute=true", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := server.Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal([]string{"feature-x.preview.acme.com"}, actual.ServiceDomain)
+}
+
 // ServeHTTP > Config
 
 func (s *ServerTestSuite) Test_ServeHTTP_SetsContentTypeToText_WhenUrlIsConfig() {
@@ -1313,6 +4203,156 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenReadFileFails() {
 
 
 
+// ServeHTTP > Debug Render
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsTemplates_WhenUrlIsDebugRender() {
+	mockObj := getReconfigureMock("GetTemplates")
+	mockObj.On("GetTemplates", mock.Anything).Return("the front", "the back", nil)
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	url := strings.Replace(s.ReconfigureUrl, "/reconfigure", "/debug/render", -1)
+	req, _ := http.NewRequest("GET", url, nil)
+	expected, _ := json.Marshal(server.RenderResponse{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		Frontend:    "the front",
+		Backend:     "the back",
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", expected)
+	mockObj.AssertNotCalled(s.T(), "Execute", mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_RendersFrontend_WhenUrlIsDebugRenderAndTemplatesDoNotReturnIt() {
+	url := fmt.Sprintf("%s/debug/render?serviceName=my-service&servicePath=/demo&port=1234", s.BaseUrl)
+	req, _ := http.NewRequest("GET", url, nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := server.RenderResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal(`
+    acl url_my-service_1234 path_beg /demo
+    use_backend my-service-be1234 if url_my-service_1234`, actual.Frontend)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsDebugRenderAndServiceNameIsNotPresent() {
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/debug/render", s.BaseUrl), nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+// ServeHTTP > Status
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus_WhenUrlIsStatus() {
+	expected, _ := json.Marshal(proxy.GetStatus())
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/status", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", expected)
+}
+
+// ServeHTTP > Metrics
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsPrometheusMetrics_WhenUrlIsMetrics() {
+	st := proxy.GetStatus()
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/metrics", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Contains(rw.Body.String(), "# TYPE dfp_reloads_total counter\n")
+	s.Contains(rw.Body.String(), fmt.Sprintf("\ndfp_reloads_total %d\n", st.ReloadCount))
+	s.Contains(rw.Body.String(), fmt.Sprintf("\ndfp_reload_failures_total %d\n", st.ReloadFailureCount))
+	s.Contains(rw.Body.String(), "\ndfp_last_reload_duration_seconds ")
+	s.Contains(rw.Body.String(), "\ndfp_last_config_timestamp_seconds ")
+	lastReloadSuccess := 0
+	if st.ReloadCount > 0 && len(st.LastReloadError) == 0 {
+		lastReloadSuccess = 1
+	}
+	s.Contains(rw.Body.String(), fmt.Sprintf("\ndfp_last_reload_success %d\n", lastReloadSuccess))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsBackendMetricsLabeledByServiceAndDomain_WhenUrlIsMetrics() {
+	getAllBackendStatsOrig := getAllBackendStats
+	defer func() { getAllBackendStats = getAllBackendStatsOrig }()
+	getAllBackendStats = func() ([]proxy.BackendStats, error) {
+		return []proxy.BackendStats{
+			{Name: "my-service-be8080", Status: "UP", TotalRequests: 25, Http4xxResponses: 3, Http5xxResponses: 2, ResponseTime: 150},
+			{Name: "acme-challenge-be", Status: "UP", TotalRequests: 7},
+		}, nil
+	}
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{
+		"my-service": {ServiceName: "my-service", ServiceDomain: []string{"acme.com", "www.acme.com"}},
+	})
+	proxy.Instance = proxyMock
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/metrics", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	labels := `service="my-service",domain="acme.com,www.acme.com",backend="my-service-be8080"`
+	s.Equal(200, rw.Code)
+	s.Contains(rw.Body.String(), "# TYPE dfp_backend_requests_total counter\n")
+	s.Contains(rw.Body.String(), "\ndfp_backend_up{"+labels+"} 1\n")
+	s.Contains(rw.Body.String(), "\ndfp_backend_requests_total{"+labels+"} 25\n")
+	s.Contains(rw.Body.String(), "\ndfp_backend_responses_total{"+labels+`,code="4xx"} 3`+"\n")
+	s.Contains(rw.Body.String(), "\ndfp_backend_responses_total{"+labels+`,code="5xx"} 2`+"\n")
+	s.Contains(rw.Body.String(), "\ndfp_backend_response_time_seconds{"+labels+"} 0.15\n")
+	s.NotContains(rw.Body.String(), "acme-challenge-be")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsRequestDurationHistograms_WhenLogMetricsAreCollected() {
+	getAllBackendStatsOrig := getAllBackendStats
+	defer func() { getAllBackendStats = getAllBackendStatsOrig }()
+	getAllBackendStats = func() ([]proxy.BackendStats, error) {
+		return []proxy.BackendStats{}, nil
+	}
+	defer func() { logMetrics = nil }()
+	logMetrics = proxy.NewLogMetrics()
+	logMetrics.Observe(proxy.HttpLogEntry{Backend: "my-service-be8080", Status: "200", TotalTime: 20})
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{
+		"my-service": {ServiceName: "my-service", ServiceDomain: []string{"acme.com"}},
+	})
+	proxy.Instance = proxyMock
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/metrics", nil)
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	labels := `service="my-service",domain="acme.com",backend="my-service-be8080"`
+	s.Contains(rw.Body.String(), "# TYPE dfp_backend_request_duration_seconds histogram\n")
+	s.Contains(rw.Body.String(), "\ndfp_backend_request_duration_seconds_bucket{"+labels+`,le="0.01"} 0`+"\n")
+	s.Contains(rw.Body.String(), "\ndfp_backend_request_duration_seconds_bucket{"+labels+`,le="0.025"} 1`+"\n")
+	s.Contains(rw.Body.String(), "\ndfp_backend_request_duration_seconds_bucket{"+labels+`,le="+Inf"} 1`+"\n")
+	s.Contains(rw.Body.String(), "\ndfp_backend_request_duration_seconds_sum{"+labels+"} 0.02\n")
+	s.Contains(rw.Body.String(), "\ndfp_backend_http_responses_total{"+labels+`,code="200"} 1`+"\n")
+}
+
 func (s *ServerTestSuite) Test_UsersMerge_AllCases(){
 	users := mergeUsers("someService", "user1:pass1,user2:pass2", "", false, "", false)
 	assert.DeepEqual(s.T(),users, []proxy.User{
@@ -1397,6 +4437,8 @@ func (s *ServerTestSuite) Test_UsersMerge_AllCases(){
 func TestServerUnitTestSuite(t *testing.T) {
 	s := new(ServerTestSuite)
 	logPrintf = func(format string, v ...interface{}) {}
+	logWarnf = func(format string, v ...interface{}) {}
+	logRequestf = func(req *http.Request, format string, v ...interface{}) {}
 	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		actualPath := r.URL.Path
 		if r.Method == "GET" {
@@ -1542,6 +4584,16 @@ func (m *ReconfigureMock) Execute(args []string) error {
 	return params.Error(0)
 }
 
+// ExecuteContext is recorded as Execute so that the assertions do not depend on which of the two is invoked
+func (m *ReconfigureMock) ExecuteContext(ctx context.Context) error {
+	return m.Execute([]string{})
+}
+
+// Import is recorded as Execute as well
+func (m *ReconfigureMock) Import(ctx context.Context) error {
+	return m.Execute([]string{})
+}
+
 func (m *ReconfigureMock) GetData() (actions.BaseReconfigure, proxy.Service) {
 	m.Called()
 	return actions.BaseReconfigure{}, proxy.Service{}
@@ -1583,16 +4635,44 @@ func (m *RemoveMock) Execute(args []string) error {
 	return params.Error(0)
 }
 
+func (m *RemoveMock) Drain() error {
+	params := m.Called()
+	return params.Error(0)
+}
+
+func (m *RemoveMock) GetRemoved() actions.Removed {
+	params := m.Called()
+	return params.Get(0).(actions.Removed)
+}
+
 func getRemoveMock(skipMethod string) *RemoveMock {
 	mockObj := new(RemoveMock)
 	if skipMethod != "Execute" {
 		mockObj.On("Execute", mock.Anything).Return(nil)
 	}
+	if skipMethod != "Drain" {
+		mockObj.On("Drain").Return(nil)
+	}
+	if skipMethod != "GetRemoved" {
+		mockObj.On("GetRemoved").Return(actions.Removed{})
+	}
 	return mockObj
 }
 
 // Util
 
+func getConflictingProxyMock() *ProxyMock {
+	mockObj := getProxyMock("GetServices")
+	mockObj.On("GetServices").Return(map[string]proxy.Service{
+		"other-service": {
+			ServiceName:   "other-service",
+			ServiceDomain: []string{"my-domain.com"},
+			ServiceDest:   []proxy.ServiceDest{{ServicePath: []string{"/path/to"}}},
+		},
+	})
+	return mockObj
+}
+
 func (s *ServerTestSuite) invokesReconfigure(req *http.Request, invoke bool) {
 	mockObj := getReconfigureMock("")
 	var actualBase actions.BaseReconfigure
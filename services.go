@@ -0,0 +1,188 @@
+package main
+
+import (
+	"./proxy"
+	"./server"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ServiceList is a page of the services of the proxy.
+type ServiceList struct {
+	// The number of the services that match the filters.
+	Total  int
+	Offset int
+	// The maximum number of services in the page. Zero when all the services after the offset are included.
+	Limit int
+	// The services or, when the fields query is specified, the selected fields of the services.
+	Services []interface{}
+}
+
+type serviceFilter struct {
+	field    int
+	value    string
+	contains bool
+}
+
+// listServices outputs the services sorted by their names. The passwords of the users and the certificates are redacted.
+// The filter queries (e.g. filter=serviceName~team-a) select the services with a parameter that contains (~) or equals (=) the value,
+// limit and offset select a page, and fields (e.g. fields=serviceName,serviceDomain) selects the parameters included in the output.
+func (m *Serve) listServices(w http.ResponseWriter, req *http.Request) {
+	if !m.authorizeNamespace(w, req) {
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	list, err := m.getServiceList(req)
+	if err != nil {
+		response := server.Response{}
+		m.writeBadRequest(w, &response, err.Error())
+		js, _ := json.Marshal(response)
+		w.Write(js)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	js, _ := json.Marshal(list)
+	w.Write(js)
+}
+
+func (m *Serve) getServiceList(req *http.Request) (ServiceList, error) {
+	list := ServiceList{Services: []interface{}{}}
+	query := req.URL.Query()
+	params := getServiceParamFields()
+	filters := []serviceFilter{}
+	for _, filter := range query["filter"] {
+		f, err := getServiceFilter(filter, params)
+		if err != nil {
+			return list, err
+		}
+		filters = append(filters, f)
+	}
+	fields := []int{}
+	if len(query.Get("fields")) > 0 {
+		for _, param := range strings.Split(query.Get("fields"), ",") {
+			field, ok := params[param]
+			if !ok {
+				return list, fmt.Errorf("%s is not a parameter of the services", param)
+			}
+			fields = append(fields, field)
+		}
+	}
+	for _, param := range []string{"limit", "offset"} {
+		if value := query.Get(param); len(value) > 0 {
+			if i, err := strconv.Atoi(value); err != nil || i < 0 {
+				return list, fmt.Errorf("%s must be a positive number", param)
+			}
+		}
+	}
+	list.Limit, _ = strconv.Atoi(query.Get("limit"))
+	list.Offset, _ = strconv.Atoi(query.Get("offset"))
+	namespace := query.Get("namespace")
+	services := proxy.Instance.GetServices()
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	matching := []proxy.Service{}
+	for _, name := range names {
+		sr := services[name]
+		if len(namespace) > 0 && sr.Namespace != namespace {
+			continue
+		}
+		if matchesServiceFilters(sr, filters) {
+			matching = append(matching, sr)
+		}
+	}
+	list.Total = len(matching)
+	for i := list.Offset; i < len(matching) && (list.Limit == 0 || i < list.Offset+list.Limit); i++ {
+		sr := proxy.RedactService(matching[i])
+		if len(fields) == 0 {
+			list.Services = append(list.Services, sr)
+			continue
+		}
+		// The fields are output with the same names as in the services without the fields query
+		v := reflect.ValueOf(sr)
+		selected := map[string]interface{}{}
+		for _, field := range fields {
+			selected[v.Type().Field(field).Name] = v.Field(field).Interface()
+		}
+		list.Services = append(list.Services, selected)
+	}
+	return list, nil
+}
+
+// getServiceParamFields returns the indexes of the fields of proxy.Service keyed by their parameter names.
+// The name parameter is an alias of serviceName.
+func getServiceParamFields() map[string]int {
+	fields := map[string]int{}
+	t := reflect.TypeOf(proxy.Service{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := proxy.GetParamName(t.Field(i)); len(name) > 0 {
+			fields[name] = i
+		}
+	}
+	fields["name"] = fields["serviceName"]
+	return fields
+}
+
+// getServiceFilter parses filters formatted as <param>~<value> or <param>=<value>.
+func getServiceFilter(filter string, params map[string]int) (serviceFilter, error) {
+	i := strings.IndexAny(filter, "~=")
+	if i <= 0 {
+		return serviceFilter{}, fmt.Errorf("The filter %s must be formatted as <param>~<value> or <param>=<value>", filter)
+	}
+	field, ok := params[filter[:i]]
+	if !ok {
+		return serviceFilter{}, fmt.Errorf("%s is not a parameter of the services", filter[:i])
+	}
+	// The certificates are not output so they cannot be searched either
+	if getServiceFieldValues(reflect.ValueOf(proxy.Service{}).Field(field)) == nil || reflect.TypeOf(proxy.Service{}).Field(field).Name == "ServiceCert" {
+		return serviceFilter{}, fmt.Errorf("The services cannot be filtered by %s", filter[:i])
+	}
+	value := filter[i+1:]
+	if reflect.TypeOf(proxy.Service{}).Field(field).Name == "LoggingDisabled" {
+		// The parameter is the inverted loggingEnabled
+		if enabled, err := strconv.ParseBool(value); err == nil {
+			value = strconv.FormatBool(!enabled)
+		}
+	}
+	return serviceFilter{field: field, value: value, contains: filter[i] == '~'}, nil
+}
+
+// matchesServiceFilters returns whether one of the values of each of the filtered fields matches the filter.
+func matchesServiceFilters(sr proxy.Service, filters []serviceFilter) bool {
+	v := reflect.ValueOf(sr)
+	for _, filter := range filters {
+		matched := false
+		for _, value := range getServiceFieldValues(v.Field(filter.field)) {
+			if value == filter.value || (filter.contains && strings.Contains(value, filter.value)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// getServiceFieldValues returns the values of the field as strings. Nil is returned for the fields that cannot be filtered by.
+func getServiceFieldValues(v reflect.Value) []string {
+	switch value := v.Interface().(type) {
+	case string:
+		return []string{value}
+	case []string:
+		return append([]string{}, value...)
+	case bool:
+		return []string{strconv.FormatBool(value)}
+	case int:
+		return []string{strconv.Itoa(value)}
+	}
+	return nil
+}
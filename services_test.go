@@ -0,0 +1,120 @@
+// +build !integration
+
+package main
+
+import (
+	"./proxy"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ServicesTestSuite struct {
+	suite.Suite
+}
+
+func (s *ServicesTestSuite) SetupTest() {
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{
+		"team-a-api":     {ServiceName: "team-a-api", ServiceDomain: []string{"api.acme.com"}, ServiceCert: "cert", Users: []proxy.User{{Username: "user", Password: "pass"}}},
+		"team-a-web":     {ServiceName: "team-a-web", ServiceDomain: []string{"acme.com"}, HttpsOnly: true},
+		"team-b-web":     {ServiceName: "team-b-web", ServiceDomain: []string{"b.acme.com"}, HttpsOnly: true},
+		"team-c.billing": {ServiceName: "team-c.billing", Namespace: "team-c"},
+	})
+	proxy.Instance = proxyMock
+}
+
+func (s *ServicesTestSuite) getServiceList(url string) (int, map[string]interface{}) {
+	req, _ := http.NewRequest("GET", url, nil)
+	rw := httptest.NewRecorder()
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+	actual := map[string]interface{}{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	return rw.Code, actual
+}
+
+func (s *ServicesTestSuite) getServiceNames(list map[string]interface{}) []string {
+	names := []string{}
+	services, _ := list["Services"].([]interface{})
+	for _, sr := range services {
+		names = append(names, sr.(map[string]interface{})["ServiceName"].(string))
+	}
+	return names
+}
+
+func (s *ServicesTestSuite) Test_ListServices_ReturnsAllServicesSortedByName() {
+	code, actual := s.getServiceList("/v1/docker-flow-proxy/services")
+
+	s.Equal(http.StatusOK, code)
+	s.Equal(float64(4), actual["Total"])
+	s.Equal([]string{"team-a-api", "team-a-web", "team-b-web", "team-c.billing"}, s.getServiceNames(actual))
+}
+
+func (s *ServicesTestSuite) Test_ListServices_ReturnsPage_WhenLimitAndOffsetAreSpecified() {
+	code, actual := s.getServiceList("/v1/docker-flow-proxy/services?limit=2&offset=1")
+
+	s.Equal(http.StatusOK, code)
+	s.Equal(float64(4), actual["Total"])
+	s.Equal([]string{"team-a-web", "team-b-web"}, s.getServiceNames(actual))
+}
+
+func (s *ServicesTestSuite) Test_ListServices_ReturnsMatchingServices_WhenFiltersAreSpecified() {
+	_, contains := s.getServiceList("/v1/docker-flow-proxy/services?filter=name~team-a")
+	_, combined := s.getServiceList("/v1/docker-flow-proxy/services?filter=serviceName~web&filter=serviceDomain=acme.com")
+	_, boolean := s.getServiceList("/v1/docker-flow-proxy/services?filter=httpsOnly=true")
+
+	s.Equal([]string{"team-a-api", "team-a-web"}, s.getServiceNames(contains))
+	s.Equal(float64(2), contains["Total"])
+	s.Equal([]string{"team-a-web"}, s.getServiceNames(combined))
+	s.Equal([]string{"team-a-web", "team-b-web"}, s.getServiceNames(boolean))
+}
+
+func (s *ServicesTestSuite) Test_ListServices_ReturnsSelectedFields_WhenFieldsAreSpecified() {
+	_, actual := s.getServiceList("/v1/docker-flow-proxy/services?fields=serviceName,serviceDomain&limit=1")
+
+	s.Equal([]interface{}{
+		map[string]interface{}{"ServiceName": "team-a-api", "ServiceDomain": []interface{}{"api.acme.com"}},
+	}, actual["Services"])
+}
+
+func (s *ServicesTestSuite) Test_ListServices_RedactsPasswordsAndCertificates() {
+	_, all := s.getServiceList("/v1/docker-flow-proxy/services?limit=1")
+	_, selected := s.getServiceList("/v1/docker-flow-proxy/services?fields=serviceCert,users&limit=1")
+
+	sr := all["Services"].([]interface{})[0].(map[string]interface{})
+	s.Equal("*****", sr["ServiceCert"])
+	s.Equal("*****", sr["Users"].([]interface{})[0].(map[string]interface{})["Password"])
+	s.Equal([]interface{}{
+		map[string]interface{}{
+			"ServiceCert": "*****",
+			"Users":       []interface{}{map[string]interface{}{"Username": "user", "Password": "*****", "PassEncrypted": false}},
+		},
+	}, selected["Services"])
+}
+
+func (s *ServicesTestSuite) Test_ListServices_ReturnsServicesOfTheNamespace_WhenNamespaceIsSpecified() {
+	_, actual := s.getServiceList("/v1/docker-flow-proxy/services?namespace=team-c")
+
+	s.Equal([]string{"team-c.billing"}, s.getServiceNames(actual))
+}
+
+func (s *ServicesTestSuite) Test_ListServices_ReturnsStatus400_WhenQueriesAreInvalid() {
+	for _, query := range []string{"filter=unknown~a", "filter=serviceName", "filter=serviceDest~a", "filter=serviceCert~a", "fields=unknown", "limit=-1", "offset=a"} {
+		code, _ := s.getServiceList("/v1/docker-flow-proxy/services?" + query)
+
+		s.Equal(http.StatusBadRequest, code, query)
+	}
+}
+
+func TestServicesUnitTestSuite(t *testing.T) {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	logPrintfOrig := logPrintf
+	defer func() { logPrintf = logPrintfOrig }()
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(ServicesTestSuite))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"./actions"
+	"./proxy"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+var statTemplateFile = os.Stat
+
+// The versions (modification time and size) of the template files of the services keyed by their paths
+var templateFileVersions = map[string]string{}
+
+// watchTemplateFiles reconfigures the services whose templateFePath, templateBePath, or frontendTemplatePath files changed on disk.
+// Templates referenced as Docker configs or secrets are checked at the paths they are mounted to.
+func (m *Serve) watchTemplateFiles(interval time.Duration) {
+	for range time.Tick(interval) {
+		m.reconfigureChangedTemplates()
+	}
+}
+
+func (m *Serve) reconfigureChangedTemplates() {
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	services := proxy.Instance.GetServices()
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	versions := map[string]string{}
+	for _, name := range names {
+		sr := services[name]
+		changed := false
+		paths := []string{sr.TemplateFePath, sr.TemplateBePath}
+		for _, sd := range sr.ServiceDest {
+			paths = append(paths, sd.FrontendTemplatePath)
+		}
+		for _, path := range paths {
+			if len(path) == 0 {
+				continue
+			}
+			if _, ok := versions[path]; !ok {
+				versions[path] = getTemplateFileVersion(path)
+			}
+			// Files seen for the first time and files that were removed do not trigger reconfiguration
+			if previous, ok := templateFileVersions[path]; ok && len(versions[path]) > 0 && previous != versions[path] {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		logPrintf("The templates of the service %s changed. Reconfiguring the service.", name)
+		action := actions.NewReconfigure(m.BaseReconfigure, sr, m.Mode)
+		if err := action.Execute([]string{}); err != nil {
+			logWarnf("Could not reconfigure the service %s with the changed templates\n%s", name, err.Error())
+		}
+	}
+	templateFileVersions = versions
+}
+
+func getTemplateFileVersion(path string) string {
+	info, err := statTemplateFile(proxy.ResolveDockerPath(path))
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d,%d", info.ModTime().UnixNano(), info.Size())
+}
@@ -0,0 +1,103 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"./actions"
+	"./proxy"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TemplateWatchTestSuite struct {
+	suite.Suite
+	fePath       string
+	bePath       string
+	reconfigured []string
+}
+
+func (s *TemplateWatchTestSuite) SetupTest() {
+	prefix := fmt.Sprintf("%s/template-watch-%d", os.TempDir(), time.Now().UnixNano())
+	s.fePath = prefix + "-fe.tmpl"
+	s.bePath = prefix + "-be.tmpl"
+	ioutil.WriteFile(s.fePath, []byte("frontend"), 0644)
+	ioutil.WriteFile(s.bePath, []byte("backend"), 0644)
+	templateFileVersions = map[string]string{}
+	s.reconfigured = []string{}
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{
+		"my-service":    {ServiceName: "my-service", TemplateFePath: s.fePath, TemplateBePath: s.bePath},
+		"other-service": {ServiceName: "other-service"},
+	})
+	proxy.Instance = proxyMock
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		s.reconfigured = append(s.reconfigured, serviceData.ServiceName)
+		return getReconfigureMock("")
+	}
+}
+
+func (s *TemplateWatchTestSuite) TearDownTest() {
+	os.Remove(s.fePath)
+	os.Remove(s.bePath)
+}
+
+func (s *TemplateWatchTestSuite) Test_ReconfigureChangedTemplates_DoesNotReconfigure_WhenTemplatesAreSeenForTheFirstTime() {
+	srv := Serve{}
+
+	srv.reconfigureChangedTemplates()
+
+	s.Empty(s.reconfigured)
+}
+
+func (s *TemplateWatchTestSuite) Test_ReconfigureChangedTemplates_ReconfiguresService_WhenTemplateChanges() {
+	srv := Serve{}
+	srv.reconfigureChangedTemplates()
+	ioutil.WriteFile(s.bePath, []byte("backend with a new server"), 0644)
+
+	srv.reconfigureChangedTemplates()
+	srv.reconfigureChangedTemplates()
+
+	s.Equal([]string{"my-service"}, s.reconfigured)
+}
+
+func (s *TemplateWatchTestSuite) Test_ReconfigureChangedTemplates_ReconfiguresService_WhenFrontendTemplateOfDestinationChanges() {
+	proxyMock := getProxyMock("GetServices")
+	proxyMock.On("GetServices").Return(map[string]proxy.Service{
+		"mqtt": {ServiceName: "mqtt", ReqMode: "tcp", ServiceDest: []proxy.ServiceDest{{SrcPort: 1883, Port: "1883", FrontendTemplatePath: s.fePath}}},
+	})
+	proxy.Instance = proxyMock
+	srv := Serve{}
+	srv.reconfigureChangedTemplates()
+	ioutil.WriteFile(s.fePath, []byte("frontend with new options"), 0644)
+
+	srv.reconfigureChangedTemplates()
+
+	s.Equal([]string{"mqtt"}, s.reconfigured)
+}
+
+func (s *TemplateWatchTestSuite) Test_ReconfigureChangedTemplates_DoesNotReconfigure_WhenTemplateIsRemoved() {
+	srv := Serve{}
+	srv.reconfigureChangedTemplates()
+	os.Remove(s.fePath)
+
+	srv.reconfigureChangedTemplates()
+
+	s.Empty(s.reconfigured)
+}
+
+func TestTemplateWatchUnitTestSuite(t *testing.T) {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	logPrintfOrig := logPrintf
+	defer func() { logPrintf = logPrintfOrig }()
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(TemplateWatchTestSuite))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"./proxy"
+	"strconv"
+	"time"
+)
+
+var generateDhParams = proxy.GenerateDhParams
+var newTlsTicketKey = proxy.NewTlsTicketKey
+var writeTlsTicketKeys = proxy.WriteTlsTicketKeys
+var rotateTlsTicketKey = proxy.RotateTlsTicketKey
+var timeNow = time.Now
+
+// The registry "service" that stores the TLS ticket keys shared by the replicas keyed by their rotation periods
+const tlsTicketKeysRegistryName = "tls-ticket-keys"
+
+// initTls generates the DH parameters (DH_PARAMS_SIZE) in the background and the TLS ticket keys rotated every TLS_TICKET_KEYS_ROTATION seconds.
+func (m *Serve) initTls() {
+	if bits, _ := strconv.Atoi(proxy.GetSecretOrEnvVar("DH_PARAMS_SIZE", "0")); bits > 0 {
+		go m.generateDhParams(bits)
+	}
+	seconds, _ := strconv.ParseInt(proxy.GetSecretOrEnvVar("TLS_TICKET_KEYS_ROTATION", "0"), 10, 64)
+	if seconds <= 0 {
+		return
+	}
+	// The replicas rotate the keys at the same time since the periods are derived from the clock
+	period := timeNow().Unix() / seconds
+	keys := []string{}
+	for p := period - 1; p <= period+1; p++ {
+		key, err := m.getTlsTicketKey(p)
+		if err != nil {
+			logWarnf("Could not initialize the TLS ticket keys. HAProxy will generate its own keys.\n%s", err.Error())
+			return
+		}
+		keys = append(keys, key)
+	}
+	if err := writeTlsTicketKeys(m.ConfigsPath, keys); err != nil {
+		logWarnf("%s", err.Error())
+		return
+	}
+	go m.rotateTlsTicketKeys(seconds, period)
+}
+
+// generateDhParams reloads the proxy once the DH parameters are generated so that they replace the default ones.
+func (m *Serve) generateDhParams(bits int) {
+	logPrintf("Generating %d bit DH parameters. It might take a while.", bits)
+	generated, err := generateDhParams(m.ConfigsPath, bits)
+	if err != nil {
+		logWarnf("%s", err.Error())
+		return
+	} else if !generated {
+		return
+	}
+	reconfigureMu.Lock()
+	defer reconfigureMu.Unlock()
+	reload.Execute(true, "")
+}
+
+// rotateTlsTicketKeys rotates the keys at the start of each period until the proxy shuts down.
+func (m *Serve) rotateTlsTicketKeys(seconds, period int64) {
+	for {
+		period++
+		select {
+		case <-shutdownCh:
+			return
+		case <-time.After(time.Unix(period*seconds, 0).Sub(timeNow())):
+			m.rotateTlsTicketKey(period)
+		}
+	}
+}
+
+// rotateTlsTicketKey adds the key of the period after the one that starts.
+// The key of the starting period, added during the previous rotation, starts encrypting the tickets.
+// Replicas whose clocks are slightly ahead already encrypt with the next key, which the others can decrypt.
+// The key that was dropped from the keys file is deleted from Consul since no replica uses it anymore.
+func (m *Serve) rotateTlsTicketKey(period int64) {
+	key, err := m.getTlsTicketKey(period + 1)
+	if err == nil {
+		err = rotateTlsTicketKey(m.ConfigsPath, key)
+	}
+	if err != nil {
+		logWarnf("Could not rotate the TLS ticket keys\n%s", err.Error())
+		return
+	}
+	if len(m.ConsulAddresses) > 0 {
+		if err := registryInstance.DeleteServiceAttribute(
+			m.ConsulAddresses,
+			tlsTicketKeysRegistryName,
+			strconv.FormatInt(period-2, 10),
+			m.InstanceName,
+		); err != nil {
+			logWarnf("Could not delete the expired TLS ticket key\n%s", err.Error())
+		}
+	}
+}
+
+// getTlsTicketKey returns the key of the rotation period.
+// With Consul, the key is shared by all the replicas. The first replica that needs it generates it.
+func (m *Serve) getTlsTicketKey(period int64) (string, error) {
+	key, err := newTlsTicketKey()
+	if err != nil || len(m.ConsulAddresses) == 0 {
+		return key, err
+	}
+	return registryInstance.PutServiceAttributeIfAbsent(
+		m.ConsulAddresses,
+		tlsTicketKeysRegistryName,
+		strconv.FormatInt(period, 10),
+		key,
+		m.InstanceName,
+	)
+}
@@ -0,0 +1,156 @@
+// +build !integration
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type TlsTestSuite struct {
+	suite.Suite
+	written [][]string
+	rotated []string
+}
+
+func (s *TlsTestSuite) SetupTest() {
+	s.written = [][]string{}
+	s.rotated = []string{}
+	keys := 0
+	newTlsTicketKey = func() (string, error) {
+		keys++
+		return fmt.Sprintf("key-%d", keys), nil
+	}
+	writeTlsTicketKeys = func(configsPath string, keys []string) error {
+		s.written = append(s.written, keys)
+		return nil
+	}
+	rotateTlsTicketKey = func(configsPath, key string) error {
+		s.rotated = append(s.rotated, key)
+		return nil
+	}
+	timeNow = func() time.Time {
+		return time.Unix(3600*10+5, 0)
+	}
+}
+
+func TestTlsUnitTestSuite(t *testing.T) {
+	newTlsTicketKeyOrig := newTlsTicketKey
+	writeTlsTicketKeysOrig := writeTlsTicketKeys
+	rotateTlsTicketKeyOrig := rotateTlsTicketKey
+	timeNowOrig := timeNow
+	defer func() {
+		newTlsTicketKey = newTlsTicketKeyOrig
+		writeTlsTicketKeys = writeTlsTicketKeysOrig
+		rotateTlsTicketKey = rotateTlsTicketKeyOrig
+		timeNow = timeNowOrig
+	}()
+	suite.Run(t, new(TlsTestSuite))
+}
+
+// initTls
+
+func (s *TlsTestSuite) Test_InitTls_DoesNotWriteTlsTicketKeys_WhenRotationIsNotSet() {
+	srv := Serve{}
+
+	srv.initTls()
+
+	s.Empty(s.written)
+}
+
+func (s *TlsTestSuite) Test_InitTls_WritesKeysOfPreviousCurrentAndNextPeriods() {
+	defer func() { os.Unsetenv("TLS_TICKET_KEYS_ROTATION") }()
+	os.Setenv("TLS_TICKET_KEYS_ROTATION", "3600")
+	srv := Serve{}
+
+	srv.initTls()
+
+	s.Equal([][]string{{"key-1", "key-2", "key-3"}}, s.written)
+}
+
+func (s *TlsTestSuite) Test_InitTls_UsesKeysStoredInConsul() {
+	defer func() { os.Unsetenv("TLS_TICKET_KEYS_ROTATION") }()
+	os.Setenv("TLS_TICKET_KEYS_ROTATION", "3600")
+	registryInstanceOrig := registryInstance
+	defer func() { registryInstance = registryInstanceOrig }()
+	mockObj := getRegistrarableMock("")
+	for period, key := range map[string]string{"9": "key-9", "10": "key-10", "11": "key-11"} {
+		mockObj.On("PutServiceAttributeIfAbsent", []string{"my-consul"}, "tls-ticket-keys", period, mock.Anything, "my-instance").Return(key, nil)
+	}
+	registryInstance = mockObj
+	srv := Serve{}
+	srv.ConsulAddresses = []string{"my-consul"}
+	srv.InstanceName = "my-instance"
+
+	srv.initTls()
+
+	s.Equal([][]string{{"key-9", "key-10", "key-11"}}, s.written)
+}
+
+// rotateTlsTicketKey
+
+func (s *TlsTestSuite) Test_RotateTlsTicketKey_AddsKeyOfNextPeriod() {
+	registryInstanceOrig := registryInstance
+	defer func() { registryInstance = registryInstanceOrig }()
+	mockObj := getRegistrarableMock("")
+	mockObj.On("PutServiceAttributeIfAbsent", []string{"my-consul"}, "tls-ticket-keys", "12", mock.Anything, "my-instance").Return("key-12", nil)
+	registryInstance = mockObj
+	srv := Serve{}
+	srv.ConsulAddresses = []string{"my-consul"}
+	srv.InstanceName = "my-instance"
+
+	srv.rotateTlsTicketKey(11)
+
+	s.Equal([]string{"key-12"}, s.rotated)
+	mockObj.AssertCalled(s.T(), "DeleteServiceAttribute", []string{"my-consul"}, "tls-ticket-keys", "9", "my-instance")
+}
+
+func (s *TlsTestSuite) Test_RotateTlsTicketKeys_Returns_WhenProxyShutsDown() {
+	defer func() { shutdownCh = make(chan struct{}) }()
+	close(shutdownCh)
+	done := make(chan struct{})
+	srv := Serve{}
+
+	go func() {
+		srv.rotateTlsTicketKeys(3600, 10)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		s.Fail("The rotation did not stop")
+	}
+	s.Empty(s.rotated)
+}
+
+// generateDhParams
+
+func (s *TlsTestSuite) Test_GenerateDhParams_ReloadsProxy_WhenParamsAreGenerated() {
+	generateDhParamsOrig := generateDhParams
+	defer func() { generateDhParams = generateDhParamsOrig }()
+	reloadOrig := reload
+	defer func() { reload = reloadOrig }()
+	generated := true
+	generateDhParams = func(configsPath string, bits int) (bool, error) {
+		return generated, nil
+	}
+	reloads := 0
+	reload = ReloadMock{ExecuteMock: func(recreate bool, listenerAddr string) error {
+		s.True(recreate)
+		reloads++
+		return nil
+	}}
+	srv := Serve{}
+
+	srv.generateDhParams(2048)
+	generated = false
+	srv.generateDhParams(2048)
+
+	s.Equal(1, reloads)
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"./logging"
+	"./proxy"
 	"./registry"
 	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
+	"os"
 )
 
 var readFile = ioutil.ReadFile
@@ -13,11 +15,32 @@ var httpListenAndServe = http.ListenAndServe
 var httpWriterSetContentType = func(w http.ResponseWriter, value string) {
 	w.Header().Set("Content-Type", value)
 }
-var logPrintf = log.Printf
+var logPrintf = logging.Infof
+var logWarnf = logging.Warnf
+var logRequestf = func(req *http.Request, format string, v ...interface{}) {
+	logging.WithFields(logging.Fields{
+		"method":     req.Method,
+		"path":       req.URL.Path,
+		"remoteAddr": req.RemoteAddr,
+	}).Infof(format, v...)
+}
 
 type Executable interface {
 	Execute(args []string) error
 }
 
 var lookupHost = net.LookupHost
+var getServiceStats = proxy.GetServiceStats
+var getBackendsHealth = proxy.GetBackendsHealth
+var getAllBackendStats = proxy.GetAllBackendStats
+var setCaptureEnabled = proxy.SetCaptureEnabled
+var setFault = proxy.SetFault
+var addBlocklistEntries = proxy.AddBlocklistEntries
+var removeBlocklistEntries = proxy.RemoveBlocklistEntries
+var setServerDrain = proxy.SetServerDrain
+var softStopProxy = proxy.SoftStop
+var runPreflightChecks = proxy.RunPreflightChecks
+var detectHaProxyVersion = proxy.DetectHaProxyVersion
+var httpPost = http.Post
+var osExit = os.Exit
 var registryInstance registry.Registrarable = registry.Consul{}